@@ -17,9 +17,10 @@ import (
 	"github.com/openmeet-team/survey/internal/db"
 	"github.com/openmeet-team/survey/internal/generator"
 	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/openmeet-team/survey/internal/ogimage"
+	"github.com/openmeet-team/survey/internal/ratelimit"
 	"github.com/openmeet-team/survey/internal/telemetry"
 	"github.com/openmeet-team/survey/internal/templates"
-	"github.com/tmc/langchaingo/llms/openai"
 )
 
 func main() {
@@ -45,8 +46,11 @@ func main() {
 		log.Fatalf("Failed to load database config: %v", err)
 	}
 
-	// Connect to database
-	database, err := db.Connect(ctx, dbConfig)
+	// Connect to database, waiting for it to come up; a shutdown signal
+	// stops the wait
+	connectCtx, stopConnect := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	database, err := db.Connect(connectCtx, dbConfig)
+	stopConnect()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -54,9 +58,34 @@ func main() {
 
 	log.Println("Connected to database successfully")
 
+	// Apply pending schema migrations when asked to
+	autoMigrate, err := db.AutoMigrateFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load database config: %v", err)
+	}
+	if autoMigrate {
+		if err := db.Migrate(ctx, database); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	if err := db.RegisterPoolStats(db.PoolAPI, database); err != nil {
+		log.Printf("Warning: Failed to register database pool metrics: %v", err)
+	}
+
 	// Create database queries instance
 	queries := db.NewQueries(database)
 
+	// Cache survey lookups by slug (SURVEY_CACHE_TTL=0 disables)
+	surveyCacheConfig, err := db.SurveyCacheConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load survey cache config: %v", err)
+	}
+	queries.EnableSurveyCache(surveyCacheConfig)
+	if surveyCacheConfig.Enabled() {
+		log.Printf("Survey cache enabled: %d entries, TTL %s", surveyCacheConfig.Size, surveyCacheConfig.TTL)
+	}
+
 	// Create Echo instance
 	e := echo.New()
 
@@ -66,38 +95,94 @@ func main() {
 
 	// Create OAuth storage for session management
 	oauthStorage := oauth.NewStorage(database)
+	tokenCipher, err := oauth.TokenCipherFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load OAuth token cipher (SESSION_ENCRYPTION_KEY): %v", err)
+	}
+	if tokenCipher != nil {
+		oauthStorage.SetTokenCipher(tokenCipher)
+	} else {
+		log.Println("Warning: SESSION_ENCRYPTION_KEY not set, OAuth tokens are stored unencrypted")
+	}
 
 	// Start OAuth cleanup worker (runs every hour)
+	sessionIdleTimeout, err := oauth.SessionIdleTimeoutFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load OAuth config: %v", err)
+	}
 	cleanupCtx, cancelCleanup := context.WithCancel(ctx)
-	go oauth.StartCleanupWorker(cleanupCtx, oauthStorage, 1*time.Hour)
+	go oauth.StartCleanupWorker(cleanupCtx, oauthStorage, 1*time.Hour, sessionIdleTimeout)
 
-	// Initialize AI survey generator if OpenAI API key is configured
+	// Drop cached surveys the consumer changes
+	go queries.ListenForSurveyInvalidations(cleanupCtx, dbConfig)
+
+	// Initialize AI survey generator if the selected provider's API key is configured
 	var surveyGenerator *generator.SurveyGenerator
 	var generatorRateLimiter *generator.RateLimiter
-	openaiKey := os.Getenv("OPENAI_API_KEY")
-	if openaiKey != "" {
-		modelName := "gpt-4o-mini"
-		llm, err := openai.New(
-			openai.WithToken(openaiKey),
-			openai.WithModel(modelName),
-		)
+	var prompts *generator.PromptSelector
+	providers, err := generator.ProvidersFromEnv()
+	if err != nil {
+		log.Printf("Warning: Failed to initialize AI provider: %v", err)
+	} else if providers == nil {
+		log.Println("AI survey generation disabled (no API key configured for AI_PROVIDER)")
+	} else {
+		surveyGenerator = generator.NewSurveyGeneratorWithProvider(providers[0], providers[1:]...)
+		surveyGenerator.SetProviderTimeout(generator.ProviderTimeoutFromEnv())
+		surveyGenerator.SetMaxInputTokens(generator.MaxInputTokensFromEnv())
+		surveyGenerator.SetTemperature(generator.TemperatureFromEnv())
+		prompts = generator.NewPromptSelector(generator.PromptConfigFromEnv())
+		surveyGenerator.SetPrompts(prompts)
+		// Counting OpenAI tokens exactly needs the tokenizer's ranks, which
+		// may be downloaded; input tokens are approximated until then
+		go func() {
+			if err := generator.LoadTokenizer(); err != nil {
+				log.Printf("Warning: Failed to load OpenAI tokenizer, approximating input tokens: %v", err)
+			}
+		}()
+		moderator, err := generator.ModeratorFromEnv()
 		if err != nil {
-			log.Printf("Warning: Failed to initialize OpenAI client: %v", err)
-		} else {
-			surveyGenerator = generator.NewSurveyGenerator(llm, modelName)
-			generatorRateLimiter = generator.NewRateLimiter()
-			config := generator.RateLimiterConfigFromEnv()
-			log.Printf("AI survey generation enabled with model: %s", modelName)
-			log.Printf("AI rate limits - Anonymous: %d requests per %.1f hours, Authenticated: %d requests per %.1f hours",
-				config.AnonLimit, config.AnonWindow.Hours(),
-				config.AuthLimit, config.AuthWindow.Hours())
+			log.Fatalf("Failed to configure AI input moderation: %v", err)
 		}
-	} else {
-		log.Println("AI survey generation disabled (OPENAI_API_KEY not configured)")
+		if moderator != nil {
+			surveyGenerator.SetModerator(moderator)
+			log.Println("AI input moderation enabled")
+		}
+		// Count generations in the database so every replica shares the
+		// limits and they survive deploys
+		config := generator.RateLimiterConfigFromEnv()
+		rateLimitCounter := ratelimit.NewDBLimiter(database)
+		generatorRateLimiter = generator.NewSharedRateLimiter(rateLimitCounter, config)
+		go rateLimitCounter.StartPruning(cleanupCtx, max(config.AnonWindow, config.AuthWindow), time.Hour)
+		log.Printf("AI survey generation enabled with provider: %s, model: %s", providers[0].Name(), providers[0].Model())
+		for _, fallback := range providers[1:] {
+			log.Printf("AI fallback provider: %s, model: %s", fallback.Name(), fallback.Model())
+		}
+		for _, share := range prompts.Active() {
+			log.Printf("AI system prompt version %s: %d%% of generations", share.Version, share.Percent)
+		}
+		log.Printf("AI rate limits - Anonymous: %d requests per %.1f hours, Authenticated: %d requests per %.1f hours",
+			config.AnonLimit, config.AnonWindow.Hours(),
+			config.AuthLimit, config.AuthWindow.Hours())
 	}
 
 	// Create generation logger
 	generationLogger := generator.NewGenerationLogger(queries)
+	generationLogger.SetRedactPII(generator.RedactPIIFromEnv())
+	if surveyGenerator != nil {
+		generationLogger.SetProvider(surveyGenerator.Provider(), surveyGenerator.Model())
+		generationLogger.SetGenerationSettings(generator.TemperatureFromEnv(), prompts.Version())
+	}
+
+	// Start AI log retention worker (runs daily); logs from earlier runs are
+	// pruned even when generation is currently disabled
+	go generator.StartLogRetentionWorker(cleanupCtx, queries, generator.LogRetentionConfigFromEnv(), generator.LogRetentionInterval)
+
+	// Handle <-> DID resolution, cached in memory and persisted in the handles
+	// table the consumer keeps current
+	identityResolver := oauth.NewResolver(oauth.NetworkResolverBackend{}, queries)
+
+	// Drop cached resolutions of identities the consumer sees change
+	go db.ListenForIdentityChanges(cleanupCtx, dbConfig, identityResolver)
 
 	// Create OAuth config (optional - requires OAUTH_SECRET_JWK_B64 and SERVER_HOST env vars)
 	var oauthConfig *oauth.Config
@@ -110,21 +195,70 @@ func main() {
 		if err != nil {
 			log.Fatalf("Failed to decode OAUTH_SECRET_JWK_B64: %v", err)
 		}
+		refreshThreshold, err := oauth.RefreshThresholdFromEnv()
+		if err != nil {
+			log.Fatalf("Failed to load OAuth config: %v", err)
+		}
 		oauthConfig = &oauth.Config{
-			Host:      host,
-			SecretJWK: string(secretJWKBytes),
+			Host:             host,
+			AdditionalHosts:  oauth.AdditionalHostsFromEnv(),
+			SecretJWK:        string(secretJWKBytes),
+			Scope:            os.Getenv("OAUTH_SCOPE"),
+			RefreshThreshold: refreshThreshold,
+		}
+		if err := oauthConfig.Validate(); err != nil {
+			log.Fatalf("Invalid OAuth configuration:\n%v", err)
 		}
-		oauthHandlers = oauth.NewHandlers(database, *oauthConfig)
+		oauthHandlers = oauth.NewHandlers(oauthStorage, *oauthConfig)
+		oauthHandlers.SetResolver(identityResolver)
 		log.Println("OAuth handlers initialized")
 	} else {
 		log.Println("OAuth disabled (OAUTH_SECRET_JWK_B64 and SERVER_HOST not configured)")
 	}
 
+	// Optionally refresh active sessions' tokens before requests need them
+	if oauthConfig != nil {
+		tokenRefreshConfig, err := oauth.TokenRefreshWorkerConfigFromEnv(oauthConfig.RefreshThreshold)
+		if err != nil {
+			log.Fatalf("Failed to load OAuth config: %v", err)
+		}
+		if tokenRefreshConfig.Enabled {
+			go oauth.StartTokenRefreshWorker(cleanupCtx, oauthStorage, *oauthConfig, tokenRefreshConfig)
+		}
+	}
+
 	// Create handlers with OAuth storage, config, and optional AI generator
 	handlers := api.NewHandlersWithOAuth(queries, oauthStorage, oauthConfig)
+	handlers.SetHandleResolver(identityResolver)
+	handlers.SetProfileCache(oauth.NewProfileCache(oauthStorage))
+
+	// Results polls wait for the consumer to announce new responses
+	responseListener := db.NewListener(dbConfig, 0)
+	handlers.SetResponseSubscriber(responseListener)
+
+	// Per-survey Open Graph images; link previews use the default image
+	// without them
+	ogFonts, err := ogimage.FallbackFontsFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load Open Graph image fonts: %v", err)
+	}
+	if ogRenderer, err := ogimage.NewRenderer(ogFonts...); err != nil {
+		log.Printf("Warning: survey Open Graph images disabled: %v", err)
+	} else {
+		handlers.SetOGImages(ogimage.NewCache(ogRenderer, ogimage.DefaultCacheSize))
+	}
+
 	if surveyGenerator != nil && generatorRateLimiter != nil {
 		handlers.SetGenerator(surveyGenerator, generatorRateLimiter)
 		handlers.SetLogger(generationLogger)
+		handlers.SetGenerationTimeout(generator.GenerationTimeoutFromEnv())
+		// Streams may be opened on a different replica than started them
+		handlers.SetGenerationStreams(queries)
+		handlers.SetTextSummaries(queries)
+		budgetConfig := generator.BudgetConfigFromEnv()
+		handlers.SetBudget(generator.NewBudgetLimiter(queries, budgetConfig))
+		log.Printf("AI daily budgets - Per user: $%.2f, Per anonymous IP: $%.2f, Global: $%.2f",
+			budgetConfig.UserDaily, budgetConfig.AnonDaily, budgetConfig.GlobalDaily)
 	}
 	healthHandlers := api.NewHealthHandlers(database)
 
@@ -140,6 +274,29 @@ func main() {
 		log.Printf("PostHog analytics enabled")
 	}
 
+	// Canonical survey URLs, for QR codes and link preview images
+	if host != "" {
+		handlers.SetSiteHost(host)
+		templates.SetSiteHost(host)
+	}
+
+	// Limit which sites may embed surveys (any site by default)
+	if ancestors := os.Getenv("EMBED_FRAME_ANCESTORS"); ancestors != "" {
+		handlers.SetEmbedFrameAncestors(ancestors)
+		log.Printf("Survey embeds limited to: %s", ancestors)
+	}
+
+	// Admin API (usage dashboards, audit log) is off unless a token is configured
+	if adminToken := os.Getenv("ADMIN_API_TOKEN"); adminToken != "" {
+		handlers.SetAdmin(adminToken, queries)
+		handlers.SetAuditLog(queries)
+		handlers.SetGenerationLogs(queries)
+		if prompts != nil {
+			handlers.SetPromptVersions(prompts)
+		}
+		log.Printf("Admin API enabled")
+	}
+
 	// Configure noindex meta tag (default: block indexing, set NOINDEX=false to allow)
 	if noindex := os.Getenv("NOINDEX"); noindex == "false" {
 		templates.SetNoIndex(false)
@@ -148,8 +305,18 @@ func main() {
 		log.Println("Search engine indexing blocked (noindex meta tag enabled)")
 	}
 
+	// The site's X account, named in Twitter cards (optional)
+	templates.SetTwitterSite(os.Getenv("TWITTER_SITE"))
+
+	// Proxies trusted to report client IPs, for rate limiting
+	trustedProxies, err := ratelimit.IPExtractorFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load rate limit config: %v", err)
+	}
+	api.SetTrustedProxies(trustedProxies)
+
 	// Setup routes (includes metrics and request ID middleware)
-	api.SetupRoutes(e, handlers, healthHandlers, oauthHandlers, database)
+	api.SetupRoutes(e, handlers, healthHandlers, oauthHandlers, oauthStorage)
 
 	// Start server with graceful shutdown
 	port := os.Getenv("PORT")
@@ -173,9 +340,12 @@ func main() {
 
 	log.Println("Shutting down server...")
 
-	// Stop cleanup worker
+	// Stop cleanup and retention workers
 	cancelCleanup()
 
+	// Release results polls waiting on new responses
+	responseListener.Close()
+
 	// Graceful shutdown with timeout
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
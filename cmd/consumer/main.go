@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
@@ -15,7 +19,26 @@ import (
 	"github.com/openmeet-team/survey/internal/telemetry"
 )
 
+// shutdownGrace is how long past the drain timeout main waits for the cursor
+// flush and websocket close before exiting anyway
+const shutdownGrace = 5 * time.Second
+
 func main() {
+	backfillFrom := flag.String("backfill-from", os.Getenv("BACKFILL_FROM"), "replay events from this time_us cursor (0 for all retained history) and exit once caught up")
+	commitCursor := flag.Bool("commit-cursor", false, "during a backfill, also advance the live jetstream_cursor")
+	catchUpLag := flag.Duration("backfill-catchup-lag", consumer.DefaultCatchUpLag, "stop a backfill once events are within this much of now")
+	blockDID := flag.String("block", "", "add this DID to the blocklist and exit")
+	unblockDID := flag.String("unblock", "", "remove this DID from the blocklist and exit")
+	blockReason := flag.String("block-reason", "", "reason recorded with --block")
+	sweep := flag.Bool("sweep", false, "with --block, also delete the DID's existing surveys, responses and comments")
+	restoreURI := flag.String("restore", "", "restore this soft-deleted survey (at:// URI) and exit")
+	actor := flag.String("actor", "", "your DID, recorded in the audit log; required with --block, --unblock and --restore")
+	flag.Parse()
+
+	if (*blockDID != "" || *unblockDID != "" || *restoreURI != "") && !strings.HasPrefix(*actor, "did:") {
+		log.Fatal("--actor must be set to your DID with --block, --unblock and --restore")
+	}
+
 	log.Println("survey-consumer: Starting ATProto Jetstream consumer...")
 
 	// Initialize OpenTelemetry tracing
@@ -37,8 +60,11 @@ func main() {
 		log.Fatalf("Failed to load database config: %v", err)
 	}
 
-	// Connect to database
-	database, err := db.Connect(ctx, cfg)
+	// Connect to database, waiting for it to come up; a shutdown signal
+	// stops the wait
+	connectCtx, stopConnect := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	database, err := db.Connect(connectCtx, cfg)
+	stopConnect()
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -46,20 +72,56 @@ func main() {
 
 	log.Println("Connected to database")
 
+	// Apply pending schema migrations when asked to
+	autoMigrate, err := db.AutoMigrateFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load database config: %v", err)
+	}
+	if autoMigrate {
+		if err := db.Migrate(ctx, database); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
 	// Create queries instance
 	queries := db.NewQueries(database)
 
+	// Blocklist management runs once and exits without consuming
+	if *blockDID != "" || *unblockDID != "" {
+		runBlocklistCommand(ctx, queries, *actor, *blockDID, *unblockDID, *blockReason, *sweep)
+		return
+	}
+
+	// Restoring a deleted survey runs once and exits without consuming
+	if *restoreURI != "" {
+		if err := consumer.RestoreSurvey(ctx, queries, *actor, *restoreURI); err != nil {
+			log.Fatalf("Failed to restore %s: %v", *restoreURI, err)
+		}
+		log.Printf("Restored %s", *restoreURI)
+		return
+	}
+
+	if err := db.RegisterPoolStats(db.PoolConsumer, database); err != nil {
+		log.Printf("Warning: Failed to register database pool metrics: %v", err)
+	}
+
 	// Start metrics server for Prometheus scraping
 	metricsPort := os.Getenv("METRICS_PORT")
 	if metricsPort == "" {
 		metricsPort = "2112"
 	}
+
+	// Readiness fails once nothing has been received for this long
+	staleAfter, err := consumer.StaleAfterFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	health := consumer.NewHealthChecker(database, staleAfter)
+
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ok"))
-		})
+		http.HandleFunc("/health", health.ReadyHandler)
+		http.HandleFunc("/live", health.LiveHandler)
 		log.Printf("Metrics server listening on :%s", metricsPort)
 		if err := http.ListenAndServe(":"+metricsPort, nil); err != nil {
 			log.Printf("Metrics server error: %v", err)
@@ -67,9 +129,9 @@ func main() {
 	}()
 
 	// Build Jetstream URL
-	// Subscribe to survey, response, and results collections
+	// Subscribe to survey, response, results, and comment collections
 	// Note: Jetstream requires repeated query params, not comma-separated values
-	jetstreamURL := "wss://jetstream2.us-east.bsky.network/subscribe?wantedCollections=net.openmeet.survey&wantedCollections=net.openmeet.survey.response&wantedCollections=net.openmeet.survey.results"
+	jetstreamURL := "wss://jetstream2.us-east.bsky.network/subscribe?wantedCollections=net.openmeet.survey&wantedCollections=net.openmeet.survey.response&wantedCollections=net.openmeet.survey.results&wantedCollections=net.openmeet.survey.comment"
 
 	// Create context with cancellation for graceful shutdown
 	ctx, cancel := context.WithCancel(ctx)
@@ -79,17 +141,90 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Number of concurrent event workers (events for the same DID stay ordered)
+	opts := consumer.Options{Workers: consumer.DefaultWorkers, DrainTimeout: consumer.DefaultDrainTimeout}
+	if workersStr := os.Getenv("CONSUMER_WORKERS"); workersStr != "" {
+		workers, err := strconv.Atoi(workersStr)
+		if err != nil || workers < 1 {
+			log.Fatalf("Invalid CONSUMER_WORKERS: %q", workersStr)
+		}
+		opts.Workers = workers
+	}
+	log.Printf("Processing events with %d worker(s)", opts.Workers)
+
+	// Record size limits (CONSUMER_MAX_RECORD_BYTES, CONSUMER_MAX_QUESTIONS, ...)
+	limits, err := consumer.LimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid record limits: %v", err)
+	}
+	opts.Limits = limits
+
+	// Batched response inserts (CONSUMER_BATCH_SIZE, CONSUMER_BATCH_INTERVAL)
+	batch, err := consumer.BatchOptionsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid batch options: %v", err)
+	}
+	opts.Batch = batch
+	if batch.Size > 1 {
+		if opts.Workers < 2 {
+			log.Printf("WARNING: CONSUMER_BATCH_SIZE has no effect with a single worker")
+		}
+		log.Printf("Batching response inserts: up to %d rows or %v", batch.Size, batch.Interval)
+	}
+
+	// Soft-deleted surveys are purged after SURVEY_DELETE_GRACE_DAYS
+	deleteGrace, err := consumer.DeleteGracePeriodFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Backfill mode replays history without touching the live cursor unless asked
+	if *backfillFrom != "" {
+		from, err := strconv.ParseInt(*backfillFrom, 10, 64)
+		if err != nil || from < 0 {
+			log.Fatalf("Invalid backfill cursor: %q", *backfillFrom)
+		}
+		if lagStr := os.Getenv("BACKFILL_CATCHUP_LAG"); lagStr != "" && !flagSet("backfill-catchup-lag") {
+			lag, err := time.ParseDuration(lagStr)
+			if err != nil {
+				log.Fatalf("Invalid BACKFILL_CATCHUP_LAG: %q", lagStr)
+			}
+			*catchUpLag = lag
+		}
+		opts.Backfill = &consumer.BackfillOptions{
+			From:         from,
+			CommitCursor: *commitCursor,
+			CatchUpLag:   *catchUpLag,
+			Actor:        *actor,
+		}
+	}
+
+	go consumer.StartSurveyPurgeWorker(ctx, queries, deleteGrace, consumer.SurveyPurgeInterval)
+
 	// Run consumer in goroutine
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- consumer.RunWithReconnect(ctx, jetstreamURL, queries)
+		errChan <- consumer.RunWithReconnect(ctx, jetstreamURL, queries, opts)
 	}()
 
 	// Wait for shutdown signal or error
 	select {
 	case sig := <-sigChan:
-		log.Printf("Received signal %v, shutting down...", sig)
+		log.Printf("Received signal %v, draining in-flight events...", sig)
+		drainStart := time.Now()
 		cancel()
+
+		// The consumer finishes in-flight events (up to DrainTimeout), flushes
+		// the cursor, and closes the websocket before returning
+		select {
+		case err := <-errChan:
+			if err != nil {
+				log.Printf("Consumer error: %v", err)
+			}
+			log.Printf("Drained in %v", time.Since(drainStart).Round(time.Millisecond))
+		case <-time.After(opts.DrainTimeout + shutdownGrace):
+			log.Printf("Drain still running after %v, exiting anyway", time.Since(drainStart).Round(time.Millisecond))
+		}
 	case err := <-errChan:
 		if err != nil {
 			log.Printf("Consumer error: %v", err)
@@ -98,3 +233,38 @@ func main() {
 
 	log.Println("survey-consumer: Shutdown complete")
 }
+
+// runBlocklistCommand handles --block and --unblock
+func runBlocklistCommand(ctx context.Context, queries *db.Queries, actor, blockDID, unblockDID, reason string, sweep bool) {
+	if blockDID != "" && unblockDID != "" {
+		log.Fatal("--block and --unblock cannot be used together")
+	}
+
+	if unblockDID != "" {
+		if err := consumer.UnblockDID(ctx, queries, actor, unblockDID); err != nil {
+			log.Fatalf("Failed to unblock %s: %v", unblockDID, err)
+		}
+		log.Printf("Unblocked %s", unblockDID)
+		return
+	}
+
+	result, err := consumer.BlockDID(ctx, queries, actor, blockDID, reason, sweep)
+	if err != nil {
+		log.Fatalf("Failed to block %s: %v", blockDID, err)
+	}
+	log.Printf("Blocked %s", blockDID)
+	if sweep {
+		log.Printf("Swept %d surveys, %d responses and %d comments", result.SurveysAffected, result.ResponsesAffected, result.CommentsAffected)
+	}
+}
+
+// flagSet reports whether a flag was passed on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
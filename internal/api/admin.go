@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/generator"
+)
+
+const (
+	// defaultAIStatsDays is the window GetAIStats reports when none is given
+	defaultAIStatsDays = 30
+
+	// maxAIStatsDays bounds the window so a request can't ask for years of buckets
+	maxAIStatsDays = 366
+
+	// statsDateLayout is the format of the from and to query parameters
+	statsDateLayout = "2006-01-02"
+)
+
+// GenerationStatsInterface defines the interface for AI generation usage statistics
+type GenerationStatsInterface interface {
+	GetGenerationStats(ctx context.Context, from, to time.Time) ([]db.GenerationStatsBucket, error)
+}
+
+// AuditLogInterface defines the interface for reading the audit log
+type AuditLogInterface interface {
+	ListAuditEntries(ctx context.Context, filter db.AuditFilter, limit, offset int) ([]db.AuditEntry, error)
+}
+
+// GenerationLogsInterface defines the interface for listing AI generation logs
+type GenerationLogsInterface interface {
+	GetRecentGenerationLogs(ctx context.Context, limit, offset int) ([]*generator.AIGenerationLog, error)
+	GetGenerationLogsByModel(ctx context.Context, model string, limit, offset int) ([]*generator.AIGenerationLog, error)
+	GetGenerationLogsByStatus(ctx context.Context, status string, limit, offset int) ([]*generator.AIGenerationLog, error)
+	GetGenerationLogsByUser(ctx context.Context, userID string, limit, offset int) ([]*generator.AIGenerationLog, error)
+}
+
+// PromptVersionsInterface defines the interface for reporting the system
+// prompt versions in use
+type PromptVersionsInterface interface {
+	Active() []generator.PromptShare
+}
+
+// SetAdmin enables the admin API, authenticated by a bearer token. Admin
+// routes respond 404 until a non-empty token is set.
+func (h *Handlers) SetAdmin(token string, stats GenerationStatsInterface) {
+	h.adminToken = token
+	h.aiStats = stats
+}
+
+// SetAuditLog enables the admin audit log endpoint
+func (h *Handlers) SetAuditLog(audit AuditLogInterface) {
+	h.auditLog = audit
+}
+
+// SetGenerationLogs enables the admin AI generation log listing
+func (h *Handlers) SetGenerationLogs(logs GenerationLogsInterface) {
+	h.aiLogs = logs
+}
+
+// SetPromptVersions enables the admin endpoint reporting the system prompt
+// versions in use
+func (h *Handlers) SetPromptVersions(prompts PromptVersionsInterface) {
+	h.promptVersions = prompts
+}
+
+// RequireAdmin rejects requests without the admin bearer token
+func (h *Handlers) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if h.adminToken == "" {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not found"})
+		}
+
+		token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Unauthorized"})
+		}
+
+		return next(c)
+	}
+}
+
+// GetAIStats returns AI generation usage per UTC day for the dashboard.
+// from and to are inclusive dates (YYYY-MM-DD); the default is the last 30 days.
+// GET /api/v1/admin/ai-stats?from=2025-01-01&to=2025-01-31
+func (h *Handlers) GetAIStats(c echo.Context) error {
+	if h.aiStats == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not found"})
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(statsDateLayout, toStr)
+		if err != nil {
+			return ValidationError(c, "Invalid date", fmt.Sprintf("to must be a date like %s", statsDateLayout))
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(defaultAIStatsDays - 1))
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(statsDateLayout, fromStr)
+		if err != nil {
+			return ValidationError(c, "Invalid date", fmt.Sprintf("from must be a date like %s", statsDateLayout))
+		}
+		from = parsed
+	}
+
+	if to.Before(from) {
+		return ValidationError(c, "Invalid date range", "from must not be after to")
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxAIStatsDays {
+		return ValidationError(c, "Invalid date range", fmt.Sprintf("at most %d days can be requested", maxAIStatsDays))
+	}
+
+	// to is inclusive, the query's upper bound is not
+	buckets, err := h.aiStats.GetGenerationStats(c.Request().Context(), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return InternalServerError(c, "Failed to retrieve AI generation stats", err)
+	}
+	if buckets == nil {
+		buckets = []db.GenerationStatsBucket{}
+	}
+
+	return c.JSON(http.StatusOK, AIStatsResponse{
+		From: from.Format(statsDateLayout),
+		To:   to.Format(statsDateLayout),
+		Days: buckets,
+	})
+}
+
+// GetAuditLog returns a page of audit log entries, newest first, optionally
+// filtered by actor, action and target. since and until are RFC 3339
+// timestamps; since is inclusive and until exclusive.
+// GET /api/v1/admin/audit?actor=did:plc:...&action=did.block&since=2025-01-01T00:00:00Z&limit=20&offset=0
+func (h *Handlers) GetAuditLog(c echo.Context) error {
+	if h.auditLog == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not found"})
+	}
+
+	limit := 20 // default
+	offset := 0
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	filter := db.AuditFilter{
+		Actor:  c.QueryParam("actor"),
+		Action: c.QueryParam("action"),
+		Target: c.QueryParam("target"),
+	}
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		str := c.QueryParam(bound.param)
+		if str == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return ValidationError(c, "Invalid time", fmt.Sprintf("%s must be an RFC 3339 timestamp like 2025-01-01T00:00:00Z", bound.param))
+		}
+		*bound.value = parsed
+	}
+
+	entries, err := h.auditLog.ListAuditEntries(c.Request().Context(), filter, limit, offset)
+	if err != nil {
+		return InternalServerError(c, "Failed to retrieve audit log", err)
+	}
+	if entries == nil {
+		entries = []db.AuditEntry{}
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// GetAILogs returns a page of AI generation logs, newest first, with the
+// provider, model, temperature and prompt version each ran with. At most one
+// of model, status and user may filter the listing.
+// GET /api/v1/admin/ai-logs?model=gpt-4o-mini&limit=20&offset=0
+func (h *Handlers) GetAILogs(c echo.Context) error {
+	if h.aiLogs == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not found"})
+	}
+
+	limit := 20 // default
+	offset := 0
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	model, status, user := c.QueryParam("model"), c.QueryParam("status"), c.QueryParam("user")
+	filters := 0
+	for _, filter := range []string{model, status, user} {
+		if filter != "" {
+			filters++
+		}
+	}
+	if filters > 1 {
+		return ValidationError(c, "Invalid filter", "filter by at most one of model, status and user")
+	}
+
+	ctx := c.Request().Context()
+	var logs []*generator.AIGenerationLog
+	var err error
+	switch {
+	case model != "":
+		logs, err = h.aiLogs.GetGenerationLogsByModel(ctx, model, limit, offset)
+	case status != "":
+		logs, err = h.aiLogs.GetGenerationLogsByStatus(ctx, status, limit, offset)
+	case user != "":
+		logs, err = h.aiLogs.GetGenerationLogsByUser(ctx, user, limit, offset)
+	default:
+		logs, err = h.aiLogs.GetRecentGenerationLogs(ctx, limit, offset)
+	}
+	if err != nil {
+		return InternalServerError(c, "Failed to retrieve AI generation logs", err)
+	}
+
+	response := make([]*AIGenerationLogResponse, len(logs))
+	for i, log := range logs {
+		response[i] = ToAIGenerationLogResponse(log)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetAIPromptVersions reports the system prompt versions generations are
+// using, with the percentage of requests each gets
+// GET /api/v1/admin/ai-prompt
+func (h *Handlers) GetAIPromptVersions(c echo.Context) error {
+	if h.promptVersions == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Not found"})
+	}
+
+	active := h.promptVersions.Active()
+	response := AIPromptVersionsResponse{Versions: make([]PromptVersionShare, len(active))}
+	for i, share := range active {
+		response.Versions[i] = PromptVersionShare{Version: share.Version, Percent: share.Percent}
+	}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,375 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockGenerationStats records the window it was asked for
+type MockGenerationStats struct {
+	from, to time.Time
+	buckets  []db.GenerationStatsBucket
+}
+
+func (m *MockGenerationStats) GetGenerationStats(ctx context.Context, from, to time.Time) ([]db.GenerationStatsBucket, error) {
+	m.from, m.to = from, to
+	return m.buckets, nil
+}
+
+func serveAIStats(h *Handlers, target, token string) *httptest.ResponseRecorder {
+	e, _, _ := setupTest()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	_ = h.RequireAdmin(h.GetAIStats)(e.NewContext(req, rec))
+	return rec
+}
+
+func TestGetAIStats_Auth(t *testing.T) {
+	t.Run("disabled without a token", func(t *testing.T) {
+		_, _, h := setupTest()
+		rec := serveAIStats(h, "/api/v1/admin/ai-stats", "anything")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("rejects a missing or wrong token", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+
+		assert.Equal(t, http.StatusUnauthorized, serveAIStats(h, "/api/v1/admin/ai-stats", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, serveAIStats(h, "/api/v1/admin/ai-stats", "wrong").Code)
+	})
+}
+
+func TestGetAIStats(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	stats := &MockGenerationStats{buckets: []db.GenerationStatsBucket{{
+		Day:             day,
+		GenerationStats: db.GenerationStats{Calls: 4, Successes: 3, SuccessRate: 0.75, CostUSD: 0.002},
+		ByUserType: map[string]db.GenerationStats{
+			"anonymous":     {Calls: 1},
+			"authenticated": {Calls: 3, Successes: 3, SuccessRate: 1},
+		},
+	}}}
+	_, _, h := setupTest()
+	h.SetAdmin("s3cret", stats)
+
+	rec := serveAIStats(h, "/api/v1/admin/ai-stats?from=2025-03-01&to=2025-03-07", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// to is inclusive
+	assert.Equal(t, day, stats.from)
+	assert.Equal(t, day.AddDate(0, 0, 7), stats.to)
+
+	var body struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		Days []struct {
+			Day         time.Time `json:"day"`
+			Calls       int64     `json:"calls"`
+			SuccessRate float64   `json:"successRate"`
+			ByUserType  map[string]struct {
+				Calls int64 `json:"calls"`
+			} `json:"byUserType"`
+		} `json:"days"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "2025-03-01", body.From)
+	assert.Equal(t, "2025-03-07", body.To)
+	require.Len(t, body.Days, 1)
+	assert.Equal(t, int64(4), body.Days[0].Calls)
+	assert.Equal(t, 0.75, body.Days[0].SuccessRate)
+	assert.Equal(t, int64(3), body.Days[0].ByUserType["authenticated"].Calls)
+}
+
+func TestGetAIStats_DefaultWindow(t *testing.T) {
+	stats := &MockGenerationStats{}
+	_, _, h := setupTest()
+	h.SetAdmin("s3cret", stats)
+
+	rec := serveAIStats(h, "/api/v1/admin/ai-stats", "s3cret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, defaultAIStatsDays*24*time.Hour, stats.to.Sub(stats.from))
+	assert.JSONEq(t, `[]`, string(mustJSONField(t, rec.Body.Bytes(), "days")))
+}
+
+func TestGetAIStats_InvalidRange(t *testing.T) {
+	_, _, h := setupTest()
+	h.SetAdmin("s3cret", &MockGenerationStats{})
+
+	for _, query := range []string{
+		"?from=yesterday",
+		"?to=2025-13-01",
+		"?from=2025-03-07&to=2025-03-01",
+		"?from=2023-01-01&to=2025-01-01",
+	} {
+		rec := serveAIStats(h, "/api/v1/admin/ai-stats"+query, "s3cret")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+// mustJSONField returns the raw JSON of one top-level field
+func mustJSONField(t *testing.T, body []byte, field string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &fields))
+	return fields[field]
+}
+
+// MockAuditLog records the filter and page it was asked for
+type MockAuditLog struct {
+	filter        db.AuditFilter
+	limit, offset int
+	entries       []db.AuditEntry
+}
+
+func (m *MockAuditLog) ListAuditEntries(ctx context.Context, filter db.AuditFilter, limit, offset int) ([]db.AuditEntry, error) {
+	m.filter, m.limit, m.offset = filter, limit, offset
+	return m.entries, nil
+}
+
+func serveAuditLog(h *Handlers, target, token string) *httptest.ResponseRecorder {
+	e, _, _ := setupTest()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	_ = h.RequireAdmin(h.GetAuditLog)(e.NewContext(req, rec))
+	return rec
+}
+
+func TestGetAuditLog(t *testing.T) {
+	t.Run("requires the admin token", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetAuditLog(&MockAuditLog{})
+
+		assert.Equal(t, http.StatusUnauthorized, serveAuditLog(h, "/api/v1/admin/audit", "").Code)
+	})
+
+	t.Run("disabled without an audit log", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+
+		assert.Equal(t, http.StatusNotFound, serveAuditLog(h, "/api/v1/admin/audit", "s3cret").Code)
+	})
+
+	t.Run("filters and pages", func(t *testing.T) {
+		createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		audit := &MockAuditLog{entries: []db.AuditEntry{{
+			Actor:     "did:plc:admin",
+			Action:    db.AuditActionBlockDID,
+			Target:    "did:plc:spam",
+			Details:   json.RawMessage(`{"reason":"spam"}`),
+			CreatedAt: createdAt,
+		}}}
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetAuditLog(audit)
+
+		rec := serveAuditLog(h, "/api/v1/admin/audit?actor=did:plc:admin&action=did.block&since=2025-03-01T00:00:00Z&limit=5&offset=10", "s3cret")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, "did:plc:admin", audit.filter.Actor)
+		assert.Equal(t, db.AuditActionBlockDID, audit.filter.Action)
+		assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), audit.filter.Since)
+		assert.True(t, audit.filter.Until.IsZero())
+		assert.Equal(t, 5, audit.limit)
+		assert.Equal(t, 10, audit.offset)
+
+		var body []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body, 1)
+		assert.Equal(t, "did:plc:spam", body[0]["target"])
+		assert.Equal(t, map[string]interface{}{"reason": "spam"}, body[0]["details"])
+	})
+
+	t.Run("empty page", func(t *testing.T) {
+		audit := &MockAuditLog{}
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetAuditLog(audit)
+
+		rec := serveAuditLog(h, "/api/v1/admin/audit", "s3cret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+		assert.Equal(t, 20, audit.limit)
+	})
+
+	t.Run("invalid time", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetAuditLog(&MockAuditLog{})
+
+		assert.Equal(t, http.StatusBadRequest, serveAuditLog(h, "/api/v1/admin/audit?until=yesterday", "s3cret").Code)
+	})
+}
+
+// MockGenerationLogs records which listing it was asked for
+type MockGenerationLogs struct {
+	by, value     string
+	limit, offset int
+	logs          []*generator.AIGenerationLog
+}
+
+func (m *MockGenerationLogs) record(by, value string, limit, offset int) ([]*generator.AIGenerationLog, error) {
+	m.by, m.value, m.limit, m.offset = by, value, limit, offset
+	return m.logs, nil
+}
+
+func (m *MockGenerationLogs) GetRecentGenerationLogs(ctx context.Context, limit, offset int) ([]*generator.AIGenerationLog, error) {
+	return m.record("recent", "", limit, offset)
+}
+
+func (m *MockGenerationLogs) GetGenerationLogsByModel(ctx context.Context, model string, limit, offset int) ([]*generator.AIGenerationLog, error) {
+	return m.record("model", model, limit, offset)
+}
+
+func (m *MockGenerationLogs) GetGenerationLogsByStatus(ctx context.Context, status string, limit, offset int) ([]*generator.AIGenerationLog, error) {
+	return m.record("status", status, limit, offset)
+}
+
+func (m *MockGenerationLogs) GetGenerationLogsByUser(ctx context.Context, userID string, limit, offset int) ([]*generator.AIGenerationLog, error) {
+	return m.record("user", userID, limit, offset)
+}
+
+func serveAILogs(h *Handlers, target, token string) *httptest.ResponseRecorder {
+	e, _, _ := setupTest()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	_ = h.RequireAdmin(h.GetAILogs)(e.NewContext(req, rec))
+	return rec
+}
+
+func TestGetAILogs(t *testing.T) {
+	temperature := 0.7
+	logs := []*generator.AIGenerationLog{
+		{
+			UserID: "did:plc:test", UserType: "authenticated", Kind: generator.KindGenerate, Status: "success",
+			Provider: "openai", Model: "gpt-4.1-mini", Temperature: &temperature, PromptVersion: "v1",
+			InputTokens: 100, OutputTokens: 50, CostUSD: 0.0001, Attempts: 1,
+		},
+		// Logged before settings were recorded
+		{UserID: "192.168.1.1", UserType: "anonymous", Kind: generator.KindGenerate, Status: "error", Provider: "openai", Model: "gpt-4o-mini"},
+	}
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetGenerationLogs(&MockGenerationLogs{})
+
+		assert.Equal(t, http.StatusUnauthorized, serveAILogs(h, "/api/v1/admin/ai-logs", "").Code)
+	})
+
+	t.Run("disabled without logs", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+
+		assert.Equal(t, http.StatusNotFound, serveAILogs(h, "/api/v1/admin/ai-logs", "s3cret").Code)
+	})
+
+	t.Run("lists recent logs with their settings", func(t *testing.T) {
+		store := &MockGenerationLogs{logs: logs}
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetGenerationLogs(store)
+
+		rec := serveAILogs(h, "/api/v1/admin/ai-logs", "s3cret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "recent", store.by)
+		assert.Equal(t, 20, store.limit)
+
+		var body []map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body, 2)
+		assert.Equal(t, "openai", body[0]["provider"])
+		assert.Equal(t, "gpt-4.1-mini", body[0]["model"])
+		assert.Equal(t, 0.7, body[0]["temperature"])
+		assert.Equal(t, "v1", body[0]["promptVersion"])
+		assert.Nil(t, body[1]["temperature"])
+		assert.Equal(t, "", body[1]["promptVersion"])
+		assert.NotContains(t, body[0], "rawResponse")
+	})
+
+	for _, tt := range []struct{ query, by, value string }{
+		{"model=gpt-4.1-mini", "model", "gpt-4.1-mini"},
+		{"status=error", "status", "error"},
+		{"user=did:plc:test", "user", "did:plc:test"},
+	} {
+		t.Run("filters by "+tt.by, func(t *testing.T) {
+			store := &MockGenerationLogs{}
+			_, _, h := setupTest()
+			h.SetAdmin("s3cret", &MockGenerationStats{})
+			h.SetGenerationLogs(store)
+
+			rec := serveAILogs(h, "/api/v1/admin/ai-logs?"+tt.query+"&limit=5&offset=10", "s3cret")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `[]`, rec.Body.String())
+			assert.Equal(t, tt.by, store.by)
+			assert.Equal(t, tt.value, store.value)
+			assert.Equal(t, 5, store.limit)
+			assert.Equal(t, 10, store.offset)
+		})
+	}
+
+	t.Run("one filter at a time", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetGenerationLogs(&MockGenerationLogs{})
+
+		assert.Equal(t, http.StatusBadRequest, serveAILogs(h, "/api/v1/admin/ai-logs?model=gpt-4o&status=error", "s3cret").Code)
+	})
+}
+
+func TestGetAIPromptVersions(t *testing.T) {
+	serve := func(h *Handlers, token string) *httptest.ResponseRecorder {
+		e, _, _ := setupTest()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/ai-prompt", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		_ = h.RequireAdmin(h.GetAIPromptVersions)(e.NewContext(req, rec))
+		return rec
+	}
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetPromptVersions(generator.NewPromptSelector(generator.PromptConfig{Version: generator.DefaultPromptVersion}))
+
+		assert.Equal(t, http.StatusUnauthorized, serve(h, "").Code)
+	})
+
+	t.Run("disabled without generation", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+
+		assert.Equal(t, http.StatusNotFound, serve(h, "s3cret").Code)
+	})
+
+	t.Run("reports the active versions", func(t *testing.T) {
+		_, _, h := setupTest()
+		h.SetAdmin("s3cret", &MockGenerationStats{})
+		h.SetPromptVersions(generator.NewPromptSelector(generator.PromptConfig{Version: generator.DefaultPromptVersion}))
+
+		rec := serve(h, "s3cret")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"versions":[{"version":"v1","percent":100}]}`, rec.Body.String())
+	})
+}
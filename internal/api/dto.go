@@ -4,13 +4,30 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/generator"
 	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
 )
 
 // CreateSurveyRequest represents the request body for creating a survey
 type CreateSurveyRequest struct {
-	Slug       string `json:"slug"`       // optional, auto-generate if missing
-	Definition string `json:"definition"` // YAML or JSON string
+	Slug       string     `json:"slug"`               // optional, auto-generate if missing
+	Definition string     `json:"definition"`         // YAML or JSON string
+	StartsAt   *time.Time `json:"startsAt,omitempty"` // optional, open from creation if missing
+	EndsAt     *time.Time `json:"endsAt,omitempty"`   // optional, never closes if missing
+}
+
+// UpdateSurveyRequest is an author's edit of a survey. Version is the
+// version the editor read; the edit is refused with 409 Conflict if the
+// survey has changed since.
+type UpdateSurveyRequest struct {
+	Definition  string     `json:"definition"`            // YAML or JSON string
+	Title       *string    `json:"title,omitempty"`       // optional, unchanged if missing
+	Description *string    `json:"description,omitempty"` // optional, unchanged if missing
+	StartsAt    *time.Time `json:"startsAt,omitempty"`    // optional, unchanged if missing
+	EndsAt      *time.Time `json:"endsAt,omitempty"`      // optional, unchanged if missing
+	Version     int        `json:"version"`
 }
 
 // SurveyResponse represents a survey in API responses
@@ -23,6 +40,8 @@ type SurveyResponse struct {
 	Title       string                   `json:"title"`
 	Description *string                  `json:"description,omitempty"`
 	Definition  *models.SurveyDefinition `json:"definition,omitempty"` // omitted in list view
+	Version     int                      `json:"version"`              // send back with UpdateSurveyRequest
+	Lang        *string                  `json:"lang,omitempty"`
 	StartsAt    *time.Time               `json:"startsAt,omitempty"`
 	EndsAt      *time.Time               `json:"endsAt,omitempty"`
 	CreatedAt   time.Time                `json:"createdAt"`
@@ -31,14 +50,31 @@ type SurveyResponse struct {
 
 // SurveyListResponse represents a survey in list responses (without full definition)
 type SurveyListResponse struct {
-	ID          uuid.UUID  `json:"id"`
-	Slug        string     `json:"slug"`
-	Title       string     `json:"title"`
-	Description *string    `json:"description,omitempty"`
-	StartsAt    *time.Time `json:"startsAt,omitempty"`
-	EndsAt      *time.Time `json:"endsAt,omitempty"`
-	CreatedAt   time.Time  `json:"createdAt"`
-	UpdatedAt   time.Time  `json:"updatedAt"`
+	ID          uuid.UUID           `json:"id"`
+	Slug        string              `json:"slug"`
+	Title       string              `json:"title"`
+	Description *string             `json:"description,omitempty"`
+	Lang        *string             `json:"lang,omitempty"`
+	StartsAt    *time.Time          `json:"startsAt,omitempty"`
+	EndsAt      *time.Time          `json:"endsAt,omitempty"`
+	Status      models.SurveyStatus `json:"status,omitempty"` // open, closed, scheduled or deleted
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+}
+
+// SurveySearchResponse represents a survey search match, with its live response count
+type SurveySearchResponse struct {
+	SurveyListResponse
+	ResponseCount int `json:"responseCount"`
+}
+
+// AuthorSurveyResponse represents a survey in its author's listing. URI is
+// public; DeletedAt is only shown to the author.
+type AuthorSurveyResponse struct {
+	SurveyListResponse
+	URI           *string    `json:"uri,omitempty"`
+	ResponseCount int        `json:"responseCount"`
+	DeletedAt     *time.Time `json:"deletedAt,omitempty"`
 }
 
 // SubmitResponseRequest represents the request body for submitting a survey response
@@ -57,6 +93,7 @@ type ResponseSubmittedResponse struct {
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Details string `json:"details,omitempty"`
+	Reason  string `json:"reason,omitempty"` // machine-readable cause, for clients to act on, e.g. ReasonSurveyClosed
 }
 
 // SurveyResultsResponse wraps the models.SurveyResults for API response
@@ -74,6 +111,8 @@ func ToSurveyResponse(s *models.Survey, includeDefinition bool) *SurveyResponse
 		Slug:        s.Slug,
 		Title:       s.Title,
 		Description: s.Description,
+		Version:     s.Version,
+		Lang:        s.Lang,
 		StartsAt:    s.StartsAt,
 		EndsAt:      s.EndsAt,
 		CreatedAt:   s.CreatedAt,
@@ -94,24 +133,211 @@ func ToSurveyListResponse(s *models.Survey) *SurveyListResponse {
 		Slug:        s.Slug,
 		Title:       s.Title,
 		Description: s.Description,
+		Lang:        s.Lang,
 		StartsAt:    s.StartsAt,
 		EndsAt:      s.EndsAt,
+		Status:      s.Status,
 		CreatedAt:   s.CreatedAt,
 		UpdatedAt:   s.UpdatedAt,
 	}
 }
 
-// GenerateSurveyRequest for AI survey generation
+// ToSurveySearchResponse converts a search match to the search response DTO
+func ToSurveySearchResponse(r *models.SurveySearchResult) *SurveySearchResponse {
+	return &SurveySearchResponse{
+		SurveyListResponse: *ToSurveyListResponse(r.Survey),
+		ResponseCount:      r.ResponseCount,
+	}
+}
+
+// ToAuthorSurveyResponse converts an author listing entry to its DTO,
+// including the fields only the author may see when isOwner is set
+func ToAuthorSurveyResponse(r *models.AuthorSurvey, isOwner bool) *AuthorSurveyResponse {
+	resp := &AuthorSurveyResponse{
+		SurveyListResponse: *ToSurveyListResponse(r.Survey),
+		URI:                r.Survey.URI,
+		ResponseCount:      r.ResponseCount,
+	}
+	if isOwner {
+		resp.DeletedAt = r.Survey.DeletedAt
+	}
+	return resp
+}
+
+// GenerateSurveyRequest for AI survey generation. With Existing (or
+// ExistingJSON, its JSON or YAML text), Description says how to modify that
+// survey.
 type GenerateSurveyRequest struct {
-	Description  string `json:"description"`
-	ExistingJSON string `json:"existing_json,omitempty"`
-	Consent      bool   `json:"consent"`
+	Description  string                   `json:"description"`
+	Existing     *models.SurveyDefinition `json:"existing,omitempty"`
+	ExistingJSON string                   `json:"existing_json,omitempty"`
+	Consent      bool                     `json:"consent"`
 }
 
 // GenerateSurveyResponse from AI generation
 type GenerateSurveyResponse struct {
-	Definition   *models.SurveyDefinition `json:"definition"`
-	TokensUsed   int                      `json:"tokens_used"`
-	Cost         float64                  `json:"cost"`
-	NeedsCaptcha bool                     `json:"needs_captcha,omitempty"`
+	Definition   *models.SurveyDefinition  `json:"definition"`
+	TokensUsed   int                       `json:"tokens_used"`
+	Cost         float64                   `json:"cost"`
+	NeedsCaptcha bool                      `json:"needs_captcha,omitempty"`
+	Diff         *generator.DefinitionDiff `json:"diff,omitempty"` // Set when modifying a survey
+	Lang         string                    `json:"lang,omitempty"` // Set when translating a survey: the translation's BCP-47 tag
+}
+
+// TranslateSurveyRequest for AI survey translation
+type TranslateSurveyRequest struct {
+	Lang    string `json:"lang"` // BCP-47 tag of the language to translate into
+	Consent bool   `json:"consent"`
+}
+
+// SummarizeTextAnswersRequest asks for an AI summary of a text question's answers
+type SummarizeTextAnswersRequest struct {
+	QuestionID string `json:"questionId"`
+	Consent    bool   `json:"consent"`
+}
+
+// SummarizeTextAnswersResponse is the summary of a text question's answers.
+// Cached summaries were made earlier for the same number of answers and
+// cost nothing.
+type SummarizeTextAnswersResponse struct {
+	Summary    *models.TextAnswerSummary `json:"summary"`
+	Cached     bool                      `json:"cached"`
+	TokensUsed int                       `json:"tokens_used"`
+	Cost       float64                   `json:"cost"`
+}
+
+// GenerateStreamResponse starts a streamed AI generation; follow it by
+// opening StreamURL as an event stream
+type GenerateStreamResponse struct {
+	ID        string `json:"id"`
+	StreamURL string `json:"stream_url"`
+}
+
+// GenerateProgressEvent is a streamed generation's "progress" event, sent as
+// the model finishes writing each question
+type GenerateProgressEvent struct {
+	Question       int    `json:"question"`
+	Text           string `json:"text"`
+	EstimatedTotal int    `json:"estimated_total,omitempty"` // zero when unknown
+}
+
+// GenerateErrorEvent is a streamed generation's final "error" event: the
+// status and body the non-streaming endpoint would have returned
+type GenerateErrorEvent struct {
+	Status int `json:"status"`
+	ErrorResponse
+}
+
+// BudgetExceededResponse is the 429 response to a generation request over a
+// daily spending budget
+type BudgetExceededResponse struct {
+	ErrorResponse
+	Scope    string    `json:"scope"`     // "user", "anonymous" or "global"
+	ResetsAt time.Time `json:"resets_at"` // when the budget resets
+}
+
+// AIStatsResponse is AI generation usage for the admin dashboard, one entry
+// per UTC day from From to To inclusive
+type AIStatsResponse struct {
+	From string                     `json:"from"`
+	To   string                     `json:"to"`
+	Days []db.GenerationStatsBucket `json:"days"`
+}
+
+// AIGenerationLogResponse is one AI generation log in the admin listing:
+// who asked, what it cost, and what it ran on. The system prompt and raw
+// response are left out to keep pages small.
+type AIGenerationLogResponse struct {
+	ID               uuid.UUID `json:"id"`
+	UserID           string    `json:"userId"`
+	UserType         string    `json:"userType"`
+	Kind             string    `json:"kind"`
+	InputPrompt      string    `json:"inputPrompt"`
+	Status           string    `json:"status"`
+	ErrorMessage     string    `json:"errorMessage,omitempty"`
+	InputTokens      int       `json:"inputTokens"`
+	OutputTokens     int       `json:"outputTokens"`
+	CostUSD          float64   `json:"costUsd"`
+	DurationMS       int       `json:"durationMs"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Temperature      *float64  `json:"temperature"`   // null when not recorded
+	PromptVersion    string    `json:"promptVersion"` // empty when not recorded
+	FallbackFrom     string    `json:"fallbackFrom,omitempty"`
+	Attempts         int       `json:"attempts"`
+	OutputValidation string    `json:"outputValidation,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// ToAIGenerationLogResponse converts an AI generation log to its DTO
+func ToAIGenerationLogResponse(l *generator.AIGenerationLog) *AIGenerationLogResponse {
+	return &AIGenerationLogResponse{
+		ID:               l.ID,
+		UserID:           l.UserID,
+		UserType:         l.UserType,
+		Kind:             l.Kind,
+		InputPrompt:      l.InputPrompt,
+		Status:           l.Status,
+		ErrorMessage:     l.ErrorMessage,
+		InputTokens:      l.InputTokens,
+		OutputTokens:     l.OutputTokens,
+		CostUSD:          l.CostUSD,
+		DurationMS:       l.DurationMS,
+		Provider:         l.Provider,
+		Model:            l.Model,
+		Temperature:      l.Temperature,
+		PromptVersion:    l.PromptVersion,
+		FallbackFrom:     l.FallbackFrom,
+		Attempts:         l.Attempts,
+		OutputValidation: l.OutputValidation,
+		CreatedAt:        l.CreatedAt,
+	}
+}
+
+// AIPromptVersionsResponse lists the system prompt versions in use
+type AIPromptVersionsResponse struct {
+	Versions []PromptVersionShare `json:"versions"`
+}
+
+// PromptVersionShare is a system prompt version and the percentage of
+// generations sent it
+type PromptVersionShare struct {
+	Version string `json:"version"`
+	Percent int    `json:"percent"`
+}
+
+// SessionResponse is one of the signed-in user's sessions. ID is the
+// session's handle, not the session cookie value.
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	IPAddress  string    `json:"ipAddress,omitempty"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+}
+
+// RevokeAllSessionsRequest confirms ending all of the user's sessions.
+// Handle may be omitted right after logging in.
+type RevokeAllSessionsRequest struct {
+	Handle string `json:"handle" form:"handle"`
+}
+
+// RevokeAllSessionsResponse reports how many sessions were ended
+type RevokeAllSessionsResponse struct {
+	Ended   int64  `json:"ended"`
+	Message string `json:"message"`
+}
+
+// ToSessionResponse converts a session to its DTO; current is the ID of the
+// session making the request
+func ToSessionResponse(s oauth.SessionInfo, current string) *SessionResponse {
+	return &SessionResponse{
+		ID:         oauth.SessionHandle(s.ID),
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		Current:    s.ID == current,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+	}
 }
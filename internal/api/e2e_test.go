@@ -7,9 +7,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/openmeet-team/survey/internal/db"
 	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	testcontainers "github.com/testcontainers/testcontainers-go"
@@ -54,10 +56,7 @@ func setupTestServer(t *testing.T) (*echo.Echo, *Handlers, func()) {
 	require.NoError(t, err, "Failed to ping database")
 
 	// Run migrations
-	migrationSQL, err := os.ReadFile("../../internal/db/migrations/001_initial.up.sql")
-	require.NoError(t, err, "Failed to read migration file")
-
-	_, err = dbConn.ExecContext(ctx, string(migrationSQL))
+	err = db.Migrate(ctx, dbConn)
 	require.NoError(t, err, "Failed to run migrations")
 
 	// Create queries and handlers
@@ -66,7 +65,7 @@ func setupTestServer(t *testing.T) (*echo.Echo, *Handlers, func()) {
 
 	// Setup Echo server
 	e := echo.New()
-	SetupRoutes(e, handlers, NewHealthHandlers(dbConn), nil, dbConn)
+	SetupRoutes(e, handlers, NewHealthHandlers(dbConn), nil, oauth.NewStorage(dbConn))
 
 	cleanup := func() {
 		dbConn.Close()
@@ -121,12 +120,17 @@ anonymous: false
 	assert.Equal(t, "What is your favorite color?", createResp.Title)
 	assert.Len(t, createResp.Definition.Questions, 1)
 
-	// Step 2: Verify survey list endpoint is removed (returns 404)
+	// Step 2: GET /api/v1/surveys - the survey isn't discoverable, so it isn't listed
 	req = httptest.NewRequest(http.MethodGet, "/api/v1/surveys", nil)
 	rec = httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
-	assert.Equal(t, http.StatusNotFound, rec.Code, "Survey list endpoint should be removed")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var listed []SurveyListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &listed))
+	for _, s := range listed {
+		assert.NotEqual(t, "favorite-color", s.Slug, "Only discoverable surveys should be listed")
+	}
 
 	// Step 3: GET /api/v1/surveys/:slug - verify full survey returned
 	req = httptest.NewRequest(http.MethodGet, "/api/v1/surveys/favorite-color", nil)
@@ -600,3 +604,71 @@ func TestE2E_MultipleResponses(t *testing.T) {
 	assert.Equal(t, 1, results.QuestionResults["q1"].OptionCounts["python"])
 	assert.Equal(t, 1, results.QuestionResults["q1"].OptionCounts["rust"])
 }
+
+// TestE2E_BrowseSurveys tests the browse page's filtering and ordering
+// against the database
+func TestE2E_BrowseSurveys(t *testing.T) {
+	e, _, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	create := func(slug string, discoverable bool) {
+		createReq := CreateSurveyRequest{
+			Slug: slug,
+			Definition: fmt.Sprintf(`{
+				"questions": [{"id": "q1", "text": "Survey %s?", "type": "single", "required": true,
+					"options": [{"id": "yes", "text": "Yes"}, {"id": "no", "text": "No"}]}],
+				"anonymous": true,
+				"discoverable": %t
+			}`, slug, discoverable),
+		}
+		body, err := json.Marshal(createReq)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	vote := func(slug string, i int) {
+		body, err := json.Marshal(SubmitResponseRequest{
+			Answers: map[string]models.Answer{"q1": {SelectedOptions: []string{"yes"}}},
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/"+slug+"/responses", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = fmt.Sprintf("192.168.2.%d:1234", i+1)
+		req.Header.Set("User-Agent", fmt.Sprintf("TestClient/%d", i))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	browse := func(target string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec.Body.String()
+	}
+
+	create("popular-poll", true)
+	create("unlisted-poll", false)
+	create("new-poll", true)
+	for i := 0; i < 2; i++ {
+		vote("popular-poll", i)
+		vote("unlisted-poll", i+2)
+	}
+
+	body := browse("/surveys")
+	assert.NotContains(t, body, "unlisted-poll")
+	assert.Less(t, strings.Index(body, `href="/surveys/new-poll"`), strings.Index(body, `href="/surveys/popular-poll"`), "newest first")
+	assert.Contains(t, body, "2 responses")
+
+	body = browse("/surveys?sort=responses")
+	assert.NotContains(t, body, "unlisted-poll")
+	assert.Less(t, strings.Index(body, `href="/surveys/popular-poll"`), strings.Index(body, `href="/surveys/new-poll"`), "most responses first")
+
+	body = browse("/surveys?status=closed")
+	assert.Contains(t, body, "No surveys match these filters.")
+}
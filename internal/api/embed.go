@@ -0,0 +1,56 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/templates"
+)
+
+// DefaultEmbedFrameAncestors lets any site embed surveys
+const DefaultEmbedFrameAncestors = "*"
+
+// SetEmbedFrameAncestors limits which sites may embed surveys, as a CSP
+// frame-ancestors source list such as "https://blog.example.com
+// https://*.example.org". Empty allows any site.
+func (h *Handlers) SetEmbedFrameAncestors(sources string) {
+	h.embedFrameAncestors = strings.TrimSpace(sources)
+}
+
+// GetSurveyEmbedHTML renders a survey for an iframe on another site. It's
+// the only page that may be framed: X-Frame-Options is dropped and the CSP
+// gets a frame-ancestors directive instead. PostHog only loads when the
+// embedding site passes analytics=1, meaning its visitor has consented.
+// GET /surveys/:slug/embed
+func (h *Handlers) GetSurveyEmbedHTML(c echo.Context) error {
+	slug := c.Param("slug")
+
+	survey, err := h.queries.GetSurveyBySlug(c.Request().Context(), slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.String(http.StatusNotFound, "Survey not found")
+		}
+		return c.String(http.StatusInternalServerError, "Failed to load survey")
+	}
+
+	ancestors := h.embedFrameAncestors
+	if ancestors == "" {
+		ancestors = DefaultEmbedFrameAncestors
+	}
+	header := c.Response().Header()
+	header.Del("X-Frame-Options")
+	header.Set("Content-Security-Policy", contentSecurityPolicy(templates.Nonce(c.Request().Context()))+" frame-ancestors "+ancestors+";")
+
+	posthogKey := ""
+	if c.QueryParam("analytics") == "1" {
+		posthogKey = h.posthogKey
+	}
+
+	header.Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	component := templates.SurveyEmbed(survey, survey.StatusAt(time.Now()), posthogKey)
+	return component.Render(c.Request().Context(), c.Response().Writer)
+}
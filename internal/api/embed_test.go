@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSurveyEmbedHTML(t *testing.T) {
+	setup := func(t *testing.T) (*echo.Echo, *Handlers) {
+		e, mq, h := setupTest()
+		require.NoError(t, mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:    uuid.New(),
+			Slug:  "lunch",
+			Title: "Lunch order",
+			Definition: models.SurveyDefinition{Questions: []models.Question{
+				{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}}},
+			}},
+		}))
+		h.SetPostHogKey("phc_test")
+		return e, h
+	}
+	get := func(e *echo.Echo, h *Handlers, target, slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+		// Through the security headers, which the handler must relax
+		_ = SecurityHeadersMiddleware()(h.GetSurveyEmbedHTML)(c)
+		return rec
+	}
+
+	t.Run("can be framed by any site by default", func(t *testing.T) {
+		e, h := setup(t)
+		rec := get(e, h, "/surveys/lunch/embed", "lunch")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+		assert.True(t, strings.HasSuffix(rec.Header().Get("Content-Security-Policy"), " frame-ancestors *;"))
+		assert.Contains(t, rec.Body.String(), "Lunch order")
+		assert.Contains(t, rec.Body.String(), `name="embed" value="1"`)
+		assert.NotContains(t, rec.Body.String(), "phc_test", "no analytics without consent")
+
+		// The page's scripts carry the nonce its policy allows
+		nonce := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(rec.Header().Get("Content-Security-Policy"))
+		require.Len(t, nonce, 2)
+		assert.Contains(t, rec.Body.String(), `nonce="`+nonce[1]+`"`)
+	})
+
+	t.Run("configured frame ancestors", func(t *testing.T) {
+		e, h := setup(t)
+		h.SetEmbedFrameAncestors(" https://blog.example.com https://*.example.org ")
+		rec := get(e, h, "/surveys/lunch/embed", "lunch")
+
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors https://blog.example.com https://*.example.org;")
+	})
+
+	t.Run("analytics once the embedding site has consent", func(t *testing.T) {
+		e, h := setup(t)
+		rec := get(e, h, "/surveys/lunch/embed?analytics=1", "lunch")
+
+		assert.Contains(t, rec.Body.String(), "phc_test")
+	})
+
+	t.Run("unknown survey", func(t *testing.T) {
+		e, h := setup(t)
+		rec := get(e, h, "/surveys/nope/embed", "nope")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("other pages still refuse to be framed", func(t *testing.T) {
+		e, h := setup(t)
+		req := httptest.NewRequest(http.MethodGet, "/surveys/lunch", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues("lunch")
+		require.NoError(t, SecurityHeadersMiddleware()(h.GetSurveyHTML)(c))
+
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+		assert.NotContains(t, rec.Header().Get("Content-Security-Policy"), "frame-ancestors")
+	})
+}
+
+func TestSubmitResponseHTML_Embed(t *testing.T) {
+	e, mq, h := setupTest()
+	require.NoError(t, mq.CreateSurvey(context.Background(), &models.Survey{
+		ID:    uuid.New(),
+		Slug:  "lunch",
+		Title: "Lunch order",
+		Definition: models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}}},
+		}},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/surveys/lunch/responses", strings.NewReader("q1=a&embed=1"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.RemoteAddr = "192.168.1.1:12345"
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues("lunch")
+
+	require.NoError(t, h.SubmitResponseHTML(c))
+
+	assert.Len(t, mq.responses, 1)
+	assert.Contains(t, rec.Body.String(), "Thank You!")
+	assert.Contains(t, rec.Body.String(), `target="_blank"`, "results open outside the frame")
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockGenerationCosts is today's successful generation spend by user, as
+// summed from the generation logs
+type MockGenerationCosts struct {
+	spent map[string]float64
+	err   error
+}
+
+func (m *MockGenerationCosts) GetUserCostSince(ctx context.Context, userID string, since time.Time) (float64, int, error) {
+	return m.spent[userID], 0, m.err
+}
+
+func (m *MockGenerationCosts) GetTotalCostSince(ctx context.Context, since time.Time) (float64, int, error) {
+	var total float64
+	for _, cost := range m.spent {
+		total += cost
+	}
+	return total, 0, m.err
+}
+
+// countingGenerator counts the generations that reach the generator
+type countingGenerator struct {
+	MockSurveyGenerator
+	calls int
+}
+
+func (g *countingGenerator) Generate(ctx context.Context, prompt string) (*generator.GenerateResult, error) {
+	g.calls++
+	return g.MockSurveyGenerator.Generate(ctx, prompt)
+}
+
+func newBudgetHandlers(costs *MockGenerationCosts) (*Handlers, *countingGenerator, *MockGenerationLogger) {
+	gen := &countingGenerator{MockSurveyGenerator: MockSurveyGenerator{result: &generator.GenerateResult{
+		Definition: &models.SurveyDefinition{
+			Questions: []models.Question{
+				{ID: "q1", Text: "Pizza?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "yes", Text: "Yes"}, {ID: "no", Text: "No"}}},
+			},
+		},
+		InputTokens:   100,
+		OutputTokens:  50,
+		EstimatedCost: 0.0002,
+	}}}
+	logger := &MockGenerationLogger{}
+	h := &Handlers{
+		queries:       NewMockQueries(),
+		generator:     gen,
+		generatorRL:   NewMockRateLimiter(true, true),
+		generationLog: logger,
+	}
+	h.SetBudget(generator.NewBudgetLimiter(costs, generator.BudgetConfig{UserDaily: 0.50, AnonDaily: 0.10, GlobalDaily: 10.0}))
+	return h, gen, logger
+}
+
+func generateAs(t *testing.T, h *Handlers, did string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(GenerateSurveyRequest{Description: "A pizza poll", Consent: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if did != "" {
+		c.Set("user", &oauth.User{DID: did})
+	}
+
+	require.NoError(t, h.GenerateSurvey(c))
+	return rec
+}
+
+func TestGenerateSurvey_Budget(t *testing.T) {
+	t.Run("allows a user just under budget", func(t *testing.T) {
+		h, gen, _ := newBudgetHandlers(&MockGenerationCosts{spent: map[string]float64{"did:plc:alice": 0.4999}})
+
+		rec := generateAs(t, h, "did:plc:alice")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, gen.calls)
+	})
+
+	t.Run("rejects a user at budget before generating", func(t *testing.T) {
+		h, gen, logger := newBudgetHandlers(&MockGenerationCosts{spent: map[string]float64{"did:plc:alice": 0.50}})
+
+		rec := generateAs(t, h, "did:plc:alice")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, 0, gen.calls)
+
+		var resp BudgetExceededResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Error, "daily AI generation budget")
+		assert.Equal(t, generator.BudgetScopeUser, resp.Scope)
+		assert.True(t, resp.ResetsAt.After(time.Now()))
+		assert.Equal(t, 0, resp.ResetsAt.UTC().Hour(), "budgets reset at UTC midnight")
+
+		retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.Positive(t, retryAfter)
+		assert.LessOrEqual(t, retryAfter, 24*60*60)
+
+		require.Len(t, logger.errorCalls, 1)
+		assert.Equal(t, "rate_limited", logger.errorCalls[0].Status)
+		assert.Contains(t, logger.errorCalls[0].ErrorMessage, "user daily AI budget exceeded")
+		assert.Equal(t, "did:plc:alice", logger.errorCalls[0].UserID)
+	})
+
+	t.Run("anonymous users have the per-IP budget", func(t *testing.T) {
+		h, gen, logger := newBudgetHandlers(&MockGenerationCosts{spent: map[string]float64{"192.0.2.1": 0.10}})
+
+		rec := generateAs(t, h, "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, 0, gen.calls)
+
+		var resp BudgetExceededResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, generator.BudgetScopeAnonymous, resp.Scope)
+		require.Len(t, logger.errorCalls, 1)
+		assert.Contains(t, logger.errorCalls[0].ErrorMessage, "anonymous daily AI budget exceeded")
+	})
+
+	t.Run("the global budget rejects everyone", func(t *testing.T) {
+		h, gen, logger := newBudgetHandlers(&MockGenerationCosts{spent: map[string]float64{"did:plc:heavy": 9.90, "did:plc:other": 0.10}})
+
+		rec := generateAs(t, h, "did:plc:newcomer")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, 0, gen.calls)
+
+		var resp BudgetExceededResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, generator.BudgetScopeGlobal, resp.Scope)
+		require.Len(t, logger.errorCalls, 1)
+		assert.Contains(t, logger.errorCalls[0].ErrorMessage, "global daily AI budget exceeded")
+	})
+
+	t.Run("fails closed when spend can't be read", func(t *testing.T) {
+		h, gen, logger := newBudgetHandlers(&MockGenerationCosts{err: errors.New("connection refused")})
+
+		rec := generateAs(t, h, "did:plc:alice")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, 0, gen.calls)
+		assert.Empty(t, logger.errorCalls)
+	})
+}
+
+// signedInStorage returns OAuth storage holding a session for did, with the
+// session cookie to present it
+func signedInStorage(t *testing.T, did string) (*oauth.Storage, *http.Cookie) {
+	t.Helper()
+	fake := queriestest.New(t)
+	now := time.Now()
+	fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		[]interface{}{"alice-session", did, "access", "", "", "https://pds.example.com", nil, "", "", now, now.Add(time.Hour)},
+	)
+	fake.Expect("UPDATE oauth_sessions SET last_used_at").RowsAffected(1)
+	return oauth.NewStorage(fake.DB), &http.Cookie{Name: "session", Value: "alice-session"}
+}
+
+// TestGenerateSurvey_BudgetRoute tests that through the router, a signed-in
+// request is charged against its DID's budget rather than its IP's
+func TestGenerateSurvey_BudgetRoute(t *testing.T) {
+	serve := func(e *echo.Echo, cookie *http.Cookie) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(GenerateSurveyRequest{Description: "A pizza poll", Consent: true})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate", bytes.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("a signed-in user over their budget is rejected", func(t *testing.T) {
+		h, gen, _ := newBudgetHandlers(&MockGenerationCosts{spent: map[string]float64{"did:plc:alice": 0.50}})
+		storage, cookie := signedInStorage(t, "did:plc:alice")
+		e := echo.New()
+		SetupRoutes(e, h, &HealthHandlers{}, nil, storage)
+
+		rec := serve(e, cookie)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, 0, gen.calls)
+
+		var resp BudgetExceededResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, generator.BudgetScopeUser, resp.Scope)
+	})
+
+	t.Run("a signed-in user gets the user budget on a spent IP", func(t *testing.T) {
+		h, gen, _ := newBudgetHandlers(&MockGenerationCosts{spent: map[string]float64{"192.0.2.1": 0.10}})
+		storage, cookie := signedInStorage(t, "did:plc:alice")
+		e := echo.New()
+		SetupRoutes(e, h, &HealthHandlers{}, nil, storage)
+
+		rec := serve(e, cookie)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, gen.calls)
+
+		// Without the session the same IP is over the anonymous budget
+		rec = serve(e, nil)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, 1, gen.calls)
+	})
+}
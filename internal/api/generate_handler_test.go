@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/openmeet-team/survey/internal/generator"
@@ -21,13 +23,38 @@ type MockSurveyGenerator struct {
 	result        *generator.GenerateResult
 	err           error
 	validateError error
+	screenError   error
+
+	// What Generate, Modify, Translate or Summarize was last called with
+	prompt      string
+	modified    *models.SurveyDefinition
+	instruction string
+	lang        string
+	answers     []string
+	deadline    time.Time // of the context Summarize was called with
 }
 
 func (m *MockSurveyGenerator) Generate(ctx context.Context, prompt string) (*generator.GenerateResult, error) {
+	m.prompt = prompt
 	return m.result, m.err
 }
 
-func (m *MockSurveyGenerator) GenerateRaw(ctx context.Context, prompt string) (*generator.GenerateResult, error) {
+func (m *MockSurveyGenerator) Modify(ctx context.Context, existing *models.SurveyDefinition, instruction string) (*generator.GenerateResult, error) {
+	m.modified = existing
+	m.instruction = instruction
+	return m.result, m.err
+}
+
+func (m *MockSurveyGenerator) Translate(ctx context.Context, def *models.SurveyDefinition, lang string) (*generator.GenerateResult, error) {
+	m.modified = def
+	m.lang = lang
+	return m.result, m.err
+}
+
+func (m *MockSurveyGenerator) Summarize(ctx context.Context, question string, answers []string) (*generator.GenerateResult, error) {
+	m.instruction = question
+	m.answers = answers
+	m.deadline, _ = ctx.Deadline()
 	return m.result, m.err
 }
 
@@ -35,6 +62,10 @@ func (m *MockSurveyGenerator) ValidateInput(input string) error {
 	return m.validateError
 }
 
+func (m *MockSurveyGenerator) ScreenInput(ctx context.Context, input string) error {
+	return m.screenError
+}
+
 func NewMockSurveyGenerator(result *generator.GenerateResult, err error) *MockSurveyGenerator {
 	return &MockSurveyGenerator{result: result, err: err}
 }
@@ -45,12 +76,20 @@ type MockRateLimiter struct {
 	allowAuth bool
 }
 
-func (m *MockRateLimiter) AllowAnonymous(ip string) bool {
-	return m.allowAnon
+func (m *MockRateLimiter) AllowAnonymous(ctx context.Context, ip string) (bool, time.Duration, error) {
+	return m.allowAnon, m.retryAfter(m.allowAnon), nil
 }
 
-func (m *MockRateLimiter) AllowAuthenticated(did string) bool {
-	return m.allowAuth
+func (m *MockRateLimiter) AllowAuthenticated(ctx context.Context, did string) (bool, time.Duration, error) {
+	return m.allowAuth, m.retryAfter(m.allowAuth), nil
+}
+
+// retryAfter is a minute for requests that aren't allowed
+func (m *MockRateLimiter) retryAfter(allowed bool) time.Duration {
+	if allowed {
+		return 0
+	}
+	return time.Minute
 }
 
 func NewMockRateLimiter(allowAnon, allowAuth bool) *MockRateLimiter {
@@ -235,6 +274,37 @@ func TestGenerateSurvey_Success_Authenticated(t *testing.T) {
 	assert.False(t, resp.NeedsCaptcha)
 }
 
+func TestGenerateSurvey_RedactsLoggedPrompt(t *testing.T) {
+	e := echo.New()
+	gen := NewMockSurveyGenerator(&generator.GenerateResult{
+		Definition: &models.SurveyDefinition{
+			Questions: []models.Question{{ID: "q1", Text: "Coming?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "yes", Text: "Yes"}, {ID: "no", Text: "No"}}}},
+		},
+		InputTokens:  50,
+		OutputTokens: 100,
+	}, nil)
+	logs := &capturingLogDB{}
+	h := &Handlers{
+		queries:     NewMockQueries(),
+		generator:   gen,
+		generatorRL: NewMockRateLimiter(true, true),
+	}
+	h.SetLogger(generator.NewGenerationLogger(logs))
+
+	description := "RSVP poll for the party at 12 Elm Lane; questions to jane@example.com or 555-123-4567"
+	body, _ := json.Marshal(GenerateSurveyRequest{Description: description, Consent: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.GenerateSurvey(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	assert.Contains(t, gen.prompt, "jane@example.com", "the model gets the prompt as written")
+	require.Len(t, logs.logs, 1)
+	assert.Equal(t, "RSVP poll for the party at [ADDRESS]; questions to [EMAIL] or [PHONE]", logs.logs[0].InputPrompt)
+}
+
 func TestGenerateSurvey_RateLimitExceeded_Anonymous(t *testing.T) {
 	e := echo.New()
 
@@ -259,6 +329,7 @@ func TestGenerateSurvey_RateLimitExceeded_Anonymous(t *testing.T) {
 	err := h.GenerateSurvey(c)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
 
 	var resp ErrorResponse
 	err = json.Unmarshal(rec.Body.Bytes(), &resp)
@@ -266,6 +337,70 @@ func TestGenerateSurvey_RateLimitExceeded_Anonymous(t *testing.T) {
 	assert.Contains(t, resp.Error, "Rate limit")
 }
 
+// failingCounter is a rate limit counter whose database is down
+type failingCounter struct{}
+
+func (failingCounter) Allow(context.Context, string, int, time.Duration) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestGenerateSurvey_RateLimitCheckFails(t *testing.T) {
+	e := echo.New()
+	h := &Handlers{
+		queries:     NewMockQueries(),
+		generator:   NewMockSurveyGenerator(&generator.GenerateResult{}, nil),
+		generatorRL: generator.NewSharedRateLimiter(failingCounter{}, generator.RateLimiterConfigFromEnv()),
+	}
+
+	body, _ := json.Marshal(GenerateSurveyRequest{Description: "Create a poll", Consent: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.GenerateSurvey(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "fails closed")
+	assert.Contains(t, rec.Body.String(), "temporarily unavailable")
+}
+
+// keyRecordingCounter allows every request and records the keys counted
+type keyRecordingCounter struct {
+	keys []string
+}
+
+func (k *keyRecordingCounter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	k.keys = append(k.keys, key)
+	return true, 0, nil
+}
+
+// TestGenerateSurvey_RateLimitRoute tests that through the router, signed-in
+// requests are limited by DID and anonymous ones by IP
+func TestGenerateSurvey_RateLimitRoute(t *testing.T) {
+	for _, path := range []string{"/api/v1/surveys/generate", "/api/v1/surveys/generate/stream"} {
+		t.Run(path, func(t *testing.T) {
+			counter := &keyRecordingCounter{}
+			h := NewHandlers(NewMockQueries())
+			h.SetGenerator(NewMockSurveyGenerator(&generator.GenerateResult{Definition: &models.SurveyDefinition{}}, nil),
+				generator.NewSharedRateLimiter(counter, generator.RateLimiterConfigFromEnv()))
+			storage, cookie := signedInStorage(t, "did:plc:alice")
+			e := echo.New()
+			SetupRoutes(e, h, &HealthHandlers{}, nil, storage)
+
+			for _, signedIn := range []bool{true, false} {
+				body, _ := json.Marshal(GenerateSurveyRequest{Description: "Create a poll", Consent: true})
+				req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				req.RemoteAddr = "192.0.2.1:1234"
+				if signedIn {
+					req.AddCookie(cookie)
+				}
+				e.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			assert.Equal(t, []string{"ai:auth:did:plc:alice", "ai:anon:192.0.2.1"}, counter.keys)
+		})
+	}
+}
+
 func TestGenerateSurvey_CostLimitExceeded(t *testing.T) {
 	e := echo.New()
 
@@ -352,9 +487,10 @@ func TestGenerateSurvey_WithExistingJSON(t *testing.T) {
 		EstimatedCost: 0.007,
 	}
 
+	mockGen := NewMockSurveyGenerator(mockResult, nil)
 	h := &Handlers{
 		queries:     NewMockQueries(),
-		generator:   NewMockSurveyGenerator(mockResult, nil),
+		generator:   mockGen,
 		generatorRL: NewMockRateLimiter(true, true),
 	}
 
@@ -381,4 +517,75 @@ func TestGenerateSurvey_WithExistingJSON(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, resp.Definition)
 	assert.Equal(t, "Updated question text", resp.Definition.Questions[0].Text)
+
+	// The draft is parsed and modified, not validated
+	require.NotNil(t, mockGen.modified)
+	assert.Equal(t, "Original question", mockGen.modified.Questions[0].Text)
+	assert.Equal(t, "Make this question better", mockGen.instruction)
+}
+
+func TestGenerateSurvey_ModifyExisting(t *testing.T) {
+	e := echo.New()
+
+	existing := &models.SurveyDefinition{
+		Questions: []models.Question{
+			{ID: "q1", Text: "Favorite pizza?", Type: models.QuestionTypeText},
+			{ID: "q2", Text: "Any comments?", Type: models.QuestionTypeText},
+		},
+	}
+	mockResult := &generator.GenerateResult{
+		Definition: &models.SurveyDefinition{
+			Questions: []models.Question{
+				{ID: "q1", Text: "Favorite pizza topping?", Type: models.QuestionTypeText},
+				{ID: "q3", Text: "How often do you eat pizza?", Type: models.QuestionTypeText},
+			},
+		},
+		Diff: &generator.DefinitionDiff{Added: []string{"q3"}, Removed: []string{"q2"}, Changed: []string{"q1"}},
+	}
+	mockGen := NewMockSurveyGenerator(mockResult, nil)
+	h := &Handlers{
+		queries:     NewMockQueries(),
+		generator:   mockGen,
+		generatorRL: NewMockRateLimiter(true, true),
+	}
+
+	body, _ := json.Marshal(GenerateSurveyRequest{
+		Description: "Ask about toppings and how often, not comments",
+		Existing:    existing,
+		Consent:     true,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.GenerateSurvey(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, existing, mockGen.modified)
+
+	var resp GenerateSurveyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, mockResult.Diff, resp.Diff)
+}
+
+func TestGenerateSurvey_InvalidExistingJSON(t *testing.T) {
+	e := echo.New()
+	mockGen := NewMockSurveyGenerator(nil, nil)
+	h := &Handlers{
+		queries:     NewMockQueries(),
+		generator:   mockGen,
+		generatorRL: NewMockRateLimiter(true, true),
+	}
+
+	body, _ := json.Marshal(GenerateSurveyRequest{
+		Description:  "Make it shorter",
+		ExistingJSON: `{"questions": [`,
+		Consent:      true,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.GenerateSurvey(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Nil(t, mockGen.modified)
 }
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingLogDB keeps the AI generation logs a GenerationLogger writes
+type capturingLogDB struct {
+	logs []*generator.AIGenerationLog
+}
+
+func (d *capturingLogDB) LogGeneration(_ context.Context, log *generator.AIGenerationLog) error {
+	d.logs = append(d.logs, log)
+	return nil
+}
+
+func TestTranslateSurvey(t *testing.T) {
+	author := "did:plc:author"
+	definition := models.SurveyDefinition{
+		Questions: []models.Question{
+			{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}}},
+		},
+	}
+	translated := &models.SurveyDefinition{
+		Questions: []models.Question{
+			{ID: "q1", Text: "¿Dónde?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}}},
+		},
+	}
+
+	setup := func(result *generator.GenerateResult, err error) (*echo.Echo, *Handlers, *MockSurveyGenerator, *capturingLogDB) {
+		e, mq, h := setupTest()
+		mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:         uuid.New(),
+			AuthorDID:  &author,
+			Slug:       "team-lunch",
+			Title:      "Team lunch",
+			Definition: definition,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
+		gen := NewMockSurveyGenerator(result, err)
+		h.SetGenerator(gen, NewMockRateLimiter(true, true))
+		logs := &capturingLogDB{}
+		h.SetLogger(generator.NewGenerationLogger(logs))
+		return e, h, gen, logs
+	}
+
+	translate := func(t *testing.T, e *echo.Echo, h *Handlers, slug string, user *oauth.User, body TranslateSurveyRequest) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/"+slug+"/translate", bytes.NewReader(raw))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/surveys/:slug/translate")
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+		if user != nil {
+			c.Set("user", user)
+		}
+		require.NoError(t, h.TranslateSurvey(c))
+		return rec
+	}
+
+	t.Run("author gets the translated definition", func(t *testing.T) {
+		e, h, gen, logs := setup(&generator.GenerateResult{Definition: translated, InputTokens: 80, OutputTokens: 40}, nil)
+
+		rec := translate(t, e, h, "team-lunch", &oauth.User{DID: author}, TranslateSurveyRequest{Lang: "es-mx", Consent: true})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp GenerateSurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, translated, resp.Definition)
+		assert.Equal(t, "es-MX", resp.Lang)
+		assert.Equal(t, 120, resp.TokensUsed)
+
+		assert.Equal(t, "es-MX", gen.lang)
+		assert.Equal(t, &definition, gen.modified)
+
+		require.Len(t, logs.logs, 1)
+		assert.Equal(t, generator.KindTranslate, logs.logs[0].Kind)
+		assert.Equal(t, "success", logs.logs[0].Status)
+		assert.Equal(t, "Translate survey team-lunch into es-MX", logs.logs[0].InputPrompt)
+	})
+
+	t.Run("structure drift is rejected and logged", func(t *testing.T) {
+		drift := fmt.Errorf("%w: question 0: 3 options instead of 2", generator.ErrStructureChanged)
+		e, h, _, logs := setup(&generator.GenerateResult{RawResponse: `{"questions":[]}`}, drift)
+
+		rec := translate(t, e, h, "team-lunch", &oauth.User{DID: author}, TranslateSurveyRequest{Lang: "fr", Consent: true})
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		var resp ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Error, "translation added, removed or changed questions or options")
+		assert.Contains(t, resp.Details, "3 options instead of 2")
+
+		require.Len(t, logs.logs, 1)
+		assert.Equal(t, generator.KindTranslate, logs.logs[0].Kind)
+		assert.Equal(t, "error", logs.logs[0].Status)
+		assert.Equal(t, `{"questions":[]}`, logs.logs[0].RawResponse)
+	})
+
+	rejected := []struct {
+		name   string
+		slug   string
+		user   *oauth.User
+		body   TranslateSurveyRequest
+		status int
+	}{
+		{"anonymous", "team-lunch", nil, TranslateSurveyRequest{Lang: "fr", Consent: true}, http.StatusUnauthorized},
+		{"not the author", "team-lunch", &oauth.User{DID: "did:plc:other"}, TranslateSurveyRequest{Lang: "fr", Consent: true}, http.StatusForbidden},
+		{"unknown survey", "nope", &oauth.User{DID: author}, TranslateSurveyRequest{Lang: "fr", Consent: true}, http.StatusNotFound},
+		{"no consent", "team-lunch", &oauth.User{DID: author}, TranslateSurveyRequest{Lang: "fr"}, http.StatusBadRequest},
+		{"invalid lang", "team-lunch", &oauth.User{DID: author}, TranslateSurveyRequest{Lang: "not a language", Consent: true}, http.StatusBadRequest},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			e, h, gen, _ := setup(&generator.GenerateResult{Definition: translated}, nil)
+
+			rec := translate(t, e, h, tt.slug, tt.user, tt.body)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Nil(t, gen.modified, "the generator isn't called")
+		})
+	}
+}
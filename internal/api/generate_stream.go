@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/openmeet-team/survey/internal/oauth"
+)
+
+// generationStreamTTL is how long a started generation waits for its stream
+// to be opened
+const generationStreamTTL = time.Minute
+
+// GenerationStreamStoreInterface holds generations started with
+// StartGenerationStream until their stream is opened. With more than one
+// replica it must be shared, since the stream may be opened on any of them.
+type GenerationStreamStoreInterface interface {
+	SavePendingGeneration(ctx context.Context, g *db.PendingGeneration) error
+	// ClaimPendingGeneration removes and returns the generation with id if
+	// userID started it and it hasn't expired, or returns nil
+	ClaimPendingGeneration(ctx context.Context, id uuid.UUID, userID string) (*db.PendingGeneration, error)
+}
+
+// SetGenerationStreams keeps started generations in store instead of in
+// this process
+func (h *Handlers) SetGenerationStreams(store GenerationStreamStoreInterface) {
+	h.generationStreams = store
+}
+
+// generationStreams keeps started generations in memory, for a single
+// process. Each can be streamed once.
+type generationStreams struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]db.PendingGeneration
+	now  func() time.Time
+}
+
+func newGenerationStreams() *generationStreams {
+	return &generationStreams{
+		jobs: make(map[uuid.UUID]db.PendingGeneration),
+		now:  time.Now,
+	}
+}
+
+// SavePendingGeneration stores g, sweeping out expired generations
+func (s *generationStreams) SavePendingGeneration(_ context.Context, g *db.PendingGeneration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, pending := range s.jobs {
+		if now.After(pending.ExpiresAt) {
+			delete(s.jobs, id)
+		}
+	}
+
+	s.jobs[g.ID] = *g
+	return nil
+}
+
+// ClaimPendingGeneration removes and returns the generation with id if
+// userID started it and it hasn't expired, or returns nil
+func (s *generationStreams) ClaimPendingGeneration(_ context.Context, id uuid.UUID, userID string) (*db.PendingGeneration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.jobs[id]
+	if !ok || pending.UserID != userID {
+		return nil, nil
+	}
+	delete(s.jobs, id)
+	if s.now().After(pending.ExpiresAt) {
+		return nil, nil
+	}
+	return &pending, nil
+}
+
+// StartGenerationStream checks an AI generation request like GenerateSurvey,
+// and returns an ID to stream the generation with
+// POST /api/v1/surveys/generate/stream
+func (h *Handlers) StartGenerationStream(c echo.Context) error {
+	job, err := h.prepareGeneration(c)
+	if job == nil {
+		return err
+	}
+
+	pending := &db.PendingGeneration{
+		ID:          uuid.New(),
+		UserID:      job.userID,
+		UserType:    job.userType,
+		Description: job.description,
+		Existing:    job.existing,
+		TranslateTo: job.translateTo,
+		ExpiresAt:   time.Now().Add(generationStreamTTL),
+	}
+	if err := h.generationStreams.SavePendingGeneration(c.Request().Context(), pending); err != nil {
+		c.Logger().Errorf("Failed to save pending generation: %v", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to start generation",
+		})
+	}
+
+	id := pending.ID.String()
+	return c.JSON(http.StatusAccepted, GenerateStreamResponse{
+		ID:        id,
+		StreamURL: "/api/v1/surveys/generate/stream?id=" + id,
+	})
+}
+
+// StreamGeneration runs a generation started with StartGenerationStream,
+// sending server-sent events: "progress" as each question is written, then
+// "result" with the GenerateSurvey response, or "error"
+// GET /api/v1/surveys/generate/stream?id=
+func (h *Handlers) StreamGeneration(c echo.Context) error {
+	// Only whoever started the generation may stream it
+	userID := getClientIP(c)
+	if user := oauth.GetUser(c); user != nil {
+		userID = user.DID
+	}
+	var pending *db.PendingGeneration
+	if id, err := uuid.Parse(c.QueryParam("id")); err == nil {
+		pending, err = h.generationStreams.ClaimPendingGeneration(c.Request().Context(), id, userID)
+		if err != nil {
+			c.Logger().Errorf("Failed to claim pending generation: %v", err)
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error: "Failed to start generation",
+			})
+		}
+	}
+	if pending == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error: "Generation not found",
+		})
+	}
+	job := &generationJob{
+		description: pending.Description,
+		existing:    pending.Existing,
+		translateTo: pending.TranslateTo,
+		userID:      pending.UserID,
+		userType:    pending.UserType,
+	}
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // don't let a proxy hold events back
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	estimated := generator.EstimateQuestionCount(job.description, job.existing)
+	ctx := generator.WithProgress(c.Request().Context(), func(progress generator.QuestionProgress) {
+		_ = writeEvent(w, "progress", GenerateProgressEvent{
+			Question:       progress.Number,
+			Text:           progress.Text,
+			EstimatedTotal: estimatedTotal(estimated, progress.Number),
+		})
+	})
+	c.SetRequest(c.Request().WithContext(ctx))
+
+	status, body := h.runGeneration(c, job)
+	if errBody, ok := body.(ErrorResponse); ok {
+		return writeEvent(w, "error", GenerateErrorEvent{Status: status, ErrorResponse: errBody})
+	}
+	return writeEvent(w, "result", body)
+}
+
+// estimatedTotal is the question count to show with progress: the estimate,
+// unless the model has already written more, or zero when unknown
+func estimatedTotal(estimated, written int) int {
+	if estimated == 0 {
+		return 0
+	}
+	return max(estimated, written)
+}
+
+// writeEvent sends one server-sent event with data as JSON
+func writeEvent(w *echo.Response, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
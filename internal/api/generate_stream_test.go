@@ -0,0 +1,273 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingProvider is a generator.Provider that streams its response in
+// small chunks when asked
+type streamingProvider struct {
+	json string
+	err  error
+}
+
+func (p *streamingProvider) Name() string  { return "openai" }
+func (p *streamingProvider) Model() string { return "gpt-4o-mini" }
+func (p *streamingProvider) Pricing() generator.Pricing {
+	return generator.OpenAIPricing["gpt-4o-mini"]
+}
+
+func (p *streamingProvider) Generate(_ context.Context, req generator.GenerationRequest) (*generator.GenerationResult, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if req.OnChunk != nil {
+		for i := 0; i < len(p.json); i += 10 {
+			req.OnChunk(p.json[i:min(i+10, len(p.json))])
+		}
+	}
+	return &generator.GenerationResult{JSON: p.json, InputTokens: 900, OutputTokens: 120, CostUSD: 0.0002}, nil
+}
+
+const threeQuestionSurvey = `{"questions":[` +
+	`{"id":"q1","text":"Favorite pizza?","type":"single","required":false,"options":[{"id":"opt1","text":"Margherita"},{"id":"opt2","text":"Pepperoni"}]},` +
+	`{"id":"q2","text":"How hungry are you?","type":"rating","required":false,"min":1,"max":5,"options":[]},` +
+	`{"id":"q3","text":"Anything else?","type":"text","required":false,"options":[]}` +
+	`],"anonymous":false}`
+
+// sseEvent is one parsed server-sent event
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+func newStreamingHandlers(provider generator.Provider) (*Handlers, *MockGenerationLogger) {
+	logger := &MockGenerationLogger{}
+	h := NewHandlers(nil)
+	h.SetGenerator(generator.NewSurveyGeneratorWithProvider(provider), NewMockRateLimiter(true, true))
+	h.SetLogger(logger)
+	return h, logger
+}
+
+// startStream posts a streamed generation and returns its ID
+func startStream(t *testing.T, e *echo.Echo, h *Handlers, request GenerateSurveyRequest) GenerateStreamResponse {
+	t.Helper()
+	body, _ := json.Marshal(request)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate/stream", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.StartGenerationStream(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	var started GenerateStreamResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &started))
+	return started
+}
+
+func openStream(t *testing.T, e *echo.Echo, h *Handlers, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys/generate/stream?id="+id, nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.StreamGeneration(e.NewContext(req, rec)))
+	return rec
+}
+
+func TestGenerationStream_StreamsProgressAndResult(t *testing.T) {
+	e := echo.New()
+	h, logger := newStreamingHandlers(&streamingProvider{json: threeQuestionSurvey})
+
+	started := startStream(t, e, h, GenerateSurveyRequest{Description: "A 3 question poll about pizza", Consent: true})
+	assert.NotEmpty(t, started.ID)
+	assert.Equal(t, "/api/v1/surveys/generate/stream?id="+started.ID, started.StreamURL)
+
+	rec := openStream(t, e, h, started.ID)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+
+	events := parseEvents(t, rec.Body.String())
+	require.Len(t, events, 4)
+
+	for i, want := range []string{"Favorite pizza?", "How hungry are you?", "Anything else?"} {
+		assert.Equal(t, "progress", events[i].name)
+		var progress GenerateProgressEvent
+		require.NoError(t, json.Unmarshal([]byte(events[i].data), &progress))
+		assert.Equal(t, GenerateProgressEvent{Question: i + 1, Text: want, EstimatedTotal: 3}, progress)
+	}
+
+	// The final event carries the same validated payload as GenerateSurvey
+	assert.Equal(t, "result", events[3].name)
+	var result GenerateSurveyResponse
+	require.NoError(t, json.Unmarshal([]byte(events[3].data), &result))
+	require.NotNil(t, result.Definition)
+	assert.Len(t, result.Definition.Questions, 3)
+	assert.Equal(t, 1020, result.TokensUsed)
+	assert.InDelta(t, 0.0002, result.Cost, 1e-12)
+
+	// Streamed generations are logged with their token usage
+	require.Len(t, logger.successCalls, 1)
+	assert.Equal(t, 900, logger.successCalls[0].Result.InputTokens)
+	assert.Equal(t, 120, logger.successCalls[0].Result.OutputTokens)
+}
+
+func TestGenerationStream_Error(t *testing.T) {
+	e := echo.New()
+	h, logger := newStreamingHandlers(&streamingProvider{err: errors.New("API returned unexpected status code: 401")})
+
+	started := startStream(t, e, h, GenerateSurveyRequest{Description: "A poll about pizza", Consent: true})
+	rec := openStream(t, e, h, started.ID)
+
+	events := parseEvents(t, rec.Body.String())
+	require.Len(t, events, 1)
+	assert.Equal(t, "error", events[0].name)
+
+	var failure GenerateErrorEvent
+	require.NoError(t, json.Unmarshal([]byte(events[0].data), &failure))
+	assert.Equal(t, http.StatusInternalServerError, failure.Status)
+	assert.Equal(t, "AI generation failed", failure.Error)
+	assert.Len(t, logger.errorCalls, 1)
+}
+
+func TestGenerationStream_ChecksRequestsLikeGenerateSurvey(t *testing.T) {
+	e := echo.New()
+	h, _ := newStreamingHandlers(&streamingProvider{json: threeQuestionSurvey})
+
+	body, _ := json.Marshal(GenerateSurveyRequest{Description: "A poll", Consent: false})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate/stream", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	require.NoError(t, h.StartGenerationStream(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, h.generationStreams.(*generationStreams).jobs)
+}
+
+func TestGenerationStream_StreamsOnce(t *testing.T) {
+	e := echo.New()
+	h, _ := newStreamingHandlers(&streamingProvider{json: threeQuestionSurvey})
+
+	started := startStream(t, e, h, GenerateSurveyRequest{Description: "A poll about pizza", Consent: true})
+	assert.Equal(t, http.StatusOK, openStream(t, e, h, started.ID).Code)
+	assert.Equal(t, http.StatusNotFound, openStream(t, e, h, started.ID).Code)
+	assert.Equal(t, http.StatusNotFound, openStream(t, e, h, "unknown").Code)
+}
+
+func TestGenerationStream_SharedStore(t *testing.T) {
+	e := echo.New()
+	shared := newGenerationStreams()
+
+	// Started on one replica, streamed from another
+	first, _ := newStreamingHandlers(&streamingProvider{json: threeQuestionSurvey})
+	first.SetGenerationStreams(shared)
+	second, _ := newStreamingHandlers(&streamingProvider{json: threeQuestionSurvey})
+	second.SetGenerationStreams(shared)
+
+	started := startStream(t, e, first, GenerateSurveyRequest{Description: "A poll about pizza", Consent: true})
+	rec := openStream(t, e, second, started.ID)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	events := parseEvents(t, rec.Body.String())
+	require.NotEmpty(t, events)
+	assert.Equal(t, "result", events[len(events)-1].name)
+}
+
+func TestGenerationStream_StoreError(t *testing.T) {
+	e := echo.New()
+	h, _ := newStreamingHandlers(&streamingProvider{json: threeQuestionSurvey})
+	h.SetGenerationStreams(failingGenerationStreams{})
+
+	body, _ := json.Marshal(GenerateSurveyRequest{Description: "A poll about pizza", Consent: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/generate/stream", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.StartGenerationStream(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	assert.Equal(t, http.StatusInternalServerError, openStream(t, e, h, uuid.NewString()).Code)
+}
+
+// failingGenerationStreams is a GenerationStreamStoreInterface whose
+// database is down
+type failingGenerationStreams struct{}
+
+func (failingGenerationStreams) SavePendingGeneration(context.Context, *db.PendingGeneration) error {
+	return errors.New("connection refused")
+}
+
+func (failingGenerationStreams) ClaimPendingGeneration(context.Context, uuid.UUID, string) (*db.PendingGeneration, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestGenerationStreams(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	streams := newGenerationStreams()
+	streams.now = func() time.Time { return now }
+
+	add := func(userID string) uuid.UUID {
+		t.Helper()
+		g := &db.PendingGeneration{ID: uuid.New(), UserID: userID, ExpiresAt: now.Add(generationStreamTTL)}
+		require.NoError(t, streams.SavePendingGeneration(ctx, g))
+		return g.ID
+	}
+	claim := func(id uuid.UUID, userID string) *db.PendingGeneration {
+		t.Helper()
+		g, err := streams.ClaimPendingGeneration(ctx, id, userID)
+		require.NoError(t, err)
+		return g
+	}
+
+	t.Run("only the requester may claim", func(t *testing.T) {
+		id := add("192.0.2.1")
+		assert.Nil(t, claim(id, "192.0.2.2"))
+		assert.NotNil(t, claim(id, "192.0.2.1"))
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		id := add("192.0.2.1")
+		now = now.Add(generationStreamTTL + time.Second)
+		assert.Nil(t, claim(id, "192.0.2.1"))
+	})
+
+	t.Run("adding sweeps expired generations", func(t *testing.T) {
+		add("192.0.2.1")
+		now = now.Add(generationStreamTTL + time.Second)
+		add("192.0.2.1")
+		assert.Len(t, streams.jobs, 1)
+	})
+}
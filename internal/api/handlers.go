@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,11 +17,14 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/export"
 	"github.com/openmeet-team/survey/internal/generator"
 	"github.com/openmeet-team/survey/internal/models"
 	"github.com/openmeet-team/survey/internal/oauth"
 	"github.com/openmeet-team/survey/internal/telemetry"
 	"github.com/openmeet-team/survey/internal/templates"
+	"golang.org/x/text/language"
 )
 
 // QueriesInterface defines the interface for database queries
@@ -27,32 +33,69 @@ type QueriesInterface interface {
 	CreateSurvey(ctx context.Context, s *models.Survey) error
 	GetSurveyBySlug(ctx context.Context, slug string) (*models.Survey, error)
 	GetSurveyByURI(ctx context.Context, uri string) (*models.Survey, error)
-	ListSurveys(ctx context.Context, limit, offset int) ([]*models.Survey, error)
+	UpdateSurveyIfVersion(ctx context.Context, s *models.Survey, version int) error
+	ListSurveys(ctx context.Context, params db.ListSurveysParams) ([]*models.Survey, string, error)
+	SearchSurveys(ctx context.Context, query string, limit, offset int) ([]*models.SurveySearchResult, error)
+	CountSitemapSurveys(ctx context.Context) (int, error)
+	StreamSitemapSurveys(ctx context.Context, offset, limit int, fn func(db.SitemapSurvey) error) error
+	GetTextAnswers(ctx context.Context, surveyURI, questionID string, limit, offset int) ([]string, error)
+	CountTextAnswers(ctx context.Context, surveyURI, questionID string) (int, error)
+	StreamResponses(ctx context.Context, surveyURI string, fn func(db.ResponseRow) error) error
+	GetSurveysByAuthor(ctx context.Context, did string, limit, offset int, filter db.AuthorSurveyFilter) ([]*models.AuthorSurvey, error)
+	CountSurveysByAuthor(ctx context.Context, did string, filter db.AuthorSurveyFilter) (int, error)
 	SlugExists(ctx context.Context, slug string) (bool, error)
 	CreateResponse(ctx context.Context, r *models.Response) error
 	GetResponseBySurveyAndVoter(ctx context.Context, surveyID uuid.UUID, voterDID, voterSession string) (*models.Response, error)
+	GetResponseCount(ctx context.Context, surveyID uuid.UUID) (int, error)
 	GetSurveyResults(ctx context.Context, surveyID uuid.UUID) (*models.SurveyResults, error)
 	UpdateSurveyResults(ctx context.Context, surveyID uuid.UUID, resultsURI, resultsCID string) error
+	GetPublishedResults(ctx context.Context, surveyURI string) (*models.PublishedResults, error)
+	ListCommentsBySurvey(ctx context.Context, surveyID uuid.UUID, limit int) ([]*models.Comment, error)
 	GetStats(ctx context.Context) (*models.Stats, error)
+	GetHandle(ctx context.Context, did string) (string, error)
+	GetHandles(ctx context.Context, dids []string) (map[string]string, error)
+	GetSurveyCounts(ctx context.Context, uris []string) (map[string]int, error)
+	UpsertHandle(ctx context.Context, did, handle string) error
+}
+
+// ProfileCacheInterface serves Bluesky profiles. *oauth.ProfileCache
+// implements it.
+type ProfileCacheInterface interface {
+	Get(ctx context.Context, did string) (*oauth.Profile, error)
 }
 
 // GeneratorInterface defines the interface for AI survey generation
 type GeneratorInterface interface {
 	Generate(ctx context.Context, prompt string) (*generator.GenerateResult, error)
-	GenerateRaw(ctx context.Context, prompt string) (*generator.GenerateResult, error)
+	Modify(ctx context.Context, existing *models.SurveyDefinition, instruction string) (*generator.GenerateResult, error)
+	Translate(ctx context.Context, def *models.SurveyDefinition, lang string) (*generator.GenerateResult, error)
+	Summarize(ctx context.Context, question string, answers []string) (*generator.GenerateResult, error)
 	ValidateInput(input string) error
+	ScreenInput(ctx context.Context, input string) error
 }
 
-// RateLimiterInterface defines the interface for rate limiting
+// RateLimiterInterface defines the interface for rate limiting. When a
+// request isn't allowed, retryAfter is how long until one may be.
 type RateLimiterInterface interface {
-	AllowAnonymous(ip string) bool
-	AllowAuthenticated(did string) bool
+	AllowAnonymous(ctx context.Context, ip string) (allowed bool, retryAfter time.Duration, err error)
+	AllowAuthenticated(ctx context.Context, did string) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// BudgetCheckerInterface defines the interface for daily AI spending budgets
+type BudgetCheckerInterface interface {
+	Check(ctx context.Context, userID, userType string) error
 }
 
 // GenerationLoggerInterface defines the interface for logging AI generation attempts
 type GenerationLoggerInterface interface {
 	LogSuccess(ctx context.Context, userID, userType, inputPrompt, systemPrompt, rawResponse string, result *generator.GenerateResult, durationMS int) error
 	LogError(ctx context.Context, userID, userType, inputPrompt, systemPrompt, rawResponse, status, errorMessage string, inputTokens, outputTokens int, costUSD float64, durationMS int) error
+	LogFailedAttempts(ctx context.Context, userID, userType, inputPrompt, systemPrompt string, attempts []generator.FailedAttempt) error
+}
+
+// ResponseSubscriberInterface announces changes to a survey's responses
+type ResponseSubscriberInterface interface {
+	Subscribe(uri string) (<-chan struct{}, func())
 }
 
 // Handlers holds the HTTP handlers and dependencies
@@ -64,26 +107,57 @@ type Handlers struct {
 	posthogKey     string
 	generator      GeneratorInterface
 	generatorRL    RateLimiterInterface
+	budget         BudgetCheckerInterface // daily spending budgets; nil disables them
 	generationLog  GenerationLoggerInterface
+	resolveHandle  func(ctx context.Context, did string) (string, error) // fallback when no handle is stored
+	profiles       ProfileCacheInterface            // author profiles; nil shows just the handle
+	getRecord      func(c echo.Context, collection, rkey string) (*oauth.PDSRecord, error)                           // reads from the signed-in user's PDS
+	updateRecord   func(c echo.Context, collection, rkey string, record interface{}, swapCID string) (cid string, err error) // writes to the signed-in user's PDS, failing with oauth.ErrRecordChanged unless it's still at swapCID
+	adminToken     string // bearer token for the admin API; empty disables it
+	aiStats        GenerationStatsInterface
+	auditLog       AuditLogInterface
+	aiLogs         GenerationLogsInterface
+	promptVersions PromptVersionsInterface
+	summaries      TextSummaryStoreInterface // cached AI summaries of text answers; nil disables summarizing
+	generationStreams GenerationStreamStoreInterface // generations waiting for their event stream
+	generationTimeout time.Duration      // how long a generation may take in all
+	ogImages          OGImagesInterface  // per-survey Open Graph images; nil uses the default image
+	embedFrameAncestors string           // CSP frame-ancestors sources for embed pages; empty allows any site
+	siteHost          string             // public hostname for canonical URLs; empty disables QR codes
+	responses         ResponseSubscriberInterface // wakes waiting results polls; nil polls without waiting
 }
 
 // NewHandlers creates a new Handlers instance
 func NewHandlers(q QueriesInterface) *Handlers {
-	return &Handlers{
-		queries:      q,
-		oauthStorage: nil, // Optional: can be nil if OAuth not configured
-		supportURL:   "",
-	}
+	h := &Handlers{
+		queries:       q,
+		oauthStorage:  nil, // Optional: can be nil if OAuth not configured
+		supportURL:    "",
+		resolveHandle: resolveHandleViaProfile,
+
+		generationStreams: newGenerationStreams(),
+		generationTimeout: generator.DefaultGenerationTimeout,
+	}
+	h.getRecord = h.getRecordViaSession
+	h.updateRecord = h.updateRecordViaSession
+	return h
 }
 
 // NewHandlersWithOAuth creates a new Handlers instance with OAuth support
 func NewHandlersWithOAuth(q QueriesInterface, oauthStorage *oauth.Storage, oauthConfig *oauth.Config) *Handlers {
-	return &Handlers{
-		queries:      q,
-		oauthStorage: oauthStorage,
-		oauthConfig:  oauthConfig,
-		supportURL:   "",
-	}
+	h := &Handlers{
+		queries:       q,
+		oauthStorage:  oauthStorage,
+		oauthConfig:   oauthConfig,
+		supportURL:    "",
+		resolveHandle: resolveHandleViaProfile,
+
+		generationStreams: newGenerationStreams(),
+		generationTimeout: generator.DefaultGenerationTimeout,
+	}
+	h.getRecord = h.getRecordViaSession
+	h.updateRecord = h.updateRecordViaSession
+	return h
 }
 
 // SetSupportURL sets the support URL for the handlers
@@ -102,11 +176,119 @@ func (h *Handlers) SetGenerator(gen GeneratorInterface, rl RateLimiterInterface)
 	h.generatorRL = rl
 }
 
+// SetGenerationTimeout sets how long a generation may take, across retries
+// and fallbacks, before it's abandoned
+func (h *Handlers) SetGenerationTimeout(timeout time.Duration) {
+	h.generationTimeout = timeout
+}
+
+// SetBudget enforces daily AI spending budgets on generation requests
+func (h *Handlers) SetBudget(budget BudgetCheckerInterface) {
+	h.budget = budget
+}
+
 // SetLogger sets the generation logger for AI survey generation
 func (h *Handlers) SetLogger(logger GenerationLoggerInterface) {
 	h.generationLog = logger
 }
 
+// SetProfileCache sets the cache survey pages use to show their author's
+// display name and avatar
+func (h *Handlers) SetProfileCache(profiles ProfileCacheInterface) {
+	h.profiles = profiles
+}
+
+// SetHandleResolver resolves author handles we haven't stored through
+// resolver, so repeated lookups are served from its cache
+func (h *Handlers) SetHandleResolver(resolver *oauth.Resolver) {
+	h.resolveHandle = resolver.ResolveDID
+}
+
+// SetResponseSubscriber lets results polls wait for a survey's responses to
+// change instead of re-rendering on every poll
+func (h *Handlers) SetResponseSubscriber(responses ResponseSubscriberInterface) {
+	h.responses = responses
+}
+
+// resolveHandleViaProfile looks up a DID's handle from the public Bluesky API
+func resolveHandleViaProfile(_ context.Context, did string) (string, error) {
+	profile, err := oauth.GetProfile(did)
+	if err != nil {
+		return "", err
+	}
+	return profile.Handle, nil
+}
+
+// authorHandle returns the current handle for a survey's author.
+// Handles are kept current by the consumer from identity events; if we've never
+// seen one for this DID, resolve it via the public API and store it.
+// Returns empty string if the survey has no author or the handle can't be resolved.
+func (h *Handlers) authorHandle(c echo.Context, survey *models.Survey) string {
+	if survey.AuthorDID == nil || *survey.AuthorDID == "" {
+		return ""
+	}
+	return h.handleForDID(c, *survey.AuthorDID)
+}
+
+// handleForDID returns did's current handle: the stored one if we have it,
+// otherwise resolved via the public API and stored. Returns empty string if
+// the handle can't be resolved.
+func (h *Handlers) handleForDID(c echo.Context, did string) string {
+	ctx := c.Request().Context()
+
+	handle, err := h.queries.GetHandle(ctx, did)
+	if err != nil {
+		c.Logger().Errorf("Failed to get handle for %s: %v", did, err)
+	}
+	if handle != "" {
+		return handle
+	}
+	return h.resolveAndStoreHandle(c, did)
+}
+
+// resolveAndStoreHandle resolves the handle of a DID we have none stored
+// for and stores it. Returns empty string if it can't be resolved.
+func (h *Handlers) resolveAndStoreHandle(c echo.Context, did string) string {
+	if h.resolveHandle == nil {
+		return ""
+	}
+	ctx := c.Request().Context()
+	handle, err := h.resolveHandle(ctx, did)
+	if err != nil || handle == "" {
+		return ""
+	}
+
+	if err := h.queries.UpsertHandle(ctx, did, handle); err != nil {
+		c.Logger().Errorf("Failed to store handle for %s: %v", did, err)
+	}
+
+	return handle
+}
+
+// authorProfile returns the profile shown in a survey's author header. The
+// handle comes from authorHandle, which identity events keep current; if no
+// profile is cached and it can't be fetched, only the handle is shown.
+// Returns nil if there's no handle to show.
+func (h *Handlers) authorProfile(c echo.Context, survey *models.Survey) *oauth.Profile {
+	handle := h.authorHandle(c, survey)
+	if handle == "" {
+		return nil
+	}
+	did := *survey.AuthorDID
+
+	if h.profiles != nil {
+		profile, err := h.profiles.Get(c.Request().Context(), did)
+		if err == nil {
+			author := *profile
+			author.Handle = handle
+			return &author
+		}
+		c.Logger().Errorf("Failed to get profile for %s: %v", did, err)
+	}
+
+	return &oauth.Profile{DID: did, Handle: handle}
+}
+
 // ensureValidToken checks if the session's access token is valid and refreshes if needed.
 // Returns error if refresh is needed but fails (caller should invalidate session).
 // Returns nil if OAuth is not configured (config is nil).
@@ -116,8 +298,40 @@ func (h *Handlers) ensureValidToken(ctx context.Context, session *oauth.OAuthSes
 		return nil
 	}
 
+	// A nil *Storage must reach EnsureValidToken as a nil interface
+	var storage oauth.SessionTokenUpdater
+	if h.oauthStorage != nil {
+		storage = h.oauthStorage
+	}
+
 	// Call the oauth package's EnsureValidToken function
-	return oauth.EnsureValidToken(ctx, session, h.oauthStorage, *h.oauthConfig)
+	return oauth.EnsureValidToken(ctx, session, storage, *h.oauthConfig)
+}
+
+// tokenRefreshRetryAfter is the Retry-After, in seconds, sent when a token
+// refresh fails transiently
+const tokenRefreshRetryAfter = "30"
+
+// tokenRefreshFailed handles an error from ensureValidToken and returns the
+// status and message to respond with. A session that can't be refreshed is
+// deleted and its cookie cleared (401); a transient failure keeps the session
+// and asks the client to retry (503 with Retry-After).
+func (h *Handlers) tokenRefreshFailed(c echo.Context, err error) (int, string) {
+	c.Logger().Errorf("Failed to refresh access token: %v", err)
+
+	switch {
+	case errors.Is(err, oauth.ErrSessionInvalid):
+		if cookie, cookieErr := c.Cookie("session"); cookieErr == nil && h.oauthStorage != nil {
+			_ = h.oauthStorage.DeleteSession(c.Request().Context(), cookie.Value)
+		}
+		c.SetCookie(&http.Cookie{Name: "session", Value: "", MaxAge: -1, Path: "/"})
+		return http.StatusUnauthorized, "Session expired. Please log in again."
+	case errors.Is(err, oauth.ErrRefreshTransient):
+		c.Response().Header().Set("Retry-After", tokenRefreshRetryAfter)
+		return http.StatusServiceUnavailable, "Could not reach your login server. Please try again shortly."
+	default:
+		return http.StatusInternalServerError, "Failed to refresh your session"
+	}
 }
 
 // CreateSurvey creates a new survey
@@ -147,6 +361,9 @@ func (h *Handlers) CreateSurvey(c echo.Context) error {
 			Details: err.Error(),
 		})
 	}
+	if err := models.ValidateSchedule(req.StartsAt, req.EndsAt); err != nil {
+		return ValidationError(c, "Invalid schedule", err.Error())
+	}
 
 	// Generate or validate slug
 	slug := req.Slug
@@ -192,12 +409,21 @@ func (h *Handlers) CreateSurvey(c echo.Context) error {
 		Slug:       slug,
 		Title:      title,
 		Definition: *def,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
 		CreatedAt:  now,
 		UpdatedAt:  now,
 	}
 
 	// Save to database
 	if err := h.queries.CreateSurvey(c.Request().Context(), survey); err != nil {
+		if errors.Is(err, db.ErrSlugTaken) {
+			// Slug was claimed between the existence check and the insert
+			return c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Survey slug already exists",
+				Details: fmt.Sprintf("A survey with slug '%s' already exists", slug),
+			})
+		}
 		return InternalServerError(c, "Failed to create survey", err)
 	}
 
@@ -205,6 +431,252 @@ func (h *Handlers) CreateSurvey(c echo.Context) error {
 	return c.JSON(http.StatusCreated, ToSurveyResponse(survey, true))
 }
 
+// UpdateSurvey saves the author's edit of a survey to their PDS and the
+// index. The request carries the version the editor read; if the survey has
+// changed since, in another editor or directly on the PDS, the edit is
+// refused with 409 Conflict so the editor can reload instead of overwriting.
+// PUT /api/v1/surveys/:slug
+func (h *Handlers) UpdateSurvey(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+
+	var req UpdateSurveyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+	}
+	if req.Version < 1 {
+		return ValidationError(c, "Missing version", "Send the version returned when the survey was read")
+	}
+
+	def, err := models.ParseSurveyDefinition([]byte(req.Definition))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid survey definition",
+			Details: err.Error(),
+		})
+	}
+	if err := def.ValidateDefinition(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid survey definition",
+			Details: err.Error(),
+		})
+	}
+
+	slug := c.Param("slug")
+	ctx := c.Request().Context()
+
+	survey, err := h.queries.GetSurveyBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Survey not found",
+				Details: fmt.Sprintf("No survey found with slug '%s'", slug),
+			})
+		}
+		return InternalServerError(c, "Failed to retrieve survey", err)
+	}
+	if survey.URI == nil || survey.AuthorDID == nil || *survey.AuthorDID != user.DID {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Forbidden",
+			Details: "Only the survey's author can edit it",
+		})
+	}
+
+	// The slug lookup may be served from the cache; check the version
+	// against the database before writing to the PDS
+	current, err := h.queries.GetSurveyByURI(ctx, *survey.URI)
+	if err != nil {
+		return InternalServerError(c, "Failed to retrieve survey", err)
+	}
+	if current.Version != req.Version {
+		return versionConflict(c, current.Version)
+	}
+
+	// The record is rewritten whole, so start from what's on the PDS to keep
+	// fields this app doesn't manage. If it no longer matches the indexed
+	// CID, the survey was changed elsewhere and the index hasn't caught up.
+	rkey := (*current.URI)[strings.LastIndex(*current.URI, "/")+1:]
+	existing, err := h.getRecord(c, "net.openmeet.survey", rkey)
+	if err != nil {
+		c.Logger().Errorf("Failed to read survey %s from PDS: %v", *current.URI, err)
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "Failed to read survey from your PDS",
+			Details: err.Error(),
+		})
+	}
+	if current.CID != nil && existing.CID != *current.CID {
+		return versionConflict(c, 0)
+	}
+
+	if req.Title != nil {
+		current.Title = *req.Title
+	}
+	if req.Description != nil {
+		current.Description = req.Description
+	}
+	if req.StartsAt != nil {
+		current.StartsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		current.EndsAt = req.EndsAt
+	}
+	if err := models.ValidateSchedule(current.StartsAt, current.EndsAt); err != nil {
+		return ValidationError(c, "Invalid schedule", err.Error())
+	}
+	current.Definition = *def
+
+	// The PDS is the source of truth, so write there first, swapping out
+	// the record read above: a concurrent edit makes one of the two writes
+	// fail without writing anything. The consumer indexes the new record
+	// without bumping the version again, since the content matches what is
+	// saved below.
+	record := surveyRecord(current, def)
+	for field, value := range existing.Value {
+		if !surveyRecordFields[field] {
+			record[field] = value
+		}
+	}
+	cid, err := h.updateRecord(c, "net.openmeet.survey", rkey, record, existing.CID)
+	if err != nil {
+		if errors.Is(err, oauth.ErrRecordChanged) {
+			return versionConflict(c, 0)
+		}
+		if errors.Is(err, oauth.ErrSessionInvalid) || errors.Is(err, oauth.ErrRefreshTransient) {
+			status, message := h.tokenRefreshFailed(c, err)
+			return c.JSON(status, ErrorResponse{Error: message})
+		}
+		c.Logger().Errorf("Failed to update survey %s on PDS: %v", *current.URI, err)
+		return c.JSON(http.StatusBadGateway, ErrorResponse{
+			Error:   "Failed to save survey to your PDS",
+			Details: err.Error(),
+		})
+	}
+
+	current.CID = &cid
+	if err := h.queries.UpdateSurveyIfVersion(ctx, current, req.Version); err != nil {
+		if !errors.Is(err, db.ErrVersionConflict) {
+			return InternalServerError(c, "Failed to update survey", err)
+		}
+		// The swap succeeded, so the edit is saved on the PDS; the index
+		// moved on some other way (such as the consumer indexing the edit
+		// first) and will be brought in line by the consumer
+		c.Logger().Warnf("Survey %s changed in the index while saving version %d", *current.URI, req.Version)
+		if indexed, err := h.queries.GetSurveyByURI(ctx, *current.URI); err == nil {
+			current.Version = indexed.Version
+		}
+	}
+
+	return c.JSON(http.StatusOK, ToSurveyResponse(current, true))
+}
+
+// versionConflict responds 409 to an edit based on an outdated survey
+// version. current is the survey's version now, or 0 if unknown.
+func versionConflict(c echo.Context, current int) error {
+	details := "The survey was changed since you loaded it. Reload it and reapply your changes."
+	if current > 0 {
+		details = fmt.Sprintf("The survey is now at version %d. Reload it and reapply your changes.", current)
+	}
+	return c.JSON(http.StatusConflict, ErrorResponse{
+		Error:   "Survey was changed by someone else",
+		Details: details,
+	})
+}
+
+// surveyRecordFields are the net.openmeet.survey fields surveyRecord sets or
+// leaves out; any others on a record are carried over when it's edited
+var surveyRecordFields = map[string]bool{
+	"$type": true, "name": true, "description": true, "lang": true, "questions": true,
+	"anonymous": true, "allowMultipleResponses": true, "allowComments": true,
+	"discoverable": true, "pageSize": true, "startsAt": true, "endsAt": true, "createdAt": true,
+}
+
+// surveyRecord builds the net.openmeet.survey record for survey, with the
+// questions and settings of def
+func surveyRecord(survey *models.Survey, def *models.SurveyDefinition) map[string]interface{} {
+	record := map[string]interface{}{
+		"$type":     "net.openmeet.survey",
+		"name":      survey.Title,
+		"questions": def.Questions,
+		"createdAt": survey.CreatedAt.Format(time.RFC3339),
+	}
+
+	// Add optional fields if present
+	if survey.Description != nil && *survey.Description != "" {
+		record["description"] = *survey.Description
+	}
+	if survey.Lang != nil && *survey.Lang != "" {
+		record["lang"] = *survey.Lang
+	}
+	if survey.StartsAt != nil {
+		record["startsAt"] = survey.StartsAt.UTC().Format(time.RFC3339)
+	}
+	if survey.EndsAt != nil {
+		record["endsAt"] = survey.EndsAt.UTC().Format(time.RFC3339)
+	}
+	if def.Anonymous {
+		record["anonymous"] = def.Anonymous
+	}
+	if def.AllowMultipleResponses {
+		record["allowMultipleResponses"] = def.AllowMultipleResponses
+	}
+	if def.AllowComments {
+		record["allowComments"] = def.AllowComments
+	}
+	if def.Discoverable {
+		record["discoverable"] = def.Discoverable
+	}
+	if def.PageSize > 0 {
+		record["pageSize"] = def.PageSize
+	}
+
+	return record
+}
+
+// getRecordViaSession reads a record from the PDS of the user signed in to c
+func (h *Handlers) getRecordViaSession(c echo.Context, collection, rkey string) (*oauth.PDSRecord, error) {
+	if h.oauthStorage == nil {
+		return nil, fmt.Errorf("OAuth not configured")
+	}
+
+	session, err := oauth.GetSession(c, h.oauthStorage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	return oauth.GetRecord(session.PDSUrl, session.DID, collection, rkey)
+}
+
+// updateRecordViaSession replaces a record on the PDS of the user signed in
+// to c, provided it's still at swapCID, and returns its new CID
+func (h *Handlers) updateRecordViaSession(c echo.Context, collection, rkey string, record interface{}, swapCID string) (string, error) {
+	if h.oauthStorage == nil {
+		return "", fmt.Errorf("OAuth not configured")
+	}
+
+	session, err := oauth.GetSession(c, h.oauthStorage)
+	if err != nil {
+		return "", fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return "", fmt.Errorf("session not found")
+	}
+
+	if err := h.ensureValidToken(c.Request().Context(), session); err != nil {
+		return "", fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	_, cid, err := oauth.SwapRecord(session, collection, rkey, record, swapCID)
+	return cid, err
+}
+
 // GetSurvey retrieves a survey by slug
 // GET /api/v1/surveys/:slug
 func (h *Handlers) GetSurvey(c echo.Context) error {
@@ -224,10 +696,68 @@ func (h *Handlers) GetSurvey(c echo.Context) error {
 	return c.JSON(http.StatusOK, ToSurveyResponse(survey, true))
 }
 
-// ListSurveys retrieves a list of surveys with pagination
-// GET /api/v1/surveys?limit=20&offset=0
+// ListSurveys retrieves a page of discoverable surveys, newest first,
+// optionally filtered by BCP-47 language ("es" also matches "es-MX"), author
+// DID and status (open, closed or scheduled). The cursor for the next page
+// is returned in the X-Next-Cursor header, which is absent on the last page.
+// GET /api/v1/surveys?limit=20&cursor=...&lang=es&author=did:plc:...&status=open
 func (h *Handlers) ListSurveys(c echo.Context) error {
-	// Parse pagination parameters
+	// Parse pagination parameters. The list is public, so it only has
+	// surveys their authors chose to list.
+	params := db.ListSurveysParams{
+		Limit:        20, // default
+		Cursor:       c.QueryParam("cursor"),
+		AuthorDID:    c.QueryParam("author"),
+		Discoverable: true,
+	}
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			params.Limit = l
+		}
+	}
+
+	if statusStr := c.QueryParam("status"); statusStr != "" {
+		status, err := models.ParseSurveyStatus(statusStr)
+		if err != nil || status == models.SurveyStatusDeleted {
+			return ValidationError(c, "Invalid status", "status must be open, closed or scheduled")
+		}
+		params.Status = status
+	}
+
+	// Canonicalize so "ES" or "es_mx" match what the consumer stored
+	if langStr := c.QueryParam("lang"); langStr != "" {
+		tag, err := language.Parse(langStr)
+		if err != nil {
+			return ValidationError(c, "Invalid lang", fmt.Sprintf("'%s' is not a valid BCP-47 language tag", langStr))
+		}
+		params.Lang = tag.String()
+	}
+
+	surveys, next, err := h.queries.ListSurveys(c.Request().Context(), params)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidCursor) {
+			return ValidationError(c, "Invalid cursor", "Use the X-Next-Cursor value from the previous page")
+		}
+		return InternalServerError(c, "Failed to retrieve surveys", err)
+	}
+	if next != "" {
+		c.Response().Header().Set(headerNextCursor, next)
+	}
+
+	// Convert to list response (without definitions)
+	result := make([]SurveyListResponse, len(surveys))
+	for i, s := range surveys {
+		result[i] = *ToSurveyListResponse(s)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// SearchSurveys finds discoverable surveys by keyword, most relevant first.
+// An empty query returns no results.
+// GET /api/v1/surveys/search?q=coffee&limit=20&offset=0
+func (h *Handlers) SearchSurveys(c echo.Context) error {
 	limit := 20 // default
 	offset := 0
 
@@ -243,18 +773,127 @@ func (h *Handlers) ListSurveys(c echo.Context) error {
 		}
 	}
 
-	surveys, err := h.queries.ListSurveys(c.Request().Context(), limit, offset)
+	results, err := h.queries.SearchSurveys(c.Request().Context(), c.QueryParam("q"), limit, offset)
+	if err != nil {
+		return InternalServerError(c, "Failed to search surveys", err)
+	}
+
+	response := make([]SurveySearchResponse, len(results))
+	for i, r := range results {
+		response[i] = *ToSurveySearchResponse(r)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// ListUserSurveys retrieves a page of the surveys published by a DID, newest
+// first, with their response counts. Others see only discoverable surveys;
+// signed in as the DID, unlisted surveys are included too, and
+// includeDeleted=true also lists soft-deleted ones. status keeps only open,
+// closed, scheduled or (for the DID only) deleted surveys. The total for the
+// same filter is returned in the X-Total-Count header.
+// GET /api/v1/users/:did/surveys?limit=20&offset=0&includeDeleted=true&status=open
+func (h *Handlers) ListUserSurveys(c echo.Context) error {
+	did := c.Param("did")
+	if !strings.HasPrefix(did, "did:") {
+		return ValidationError(c, "Invalid DID", fmt.Sprintf("'%s' is not a DID", did))
+	}
+
+	limit := 20 // default
+	offset := 0
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	user := oauth.GetUser(c)
+	isOwner := user != nil && user.DID == did
+
+	var filter db.AuthorSurveyFilter
+	if isOwner {
+		filter.IncludeUnlisted = true
+	}
+	if includeStr := c.QueryParam("includeDeleted"); includeStr != "" {
+		include, err := strconv.ParseBool(includeStr)
+		if err != nil {
+			return ValidationError(c, "Invalid includeDeleted", "includeDeleted must be true or false")
+		}
+		if include && !isOwner {
+			return c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Forbidden",
+				Details: "Only the author can list their deleted surveys",
+			})
+		}
+		filter.IncludeDeleted = include
+	}
+	if statusStr := c.QueryParam("status"); statusStr != "" {
+		status, err := models.ParseSurveyStatus(statusStr)
+		if err != nil {
+			return ValidationError(c, "Invalid status", "status must be open, closed, scheduled or deleted")
+		}
+		if status == models.SurveyStatusDeleted {
+			if !isOwner {
+				return c.JSON(http.StatusForbidden, ErrorResponse{
+					Error:   "Forbidden",
+					Details: "Only the author can list their deleted surveys",
+				})
+			}
+			filter.IncludeDeleted = true
+		}
+		filter.Status = status
+	}
+
+	ctx := c.Request().Context()
+	results, err := h.queries.GetSurveysByAuthor(ctx, did, limit, offset, filter)
+	if err != nil {
+		return InternalServerError(c, "Failed to retrieve surveys", err)
+	}
+	total, err := h.queries.CountSurveysByAuthor(ctx, did, filter)
 	if err != nil {
 		return InternalServerError(c, "Failed to retrieve surveys", err)
 	}
+	c.Response().Header().Set(headerTotalCount, strconv.Itoa(total))
 
-	// Convert to list response (without definitions)
-	result := make([]SurveyListResponse, len(surveys))
-	for i, s := range surveys {
-		result[i] = *ToSurveyListResponse(s)
+	response := make([]AuthorSurveyResponse, len(results))
+	for i, r := range results {
+		response[i] = *ToAuthorSurveyResponse(r, isOwner)
 	}
 
-	return c.JSON(http.StatusOK, result)
+	return c.JSON(http.StatusOK, response)
+}
+
+// Reasons a response is refused with 403 Forbidden, in ErrorResponse.Reason
+const (
+	ReasonSurveyClosed     = "survey_closed"
+	ReasonSurveyNotYetOpen = "survey_not_yet_open"
+)
+
+// surveyNotAccepting returns why survey isn't accepting responses at now,
+// or nil if it is
+func surveyNotAccepting(survey *models.Survey, now time.Time) *ErrorResponse {
+	switch survey.StatusAt(now) {
+	case models.SurveyStatusClosed:
+		return &ErrorResponse{
+			Error:   "Survey closed",
+			Details: fmt.Sprintf("This survey closed at %s", survey.EndsAt.UTC().Format(time.RFC3339)),
+			Reason:  ReasonSurveyClosed,
+		}
+	case models.SurveyStatusScheduled:
+		return &ErrorResponse{
+			Error:   "Survey not yet open",
+			Details: fmt.Sprintf("This survey opens at %s", survey.StartsAt.UTC().Format(time.RFC3339)),
+			Reason:  ReasonSurveyNotYetOpen,
+		}
+	}
+	return nil
 }
 
 // SubmitResponse submits a response to a survey
@@ -274,6 +913,10 @@ func (h *Handlers) SubmitResponse(c echo.Context) error {
 		return InternalServerError(c, "Failed to retrieve survey", err)
 	}
 
+	if refusal := surveyNotAccepting(survey, time.Now()); refusal != nil {
+		return c.JSON(http.StatusForbidden, refusal)
+	}
+
 	// Parse request body
 	var req SubmitResponseRequest
 	if err := c.Bind(&req); err != nil {
@@ -366,8 +1009,249 @@ func (h *Handlers) GetResults(c echo.Context) error {
 	return c.JSON(http.StatusOK, results)
 }
 
+// GetTextAnswers pages through the text answers to one question, oldest
+// first; results include only the first db.ResultsTextAnswerPreview
+// GET /api/v1/surveys/:slug/results/:questionId/text?limit=50&offset=0
+func (h *Handlers) GetTextAnswers(c echo.Context) error {
+	slug := c.Param("slug")
+	questionID := c.Param("questionId")
+
+	limit := db.ResultsTextAnswerPreview // default
+	offset := 0
+
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			limit = l
+		}
+	}
+
+	if offsetStr := c.QueryParam("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	survey, err := h.queries.GetSurveyBySlug(c.Request().Context(), slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Survey not found",
+				Details: fmt.Sprintf("No survey found with slug '%s'", slug),
+			})
+		}
+		return InternalServerError(c, "Failed to retrieve survey", err)
+	}
+
+	var question *models.Question
+	for i := range survey.Definition.Questions {
+		if survey.Definition.Questions[i].ID == questionID {
+			question = &survey.Definition.Questions[i]
+		}
+	}
+	if question == nil || question.Type != models.QuestionTypeText {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Question not found",
+			Details: fmt.Sprintf("Survey '%s' has no text question '%s'", slug, questionID),
+		})
+	}
+
+	// Answers are looked up by record URI; surveys that were never published
+	// to a PDS only show the preview in their results
+	answers := []string{}
+	if survey.URI != nil {
+		answers, err = h.queries.GetTextAnswers(c.Request().Context(), *survey.URI, questionID, limit, offset)
+		if err != nil {
+			return InternalServerError(c, "Failed to retrieve answers", err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, answers)
+}
+
+// ExportResponsesCSV downloads a survey's responses as CSV, one column per
+// question. Only the survey's author may export it. Responses are streamed
+// from the database to the client, so large surveys aren't held in memory.
+// GET /api/v1/surveys/:slug/export.csv
+func (h *Handlers) ExportResponsesCSV(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+
+	slug := c.Param("slug")
+	ctx := c.Request().Context()
+
+	survey, err := h.queries.GetSurveyBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Survey not found",
+				Details: fmt.Sprintf("No survey found with slug '%s'", slug),
+			})
+		}
+		return InternalServerError(c, "Failed to retrieve survey", err)
+	}
+	if survey.URI == nil || survey.AuthorDID == nil || *survey.AuthorDID != user.DID {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Forbidden",
+			Details: "Only the survey's author can export its responses",
+		})
+	}
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{
+		"filename": survey.Slug + "-responses.csv",
+	}))
+	header.Set("Cache-Control", "no-store")
+
+	cw := export.NewCSVWriter(c.Response(), survey)
+	err = cw.WriteHeader()
+	if err == nil {
+		err = h.queries.StreamResponses(ctx, *survey.URI, h.exportHandles(c, survey, cw.Write))
+	}
+	if err == nil {
+		err = cw.Flush()
+	}
+	if err != nil {
+		// Once rows have reached the client the status can't change; the
+		// truncated download is all that can be done
+		if !c.Response().Committed {
+			header.Del(echo.HeaderContentDisposition)
+			return InternalServerError(c, "Failed to export responses", err)
+		}
+		c.Logger().Errorf("Failed to export responses for %s: %v", survey.Slug, err)
+	}
+	return nil
+}
+
+// maxExportHandleResolutions caps how many respondents' handles one export
+// resolves over the network; the rest are exported with their DID only
+const maxExportHandleResolutions = 200
+
+// exportHandles fills in the handles of the respondents write is given that
+// have none stored (only authors' and signed-in users' handles are), before
+// passing the row on. Each DID is resolved once per export, through the
+// resolver's cache, and stored for the next one.
+func (h *Handlers) exportHandles(c echo.Context, survey *models.Survey, write func(db.ResponseRow) error) func(db.ResponseRow) error {
+	if survey.Definition.Anonymous {
+		return write
+	}
+	resolved := make(map[string]string)
+	return func(row db.ResponseRow) error {
+		if row.VoterDID != nil && row.VoterHandle == nil {
+			handle, ok := resolved[*row.VoterDID]
+			if !ok && len(resolved) < maxExportHandleResolutions {
+				handle = h.resolveAndStoreHandle(c, *row.VoterDID)
+				resolved[*row.VoterDID] = handle
+			}
+			if handle != "" {
+				row.VoterHandle = &handle
+			}
+		}
+		return write(row)
+	}
+}
+
+// TranslateSurvey handles POST /api/v1/surveys/:slug/translate. It
+// translates the survey's text into another language with AI and returns
+// the translated definition for the author to review; nothing is saved.
+func (h *Handlers) TranslateSurvey(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+
+	var req TranslateSurveyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+	}
+	if !req.Consent {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "AI translation requires explicit consent for AI processing",
+		})
+	}
+	tag, err := language.Parse(strings.TrimSpace(req.Lang))
+	if err != nil {
+		return ValidationError(c, "Invalid lang", fmt.Sprintf("'%s' is not a valid BCP-47 language tag", req.Lang))
+	}
+
+	slug := c.Param("slug")
+	survey, err := h.queries.GetSurveyBySlug(c.Request().Context(), slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Survey not found",
+				Details: fmt.Sprintf("No survey found with slug '%s'", slug),
+			})
+		}
+		return InternalServerError(c, "Failed to retrieve survey", err)
+	}
+	if survey.AuthorDID == nil || *survey.AuthorDID != user.DID {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Forbidden",
+			Details: "Only the survey's author can translate it",
+		})
+	}
+
+	// Log the translation, and any rate limit it hits, as one
+	c.SetRequest(c.Request().WithContext(generator.WithKind(c.Request().Context(), generator.KindTranslate)))
+
+	job, err := h.admitGeneration(c, fmt.Sprintf("Translate survey %s into %s", survey.Slug, tag))
+	if job == nil {
+		return err
+	}
+	job.existing = &survey.Definition
+	job.translateTo = tag.String()
+
+	status, body := h.runGeneration(c, job)
+	return c.JSON(status, body)
+}
+
 // Helper Functions
 
+// formOtherText reads the free text submitted for the selected options of a
+// choice question that allow it. Returns nil if there is none.
+func formOtherText(formValues url.Values, question models.Question, selected []string) map[string]string {
+	isSelected := make(map[string]bool, len(selected))
+	for _, id := range selected {
+		isSelected[id] = true
+	}
+
+	var other map[string]string
+	for _, option := range question.Options {
+		if !option.AllowFreeText || !isSelected[option.ID] {
+			continue
+		}
+		if text := formValues.Get(templates.OtherTextField(question.ID, option.ID)); text != "" {
+			if other == nil {
+				other = make(map[string]string)
+			}
+			other[option.ID] = text
+		}
+	}
+	return other
+}
+
+// surveyPageComments is how many recent comments the survey page shows
+const surveyPageComments = 20
+
+// searchPageResults is how many matches the search page shows
+const searchPageResults = 20
+
+// browsePageSurveys is how many surveys each page of the browse list shows
+const browsePageSurveys = 20
+
+// headerNextCursor carries the cursor for the next page of a list response
+const headerNextCursor = "X-Next-Cursor"
+
+// headerTotalCount carries the number of items across every page of an
+// offset-paginated list response
+const headerTotalCount = "X-Total-Count"
+
 var slugifyRegex = regexp.MustCompile(`[^a-z0-9]+`)
 
 // generateSlug creates a URL-friendly slug from a title
@@ -417,8 +1301,33 @@ func (h *Handlers) GetSurveyHTML(c echo.Context) error {
 	// Get user and profile from context
 	user, profile := getUserAndProfile(c)
 
+	author := h.authorProfile(c, survey)
+
+	// Recent comments, only when the author has enabled them
+	var comments []*models.Comment
+	if survey.Definition.AllowComments {
+		comments, err = h.queries.ListCommentsBySurvey(c.Request().Context(), survey.ID, surveyPageComments)
+		if err != nil {
+			// Comments are secondary; still render the survey
+			c.Logger().Errorf("Failed to load comments for survey %s: %v", survey.ID, err)
+			comments = nil
+		}
+	}
+
+	// Closed and scheduled surveys show when they closed or open instead of
+	// a form whose submissions would be refused
+	status := survey.StatusAt(time.Now())
+
+	// For the page's structured data, which leaves it out if unknown
+	var responseCount *int
+	if count, err := h.queries.GetResponseCount(c.Request().Context(), survey.ID); err != nil {
+		c.Logger().Errorf("Failed to get response count for survey %s: %v", survey.ID, err)
+	} else {
+		responseCount = &count
+	}
+
 	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
-	component := templates.SurveyForm(survey, user, profile, h.posthogKey)
+	component := templates.SurveyForm(survey, status, author, user, profile, h.posthogKey, comments, responseCount)
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
 
@@ -509,8 +1418,9 @@ func (h *Handlers) CreateSurveyHTML(c echo.Context) error {
 			if err := h.ensureValidToken(c.Request().Context(), session); err != nil {
 				// Token refresh failed - log and continue with local-only survey
 				c.Logger().Errorf("Failed to refresh access token: %v", err)
-				// Optionally: delete the invalid session
-				// h.oauthStorage.DeleteSession(c.Request().Context(), session.ID)
+				if errors.Is(err, oauth.ErrSessionInvalid) {
+					_ = h.oauthStorage.DeleteSession(c.Request().Context(), session.ID)
+				}
 			} else {
 				// Token is valid - write to PDS
 				rkey := oauth.GenerateTID()
@@ -520,18 +1430,7 @@ func (h *Handlers) CreateSurveyHTML(c echo.Context) error {
 				uri = &atURI
 				authorDID = &session.DID
 
-				// Build ATProto record matching lexicon format
-				record := map[string]interface{}{
-					"$type":     "net.openmeet.survey",
-					"name":      title,
-					"questions": def.Questions,
-					"createdAt": time.Now().Format(time.RFC3339),
-				}
-
-				// Add optional fields if present
-				if def.Anonymous {
-					record["anonymous"] = def.Anonymous
-				}
+				record := surveyRecord(&models.Survey{Title: title, CreatedAt: time.Now()}, def)
 
 				// Write to PDS
 				pdsURI, pdsCID, err := oauth.CreateRecord(session, "net.openmeet.survey", rkey, record)
@@ -588,6 +1487,13 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 		return component.Render(c.Request().Context(), c.Response().Writer)
 	}
 
+	// Refused like the API, but with the page's notice, since the form may
+	// have been loaded before the survey closed
+	if status := survey.StatusAt(time.Now()); templates.SurveyUnavailable(status) {
+		component := templates.Error(templates.SurveyStatusText(c.Request().Context(), survey, status))
+		return component.Render(c.Request().Context(), c.Response().Writer)
+	}
+
 	// Parse form data into answers
 	answers := make(map[string]models.Answer)
 	formValues, err := c.FormParams()
@@ -601,12 +1507,14 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 			if value := formValues.Get(question.ID); value != "" {
 				answers[question.ID] = models.Answer{
 					SelectedOptions: []string{value},
+					OtherText:       formOtherText(formValues, question, []string{value}),
 				}
 			}
 		} else if question.Type == models.QuestionTypeMulti {
 			if values, ok := formValues[question.ID]; ok && len(values) > 0 {
 				answers[question.ID] = models.Answer{
 					SelectedOptions: values,
+					OtherText:       formOtherText(formValues, question, values),
 				}
 			}
 		} else if question.Type == models.QuestionTypeText {
@@ -615,6 +1523,17 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 					Text: value,
 				}
 			}
+		} else if question.Type == models.QuestionTypeRating {
+			if value := formValues.Get(question.ID); value != "" {
+				rating, err := strconv.Atoi(value)
+				if err != nil {
+					component := templates.Error("Invalid rating for question " + question.ID)
+					return component.Render(c.Request().Context(), c.Response().Writer)
+				}
+				answers[question.ID] = models.Answer{
+					Rating: &rating,
+				}
+			}
 		}
 	}
 
@@ -662,6 +1581,12 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 					if answer.Text != "" {
 						lexAnswer["text"] = answer.Text
 					}
+					if answer.Rating != nil {
+						lexAnswer["rating"] = *answer.Rating
+					}
+					if len(answer.OtherText) > 0 {
+						lexAnswer["otherText"] = answer.OtherText
+					}
 					lexiconAnswers = append(lexiconAnswers, lexAnswer)
 				}
 
@@ -729,12 +1654,20 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 			return component.Render(c.Request().Context(), c.Response().Writer)
 		}
 
-		if existingResponse != nil {
+		if existingResponse != nil && !survey.Definition.AllowMultipleResponses {
 			component := templates.Error("You have already submitted a response to this survey")
 			return component.Render(c.Request().Context(), c.Response().Writer)
 		}
 	}
 
+	// ATProto responses share the consumer's dedup key so the firehose copy
+	// of this record lands on the same row
+	var dedupKey *string
+	if voterDID != nil && uri != nil {
+		key := models.ResponseDedupKey(&survey.Definition, survey.ID, *voterDID, *uri)
+		dedupKey = &key
+	}
+
 	// Create response locally
 	now := time.Now()
 	response := &models.Response{
@@ -746,6 +1679,7 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 		RecordCID:    cid,
 		Answers:      answers,
 		CreatedAt:    now,
+		DedupKey:     dedupKey,
 	}
 
 	if err := h.queries.CreateResponse(c.Request().Context(), response); err != nil {
@@ -756,7 +1690,11 @@ func (h *Handlers) SubmitResponseHTML(c echo.Context) error {
 	// Record metrics (no slug label to avoid cardinality explosion)
 	telemetry.SurveyResponsesTotal.WithLabelValues("web").Inc()
 
-	// Return thank you message
+	// Return thank you message, sized for the frame when embedded
+	if formValues.Get("embed") == "1" {
+		component := templates.EmbedThankYou(slug)
+		return component.Render(c.Request().Context(), c.Response().Writer)
+	}
 	component := templates.ThankYou(slug)
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
@@ -779,15 +1717,41 @@ func (h *Handlers) GetResultsHTML(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Failed to load results")
 	}
 
+	// The author's published snapshot is shown alongside live counts; the
+	// page still renders without it
+	var published *models.PublishedResults
+	if survey.URI != nil {
+		published, err = h.queries.GetPublishedResults(c.Request().Context(), *survey.URI)
+		if err != nil {
+			c.Logger().Errorf("Failed to get published results for %s: %v", *survey.URI, err)
+		}
+	}
+
+	// AI summaries of text answers, shown when the author has made any
+	var summaries map[string]*models.TextAnswerSummary
+	if h.summaries != nil {
+		summaries, err = h.summaries.GetTextAnswerSummaries(c.Request().Context(), survey.ID)
+		if err != nil {
+			c.Logger().Errorf("Failed to get text answer summaries for %s: %v", survey.Slug, err)
+		}
+	}
+
 	// Get user and profile from context
 	user, profile := getUserAndProfile(c)
 
 	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
-	component := templates.SurveyResults(survey, results, user, profile, h.posthogKey)
+	component := templates.SurveyResults(survey, results, published, summaries, user, profile, h.posthogKey)
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
 
-// GetResultsPartialHTML renders just the results partial (for HTMX polling)
+// resultsWaitTimeout is how long a results poll with wait=1 waits for the
+// survey's responses to change before rendering them anyway
+const resultsWaitTimeout = 25 * time.Second
+
+// GetResultsPartialHTML renders just the results partial (for HTMX polling).
+// With wait=1 it first waits, up to resultsWaitTimeout, for the survey's
+// responses to change, so polls cost a render per change rather than per
+// interval.
 // GET /surveys/:slug/results-partial
 func (h *Handlers) GetResultsPartialHTML(c echo.Context) error {
 	slug := c.Param("slug")
@@ -800,12 +1764,27 @@ func (h *Handlers) GetResultsPartialHTML(c echo.Context) error {
 		return c.String(http.StatusInternalServerError, "Failed to load survey")
 	}
 
+	if c.QueryParam("wait") == "1" && h.responses != nil && survey.URI != nil {
+		changed, unsubscribe := h.responses.Subscribe(*survey.URI)
+		timer := time.NewTimer(resultsWaitTimeout)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-c.Request().Context().Done():
+		}
+		timer.Stop()
+		unsubscribe()
+		if err := c.Request().Context().Err(); err != nil {
+			return nil
+		}
+	}
+
 	results, err := h.queries.GetSurveyResults(c.Request().Context(), survey.ID)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, "Failed to load results")
 	}
 
-	component := templates.ResultsPartial(survey, results)
+	component := templates.ResultsPartial(survey, results, templates.IsSurveyAuthor(survey, oauth.GetUser(c)))
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
 
@@ -866,11 +1845,26 @@ func (h *Handlers) PublishResultsHTML(c echo.Context) error {
 			})
 		}
 
-		lexiconQuestionResults = append(lexiconQuestionResults, map[string]interface{}{
+		questionResult := map[string]interface{}{
 			"questionId":        qResult.QuestionID,
 			"optionCounts":      optionCounts,
-			"textResponseCount": len(qResult.TextAnswers),
-		})
+			"textResponseCount": qResult.TextAnswerCount,
+		}
+
+		// Lexicons have no float type, so publish the distribution and let
+		// readers derive the average
+		if len(qResult.RatingCounts) > 0 {
+			ratingCounts := make([]map[string]interface{}, 0, len(qResult.RatingCounts))
+			for value, count := range qResult.RatingCounts {
+				ratingCounts = append(ratingCounts, map[string]interface{}{
+					"value": value,
+					"count": count,
+				})
+			}
+			questionResult["ratingCounts"] = ratingCounts
+		}
+
+		lexiconQuestionResults = append(lexiconQuestionResults, questionResult)
 	}
 
 	// Build ATProto results record matching lexicon format
@@ -890,13 +1884,8 @@ func (h *Handlers) PublishResultsHTML(c echo.Context) error {
 
 	// Ensure token is valid before PDS write
 	if err := h.ensureValidToken(c.Request().Context(), session); err != nil {
-		c.Logger().Errorf("Failed to refresh access token: %v", err)
-		// Delete invalid session and clear cookie
-		if h.oauthStorage != nil {
-			_ = h.oauthStorage.DeleteSession(c.Request().Context(), session.ID)
-		}
-		c.SetCookie(&http.Cookie{Name: "session", Value: "", MaxAge: -1, Path: "/"})
-		component := templates.Error("Session expired. Please log in again.")
+		_, message := h.tokenRefreshFailed(c, err)
+		component := templates.Error(message)
 		return component.Render(c.Request().Context(), c.Response().Writer)
 	}
 
@@ -1025,6 +2014,108 @@ func (h *Handlers) LandingPage(c echo.Context) error {
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
 
+// SearchSurveysHTML displays the first page of survey search results
+// GET /search?q=coffee
+func (h *Handlers) SearchSurveysHTML(c echo.Context) error {
+	query := c.QueryParam("q")
+
+	results, err := h.queries.SearchSurveys(c.Request().Context(), query, searchPageResults, 0)
+	if err != nil {
+		c.Logger().Errorf("Failed to search surveys: %v", err)
+		return c.String(http.StatusInternalServerError, "Failed to search surveys")
+	}
+
+	user, profile := getUserAndProfile(c)
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	component := templates.SearchPage(query, results, user, profile, h.posthogKey)
+	return component.Render(c.Request().Context(), c.Response().Writer)
+}
+
+// BrowseSurveysHTML lists discoverable surveys, optionally filtered by
+// status (open, closed or scheduled) and language, newest or most answered
+// first. Load more requests from htmx get just the next page of the list.
+// GET /surveys?status=open&lang=es&sort=responses&cursor=...
+func (h *Handlers) BrowseSurveysHTML(c echo.Context) error {
+	filter := templates.BrowseFilter{Sort: c.QueryParam("sort")}
+	cursor := c.QueryParam("cursor")
+	params := db.ListSurveysParams{
+		Discoverable: true,
+		Limit:        browsePageSurveys,
+		Cursor:       cursor,
+	}
+
+	switch filter.Sort {
+	case "", templates.BrowseSortNewest:
+		params.Sort = db.SortNewest
+	case templates.BrowseSortResponses:
+		params.Sort = db.SortMostResponses
+	default:
+		return c.String(http.StatusBadRequest, "sort must be newest or responses")
+	}
+
+	if statusStr := c.QueryParam("status"); statusStr != "" {
+		status, err := models.ParseSurveyStatus(statusStr)
+		if err != nil || status == models.SurveyStatusDeleted {
+			return c.String(http.StatusBadRequest, "status must be open, closed or scheduled")
+		}
+		filter.Status = status
+		params.Status = status
+	}
+
+	// Canonicalize so "ES" or "es_mx" match what the consumer stored
+	if langStr := strings.TrimSpace(c.QueryParam("lang")); langStr != "" {
+		tag, err := language.Parse(langStr)
+		if err != nil {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("'%s' is not a valid language code", langStr))
+		}
+		filter.Lang = tag.String()
+		params.Lang = tag.String()
+	}
+
+	ctx := c.Request().Context()
+	surveys, next, err := h.queries.ListSurveys(ctx, params)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidCursor) {
+			return c.String(http.StatusBadRequest, "Invalid cursor")
+		}
+		c.Logger().Errorf("Failed to list surveys: %v", err)
+		return c.String(http.StatusInternalServerError, "Failed to load surveys")
+	}
+
+	// Only stored handles: resolving unknown ones would mean a request per
+	// author
+	var dids []string
+	for _, s := range surveys {
+		if s.AuthorDID != nil && *s.AuthorDID != "" {
+			dids = append(dids, *s.AuthorDID)
+		}
+	}
+	handles, err := h.queries.GetHandles(ctx, dids)
+	if err != nil {
+		c.Logger().Errorf("Failed to get author handles: %v", err)
+	}
+	items := make([]templates.BrowseSurvey, len(surveys))
+	for i, s := range surveys {
+		items[i] = templates.BrowseSurvey{Survey: s}
+		if s.AuthorDID != nil {
+			items[i].AuthorHandle = handles[*s.AuthorDID]
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	// The same URL is a whole page or just the list, so caches mustn't give
+	// one for the other
+	c.Response().Header().Add(echo.HeaderVary, "HX-Request")
+	if cursor != "" && c.Request().Header.Get("HX-Request") != "" {
+		return templates.BrowseResults(filter, items, next).Render(ctx, c.Response().Writer)
+	}
+
+	user, profile := getUserAndProfile(c)
+	component := templates.BrowsePage(filter, cursor, items, next, user, profile, h.posthogKey)
+	return component.Render(ctx, c.Response().Writer)
+}
+
 // PrivacyPage displays the privacy policy
 // GET /privacy
 func (h *Handlers) PrivacyPage(c echo.Context) error {
@@ -1057,9 +2148,19 @@ func (h *Handlers) MyDataHTML(c echo.Context) error {
 	// Get profile
 	_, profile := getUserAndProfile(c)
 
+	// Sessions are listed if they can be loaded; the page works without them
+	var sessions []oauth.SessionInfo
+	if h.oauthStorage != nil {
+		var err error
+		sessions, err = h.oauthStorage.GetSessionsByDID(c.Request().Context(), user.DID)
+		if err != nil {
+			c.Logger().Errorf("Failed to list sessions for %s: %v", user.DID, err)
+		}
+	}
+
 	// Render overview page
 	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
-	component := templates.MyDataPage(user, profile, h.posthogKey)
+	component := templates.MyDataPage(user, profile, sessions, currentSessionID(c), h.posthogKey)
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
 
@@ -1103,10 +2204,30 @@ func (h *Handlers) MyDataCollectionHTML(c echo.Context) error {
 
 	// Render collection page
 	c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
-	component := templates.MyDataCollectionPage(user, profile, collection, records.Records, records.Cursor, h.posthogKey)
+	component := templates.MyDataCollectionPage(user, profile, collection, records.Records, h.recordResponseCounts(c, collection, records.Records), records.Cursor, h.posthogKey)
 	return component.Render(c.Request().Context(), c.Response().Writer)
 }
 
+// recordResponseCounts returns the response count of each survey record
+// listed on a My Data page, keyed by record URI, or nil for other
+// collections or if the counts can't be read. Surveys we haven't indexed
+// are missing.
+func (h *Handlers) recordResponseCounts(c echo.Context, collection string, records []oauth.PDSRecord) map[string]int {
+	if collection != "net.openmeet.survey" || len(records) == 0 {
+		return nil
+	}
+	uris := make([]string, len(records))
+	for i, record := range records {
+		uris[i] = record.URI
+	}
+	counts, err := h.queries.GetSurveyCounts(c.Request().Context(), uris)
+	if err != nil {
+		c.Logger().Errorf("Failed to get survey response counts: %v", err)
+		return nil
+	}
+	return counts
+}
+
 // MyDataRecordHTML displays a single record for editing
 // GET /my-data/:collection/:rkey
 func (h *Handlers) MyDataRecordHTML(c echo.Context) error {
@@ -1200,13 +2321,7 @@ func (h *Handlers) UpdateRecordHTML(c echo.Context) error {
 
 	// Ensure token is valid before PDS operation
 	if err := h.ensureValidToken(c.Request().Context(), session); err != nil {
-		c.Logger().Errorf("Failed to refresh access token: %v", err)
-		// Delete invalid session and clear cookie
-		if h.oauthStorage != nil {
-			_ = h.oauthStorage.DeleteSession(c.Request().Context(), session.ID)
-		}
-		c.SetCookie(&http.Cookie{Name: "session", Value: "", MaxAge: -1, Path: "/"})
-		return c.String(http.StatusUnauthorized, "Session expired. Please log in again.")
+		return c.String(h.tokenRefreshFailed(c, err))
 	}
 
 	// Update record on PDS
@@ -1255,6 +2370,9 @@ func (h *Handlers) ATProtoURL(c echo.Context) error {
 		}
 		return InternalServerError(c, "Failed to retrieve survey", err)
 	}
+	if survey.DeletedAt != nil {
+		return c.String(http.StatusNotFound, "Survey not found")
+	}
 
 	// Redirect to survey by slug
 	return c.Redirect(http.StatusSeeOther, "/surveys/"+survey.Slug)
@@ -1293,13 +2411,7 @@ func (h *Handlers) DeleteRecordsHTML(c echo.Context) error {
 
 	// Ensure token is valid before PDS operations
 	if err := h.ensureValidToken(c.Request().Context(), session); err != nil {
-		c.Logger().Errorf("Failed to refresh access token: %v", err)
-		// Delete invalid session and clear cookie
-		if h.oauthStorage != nil {
-			_ = h.oauthStorage.DeleteSession(c.Request().Context(), session.ID)
-		}
-		c.SetCookie(&http.Cookie{Name: "session", Value: "", MaxAge: -1, Path: "/"})
-		return c.String(http.StatusUnauthorized, "Session expired. Please log in again.")
+		return c.String(h.tokenRefreshFailed(c, err))
 	}
 
 	// Delete each record
@@ -1318,10 +2430,32 @@ func (h *Handlers) DeleteRecordsHTML(c echo.Context) error {
 // GenerateSurvey handles AI survey generation requests
 // POST /api/v1/surveys/generate
 func (h *Handlers) GenerateSurvey(c echo.Context) error {
+	job, err := h.prepareGeneration(c)
+	if job == nil {
+		return err
+	}
+
+	status, body := h.runGeneration(c, job)
+	return c.JSON(status, body)
+}
+
+// generationJob is a generation request that passed consent, rate limit and
+// input checks
+type generationJob struct {
+	description string
+	existing    *models.SurveyDefinition // The survey to modify or translate; nil for a new survey
+	translateTo string                   // BCP-47 tag to translate existing into, instead of modifying it
+	userID      string                   // DID for authenticated, IP for anonymous
+	userType    string
+}
+
+// prepareGeneration parses and checks a generation request. When a check
+// fails it writes the error response and returns a nil job.
+func (h *Handlers) prepareGeneration(c echo.Context) (*generationJob, error) {
 	// Parse request
 	var req GenerateSurveyRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
+		return nil, c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Details: err.Error(),
 		})
@@ -1329,28 +2463,107 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 
 	// Check consent
 	if !req.Consent {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
+		return nil, c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error: "AI generation requires explicit consent for OpenAI processing",
 		})
 	}
 
 	// Validate description
 	if strings.TrimSpace(req.Description) == "" {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
+		return nil, c.JSON(http.StatusBadRequest, ErrorResponse{
 			Error: "Description cannot be empty",
 		})
 	}
 
+	// Parse the survey to modify, if any. It's the user's draft, so it only
+	// has to parse, not validate.
+	existing := req.Existing
+	if existing == nil && strings.TrimSpace(req.ExistingJSON) != "" {
+		def, err := models.ParseSurveyDefinition([]byte(req.ExistingJSON))
+		if err != nil {
+			return nil, c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid existing survey",
+				Details: err.Error(),
+			})
+		}
+		existing = def
+	}
+
+	job, err := h.admitGeneration(c, req.Description)
+	if job == nil {
+		return nil, err
+	}
+
+	// Validate user input first (before building combined prompt)
+	if err := h.generator.ValidateInput(req.Description); err != nil {
+		telemetry.AIGenerationsTotal.WithLabelValues("error").Inc()
+
+		if h.generationLog != nil {
+			_ = h.generationLog.LogError(
+				c.Request().Context(),
+				job.userID,
+				job.userType,
+				req.Description,
+				"",
+				"", // No LLM call yet, no raw response
+				"validation_failed",
+				err.Error(),
+				0, 0, 0.0, 0,
+			)
+		}
+
+		return nil, c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// Screen the description for prompt injection and disallowed content
+	if err := h.generator.ScreenInput(c.Request().Context(), req.Description); err != nil {
+		telemetry.AIGenerationsTotal.WithLabelValues("error").Inc()
+
+		if h.generationLog != nil {
+			_ = h.generationLog.LogError(
+				c.Request().Context(),
+				job.userID,
+				job.userType,
+				req.Description,
+				"",
+				"", // No LLM call yet, no raw response
+				"validation_failed",
+				err.Error(), // The flag reason, kept from the user
+				0, 0, 0.0, 0,
+			)
+		}
+
+		message := "This description can't be used for AI generation."
+		var flag *generator.ScreenFlag
+		if errors.As(err, &flag) {
+			message = flag.UserMessage()
+		}
+		return nil, c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: message,
+		})
+	}
+
+	job.existing = existing
+	return job, nil
+}
+
+// admitGeneration checks that AI generation is available and that the
+// requester is within their rate limit and budgets, and returns a job for
+// description. When a check fails it writes the error response and returns
+// a nil job.
+func (h *Handlers) admitGeneration(c echo.Context, description string) (*generationJob, error) {
 	// Check if generator is configured
 	if h.generator == nil {
-		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+		return nil, c.JSON(http.StatusServiceUnavailable, ErrorResponse{
 			Error: "AI survey generation is not available",
 		})
 	}
 
 	// Check if rate limiter is configured
 	if h.generatorRL == nil {
-		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+		return nil, c.JSON(http.StatusServiceUnavailable, ErrorResponse{
 			Error: "AI survey generation is not available",
 		})
 	}
@@ -1358,22 +2571,33 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 	// Get user context (authenticated vs anonymous)
 	user := oauth.GetUser(c)
 	var allowed bool
+	var retryAfter time.Duration
+	var err error
 	var userID string
 	var userType string
 
+	ctx := c.Request().Context()
 	if user != nil {
 		// Authenticated user - check DID-based rate limit
-		allowed = h.generatorRL.AllowAuthenticated(user.DID)
+		allowed, retryAfter, err = h.generatorRL.AllowAuthenticated(ctx, user.DID)
 		userID = user.DID
 		userType = "authenticated"
 	} else {
 		// Anonymous user - check IP-based rate limit
 		ip := getClientIP(c)
-		allowed = h.generatorRL.AllowAnonymous(ip)
+		allowed, retryAfter, err = h.generatorRL.AllowAnonymous(ctx, ip)
 		userID = ip
 		userType = "anonymous"
 	}
 
+	// Like the budget check, fail closed: the limits protect the budget
+	if err != nil {
+		c.Logger().Errorf("Failed to check AI rate limit for %s: %v", userID, err)
+		return nil, c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI survey generation is temporarily unavailable",
+		})
+	}
+
 	if !allowed {
 		// Record rate limit hit metric
 		if user != nil {
@@ -1390,7 +2614,7 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 				c.Request().Context(),
 				userID,
 				userType,
-				req.Description,
+				description,
 				"", // System prompt not available yet
 				"", // No LLM call yet, no raw response
 				"rate_limited",
@@ -1399,59 +2623,113 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 			)
 		}
 
-		return c.JSON(http.StatusTooManyRequests, ErrorResponse{
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(max(math.Ceil(retryAfter.Seconds()), 1))))
+		return nil, c.JSON(http.StatusTooManyRequests, ErrorResponse{
 			Error: "Rate limit exceeded for AI generation. Please try again later.",
 		})
 	}
 
-	// Validate user input first (before building combined prompt)
-	if err := h.generator.ValidateInput(req.Description); err != nil {
-		telemetry.AIGenerationsTotal.WithLabelValues("error").Inc()
-
-		if h.generationLog != nil {
-			_ = h.generationLog.LogError(
-				c.Request().Context(),
-				userID,
-				userType,
-				req.Description,
-				"",
-				"", // No LLM call yet, no raw response
-				"validation_failed",
-				err.Error(),
-				0, 0, 0.0, 0,
-			)
+	// Check daily spending budgets before anything reaches a provider
+	if h.budget != nil {
+		if err := h.budget.Check(c.Request().Context(), userID, userType); err != nil {
+			return nil, h.budgetExceeded(c, userID, userType, description, err)
 		}
+	}
 
-		return c.JSON(http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
+	return &generationJob{
+		description: description,
+		userID:      userID,
+		userType:    userType,
+	}, nil
+}
+
+// budgetExceeded responds to a request a daily spending budget rejected,
+// logging it as rate limited. Other budget check errors fail closed.
+func (h *Handlers) budgetExceeded(c echo.Context, userID, userType, description string, err error) error {
+	var exceeded *generator.BudgetExceededError
+	if !errors.As(err, &exceeded) {
+		c.Logger().Errorf("Failed to check AI budget for %s: %v", userID, err)
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI survey generation is temporarily unavailable",
 		})
 	}
 
-	// Build prompt
-	prompt := req.Description
-	isRefinement := req.ExistingJSON != ""
-	if isRefinement {
-		prompt = fmt.Sprintf("Existing survey JSON: %s\n\nModification request: %s", req.ExistingJSON, req.Description)
+	telemetry.AIGenerationsTotal.WithLabelValues("rate_limited").Inc()
+	if h.generationLog != nil {
+		_ = h.generationLog.LogError(
+			c.Request().Context(),
+			userID,
+			userType,
+			description,
+			"",
+			"", // No LLM call, no raw response
+			"rate_limited",
+			exceeded.Error(),
+			0, 0, 0.0, 0,
+		)
+	}
+
+	message := "You've reached your daily AI generation budget."
+	switch exceeded.Scope {
+	case generator.BudgetScopeGlobal:
+		message = "AI survey generation has reached its daily budget."
+	case generator.BudgetScopeAnonymous:
+		message = "The daily AI generation budget for anonymous use has been reached. Log in for a larger budget."
 	}
 
+	retryAfter := math.Ceil(time.Until(exceeded.ResetsAt).Seconds())
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(max(retryAfter, 1))))
+	return c.JSON(http.StatusTooManyRequests, BudgetExceededResponse{
+		ErrorResponse: ErrorResponse{Error: message},
+		Scope:         exceeded.Scope,
+		ResetsAt:      exceeded.ResetsAt,
+	})
+}
+
+// statusClientClosedRequest is the status logged for a request the client
+// abandoned; nobody is left to receive it
+const statusClientClosedRequest = 499
+
+// runGeneration generates a survey for job, recording metrics and logs, and
+// returns the response status and body
+func (h *Handlers) runGeneration(c echo.Context, job *generationJob) (int, interface{}) {
 	// Record duration metric
 	start := time.Now()
 
-	// Call generator - use GenerateRaw for refinement (already validated user input)
+	// Generate within the deadline. A client that disconnects cancels the
+	// request context, and with it the provider call.
+	ctx := c.Request().Context()
+	if h.generationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.generationTimeout)
+		defer cancel()
+	}
+
+	// Call generator - Modify when there's a survey to modify
 	var result *generator.GenerateResult
 	var err error
-	if isRefinement {
-		result, err = h.generator.GenerateRaw(c.Request().Context(), prompt)
+	if job.translateTo != "" {
+		result, err = h.generator.Translate(ctx, job.existing, job.translateTo)
+	} else if job.existing != nil {
+		result, err = h.generator.Modify(ctx, job.existing, job.description)
 	} else {
-		result, err = h.generator.Generate(c.Request().Context(), prompt)
+		result, err = h.generator.Generate(ctx, job.description)
 	}
 
 	// Record duration
 	duration := time.Since(start).Seconds()
 	durationMS := int(duration * 1000)
 	telemetry.AIGenerationDuration.Observe(duration)
+	if result != nil && result.OutputValidation != "" {
+		telemetry.AIOutputValidationTotal.WithLabelValues(result.OutputValidation).Inc()
+	}
 
 	if err != nil {
+		// Failures are logged with the prompt version the generation was sent
+		if result != nil && result.PromptVersion != "" {
+			c.SetRequest(c.Request().WithContext(generator.WithPromptVersion(c.Request().Context(), result.PromptVersion)))
+		}
+
 		// Determine error status and message for logging
 		var status string
 		var errorMessage string
@@ -1467,8 +2745,49 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 			costUSD = result.EstimatedCost
 		}
 
+		// A generation stopped before it finished is logged with the usage
+		// known when it stopped, after the request context is gone
+		if stopped := ctx.Err(); stopped != nil {
+			c.SetRequest(c.Request().WithContext(context.WithoutCancel(c.Request().Context())))
+			status = "cancelled"
+			if errors.Is(stopped, context.DeadlineExceeded) {
+				status = "timeout"
+			}
+			telemetry.AIGenerationsTotal.WithLabelValues(status).Inc()
+			telemetry.AITokensTotal.WithLabelValues("input").Add(float64(inputTokens))
+			telemetry.AITokensTotal.WithLabelValues("output").Add(float64(outputTokens))
+			telemetry.AIDailyCostUSD.Add(costUSD)
+
+			if h.generationLog != nil {
+				h.logFailedAttempts(c, job.userID, job.userType, job.description, result)
+				_ = h.generationLog.LogError(
+					c.Request().Context(),
+					job.userID,
+					job.userType,
+					job.description,
+					"",
+					rawResponse,
+					status,
+					err.Error(),
+					inputTokens, outputTokens, costUSD,
+					durationMS,
+				)
+			}
+
+			if status == "timeout" {
+				return http.StatusGatewayTimeout, ErrorResponse{
+					Error:   "AI generation timed out",
+					Details: fmt.Sprintf("The survey took longer than %s to generate. Try a shorter description or a smaller survey.", h.generationTimeout),
+				}
+			}
+			return statusClientClosedRequest, ErrorResponse{
+				Error: "AI generation cancelled",
+			}
+		}
+
 		// Check error type for specific responses
-		if errors.Is(err, generator.ErrInputTooLong) || errors.Is(err, generator.ErrEmptyInput) || errors.Is(err, generator.ErrBlockedPattern) {
+		if errors.Is(err, generator.ErrInputTooLong) || errors.Is(err, generator.ErrEmptyInput) || errors.Is(err, generator.ErrBlockedPattern) ||
+			errors.Is(err, generator.ErrTooManyInputTokens) {
 			status = "validation_failed"
 			errorMessage = err.Error()
 			telemetry.AIGenerationsTotal.WithLabelValues("error").Inc()
@@ -1477,9 +2796,9 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 			if h.generationLog != nil {
 				_ = h.generationLog.LogError(
 					c.Request().Context(),
-					userID,
-					userType,
-					req.Description,
+					job.userID,
+					job.userType,
+					job.description,
 					"", // System prompt not available on validation failure
 					rawResponse,
 					status,
@@ -1491,22 +2810,28 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 
 			// Return specific error response
 			if errors.Is(err, generator.ErrInputTooLong) {
-				return c.JSON(http.StatusBadRequest, ErrorResponse{
+				return http.StatusBadRequest, ErrorResponse{
 					Error:   "Input too long",
 					Details: err.Error(),
-				})
+				}
 			}
 			if errors.Is(err, generator.ErrEmptyInput) {
-				return c.JSON(http.StatusBadRequest, ErrorResponse{
+				return http.StatusBadRequest, ErrorResponse{
 					Error:   "Input cannot be empty",
 					Details: err.Error(),
-				})
+				}
 			}
 			if errors.Is(err, generator.ErrBlockedPattern) {
-				return c.JSON(http.StatusBadRequest, ErrorResponse{
+				return http.StatusBadRequest, ErrorResponse{
 					Error:   "Input contains blocked pattern",
 					Details: "Your input was flagged for potentially unsafe content",
-				})
+				}
+			}
+			if errors.Is(err, generator.ErrTooManyInputTokens) {
+				return http.StatusBadRequest, ErrorResponse{
+					Error:   "Input too long",
+					Details: "This request is too large to generate. Try a shorter description or a smaller survey.",
+				}
 			}
 		}
 
@@ -1516,12 +2841,12 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 			telemetry.AIGenerationsTotal.WithLabelValues("budget_exceeded").Inc()
 
 			// Log cost limit error
-			if h.generationLog != nil {
+			if h.generationLog != nil && !h.logFailedAttempts(c, job.userID, job.userType, job.description, result) {
 				_ = h.generationLog.LogError(
 					c.Request().Context(),
-					userID,
-					userType,
-					req.Description,
+					job.userID,
+					job.userType,
+					job.description,
 					"", // System prompt not available
 					rawResponse,
 					status,
@@ -1531,9 +2856,9 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 				)
 			}
 
-			return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			return http.StatusServiceUnavailable, ErrorResponse{
 				Error: "AI generation budget exceeded. Please try again later.",
-			})
+			}
 		}
 
 		// Generic error (includes "invalid LLM output" errors)
@@ -1541,13 +2866,15 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 		errorMessage = err.Error()
 		telemetry.AIGenerationsTotal.WithLabelValues("error").Inc()
 
-		// Log generic error - now includes raw response from partial result
-		if h.generationLog != nil {
+		// Log generic error - now includes raw response from partial result.
+		// After a fallback every attempt, including this failure, is logged
+		// against its own provider.
+		if h.generationLog != nil && !h.logFailedAttempts(c, job.userID, job.userType, job.description, result) {
 			_ = h.generationLog.LogError(
 				c.Request().Context(),
-				userID,
-				userType,
-				req.Description,
+				job.userID,
+				job.userType,
+				job.description,
 				"", // System prompt could be extracted from result if needed
 				rawResponse,
 				status,
@@ -1558,27 +2885,38 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 		}
 
 		c.Logger().Errorf("AI generation failed: %v", err)
-		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+		if errors.Is(err, generator.ErrStructureChanged) {
+			return http.StatusInternalServerError, ErrorResponse{
+				Error:   "The translation added, removed or changed questions or options, so it wasn't used. Please try again.",
+				Details: err.Error(),
+			}
+		}
+		return http.StatusInternalServerError, ErrorResponse{
 			Error:   "AI generation failed",
 			Details: err.Error(),
-		})
+		}
 	}
 
 	// Record success metrics
 	telemetry.AIGenerationsTotal.WithLabelValues("success").Inc()
 	telemetry.AITokensTotal.WithLabelValues("input").Add(float64(result.InputTokens))
 	telemetry.AITokensTotal.WithLabelValues("output").Add(float64(result.OutputTokens))
+	if result.EstimatedInputTokens > 0 && result.InputTokens > 0 {
+		telemetry.AIInputTokenEstimateRatio.WithLabelValues(result.Provider).
+			Observe(float64(result.InputTokens) / float64(result.EstimatedInputTokens))
+	}
 
 	// Update daily cost (additive - gauge tracks cumulative cost for the day)
 	telemetry.AIDailyCostUSD.Add(result.EstimatedCost)
 
-	// Log successful generation
+	// Log successful generation, after the providers it fell back from
 	if h.generationLog != nil {
+		h.logFailedAttempts(c, job.userID, job.userType, job.description, result)
 		_ = h.generationLog.LogSuccess(
 			c.Request().Context(),
-			userID,
-			userType,
-			req.Description,
+			job.userID,
+			job.userType,
+			job.description,
 			result.SystemPrompt,
 			result.RawResponse,
 			result,
@@ -1587,10 +2925,29 @@ func (h *Handlers) GenerateSurvey(c echo.Context) error {
 	}
 
 	// Return success response
-	return c.JSON(http.StatusOK, GenerateSurveyResponse{
+	return http.StatusOK, GenerateSurveyResponse{
 		Definition:   result.Definition,
 		TokensUsed:   result.InputTokens + result.OutputTokens,
 		Cost:         result.EstimatedCost,
 		NeedsCaptcha: false, // MVP: no captcha implementation yet
-	})
+		Diff:         result.Diff,
+		Lang:         job.translateTo,
+	}
+}
+
+// logFailedAttempts logs the provider calls a generation fell back from, and
+// reports whether there were any
+func (h *Handlers) logFailedAttempts(c echo.Context, userID, userType, inputPrompt string, result *generator.GenerateResult) bool {
+	if result == nil || len(result.FailedAttempts) == 0 {
+		return false
+	}
+	_ = h.generationLog.LogFailedAttempts(
+		c.Request().Context(),
+		userID,
+		userType,
+		inputPrompt,
+		result.SystemPrompt,
+		result.FailedAttempts,
+	)
+	return true
 }
@@ -5,15 +5,23 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
 	"github.com/openmeet-team/survey/internal/models"
 	"github.com/openmeet-team/survey/internal/oauth"
 	"github.com/stretchr/testify/assert"
@@ -27,6 +35,9 @@ type MockQueries struct {
 	slugs           map[string]bool
 	responses       map[uuid.UUID]*models.Response
 	responsesBySurvey map[uuid.UUID]map[string]*models.Response // surveyID -> voterSession -> response
+	handles           map[string]string                         // DID -> handle
+	published         map[string]*models.PublishedResults       // survey URI -> published results
+	comments          map[uuid.UUID][]*models.Comment           // surveyID -> comments, newest first
 }
 
 func NewMockQueries() *MockQueries {
@@ -36,6 +47,9 @@ func NewMockQueries() *MockQueries {
 		slugs:             make(map[string]bool),
 		responses:         make(map[uuid.UUID]*models.Response),
 		responsesBySurvey: make(map[uuid.UUID]map[string]*models.Response),
+		handles:           make(map[string]string),
+		published:         make(map[string]*models.PublishedResults),
+		comments:          make(map[uuid.UUID][]*models.Comment),
 	}
 }
 
@@ -56,19 +70,223 @@ func (m *MockQueries) GetSurveyBySlug(ctx context.Context, slug string) (*models
 	return nil, sql.ErrNoRows
 }
 
+// GetSurveyByURI returns a copy, as the database would, so callers editing it
+// don't change the stored survey
 func (m *MockQueries) GetSurveyByURI(ctx context.Context, uri string) (*models.Survey, error) {
 	if s, ok := m.surveysByURI[uri]; ok {
-		return s, nil
+		stored := *s
+		return &stored, nil
 	}
 	return nil, sql.ErrNoRows
 }
 
-func (m *MockQueries) ListSurveys(ctx context.Context, limit, offset int) ([]*models.Survey, error) {
+func (m *MockQueries) UpdateSurveyIfVersion(ctx context.Context, s *models.Survey, version int) error {
+	for _, existing := range m.surveys {
+		if existing.ID != s.ID {
+			continue
+		}
+		if existing.Version != version {
+			return db.ErrVersionConflict
+		}
+		s.Version = version + 1
+		m.surveys[s.Slug] = s
+		if s.URI != nil {
+			m.surveysByURI[*s.URI] = s
+		}
+		return nil
+	}
+	return fmt.Errorf("survey not found: %w", sql.ErrNoRows)
+}
+
+// ListSurveys filters on the Status the test set on each survey, since
+// statuses are computed by the database. Response counts are those of the
+// mock's responses.
+func (m *MockQueries) ListSurveys(ctx context.Context, params db.ListSurveysParams) ([]*models.Survey, string, error) {
+	after, err := db.DecodeCursor(params.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	byResponses := params.Sort == db.SortMostResponses
+	if after != nil && (after.ResponseCount != nil) != byResponses {
+		return nil, "", db.ErrInvalidCursor
+	}
+	lang := params.Lang
+	var surveys []*models.Survey
+	for _, s := range m.surveys {
+		if lang != "" && (s.Lang == nil || (*s.Lang != lang && !strings.HasPrefix(*s.Lang, lang+"-"))) {
+			continue
+		}
+		if params.AuthorDID != "" && (s.AuthorDID == nil || *s.AuthorDID != params.AuthorDID) {
+			continue
+		}
+		if params.Status != "" && s.Status != params.Status {
+			continue
+		}
+		if params.Discoverable && !s.Definition.Discoverable {
+			continue
+		}
+		listed := *s
+		listed.ResponseCount = len(m.responsesBySurvey[s.ID])
+		surveys = append(surveys, &listed)
+	}
+	// before reports whether a comes before b in the list
+	before := func(a, b *models.Survey) bool {
+		if byResponses && a.ResponseCount != b.ResponseCount {
+			return a.ResponseCount > b.ResponseCount
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID.String() > b.ID.String()
+	}
+	sort.Slice(surveys, func(i, j int) bool { return before(surveys[i], surveys[j]) })
+	var cursor *models.Survey
+	if after != nil {
+		cursor = &models.Survey{ID: after.ID, CreatedAt: after.CreatedAt}
+		if after.ResponseCount != nil {
+			cursor.ResponseCount = *after.ResponseCount
+		}
+	}
+	var page []*models.Survey
+	for _, s := range surveys {
+		if cursor != nil && !before(cursor, s) {
+			continue
+		}
+		if len(page) == params.Limit {
+			last := page[len(page)-1]
+			if byResponses {
+				return page, db.EncodeResponsesCursor(last.ResponseCount, last.CreatedAt, last.ID), nil
+			}
+			return page, db.EncodeCursor(last.CreatedAt, last.ID), nil
+		}
+		page = append(page, s)
+	}
+	return page, "", nil
+}
+
+func (m *MockQueries) SearchSurveys(ctx context.Context, query string, limit, offset int) ([]*models.SurveySearchResult, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil, nil
+	}
+	var results []*models.SurveySearchResult
+	for _, s := range m.surveys {
+		if !s.Definition.Discoverable {
+			continue
+		}
+		text := strings.ToLower(s.Title)
+		if s.Description != nil {
+			text += " " + strings.ToLower(*s.Description)
+		}
+		if !strings.Contains(text, query) {
+			continue
+		}
+		results = append(results, &models.SurveySearchResult{Survey: s, ResponseCount: len(m.responsesBySurvey[s.ID])})
+	}
+	return results, nil
+}
+
+// sitemapSurveys are the discoverable, non-deleted surveys, oldest first
+func (m *MockQueries) sitemapSurveys() []*models.Survey {
 	var surveys []*models.Survey
 	for _, s := range m.surveys {
-		surveys = append(surveys, s)
+		if s.Definition.Discoverable && s.DeletedAt == nil {
+			surveys = append(surveys, s)
+		}
+	}
+	sort.Slice(surveys, func(i, j int) bool { return surveys[i].CreatedAt.Before(surveys[j].CreatedAt) })
+	return surveys
+}
+
+func (m *MockQueries) CountSitemapSurveys(ctx context.Context) (int, error) {
+	return len(m.sitemapSurveys()), nil
+}
+
+func (m *MockQueries) StreamSitemapSurveys(ctx context.Context, offset, limit int, fn func(db.SitemapSurvey) error) error {
+	surveys := m.sitemapSurveys()
+	for i := offset; i < len(surveys) && i < offset+limit; i++ {
+		if err := fn(db.SitemapSurvey{Slug: surveys[i].Slug, UpdatedAt: surveys[i].UpdatedAt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockQueries) GetTextAnswers(ctx context.Context, surveyURI, questionID string, limit, offset int) ([]string, error) {
+	answers := []string{}
+	err := m.StreamResponses(ctx, surveyURI, func(row db.ResponseRow) error {
+		if text := row.Answers[questionID].Text; text != "" {
+			answers = append(answers, text)
+		}
+		return nil
+	})
+	if offset >= len(answers) {
+		return []string{}, err
+	}
+	answers = answers[offset:]
+	if len(answers) > limit {
+		answers = answers[:limit]
+	}
+	return answers, err
+}
+
+func (m *MockQueries) CountTextAnswers(ctx context.Context, surveyURI, questionID string) (int, error) {
+	answers, err := m.GetTextAnswers(ctx, surveyURI, questionID, math.MaxInt, 0)
+	return len(answers), err
+}
+
+func (m *MockQueries) StreamResponses(ctx context.Context, surveyURI string, fn func(db.ResponseRow) error) error {
+	survey, ok := m.surveysByURI[surveyURI]
+	if !ok {
+		return nil
+	}
+	var responses []*models.Response
+	for _, r := range m.responsesBySurvey[survey.ID] {
+		responses = append(responses, r)
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].CreatedAt.Before(responses[j].CreatedAt) })
+	for _, r := range responses {
+		row := db.ResponseRow{ID: r.ID, VoterDID: r.VoterDID, Answers: r.Answers, CreatedAt: r.CreatedAt}
+		if r.VoterDID != nil {
+			if handle, ok := m.handles[*r.VoterDID]; ok {
+				row.VoterHandle = &handle
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockQueries) GetSurveysByAuthor(ctx context.Context, did string, limit, offset int, filter db.AuthorSurveyFilter) ([]*models.AuthorSurvey, error) {
+	var results []*models.AuthorSurvey
+	for _, s := range m.surveys {
+		if s.AuthorDID == nil || *s.AuthorDID != did {
+			continue
+		}
+		if (!filter.IncludeUnlisted && !s.Definition.Discoverable) || (!filter.IncludeDeleted && s.DeletedAt != nil) {
+			continue
+		}
+		if filter.Status != "" && s.Status != filter.Status {
+			continue
+		}
+		results = append(results, &models.AuthorSurvey{Survey: s, ResponseCount: len(m.responsesBySurvey[s.ID])})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Survey.CreatedAt.After(results[j].Survey.CreatedAt) })
+	if offset >= len(results) {
+		return nil, nil
+	}
+	results = results[offset:]
+	if len(results) > limit {
+		results = results[:limit]
 	}
-	return surveys, nil
+	return results, nil
+}
+
+func (m *MockQueries) CountSurveysByAuthor(ctx context.Context, did string, filter db.AuthorSurveyFilter) (int, error) {
+	results, _ := m.GetSurveysByAuthor(ctx, did, len(m.surveys), 0, filter)
+	return len(results), nil
 }
 
 func (m *MockQueries) SlugExists(ctx context.Context, slug string) (bool, error) {
@@ -97,6 +315,10 @@ func (m *MockQueries) GetResponseBySurveyAndVoter(ctx context.Context, surveyID
 	return nil, nil // No existing response
 }
 
+func (m *MockQueries) GetResponseCount(ctx context.Context, surveyID uuid.UUID) (int, error) {
+	return len(m.responsesBySurvey[surveyID]), nil
+}
+
 func (m *MockQueries) GetSurveyResults(ctx context.Context, surveyID uuid.UUID) (*models.SurveyResults, error) {
 	// Simple mock implementation
 	return &models.SurveyResults{
@@ -118,6 +340,18 @@ func (m *MockQueries) UpdateSurveyResults(ctx context.Context, surveyID uuid.UUI
 	return fmt.Errorf("survey not found")
 }
 
+func (m *MockQueries) GetPublishedResults(ctx context.Context, surveyURI string) (*models.PublishedResults, error) {
+	return m.published[surveyURI], nil
+}
+
+func (m *MockQueries) ListCommentsBySurvey(ctx context.Context, surveyID uuid.UUID, limit int) ([]*models.Comment, error) {
+	comments := m.comments[surveyID]
+	if len(comments) > limit {
+		comments = comments[:limit]
+	}
+	return comments, nil
+}
+
 func (m *MockQueries) GetStats(ctx context.Context) (*models.Stats, error) {
 	// Count surveys
 	surveyCount := len(m.surveys)
@@ -153,10 +387,43 @@ func (m *MockQueries) GetStats(ctx context.Context) (*models.Stats, error) {
 
 // Test Helpers
 
+func (m *MockQueries) GetHandle(ctx context.Context, did string) (string, error) {
+	return m.handles[did], nil
+}
+
+func (m *MockQueries) GetSurveyCounts(ctx context.Context, uris []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, uri := range uris {
+		if s, ok := m.surveysByURI[uri]; ok {
+			counts[uri] = len(m.responsesBySurvey[s.ID])
+		}
+	}
+	return counts, nil
+}
+
+func (m *MockQueries) GetHandles(ctx context.Context, dids []string) (map[string]string, error) {
+	handles := make(map[string]string)
+	for _, did := range dids {
+		if handle, ok := m.handles[did]; ok {
+			handles[did] = handle
+		}
+	}
+	return handles, nil
+}
+
+func (m *MockQueries) UpsertHandle(ctx context.Context, did, handle string) error {
+	m.handles[did] = handle
+	return nil
+}
+
 func setupTest() (*echo.Echo, *MockQueries, *Handlers) {
 	e := echo.New()
 	mq := NewMockQueries()
 	h := NewHandlers(mq)
+	// Never hit the public Bluesky API from tests
+	h.resolveHandle = func(_ context.Context, did string) (string, error) {
+		return "", fmt.Errorf("handle resolution disabled in tests")
+	}
 	return e, mq, h
 }
 
@@ -203,6 +470,35 @@ func TestCreateSurvey_WithJSONDefinition(t *testing.T) {
 	assert.Equal(t, "What is your favorite color?", resp.Definition.Questions[0].Text)
 }
 
+func TestCreateSurvey_Schedule(t *testing.T) {
+	e, mq, h := setupTest()
+
+	create := func(t *testing.T, req CreateSurveyRequest) *httptest.ResponseRecorder {
+		req.Definition = `{"questions":[{"id":"q1","text":"When?","type":"text"}]}`
+		body, _ := json.Marshal(req)
+		httpReq := httptest.NewRequest(http.MethodPost, "/api/v1/surveys", bytes.NewReader(body))
+		httpReq.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		require.NoError(t, h.CreateSurvey(e.NewContext(httpReq, rec)))
+		return rec
+	}
+	opens := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	closes := opens.Add(7 * 24 * time.Hour)
+
+	rec := create(t, CreateSurveyRequest{Slug: "scheduled", StartsAt: &opens, EndsAt: &closes})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var resp SurveyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.StartsAt.Equal(opens))
+	assert.True(t, resp.EndsAt.Equal(closes))
+	assert.True(t, mq.surveys["scheduled"].EndsAt.Equal(closes))
+
+	rec = create(t, CreateSurveyRequest{Slug: "backwards", StartsAt: &closes, EndsAt: &opens})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "endsAt must be after startsAt")
+	assert.NotContains(t, mq.surveys, "backwards")
+}
+
 func TestCreateSurvey_WithYAMLDefinition(t *testing.T) {
 	e, _, h := setupTest()
 
@@ -574,6 +870,109 @@ func TestSubmitResponse_InvalidAnswers(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestSubmitResponse_NotAccepting(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name     string
+		startsAt *time.Time
+		endsAt   *time.Time
+		reason   string
+	}{
+		{name: "closed", endsAt: &past, reason: ReasonSurveyClosed},
+		{name: "not yet open", startsAt: &future, reason: ReasonSurveyNotYetOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, mq, h := setupTest()
+			mq.CreateSurvey(context.Background(), &models.Survey{
+				ID:       uuid.New(),
+				Slug:     "test-survey",
+				Title:    "Test Survey",
+				StartsAt: tt.startsAt,
+				EndsAt:   tt.endsAt,
+				Definition: models.SurveyDefinition{Questions: []models.Question{
+					{ID: "q1", Text: "Test Question", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "A"}}},
+				}},
+			})
+
+			body, _ := json.Marshal(SubmitResponseRequest{Answers: map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}}})
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/test-survey/responses", bytes.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("slug")
+			c.SetParamValues("test-survey")
+
+			require.NoError(t, h.SubmitResponse(c))
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+
+			var errResp ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+			assert.Equal(t, tt.reason, errResp.Reason)
+			assert.Empty(t, mq.responses, "nothing stored")
+
+			// The web form is refused too, with the page's notice
+			req = httptest.NewRequest(http.MethodPost, "/surveys/test-survey/responses", strings.NewReader("q1=a"))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			rec = httptest.NewRecorder()
+			c = e.NewContext(req, rec)
+			c.SetParamNames("slug")
+			c.SetParamValues("test-survey")
+
+			require.NoError(t, h.SubmitResponseHTML(c))
+			assert.Contains(t, rec.Body.String(), "This survey")
+			assert.Empty(t, mq.responses, "nothing stored")
+		})
+	}
+}
+
+func TestGetSurveyHTML_Closed(t *testing.T) {
+	e, mq, h := setupTest()
+	closed := time.Now().Add(-time.Hour)
+	mq.CreateSurvey(context.Background(), &models.Survey{
+		ID:     uuid.New(),
+		Slug:   "test-survey",
+		Title:  "Test Survey",
+		EndsAt: &closed,
+		Definition: models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Test Question", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "A"}}},
+		}},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/surveys/test-survey", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues("test-survey")
+
+	require.NoError(t, h.GetSurveyHTML(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "This survey closed on")
+	assert.NotContains(t, rec.Body.String(), "<form")
+}
+
+func TestGetSurveyHTML_JSONLD(t *testing.T) {
+	e, mq, h := setupTest()
+	survey := &models.Survey{ID: uuid.New(), Slug: "test-survey", Title: "Test </script> Survey"}
+	mq.CreateSurvey(context.Background(), survey)
+	for _, session := range []string{"voter-1", "voter-2"} {
+		mq.CreateResponse(context.Background(), &models.Response{ID: uuid.New(), SurveyID: survey.ID, VoterSession: &session})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/surveys/test-survey", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues("test-survey")
+
+	require.NoError(t, h.GetSurveyHTML(c))
+	assert.Contains(t, rec.Body.String(), `<script id="survey-json-ld" type="application/ld+json">`)
+	assert.Contains(t, rec.Body.String(), `"name":"Test \u003c/script\u003e Survey"`)
+	assert.Contains(t, rec.Body.String(), `"userInteractionCount":2`)
+}
+
 func TestGetResults_Success(t *testing.T) {
 	e, mq, h := setupTest()
 
@@ -733,6 +1132,50 @@ func TestSubmitResponseHTML_GuestVoting(t *testing.T) {
 	assert.True(t, rec.Code == http.StatusOK || rec.Code == http.StatusSeeOther)
 }
 
+func TestSubmitResponseHTML_OtherText(t *testing.T) {
+	e, mq, h := setupTest()
+
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		Slug:  "other-survey",
+		Title: "Other Survey",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{
+				{
+					ID:   "q1",
+					Text: "Which days?",
+					Type: models.QuestionTypeMulti,
+					Options: []models.Option{
+						{ID: "mon", Text: "Monday"},
+						{ID: "other", Text: "Other", AllowFreeText: true},
+					},
+				},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	mq.CreateSurvey(context.Background(), survey)
+
+	form := "q1=mon&q1=other&q1-other-other=Thursday&q1-other-mon=ignored"
+	req := httptest.NewRequest(http.MethodPost, "/surveys/other-survey/responses", strings.NewReader(form))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.RemoteAddr = "192.168.1.1:12345"
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues("other-survey")
+
+	require.NoError(t, h.SubmitResponseHTML(c))
+
+	require.Len(t, mq.responses, 1)
+	for _, response := range mq.responses {
+		answer := response.Answers["q1"]
+		assert.ElementsMatch(t, []string{"mon", "other"}, answer.SelectedOptions)
+		assert.Equal(t, map[string]string{"other": "Thursday"}, answer.OtherText, "only options that allow free text are read")
+	}
+}
+
 // RED PHASE: Test response with PDS write (user logged in + survey has URI)
 func TestSubmitResponseHTML_WithOAuthAndSurveyURI(t *testing.T) {
 	// When:
@@ -1162,6 +1605,21 @@ func TestMyDataHTML_ShowsOverview(t *testing.T) {
 	assert.Contains(t, body, "net.openmeet.survey", "Should show survey collection")
 }
 
+// TestRecordResponseCounts ensures the My Data survey collection shows each
+// indexed survey's response count
+func TestRecordResponseCounts(t *testing.T) {
+	e, mq, h := setupTest()
+	uri := "at://did:plc:alice/net.openmeet.survey/lunch"
+	survey := &models.Survey{ID: uuid.New(), URI: &uri, Slug: "lunch", Title: "Lunch"}
+	mq.CreateSurvey(context.Background(), survey)
+	mq.responsesBySurvey[survey.ID]["session-1"] = &models.Response{ID: uuid.New(), SurveyID: survey.ID}
+
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/my-data/net.openmeet.survey", nil), httptest.NewRecorder())
+	records := []oauth.PDSRecord{{URI: uri, RKey: "lunch"}, {URI: "at://did:plc:alice/net.openmeet.survey/unindexed", RKey: "unindexed"}}
+	assert.Equal(t, map[string]int{uri: 1}, h.recordResponseCounts(c, "net.openmeet.survey", records))
+	assert.Nil(t, h.recordResponseCounts(c, "net.openmeet.survey.response", records), "only surveys have response counts")
+}
+
 // RED PHASE: Test MyDataCollection requires auth
 func TestMyDataCollectionHTML_RequiresAuth(t *testing.T) {
 	e, _, h := setupTest()
@@ -1207,60 +1665,71 @@ func TestDeleteRecordsHTML_RequiresAuth(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 }
 
-// RED PHASE: Test that ListSurveysHTML route should not exist
-// This test ensures the public survey list page is removed for security
-func TestListSurveysHTML_RouteNotFound(t *testing.T) {
+// TestBrowseSurveysHTML_RouteListsOnlyDiscoverable ensures the public survey
+// list never shows surveys only reachable by direct link
+func TestBrowseSurveysHTML_RouteListsOnlyDiscoverable(t *testing.T) {
 	e, mq, h := setupTest()
 	hh := &HealthHandlers{}
 
-	// Setup routes (this will include the route we want to remove)
 	SetupRoutes(e, h, hh, nil, nil)
 
-	// Create a survey
-	survey := &models.Survey{
-		ID:        uuid.New(),
-		Slug:      "test-survey",
-		Title:     "Test Survey",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	for _, discoverable := range []bool{true, false} {
+		slug := "unlisted-survey"
+		if discoverable {
+			slug = "public-survey"
+		}
+		mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:         uuid.New(),
+			Slug:       slug,
+			Title:      slug,
+			Definition: models.SurveyDefinition{Discoverable: discoverable},
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		})
 	}
-	mq.CreateSurvey(context.Background(), survey)
 
-	// Try to access the HTML survey list page
 	req := httptest.NewRequest(http.MethodGet, "/surveys", nil)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
-	// Should return 404 Not Found (route removed for security)
-	assert.Equal(t, http.StatusNotFound, rec.Code, "GET /surveys HTML route should not exist")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `href="/surveys/public-survey"`)
+	assert.NotContains(t, rec.Body.String(), "unlisted-survey")
 }
 
-// RED PHASE: Test that ListSurveys JSON API route should not exist
-// This test ensures the public survey list JSON API is removed for security
-func TestListSurveys_APIRouteNotFound(t *testing.T) {
+// The public survey list only has discoverable surveys
+func TestListSurveys_APIRouteListsDiscoverable(t *testing.T) {
 	e, mq, h := setupTest()
 	hh := &HealthHandlers{}
 
 	// Setup routes
 	SetupRoutes(e, h, hh, nil, nil)
 
-	// Create a survey
-	survey := &models.Survey{
+	mq.CreateSurvey(context.Background(), &models.Survey{
+		ID:         uuid.New(),
+		Slug:       "listed-survey",
+		Title:      "Listed Survey",
+		Definition: models.SurveyDefinition{Discoverable: true},
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	})
+	mq.CreateSurvey(context.Background(), &models.Survey{
 		ID:        uuid.New(),
-		Slug:      "test-survey",
-		Title:     "Test Survey",
+		Slug:      "unlisted-survey",
+		Title:     "Unlisted Survey",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
-	}
-	mq.CreateSurvey(context.Background(), survey)
+	})
 
-	// Try to access the JSON API survey list
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys", nil)
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
-	// Should return 404 Not Found (route removed for security)
-	assert.Equal(t, http.StatusNotFound, rec.Code, "GET /api/v1/surveys route should not exist")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var surveys []SurveyListResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+	require.Len(t, surveys, 1)
+	assert.Equal(t, "listed-survey", surveys[0].Slug)
 }
 
 // RED PHASE: Test PostHog script is included when key is configured
@@ -1391,6 +1860,921 @@ func TestGetSurveyHTML_PostHogScriptIncluded(t *testing.T) {
 	assert.Contains(t, body, "phc_TestAPIKey123", "Should include API key")
 }
 
+func TestGetSurveyHTML_Comments(t *testing.T) {
+	render := func(t *testing.T, allowComments bool) string {
+		e, mq, h := setupTest()
+
+		survey := &models.Survey{
+			ID:    uuid.New(),
+			Slug:  "commented-survey",
+			Title: "Commented Survey",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{ID: "q1", Text: "Test", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "A"}}},
+				},
+				AllowComments: allowComments,
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		mq.CreateSurvey(context.Background(), survey)
+		mq.comments[survey.ID] = []*models.Comment{{
+			ID:        uuid.New(),
+			SurveyID:  survey.ID,
+			AuthorDID: "did:plc:commenter",
+			Text:      "Please add a Friday slot",
+			CreatedAt: time.Now(),
+		}}
+
+		req := httptest.NewRequest(http.MethodGet, "/surveys/commented-survey", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues("commented-survey")
+
+		require.NoError(t, h.GetSurveyHTML(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	t.Run("shown when the author allows comments", func(t *testing.T) {
+		body := render(t, true)
+		assert.Contains(t, body, "Please add a Friday slot")
+		assert.Contains(t, body, "did:plc:commenter")
+	})
+
+	t.Run("hidden otherwise", func(t *testing.T) {
+		body := render(t, false)
+		assert.NotContains(t, body, "Please add a Friday slot")
+	})
+}
+
+func TestSearchSurveys(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	description := "Pick the best coffee beans"
+	findable := &models.Survey{
+		ID:          uuid.New(),
+		Slug:        "coffee-beans",
+		Title:       "Coffee Survey",
+		Description: &description,
+		Definition:  models.SurveyDefinition{Discoverable: true},
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	private := &models.Survey{
+		ID:         uuid.New(),
+		Slug:       "private-coffee",
+		Title:      "Private Coffee Survey",
+		Definition: models.SurveyDefinition{},
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	mq.CreateSurvey(context.Background(), findable)
+	mq.CreateSurvey(context.Background(), private)
+	mq.responsesBySurvey[findable.ID]["session-1"] = &models.Response{ID: uuid.New(), SurveyID: findable.ID}
+
+	search := func(t *testing.T, query string) []SurveySearchResponse {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys/search?q="+url.QueryEscape(query), nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var results []SurveySearchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		return results
+	}
+
+	t.Run("returns only discoverable matches", func(t *testing.T) {
+		results := search(t, "coffee")
+		require.Len(t, results, 1)
+		assert.Equal(t, "coffee-beans", results[0].Slug)
+		assert.Equal(t, 1, results[0].ResponseCount)
+	})
+
+	t.Run("empty query returns an empty list", func(t *testing.T) {
+		results := search(t, "")
+		assert.NotNil(t, results)
+		assert.Empty(t, results)
+	})
+
+	t.Run("HTML page lists matches", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=coffee", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "Coffee Survey")
+		assert.Contains(t, body, "/surveys/coffee-beans")
+		assert.Contains(t, body, "1 response")
+		assert.NotContains(t, body, "Private Coffee Survey")
+	})
+}
+
+func TestBrowseSurveysHTML(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	alice := "did:plc:alice"
+	spanish := "es-MX"
+	mq.handles[alice] = "alice.bsky.social"
+	seed := []struct {
+		slug      string
+		responses int
+		status    models.SurveyStatus
+		lang      *string
+		author    *string
+		listed    bool
+	}{
+		{"lunch", 1, models.SurveyStatusOpen, nil, &alice, true},
+		{"retro", 3, models.SurveyStatusClosed, nil, nil, true},
+		{"encuesta", 0, models.SurveyStatusOpen, &spanish, nil, true},
+		{"unlisted", 5, models.SurveyStatusOpen, nil, &alice, false},
+	}
+	for i, s := range seed {
+		survey := &models.Survey{
+			ID:         uuid.New(),
+			Slug:       s.slug,
+			Title:      "Survey " + s.slug,
+			AuthorDID:  s.author,
+			Lang:       s.lang,
+			Status:     s.status,
+			Definition: models.SurveyDefinition{Discoverable: s.listed},
+			CreatedAt:  created.Add(time.Duration(i) * time.Hour),
+			UpdatedAt:  created,
+		}
+		mq.CreateSurvey(context.Background(), survey)
+		for j := 0; j < s.responses; j++ {
+			mq.responsesBySurvey[survey.ID][fmt.Sprintf("session-%d", j)] = &models.Response{ID: uuid.New(), SurveyID: survey.ID}
+		}
+	}
+
+	get := func(t *testing.T, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	// listed returns the slugs of the surveys on a page, in order
+	listed := func(body string) []string {
+		var slugs []string
+		for _, m := range regexp.MustCompile(`<a href="/surveys/([a-z]+)" style`).FindAllStringSubmatch(body, -1) {
+			slugs = append(slugs, m[1])
+		}
+		return slugs
+	}
+
+	t.Run("lists discoverable surveys, newest first", func(t *testing.T) {
+		rec := get(t, "/surveys")
+		require.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Equal(t, []string{"encuesta", "retro", "lunch"}, listed(body))
+		assert.Contains(t, body, "by @alice.bsky.social · 1 response · March 1, 2025")
+		assert.Contains(t, body, ">Closed</span>")
+		assert.Contains(t, body, `<meta property="og:title" content="Browse Surveys - OpenMeet Survey">`)
+		assert.NotContains(t, body, "Load more")
+	})
+
+	t.Run("filters by status and language", func(t *testing.T) {
+		assert.Equal(t, []string{"retro"}, listed(get(t, "/surveys?status=closed").Body.String()))
+		assert.Equal(t, []string{"encuesta"}, listed(get(t, "/surveys?lang=ES").Body.String()))
+	})
+
+	t.Run("sorts by most responses", func(t *testing.T) {
+		body := get(t, "/surveys?sort=responses").Body.String()
+		assert.Equal(t, []string{"retro", "lunch", "encuesta"}, listed(body))
+		assert.Contains(t, body, `<option value="responses" selected>`)
+	})
+
+	t.Run("loads more a page at a time", func(t *testing.T) {
+		for i := 0; i < browsePageSurveys; i++ {
+			mq.CreateSurvey(context.Background(), &models.Survey{
+				ID:         uuid.New(),
+				Slug:       fmt.Sprintf("extra%c%c", 'a'+i/26, 'a'+i%26),
+				Title:      "Extra",
+				Status:     models.SurveyStatusOpen,
+				Definition: models.SurveyDefinition{Discoverable: true},
+				CreatedAt:  created.Add(time.Duration(24+i) * time.Hour),
+				UpdatedAt:  created,
+			})
+		}
+		t.Cleanup(func() {
+			for slug := range mq.surveys {
+				if strings.HasPrefix(slug, "extra") {
+					delete(mq.surveys, slug)
+				}
+			}
+		})
+
+		body := get(t, "/surveys?sort=responses").Body.String()
+		assert.Len(t, listed(body), browsePageSurveys)
+		more := regexp.MustCompile(`hx-get="([^"]+)"`).FindStringSubmatch(body)
+		require.Len(t, more, 2, "Load more link")
+		next := html.UnescapeString(more[1])
+		assert.True(t, strings.HasPrefix(next, "/surveys?cursor="), next)
+		assert.Contains(t, next, "sort=responses")
+
+		rec := get(t, next, "HX-Request", "true")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "<html", "only the next page of the list")
+		assert.Len(t, listed(rec.Body.String()), 3)
+		assert.NotContains(t, rec.Body.String(), "Load more")
+		assert.Contains(t, strings.Join(rec.Header().Values(echo.HeaderVary), ", "), "HX-Request")
+
+		// Without htmx the link opens the page on its own, kept out of search
+		// engines
+		rec = get(t, next)
+		assert.Contains(t, strings.Join(rec.Header().Values(echo.HeaderVary), ", "), "HX-Request")
+		body = rec.Body.String()
+		assert.Contains(t, body, "<html")
+		assert.Contains(t, body, `<meta name="robots" content="noindex`)
+	})
+
+	t.Run("rejects bad parameters", func(t *testing.T) {
+		for _, query := range []string{"status=deleted", "status=gone", "sort=oldest", "lang=not%20a%20language!", "cursor=garbage"} {
+			assert.Equal(t, http.StatusBadRequest, get(t, "/surveys?"+query).Code, query)
+		}
+		// A cursor from the other order
+		cursor := db.EncodeCursor(created, uuid.New())
+		assert.Equal(t, http.StatusBadRequest, get(t, "/surveys?sort=responses&cursor="+cursor).Code)
+	})
+}
+
+func TestGetTextAnswers(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	uri := "at://did:plc:author/net.openmeet.survey/text"
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		URI:   &uri,
+		Slug:  "feedback",
+		Title: "Feedback",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{
+				{ID: "q1", Text: "Pick one", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "A"}}},
+				{ID: "q2", Text: "Thoughts?", Type: models.QuestionTypeText},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	mq.CreateSurvey(context.Background(), survey)
+	base := time.Now().Add(-time.Hour)
+	for i, text := range []string{"first", "second", "third"} {
+		session := fmt.Sprintf("session-%d", i)
+		mq.responsesBySurvey[survey.ID][session] = &models.Response{
+			ID:        uuid.New(),
+			SurveyID:  survey.ID,
+			Answers:   map[string]models.Answer{"q2": {Text: text}},
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+	}
+
+	get := func(t *testing.T, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("pages through answers", func(t *testing.T) {
+		rec := get(t, "/api/v1/surveys/feedback/results/q2/text?limit=2&offset=1")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var answers []string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answers))
+		assert.Equal(t, []string{"second", "third"}, answers)
+	})
+
+	t.Run("past the end is empty", func(t *testing.T) {
+		rec := get(t, "/api/v1/surveys/feedback/results/q2/text?offset=10")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `[]`, rec.Body.String())
+	})
+
+	t.Run("choice and unknown questions are not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(t, "/api/v1/surveys/feedback/results/q1/text").Code)
+		assert.Equal(t, http.StatusNotFound, get(t, "/api/v1/surveys/feedback/results/nope/text").Code)
+		assert.Equal(t, http.StatusNotFound, get(t, "/api/v1/surveys/missing/results/q2/text").Code)
+	})
+}
+
+func TestExportResponsesCSV(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	author := "did:plc:author"
+	uri := "at://" + author + "/net.openmeet.survey/export"
+	survey := &models.Survey{
+		ID:        uuid.New(),
+		URI:       &uri,
+		AuthorDID: &author,
+		Slug:      "team-lunch",
+		Title:     "Team lunch",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{
+				{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}}},
+				{ID: "q2", Text: "Anything else?", Type: models.QuestionTypeText},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	mq.CreateSurvey(context.Background(), survey)
+
+	voter := "did:plc:voter"
+	mq.handles[voter] = "voter.test"
+	mq.responsesBySurvey[survey.ID]["voter"] = &models.Response{
+		ID:        uuid.New(),
+		SurveyID:  survey.ID,
+		VoterDID:  &voter,
+		Answers:   map[string]models.Answer{"q1": {SelectedOptions: []string{"b"}}, "q2": {Text: "Early, please"}},
+		CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	export := func(t *testing.T, slug string, user *oauth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys/"+slug+"/export.csv", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/surveys/:slug/export.csv")
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+		if user != nil {
+			c.Set("user", user)
+		}
+		require.NoError(t, h.ExportResponsesCSV(c))
+		return rec
+	}
+
+	t.Run("author downloads CSV", func(t *testing.T) {
+		rec := export(t, "team-lunch", &oauth.User{DID: author})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename=team-lunch-responses.csv`, rec.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, "Submitted At,Handle,DID,Where?,Anything else?\n"+
+			"2025-01-02T03:04:05Z,voter.test,did:plc:voter,Sushi,\"Early, please\"\n", rec.Body.String())
+	})
+
+	t.Run("resolves respondents' handles that aren't stored", func(t *testing.T) {
+		guest := "did:plc:guest"
+		for i, day := range []int{3, 4} {
+			mq.responsesBySurvey[survey.ID][fmt.Sprintf("guest-%d", i)] = &models.Response{
+				ID:        uuid.New(),
+				SurveyID:  survey.ID,
+				VoterDID:  &guest,
+				Answers:   map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}},
+				CreatedAt: time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC),
+			}
+		}
+		t.Cleanup(func() {
+			delete(mq.responsesBySurvey[survey.ID], "guest-0")
+			delete(mq.responsesBySurvey[survey.ID], "guest-1")
+			delete(mq.handles, guest)
+		})
+		resolved := 0
+		h.resolveHandle = func(_ context.Context, did string) (string, error) {
+			resolved++
+			return "guest.test", nil
+		}
+		t.Cleanup(func() {
+			h.resolveHandle = func(_ context.Context, did string) (string, error) { return "", fmt.Errorf("disabled") }
+		})
+
+		rec := export(t, "team-lunch", &oauth.User{DID: author})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "2025-01-03T00:00:00Z,guest.test,did:plc:guest,Tacos,\n"+
+			"2025-01-04T00:00:00Z,guest.test,did:plc:guest,Tacos,\n")
+		assert.Equal(t, 1, resolved, "each DID is resolved once")
+		assert.Equal(t, "guest.test", mq.handles[guest], "and stored")
+	})
+
+	t.Run("requires sign in", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys/team-lunch/export.csv", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("other users are forbidden", func(t *testing.T) {
+		rec := export(t, "team-lunch", &oauth.User{DID: voter})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentDisposition))
+	})
+
+	t.Run("unknown survey", func(t *testing.T) {
+		rec := export(t, "missing", &oauth.User{DID: author})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestUpdateSurvey(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	author := "did:plc:author"
+	uri := "at://" + author + "/net.openmeet.survey/3kedit"
+	indexedCID := "bafyindexed"
+	lang := "es"
+	opens := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	survey := &models.Survey{
+		ID:        uuid.New(),
+		URI:       &uri,
+		CID:       &indexedCID,
+		AuthorDID: &author,
+		Slug:      "team-lunch",
+		Title:     "Team lunch",
+		Lang:      &lang,
+		StartsAt:  &opens,
+		Version:   3,
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{
+				{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}}},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	mq.CreateSurvey(context.Background(), survey)
+
+	pdsCID := indexedCID
+	h.getRecord = func(c echo.Context, collection, rkey string) (*oauth.PDSRecord, error) {
+		return &oauth.PDSRecord{CID: pdsCID, Value: map[string]interface{}{"name": "Team lunch", "lang": "es", "startsAt": "2025-03-01T00:00:00Z"}}, nil
+	}
+	var pdsWrites []string
+	var written map[string]interface{}
+	h.updateRecord = func(c echo.Context, collection, rkey string, record interface{}, swapCID string) (string, error) {
+		if swapCID != pdsCID {
+			return "", oauth.ErrRecordChanged
+		}
+		pdsWrites = append(pdsWrites, rkey)
+		written = record.(map[string]interface{})
+		return "bafyupdated", nil
+	}
+
+	definition := `{"questions":[{"id":"q1","text":"Where?","type":"single","options":[{"id":"a","text":"Tacos"},{"id":"b","text":"Sushi"},{"id":"c","text":"Pizza"}]}]}`
+	update := func(t *testing.T, user *oauth.User, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/surveys/team-lunch", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/surveys/:slug")
+		c.SetParamNames("slug")
+		c.SetParamValues("team-lunch")
+		if user != nil {
+			c.Set("user", user)
+		}
+		require.NoError(t, h.UpdateSurvey(c))
+		return rec
+	}
+	body := func(version int) string {
+		req := UpdateSurveyRequest{Definition: definition, Version: version}
+		b, _ := json.Marshal(req)
+		return string(b)
+	}
+
+	t.Run("requires sign in", func(t *testing.T) {
+		rec := update(t, nil, body(3))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("requires version", func(t *testing.T) {
+		rec := update(t, &oauth.User{DID: author}, body(0))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("other users are forbidden", func(t *testing.T) {
+		rec := update(t, &oauth.User{DID: "did:plc:other"}, body(3))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("stale version conflicts without writing to the PDS", func(t *testing.T) {
+		pdsWrites = nil
+		rec := update(t, &oauth.User{DID: author}, body(2))
+		require.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "version 3")
+		assert.Empty(t, pdsWrites)
+		assert.Len(t, mq.surveys["team-lunch"].Definition.Questions[0].Options, 2)
+	})
+
+	writeRecord := h.updateRecord
+
+	t.Run("record changed on the PDS conflicts without writing", func(t *testing.T) {
+		pdsWrites = nil
+		pdsCID = "bafyelsewhere"
+		defer func() { pdsCID = indexedCID }()
+
+		rec := update(t, &oauth.User{DID: author}, body(3))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Empty(t, pdsWrites)
+	})
+
+	t.Run("concurrent save conflicts without writing", func(t *testing.T) {
+		h.updateRecord = func(c echo.Context, collection, rkey string, record interface{}, swapCID string) (string, error) {
+			// Another edit was written between the read and the swap
+			return "", oauth.ErrRecordChanged
+		}
+		defer func() { h.updateRecord = writeRecord }()
+
+		rec := update(t, &oauth.User{DID: author}, body(3))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, 3, mq.surveys["team-lunch"].Version)
+		assert.Len(t, mq.surveys["team-lunch"].Definition.Questions[0].Options, 2)
+	})
+
+	t.Run("index moving on after the swap still reports the saved edit", func(t *testing.T) {
+		h.updateRecord = func(c echo.Context, collection, rkey string, record interface{}, swapCID string) (string, error) {
+			// The consumer indexes the edit before the handler saves it
+			mq.surveysByURI[uri].Version++
+			return "bafyupdated", nil
+		}
+		defer func() {
+			h.updateRecord = writeRecord
+			mq.surveysByURI[uri].Version = 3
+		}()
+
+		rec := update(t, &oauth.User{DID: author}, body(3))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp SurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 4, resp.Version)
+		assert.Len(t, resp.Definition.Questions[0].Options, 3)
+	})
+
+	t.Run("token refresh failures", func(t *testing.T) {
+		defer func() { h.updateRecord = writeRecord }()
+
+		h.updateRecord = func(c echo.Context, collection, rkey string, record interface{}, swapCID string) (string, error) {
+			return "", fmt.Errorf("failed to refresh access token: %w", oauth.ErrSessionInvalid)
+		}
+		rec := update(t, &oauth.User{DID: author}, body(3))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("Set-Cookie"), "session=;")
+
+		h.updateRecord = func(c echo.Context, collection, rkey string, record interface{}, swapCID string) (string, error) {
+			return "", fmt.Errorf("failed to refresh access token: %w", oauth.ErrRefreshTransient)
+		}
+		rec = update(t, &oauth.User{DID: author}, body(3))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, tokenRefreshRetryAfter, rec.Header().Get("Retry-After"))
+		assert.Empty(t, rec.Header().Get("Set-Cookie"))
+		assert.Equal(t, 3, mq.surveys["team-lunch"].Version)
+	})
+
+	t.Run("author saves current version", func(t *testing.T) {
+		pdsWrites = nil
+		rec := update(t, &oauth.User{DID: author}, body(3))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var resp SurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, 4, resp.Version)
+		assert.Len(t, resp.Definition.Questions[0].Options, 3)
+		assert.Equal(t, []string{"3kedit"}, pdsWrites)
+		assert.Equal(t, "bafyupdated", *mq.surveys["team-lunch"].CID)
+
+		// Fields the edit doesn't touch are kept
+		assert.Equal(t, "es", written["lang"])
+		assert.Equal(t, "2025-03-01T00:00:00Z", written["startsAt"])
+		assert.NotContains(t, written, "endsAt")
+	})
+
+	t.Run("author edits the schedule", func(t *testing.T) {
+		pdsCID = "bafyupdated"
+		closes := opens.Add(7 * 24 * time.Hour)
+		b, _ := json.Marshal(UpdateSurveyRequest{Definition: definition, EndsAt: &closes, Version: 4})
+		rec := update(t, &oauth.User{DID: author}, string(b))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2025-03-08T00:00:00Z", written["endsAt"])
+		assert.Equal(t, "2025-03-01T00:00:00Z", written["startsAt"], "unchanged when missing")
+		assert.True(t, mq.surveys["team-lunch"].EndsAt.Equal(closes))
+
+		early := opens.Add(-time.Hour)
+		b, _ = json.Marshal(UpdateSurveyRequest{Definition: definition, EndsAt: &early, Version: 5})
+		rec = update(t, &oauth.User{DID: author}, string(b))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "endsAt must be after startsAt")
+		assert.True(t, mq.surveys["team-lunch"].EndsAt.Equal(closes))
+	})
+}
+
+func TestTokenRefreshFailed(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("DELETE FROM oauth_sessions").RowsAffected(1)
+	h := NewHandlersWithOAuth(NewMockQueries(), oauth.NewStorage(fake.DB), nil)
+	e := echo.New()
+
+	call := func(err error) (*httptest.ResponseRecorder, int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/my-data/delete", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: "session-1"})
+		rec := httptest.NewRecorder()
+		status, message := h.tokenRefreshFailed(e.NewContext(req, rec), fmt.Errorf("token refresh failed: %w", err))
+		return rec, status, message
+	}
+
+	t.Run("invalid session is deleted and its cookie cleared", func(t *testing.T) {
+		rec, status, message := call(oauth.ErrSessionInvalid)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "Session expired. Please log in again.", message)
+		assert.Contains(t, rec.Header().Get("Set-Cookie"), "Max-Age=0")
+		assert.Empty(t, rec.Header().Get("Retry-After"))
+
+		deletes := fake.CallsMatching("DELETE FROM oauth_sessions")
+		require.Len(t, deletes, 1)
+		assert.Equal(t, []interface{}{"session-1"}, deletes[0].Args)
+	})
+
+	t.Run("transient failure keeps the session", func(t *testing.T) {
+		rec, status, _ := call(oauth.ErrRefreshTransient)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, tokenRefreshRetryAfter, rec.Header().Get("Retry-After"))
+		assert.Empty(t, rec.Header().Get("Set-Cookie"))
+		assert.Len(t, fake.CallsMatching("DELETE FROM oauth_sessions"), 1)
+	})
+
+	t.Run("unclassified failure", func(t *testing.T) {
+		rec, status, _ := call(errors.New("storage is nil"))
+		assert.Equal(t, http.StatusInternalServerError, status)
+		assert.Empty(t, rec.Header().Get("Set-Cookie"))
+	})
+}
+
+func TestListUserSurveys(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	alice := "did:plc:alice"
+	bob := "did:plc:bob"
+	deletedAt := time.Now()
+	create := func(slug, author string, age time.Duration, discoverable bool, deleted *time.Time) *models.Survey {
+		survey := &models.Survey{
+			ID:         uuid.New(),
+			AuthorDID:  &author,
+			Slug:       slug,
+			Title:      slug,
+			Definition: models.SurveyDefinition{Discoverable: discoverable},
+			CreatedAt:  time.Now().Add(-age),
+			UpdatedAt:  time.Now().Add(-age),
+			DeletedAt:  deleted,
+		}
+		mq.CreateSurvey(context.Background(), survey)
+		return survey
+	}
+	older := create("alice-older", alice, 2*time.Hour, true, nil)
+	create("alice-unlisted", alice, time.Hour, false, nil)
+	create("alice-deleted", alice, 30*time.Minute, true, &deletedAt)
+	create("bob-survey", bob, time.Minute, true, nil)
+	mq.responsesBySurvey[older.ID]["session-1"] = &models.Response{ID: uuid.New(), SurveyID: older.ID}
+
+	list := func(t *testing.T, target string, user *oauth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/users/:did/surveys")
+		c.SetParamNames("did")
+		c.SetParamValues(strings.Split(strings.TrimPrefix(target, "/api/v1/users/"), "/")[0])
+		if user != nil {
+			c.Set("user", user)
+		}
+		require.NoError(t, h.ListUserSurveys(c))
+		return rec
+	}
+	slugs := func(t *testing.T, rec *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, rec.Code)
+		var results []AuthorSurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		out := make([]string, len(results))
+		for i, r := range results {
+			out[i] = r.Slug
+		}
+		return out
+	}
+
+	t.Run("others see only discoverable surveys", func(t *testing.T) {
+		rec := list(t, "/api/v1/users/"+alice+"/surveys", &oauth.User{DID: bob})
+		assert.Equal(t, []string{"alice-older"}, slugs(t, rec))
+		assert.Equal(t, "1", rec.Header().Get(headerTotalCount))
+
+		var results []AuthorSurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+		assert.Equal(t, 1, results[0].ResponseCount)
+	})
+
+	t.Run("signed out through the router", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+bob+"/surveys", nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, []string{"bob-survey"}, slugs(t, rec))
+	})
+
+	t.Run("author sees unlisted surveys", func(t *testing.T) {
+		rec := list(t, "/api/v1/users/"+alice+"/surveys", &oauth.User{DID: alice})
+		assert.Equal(t, []string{"alice-unlisted", "alice-older"}, slugs(t, rec))
+		assert.Equal(t, "2", rec.Header().Get(headerTotalCount))
+	})
+
+	t.Run("author can include deleted surveys", func(t *testing.T) {
+		rec := list(t, "/api/v1/users/"+alice+"/surveys?includeDeleted=true", &oauth.User{DID: alice})
+		assert.Equal(t, []string{"alice-deleted", "alice-unlisted", "alice-older"}, slugs(t, rec))
+		assert.Contains(t, rec.Body.String(), `"deletedAt"`)
+	})
+
+	t.Run("others can't include deleted surveys", func(t *testing.T) {
+		rec := list(t, "/api/v1/users/"+alice+"/surveys?includeDeleted=true", nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		rec := list(t, "/api/v1/users/"+alice+"/surveys?limit=1&offset=1", &oauth.User{DID: alice})
+		assert.Equal(t, []string{"alice-older"}, slugs(t, rec))
+		assert.Equal(t, "2", rec.Header().Get(headerTotalCount))
+	})
+
+	t.Run("rejects a non-DID", func(t *testing.T) {
+		rec := list(t, "/api/v1/users/alice/surveys", nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGetSurveyHTML_AuthorHandle(t *testing.T) {
+	newAuthoredSurvey := func(slug, did string) *models.Survey {
+		return &models.Survey{
+			ID:        uuid.New(),
+			AuthorDID: &did,
+			Slug:      slug,
+			Title:     "Authored Survey",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{
+						ID:       "q1",
+						Text:     "Test",
+						Type:     models.QuestionTypeSingle,
+						Required: true,
+						Options:  []models.Option{{ID: "a", Text: "A"}},
+					},
+				},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+
+	renderSurvey := func(t *testing.T, e *echo.Echo, h *Handlers, slug string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/surveys/"+slug, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+
+		require.NoError(t, h.GetSurveyHTML(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	t.Run("shows stored handle", func(t *testing.T) {
+		e, mq, h := setupTest()
+		mq.CreateSurvey(context.Background(), newAuthoredSurvey("authored", "did:plc:alice"))
+		mq.UpsertHandle(context.Background(), "did:plc:alice", "alice.bsky.social")
+
+		body := renderSurvey(t, e, h, "authored")
+		assert.Contains(t, body, "@alice.bsky.social")
+	})
+
+	t.Run("resolves and stores handle when none is stored", func(t *testing.T) {
+		e, mq, h := setupTest()
+		h.resolveHandle = func(_ context.Context, did string) (string, error) {
+			assert.Equal(t, "did:plc:bob", did)
+			return "bob.bsky.social", nil
+		}
+		mq.CreateSurvey(context.Background(), newAuthoredSurvey("authored", "did:plc:bob"))
+
+		body := renderSurvey(t, e, h, "authored")
+		assert.Contains(t, body, "@bob.bsky.social")
+		assert.Equal(t, "bob.bsky.social", mq.handles["did:plc:bob"])
+	})
+
+	t.Run("omits author when handle cannot be resolved", func(t *testing.T) {
+		e, mq, h := setupTest()
+		mq.CreateSurvey(context.Background(), newAuthoredSurvey("authored", "did:plc:carol"))
+
+		body := renderSurvey(t, e, h, "authored")
+		assert.NotContains(t, body, "survey-author")
+	})
+
+	t.Run("shows cached display name and avatar", func(t *testing.T) {
+		e, mq, h := setupTest()
+		h.SetProfileCache(&mockProfileCache{profiles: map[string]*oauth.Profile{
+			"did:plc:alice": {DID: "did:plc:alice", Handle: "alice.old", DisplayName: "Alice Smith", Avatar: "https://cdn.example.com/alice.jpg"},
+		}})
+		mq.CreateSurvey(context.Background(), newAuthoredSurvey("authored", "did:plc:alice"))
+		mq.UpsertHandle(context.Background(), "did:plc:alice", "alice.bsky.social")
+
+		body := renderSurvey(t, e, h, "authored")
+		assert.Contains(t, body, "Alice Smith")
+		assert.Contains(t, body, "https://cdn.example.com/alice.jpg")
+		// The stored handle, which identity events keep current, wins
+		assert.Contains(t, body, "@alice.bsky.social")
+		assert.NotContains(t, body, "alice.old")
+	})
+
+	t.Run("shows just the handle when the profile is unavailable", func(t *testing.T) {
+		e, mq, h := setupTest()
+		h.SetProfileCache(&mockProfileCache{})
+		mq.CreateSurvey(context.Background(), newAuthoredSurvey("authored", "did:plc:alice"))
+		mq.UpsertHandle(context.Background(), "did:plc:alice", "alice.bsky.social")
+
+		body := renderSurvey(t, e, h, "authored")
+		assert.Contains(t, body, "@alice.bsky.social")
+		assert.NotContains(t, body, "author-avatar")
+	})
+}
+
+// mockProfileCache serves profiles from a map, failing for unknown DIDs
+type mockProfileCache struct {
+	profiles map[string]*oauth.Profile
+}
+
+func (m *mockProfileCache) Get(ctx context.Context, did string) (*oauth.Profile, error) {
+	if profile, ok := m.profiles[did]; ok {
+		return profile, nil
+	}
+	return nil, fmt.Errorf("profile unavailable")
+}
+
+func TestGetResultsHTML_PublishedResults(t *testing.T) {
+	surveyURI := "at://did:plc:alice/net.openmeet.survey/abc"
+	newPublishedSurvey := func() *models.Survey {
+		did := "did:plc:alice"
+		return &models.Survey{
+			ID:        uuid.New(),
+			URI:       &surveyURI,
+			AuthorDID: &did,
+			Slug:      "published",
+			Title:     "Published Survey",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{
+						ID:      "q1",
+						Text:    "Favorite color?",
+						Type:    models.QuestionTypeSingle,
+						Options: []models.Option{{ID: "red", Text: "Red"}, {ID: "blue", Text: "Blue"}},
+					},
+				},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+
+	renderResults := func(t *testing.T, e *echo.Echo, h *Handlers) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/surveys/published/results", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues("published")
+
+		require.NoError(t, h.GetResultsHTML(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	t.Run("shows published snapshot alongside live counts", func(t *testing.T) {
+		e, mq, h := setupTest()
+		mq.CreateSurvey(context.Background(), newPublishedSurvey())
+		mq.published[surveyURI] = &models.PublishedResults{
+			SurveyURI:    surveyURI,
+			ResultsURI:   "at://did:plc:alice/net.openmeet.survey.results/xyz",
+			PublisherDID: "did:plc:alice",
+			TotalVotes:   4,
+			QuestionResults: map[string]*models.PublishedQuestionResult{
+				"q1": {QuestionID: "q1", OptionCounts: map[string]int{"red": 3, "blue": 1}},
+			},
+			PublishedAt: time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC),
+		}
+
+		body := renderResults(t, e, h)
+		assert.Contains(t, body, "Official results published by the author on March 8, 2025")
+		assert.Contains(t, body, "3 votes (75.0%)")
+		assert.Contains(t, body, "results-container", "live counts are still shown")
+	})
+
+	t.Run("omits snapshot when none is published", func(t *testing.T) {
+		e, mq, h := setupTest()
+		mq.CreateSurvey(context.Background(), newPublishedSurvey())
+
+		body := renderResults(t, e, h)
+		assert.NotContains(t, body, "published-results")
+	})
+}
+
 // RED PHASE: Short URL Routes
 
 func TestShortSlugURL_RedirectsToSurvey(t *testing.T) {
@@ -1487,6 +2871,35 @@ func TestATProtoURL_RedirectsToSurvey(t *testing.T) {
 	assert.Equal(t, "/surveys/atproto-survey", rec.Header().Get("Location"))
 }
 
+func TestATProtoURL_DeletedSurvey(t *testing.T) {
+	e, mq, h := setupTest()
+
+	did := "did:plc:xyz123"
+	rkey := "deleted123"
+	uri := fmt.Sprintf("at://%s/net.openmeet.survey/%s", did, rkey)
+	deletedAt := time.Now()
+
+	mq.CreateSurvey(context.Background(), &models.Survey{
+		ID:        uuid.New(),
+		URI:       &uri,
+		Slug:      "deleted-survey",
+		Title:     "Deleted Survey",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		DeletedAt: &deletedAt,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/at/%s/%s", did, rkey), nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("did", "rkey")
+	c.SetParamValues(did, rkey)
+
+	err := h.ATProtoURL(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
 func TestATProtoURL_NotFound(t *testing.T) {
 	e, _, h := setupTest()
 
@@ -1504,3 +2917,281 @@ func TestATProtoURL_NotFound(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 }
+
+func TestListSurveys_LangFilter(t *testing.T) {
+	e, mq, h := setupTest()
+
+	for slug, lang := range map[string]string{"encuesta": "es-MX", "chousa": "ja", "survey": ""} {
+		survey := &models.Survey{ID: uuid.New(), Slug: slug, Title: slug, Definition: models.SurveyDefinition{Discoverable: true}}
+		if lang != "" {
+			survey.Lang = &lang
+		}
+		mq.CreateSurvey(context.Background(), survey)
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, h.ListSurveys(e.NewContext(req, rec)))
+		return rec
+	}
+
+	t.Run("matches regional variants, case-insensitively", func(t *testing.T) {
+		rec := list("?lang=ES")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var surveys []SurveyListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+		require.Len(t, surveys, 1)
+		assert.Equal(t, "encuesta", surveys[0].Slug)
+		assert.Equal(t, "es-MX", *surveys[0].Lang)
+	})
+
+	t.Run("no filter lists everything", func(t *testing.T) {
+		var surveys []SurveyListResponse
+		require.NoError(t, json.Unmarshal(list("").Body.Bytes(), &surveys))
+		assert.Len(t, surveys, 3)
+	})
+
+	t.Run("rejects a malformed tag", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, list("?lang=not_a_language!").Code)
+	})
+}
+
+func TestListSurveys_Cursor(t *testing.T) {
+	e, mq, h := setupTest()
+
+	// Pairs share a timestamp so pages must break ties on ID
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:        uuid.New(),
+			Slug:      fmt.Sprintf("survey-%d", i),
+			Title:     fmt.Sprintf("Survey %d", i),
+			CreatedAt:  base.Add(time.Duration(i/2) * time.Minute),
+			Definition: models.SurveyDefinition{Discoverable: true},
+		})
+	}
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, h.ListSurveys(e.NewContext(req, rec)))
+		return rec
+	}
+
+	t.Run("walks every survey exactly once", func(t *testing.T) {
+		seen := map[string]int{}
+		query := "?limit=3"
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 5, "pagination did not terminate")
+			rec := list(query)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var surveys []SurveyListResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+			for _, s := range surveys {
+				seen[s.Slug]++
+			}
+
+			next := rec.Header().Get(headerNextCursor)
+			if next == "" {
+				break
+			}
+			query = "?limit=3&cursor=" + url.QueryEscape(next)
+		}
+
+		assert.Len(t, seen, 7)
+		for slug, n := range seen {
+			assert.Equal(t, 1, n, "survey %s listed %d times", slug, n)
+		}
+	})
+
+	t.Run("rejects a malformed cursor", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, list("?cursor=not-a-cursor").Code)
+	})
+}
+
+func TestListSurveys_StatusFilter(t *testing.T) {
+	e, mq, h := setupTest()
+
+	alice := "did:plc:alice"
+	for slug, status := range map[string]models.SurveyStatus{
+		"lunch":   models.SurveyStatusOpen,
+		"retro":   models.SurveyStatusClosed,
+		"offsite": models.SurveyStatusScheduled,
+	} {
+		mq.CreateSurvey(context.Background(), &models.Survey{ID: uuid.New(), Slug: slug, Title: slug, AuthorDID: &alice, Status: status,
+			Definition: models.SurveyDefinition{Discoverable: true}})
+	}
+	bob := "did:plc:bob"
+	mq.CreateSurvey(context.Background(), &models.Survey{ID: uuid.New(), Slug: "bobs", Title: "bobs", AuthorDID: &bob, Status: models.SurveyStatusOpen,
+		Definition: models.SurveyDefinition{Discoverable: true}})
+
+	list := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, h.ListSurveys(e.NewContext(req, rec)))
+		return rec
+	}
+	slugs := func(t *testing.T, rec *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, rec.Code)
+		var surveys []SurveyListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+		var result []string
+		for _, s := range surveys {
+			result = append(result, s.Slug+":"+string(s.Status))
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	t.Run("by status", func(t *testing.T) {
+		assert.Equal(t, []string{"bobs:open", "lunch:open"}, slugs(t, list("?status=open")))
+		assert.Equal(t, []string{"retro:closed"}, slugs(t, list("?status=closed")))
+		assert.Equal(t, []string{"offsite:scheduled"}, slugs(t, list("?status=scheduled")))
+	})
+
+	t.Run("by author and status", func(t *testing.T) {
+		assert.Equal(t, []string{"lunch:open"}, slugs(t, list("?status=open&author="+alice)))
+	})
+
+	t.Run("rejects unknown and deleted statuses", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, list("?status=archived").Code)
+		assert.Equal(t, http.StatusBadRequest, list("?status=deleted").Code)
+	})
+}
+
+func TestListSurveys_StatusFilterRoute(t *testing.T) {
+	e, mq, h := setupTest()
+	SetupRoutes(e, h, &HealthHandlers{}, nil, nil)
+
+	for slug, status := range map[string]models.SurveyStatus{
+		"lunch":   models.SurveyStatusOpen,
+		"retro":   models.SurveyStatusClosed,
+		"offsite": models.SurveyStatusScheduled,
+	} {
+		mq.CreateSurvey(context.Background(), &models.Survey{ID: uuid.New(), Slug: slug, Title: slug, Status: status,
+			Definition: models.SurveyDefinition{Discoverable: true}})
+	}
+	// Not discoverable, so never listed whatever its status
+	mq.CreateSurvey(context.Background(), &models.Survey{ID: uuid.New(), Slug: "private", Title: "private", Status: models.SurveyStatusOpen})
+
+	list := func(t *testing.T, status string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys?status="+status, nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var surveys []SurveyListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+		var result []string
+		for _, s := range surveys {
+			result = append(result, s.Slug+":"+string(s.Status))
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"lunch:open"}, list(t, "open"))
+	assert.Equal(t, []string{"retro:closed"}, list(t, "closed"))
+	assert.Equal(t, []string{"offsite:scheduled"}, list(t, "scheduled"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/surveys?status=deleted", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestListUserSurveys_StatusFilter(t *testing.T) {
+	e, mq, h := setupTest()
+
+	alice := "did:plc:alice"
+	deletedAt := time.Now()
+	for slug, status := range map[string]models.SurveyStatus{
+		"lunch": models.SurveyStatusOpen,
+		"retro": models.SurveyStatusClosed,
+		"old":   models.SurveyStatusDeleted,
+	} {
+		survey := &models.Survey{ID: uuid.New(), Slug: slug, Title: slug, AuthorDID: &alice, Status: status,
+			Definition: models.SurveyDefinition{Discoverable: true}}
+		if status == models.SurveyStatusDeleted {
+			survey.DeletedAt = &deletedAt
+		}
+		mq.CreateSurvey(context.Background(), survey)
+	}
+
+	list := func(query string, user *oauth.User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+alice+"/surveys"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("did")
+		c.SetParamValues(alice)
+		if user != nil {
+			c.Set("user", user)
+		}
+		require.NoError(t, h.ListUserSurveys(c))
+		return rec
+	}
+
+	t.Run("by status", func(t *testing.T) {
+		rec := list("?status=closed", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var surveys []AuthorSurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+		require.Len(t, surveys, 1)
+		assert.Equal(t, "retro", surveys[0].Slug)
+		assert.Equal(t, models.SurveyStatusClosed, surveys[0].Status)
+		assert.Equal(t, "1", rec.Header().Get(headerTotalCount))
+	})
+
+	t.Run("deleted is for the author only", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, list("?status=deleted", nil).Code)
+
+		rec := list("?status=deleted", &oauth.User{DID: alice})
+		require.Equal(t, http.StatusOK, rec.Code)
+		var surveys []AuthorSurveyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &surveys))
+		require.Len(t, surveys, 1)
+		assert.Equal(t, "old", surveys[0].Slug)
+	})
+
+	t.Run("rejects an unknown status", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, list("?status=archived", nil).Code)
+	})
+}
+
+// fakeResponseSubscriber signals every subscription at once, recording the
+// surveys subscribed to
+type fakeResponseSubscriber struct {
+	subscribed []string
+}
+
+func (f *fakeResponseSubscriber) Subscribe(uri string) (<-chan struct{}, func()) {
+	f.subscribed = append(f.subscribed, uri)
+	ch := make(chan struct{}, 1)
+	ch <- struct{}{}
+	return ch, func() {}
+}
+
+// TestGetResultsPartialHTML_WaitsForResponses ensures results polls asking to
+// wait are held until the survey's responses change, and others aren't
+func TestGetResultsPartialHTML_WaitsForResponses(t *testing.T) {
+	e, mq, h := setupTest()
+	subscriber := &fakeResponseSubscriber{}
+	h.SetResponseSubscriber(subscriber)
+
+	uri := "at://did:plc:alice/net.openmeet.survey/lunch"
+	survey := &models.Survey{ID: uuid.New(), URI: &uri, Slug: "lunch", Title: "Lunch"}
+	mq.CreateSurvey(context.Background(), survey)
+
+	for _, target := range []string{"/surveys/lunch/results-partial", "/surveys/lunch/results-partial?wait=1"} {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+		c.SetParamNames("slug")
+		c.SetParamValues("lunch")
+
+		require.NoError(t, h.GetResultsPartialHTML(c))
+		assert.Equal(t, http.StatusOK, rec.Code, target)
+	}
+	assert.Equal(t, []string{uri}, subscriber.subscribed, "only the waiting poll subscribes")
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/templates"
+)
+
+// localeCookieMaxAge is how long a picked language is remembered
+const localeCookieMaxAge = 365 * 24 * 60 * 60
+
+// LocaleMiddleware sets the language pages render in on the request's
+// context: the one the visitor picked, else the best match for their
+// browser's Accept-Language
+func LocaleMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			picked := ""
+			if cookie, err := req.Cookie(templates.LocaleCookie); err == nil {
+				picked = cookie.Value
+			}
+			locale := templates.MatchLocale(picked, req.Header.Get("Accept-Language"))
+			c.SetRequest(req.WithContext(templates.SetLocale(req.Context(), locale)))
+
+			// The same URL renders differently per visitor, so caches must
+			// key on what chose the language
+			c.Response().Header().Add(echo.HeaderVary, "Accept-Language, Cookie")
+
+			return next(c)
+		}
+	}
+}
+
+// SetLanguage remembers the language a visitor picked and sends them back to
+// the page they picked it on
+// GET /language/:tag
+func (h *Handlers) SetLanguage(c echo.Context) error {
+	tag := c.Param("tag")
+
+	supported := false
+	for _, locale := range templates.Locales {
+		if locale.String() == tag {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return c.String(http.StatusNotFound, "Language not found")
+	}
+
+	c.SetCookie(&http.Cookie{
+		Name:     templates.LocaleCookie,
+		Value:    tag,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true, // HTTPS only
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   localeCookieMaxAge,
+	})
+	return c.Redirect(http.StatusSeeOther, languageReturnPath(c))
+}
+
+// languageReturnPath is the page the language was picked on, from the
+// Referer, or the home page. Only pages on this site are returned to, so the
+// link can't be used to redirect elsewhere.
+func languageReturnPath(c echo.Context) string {
+	referer, err := url.Parse(c.Request().Referer())
+	if err != nil || referer.Host != c.Request().Host || referer.Path == "" {
+		return "/"
+	}
+	return referer.RequestURI()
+}
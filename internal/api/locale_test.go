@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocaleMiddleware(t *testing.T) {
+	e, mq, h := setupTest()
+	require.NoError(t, mq.CreateSurvey(context.Background(), &models.Survey{
+		ID:    uuid.New(),
+		Slug:  "lunch",
+		Title: "Lunch order",
+		Definition: models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}}},
+		}},
+	}))
+	get := func(acceptLanguage, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/surveys/lunch/embed", nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: templates.LocaleCookie, Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues("lunch")
+		require.NoError(t, LocaleMiddleware()(h.GetSurveyEmbedHTML)(c))
+		return rec
+	}
+
+	t.Run("English without Accept-Language", func(t *testing.T) {
+		rec := get("", "")
+		assert.Contains(t, rec.Body.String(), "Submit Response")
+		assert.Contains(t, rec.Body.String(), `<html lang="en">`)
+		assert.Equal(t, "Accept-Language, Cookie", rec.Header().Get(echo.HeaderVary))
+	})
+
+	t.Run("Spanish from Accept-Language", func(t *testing.T) {
+		rec := get("es-MX,es;q=0.9,en;q=0.8", "")
+		assert.Contains(t, rec.Body.String(), "Enviar respuesta")
+		assert.Contains(t, rec.Body.String(), `<html lang="es">`)
+	})
+
+	t.Run("unsupported languages fall back to English", func(t *testing.T) {
+		rec := get("fr-FR,de;q=0.8", "")
+		assert.Contains(t, rec.Body.String(), "Submit Response")
+	})
+
+	t.Run("cookie overrides Accept-Language", func(t *testing.T) {
+		rec := get("es", "en")
+		assert.Contains(t, rec.Body.String(), "Submit Response")
+
+		rec = get("en-US", "es")
+		assert.Contains(t, rec.Body.String(), "Enviar respuesta")
+	})
+
+	t.Run("unsupported cookie is ignored", func(t *testing.T) {
+		rec := get("es", "fr")
+		assert.Contains(t, rec.Body.String(), "Enviar respuesta")
+	})
+}
+
+func TestSetLanguage(t *testing.T) {
+	set := func(tag, referer string) *httptest.ResponseRecorder {
+		e, _, h := setupTest()
+		req := httptest.NewRequest(http.MethodGet, "/language/"+tag, nil)
+		if referer != "" {
+			req.Header.Set("Referer", referer)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("tag")
+		c.SetParamValues(tag)
+		require.NoError(t, h.SetLanguage(c))
+		return rec
+	}
+
+	t.Run("sets the cookie and returns to the page", func(t *testing.T) {
+		rec := set("es", "http://example.com/surveys/lunch/results?x=1")
+
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/surveys/lunch/results?x=1", rec.Header().Get("Location"))
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, templates.LocaleCookie, cookies[0].Name)
+		assert.Equal(t, "es", cookies[0].Value)
+		assert.Equal(t, "/", cookies[0].Path)
+		assert.Equal(t, localeCookieMaxAge, cookies[0].MaxAge)
+	})
+
+	t.Run("another site's referer goes home", func(t *testing.T) {
+		rec := set("en", "https://evil.example.net/phish")
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/", rec.Header().Get("Location"))
+	})
+
+	t.Run("no referer goes home", func(t *testing.T) {
+		rec := set("en", "")
+		assert.Equal(t, "/", rec.Header().Get("Location"))
+	})
+
+	t.Run("unsupported language", func(t *testing.T) {
+		rec := set("fr", "http://example.com/")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Result().Cookies())
+	})
+}
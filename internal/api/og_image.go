@@ -0,0 +1,58 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/ogimage"
+	"github.com/openmeet-team/survey/internal/templates"
+)
+
+// OGImagesInterface renders survey Open Graph images, cached by key
+type OGImagesInterface interface {
+	Image(key string, card ogimage.Card) ([]byte, error)
+}
+
+// SetOGImages enables per-survey Open Graph images. Without it, survey
+// image URLs redirect to the default image.
+func (h *Handlers) SetOGImages(images OGImagesInterface) {
+	h.ogImages = images
+}
+
+// GetSurveyOGImage renders the image link previews show for a survey: its
+// title, author and question count. The image is cached by slug and
+// version, so an edit renders a new one. When it can't be rendered, the
+// request is redirected to the default image.
+// GET /surveys/:slug/og.png
+func (h *Handlers) GetSurveyOGImage(c echo.Context) error {
+	slug := c.Param("slug")
+
+	survey, err := h.queries.GetSurveyBySlug(c.Request().Context(), slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.String(http.StatusNotFound, "Survey not found")
+		}
+		return c.String(http.StatusInternalServerError, "Failed to load survey")
+	}
+
+	if h.ogImages == nil {
+		return c.Redirect(http.StatusFound, templates.DefaultOGImage)
+	}
+	card := ogimage.Card{
+		Title:     survey.Title,
+		Author:    h.authorHandle(c, survey),
+		Questions: len(survey.Definition.Questions),
+	}
+	image, err := h.ogImages.Image(fmt.Sprintf("%s:%d", survey.Slug, survey.Version), card)
+	if err != nil {
+		c.Logger().Errorf("Failed to render Open Graph image for survey %s: %v", slug, err)
+		return c.Redirect(http.StatusFound, templates.DefaultOGImage)
+	}
+
+	// The URL carries the version, so a cached image is never stale
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.Blob(http.StatusOK, "image/png", image)
+}
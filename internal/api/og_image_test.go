@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/ogimage"
+	"github.com/openmeet-team/survey/internal/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingOGImages returns image or err, recording what it was asked for
+type recordingOGImages struct {
+	image []byte
+	err   error
+	keys  []string
+	cards []ogimage.Card
+}
+
+func (r *recordingOGImages) Image(key string, card ogimage.Card) ([]byte, error) {
+	r.keys = append(r.keys, key)
+	r.cards = append(r.cards, card)
+	return r.image, r.err
+}
+
+func TestGetSurveyOGImage(t *testing.T) {
+	setup := func(t *testing.T) (*echo.Echo, *Handlers) {
+		e, mq, h := setupTest()
+		author := "did:plc:alice"
+		require.NoError(t, mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:        uuid.New(),
+			Slug:      "lunch",
+			Title:     "Lunch order",
+			AuthorDID: &author,
+			Version:   2,
+			Definition: models.SurveyDefinition{Questions: []models.Question{
+				{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}}},
+				{ID: "q2", Text: "When?", Type: models.QuestionTypeText},
+			}},
+		}))
+		require.NoError(t, mq.UpsertHandle(context.Background(), author, "alice.bsky.social"))
+		return e, h
+	}
+	get := func(e *echo.Echo, h *Handlers, slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/surveys/"+slug+"/og.png", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+		_ = h.GetSurveyOGImage(c)
+		return rec
+	}
+
+	t.Run("renders the survey's card", func(t *testing.T) {
+		e, h := setup(t)
+		renderer, err := ogimage.NewRenderer()
+		require.NoError(t, err)
+		h.SetOGImages(ogimage.NewCache(renderer, 10))
+
+		rec := get(e, h, "lunch")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "public")
+		img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, ogimage.Width, img.Bounds().Dx())
+		assert.Equal(t, ogimage.Height, img.Bounds().Dy())
+	})
+
+	t.Run("keys the cache by slug and version", func(t *testing.T) {
+		e, h := setup(t)
+		images := &recordingOGImages{image: []byte("png")}
+		h.SetOGImages(images)
+
+		rec := get(e, h, "lunch")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"lunch:2"}, images.keys)
+		assert.Equal(t, []ogimage.Card{{Title: "Lunch order", Author: "alice.bsky.social", Questions: 2}}, images.cards)
+	})
+
+	t.Run("falls back to the default image", func(t *testing.T) {
+		e, h := setup(t)
+		h.SetOGImages(&recordingOGImages{err: errors.New("no fonts")})
+
+		rec := get(e, h, "lunch")
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, templates.DefaultOGImage, rec.Header().Get(echo.HeaderLocation))
+
+		e, h = setup(t)
+		rec = get(e, h, "lunch")
+		assert.Equal(t, http.StatusFound, rec.Code, "without images configured")
+	})
+
+	t.Run("unknown survey", func(t *testing.T) {
+		e, h := setup(t)
+		h.SetOGImages(&recordingOGImages{image: []byte("png")})
+		assert.Equal(t, http.StatusNotFound, get(e, h, "nope").Code)
+	})
+}
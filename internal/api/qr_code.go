@@ -0,0 +1,81 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/skip2/go-qrcode"
+)
+
+// QR code sizes in pixels, for the size query parameter
+const (
+	DefaultQRCodeSize = 512
+	MinQRCodeSize     = 128
+	MaxQRCodeSize     = 1024
+)
+
+// SetSiteHost sets the public hostname survey URLs are built on, such as
+// survey.openmeet.net; like SERVER_HOST, it may be given as an https:// URL.
+// Without it, QR codes aren't available.
+func (h *Handlers) SetSiteHost(host string) {
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	h.siteHost = strings.TrimSuffix(host, "/")
+}
+
+// canonicalSurveyURL returns a survey page's public URL, or "" without a
+// site host
+func (h *Handlers) canonicalSurveyURL(slug string) string {
+	if h.siteHost == "" {
+		return ""
+	}
+	return "https://" + h.siteHost + "/surveys/" + slug
+}
+
+// qrCodeSize reads the size query parameter, clamped to the allowed range
+func qrCodeSize(param string) int {
+	size, err := strconv.Atoi(param)
+	if err != nil {
+		return DefaultQRCodeSize
+	}
+	return max(MinQRCodeSize, min(size, MaxQRCodeSize))
+}
+
+// GetSurveyQRCode renders a QR code for a survey's page, for printing on
+// slides and posters. It only encodes the canonical URL of a survey that
+// exists, never one taken from the request, so the Host header can't point
+// it elsewhere.
+// GET /surveys/:slug/qr.png?size=
+func (h *Handlers) GetSurveyQRCode(c echo.Context) error {
+	slug := c.Param("slug")
+	if err := models.ValidateSlug(slug); err != nil {
+		return c.String(http.StatusNotFound, "Survey not found")
+	}
+
+	survey, err := h.queries.GetSurveyBySlug(c.Request().Context(), slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.String(http.StatusNotFound, "Survey not found")
+		}
+		return c.String(http.StatusInternalServerError, "Failed to load survey")
+	}
+
+	target := h.canonicalSurveyURL(survey.Slug)
+	if target == "" {
+		return c.String(http.StatusNotFound, "QR codes are not available")
+	}
+	image, err := qrcode.Encode(target, qrcode.Medium, qrCodeSize(c.QueryParam("size")))
+	if err != nil {
+		c.Logger().Errorf("Failed to render QR code for survey %s: %v", slug, err)
+		return c.String(http.StatusInternalServerError, "Failed to render QR code")
+	}
+
+	// A slug's URL never changes, so neither does its code
+	c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	return c.Blob(http.StatusOK, "image/png", image)
+}
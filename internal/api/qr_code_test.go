@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSurveyQRCode(t *testing.T) {
+	setup := func(t *testing.T) (*echo.Echo, *Handlers) {
+		e, mq, h := setupTest()
+		require.NoError(t, mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:    uuid.New(),
+			Slug:  "lunch",
+			Title: "Lunch order",
+		}))
+		h.SetSiteHost("survey.example.com")
+		return e, h
+	}
+	get := func(e *echo.Echo, h *Handlers, target, slug string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("slug")
+		c.SetParamValues(slug)
+		_ = h.GetSurveyQRCode(c)
+		return rec
+	}
+
+	t.Run("renders the survey's QR code", func(t *testing.T) {
+		e, h := setup(t)
+		rec := get(e, h, "/surveys/lunch/qr.png?size=256", "lunch")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Cache-Control"), "max-age=31536000")
+		img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+	})
+
+	t.Run("clamps size", func(t *testing.T) {
+		e, h := setup(t)
+		for target, want := range map[string]int{
+			"/surveys/lunch/qr.png":            DefaultQRCodeSize,
+			"/surveys/lunch/qr.png?size=10":    MinQRCodeSize,
+			"/surveys/lunch/qr.png?size=99999": MaxQRCodeSize,
+			"/surveys/lunch/qr.png?size=big":   DefaultQRCodeSize,
+		} {
+			rec := get(e, h, target, "lunch")
+			require.Equal(t, http.StatusOK, rec.Code, target)
+			img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, want, img.Bounds().Dx(), target)
+		}
+	})
+
+	t.Run("unknown survey", func(t *testing.T) {
+		e, h := setup(t)
+		rec := get(e, h, "/surveys/nope/qr.png", "nope")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Header().Get("Cache-Control"))
+	})
+
+	t.Run("invalid slug", func(t *testing.T) {
+		e, h := setup(t)
+		rec := get(e, h, "/surveys/x/qr.png", "https://evil.example/")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("without a site host", func(t *testing.T) {
+		e, h := setup(t)
+		h.SetSiteHost("")
+		rec := get(e, h, "/surveys/lunch/qr.png", "lunch")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestCanonicalSurveyURL(t *testing.T) {
+	_, _, h := setupTest()
+	assert.Empty(t, h.canonicalSurveyURL("lunch"))
+
+	h.SetSiteHost("survey.example.com")
+	assert.Equal(t, "https://survey.example.com/surveys/lunch", h.canonicalSurveyURL("lunch"))
+
+	// SERVER_HOST may be given as a URL
+	for _, host := range []string{"https://survey.example.com/", "http://survey.example.com"} {
+		h.SetSiteHost(host)
+		assert.Equal(t, "https://survey.example.com/surveys/lunch", h.canonicalSurveyURL("lunch"), host)
+	}
+}
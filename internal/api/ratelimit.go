@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/ratelimit"
 	"golang.org/x/time/rate"
 )
 
@@ -121,7 +122,10 @@ type RateLimiterConfig struct {
 	SurveyCreation *IPRateLimiter
 	VoteSubmission *IPRateLimiter
 	GeneralAPI     *IPRateLimiter
-	OAuth          *IPRateLimiter
+
+	// OAuth guards login and callback, which resolve handles and call the
+	// user's auth server, so it allows only a short burst
+	OAuth *ratelimit.Limiter
 }
 
 // NewRateLimiterConfig creates rate limiters with the specified limits
@@ -130,6 +134,11 @@ func NewRateLimiterConfig() *RateLimiterConfig {
 		SurveyCreation: NewIPRateLimiter(5, time.Minute),   // 5 requests per minute
 		VoteSubmission: NewIPRateLimiter(10, time.Minute),  // 10 requests per minute
 		GeneralAPI:     NewIPRateLimiter(60, time.Minute),  // 60 requests per minute
-		OAuth:          NewIPRateLimiter(10, time.Minute),  // 10 requests per minute
+		OAuth: ratelimit.New(ratelimit.Config{ // 10 requests per minute, 5 at once
+			Requests: 10,
+			Period:   time.Minute,
+			Burst:    5,
+			ClientIP: clientIPs,
+		}),
 	}
 }
@@ -242,3 +242,43 @@ func TestRateLimiting_XForwardedForHeader(t *testing.T) {
 
 	assert.Equal(t, 3, successCount)
 }
+
+// TestRateLimiting_OAuthEndpoints tests that login and callback share a
+// burst of 5 per client before returning 429 with Retry-After
+func TestRateLimiting_OAuthEndpoints(t *testing.T) {
+	e := echo.New()
+	config := NewRateLimiterConfig()
+
+	calls := 0
+	handler := func(c echo.Context) error {
+		calls++
+		return c.String(http.StatusOK, "ok")
+	}
+	oauthGroup := e.Group("/oauth")
+	oauthGroup.POST("/login", handler, config.OAuth.Middleware())
+	oauthGroup.GET("/callback", handler, config.OAuth.Middleware())
+
+	send := func(method, path, clientIP string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:12345" // Load balancer IP
+		req.Header.Set("X-Forwarded-For", clientIP)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send(http.MethodPost, "/oauth/login", "203.0.113.100").Code)
+	}
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, send(http.MethodGet, "/oauth/callback", "203.0.113.100").Code)
+	}
+
+	rec := send(http.MethodPost, "/oauth/login", "203.0.113.100")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Other clients behind the same load balancer are unaffected
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/oauth/callback", "203.0.113.200").Code)
+	assert.Equal(t, 6, calls)
+}
@@ -1,8 +1,6 @@
 package api
 
 import (
-	"database/sql"
-
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/openmeet-team/survey/internal/oauth"
@@ -11,7 +9,7 @@ import (
 )
 
 // SetupRoutes configures all API routes
-func SetupRoutes(e *echo.Echo, h *Handlers, hh *HealthHandlers, oh *oauth.Handlers, db *sql.DB) {
+func SetupRoutes(e *echo.Echo, h *Handlers, hh *HealthHandlers, oh *oauth.Handlers, storage *oauth.Storage) {
 	// Health check and metrics endpoints (no middleware)
 	e.GET("/health", hh.Health)
 	e.GET("/health/ready", hh.Readiness)
@@ -28,7 +26,6 @@ func SetupRoutes(e *echo.Echo, h *Handlers, hh *HealthHandlers, oh *oauth.Handle
 	e.Use(otelecho.Middleware("survey-api"))
 
 	// Create session middleware
-	storage := oauth.NewStorage(db)
 	sessionMiddleware := oauth.SessionMiddleware(storage)
 
 	// Create rate limiters
@@ -59,15 +56,45 @@ func SetupRoutes(e *echo.Echo, h *Handlers, hh *HealthHandlers, oh *oauth.Handle
 
 	// Survey management with rate limiting and body limits
 	api.POST("/surveys", h.CreateSurvey, rateLimiters.SurveyCreation.Middleware(), NewBodyLimitMiddleware(bodyLimits.SurveyCreation))
+	api.GET("/surveys", h.ListSurveys, rateLimiters.GeneralAPI.Middleware())
+	api.GET("/surveys/search", h.SearchSurveys, rateLimiters.GeneralAPI.Middleware())
 	api.GET("/surveys/:slug", h.GetSurvey, rateLimiters.GeneralAPI.Middleware())
-	api.POST("/surveys/generate", h.GenerateSurvey, rateLimiters.SurveyCreation.Middleware())
+	api.PUT("/surveys/:slug", h.UpdateSurvey, sessionMiddleware, rateLimiters.SurveyCreation.Middleware(), NewBodyLimitMiddleware(bodyLimits.SurveyCreation))
+	// Generation is open to anonymous users; the session puts signed-in users
+	// on their own rate limit and budget
+	api.POST("/surveys/generate", h.GenerateSurvey, sessionMiddleware, rateLimiters.SurveyCreation.Middleware())
+	api.POST("/surveys/generate/stream", h.StartGenerationStream, sessionMiddleware, rateLimiters.SurveyCreation.Middleware())
+	api.GET("/surveys/generate/stream", h.StreamGeneration, sessionMiddleware, rateLimiters.GeneralAPI.Middleware())
 
 	// Response submission and results with rate limiting and body limits
 	api.POST("/surveys/:slug/responses", h.SubmitResponse, rateLimiters.VoteSubmission.Middleware(), NewBodyLimitMiddleware(bodyLimits.ResponseSubmission))
 	api.GET("/surveys/:slug/results", h.GetResults, rateLimiters.GeneralAPI.Middleware())
+	api.GET("/surveys/:slug/results/:questionId/text", h.GetTextAnswers, rateLimiters.GeneralAPI.Middleware())
+	api.GET("/surveys/:slug/export.csv", h.ExportResponsesCSV, sessionMiddleware, rateLimiters.GeneralAPI.Middleware())
+	api.POST("/surveys/:slug/translate", h.TranslateSurvey, sessionMiddleware, rateLimiters.SurveyCreation.Middleware())
+	api.POST("/surveys/:slug/summarize", h.SummarizeTextAnswers, sessionMiddleware, rateLimiters.SurveyCreation.Middleware())
+
+	// Surveys by author; the session identifies the author to show them more
+	api.GET("/users/:did/surveys", h.ListUserSurveys, sessionMiddleware, rateLimiters.GeneralAPI.Middleware())
+
+	// The signed-in user's sessions across devices
+	api.GET("/sessions", h.ListSessions, sessionMiddleware, rateLimiters.GeneralAPI.Middleware())
+	api.DELETE("/sessions/:id", h.DeleteSession, sessionMiddleware, rateLimiters.GeneralAPI.Middleware())
+	api.POST("/sessions/revoke-all", h.RevokeAllSessions, sessionMiddleware, rateLimiters.GeneralAPI.Middleware())
+
+	// Admin API, bearer token required (see Handlers.SetAdmin)
+	admin := api.Group("/admin", h.RequireAdmin)
+	admin.GET("/ai-stats", h.GetAIStats, rateLimiters.GeneralAPI.Middleware())
+	admin.GET("/audit", h.GetAuditLog, rateLimiters.GeneralAPI.Middleware())
+	admin.GET("/ai-logs", h.GetAILogs, rateLimiters.GeneralAPI.Middleware())
+	admin.GET("/ai-prompt", h.GetAIPromptVersions, rateLimiters.GeneralAPI.Middleware())
 
-	// HTML routes (Templ handlers) - with session middleware
-	web := e.Group("", sessionMiddleware)
+	// Crawler files, which need neither sessions nor a language
+	e.GET("/robots.txt", h.GetRobotsTxt, rateLimiters.GeneralAPI.Middleware())
+	e.GET("/sitemap.xml", h.GetSitemap, rateLimiters.GeneralAPI.Middleware())
+
+	// HTML routes (Templ handlers) - with session and locale middleware
+	web := e.Group("", sessionMiddleware, LocaleMiddleware())
 
 	// Short URL routes with rate limiting
 	web.GET("/s/:slug", h.ShortSlugURL, rateLimiters.GeneralAPI.Middleware())
@@ -79,6 +106,9 @@ func SetupRoutes(e *echo.Echo, h *Handlers, hh *HealthHandlers, oh *oauth.Handle
 
 	// Survey viewing and voting with rate limiting and body limits
 	web.GET("/surveys/:slug", h.GetSurveyHTML, rateLimiters.GeneralAPI.Middleware())
+	web.GET("/surveys/:slug/og.png", h.GetSurveyOGImage, rateLimiters.GeneralAPI.Middleware())
+	web.GET("/surveys/:slug/embed", h.GetSurveyEmbedHTML, rateLimiters.GeneralAPI.Middleware())
+	web.GET("/surveys/:slug/qr.png", h.GetSurveyQRCode, rateLimiters.GeneralAPI.Middleware())
 	web.POST("/surveys/:slug/responses", h.SubmitResponseHTML, rateLimiters.VoteSubmission.Middleware(), NewBodyLimitMiddleware(bodyLimits.ResponseSubmission))
 
 	// Results with rate limiting
@@ -99,14 +129,24 @@ func SetupRoutes(e *echo.Echo, h *Handlers, hh *HealthHandlers, oh *oauth.Handle
 		oauthGroup.GET("/login", oh.LoginPage, rateLimiters.OAuth.Middleware())
 		oauthGroup.POST("/login", oh.Login, rateLimiters.OAuth.Middleware())
 		oauthGroup.GET("/callback", oh.Callback, rateLimiters.OAuth.Middleware())
-		oauthGroup.GET("/client-metadata.json", oh.ClientMetadata, rateLimiters.OAuth.Middleware())
-		oauthGroup.GET("/jwks.json", oh.JWKS, rateLimiters.OAuth.Middleware())
+		// Fetched by auth servers on every login, from a handful of IPs
+		oauthGroup.GET("/client-metadata.json", oh.ClientMetadata, rateLimiters.GeneralAPI.Middleware())
+		oauthGroup.GET("/jwks.json", oh.JWKS, rateLimiters.GeneralAPI.Middleware())
 		oauthGroup.POST("/logout", oh.Logout, rateLimiters.OAuth.Middleware())
 	}
 
 	// Landing page with statistics
 	web.GET("/", h.LandingPage, rateLimiters.GeneralAPI.Middleware())
 
+	// Keyword search over discoverable surveys
+	web.GET("/search", h.SearchSurveysHTML, rateLimiters.GeneralAPI.Middleware())
+
+	// Discoverable surveys, filtered and sorted
+	web.GET("/surveys", h.BrowseSurveysHTML, rateLimiters.GeneralAPI.Middleware())
+
+	// Language picked in the footer, overriding Accept-Language
+	web.GET("/language/:tag", h.SetLanguage, rateLimiters.GeneralAPI.Middleware())
+
 	// Legal pages
 	web.GET("/privacy", h.PrivacyPage, rateLimiters.GeneralAPI.Middleware())
 	web.GET("/terms", h.TermsPage, rateLimiters.GeneralAPI.Middleware())
@@ -1,9 +1,29 @@
 package api
 
 import (
+	"crypto/rand"
+
 	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/templates"
 )
 
+// contentSecurityPolicy is a balanced policy that allows common use cases
+// while maintaining security. Inline scripts only run if they carry nonce,
+// which pages stamp on theirs (see templates.Nonce). Styles still allow
+// 'unsafe-inline': pages use style attributes throughout, and Monaco adds
+// style elements at runtime without a nonce. It has no frame-ancestors;
+// X-Frame-Options covers framing, except on embed pages.
+func contentSecurityPolicy(nonce string) string {
+	return "default-src 'self'; " +
+		"script-src 'self' 'nonce-" + nonce + "' https://unpkg.com https://cdnjs.cloudflare.com https://*.posthog.com https://*.i.posthog.com; " + // Allow our inline scripts, HTMX, Monaco, and PostHog
+		"style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com; " + // unsafe-inline needed for inline styles, Monaco CSS from CDN
+		"img-src 'self' data: https:; " + // Allow images from same origin, data URIs, and HTTPS
+		"font-src 'self' data: https://cdnjs.cloudflare.com; " + // Allow fonts from same origin, data URIs, and Monaco fonts
+		"connect-src 'self' https://*.posthog.com https://*.i.posthog.com; " + // Allow PostHog analytics
+		"worker-src 'self' blob: https://cdnjs.cloudflare.com; " + // Allow PostHog web workers and Monaco workers
+		"base-uri 'self';" // An injected <base> can't point our script paths elsewhere
+}
+
 // SecurityHeadersMiddleware adds security headers to all responses
 // to protect against common web vulnerabilities
 func SecurityHeadersMiddleware() echo.MiddlewareFunc {
@@ -11,6 +31,12 @@ func SecurityHeadersMiddleware() echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			res := c.Response()
 
+			// A fresh nonce for each response, for templates to stamp on
+			// their inline scripts
+			nonce := rand.Text()
+			req := c.Request()
+			c.SetRequest(req.WithContext(templates.SetNonce(req.Context(), nonce)))
+
 			// Set security headers before calling next handler
 			// This ensures they're set even if handler errors
 
@@ -45,17 +71,8 @@ func SecurityHeadersMiddleware() echo.MiddlewareFunc {
 			}
 
 			// Content-Security-Policy: Protect against XSS and injection attacks
-			// This is a balanced policy that allows common use cases while maintaining security
 			if res.Header().Get("Content-Security-Policy") == "" {
-				csp := "default-src 'self'; " +
-					"script-src 'self' 'unsafe-inline' https://unpkg.com https://cdnjs.cloudflare.com https://*.posthog.com https://*.i.posthog.com; " + // Allow HTMX, Monaco, and PostHog
-					"style-src 'self' 'unsafe-inline' https://cdnjs.cloudflare.com; " + // unsafe-inline needed for inline styles, Monaco CSS from CDN
-					"img-src 'self' data: https:; " + // Allow images from same origin, data URIs, and HTTPS
-					"font-src 'self' data: https://cdnjs.cloudflare.com; " + // Allow fonts from same origin, data URIs, and Monaco fonts
-					"connect-src 'self' https://*.posthog.com https://*.i.posthog.com; " + // Allow PostHog analytics
-					"worker-src 'self' blob: https://cdnjs.cloudflare.com;" // Allow PostHog web workers and Monaco workers
-
-				res.Header().Set("Content-Security-Policy", csp)
+				res.Header().Set("Content-Security-Policy", contentSecurityPolicy(nonce))
 			}
 
 			// Call next handler
@@ -3,9 +3,11 @@ package api
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/templates"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -119,6 +121,33 @@ func TestSecurityHeadersMiddleware_CSP(t *testing.T) {
 	assert.Contains(t, csp, "font-src", "CSP should define font sources")
 }
 
+// TestSecurityHeadersMiddleware_CSPNonce verifies inline scripts are allowed
+// by a fresh nonce, the one templates stamp, rather than 'unsafe-inline'
+func TestSecurityHeadersMiddleware_CSPNonce(t *testing.T) {
+	e := echo.New()
+	serve := func() (csp, nonce string) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		handler := func(c echo.Context) error {
+			nonce = templates.Nonce(c.Request().Context())
+			return c.String(http.StatusOK, "test")
+		}
+		require.NoError(t, SecurityHeadersMiddleware()(handler)(c))
+		return rec.Header().Get("Content-Security-Policy"), nonce
+	}
+
+	csp, nonce := serve()
+	require.NotEmpty(t, nonce, "handlers should see the nonce")
+	assert.Contains(t, csp, "script-src 'self' 'nonce-"+nonce+"' ")
+	assert.NotContains(t, strings.Split(csp, "style-src")[0], "'unsafe-inline'", "script-src should not allow unsafe-inline")
+	assert.Contains(t, csp, "base-uri 'self';")
+
+	_, another := serve()
+	assert.NotEqual(t, nonce, another, "each response gets its own nonce")
+}
+
 // TestSecurityHeadersMiddleware_HTMLResponse verifies headers on HTML responses
 func TestSecurityHeadersMiddleware_HTMLResponse(t *testing.T) {
 	e := echo.New()
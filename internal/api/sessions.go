@@ -0,0 +1,171 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/oauth"
+)
+
+// ListSessions lists the signed-in user's active sessions
+// GET /api/v1/sessions
+func (h *Handlers) ListSessions(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+	if h.oauthStorage == nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "OAuth not configured"})
+	}
+
+	sessions, err := h.oauthStorage.GetSessionsByDID(c.Request().Context(), user.DID)
+	if err != nil {
+		return InternalServerError(c, "Failed to list sessions", err)
+	}
+
+	current := currentSessionID(c)
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = *ToSessionResponse(session, current)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// DeleteSession ends one of the signed-in user's sessions, identified by its
+// handle, revoking its refresh token at the issuer as logout does. Ending the
+// session making the request logs the user out.
+// DELETE /api/v1/sessions/:id
+func (h *Handlers) DeleteSession(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+	if h.oauthStorage == nil || h.oauthConfig == nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "OAuth not configured"})
+	}
+
+	ctx := c.Request().Context()
+	sessions, err := h.oauthStorage.GetSessionsByDID(ctx, user.DID)
+	if err != nil {
+		return InternalServerError(c, "Failed to list sessions", err)
+	}
+
+	handle := c.Param("id")
+	var id string
+	for _, session := range sessions {
+		if oauth.SessionHandle(session.ID) == handle {
+			id = session.ID
+			break
+		}
+	}
+	if id == "" {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Session not found"})
+	}
+
+	if err := oauth.RevokeUserSession(ctx, user.DID, id, h.oauthStorage, *h.oauthConfig); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Ended concurrently
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: "Session not found"})
+		}
+		return InternalServerError(c, "Failed to delete session", err)
+	}
+
+	if id == currentSessionID(c) {
+		loggedOut(c)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// revokeAllRecentLogin is how recently the requesting session must have been
+// created for RevokeAllSessions to skip asking for the handle
+const revokeAllRecentLogin = 5 * time.Minute
+
+// RevokeAllSessions ends every one of the signed-in user's sessions,
+// including this one, and revokes their refresh tokens at the issuer. The
+// user must re-enter their handle, unless they logged in within
+// revokeAllRecentLogin.
+// POST /api/v1/sessions/revoke-all
+func (h *Handlers) RevokeAllSessions(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+	if h.oauthStorage == nil || h.oauthConfig == nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "OAuth not configured"})
+	}
+
+	var req RevokeAllSessionsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+	}
+
+	if !h.revokeAllConfirmed(c, user.DID, req.Handle) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Confirmation required",
+			Details: "Re-enter your handle to log out everywhere",
+		})
+	}
+
+	ended, err := oauth.RevokeSessionsByDID(c.Request().Context(), user.DID, h.oauthStorage, *h.oauthConfig)
+	if err != nil {
+		return InternalServerError(c, "Failed to end sessions", err)
+	}
+
+	loggedOut(c)
+	return c.JSON(http.StatusOK, RevokeAllSessionsResponse{
+		Ended:   ended,
+		Message: fmt.Sprintf("Ended %d session(s). Log in again to continue.", ended),
+	})
+}
+
+// revokeAllConfirmed reports whether the user confirmed ending all their
+// sessions: handle, if given, must be their current handle; otherwise the
+// requesting session must be a recent login
+func (h *Handlers) revokeAllConfirmed(c echo.Context, did, handle string) bool {
+	if handle = strings.TrimPrefix(strings.TrimSpace(handle), "@"); handle != "" {
+		return strings.EqualFold(handle, h.handleForDID(c, did))
+	}
+
+	session, err := h.oauthStorage.GetSessionByID(c.Request().Context(), currentSessionID(c))
+	if err != nil {
+		return false
+	}
+	return session.DID == did && time.Since(session.CreatedAt) <= revokeAllRecentLogin
+}
+
+// loggedOut clears the session cookie after the requesting session has been
+// ended, sending htmx requests to the home page
+func loggedOut(c echo.Context) {
+	// Same cookie as oauth.Handlers.Logout clears
+	c.SetCookie(&http.Cookie{
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+	if c.Request().Header.Get("HX-Request") != "" {
+		c.Response().Header().Set("HX-Redirect", "/")
+	}
+}
+
+// currentSessionID returns the ID of the session making the request, or ""
+func currentSessionID(c echo.Context) string {
+	cookie, err := c.Cookie("session")
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
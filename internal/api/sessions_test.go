@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sessionColumns = []string{"id", "user_agent", "ip_address", "created_at", "last_used_at"}
+
+// setupSessionsTest returns handlers whose OAuth storage lists two sessions
+// for did:plc:alice, the laptop one making the requests
+func setupSessionsTest(t *testing.T) (*queriestest.DB, *Handlers) {
+	fake := queriestest.New(t)
+	now := time.Now()
+	fake.Expect("FROM oauth_sessions WHERE did = $1").Rows(sessionColumns,
+		[]interface{}{"laptop-session", "Firefox", "203.0.113.7", now.Add(-48 * time.Hour), now},
+		[]interface{}{"phone-session", "", "", now.Add(-24 * time.Hour), now.Add(-time.Hour)},
+	)
+	fake.Expect("DELETE FROM oauth_sessions").RowsAffected(1)
+	fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		// No refresh token, so there is nothing to revoke upstream
+		[]interface{}{"phone-session", "did:plc:alice", "access", "", "", "https://pds.example.com", nil, "", "", now, now.Add(time.Hour)},
+	)
+	config := &oauth.Config{Host: "survey.example.com", SecretJWK: "test-key"}
+	return fake, NewHandlersWithOAuth(NewMockQueries(), oauth.NewStorage(fake.DB), config)
+}
+
+func serveSessions(handler echo.HandlerFunc, method, id string, user *oauth.User) *httptest.ResponseRecorder {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/api/v1/sessions/"+id, nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "laptop-session"})
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	if user != nil {
+		c.Set("user", user)
+	}
+	_ = handler(c)
+	return rec
+}
+
+func TestListSessions(t *testing.T) {
+	fake, h := setupSessionsTest(t)
+
+	t.Run("requires sign in", func(t *testing.T) {
+		rec := serveSessions(h.ListSessions, http.MethodGet, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("lists the user's sessions", func(t *testing.T) {
+		rec := serveSessions(h.ListSessions, http.MethodGet, "", &oauth.User{DID: "did:plc:alice"})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var sessions []SessionResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sessions))
+		require.Len(t, sessions, 2)
+		assert.Equal(t, oauth.SessionHandle("laptop-session"), sessions[0].ID)
+		assert.True(t, sessions[0].Current)
+		assert.Equal(t, "Firefox", sessions[0].UserAgent)
+		assert.Equal(t, "203.0.113.7", sessions[0].IPAddress)
+		assert.False(t, sessions[1].Current)
+
+		assert.NotContains(t, rec.Body.String(), "laptop-session", "session IDs authenticate and must not be listed")
+		calls := fake.CallsMatching("FROM oauth_sessions WHERE did = $1")
+		require.NotEmpty(t, calls)
+		assert.Equal(t, []interface{}{"did:plc:alice"}, calls[len(calls)-1].Args)
+	})
+}
+
+func TestDeleteSession(t *testing.T) {
+	alice := &oauth.User{DID: "did:plc:alice"}
+
+	t.Run("requires sign in", func(t *testing.T) {
+		_, h := setupSessionsTest(t)
+		rec := serveSessions(h.DeleteSession, http.MethodDelete, oauth.SessionHandle("phone-session"), nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("unknown handle is not found", func(t *testing.T) {
+		fake, h := setupSessionsTest(t)
+		rec := serveSessions(h.DeleteSession, http.MethodDelete, oauth.SessionHandle("someone-elses-session"), alice)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, fake.CallsMatching("DELETE FROM oauth_sessions"))
+	})
+
+	t.Run("ends another session", func(t *testing.T) {
+		fake, h := setupSessionsTest(t)
+		rec := serveSessions(h.DeleteSession, http.MethodDelete, oauth.SessionHandle("phone-session"), alice)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Set-Cookie"))
+
+		deletes := fake.CallsMatching("DELETE FROM oauth_sessions WHERE id = $1 AND did = $2")
+		require.Len(t, deletes, 1)
+		assert.Equal(t, []interface{}{"phone-session", "did:plc:alice"}, deletes[0].Args)
+
+		// The session is loaded to revoke its refresh token before it's deleted
+		calls := fake.Calls()
+		require.GreaterOrEqual(t, len(calls), 2)
+		assert.Contains(t, calls[len(calls)-2].Query, "SELECT id, did, access_token, refresh_token")
+	})
+
+	t.Run("requires OAuth", func(t *testing.T) {
+		fake, _ := setupSessionsTest(t)
+		h := NewHandlersWithOAuth(NewMockQueries(), oauth.NewStorage(fake.DB), nil)
+		rec := serveSessions(h.DeleteSession, http.MethodDelete, oauth.SessionHandle("phone-session"), alice)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Empty(t, fake.CallsMatching("DELETE FROM oauth_sessions"))
+	})
+
+	t.Run("ending the current session logs out", func(t *testing.T) {
+		fake, h := setupSessionsTest(t)
+		rec := serveSessions(h.DeleteSession, http.MethodDelete, oauth.SessionHandle("laptop-session"), alice)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Contains(t, rec.Header().Get("Set-Cookie"), "session=;")
+		assert.Len(t, fake.CallsMatching("DELETE FROM oauth_sessions"), 1)
+	})
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	alice := &oauth.User{DID: "did:plc:alice"}
+	config := &oauth.Config{Host: "survey.example.com", SecretJWK: "test-key"}
+
+	// setup returns handlers whose OAuth storage holds three sessions for
+	// did:plc:alice, the laptop one (created at loginAt) making the requests
+	setup := func(t *testing.T, loginAt time.Time) (*queriestest.DB, *Handlers) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM oauth_sessions WHERE did").RowsAffected(3)
+		fake.Expect("SELECT id FROM oauth_sessions WHERE did").Rows([]string{"id"},
+			[]interface{}{"laptop-session"},
+		)
+		fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+			[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+			// No refresh token, so there is nothing to revoke upstream
+			[]interface{}{"laptop-session", "did:plc:alice", "access", "", "", "https://pds.example.com", nil, "", "", loginAt, time.Now().Add(time.Hour)},
+		)
+		mq := NewMockQueries()
+		mq.UpsertHandle(context.Background(), "did:plc:alice", "alice.bsky.social")
+		return fake, NewHandlersWithOAuth(mq, oauth.NewStorage(fake.DB), config)
+	}
+
+	serve := func(h *Handlers, body string, user *oauth.User) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sessions/revoke-all", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.AddCookie(&http.Cookie{Name: "session", Value: "laptop-session"})
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if user != nil {
+			c.Set("user", user)
+		}
+		_ = h.RevokeAllSessions(c)
+		return rec
+	}
+
+	t.Run("requires sign in", func(t *testing.T) {
+		_, h := setup(t, time.Now().Add(-48*time.Hour))
+		rec := serve(h, `{"handle":"alice.bsky.social"}`, nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("requires OAuth", func(t *testing.T) {
+		fake, _ := setup(t, time.Now())
+		h := NewHandlersWithOAuth(NewMockQueries(), oauth.NewStorage(fake.DB), nil)
+		rec := serve(h, `{"handle":"alice.bsky.social"}`, alice)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("ends every session when the handle is re-entered", func(t *testing.T) {
+		fake, h := setup(t, time.Now().Add(-48*time.Hour))
+		rec := serve(h, `{"handle":"@Alice.bsky.social"}`, alice)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response RevokeAllSessionsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, int64(3), response.Ended)
+		assert.Contains(t, response.Message, "Ended 3 session(s)")
+
+		calls := fake.CallsMatching("DELETE FROM oauth_sessions WHERE did")
+		require.Len(t, calls, 1)
+		assert.Equal(t, []interface{}{"did:plc:alice"}, calls[0].Args)
+		assert.Contains(t, rec.Header().Get("Set-Cookie"), "session=;")
+	})
+
+	t.Run("rejects the wrong handle", func(t *testing.T) {
+		fake, h := setup(t, time.Now())
+		rec := serve(h, `{"handle":"mallory.bsky.social"}`, alice)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, fake.CallsMatching("DELETE FROM oauth_sessions"))
+	})
+
+	t.Run("skips the handle right after logging in", func(t *testing.T) {
+		_, h := setup(t, time.Now().Add(-time.Minute))
+		rec := serve(h, `{}`, alice)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("requires the handle for an older login", func(t *testing.T) {
+		fake, h := setup(t, time.Now().Add(-revokeAllRecentLogin-time.Minute))
+		rec := serve(h, `{}`, alice)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, fake.CallsMatching("DELETE FROM oauth_sessions"))
+	})
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/templates"
+)
+
+// SitemapMaxURLs is the most URLs one sitemap may list (sitemaps.org).
+// Past it, /sitemap.xml becomes an index of pages of this many.
+const SitemapMaxURLs = 50000
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// sitemapURL is a survey's <url> entry in a sitemap
+type sitemapURL struct {
+	XMLName xml.Name `xml:"url"`
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod"`
+}
+
+// sitemapRef is a page's <sitemap> entry in a sitemap index
+type sitemapRef struct {
+	XMLName xml.Name `xml:"sitemap"`
+	Loc     string   `xml:"loc"`
+}
+
+// siteURL returns the site's public origin, such as
+// https://survey.openmeet.net: the configured site host, or else the host
+// the request was made to
+func (h *Handlers) siteURL(c echo.Context) string {
+	if h.siteHost != "" {
+		return "https://" + h.siteHost
+	}
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+// GetRobotsTxt tells crawlers what they may index. While templates.NoIndex
+// is set, that's nothing; otherwise it's everything but the API, login and
+// personal pages, and the sitemap is listed.
+// GET /robots.txt
+func (h *Handlers) GetRobotsTxt(c echo.Context) error {
+	var robots strings.Builder
+	robots.WriteString("User-agent: *\n")
+	if templates.NoIndex {
+		robots.WriteString("Disallow: /\n")
+	} else {
+		robots.WriteString("Disallow: /api/\n")
+		robots.WriteString("Disallow: /oauth/\n")
+		robots.WriteString("Disallow: /my-data\n")
+		robots.WriteString("\nSitemap: " + h.siteURL(c) + "/sitemap.xml\n")
+	}
+	return c.String(http.StatusOK, robots.String())
+}
+
+// GetSitemap lists the surveys search engines may index, those their
+// authors made discoverable, with when each last changed. Up to
+// SitemapMaxURLs surveys it's one sitemap; past that it's a sitemap index
+// whose pages are fetched with ?page=N. Entries are written as they're read
+// from the database.
+// GET /sitemap.xml
+func (h *Handlers) GetSitemap(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	count, err := h.queries.CountSitemapSurveys(ctx)
+	if err != nil {
+		c.Logger().Errorf("Failed to count sitemap surveys: %v", err)
+		return c.String(http.StatusInternalServerError, "Failed to load sitemap")
+	}
+	pages := (count + SitemapMaxURLs - 1) / SitemapMaxURLs
+
+	page := 1
+	if param := c.QueryParam("page"); param != "" {
+		page, err = strconv.Atoi(param)
+		if err != nil || page < 1 || page > pages {
+			return c.String(http.StatusNotFound, "Sitemap page not found")
+		}
+	} else if pages > 1 {
+		return h.writeSitemapIndex(c, pages)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationXMLCharsetUTF8)
+	res.WriteHeader(http.StatusOK)
+	io.WriteString(res, xml.Header+`<urlset xmlns="`+sitemapNamespace+`">`+"\n")
+
+	site := h.siteURL(c)
+	enc := xml.NewEncoder(res)
+	err = h.queries.StreamSitemapSurveys(ctx, (page-1)*SitemapMaxURLs, SitemapMaxURLs, func(s db.SitemapSurvey) error {
+		if err := enc.Encode(sitemapURL{Loc: site + "/surveys/" + s.Slug, LastMod: s.UpdatedAt.UTC().Format(time.RFC3339)}); err != nil {
+			return err
+		}
+		_, err := io.WriteString(res, "\n")
+		return err
+	})
+	if err != nil {
+		// The status is already sent; the unclosed document tells crawlers
+		// it's incomplete
+		c.Logger().Errorf("Sitemap page %d cut short: %v", page, err)
+		return nil
+	}
+
+	io.WriteString(res, "</urlset>\n")
+	return nil
+}
+
+// writeSitemapIndex writes a sitemap index of the sitemap's pages
+func (h *Handlers) writeSitemapIndex(c echo.Context, pages int) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMEApplicationXMLCharsetUTF8)
+	res.WriteHeader(http.StatusOK)
+	io.WriteString(res, xml.Header+`<sitemapindex xmlns="`+sitemapNamespace+`">`+"\n")
+
+	site := h.siteURL(c)
+	enc := xml.NewEncoder(res)
+	for page := 1; page <= pages; page++ {
+		if err := enc.Encode(sitemapRef{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", site, page)}); err != nil {
+			return err
+		}
+		io.WriteString(res, "\n")
+	}
+
+	io.WriteString(res, "</sitemapindex>\n")
+	return nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRobotsTxt(t *testing.T) {
+	get := func(h *Handlers, e *echo.Echo) string {
+		req := httptest.NewRequest(http.MethodGet, "/robots.txt", nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, h.GetRobotsTxt(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	t.Run("blocks everything while indexing is off", func(t *testing.T) {
+		e, _, h := setupTest()
+		assert.Equal(t, "User-agent: *\nDisallow: /\n", get(h, e))
+	})
+
+	t.Run("lists the sitemap when indexing is on", func(t *testing.T) {
+		templates.SetNoIndex(false)
+		t.Cleanup(func() { templates.SetNoIndex(true) })
+		e, _, h := setupTest()
+		h.SetSiteHost("https://survey.openmeet.net/")
+
+		robots := get(h, e)
+		assert.NotContains(t, robots, "Disallow: /\n")
+		assert.Contains(t, robots, "Disallow: /api/\n")
+		assert.Contains(t, robots, "Sitemap: https://survey.openmeet.net/sitemap.xml\n")
+	})
+}
+
+func TestGetSitemap(t *testing.T) {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	add := func(mq *MockQueries, slug string, i int, discoverable bool) {
+		mq.CreateSurvey(context.Background(), &models.Survey{
+			ID:         uuid.New(),
+			Slug:       slug,
+			Definition: models.SurveyDefinition{Discoverable: discoverable},
+			CreatedAt:  created.Add(time.Duration(i) * time.Second),
+			UpdatedAt:  created.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	get := func(e *echo.Echo, h *Handlers, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, h.GetSitemap(e.NewContext(req, rec)))
+		return rec
+	}
+
+	t.Run("lists discoverable surveys", func(t *testing.T) {
+		e, mq, h := setupTest()
+		h.SetSiteHost("survey.openmeet.net")
+		add(mq, "lunch", 1, true)
+		add(mq, "private", 2, false)
+		add(mq, "retro", 3, true)
+		deleted := time.Now()
+		mq.surveys["retro"].DeletedAt = &deleted
+
+		rec := get(e, h, "/sitemap.xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, echo.MIMEApplicationXMLCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+
+		var sitemap struct {
+			XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+			URLs    []struct {
+				Loc     string `xml:"loc"`
+				LastMod string `xml:"lastmod"`
+			} `xml:"url"`
+		}
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &sitemap))
+		require.Len(t, sitemap.URLs, 1)
+		assert.Equal(t, "https://survey.openmeet.net/surveys/lunch", sitemap.URLs[0].Loc)
+		assert.Equal(t, "2025-03-01T13:00:00Z", sitemap.URLs[0].LastMod)
+	})
+
+	t.Run("uses the request's host without a site host", func(t *testing.T) {
+		e, mq, h := setupTest()
+		add(mq, "lunch", 1, true)
+
+		rec := get(e, h, "/sitemap.xml")
+		assert.Contains(t, rec.Body.String(), "<loc>http://example.com/surveys/lunch</loc>")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		e, _, h := setupTest()
+
+		rec := get(e, h, "/sitemap.xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, strings.HasSuffix(rec.Body.String(), `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+"\n</urlset>\n"))
+	})
+
+	t.Run("splits into an index of pages past the limit", func(t *testing.T) {
+		e, mq, h := setupTest()
+		for i := 0; i <= SitemapMaxURLs; i++ {
+			add(mq, fmt.Sprintf("survey-%d", i), i, true)
+		}
+
+		rec := get(e, h, "/sitemap.xml")
+		var index struct {
+			XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+			Sitemaps []struct {
+				Loc string `xml:"loc"`
+			} `xml:"sitemap"`
+		}
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &index))
+		require.Len(t, index.Sitemaps, 2)
+		assert.Equal(t, "http://example.com/sitemap.xml?page=1", index.Sitemaps[0].Loc)
+		assert.Equal(t, "http://example.com/sitemap.xml?page=2", index.Sitemaps[1].Loc)
+
+		// Oldest first, so the newest is alone on the second page
+		rec = get(e, h, "/sitemap.xml?page=2")
+		assert.Equal(t, 1, strings.Count(rec.Body.String(), "<url>"))
+		assert.Contains(t, rec.Body.String(), fmt.Sprintf("/surveys/survey-%d<", SitemapMaxURLs))
+
+		rec = get(e, h, "/sitemap.xml?page=1")
+		assert.Equal(t, SitemapMaxURLs, strings.Count(rec.Body.String(), "<url>"))
+
+		for _, page := range []string{"0", "3", "two"} {
+			rec = get(e, h, "/sitemap.xml?page="+page)
+			assert.Equal(t, http.StatusNotFound, rec.Code, page)
+		}
+	})
+}
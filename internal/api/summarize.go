@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/openmeet-team/survey/internal/telemetry"
+)
+
+const (
+	// maxSummarizedAnswers caps the answers read for a summary; later
+	// answers are left out
+	maxSummarizedAnswers = 2000
+
+	// summaryAnswerPage is how many answers are read per query
+	summaryAnswerPage = 100
+)
+
+// TextSummaryStoreInterface stores the latest AI summary of each text
+// question's answers
+type TextSummaryStoreInterface interface {
+	GetTextAnswerSummary(ctx context.Context, surveyID uuid.UUID, questionID string) (*models.TextAnswerSummary, error)
+	GetTextAnswerSummaries(ctx context.Context, surveyID uuid.UUID) (map[string]*models.TextAnswerSummary, error)
+	SaveTextAnswerSummary(ctx context.Context, s *models.TextAnswerSummary) error
+}
+
+// SetTextSummaries enables AI summaries of text answers, cached in store.
+// Summaries also need the generator (SetGenerator).
+func (h *Handlers) SetTextSummaries(store TextSummaryStoreInterface) {
+	h.summaries = store
+}
+
+// SummarizeTextAnswers summarizes the answers to one of a survey's text
+// questions with AI. Only the survey's author may ask. A summary is reused
+// until the question has more answers, so asking again costs nothing.
+// POST /api/v1/surveys/:slug/summarize
+func (h *Handlers) SummarizeTextAnswers(c echo.Context) error {
+	user := oauth.GetUser(c)
+	if user == nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required"})
+	}
+	if h.summaries == nil {
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+			Error: "AI summaries are not available",
+		})
+	}
+
+	var req SummarizeTextAnswersRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: err.Error(),
+		})
+	}
+	if !req.Consent {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error: "AI summaries require explicit consent for AI processing",
+		})
+	}
+
+	ctx := c.Request().Context()
+	slug := c.Param("slug")
+	survey, err := h.queries.GetSurveyBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Survey not found",
+				Details: fmt.Sprintf("No survey found with slug '%s'", slug),
+			})
+		}
+		return InternalServerError(c, "Failed to retrieve survey", err)
+	}
+	if survey.AuthorDID == nil || *survey.AuthorDID != user.DID {
+		return c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Forbidden",
+			Details: "Only the survey's author can summarize its answers",
+		})
+	}
+
+	var question *models.Question
+	for i := range survey.Definition.Questions {
+		if survey.Definition.Questions[i].ID == req.QuestionID {
+			question = &survey.Definition.Questions[i]
+		}
+	}
+	if question == nil || question.Type != models.QuestionTypeText {
+		return c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Question not found",
+			Details: fmt.Sprintf("Survey '%s' has no text question '%s'", slug, req.QuestionID),
+		})
+	}
+
+	answers, answerCount, err := h.allTextAnswers(ctx, survey, question.ID)
+	if err != nil {
+		return InternalServerError(c, "Failed to retrieve answers", err)
+	}
+	if len(answers) < models.MinSummaryAnswers {
+		return c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Not enough responses to summarize",
+			Details: fmt.Sprintf("Summaries need at least %d answers", models.MinSummaryAnswers),
+		})
+	}
+
+	cached, err := h.summaries.GetTextAnswerSummary(ctx, survey.ID, question.ID)
+	if err != nil {
+		c.Logger().Errorf("Failed to get text answer summary for %s: %v", survey.Slug, err)
+	}
+	if cached != nil && cached.ResponseCount == answerCount {
+		return c.JSON(http.StatusOK, SummarizeTextAnswersResponse{Summary: cached, Cached: true})
+	}
+
+	// Log the summary, and any rate limit it hits, as one
+	c.SetRequest(c.Request().WithContext(generator.WithKind(ctx, generator.KindSummarize)))
+	ctx = c.Request().Context()
+
+	// The answers aren't logged; they're responders' words, not the author's
+	description := fmt.Sprintf("Summarize %d answers to question %s of survey %s", len(answers), question.ID, survey.Slug)
+	job, err := h.admitGeneration(c, description)
+	if job == nil {
+		return err
+	}
+
+	// Summarize within the same deadline as a generation
+	summarizeCtx := ctx
+	if h.generationTimeout > 0 {
+		var cancel context.CancelFunc
+		summarizeCtx, cancel = context.WithTimeout(ctx, h.generationTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result, err := h.generator.Summarize(summarizeCtx, question.Text, answers)
+	duration := time.Since(start).Seconds()
+	durationMS := int(duration * 1000)
+	telemetry.AIGenerationDuration.Observe(duration)
+
+	if err != nil {
+		status := "error"
+		if errors.Is(summarizeCtx.Err(), context.DeadlineExceeded) {
+			status = "timeout"
+		}
+		telemetry.AIGenerationsTotal.WithLabelValues(status).Inc()
+		if h.generationLog != nil && !h.logFailedAttempts(c, job.userID, job.userType, description, result) {
+			var rawResponse, systemPrompt string
+			var inputTokens, outputTokens int
+			var costUSD float64
+			if result != nil {
+				rawResponse, systemPrompt = result.RawResponse, result.SystemPrompt
+				inputTokens, outputTokens, costUSD = result.InputTokens, result.OutputTokens, result.EstimatedCost
+			}
+			_ = h.generationLog.LogError(ctx, job.userID, job.userType, description, systemPrompt, rawResponse,
+				status, err.Error(), inputTokens, outputTokens, costUSD, durationMS)
+		}
+
+		c.Logger().Errorf("AI summary failed: %v", err)
+		if status == "timeout" {
+			return c.JSON(http.StatusGatewayTimeout, ErrorResponse{
+				Error:   "AI summary timed out",
+				Details: fmt.Sprintf("The summary took longer than %s. Try again later.", h.generationTimeout),
+			})
+		}
+		if errors.Is(err, generator.ErrCostLimitExceeded) {
+			return c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+				Error: "AI generation budget exceeded. Please try again later.",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "AI summary failed",
+			Details: err.Error(),
+		})
+	}
+
+	telemetry.AIGenerationsTotal.WithLabelValues("success").Inc()
+	telemetry.AITokensTotal.WithLabelValues("input").Add(float64(result.InputTokens))
+	telemetry.AITokensTotal.WithLabelValues("output").Add(float64(result.OutputTokens))
+	telemetry.AIDailyCostUSD.Add(result.EstimatedCost)
+	if h.generationLog != nil {
+		h.logFailedAttempts(c, job.userID, job.userType, description, result)
+		_ = h.generationLog.LogSuccess(ctx, job.userID, job.userType, description,
+			result.SystemPrompt, result.RawResponse, result, durationMS)
+	}
+
+	summary := &models.TextAnswerSummary{
+		SurveyID:      survey.ID,
+		QuestionID:    question.ID,
+		ResponseCount: answerCount,
+		Summary:       *result.Summary,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := h.summaries.SaveTextAnswerSummary(ctx, summary); err != nil {
+		c.Logger().Errorf("Failed to save text answer summary for %s: %v", survey.Slug, err)
+	}
+
+	return c.JSON(http.StatusOK, SummarizeTextAnswersResponse{
+		Summary:    summary,
+		TokensUsed: result.InputTokens + result.OutputTokens,
+		Cost:       result.EstimatedCost,
+	})
+}
+
+// allTextAnswers reads up to maxSummarizedAnswers answers to a survey's text
+// question, oldest first, and counts all of them, so a summary is redone when
+// answers arrive past the cap. Surveys never published to a PDS have none.
+func (h *Handlers) allTextAnswers(ctx context.Context, survey *models.Survey, questionID string) ([]string, int, error) {
+	var answers []string
+	if survey.URI == nil {
+		return answers, 0, nil
+	}
+	for len(answers) < maxSummarizedAnswers {
+		page, err := h.queries.GetTextAnswers(ctx, *survey.URI, questionID, summaryAnswerPage, len(answers))
+		if err != nil {
+			return nil, 0, err
+		}
+		answers = append(answers, page...)
+		if len(page) < summaryAnswerPage {
+			return answers, len(answers), nil
+		}
+	}
+
+	count, err := h.queries.CountTextAnswers(ctx, *survey.URI, questionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return answers, count, nil
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSummaryStore keeps text answer summaries in memory
+type mockSummaryStore struct {
+	summaries map[string]*models.TextAnswerSummary // question ID -> summary
+	saves     int
+}
+
+func (s *mockSummaryStore) GetTextAnswerSummary(_ context.Context, _ uuid.UUID, questionID string) (*models.TextAnswerSummary, error) {
+	return s.summaries[questionID], nil
+}
+
+func (s *mockSummaryStore) GetTextAnswerSummaries(_ context.Context, _ uuid.UUID) (map[string]*models.TextAnswerSummary, error) {
+	return s.summaries, nil
+}
+
+func (s *mockSummaryStore) SaveTextAnswerSummary(_ context.Context, summary *models.TextAnswerSummary) error {
+	s.summaries[summary.QuestionID] = summary
+	s.saves++
+	return nil
+}
+
+// slowSummarizer summarizes until its context is done
+type slowSummarizer struct {
+	*MockSurveyGenerator
+}
+
+func (slowSummarizer) Summarize(ctx context.Context, question string, answers []string) (*generator.GenerateResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSummarizeTextAnswers(t *testing.T) {
+	author := "did:plc:author"
+	summary := &models.TextSummary{
+		Overview: "People want better coffee.",
+		Themes:   []models.SummaryTheme{{Theme: "Coffee", Summary: "It's bad.", Count: 5}},
+	}
+
+	setup := func(answers int, result *generator.GenerateResult, err error) (*echo.Echo, *Handlers, *MockSurveyGenerator, *mockSummaryStore, *capturingLogDB) {
+		e, mq, h := setupTest()
+		uri := "at://did:plc:author/net.openmeet.survey/retro"
+		survey := &models.Survey{
+			ID:        uuid.New(),
+			URI:       &uri,
+			AuthorDID: &author,
+			Slug:      "retro",
+			Title:     "Retro",
+			Definition: models.SurveyDefinition{Questions: []models.Question{
+				{ID: "q1", Text: "Pick one", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}}},
+				{ID: "q2", Text: "What would you change?", Type: models.QuestionTypeText},
+			}},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		mq.CreateSurvey(context.Background(), survey)
+		base := time.Now().Add(-time.Hour)
+		for i := 0; i < answers; i++ {
+			session := fmt.Sprintf("session-%d", i)
+			mq.responsesBySurvey[survey.ID][session] = &models.Response{
+				ID:        uuid.New(),
+				SurveyID:  survey.ID,
+				Answers:   map[string]models.Answer{"q2": {Text: fmt.Sprintf("answer %d", i)}},
+				CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			}
+		}
+
+		gen := NewMockSurveyGenerator(result, err)
+		h.SetGenerator(gen, NewMockRateLimiter(true, true))
+		logs := &capturingLogDB{}
+		h.SetLogger(generator.NewGenerationLogger(logs))
+		store := &mockSummaryStore{summaries: map[string]*models.TextAnswerSummary{}}
+		h.SetTextSummaries(store)
+		return e, h, gen, store, logs
+	}
+
+	summarize := func(t *testing.T, e *echo.Echo, h *Handlers, user *oauth.User, body SummarizeTextAnswersRequest) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/surveys/retro/summarize", bytes.NewReader(raw))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/v1/surveys/:slug/summarize")
+		c.SetParamNames("slug")
+		c.SetParamValues("retro")
+		if user != nil {
+			c.Set("user", user)
+		}
+		require.NoError(t, h.SummarizeTextAnswers(c))
+		return rec
+	}
+
+	t.Run("summarizes, logs and caches", func(t *testing.T) {
+		e, h, gen, store, logs := setup(6, &generator.GenerateResult{Summary: summary, InputTokens: 300, OutputTokens: 80, EstimatedCost: 0.0001}, nil)
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp SummarizeTextAnswersResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.False(t, resp.Cached)
+		assert.Equal(t, 380, resp.TokensUsed)
+		assert.Equal(t, 6, resp.Summary.ResponseCount)
+		assert.Equal(t, *summary, resp.Summary.Summary)
+
+		assert.Equal(t, "What would you change?", gen.instruction)
+		assert.Len(t, gen.answers, 6)
+
+		require.Len(t, logs.logs, 1)
+		assert.Equal(t, generator.KindSummarize, logs.logs[0].Kind)
+		assert.Equal(t, "success", logs.logs[0].Status)
+		assert.Equal(t, 0.0001, logs.logs[0].CostUSD, "counts against the author's budget")
+		assert.NotContains(t, logs.logs[0].InputPrompt, "answer 0", "answers aren't logged")
+
+		// Asking again with no new answers reuses the summary
+		gen.answers = nil
+		rec = summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.True(t, resp.Cached)
+		assert.Nil(t, gen.answers, "the generator isn't called")
+		assert.Len(t, logs.logs, 1)
+		assert.Equal(t, 1, store.saves)
+	})
+
+	t.Run("a summary of fewer answers is redone", func(t *testing.T) {
+		e, h, gen, store, _ := setup(7, &generator.GenerateResult{Summary: summary}, nil)
+		store.summaries["q2"] = &models.TextAnswerSummary{QuestionID: "q2", ResponseCount: 6, Summary: *summary}
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, gen.answers, 7)
+		assert.Equal(t, 7, store.summaries["q2"].ResponseCount)
+	})
+
+	t.Run("answers past the cap still redo the summary", func(t *testing.T) {
+		e, h, gen, store, _ := setup(maxSummarizedAnswers+1, &generator.GenerateResult{Summary: summary}, nil)
+		store.summaries["q2"] = &models.TextAnswerSummary{QuestionID: "q2", ResponseCount: maxSummarizedAnswers, Summary: *summary}
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, gen.answers, maxSummarizedAnswers)
+		assert.Equal(t, maxSummarizedAnswers+1, store.summaries["q2"].ResponseCount)
+	})
+
+	t.Run("summarizes within the generation deadline", func(t *testing.T) {
+		e, h, gen, _, _ := setup(6, &generator.GenerateResult{Summary: summary}, nil)
+		h.SetGenerationTimeout(time.Minute)
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.False(t, gen.deadline.IsZero(), "the summary has a deadline")
+		assert.WithinDuration(t, time.Now().Add(time.Minute), gen.deadline, 5*time.Second)
+	})
+
+	t.Run("a timed out summary is logged as such", func(t *testing.T) {
+		e, h, gen, _, logs := setup(6, nil, nil)
+		h.SetGenerator(slowSummarizer{gen}, NewMockRateLimiter(true, true))
+		h.SetGenerationTimeout(10 * time.Millisecond)
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		require.Len(t, logs.logs, 1)
+		assert.Equal(t, "timeout", logs.logs[0].Status)
+	})
+
+	t.Run("too few answers short-circuit", func(t *testing.T) {
+		e, h, gen, _, logs := setup(4, &generator.GenerateResult{Summary: summary}, nil)
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Not enough responses to summarize")
+		assert.Nil(t, gen.answers)
+		assert.Empty(t, logs.logs)
+	})
+
+	t.Run("failures are logged", func(t *testing.T) {
+		e, h, _, store, logs := setup(5, &generator.GenerateResult{RawResponse: "not json"}, fmt.Errorf("invalid LLM output: bad"))
+
+		rec := summarize(t, e, h, &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true})
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+		require.Len(t, logs.logs, 1)
+		assert.Equal(t, "error", logs.logs[0].Status)
+		assert.Equal(t, generator.KindSummarize, logs.logs[0].Kind)
+		assert.Equal(t, "not json", logs.logs[0].RawResponse)
+		assert.Zero(t, store.saves)
+	})
+
+	rejected := []struct {
+		name   string
+		user   *oauth.User
+		body   SummarizeTextAnswersRequest
+		status int
+	}{
+		{"anonymous", nil, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true}, http.StatusUnauthorized},
+		{"not the author", &oauth.User{DID: "did:plc:other"}, SummarizeTextAnswersRequest{QuestionID: "q2", Consent: true}, http.StatusForbidden},
+		{"no consent", &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q2"}, http.StatusBadRequest},
+		{"not a text question", &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q1", Consent: true}, http.StatusNotFound},
+		{"unknown question", &oauth.User{DID: author}, SummarizeTextAnswersRequest{QuestionID: "q9", Consent: true}, http.StatusNotFound},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			e, h, gen, _, _ := setup(6, &generator.GenerateResult{Summary: summary}, nil)
+
+			rec := summarize(t, e, h, tt.user, tt.body)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Nil(t, gen.answers, "the generator isn't called")
+		})
+	}
+}
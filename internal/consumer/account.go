@@ -0,0 +1,106 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/openmeet-team/survey/internal/db"
+)
+
+// Account statuses sent by Jetstream when an account is not active
+const (
+	AccountStatusDeactivated = "deactivated"
+	AccountStatusDeleted     = "deleted"
+	AccountStatusTakendown   = "takendown"
+	AccountStatusSuspended   = "suspended"
+)
+
+// JetstreamAccount represents the account portion of a Jetstream message
+type JetstreamAccount struct {
+	Active bool   `json:"active"`
+	Did    string `json:"did"`
+	Seq    int64  `json:"seq,omitempty"`
+	Status string `json:"status,omitempty"` // Present when active is false
+	Time   string `json:"time,omitempty"`
+}
+
+// accountAction decides what to do with a DID's records for an account event
+func accountAction(account *JetstreamAccount) string {
+	switch {
+	case account.Active:
+		return db.AccountActionUnhide
+	case account.Status == AccountStatusDeleted:
+		return db.AccountActionPurge
+	default:
+		// Deactivated, taken down, suspended, or unknown: hide but keep the data
+		return db.AccountActionHide
+	}
+}
+
+// processAccountEvent hides, restores, or purges a DID's surveys, responses and comments.
+// Jetstream delivers account events for every account on the network, so an
+// audit row is only written when the DID actually had records indexed.
+func (p *Processor) processAccountEvent(ctx context.Context, msg *JetstreamMessage) error {
+	account := msg.Account
+	if account == nil {
+		return nil
+	}
+
+	did := account.Did
+	if did == "" {
+		did = msg.Did
+	}
+	if did == "" {
+		return nil
+	}
+
+	action := accountAction(account)
+
+	var result *db.AccountActionResult
+	var err error
+	switch action {
+	case db.AccountActionPurge:
+		result, err = p.queries.DeleteAllForDID(ctx, did)
+	case db.AccountActionHide:
+		result, err = p.queries.SetHiddenForDID(ctx, did, true)
+	default:
+		result, err = p.queries.SetHiddenForDID(ctx, did, false)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s records for %s: %w", action, did, err)
+	}
+
+	if result.Total() == 0 {
+		return nil // DID has nothing indexed (or nothing changed)
+	}
+
+	if result.SurveysAffected > 0 {
+		if err := p.queries.InvalidateSurveysByAuthor(ctx, did); err != nil {
+			return fmt.Errorf("failed to invalidate cached surveys: %w", err)
+		}
+	}
+
+	if err := p.queries.LogAccountAction(ctx, did, action, account.Status, result); err != nil {
+		return fmt.Errorf("failed to log account action: %w", err)
+	}
+
+	// Purges can't be undone, so they also go to the audit log
+	if action == db.AccountActionPurge {
+		details := db.AuditDetails(map[string]interface{}{
+			"status":           account.Status,
+			"surveysDeleted":   result.SurveysAffected,
+			"responsesDeleted": result.ResponsesAffected,
+			"commentsDeleted":  result.CommentsAffected,
+		})
+		if err := p.queries.RecordAudit(ctx, db.AuditActorSystem, db.AuditActionPurgeAccount, did, details); err != nil {
+			return err
+		}
+	}
+
+	AccountActions.WithLabelValues(action).Inc()
+	log.Printf("Account %s (%s): %s %d surveys, %d responses and %d comments",
+		did, account.Status, action, result.SurveysAffected, result.ResponsesAffected, result.CommentsAffected)
+
+	return nil
+}
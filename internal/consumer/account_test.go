@@ -0,0 +1,220 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestAccountAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		account *JetstreamAccount
+		want    string
+	}{
+		{"active account restores", &JetstreamAccount{Active: true}, db.AccountActionUnhide},
+		{"deleted account purges", &JetstreamAccount{Active: false, Status: AccountStatusDeleted}, db.AccountActionPurge},
+		{"deactivated account hides", &JetstreamAccount{Active: false, Status: AccountStatusDeactivated}, db.AccountActionHide},
+		{"taken down account hides", &JetstreamAccount{Active: false, Status: AccountStatusTakendown}, db.AccountActionHide},
+		{"unknown status hides", &JetstreamAccount{Active: false}, db.AccountActionHide},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accountAction(tt.account); got != tt.want {
+				t.Errorf("accountAction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessAccountEvent(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	// createAccountData creates a survey authored by did with a response from
+	// another voter, plus a response by did on someone else's survey
+	createAccountData := func(t *testing.T, did, suffix string) (*models.Survey, *models.Response) {
+		t.Helper()
+
+		definition := models.SurveyDefinition{
+			Questions: []models.Question{
+				{
+					ID:       "q1",
+					Text:     "Question 1?",
+					Type:     models.QuestionTypeSingle,
+					Required: true,
+					Options: []models.Option{
+						{ID: "a", Text: "Option A"},
+					},
+				},
+			},
+		}
+
+		own := &models.Survey{
+			ID:         uuid.New(),
+			URI:        stringPtr("at://" + did + "/net.openmeet.survey/" + suffix),
+			CID:        stringPtr("bafyacct-" + suffix),
+			AuthorDID:  stringPtr(did),
+			Slug:       "test-account-" + suffix,
+			Title:      "Account Survey",
+			Definition: definition,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, own); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+
+		other := &models.Survey{
+			ID:         uuid.New(),
+			URI:        stringPtr("at://did:plc:otherauthor/net.openmeet.survey/" + suffix),
+			CID:        stringPtr("bafyother-" + suffix),
+			AuthorDID:  stringPtr("did:plc:otherauthor"),
+			Slug:       "test-account-other-" + suffix,
+			Title:      "Other Survey",
+			Definition: definition,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, other); err != nil {
+			t.Fatalf("Failed to create other survey: %v", err)
+		}
+
+		vote := &models.Response{
+			ID:        uuid.New(),
+			SurveyID:  other.ID,
+			VoterDID:  stringPtr(did),
+			RecordURI: stringPtr("at://" + did + "/net.openmeet.survey.response/" + suffix),
+			RecordCID: stringPtr("bafyvote-" + suffix),
+			Answers: map[string]models.Answer{
+				"q1": {SelectedOptions: []string{"a"}},
+			},
+			CreatedAt: time.Now(),
+		}
+		if err := queries.CreateResponse(ctx, vote); err != nil {
+			t.Fatalf("Failed to create response: %v", err)
+		}
+
+		return own, vote
+	}
+
+	accountEvent := func(did string, active bool, status string) *JetstreamMessage {
+		return &JetstreamMessage{
+			Kind: "account",
+			Did:  did,
+			Account: &JetstreamAccount{
+				Active: active,
+				Did:    did,
+				Status: status,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+
+	countActions := func(t *testing.T, did string) int {
+		t.Helper()
+		var count int
+		err := database.QueryRow(`SELECT COUNT(*) FROM account_actions WHERE did = $1`, did).Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to count account actions: %v", err)
+		}
+		return count
+	}
+
+	t.Run("deactivation hides and reactivation restores", func(t *testing.T) {
+		did := "did:plc:acct-deactivate-" + uuid.New().String()[:8]
+		own, vote := createAccountData(t, did, uuid.New().String()[:8])
+
+		if err := processor.ProcessMessage(ctx, accountEvent(did, false, AccountStatusDeactivated)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		if _, err := queries.GetSurveyBySlug(ctx, own.Slug); err == nil {
+			t.Error("Hidden survey should not be found by slug")
+		}
+		count, err := queries.CountResponsesBySurvey(ctx, vote.SurveyID)
+		if err != nil {
+			t.Fatalf("Failed to count responses: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("Expected hidden response to be excluded, got %d responses", count)
+		}
+
+		// Data is kept, not purged
+		stillThere, err := queries.GetSurveyByURI(ctx, *own.URI)
+		if err != nil || stillThere == nil {
+			t.Fatalf("Hidden survey should still exist: %v", err)
+		}
+
+		if err := processor.ProcessMessage(ctx, accountEvent(did, true, "")); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		if _, err := queries.GetSurveyBySlug(ctx, own.Slug); err != nil {
+			t.Errorf("Restored survey should be found by slug: %v", err)
+		}
+		count, err = queries.CountResponsesBySurvey(ctx, vote.SurveyID)
+		if err != nil {
+			t.Fatalf("Failed to count responses: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected restored response to be counted, got %d responses", count)
+		}
+
+		if got := countActions(t, did); got != 2 {
+			t.Errorf("Expected 2 audit rows (hide, unhide), got %d", got)
+		}
+	})
+
+	t.Run("deletion purges surveys and responses", func(t *testing.T) {
+		did := "did:plc:acct-delete-" + uuid.New().String()[:8]
+		own, vote := createAccountData(t, did, uuid.New().String()[:8])
+
+		if err := processor.ProcessMessage(ctx, accountEvent(did, false, AccountStatusDeleted)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		if _, err := queries.GetSurveyByURI(ctx, *own.URI); err == nil {
+			t.Error("Survey should be purged")
+		}
+		gone, err := queries.GetResponseByRecordURI(ctx, *vote.RecordURI)
+		if err != nil {
+			t.Fatalf("Failed to check response: %v", err)
+		}
+		if gone != nil {
+			t.Error("Response should be purged")
+		}
+
+		if got := countActions(t, did); got != 1 {
+			t.Errorf("Expected 1 audit row for purge, got %d", got)
+		}
+
+		entries, err := queries.ListAuditEntries(ctx, db.AuditFilter{Action: db.AuditActionPurgeAccount, Target: did}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Actor != db.AuditActorSystem {
+			t.Errorf("Expected 1 audit log entry by the system, got %+v", entries)
+		}
+	})
+
+	t.Run("events for unknown DIDs are ignored", func(t *testing.T) {
+		did := "did:plc:acct-unknown-" + uuid.New().String()[:8]
+
+		if err := processor.ProcessMessage(ctx, accountEvent(did, false, AccountStatusDeleted)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		if got := countActions(t, did); got != 0 {
+			t.Errorf("Expected no audit rows for unknown DID, got %d", got)
+		}
+	})
+}
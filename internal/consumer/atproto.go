@@ -2,19 +2,36 @@ package consumer
 
 import (
 	"fmt"
-	"regexp"
+	"log"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/openmeet-team/survey/internal/models"
+	"golang.org/x/text/language"
 )
 
 // ParseSurveyRecord parses an ATProto survey record into our Survey model
 // Handles lexicon field mapping: name -> title, questions array with token types
-func ParseSurveyRecord(record map[string]interface{}) (*models.SurveyDefinition, string, string, error) {
+// lang is the canonical BCP-47 tag, or "" if the record has none or it's invalid
+// createdAt is nil if the record's createdAt is missing or malformed
+func ParseSurveyRecord(record map[string]interface{}) (*models.SurveyDefinition, string, string, string, *time.Time, error) {
+	return ParseSurveyRecordWithLimits(record, DefaultLimits())
+}
+
+// ParseSurveyRecordWithLimits is ParseSurveyRecord with explicit size limits.
+// Exceeding one returns a *LimitError. The serialized record size is checked
+// separately, before parsing (see Processor.ProcessMessage).
+func ParseSurveyRecordWithLimits(record map[string]interface{}, limits Limits) (*models.SurveyDefinition, string, string, string, *time.Time, error) {
+	limits = limits.withDefaults()
+
 	// Extract name (maps to our "title")
 	name, ok := record["name"].(string)
 	if !ok || name == "" {
-		return nil, "", "", fmt.Errorf("survey name is required")
+		return nil, "", "", "", nil, fmt.Errorf("survey name is required")
+	}
+	if err := limits.checkText("name", name); err != nil {
+		return nil, "", "", "", nil, err
 	}
 
 	// Extract description (optional)
@@ -22,6 +39,9 @@ func ParseSurveyRecord(record map[string]interface{}) (*models.SurveyDefinition,
 	if desc, hasDesc := record["description"].(string); hasDesc {
 		description = desc
 	}
+	if err := limits.checkText("description", description); err != nil {
+		return nil, "", "", "", nil, err
+	}
 
 	// Extract anonymous flag (optional, default false)
 	anonymous := false
@@ -29,37 +49,95 @@ func ParseSurveyRecord(record map[string]interface{}) (*models.SurveyDefinition,
 		anonymous = anonVal
 	}
 
+	// Extract allowMultipleResponses flag (optional, default false)
+	allowMultiple := false
+	if multiVal, hasMulti := record["allowMultipleResponses"].(bool); hasMulti {
+		allowMultiple = multiVal
+	}
+
+	// Extract allowComments flag (optional, default false)
+	allowComments := false
+	if commentsVal, hasComments := record["allowComments"].(bool); hasComments {
+		allowComments = commentsVal
+	}
+
+	// Extract discoverable flag (optional, default false)
+	discoverable := false
+	if discoverableVal, hasDiscoverable := record["discoverable"].(bool); hasDiscoverable {
+		discoverable = discoverableVal
+	}
+
+	// Extract pageSize (optional, default 0 for a single page)
+	pageSize, err := parseOptionalInt(record, "pageSize")
+	if err != nil {
+		return nil, "", "", "", nil, err
+	}
+	if pageSize < 0 {
+		return nil, "", "", "", nil, fmt.Errorf("pageSize must not be negative")
+	}
+
 	// Parse questions array
 	questionsRaw, ok := record["questions"].([]interface{})
 	if !ok || len(questionsRaw) == 0 {
-		return nil, "", "", fmt.Errorf("survey must have at least one question")
+		return nil, "", "", "", nil, fmt.Errorf("survey must have at least one question")
+	}
+	if err := checkCount(LimitQuestions, "questions", len(questionsRaw), limits.MaxQuestions); err != nil {
+		return nil, "", "", "", nil, err
 	}
 
 	questions := make([]models.Question, 0, len(questionsRaw))
 	for i, qRaw := range questionsRaw {
 		qObj, ok := qRaw.(map[string]interface{})
 		if !ok {
-			return nil, "", "", fmt.Errorf("question %d is not an object", i)
+			return nil, "", "", "", nil, fmt.Errorf("question %d is not an object", i)
 		}
 
-		question, err := parseQuestion(qObj, i)
+		question, err := parseQuestion(qObj, i, limits)
 		if err != nil {
-			return nil, "", "", err
+			return nil, "", "", "", nil, err
 		}
 
 		questions = append(questions, *question)
 	}
 
 	def := &models.SurveyDefinition{
-		Questions: questions,
-		Anonymous: anonymous,
+		Questions:              questions,
+		Anonymous:              anonymous,
+		AllowMultipleResponses: allowMultiple,
+		AllowComments:          allowComments,
+		Discoverable:           discoverable,
+		PageSize:               pageSize,
 	}
 
-	return def, name, description, nil
+	return def, name, description, parseLang(record), parseCreatedAt(record), nil
+}
+
+// parseLang returns the canonical form of the record's optional BCP-47 lang
+// field. A missing, unknown or malformed tag is dropped rather than failing
+// the record.
+func parseLang(record map[string]interface{}) string {
+	raw, ok := record["lang"].(string)
+	if !ok || raw == "" {
+		return ""
+	}
+
+	tag, err := language.Parse(raw)
+	if err != nil || tag == language.Und {
+		return ""
+	}
+	return tag.String()
+}
+
+// optionalLang converts a parsed lang to the survey's nullable lang column
+func optionalLang(lang string) *string {
+	if lang == "" {
+		return nil
+	}
+	return &lang
 }
 
 // parseQuestion parses a single question from ATProto format
-func parseQuestion(qObj map[string]interface{}, index int) (*models.Question, error) {
+func parseQuestion(qObj map[string]interface{}, index int, limits Limits) (*models.Question, error) {
 	// Extract question ID
 	id, ok := qObj["id"].(string)
 	if !ok || id == "" {
@@ -71,6 +149,9 @@ func parseQuestion(qObj map[string]interface{}, index int) (*models.Question, er
 	if !ok || text == "" {
 		return nil, fmt.Errorf("question %d: text is required", index)
 	}
+	if err := limits.checkText(fmt.Sprintf("question %d text", index), text); err != nil {
+		return nil, err
+	}
 
 	// Extract question type (with token prefix like "net.openmeet.survey#single")
 	typeRaw, ok := qObj["type"].(string)
@@ -90,13 +171,16 @@ func parseQuestion(qObj map[string]interface{}, index int) (*models.Question, er
 	// Parse options array (for choice questions)
 	var options []models.Option
 	if optionsRaw, hasOptions := qObj["options"].([]interface{}); hasOptions {
+		if err := checkCount(LimitOptions, fmt.Sprintf("question %d options", index), len(optionsRaw), limits.MaxOptionsPerQuestion); err != nil {
+			return nil, err
+		}
 		for j, optRaw := range optionsRaw {
 			optObj, ok := optRaw.(map[string]interface{})
 			if !ok {
 				return nil, fmt.Errorf("question %d, option %d: not an object", index, j)
 			}
 
-			option, err := parseOption(optObj, index, j)
+			option, err := parseOption(optObj, index, j, limits)
 			if err != nil {
 				return nil, err
 			}
@@ -105,17 +189,82 @@ func parseQuestion(qObj map[string]interface{}, index int) (*models.Question, er
 		}
 	}
 
+	// Parse scale and labels (for rating questions)
+	minValue, err := parseOptionalInt(qObj, "min")
+	if err != nil {
+		return nil, fmt.Errorf("question %d: %w", index, err)
+	}
+	maxValue, err := parseOptionalInt(qObj, "max")
+	if err != nil {
+		return nil, fmt.Errorf("question %d: %w", index, err)
+	}
+
+	// Selection cap (for multi-choice questions); other types ignore it
+	maxSelections, err := parseOptionalInt(qObj, "maxSelections")
+	if err != nil {
+		return nil, fmt.Errorf("question %d: %w", index, err)
+	}
+	if maxSelections < 0 {
+		return nil, fmt.Errorf("question %d: maxSelections must not be negative", index)
+	}
+	if questionType != string(models.QuestionTypeMulti) {
+		maxSelections = 0
+	} else if maxSelections > len(options) {
+		log.Printf("WARNING: question %d: maxSelections %d exceeds its %d options, clamping", index, maxSelections, len(options))
+		maxSelections = len(options)
+	}
+
+	// Page marker (optional, default false)
+	pageBreak, _ := qObj["pageBreak"].(bool)
+
+	var labels []string
+	if labelsRaw, hasLabels := qObj["labels"].([]interface{}); hasLabels {
+		if err := checkCount(LimitOptions, fmt.Sprintf("question %d labels", index), len(labelsRaw), limits.MaxOptionsPerQuestion); err != nil {
+			return nil, err
+		}
+		for j, labelRaw := range labelsRaw {
+			label, ok := labelRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("question %d, label %d: not a string", index, j)
+			}
+			if err := limits.checkText(fmt.Sprintf("question %d, label %d", index, j), label); err != nil {
+				return nil, err
+			}
+			labels = append(labels, label)
+		}
+	}
+
 	return &models.Question{
 		ID:       id,
 		Text:     text,
 		Type:     models.QuestionType(questionType),
 		Required: required,
 		Options:  options,
+		Min:      minValue,
+		Max:      maxValue,
+		Labels:   labels,
+
+		MaxSelections: maxSelections,
+		PageBreak:     pageBreak,
 	}, nil
 }
 
+// parseOptionalInt reads an integer field from a decoded JSON record.
+// JSON numbers decode as float64, so non-integral values are rejected.
+func parseOptionalInt(obj map[string]interface{}, field string) (int, error) {
+	raw, ok := obj[field]
+	if !ok {
+		return 0, nil
+	}
+	num, ok := raw.(float64)
+	if !ok || num != math.Trunc(num) {
+		return 0, fmt.Errorf("%s must be an integer", field)
+	}
+	return int(num), nil
+}
+
 // parseOption parses a single option from ATProto format
-func parseOption(optObj map[string]interface{}, qIndex, optIndex int) (*models.Option, error) {
+func parseOption(optObj map[string]interface{}, qIndex, optIndex int, limits Limits) (*models.Option, error) {
 	id, ok := optObj["id"].(string)
 	if !ok || id == "" {
 		return nil, fmt.Errorf("question %d, option %d: id is required", qIndex, optIndex)
@@ -125,10 +274,17 @@ func parseOption(optObj map[string]interface{}, qIndex, optIndex int) (*models.O
 	if !ok || text == "" {
 		return nil, fmt.Errorf("question %d, option %d: text is required", qIndex, optIndex)
 	}
+	if err := limits.checkText(fmt.Sprintf("question %d, option %d text", qIndex, optIndex), text); err != nil {
+		return nil, err
+	}
+
+	// Extract allowFreeText flag (optional, default false)
+	allowFreeText, _ := optObj["allowFreeText"].(bool)
 
 	return &models.Option{
-		ID:   id,
-		Text: text,
+		ID:            id,
+		Text:          text,
+		AllowFreeText: allowFreeText,
 	}, nil
 }
 
@@ -144,34 +300,35 @@ func stripTokenPrefix(tokenType string) string {
 
 // ParseResponseRecord parses an ATProto response record into our Answer model
 // Handles lexicon field mapping: selectedOptions (not "selected")
-func ParseResponseRecord(record map[string]interface{}) (string, map[string]models.Answer, error) {
+// createdAt is nil if the record's createdAt is missing or malformed
+func ParseResponseRecord(record map[string]interface{}) (string, map[string]models.Answer, *time.Time, error) {
 	// Extract subject (survey reference)
 	subject, ok := record["subject"].(map[string]interface{})
 	if !ok {
-		return "", nil, fmt.Errorf("subject is required")
+		return "", nil, nil, fmt.Errorf("subject is required")
 	}
 
 	surveyURI, ok := subject["uri"].(string)
 	if !ok || surveyURI == "" {
-		return "", nil, fmt.Errorf("subject.uri is required")
+		return "", nil, nil, fmt.Errorf("subject.uri is required")
 	}
 
 	// Parse answers array
 	answersRaw, ok := record["answers"].([]interface{})
 	if !ok || len(answersRaw) == 0 {
-		return "", nil, fmt.Errorf("answers array is required")
+		return "", nil, nil, fmt.Errorf("answers array is required")
 	}
 
 	answers := make(map[string]models.Answer)
 	for i, ansRaw := range answersRaw {
 		ansObj, ok := ansRaw.(map[string]interface{})
 		if !ok {
-			return "", nil, fmt.Errorf("answer %d is not an object", i)
+			return "", nil, nil, fmt.Errorf("answer %d is not an object", i)
 		}
 
 		questionID, ok := ansObj["questionId"].(string)
 		if !ok || questionID == "" {
-			return "", nil, fmt.Errorf("answer %d: questionId is required", i)
+			return "", nil, nil, fmt.Errorf("answer %d: questionId is required", i)
 		}
 
 		answer := models.Answer{}
@@ -180,13 +337,13 @@ func ParseResponseRecord(record map[string]interface{}) (string, map[string]mode
 		if selectedRaw, hasSelected := ansObj["selectedOptions"]; hasSelected {
 			selectedArr, ok := selectedRaw.([]interface{})
 			if !ok {
-				return "", nil, fmt.Errorf("answer %d: selectedOptions must be an array", i)
+				return "", nil, nil, fmt.Errorf("answer %d: selectedOptions must be an array", i)
 			}
 
 			for j, optRaw := range selectedArr {
 				optID, ok := optRaw.(string)
 				if !ok {
-					return "", nil, fmt.Errorf("answer %d, option %d: not a string", i, j)
+					return "", nil, nil, fmt.Errorf("answer %d, option %d: not a string", i, j)
 				}
 				answer.SelectedOptions = append(answer.SelectedOptions, optID)
 			}
@@ -196,60 +353,287 @@ func ParseResponseRecord(record map[string]interface{}) (string, map[string]mode
 		if textRaw, hasText := ansObj["text"]; hasText {
 			textStr, ok := textRaw.(string)
 			if !ok {
-				return "", nil, fmt.Errorf("answer %d: text must be a string", i)
+				return "", nil, nil, fmt.Errorf("answer %d: text must be a string", i)
 			}
 			answer.Text = textStr
 		}
 
+		// Parse otherText (free text for options that allow it), keyed by option ID
+		if otherRaw, hasOther := ansObj["otherText"]; hasOther {
+			otherObj, ok := otherRaw.(map[string]interface{})
+			if !ok {
+				return "", nil, nil, fmt.Errorf("answer %d: otherText must be an object", i)
+			}
+
+			answer.OtherText = make(map[string]string, len(otherObj))
+			for optID, textRaw := range otherObj {
+				textStr, ok := textRaw.(string)
+				if !ok {
+					return "", nil, nil, fmt.Errorf("answer %d: otherText for option %s must be a string", i, optID)
+				}
+				answer.OtherText[optID] = textStr
+			}
+		}
+
+		// Parse rating (for rating questions)
+		if _, hasRating := ansObj["rating"]; hasRating {
+			rating, err := parseOptionalInt(ansObj, "rating")
+			if err != nil {
+				return "", nil, nil, fmt.Errorf("answer %d: %w", i, err)
+			}
+			answer.Rating = &rating
+		}
+
 		answers[questionID] = answer
 	}
 
-	return surveyURI, answers, nil
+	return surveyURI, answers, parseCreatedAt(record), nil
 }
 
-// GenerateSlugFromTitle creates a URL-friendly slug from a survey title
-// Handles collisions by appending -2, -3, etc.
-var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+// ParseResultsRecord parses an ATProto survey results record
+// Returns: surveyURI, published tallies, finalizedAt
+// Only the subject is required. Tallies are nil if they are malformed, so the
+// record is still tracked; finalizedAt is nil if missing or malformed.
+func ParseResultsRecord(record map[string]interface{}) (string, *models.PublishedResults, *time.Time, error) {
+	// Extract subject (survey reference)
+	subject, ok := record["subject"].(map[string]interface{})
+	if !ok {
+		return "", nil, nil, fmt.Errorf("subject is required")
+	}
 
-func GenerateSlugFromTitle(title string) string {
-	// Convert to lowercase
-	slug := strings.ToLower(title)
+	surveyURI, ok := subject["uri"].(string)
+	if !ok || surveyURI == "" {
+		return "", nil, nil, fmt.Errorf("subject.uri is required")
+	}
 
-	// Replace non-alphanumeric characters with hyphens
-	slug = slugRegex.ReplaceAllString(slug, "-")
+	// Malformed tallies don't invalidate the record, we just can't show them
+	tallies, err := parseResultsTallies(record)
+	if err == nil {
+		tallies.SurveyURI = surveyURI
+	}
 
-	// Trim leading/trailing hyphens
-	slug = strings.Trim(slug, "-")
+	return surveyURI, tallies, parseDatetime(record, "finalizedAt"), nil
+}
 
-	// Ensure minimum length
-	if len(slug) < 3 {
-		slug = "survey-" + slug
+// parseResultsTallies parses totalVotes and questionResults from a results record
+func parseResultsTallies(record map[string]interface{}) (*models.PublishedResults, error) {
+	totalVotes, err := parseCount(record, "totalVotes")
+	if err != nil {
+		return nil, err
 	}
 
-	// Truncate to max length (50 chars)
-	if len(slug) > 50 {
-		slug = slug[:50]
-		// Trim trailing hyphen if we cut in the middle
-		slug = strings.TrimRight(slug, "-")
+	questionsRaw, ok := record["questionResults"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("questionResults array is required")
 	}
 
-	return slug
+	questionResults := make(map[string]*models.PublishedQuestionResult, len(questionsRaw))
+	for i, qRaw := range questionsRaw {
+		qObj, ok := qRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("question result %d is not an object", i)
+		}
+
+		questionID, ok := qObj["questionId"].(string)
+		if !ok || questionID == "" {
+			return nil, fmt.Errorf("question result %d: questionId is required", i)
+		}
+
+		textCount, err := parseCount(qObj, "textResponseCount")
+		if err != nil {
+			return nil, fmt.Errorf("question result %d: %w", i, err)
+		}
+
+		qResult := &models.PublishedQuestionResult{
+			QuestionID:        questionID,
+			TextResponseCount: textCount,
+		}
+
+		optionCounts, err := parseCountArray(qObj, "optionCounts")
+		if err != nil {
+			return nil, fmt.Errorf("question result %d: %w", i, err)
+		}
+		for _, entry := range optionCounts {
+			optionID, ok := entry.obj["optionId"].(string)
+			if !ok || optionID == "" {
+				return nil, fmt.Errorf("question result %d: optionId is required", i)
+			}
+			if qResult.OptionCounts == nil {
+				qResult.OptionCounts = make(map[string]int)
+			}
+			qResult.OptionCounts[optionID] = entry.count
+		}
+
+		ratingCounts, err := parseCountArray(qObj, "ratingCounts")
+		if err != nil {
+			return nil, fmt.Errorf("question result %d: %w", i, err)
+		}
+		for _, entry := range ratingCounts {
+			if _, ok := entry.obj["value"]; !ok {
+				return nil, fmt.Errorf("question result %d: rating value is required", i)
+			}
+			value, err := parseOptionalInt(entry.obj, "value")
+			if err != nil {
+				return nil, fmt.Errorf("question result %d: %w", i, err)
+			}
+			if qResult.RatingCounts == nil {
+				qResult.RatingCounts = make(map[int]int)
+			}
+			qResult.RatingCounts[value] = entry.count
+		}
+
+		questionResults[questionID] = qResult
+	}
+
+	return &models.PublishedResults{
+		TotalVotes:      totalVotes,
+		QuestionResults: questionResults,
+	}, nil
 }
 
-// ParseResultsRecord parses an ATProto survey results record
-// Returns: surveyURI, resultsCID
-func ParseResultsRecord(record map[string]interface{}) (string, error) {
+// countEntry is one {..., count} object from a results tally array
+type countEntry struct {
+	obj   map[string]interface{}
+	count int
+}
+
+// parseCountArray reads an optional array of objects that each carry a
+// required, non-negative count
+func parseCountArray(obj map[string]interface{}, field string) ([]countEntry, error) {
+	raw, ok := obj[field]
+	if !ok {
+		return nil, nil
+	}
+	arr, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array", field)
+	}
+
+	entries := make([]countEntry, 0, len(arr))
+	for j, entryRaw := range arr {
+		entryObj, ok := entryRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s %d: not an object", field, j)
+		}
+		if _, ok := entryObj["count"]; !ok {
+			return nil, fmt.Errorf("%s %d: count is required", field, j)
+		}
+		count, err := parseCount(entryObj, "count")
+		if err != nil {
+			return nil, fmt.Errorf("%s %d: %w", field, j, err)
+		}
+		entries = append(entries, countEntry{obj: entryObj, count: count})
+	}
+	return entries, nil
+}
+
+// parseCount reads an optional non-negative integer field
+func parseCount(obj map[string]interface{}, field string) (int, error) {
+	count, err := parseOptionalInt(obj, field)
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		return 0, fmt.Errorf("%s must not be negative", field)
+	}
+	return count, nil
+}
+
+// ParseCommentRecord parses an ATProto comment record
+// Returns: surveyURI, comment text (trimmed), createdAt
+// Text longer than models.MaxCommentLength bytes returns a *LimitError;
+// createdAt is nil if the record's createdAt is missing or malformed
+func ParseCommentRecord(record map[string]interface{}) (string, string, *time.Time, error) {
 	// Extract subject (survey reference)
 	subject, ok := record["subject"].(map[string]interface{})
 	if !ok {
-		return "", fmt.Errorf("subject is required")
+		return "", "", nil, fmt.Errorf("subject is required")
 	}
 
 	surveyURI, ok := subject["uri"].(string)
 	if !ok || surveyURI == "" {
-		return "", fmt.Errorf("subject.uri is required")
+		return "", "", nil, fmt.Errorf("subject.uri is required")
+	}
+
+	text, ok := record["text"].(string)
+	if !ok {
+		return "", "", nil, fmt.Errorf("text is required")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", "", nil, fmt.Errorf("text must not be empty")
+	}
+	if err := checkCount(LimitTextLength, "text", len(text), models.MaxCommentLength); err != nil {
+		return "", "", nil, err
+	}
+
+	return surveyURI, text, parseCreatedAt(record), nil
+}
+
+const (
+	// maxCreatedAtSkew is how far a record's createdAt may run ahead of the
+	// Jetstream event time before we treat it as bogus
+	maxCreatedAtSkew = 24 * time.Hour
+)
+
+// earliestCreatedAt predates every ATProto repo; anything older is bogus
+var earliestCreatedAt = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseCreatedAt reads a record's client-declared createdAt (RFC 3339).
+// Returns nil if it is missing or malformed.
+func parseCreatedAt(record map[string]interface{}) *time.Time {
+	return parseDatetime(record, "createdAt")
+}
+
+// parseSchedule reads a survey record's optional startsAt and endsAt, the
+// window it takes responses in. A malformed time is dropped, like a
+// malformed createdAt, but a window that closes before it opens is an error.
+func parseSchedule(record map[string]interface{}) (startsAt, endsAt *time.Time, err error) {
+	startsAt = parseDatetime(record, "startsAt")
+	endsAt = parseDatetime(record, "endsAt")
+	if err := models.ValidateSchedule(startsAt, endsAt); err != nil {
+		return nil, nil, err
+	}
+	return startsAt, endsAt, nil
+}
+
+// parseDatetime reads an RFC 3339 datetime field, normalized to UTC.
+// Returns nil if it is missing or malformed.
+func parseDatetime(record map[string]interface{}, field string) *time.Time {
+	raw, ok := record[field].(string)
+	if !ok || raw == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil
 	}
+	parsed = parsed.UTC()
+	return &parsed
+}
 
-	// We don't need to parse the actual results data - just track that results were published
-	return surveyURI, nil
+// resolveCreatedAt picks the created_at to store for a record: its declared
+// createdAt when plausible, otherwise the Jetstream event time (or now, if
+// the event has no time_us). Declared times more than maxCreatedAtSkew after
+// the event, or before earliestCreatedAt, are ignored so spam can't pin
+// itself to the top or bottom of time-ordered listings.
+func resolveCreatedAt(declared *time.Time, eventTimeUs int64, now time.Time) time.Time {
+	eventTime := resolveEventTime(eventTimeUs, now)
+
+	if declared == nil {
+		return eventTime
+	}
+	if declared.After(eventTime.Add(maxCreatedAtSkew)) || declared.Before(earliestCreatedAt) {
+		return eventTime
+	}
+	return *declared
+}
+
+// resolveEventTime converts a Jetstream time_us to a time, or returns now if
+// the event has none
+func resolveEventTime(eventTimeUs int64, now time.Time) time.Time {
+	if eventTimeUs > 0 {
+		return time.UnixMicro(eventTimeUs).UTC()
+	}
+	return now
 }
@@ -0,0 +1,619 @@
+package consumer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestParseSurveyRecordRating(t *testing.T) {
+	record := map[string]interface{}{
+		"$type": "net.openmeet.survey",
+		"name":  "Satisfaction",
+		"questions": []interface{}{
+			map[string]interface{}{
+				"id":     "q1",
+				"text":   "How satisfied are you?",
+				"type":   "net.openmeet.survey#rating",
+				"min":    float64(1),
+				"max":    float64(5),
+				"labels": []interface{}{"Awful", "Poor", "OK", "Good", "Great"},
+			},
+		},
+	}
+
+	def, _, _, _, _, err := ParseSurveyRecord(record)
+	if err != nil {
+		t.Fatalf("ParseSurveyRecord failed: %v", err)
+	}
+
+	q := def.Questions[0]
+	if q.Type != models.QuestionTypeRating {
+		t.Errorf("Expected type rating, got %q", q.Type)
+	}
+	if q.Min != 1 || q.Max != 5 {
+		t.Errorf("Expected scale 1-5, got %d-%d", q.Min, q.Max)
+	}
+	if len(q.Labels) != 5 || q.Labels[4] != "Great" {
+		t.Errorf("Expected 5 labels ending in Great, got %v", q.Labels)
+	}
+	if err := def.ValidateDefinition(); err != nil {
+		t.Errorf("Expected valid definition, got: %v", err)
+	}
+
+	t.Run("rejects non-integer scale", func(t *testing.T) {
+		record["questions"].([]interface{})[0].(map[string]interface{})["max"] = 4.5
+		if _, _, _, _, _, err := ParseSurveyRecord(record); err == nil {
+			t.Error("Expected error for non-integer max")
+		}
+	})
+}
+
+func TestParseSurveyRecordMaxSelections(t *testing.T) {
+	record := func(questionType string, maxSelections interface{}) map[string]interface{} {
+		question := map[string]interface{}{
+			"id":   "q1",
+			"text": "Pick your favourites",
+			"type": questionType,
+			"options": []interface{}{
+				map[string]interface{}{"id": "a", "text": "A"},
+				map[string]interface{}{"id": "b", "text": "B"},
+				map[string]interface{}{"id": "c", "text": "C"},
+			},
+		}
+		if maxSelections != nil {
+			question["maxSelections"] = maxSelections
+		}
+		return map[string]interface{}{
+			"$type":     "net.openmeet.survey",
+			"name":      "Favourites",
+			"questions": []interface{}{question},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		questionType  string
+		maxSelections interface{}
+		want          int
+	}{
+		{"absent means unlimited", "net.openmeet.survey#multi", nil, 0},
+		{"zero means unlimited", "net.openmeet.survey#multi", float64(0), 0},
+		{"cap within option count", "net.openmeet.survey#multi", float64(2), 2},
+		{"cap above option count is clamped", "net.openmeet.survey#multi", float64(5), 3},
+		{"ignored on single choice", "net.openmeet.survey#single", float64(2), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def, _, _, _, _, err := ParseSurveyRecord(record(tt.questionType, tt.maxSelections))
+			if err != nil {
+				t.Fatalf("ParseSurveyRecord failed: %v", err)
+			}
+			if got := def.Questions[0].MaxSelections; got != tt.want {
+				t.Errorf("Expected maxSelections %d, got %d", tt.want, got)
+			}
+			if err := def.ValidateDefinition(); err != nil {
+				t.Errorf("Expected valid definition, got: %v", err)
+			}
+		})
+	}
+
+	for _, bad := range []interface{}{float64(-1), 1.5, "2"} {
+		if _, _, _, _, _, err := ParseSurveyRecord(record("net.openmeet.survey#multi", bad)); err == nil {
+			t.Errorf("Expected error for maxSelections %v", bad)
+		}
+	}
+}
+
+func TestParseSurveyRecordLang(t *testing.T) {
+	tests := []struct {
+		name string
+		lang interface{}
+		want string
+	}{
+		{"missing", nil, ""},
+		{"simple", "es", "es"},
+		{"with region", "ja-JP", "ja-JP"},
+		{"canonicalized", "PT_br", "pt-BR"},
+		{"undetermined", "und", ""},
+		{"malformed", "not a language!", ""},
+		{"unknown language", "xx", ""},
+		{"wrong type", float64(42), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := map[string]interface{}{
+				"name": "Encuesta",
+				"questions": []interface{}{
+					map[string]interface{}{
+						"id":      "q1",
+						"text":    "¿Cuál?",
+						"type":    "net.openmeet.survey#single",
+						"options": []interface{}{map[string]interface{}{"id": "a", "text": "A"}, map[string]interface{}{"id": "b", "text": "B"}},
+					},
+				},
+			}
+			if tt.lang != nil {
+				record["lang"] = tt.lang
+			}
+
+			_, _, _, lang, _, err := ParseSurveyRecord(record)
+			if err != nil {
+				t.Fatalf("Expected a bad lang to be dropped, not fail the record: %v", err)
+			}
+			if lang != tt.want {
+				t.Errorf("Expected lang %q, got %q", tt.want, lang)
+			}
+		})
+	}
+}
+
+func TestParseResponseRecordRating(t *testing.T) {
+	record := map[string]interface{}{
+		"$type": "net.openmeet.survey.response",
+		"subject": map[string]interface{}{
+			"uri": "at://did:plc:author/net.openmeet.survey/abc",
+		},
+		"answers": []interface{}{
+			map[string]interface{}{
+				"questionId": "q1",
+				"rating":     float64(4),
+			},
+		},
+	}
+
+	_, answers, _, err := ParseResponseRecord(record)
+	if err != nil {
+		t.Fatalf("ParseResponseRecord failed: %v", err)
+	}
+	if answers["q1"].Rating == nil || *answers["q1"].Rating != 4 {
+		t.Errorf("Expected rating 4, got %v", answers["q1"].Rating)
+	}
+
+	t.Run("rejects non-integer rating", func(t *testing.T) {
+		record["answers"] = []interface{}{
+			map[string]interface{}{"questionId": "q1", "rating": "four"},
+		}
+		if _, _, _, err := ParseResponseRecord(record); err == nil {
+			t.Error("Expected error for non-numeric rating")
+		}
+	})
+}
+
+func TestParseResponseRecordOtherText(t *testing.T) {
+	record := map[string]interface{}{
+		"$type": "net.openmeet.survey.response",
+		"subject": map[string]interface{}{
+			"uri": "at://did:plc:author/net.openmeet.survey/abc",
+		},
+		"answers": []interface{}{
+			map[string]interface{}{
+				"questionId":      "q1",
+				"selectedOptions": []interface{}{"other"},
+				"otherText":       map[string]interface{}{"other": "Thursday"},
+			},
+		},
+	}
+
+	_, answers, _, err := ParseResponseRecord(record)
+	if err != nil {
+		t.Fatalf("ParseResponseRecord failed: %v", err)
+	}
+	if got := answers["q1"].OtherText["other"]; got != "Thursday" {
+		t.Errorf("Expected otherText Thursday, got %q", got)
+	}
+
+	t.Run("rejects otherText that isn't an object", func(t *testing.T) {
+		record["answers"] = []interface{}{
+			map[string]interface{}{"questionId": "q1", "selectedOptions": []interface{}{"other"}, "otherText": "Thursday"},
+		}
+		if _, _, _, err := ParseResponseRecord(record); err == nil {
+			t.Error("Expected error for non-object otherText")
+		}
+	})
+
+	t.Run("rejects non-string otherText values", func(t *testing.T) {
+		record["answers"] = []interface{}{
+			map[string]interface{}{"questionId": "q1", "selectedOptions": []interface{}{"other"}, "otherText": map[string]interface{}{"other": float64(4)}},
+		}
+		if _, _, _, err := ParseResponseRecord(record); err == nil {
+			t.Error("Expected error for non-string otherText value")
+		}
+	})
+}
+
+func TestParseSurveyRecordAllowFreeText(t *testing.T) {
+	record := map[string]interface{}{
+		"name": "Other",
+		"questions": []interface{}{
+			map[string]interface{}{
+				"id":   "q1",
+				"text": "Preferred day?",
+				"type": "net.openmeet.survey#single",
+				"options": []interface{}{
+					map[string]interface{}{"id": "mon", "text": "Monday"},
+					map[string]interface{}{"id": "other", "text": "Other", "allowFreeText": true},
+				},
+			},
+		},
+	}
+
+	def, _, _, _, _, err := ParseSurveyRecord(record)
+	if err != nil {
+		t.Fatalf("ParseSurveyRecord failed: %v", err)
+	}
+	options := def.Questions[0].Options
+	if options[0].AllowFreeText || !options[1].AllowFreeText {
+		t.Errorf("Expected only the other option to allow free text, got %+v", options)
+	}
+}
+
+func TestParseSurveyRecordDiscoverable(t *testing.T) {
+	for _, discoverable := range []interface{}{nil, true, false, "yes"} {
+		record := map[string]interface{}{
+			"name": "Findable",
+			"questions": []interface{}{
+				map[string]interface{}{
+					"id":      "q1",
+					"text":    "Which?",
+					"type":    "net.openmeet.survey#single",
+					"options": []interface{}{map[string]interface{}{"id": "a", "text": "A"}, map[string]interface{}{"id": "b", "text": "B"}},
+				},
+			},
+		}
+		if discoverable != nil {
+			record["discoverable"] = discoverable
+		}
+
+		def, _, _, _, _, err := ParseSurveyRecord(record)
+		if err != nil {
+			t.Fatalf("ParseSurveyRecord failed: %v", err)
+		}
+		if want := discoverable == true; def.Discoverable != want {
+			t.Errorf("discoverable=%v: expected Discoverable %v, got %v", discoverable, want, def.Discoverable)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	opens := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	closes := time.Date(2025, 3, 8, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		record   map[string]interface{}
+		startsAt *time.Time
+		endsAt   *time.Time
+		wantErr  bool
+	}{
+		{"none", map[string]interface{}{}, nil, nil, false},
+		{"both", map[string]interface{}{"startsAt": "2025-03-01T10:00:00+01:00", "endsAt": "2025-03-08T09:00:00Z"}, &opens, &closes, false},
+		{"closes only", map[string]interface{}{"endsAt": "2025-03-08T09:00:00Z"}, nil, &closes, false},
+		{"malformed is dropped", map[string]interface{}{"startsAt": "next week", "endsAt": "2025-03-08T09:00:00Z"}, nil, &closes, false},
+		{"closes before it opens", map[string]interface{}{"startsAt": "2025-03-08T09:00:00Z", "endsAt": "2025-03-01T09:00:00Z"}, nil, nil, true},
+		{"closes as it opens", map[string]interface{}{"startsAt": "2025-03-08T09:00:00Z", "endsAt": "2025-03-08T09:00:00Z"}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startsAt, endsAt, err := parseSchedule(tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !equalTimes(startsAt, tt.startsAt) || !equalTimes(endsAt, tt.endsAt) {
+				t.Errorf("Expected %v to %v, got %v to %v", tt.startsAt, tt.endsAt, startsAt, endsAt)
+			}
+		})
+	}
+}
+
+// equalTimes reports whether two optional times are both nil or equal
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestParseCreatedAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   map[string]interface{}
+		expected *time.Time
+	}{
+		{"missing", map[string]interface{}{}, nil},
+		{"empty", map[string]interface{}{"createdAt": ""}, nil},
+		{"not a string", map[string]interface{}{"createdAt": float64(1700000000)}, nil},
+		{"malformed", map[string]interface{}{"createdAt": "2024-03-01 12:00:00"}, nil},
+		{"date only", map[string]interface{}{"createdAt": "2024-03-01"}, nil},
+		{"valid", map[string]interface{}{"createdAt": "2024-03-01T12:00:00Z"}, ptrTime(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))},
+		{"offset normalized to UTC", map[string]interface{}{"createdAt": "2024-03-01T14:00:00+02:00"}, ptrTime(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCreatedAt(tt.record)
+			if tt.expected == nil {
+				if got != nil {
+					t.Errorf("Expected nil, got %v", *got)
+				}
+				return
+			}
+			if got == nil || !got.Equal(*tt.expected) || got.Location() != time.UTC {
+				t.Errorf("Expected %v, got %v", *tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("parsers return createdAt", func(t *testing.T) {
+		survey := map[string]interface{}{
+			"name":      "Survey",
+			"createdAt": "2024-03-01T12:00:00Z",
+			"questions": []interface{}{
+				map[string]interface{}{"id": "q1", "text": "Q?", "type": "net.openmeet.survey#text"},
+			},
+		}
+		if _, _, _, _, createdAt, err := ParseSurveyRecord(survey); err != nil || createdAt == nil {
+			t.Errorf("Expected survey createdAt, got %v (err %v)", createdAt, err)
+		}
+
+		response := map[string]interface{}{
+			"subject": map[string]interface{}{"uri": "at://did:plc:author/net.openmeet.survey/abc"},
+			"answers": []interface{}{
+				map[string]interface{}{"questionId": "q1", "text": "hi"},
+			},
+		}
+		if _, _, createdAt, err := ParseResponseRecord(response); err != nil || createdAt != nil {
+			t.Errorf("Expected nil response createdAt, got %v (err %v)", createdAt, err)
+		}
+	})
+}
+
+func TestResolveCreatedAt(t *testing.T) {
+	eventTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	eventTimeUs := eventTime.UnixMicro()
+	now := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		declared    *time.Time
+		eventTimeUs int64
+		expected    time.Time
+	}{
+		{"missing falls back to event time", nil, eventTimeUs, eventTime},
+		{"plausible past value is kept", ptrTime(eventTime.Add(-72 * time.Hour)), eventTimeUs, eventTime.Add(-72 * time.Hour)},
+		{"small clock skew is kept", ptrTime(eventTime.Add(time.Hour)), eventTimeUs, eventTime.Add(time.Hour)},
+		{"exactly 24h ahead is kept", ptrTime(eventTime.Add(24 * time.Hour)), eventTimeUs, eventTime.Add(24 * time.Hour)},
+		{"far future falls back to event time", ptrTime(eventTime.Add(24*time.Hour + time.Second)), eventTimeUs, eventTime},
+		{"ancient falls back to event time", ptrTime(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)), eventTimeUs, eventTime},
+		{"no event time falls back to now", nil, 0, now},
+		{"no event time checks skew against now", ptrTime(now.Add(48 * time.Hour)), 0, now},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveCreatedAt(tt.declared, tt.eventTimeUs, now)
+			if !got.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func testResultsRecord() map[string]interface{} {
+	return map[string]interface{}{
+		"$type": "net.openmeet.survey.results",
+		"subject": map[string]interface{}{
+			"uri": "at://did:plc:author/net.openmeet.survey/abc",
+			"cid": "bafy_survey",
+		},
+		"totalVotes": float64(5),
+		"questionResults": []interface{}{
+			map[string]interface{}{
+				"questionId": "q1",
+				"optionCounts": []interface{}{
+					map[string]interface{}{"optionId": "a", "count": float64(3)},
+					map[string]interface{}{"optionId": "b", "count": float64(2)},
+				},
+			},
+			map[string]interface{}{
+				"questionId":        "q2",
+				"textResponseCount": float64(4),
+			},
+			map[string]interface{}{
+				"questionId": "q3",
+				"ratingCounts": []interface{}{
+					map[string]interface{}{"value": float64(0), "count": float64(1)},
+					map[string]interface{}{"value": float64(5), "count": float64(4)},
+				},
+			},
+		},
+		"finalizedAt": "2025-03-08T12:00:00Z",
+	}
+}
+
+func TestParseResultsRecord(t *testing.T) {
+	t.Run("parses tallies", func(t *testing.T) {
+		surveyURI, tallies, finalizedAt, err := ParseResultsRecord(testResultsRecord())
+		if err != nil {
+			t.Fatalf("ParseResultsRecord failed: %v", err)
+		}
+		if surveyURI != "at://did:plc:author/net.openmeet.survey/abc" {
+			t.Errorf("Unexpected survey URI %s", surveyURI)
+		}
+		if finalizedAt == nil || !finalizedAt.Equal(time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected finalizedAt %v", finalizedAt)
+		}
+		if tallies == nil {
+			t.Fatal("Expected tallies")
+		}
+		if tallies.SurveyURI != surveyURI || tallies.TotalVotes != 5 {
+			t.Errorf("Unexpected tallies %+v", tallies)
+		}
+		if q1 := tallies.QuestionResults["q1"]; q1 == nil || q1.OptionCounts["a"] != 3 || q1.OptionCounts["b"] != 2 {
+			t.Errorf("Unexpected q1 tally %+v", q1)
+		}
+		if q2 := tallies.QuestionResults["q2"]; q2 == nil || q2.TextResponseCount != 4 {
+			t.Errorf("Unexpected q2 tally %+v", q2)
+		}
+		if q3 := tallies.QuestionResults["q3"]; q3 == nil || q3.RatingCounts[0] != 1 || q3.RatingCounts[5] != 4 {
+			t.Errorf("Unexpected q3 tally %+v", q3)
+		}
+	})
+
+	malformed := []struct {
+		name   string
+		mutate func(record map[string]interface{})
+	}{
+		{"missing questionResults", func(r map[string]interface{}) { delete(r, "questionResults") }},
+		{"questionResults not an array", func(r map[string]interface{}) { r["questionResults"] = "lots" }},
+		{"fractional totalVotes", func(r map[string]interface{}) { r["totalVotes"] = 2.5 }},
+		{"negative count", func(r map[string]interface{}) {
+			q := r["questionResults"].([]interface{})[0].(map[string]interface{})
+			q["optionCounts"] = []interface{}{map[string]interface{}{"optionId": "a", "count": float64(-1)}}
+		}},
+		{"count missing", func(r map[string]interface{}) {
+			q := r["questionResults"].([]interface{})[0].(map[string]interface{})
+			q["optionCounts"] = []interface{}{map[string]interface{}{"optionId": "a"}}
+		}},
+		{"missing questionId", func(r map[string]interface{}) {
+			delete(r["questionResults"].([]interface{})[1].(map[string]interface{}), "questionId")
+		}},
+		{"rating value missing", func(r map[string]interface{}) {
+			q := r["questionResults"].([]interface{})[2].(map[string]interface{})
+			q["ratingCounts"] = []interface{}{map[string]interface{}{"count": float64(1)}}
+		}},
+	}
+	for _, tt := range malformed {
+		t.Run("malformed tallies fall back: "+tt.name, func(t *testing.T) {
+			record := testResultsRecord()
+			tt.mutate(record)
+
+			surveyURI, tallies, _, err := ParseResultsRecord(record)
+			if err != nil {
+				t.Fatalf("Expected record to still parse, got %v", err)
+			}
+			if surveyURI == "" {
+				t.Error("Expected survey URI")
+			}
+			if tallies != nil {
+				t.Errorf("Expected nil tallies, got %+v", tallies)
+			}
+		})
+	}
+
+	t.Run("missing subject is an error", func(t *testing.T) {
+		record := testResultsRecord()
+		delete(record, "subject")
+		if _, _, _, err := ParseResultsRecord(record); err == nil {
+			t.Error("Expected error for missing subject")
+		}
+	})
+}
+
+func TestParseCommentRecord(t *testing.T) {
+	record := func() map[string]interface{} {
+		return map[string]interface{}{
+			"$type": "net.openmeet.survey.comment",
+			"subject": map[string]interface{}{
+				"uri": "at://did:plc:author/net.openmeet.survey/abc",
+				"cid": "bafyabc",
+			},
+			"text":      "  Could we add a Friday option?\n",
+			"createdAt": "2025-03-08T12:00:00Z",
+		}
+	}
+
+	t.Run("parses subject and text", func(t *testing.T) {
+		surveyURI, text, createdAt, err := ParseCommentRecord(record())
+		if err != nil {
+			t.Fatalf("ParseCommentRecord failed: %v", err)
+		}
+		if surveyURI != "at://did:plc:author/net.openmeet.survey/abc" {
+			t.Errorf("Unexpected survey URI %s", surveyURI)
+		}
+		if text != "Could we add a Friday option?" {
+			t.Errorf("Expected trimmed text, got %q", text)
+		}
+		if createdAt == nil || !createdAt.Equal(time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected createdAt %v", createdAt)
+		}
+	})
+
+	t.Run("text at the cap is accepted", func(t *testing.T) {
+		r := record()
+		r["text"] = strings.Repeat("a", models.MaxCommentLength)
+		if _, _, _, err := ParseCommentRecord(r); err != nil {
+			t.Errorf("Expected text of %d bytes to parse, got %v", models.MaxCommentLength, err)
+		}
+	})
+
+	t.Run("text over the cap is a limit error", func(t *testing.T) {
+		r := record()
+		r["text"] = strings.Repeat("a", models.MaxCommentLength+1)
+		_, _, _, err := ParseCommentRecord(r)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != LimitTextLength {
+			t.Errorf("Expected text_length LimitError, got %v", err)
+		}
+	})
+
+	invalid := []struct {
+		name   string
+		mutate func(record map[string]interface{})
+	}{
+		{"missing subject", func(r map[string]interface{}) { delete(r, "subject") }},
+		{"missing subject uri", func(r map[string]interface{}) { r["subject"] = map[string]interface{}{"cid": "bafyabc"} }},
+		{"missing text", func(r map[string]interface{}) { delete(r, "text") }},
+		{"text not a string", func(r map[string]interface{}) { r["text"] = float64(42) }},
+		{"blank text", func(r map[string]interface{}) { r["text"] = " \n\t" }},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			r := record()
+			tt.mutate(r)
+			if _, _, _, err := ParseCommentRecord(r); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestParseSurveyRecordPages(t *testing.T) {
+	record := map[string]interface{}{
+		"$type":    "net.openmeet.survey",
+		"name":     "Sign up",
+		"pageSize": float64(2),
+		"questions": []interface{}{
+			map[string]interface{}{"id": "q1", "text": "Name", "type": "net.openmeet.survey#text"},
+			map[string]interface{}{"id": "q2", "text": "Email", "type": "net.openmeet.survey#text", "pageBreak": true},
+		},
+	}
+
+	def, _, _, _, _, err := ParseSurveyRecord(record)
+	if err != nil {
+		t.Fatalf("ParseSurveyRecord failed: %v", err)
+	}
+	if def.PageSize != 2 {
+		t.Errorf("Expected pageSize 2, got %d", def.PageSize)
+	}
+	if def.Questions[0].PageBreak || !def.Questions[1].PageBreak {
+		t.Errorf("Expected a page break on q2 only, got %v and %v", def.Questions[0].PageBreak, def.Questions[1].PageBreak)
+	}
+
+	for _, bad := range []interface{}{float64(-1), 1.5} {
+		record["pageSize"] = bad
+		if _, _, _, _, _, err := ParseSurveyRecord(record); err == nil {
+			t.Errorf("Expected error for pageSize %v", bad)
+		}
+	}
+}
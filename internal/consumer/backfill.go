@@ -0,0 +1,163 @@
+package consumer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db"
+)
+
+const (
+	// DefaultCatchUpLag is how close to now a backfill must get before it exits
+	DefaultCatchUpLag = 30 * time.Second
+
+	// DefaultProgressInterval is how often a backfill logs its progress
+	DefaultProgressInterval = 10 * time.Second
+)
+
+// BackfillOptions replays history from a fixed cursor instead of resuming
+// from the stored one. Processing is idempotent (creates of records that are
+// already indexed become updates), so replaying over indexed data is safe.
+type BackfillOptions struct {
+	// From is the time_us to replay from. 0 replays everything Jetstream retains.
+	From int64
+
+	// CommitCursor rewinds the live jetstream_cursor row to From and writes
+	// progress to it. Off by default so a backfill never moves the live
+	// consumer's resumption point.
+	CommitCursor bool
+
+	// Actor is recorded in the audit log when CommitCursor rewinds the cursor.
+	// Defaults to db.AuditActorSystem.
+	Actor string
+
+	// CatchUpLag stops the backfill once an event is within this much of now.
+	// Defaults to DefaultCatchUpLag.
+	CatchUpLag time.Duration
+
+	// ProgressInterval is how often progress is logged. Defaults to DefaultProgressInterval.
+	ProgressInterval time.Duration
+}
+
+// backfillProgress tracks a backfill across reconnects
+type backfillProgress struct {
+	opts BackfillOptions
+
+	mu        sync.Mutex
+	startedAt time.Time
+	events    int64 // events read since start
+	lastTime  int64 // time_us of the last event read
+	watermark int64 // time_us every event up to which has been processed
+
+	// Rate since the previous report
+	reportedAt     time.Time
+	reportedEvents int64
+}
+
+func newBackfillProgress(opts BackfillOptions, now time.Time) *backfillProgress {
+	if opts.CatchUpLag <= 0 {
+		opts.CatchUpLag = DefaultCatchUpLag
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = DefaultProgressInterval
+	}
+	if opts.Actor == "" {
+		opts.Actor = db.AuditActorSystem
+	}
+	return &backfillProgress{
+		opts:       opts,
+		startedAt:  now,
+		reportedAt: now,
+	}
+}
+
+// cursor returns where to (re)connect: the processed watermark once there is
+// one, so a reconnect resumes rather than restarting the backfill
+func (b *backfillProgress) cursor() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.watermark > b.opts.From {
+		return b.watermark
+	}
+	return b.opts.From
+}
+
+// observe counts an event read from the stream and reports whether it is
+// within CatchUpLag of now
+func (b *backfillProgress) observe(timeUs int64, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events++
+	if timeUs <= 0 {
+		return false
+	}
+	b.lastTime = timeUs
+	return now.Sub(time.UnixMicro(timeUs)) <= b.opts.CatchUpLag
+}
+
+// advance records the processed watermark
+func (b *backfillProgress) advance(timeUs int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if timeUs > b.watermark {
+		b.watermark = timeUs
+	}
+}
+
+// snapshot returns the event count, events/sec since the last snapshot, and
+// the time of the last event read, then resets the rate window
+func (b *backfillProgress) snapshot(now time.Time) (events int64, rate float64, current time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.reportedAt).Seconds(); elapsed > 0 {
+		rate = float64(b.events-b.reportedEvents) / elapsed
+	}
+	b.reportedAt = now
+	b.reportedEvents = b.events
+
+	if b.lastTime > 0 {
+		current = time.UnixMicro(b.lastTime).UTC()
+	}
+	return b.events, rate, current
+}
+
+// report logs progress every ProgressInterval until ctx is cancelled
+func (b *backfillProgress) report(ctx context.Context) {
+	ticker := time.NewTicker(b.opts.ProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			events, rate, current := b.snapshot(now)
+			if current.IsZero() {
+				log.Printf("Backfill: %d events (%.0f events/sec), waiting for first event", events, rate)
+				continue
+			}
+			log.Printf("Backfill: %d events (%.0f events/sec), at %s (%s behind)",
+				events, rate, current.Format(time.RFC3339), now.Sub(current).Truncate(time.Second))
+		}
+	}
+}
+
+// summary logs the totals for a finished backfill
+func (b *backfillProgress) summary(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	elapsed := now.Sub(b.startedAt)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(b.events) / elapsed.Seconds()
+	}
+	log.Printf("Backfill finished: %d events in %s (%.0f events/sec), processed through time_us %d",
+		b.events, elapsed.Truncate(time.Second), rate, b.watermark)
+}
@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackfillProgress(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("applies defaults", func(t *testing.T) {
+		b := newBackfillProgress(BackfillOptions{From: 1}, now)
+		if b.opts.CatchUpLag != DefaultCatchUpLag {
+			t.Errorf("Expected catch-up lag %v, got %v", DefaultCatchUpLag, b.opts.CatchUpLag)
+		}
+		if b.opts.ProgressInterval != DefaultProgressInterval {
+			t.Errorf("Expected progress interval %v, got %v", DefaultProgressInterval, b.opts.ProgressInterval)
+		}
+	})
+
+	t.Run("resumes from watermark after reconnect", func(t *testing.T) {
+		b := newBackfillProgress(BackfillOptions{From: 1000}, now)
+		if got := b.cursor(); got != 1000 {
+			t.Errorf("Expected initial cursor 1000, got %d", got)
+		}
+
+		b.advance(5000)
+		b.advance(4000) // stale values are ignored
+		if got := b.cursor(); got != 5000 {
+			t.Errorf("Expected cursor 5000 after advance, got %d", got)
+		}
+	})
+
+	t.Run("replays from zero", func(t *testing.T) {
+		b := newBackfillProgress(BackfillOptions{From: 0}, now)
+		if got := b.cursor(); got != 0 {
+			t.Errorf("Expected cursor 0, got %d", got)
+		}
+	})
+
+	t.Run("detects catch-up within lag", func(t *testing.T) {
+		b := newBackfillProgress(BackfillOptions{CatchUpLag: time.Minute}, now)
+
+		old := now.Add(-time.Hour).UnixMicro()
+		if b.observe(old, now) {
+			t.Error("Event an hour old should not count as caught up")
+		}
+
+		recent := now.Add(-30 * time.Second).UnixMicro()
+		if !b.observe(recent, now) {
+			t.Error("Event 30s old should count as caught up with a 1m lag")
+		}
+	})
+
+	t.Run("ignores events without a timestamp", func(t *testing.T) {
+		b := newBackfillProgress(BackfillOptions{}, now)
+		if b.observe(0, now) {
+			t.Error("Event without time_us should not count as caught up")
+		}
+	})
+
+	t.Run("reports rate and current event time", func(t *testing.T) {
+		b := newBackfillProgress(BackfillOptions{}, now)
+		eventTime := now.Add(-time.Hour)
+		for i := 0; i < 50; i++ {
+			b.observe(eventTime.UnixMicro(), now)
+		}
+
+		events, rate, current := b.snapshot(now.Add(10 * time.Second))
+		if events != 50 {
+			t.Errorf("Expected 50 events, got %d", events)
+		}
+		if rate != 5 {
+			t.Errorf("Expected 5 events/sec, got %v", rate)
+		}
+		if !current.Equal(eventTime) {
+			t.Errorf("Expected current event time %v, got %v", eventTime, current)
+		}
+
+		// Rate is measured since the previous snapshot
+		b.observe(eventTime.UnixMicro(), now)
+		events, rate, _ = b.snapshot(now.Add(20 * time.Second))
+		if events != 51 {
+			t.Errorf("Expected 51 events, got %d", events)
+		}
+		if rate != 0.1 {
+			t.Errorf("Expected 0.1 events/sec, got %v", rate)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package consumer
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultBackoffBase is the delay before the first reconnect attempt
+	DefaultBackoffBase = time.Second
+
+	// DefaultBackoffMax caps the delay between reconnect attempts
+	DefaultBackoffMax = 2 * time.Minute
+
+	// DefaultBackoffJitter is the fraction of the delay applied as random jitter (±20%)
+	DefaultBackoffJitter = 0.2
+
+	// DefaultHealthyThreshold is how long a connection must stay up before the
+	// backoff resets to the base delay
+	DefaultHealthyThreshold = 30 * time.Second
+)
+
+// Backoff computes exponential reconnect delays with jitter.
+// It is not safe for concurrent use; RunWithReconnect owns a single instance.
+type Backoff struct {
+	Base             time.Duration
+	Max              time.Duration
+	Jitter           float64
+	HealthyThreshold time.Duration
+
+	attempt int
+	rand    func() float64 // returns [0.0, 1.0), overridable in tests
+}
+
+// NewBackoff creates a Backoff with the default reconnect policy
+func NewBackoff() *Backoff {
+	return &Backoff{
+		Base:             DefaultBackoffBase,
+		Max:              DefaultBackoffMax,
+		Jitter:           DefaultBackoffJitter,
+		HealthyThreshold: DefaultHealthyThreshold,
+		rand:             rand.Float64,
+	}
+}
+
+// Attempt returns the number of consecutive failed attempts since the last reset
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+// Next records a failed attempt and returns the delay to wait before retrying.
+// The delay doubles with each attempt up to Max, then ±Jitter is applied.
+func (b *Backoff) Next() time.Duration {
+	b.attempt++
+
+	delay := b.Base
+	for i := 1; i < b.attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+
+	if b.Jitter > 0 && b.rand != nil {
+		// Scale by a factor in [1-jitter, 1+jitter)
+		factor := 1 + b.Jitter*(2*b.rand()-1)
+		delay = time.Duration(float64(delay) * factor)
+	}
+
+	return delay
+}
+
+// Reset returns the backoff to its initial state
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// ConnectionEnded resets the backoff if the connection that just ended stayed
+// up for at least HealthyThreshold. Short-lived connections keep escalating so a
+// flapping endpoint doesn't get hammered.
+func (b *Backoff) ConnectionEnded(uptime time.Duration) {
+	if uptime >= b.HealthyThreshold {
+		b.Reset()
+	}
+}
+
+// sleepContext waits for the given duration or until the context is cancelled.
+// Returns false if the context was cancelled before the delay elapsed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,116 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestBackoff(randValue float64) *Backoff {
+	b := NewBackoff()
+	b.rand = func() float64 { return randValue }
+	return b
+}
+
+func TestBackoffNext(t *testing.T) {
+	t.Run("doubles from base without jitter", func(t *testing.T) {
+		// rand=0.5 yields a jitter factor of exactly 1.0
+		b := newTestBackoff(0.5)
+
+		expected := []time.Duration{
+			1 * time.Second,
+			2 * time.Second,
+			4 * time.Second,
+			8 * time.Second,
+			16 * time.Second,
+			32 * time.Second,
+			64 * time.Second,
+			120 * time.Second, // capped
+			120 * time.Second,
+		}
+
+		for i, want := range expected {
+			got := b.Next()
+			if got != want {
+				t.Errorf("attempt %d: expected %v, got %v", i+1, want, got)
+			}
+			if b.Attempt() != i+1 {
+				t.Errorf("expected attempt counter %d, got %d", i+1, b.Attempt())
+			}
+		}
+	})
+
+	t.Run("applies jitter within ±20%", func(t *testing.T) {
+		low := newTestBackoff(0)
+		if got := low.Next(); got != 800*time.Millisecond {
+			t.Errorf("expected 800ms at minimum jitter, got %v", got)
+		}
+
+		high := newTestBackoff(0.999999)
+		got := high.Next()
+		if got < 1199*time.Millisecond || got > 1200*time.Millisecond {
+			t.Errorf("expected ~1.2s at maximum jitter, got %v", got)
+		}
+	})
+
+	t.Run("jitter stays within bounds at the cap", func(t *testing.T) {
+		b := NewBackoff()
+		for i := 0; i < 50; i++ {
+			d := b.Next()
+			if d > time.Duration(float64(DefaultBackoffMax)*1.2) {
+				t.Fatalf("delay %v exceeds cap plus jitter", d)
+			}
+		}
+	})
+}
+
+func TestBackoffReset(t *testing.T) {
+	t.Run("healthy connection resets backoff", func(t *testing.T) {
+		b := newTestBackoff(0.5)
+		b.Next()
+		b.Next()
+		b.Next()
+
+		b.ConnectionEnded(DefaultHealthyThreshold)
+
+		if b.Attempt() != 0 {
+			t.Errorf("expected attempt counter reset to 0, got %d", b.Attempt())
+		}
+		if got := b.Next(); got != time.Second {
+			t.Errorf("expected base delay after reset, got %v", got)
+		}
+	})
+
+	t.Run("short-lived connection keeps escalating", func(t *testing.T) {
+		b := newTestBackoff(0.5)
+		b.Next()
+		b.Next()
+
+		b.ConnectionEnded(time.Second)
+
+		if got := b.Next(); got != 4*time.Second {
+			t.Errorf("expected escalated delay of 4s, got %v", got)
+		}
+	})
+}
+
+func TestSleepContext(t *testing.T) {
+	t.Run("returns early when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		start := time.Now()
+		if sleepContext(ctx, time.Minute) {
+			t.Error("expected sleepContext to report cancellation")
+		}
+		if time.Since(start) > time.Second {
+			t.Error("sleepContext did not return promptly after cancellation")
+		}
+	})
+
+	t.Run("completes when delay elapses", func(t *testing.T) {
+		if !sleepContext(context.Background(), time.Millisecond) {
+			t.Error("expected sleepContext to complete")
+		}
+	})
+}
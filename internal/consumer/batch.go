@@ -0,0 +1,244 @@
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+const (
+	// DefaultBatchInterval is how long a partial batch waits for more rows
+	DefaultBatchInterval = 20 * time.Millisecond
+
+	// MaxBatchSize keeps a batch's bind parameters (9 per row) well under
+	// Postgres's limit of 65535
+	MaxBatchSize = 1000
+)
+
+// BatchOptions configures batched response inserts. Batching is off unless
+// Size is at least 2.
+//
+// Each worker waits for its response's batch to commit before finishing the
+// event, so a batch only fills from concurrent workers: batching needs
+// Workers > 1, and a batch never holds more rows than there are workers.
+type BatchOptions struct {
+	// Size is the most rows written in one INSERT
+	Size int
+
+	// Interval is the longest a row waits for its batch to fill. Defaults to
+	// DefaultBatchInterval.
+	Interval time.Duration
+}
+
+// enabled reports whether batching is configured
+func (o BatchOptions) enabled() bool {
+	return o.Size > 1
+}
+
+// BatchOptionsFromEnv reads CONSUMER_BATCH_SIZE and CONSUMER_BATCH_INTERVAL
+// (a Go duration such as "20ms"). Batching is off when the size is unset.
+func BatchOptionsFromEnv() (BatchOptions, error) {
+	opts := BatchOptions{Interval: DefaultBatchInterval}
+
+	if raw := os.Getenv("CONSUMER_BATCH_SIZE"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > MaxBatchSize {
+			return BatchOptions{}, fmt.Errorf("invalid CONSUMER_BATCH_SIZE: %q (must be 1-%d)", raw, MaxBatchSize)
+		}
+		opts.Size = size
+	}
+
+	if raw := os.Getenv("CONSUMER_BATCH_INTERVAL"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+		if err != nil || interval <= 0 {
+			return BatchOptions{}, fmt.Errorf("invalid CONSUMER_BATCH_INTERVAL: %q", raw)
+		}
+		opts.Interval = interval
+	}
+
+	return opts, nil
+}
+
+// errBatcherClosed is returned for rows added after the batcher stopped
+var errBatcherClosed = errors.New("response batcher closed")
+
+// responseBatcher collects responses from concurrent workers and upserts them
+// in multi-row statements. add blocks until the row's batch has committed, so
+// the event isn't marked complete (and the cursor can't pass it) before its
+// row is stored. If a batch fails, its rows are retried one at a time so a
+// single bad row only fails its own event.
+type responseBatcher struct {
+	opts BatchOptions
+	ctx  context.Context
+
+	// writeBatch upserts every row or none; writeOne upserts a single row
+	writeBatch func(ctx context.Context, rs []*models.Response) ([]bool, error)
+	writeOne   func(ctx context.Context, r *models.Response) (bool, error)
+
+	items   chan *batchItem
+	quit    chan struct{}
+	stopped chan struct{}
+	release func() // frees the batcher's connection after it stops
+}
+
+type batchItem struct {
+	response *models.Response
+	result   chan batchResult // buffered; receives exactly one result
+}
+
+type batchResult struct {
+	inserted bool
+	err      error
+}
+
+// newResponseBatcher creates a batcher over the given write functions. Call
+// start to begin accepting rows.
+func newResponseBatcher(ctx context.Context, opts BatchOptions,
+	writeBatch func(ctx context.Context, rs []*models.Response) ([]bool, error),
+	writeOne func(ctx context.Context, r *models.Response) (bool, error)) *responseBatcher {
+	if opts.Size > MaxBatchSize {
+		opts.Size = MaxBatchSize
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultBatchInterval
+	}
+	return &responseBatcher{
+		opts:       opts,
+		ctx:        ctx,
+		writeBatch: writeBatch,
+		writeOne:   writeOne,
+		items:      make(chan *batchItem),
+		quit:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		release:    func() {},
+	}
+}
+
+// openResponseBatcher creates and starts a batcher that writes through its
+// own connection. Workers hold a connection for their event's transaction
+// while they wait on a batch, so sharing the pool could leave the batch
+// nothing to write with.
+func openResponseBatcher(ctx context.Context, database *sql.DB, opts BatchOptions) (*responseBatcher, error) {
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve batch connection: %w", err)
+	}
+	queries := db.NewQueries(conn)
+
+	b := newResponseBatcher(ctx, opts,
+		func(ctx context.Context, rs []*models.Response) (inserted []bool, err error) {
+			err = db.ExecWithRetry(ctx, db.OpResponseBatch, func() error {
+				return queries.WithTx(ctx, func(q *db.Queries) error {
+					inserted, err = q.UpsertResponses(ctx, rs)
+					return err
+				})
+			})
+			return inserted, err
+		},
+		queries.UpsertResponse,
+	)
+	b.release = func() { conn.Close() }
+	b.start()
+	return b, nil
+}
+
+// start launches the goroutine that fills and flushes batches
+func (b *responseBatcher) start() {
+	go b.run()
+}
+
+// add queues a response for the next batch and waits for it to be written.
+// inserted reports whether a new row was created, as for UpsertResponse.
+func (b *responseBatcher) add(ctx context.Context, r *models.Response) (inserted bool, err error) {
+	item := &batchItem{response: r, result: make(chan batchResult, 1)}
+
+	select {
+	case b.items <- item:
+	case <-b.quit:
+		return false, errBatcherClosed
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+
+	// Once accepted the row is always written, even during shutdown
+	result := <-item.result
+	return result.inserted, result.err
+}
+
+// close flushes the pending batch, stops the batcher and releases its
+// connection. Later calls to add fail with errBatcherClosed.
+func (b *responseBatcher) close() {
+	close(b.quit)
+	<-b.stopped
+	b.release()
+}
+
+// run collects rows until the batch is full or the oldest row has waited
+// Interval, then writes it
+func (b *responseBatcher) run() {
+	defer close(b.stopped)
+
+	var pending []*batchItem
+	timer := time.NewTimer(b.opts.Interval)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		if len(pending) > 0 {
+			b.flush(pending)
+			pending = nil
+		}
+	}
+
+	for {
+		select {
+		case item := <-b.items:
+			pending = append(pending, item)
+			if len(pending) == 1 {
+				timer.Reset(b.opts.Interval)
+			}
+			if len(pending) >= b.opts.Size {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-b.quit:
+			flush()
+			return
+		}
+	}
+}
+
+// flush writes a batch and delivers each row's result. A failed batch is
+// retried row by row so only the rows that fail on their own are reported.
+func (b *responseBatcher) flush(items []*batchItem) {
+	responses := make([]*models.Response, len(items))
+	for i, item := range items {
+		responses[i] = item.response
+	}
+	ResponseBatchSize.Observe(float64(len(items)))
+
+	inserted, err := b.writeBatch(b.ctx, responses)
+	if err == nil {
+		for i, item := range items {
+			item.result <- batchResult{inserted: inserted[i]}
+		}
+		return
+	}
+
+	log.Printf("WARNING: Batch insert of %d responses failed, retrying individually: %v", len(items), err)
+	ResponseBatchFallbacks.Inc()
+	for _, item := range items {
+		inserted, err := b.writeOne(b.ctx, item.response)
+		item.result <- batchResult{inserted: inserted, err: err}
+	}
+}
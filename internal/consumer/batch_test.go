@@ -0,0 +1,396 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// fakeBatchWriter records the batches written and fails rows whose voter DID
+// is in bad
+type fakeBatchWriter struct {
+	mu      sync.Mutex
+	batches []int
+	single  int
+	bad     map[string]bool
+}
+
+func (w *fakeBatchWriter) writeBatch(ctx context.Context, rs []*models.Response) ([]bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, r := range rs {
+		if w.bad[*r.VoterDID] {
+			return nil, errors.New("batch rejected")
+		}
+	}
+	w.batches = append(w.batches, len(rs))
+	inserted := make([]bool, len(rs))
+	for i := range inserted {
+		inserted[i] = true
+	}
+	return inserted, nil
+}
+
+func (w *fakeBatchWriter) writeOne(ctx context.Context, r *models.Response) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.bad[*r.VoterDID] {
+		return false, fmt.Errorf("bad row %s", *r.VoterDID)
+	}
+	w.single++
+	return true, nil
+}
+
+func (w *fakeBatchWriter) counts() ([]int, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]int(nil), w.batches...), w.single
+}
+
+func startTestBatcher(t *testing.T, w *fakeBatchWriter, opts BatchOptions) *responseBatcher {
+	t.Helper()
+	b := newResponseBatcher(context.Background(), opts, w.writeBatch, w.writeOne)
+	b.start()
+	t.Cleanup(func() {
+		select {
+		case <-b.quit:
+		default:
+			b.close()
+		}
+	})
+	return b
+}
+
+func batchTestResponse(surveyID uuid.UUID, voter string) *models.Response {
+	dedupKey := "did:" + voter
+	recordURI := "at://" + voter + "/net.openmeet.survey.response/batch"
+	cid := "bafy_batch_" + voter
+	return &models.Response{
+		ID:        uuid.New(),
+		SurveyID:  surveyID,
+		VoterDID:  &voter,
+		RecordURI: &recordURI,
+		RecordCID: &cid,
+		Answers:   map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}},
+		CreatedAt: time.Now(),
+		DedupKey:  &dedupKey,
+	}
+}
+
+// addConcurrently adds a response per voter from its own goroutine, as
+// workers would, and returns each voter's error
+func addConcurrently(b *responseBatcher, surveyID uuid.UUID, voters ...string) map[string]error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+	)
+	for _, voter := range voters {
+		wg.Add(1)
+		go func(voter string) {
+			defer wg.Done()
+			inserted, err := b.add(context.Background(), batchTestResponse(surveyID, voter))
+			if err == nil && !inserted {
+				err = errors.New("expected a new row")
+			}
+			mu.Lock()
+			errs[voter] = err
+			mu.Unlock()
+		}(voter)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestResponseBatcherFlushesFullBatch(t *testing.T) {
+	w := &fakeBatchWriter{}
+	// The interval never fires, so only a full batch can release the rows
+	b := startTestBatcher(t, w, BatchOptions{Size: 3, Interval: time.Hour})
+
+	errs := addConcurrently(b, uuid.New(), "did:plc:a", "did:plc:b", "did:plc:c")
+	for voter, err := range errs {
+		if err != nil {
+			t.Errorf("Row for %s failed: %v", voter, err)
+		}
+	}
+
+	if batches, single := w.counts(); len(batches) != 1 || batches[0] != 3 || single != 0 {
+		t.Errorf("Expected one batch of 3 and no per-row writes, got batches %v and %d per-row", batches, single)
+	}
+}
+
+func TestResponseBatcherFlushesAfterInterval(t *testing.T) {
+	w := &fakeBatchWriter{}
+	b := startTestBatcher(t, w, BatchOptions{Size: 100, Interval: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := b.add(context.Background(), batchTestResponse(uuid.New(), "did:plc:alone")); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a partial batch to flush after the interval, took %v", elapsed)
+	}
+
+	if batches, _ := w.counts(); len(batches) != 1 || batches[0] != 1 {
+		t.Errorf("Expected one batch of 1, got %v", batches)
+	}
+}
+
+func TestResponseBatcherFallsBackPerRow(t *testing.T) {
+	w := &fakeBatchWriter{bad: map[string]bool{"did:plc:bad": true}}
+	b := startTestBatcher(t, w, BatchOptions{Size: 3, Interval: time.Hour})
+
+	errs := addConcurrently(b, uuid.New(), "did:plc:a", "did:plc:bad", "did:plc:c")
+
+	if errs["did:plc:bad"] == nil {
+		t.Error("Expected the bad row to fail")
+	}
+	for _, voter := range []string{"did:plc:a", "did:plc:c"} {
+		if errs[voter] != nil {
+			t.Errorf("Expected %s to succeed despite the bad row, got %v", voter, errs[voter])
+		}
+	}
+	if batches, single := w.counts(); len(batches) != 0 || single != 2 {
+		t.Errorf("Expected the good rows written one at a time, got batches %v and %d per-row", batches, single)
+	}
+}
+
+func TestResponseBatcherClose(t *testing.T) {
+	w := &fakeBatchWriter{}
+	b := startTestBatcher(t, w, BatchOptions{Size: 10, Interval: time.Hour})
+
+	// A row waiting on a partial batch is written by close
+	done := make(chan error, 1)
+	go func() {
+		_, err := b.add(context.Background(), batchTestResponse(uuid.New(), "did:plc:pending"))
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	b.close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected pending row to be written on close, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pending row never returned")
+	}
+	if batches, _ := w.counts(); len(batches) != 1 {
+		t.Errorf("Expected the pending batch flushed, got %v", batches)
+	}
+
+	if _, err := b.add(context.Background(), batchTestResponse(uuid.New(), "did:plc:late")); !errors.Is(err, errBatcherClosed) {
+		t.Errorf("Expected errBatcherClosed after close, got %v", err)
+	}
+}
+
+func TestBatchOptionsFromEnv(t *testing.T) {
+	t.Setenv("CONSUMER_BATCH_SIZE", "")
+	t.Setenv("CONSUMER_BATCH_INTERVAL", "")
+	opts, err := BatchOptionsFromEnv()
+	if err != nil || opts.enabled() || opts.Interval != DefaultBatchInterval {
+		t.Errorf("Expected batching off by default, got %+v (err %v)", opts, err)
+	}
+
+	t.Setenv("CONSUMER_BATCH_SIZE", "50")
+	t.Setenv("CONSUMER_BATCH_INTERVAL", "5ms")
+	opts, err = BatchOptionsFromEnv()
+	if err != nil || opts.Size != 50 || opts.Interval != 5*time.Millisecond {
+		t.Errorf("Expected size 50 and 5ms, got %+v (err %v)", opts, err)
+	}
+
+	for _, bad := range []string{"0", "-1", "many", "1001"} {
+		t.Setenv("CONSUMER_BATCH_SIZE", bad)
+		if _, err := BatchOptionsFromEnv(); err == nil {
+			t.Errorf("Expected error for CONSUMER_BATCH_SIZE=%q", bad)
+		}
+	}
+	t.Setenv("CONSUMER_BATCH_SIZE", "")
+	for _, bad := range []string{"soon", "0s"} {
+		t.Setenv("CONSUMER_BATCH_INTERVAL", bad)
+		if _, err := BatchOptionsFromEnv(); err == nil {
+			t.Errorf("Expected error for CONSUMER_BATCH_INTERVAL=%q", bad)
+		}
+	}
+}
+
+// createBatchTestSurvey indexes a one-question survey and returns its ID and URI
+func createBatchTestSurvey(tb testing.TB, processor *Processor, run string) (uuid.UUID, string) {
+	tb.Helper()
+	ctx := context.Background()
+	author := "did:plc:batchauthor"
+	rkey := "batch-survey-" + run
+	surveyURI := "at://" + author + "/net.openmeet.survey/" + rkey
+
+	err := processor.ProcessMessage(ctx, &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey,
+			Record:     testSurveyRecord("Batch "+run, "q1"),
+		},
+		TimeUs: time.Now().UnixMicro(),
+	})
+	if err != nil {
+		tb.Fatalf("Failed to create survey: %v", err)
+	}
+	survey, err := processor.queries.GetSurveyByURI(ctx, surveyURI)
+	if err != nil {
+		tb.Fatalf("Failed to get survey: %v", err)
+	}
+	return survey.ID, surveyURI
+}
+
+// TestBatchedResponseIngestion runs response events through a real batcher
+func TestBatchedResponseIngestion(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	ctx := context.Background()
+	run := uuid.NewString()[:8]
+	processor := NewProcessor(queries)
+	surveyID, surveyURI := createBatchTestSurvey(t, processor, run)
+
+	batcher, err := openResponseBatcher(ctx, database, BatchOptions{Size: 4, Interval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to open batcher: %v", err)
+	}
+	defer batcher.close()
+	processor.batcher = batcher
+
+	t.Run("events commit through the batch", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				voter := fmt.Sprintf("did:plc:batchvoter%d%s", i, run)
+				errs <- processor.ProcessMessageInTx(ctx, testResponseMessage(voter, "resp-"+run, surveyURI, "a"))
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Errorf("Event failed: %v", err)
+			}
+		}
+
+		count, err := queries.CountResponsesBySurvey(ctx, surveyID)
+		if err != nil {
+			t.Fatalf("Failed to count responses: %v", err)
+		}
+		if count != 4 {
+			t.Errorf("Expected 4 responses, got %d", count)
+		}
+	})
+
+	t.Run("one bad row doesn't sink the batch", func(t *testing.T) {
+		// A response for a survey that doesn't exist violates the foreign key
+		errs := make(map[string]error)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, tc := range []struct {
+			voter    string
+			surveyID uuid.UUID
+		}{
+			{"did:plc:batchgood1" + run, surveyID},
+			{"did:plc:batchorphan" + run, uuid.New()},
+			{"did:plc:batchgood2" + run, surveyID},
+		} {
+			wg.Add(1)
+			go func(voter string, surveyID uuid.UUID) {
+				defer wg.Done()
+				_, err := batcher.add(ctx, batchTestResponse(surveyID, voter))
+				mu.Lock()
+				errs[voter] = err
+				mu.Unlock()
+			}(tc.voter, tc.surveyID)
+		}
+		wg.Wait()
+
+		if errs["did:plc:batchorphan"+run] == nil {
+			t.Error("Expected the orphaned response to fail")
+		}
+		for _, voter := range []string{"did:plc:batchgood1" + run, "did:plc:batchgood2" + run} {
+			if errs[voter] != nil {
+				t.Errorf("Expected %s to be stored, got %v", voter, errs[voter])
+			}
+		}
+
+		count, err := queries.CountResponsesBySurvey(ctx, surveyID)
+		if err != nil {
+			t.Fatalf("Failed to count responses: %v", err)
+		}
+		if count != 6 {
+			t.Errorf("Expected 6 responses, got %d", count)
+		}
+	})
+
+	t.Run("batch upsert reports inserted per row", func(t *testing.T) {
+		existing := batchTestResponse(surveyID, "did:plc:batchgood1"+run)
+		fresh := batchTestResponse(surveyID, "did:plc:batchfresh"+run)
+
+		inserted, err := queries.UpsertResponses(ctx, []*models.Response{existing, fresh})
+		if err != nil {
+			t.Fatalf("UpsertResponses failed: %v", err)
+		}
+		if len(inserted) != 2 || inserted[0] || !inserted[1] {
+			t.Errorf("Expected [false true], got %v", inserted)
+		}
+	})
+}
+
+// BenchmarkResponseInserts compares per-row upserts with batched ones. Each
+// op is one response.
+//
+//	go test ./internal/consumer -run '^$' -bench ResponseInserts
+func BenchmarkResponseInserts(b *testing.B) {
+	database, queries := setupTestDB(b)
+	defer database.Close()
+
+	ctx := context.Background()
+	surveyID, _ := createBatchTestSurvey(b, NewProcessor(queries), uuid.NewString()[:8])
+
+	responses := func(n int) []*models.Response {
+		rs := make([]*models.Response, n)
+		for i := range rs {
+			rs[i] = batchTestResponse(surveyID, "did:plc:bench"+uuid.NewString())
+		}
+		return rs
+	}
+
+	b.Run("per-row", func(b *testing.B) {
+		rs := responses(b.N)
+		b.ResetTimer()
+		for _, r := range rs {
+			if _, err := queries.UpsertResponse(ctx, r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, size := range []int{10, 50, 250} {
+		b.Run(fmt.Sprintf("batch-%d", size), func(b *testing.B) {
+			rs := responses(b.N)
+			b.ResetTimer()
+			for start := 0; start < len(rs); start += size {
+				end := min(start+size, len(rs))
+				if _, err := queries.UpsertResponses(ctx, rs[start:end]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openmeet-team/survey/internal/db"
+)
+
+// BlockStatus is the account_actions status recorded for blocklist sweeps
+const BlockStatus = "blocked"
+
+// BlockDID adds a DID to the moderation blocklist so the consumer skips its
+// commits from now on. With sweep, surveys, responses and comments already indexed for
+// the DID are purged too, and the purge is recorded in account_actions.
+// The block is recorded in the audit log under actor, the admin's DID, in the
+// same transaction as the block and sweep.
+// The returned result is empty unless sweep is set.
+func BlockDID(ctx context.Context, queries *db.Queries, actor, did, reason string, sweep bool) (*db.AccountActionResult, error) {
+	result := &db.AccountActionResult{}
+	err := queries.WithTx(ctx, func(q *db.Queries) error {
+		if err := q.BlockDID(ctx, did, reason); err != nil {
+			return err
+		}
+
+		if sweep {
+			var err error
+			if result, err = sweepDID(ctx, q, did); err != nil {
+				return err
+			}
+		}
+
+		details := db.AuditDetails(map[string]interface{}{
+			"reason":           reason,
+			"sweep":            sweep,
+			"surveysDeleted":   result.SurveysAffected,
+			"responsesDeleted": result.ResponsesAffected,
+			"commentsDeleted":  result.CommentsAffected,
+		})
+		return q.RecordAudit(ctx, actor, db.AuditActionBlockDID, did, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sweep && result.Total() > 0 {
+		AccountActions.WithLabelValues(db.AccountActionPurge).Inc()
+	}
+	return result, nil
+}
+
+// UnblockDID removes a DID from the moderation blocklist and records it in the
+// audit log under actor, in one transaction
+func UnblockDID(ctx context.Context, queries *db.Queries, actor, did string) error {
+	return queries.WithTx(ctx, func(q *db.Queries) error {
+		if err := q.UnblockDID(ctx, did); err != nil {
+			return err
+		}
+		return q.RecordAudit(ctx, actor, db.AuditActionUnblockDID, did, nil)
+	})
+}
+
+// sweepDID purges the records already indexed for a blocked DID
+func sweepDID(ctx context.Context, queries *db.Queries, did string) (*db.AccountActionResult, error) {
+	result, err := queries.DeleteAllForDID(ctx, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sweep records for %s: %w", did, err)
+	}
+	if result.Total() == 0 {
+		return result, nil
+	}
+
+	if result.SurveysAffected > 0 {
+		if err := queries.InvalidateSurveysByAuthor(ctx, did); err != nil {
+			return nil, fmt.Errorf("failed to invalidate cached surveys: %w", err)
+		}
+	}
+
+	if err := queries.LogAccountAction(ctx, did, db.AccountActionPurge, BlockStatus, result); err != nil {
+		return nil, fmt.Errorf("failed to log account action: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,155 @@
+package consumer
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testAdminDID is the actor recorded for admin actions in tests
+const testAdminDID = "did:plc:testadmin"
+
+// TestBlockedDIDs tests that commits from blocked DIDs are skipped and can be swept
+func TestBlockedDIDs(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	// The test database isn't reset between runs, so keep records unique
+	run := uuid.NewString()[:8]
+
+	surveyMessage := func(did, rkey string) *JetstreamMessage {
+		return &JetstreamMessage{
+			Kind: "commit",
+			Did:  did,
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Collection: "net.openmeet.survey",
+				RKey:       rkey,
+				CID:        "bafy_" + rkey,
+				Record:     testSurveyRecord("Blocklist "+rkey, "q1"),
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+	surveyExists := func(t *testing.T, did, rkey string) bool {
+		t.Helper()
+		survey, err := queries.GetSurveyByURI(ctx, "at://"+did+"/net.openmeet.survey/"+rkey)
+		if err != nil {
+			return false
+		}
+		return survey != nil
+	}
+
+	t.Run("commits from blocked DID are skipped and counted", func(t *testing.T) {
+		did := "did:plc:spammer" + run
+		if err := queries.BlockDID(ctx, did, "spam"); err != nil {
+			t.Fatalf("BlockDID failed: %v", err)
+		}
+
+		counter := BlockedEvents.WithLabelValues("net.openmeet.survey")
+		before := testutil.ToFloat64(counter)
+
+		rkey := "blocked-" + run
+		if err := processor.ProcessMessage(ctx, surveyMessage(did, rkey)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if surveyExists(t, did, rkey) {
+			t.Error("Expected survey from blocked DID to be skipped")
+		}
+		if got := testutil.ToFloat64(counter); got != before+1 {
+			t.Errorf("Expected blocked counter %v, got %v", before+1, got)
+		}
+
+		// Unblocking takes effect immediately for this process
+		if err := queries.UnblockDID(ctx, did); err != nil {
+			t.Fatalf("UnblockDID failed: %v", err)
+		}
+		rkey = "unblocked-" + run
+		if err := processor.ProcessMessage(ctx, surveyMessage(did, rkey)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if !surveyExists(t, did, rkey) {
+			t.Error("Expected survey to be indexed after unblock")
+		}
+	})
+
+	t.Run("block with sweep removes existing records", func(t *testing.T) {
+		did := "did:plc:sweep" + run
+		rkey := "swept-" + run
+		if err := processor.ProcessMessage(ctx, surveyMessage(did, rkey)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if !surveyExists(t, did, rkey) {
+			t.Fatal("Expected survey to be indexed before block")
+		}
+
+		result, err := BlockDID(ctx, queries, testAdminDID, did, "spam", true)
+		if err != nil {
+			t.Fatalf("BlockDID failed: %v", err)
+		}
+		if result.SurveysAffected != 1 {
+			t.Errorf("Expected 1 survey swept, got %d", result.SurveysAffected)
+		}
+		if surveyExists(t, did, rkey) {
+			t.Error("Expected survey to be swept")
+		}
+
+		blocked, err := queries.IsBlocked(ctx, did)
+		if err != nil || !blocked {
+			t.Errorf("Expected DID to be blocked, got %v (err %v)", blocked, err)
+		}
+
+		entries, err := queries.ListAuditEntries(ctx, db.AuditFilter{Action: db.AuditActionBlockDID, Target: did}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Actor != testAdminDID {
+			t.Fatalf("Expected 1 audit log entry by %s, got %+v", testAdminDID, entries)
+		}
+		if !strings.Contains(string(entries[0].Details), `"surveysDeleted":1`) {
+			t.Errorf("Expected the swept counts in the details, got %s", entries[0].Details)
+		}
+	})
+
+	t.Run("block without sweep keeps existing records", func(t *testing.T) {
+		did := "did:plc:nosweep" + run
+		rkey := "kept-" + run
+		if err := processor.ProcessMessage(ctx, surveyMessage(did, rkey)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		if _, err := BlockDID(ctx, queries, testAdminDID, did, "", false); err != nil {
+			t.Fatalf("BlockDID failed: %v", err)
+		}
+		if !surveyExists(t, did, rkey) {
+			t.Error("Expected existing survey to be kept without sweep")
+		}
+	})
+
+	t.Run("unblock is audited", func(t *testing.T) {
+		did := "did:plc:unblock" + run
+		if err := queries.BlockDID(ctx, did, ""); err != nil {
+			t.Fatalf("BlockDID failed: %v", err)
+		}
+
+		if err := UnblockDID(ctx, queries, testAdminDID, did); err != nil {
+			t.Fatalf("UnblockDID failed: %v", err)
+		}
+
+		entries, err := queries.ListAuditEntries(ctx, db.AuditFilter{Actor: testAdminDID, Target: did}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Action != db.AuditActionUnblockDID {
+			t.Errorf("Expected 1 unblock entry, got %+v", entries)
+		}
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"sync"
 
 	"github.com/openmeet-team/survey/internal/db"
 )
@@ -24,11 +26,18 @@ func GetCursor(ctx context.Context, q *db.Queries) (int64, error) {
 	return timeUs, nil
 }
 
-// UpdateCursor updates the Jetstream cursor to the given value
+// UpdateCursor advances the Jetstream cursor to the given value. The cursor
+// never moves backwards: an older value is ignored (and logged) so a bug or an
+// out-of-order call can't trigger mass re-processing on restart. Use
+// ForceSetCursor for an intentional rewind.
 func UpdateCursor(ctx context.Context, q *db.Queries, timeUs int64) error {
-	query := `UPDATE jetstream_cursor SET time_us = $1, updated_at = NOW() WHERE id = 1`
+	query := `UPDATE jetstream_cursor SET time_us = $1, updated_at = NOW() WHERE id = 1 AND time_us <= $1`
 
-	result, err := q.GetDB().ExecContext(ctx, query, timeUs)
+	var result sql.Result
+	err := db.ObserveWrite(db.TableCursor, func() (err error) {
+		result, err = q.GetDB().ExecContext(ctx, query, timeUs)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update cursor: %w", err)
 	}
@@ -38,9 +47,97 @@ func UpdateCursor(ctx context.Context, q *db.Queries, timeUs int64) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
+	if rows == 0 {
+		// Either the row is missing or the stored cursor is ahead
+		current, err := GetCursor(ctx, q)
+		if err != nil {
+			return err
+		}
+		log.Printf("WARNING: Ignoring backwards cursor update from %d to %d", current, timeUs)
+	}
+
+	return nil
+}
+
+// ForceSetCursor sets the Jetstream cursor even if that moves it backwards.
+// Only for deliberate rewinds, such as a backfill that commits its progress.
+// The change is recorded in the audit log under actor, in the same
+// transaction.
+func ForceSetCursor(ctx context.Context, queries *db.Queries, actor string, timeUs int64) error {
+	return queries.WithTx(ctx, func(q *db.Queries) error {
+		return forceSetCursor(ctx, q, actor, timeUs)
+	})
+}
+
+// forceSetCursor is ForceSetCursor within its transaction
+func forceSetCursor(ctx context.Context, q *db.Queries, actor string, timeUs int64) error {
+	previous, err := GetCursor(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE jetstream_cursor SET time_us = $1, updated_at = NOW() WHERE id = 1`
+
+	var result sql.Result
+	err = db.ObserveWrite(db.TableCursor, func() (err error) {
+		result, err = q.GetDB().ExecContext(ctx, query, timeUs)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set cursor: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
 	if rows == 0 {
 		return fmt.Errorf("cursor row not found (expected id=1)")
 	}
 
+	details := db.AuditDetails(map[string]interface{}{"from": previous, "to": timeUs})
+	return q.RecordAudit(ctx, actor, db.AuditActionForceSetCursor, "jetstream_cursor", details)
+}
+
+// cursorWriter saves the WorkerPool watermark as the cursor. A value that
+// fails to save is kept and retried on the next advance, or by flush during
+// shutdown, so a transient error never leaves the stored cursor behind.
+type cursorWriter struct {
+	save func(ctx context.Context, timeUs int64) error
+
+	mu      sync.Mutex
+	pending int64 // latest value not yet saved, 0 if none
+}
+
+func newCursorWriter(save func(ctx context.Context, timeUs int64) error) *cursorWriter {
+	return &cursorWriter{save: save}
+}
+
+// advance saves timeUs, keeping it for flush if the save fails
+func (w *cursorWriter) advance(ctx context.Context, timeUs int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = timeUs
+	if err := w.save(ctx, timeUs); err != nil {
+		log.Printf("ERROR: Failed to update cursor: %v", err)
+		return
+	}
+	w.pending = 0
+}
+
+// flush saves the value left behind by a failed advance, if any
+func (w *cursorWriter) flush(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pending == 0 {
+		return nil
+	}
+	if err := w.save(ctx, w.pending); err != nil {
+		return err
+	}
+	w.pending = 0
 	return nil
 }
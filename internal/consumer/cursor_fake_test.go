@@ -0,0 +1,116 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+)
+
+func TestGetCursorFake(t *testing.T) {
+	t.Run("returns stored value", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT time_us FROM jetstream_cursor").Rows([]string{"time_us"}, []interface{}{int64(1700000000000000)})
+
+		cursor, err := GetCursor(context.Background(), db.NewQueries(fake))
+		if err != nil {
+			t.Fatalf("GetCursor failed: %v", err)
+		}
+		if cursor != 1700000000000000 {
+			t.Errorf("Expected 1700000000000000, got %d", cursor)
+		}
+	})
+
+	t.Run("missing row is an error", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT time_us FROM jetstream_cursor").Rows([]string{"time_us"})
+
+		if _, err := GetCursor(context.Background(), db.NewQueries(fake)); err == nil {
+			t.Error("Expected an error for a missing cursor row")
+		}
+	})
+}
+
+func TestUpdateCursorFake(t *testing.T) {
+	t.Run("advances the cursor", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("UPDATE jetstream_cursor").RowsAffected(1)
+
+		if err := UpdateCursor(context.Background(), db.NewQueries(fake), 2000); err != nil {
+			t.Fatalf("UpdateCursor failed: %v", err)
+		}
+
+		calls := fake.Calls()
+		if len(calls) != 1 {
+			t.Fatalf("Expected only the update, got %v", calls)
+		}
+		if calls[0].Args[0] != int64(2000) {
+			t.Errorf("Expected time_us 2000, got %v", calls[0].Args[0])
+		}
+	})
+
+	t.Run("backwards update is ignored", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("UPDATE jetstream_cursor").RowsAffected(0)
+		fake.Expect("SELECT time_us FROM jetstream_cursor").Rows([]string{"time_us"}, []interface{}{int64(5000)})
+
+		if err := UpdateCursor(context.Background(), db.NewQueries(fake), 2000); err != nil {
+			t.Fatalf("Expected backwards update to be ignored, got %v", err)
+		}
+		if len(fake.CallsMatching("SELECT time_us")) != 1 {
+			t.Error("Expected the current cursor to be read for the warning")
+		}
+	})
+
+	t.Run("missing row is an error", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("UPDATE jetstream_cursor").RowsAffected(0)
+		fake.Expect("SELECT time_us FROM jetstream_cursor").Rows([]string{"time_us"})
+
+		if err := UpdateCursor(context.Background(), db.NewQueries(fake), 2000); err == nil {
+			t.Error("Expected an error for a missing cursor row")
+		}
+	})
+}
+
+func TestForceSetCursorFake(t *testing.T) {
+	t.Run("rewinds the cursor", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT time_us FROM jetstream_cursor").Rows([]string{"time_us"}, []interface{}{int64(5000)})
+		fake.Expect("UPDATE jetstream_cursor").RowsAffected(1)
+		fake.Expect("INSERT INTO audit_log").RowsAffected(1)
+
+		if err := ForceSetCursor(context.Background(), db.NewQueries(fake), "did:plc:admin", 100); err != nil {
+			t.Fatalf("ForceSetCursor failed: %v", err)
+		}
+		updates := fake.CallsMatching("UPDATE jetstream_cursor")
+		if len(updates) != 1 || updates[0].Query != "UPDATE jetstream_cursor SET time_us = $1, updated_at = NOW() WHERE id = 1" {
+			t.Errorf("Expected an unconditional update, got %v", updates)
+		}
+
+		audits := fake.CallsMatching("INSERT INTO audit_log")
+		if len(audits) != 1 {
+			t.Fatalf("Expected the rewind to be audited, got %v", fake.Calls())
+		}
+		args := audits[0].Args
+		if args[0] != "did:plc:admin" || args[1] != db.AuditActionForceSetCursor {
+			t.Errorf("Expected a cursor entry by did:plc:admin, got %v", args)
+		}
+		if details := string(args[3].([]byte)); details != `{"from":5000,"to":100}` {
+			t.Errorf("Expected the old and new cursor in the details, got %s", details)
+		}
+	})
+
+	t.Run("missing row is an error", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT time_us FROM jetstream_cursor").Rows([]string{"time_us"})
+
+		if err := ForceSetCursor(context.Background(), db.NewQueries(fake), "did:plc:admin", 100); err == nil {
+			t.Error("Expected an error for a missing cursor row")
+		}
+		if len(fake.CallsMatching("INSERT INTO audit_log")) != 0 {
+			t.Error("Expected no audit entry for a failed rewind")
+		}
+	})
+}
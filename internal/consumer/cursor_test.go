@@ -3,13 +3,14 @@ package consumer
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"testing"
 
 	"github.com/openmeet-team/survey/internal/db"
 	_ "github.com/lib/pq"
 )
 
-func setupTestDB(t *testing.T) (*sql.DB, *db.Queries) {
+func setupTestDB(t testing.TB) (*sql.DB, *db.Queries) {
 	t.Helper()
 
 	// Use test database connection string
@@ -75,6 +76,11 @@ func TestUpdateCursor(t *testing.T) {
 	})
 
 	t.Run("updates cursor multiple times", func(t *testing.T) {
+		// Start below the values written; the previous subtest left it higher
+		if err := ForceSetCursor(context.Background(), queries, db.AuditActorSystem, 0); err != nil {
+			t.Fatalf("ForceSetCursor failed: %v", err)
+		}
+
 		values := []int64{111, 222, 333, 444, 555}
 
 		for _, val := range values {
@@ -95,6 +101,64 @@ func TestUpdateCursor(t *testing.T) {
 	})
 }
 
+func TestUpdateCursorMonotonic(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+	ctx := context.Background()
+
+	if err := UpdateCursor(ctx, queries, 5000); err != nil {
+		t.Fatalf("UpdateCursor failed: %v", err)
+	}
+
+	t.Run("ignores a backwards update", func(t *testing.T) {
+		if err := UpdateCursor(ctx, queries, 4000); err != nil {
+			t.Fatalf("Expected backwards update to be ignored without error, got %v", err)
+		}
+
+		cursor, err := GetCursor(ctx, queries)
+		if err != nil {
+			t.Fatalf("GetCursor failed: %v", err)
+		}
+		if cursor != 5000 {
+			t.Errorf("Expected cursor to stay at 5000, got %d", cursor)
+		}
+	})
+
+	t.Run("accepts the same value", func(t *testing.T) {
+		if err := UpdateCursor(ctx, queries, 5000); err != nil {
+			t.Fatalf("UpdateCursor failed: %v", err)
+		}
+	})
+
+	t.Run("still advances", func(t *testing.T) {
+		if err := UpdateCursor(ctx, queries, 6000); err != nil {
+			t.Fatalf("UpdateCursor failed: %v", err)
+		}
+
+		cursor, err := GetCursor(ctx, queries)
+		if err != nil {
+			t.Fatalf("GetCursor failed: %v", err)
+		}
+		if cursor != 6000 {
+			t.Errorf("Expected cursor to be 6000, got %d", cursor)
+		}
+	})
+
+	t.Run("ForceSetCursor rewinds", func(t *testing.T) {
+		if err := ForceSetCursor(ctx, queries, testAdminDID, 1000); err != nil {
+			t.Fatalf("ForceSetCursor failed: %v", err)
+		}
+
+		cursor, err := GetCursor(ctx, queries)
+		if err != nil {
+			t.Fatalf("GetCursor failed: %v", err)
+		}
+		if cursor != 1000 {
+			t.Errorf("Expected cursor to be rewound to 1000, got %d", cursor)
+		}
+	})
+}
+
 func TestGetCursorWithTransaction(t *testing.T) {
 	database, queries := setupTestDB(t)
 	defer database.Close()
@@ -142,3 +206,34 @@ func TestGetCursorWithTransaction(t *testing.T) {
 		}
 	})
 }
+
+func TestCursorWriterFlushesFailedSave(t *testing.T) {
+	var saved []int64
+	fail := true
+	w := newCursorWriter(func(ctx context.Context, timeUs int64) error {
+		if fail {
+			return errors.New("connection reset")
+		}
+		saved = append(saved, timeUs)
+		return nil
+	})
+
+	w.advance(context.Background(), 1000)
+	if len(saved) != 0 {
+		t.Fatalf("Expected failed save, got %v", saved)
+	}
+
+	// Shutdown flushes the value the failed save left behind
+	fail = false
+	if err := w.flush(context.Background()); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if len(saved) != 1 || saved[0] != 1000 {
+		t.Errorf("Expected flush to save 1000, got %v", saved)
+	}
+
+	// Nothing left to flush
+	if err := w.flush(context.Background()); err != nil || len(saved) != 1 {
+		t.Errorf("Expected no-op flush, got err=%v saved=%v", err, saved)
+	}
+}
@@ -0,0 +1,196 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStaleAfter is how long the consumer may go without receiving an
+	// event, ping or pong before it reports not ready
+	DefaultStaleAfter = 2 * time.Minute
+
+	// keepalivePingInterval is how often the client pings Jetstream. Our
+	// collections are quiet, so the pongs are what keep a healthy connection
+	// inside the staleness window between events.
+	keepalivePingInterval = 30 * time.Second
+
+	// dbPingTimeout bounds the database check in the readiness probe
+	dbPingTimeout = time.Second
+)
+
+// DBPinger is the database dependency of the readiness check (*sql.DB)
+type DBPinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// connTracker records the websocket state for the readiness check
+type connTracker struct {
+	mu           sync.Mutex
+	connected    bool
+	lastActivity time.Time // last event, ping or pong received
+}
+
+// defaultConn is shared by the Jetstream client and the health handlers
+var defaultConn = &connTracker{}
+
+// setConnected marks the websocket connected. Connecting counts as activity
+// so a fresh connection isn't stale before its first event.
+func (t *connTracker) setConnected(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.connected = true
+	t.lastActivity = now
+}
+
+// setDisconnected marks the websocket closed
+func (t *connTracker) setDisconnected() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.connected = false
+}
+
+// touch records that something was received on the websocket
+func (t *connTracker) touch(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastActivity = now
+}
+
+func (t *connTracker) state() (bool, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connected, t.lastActivity
+}
+
+// ReadinessResponse is the body of the readiness probe. Checks maps each
+// check name (database, websocket, activity) to "healthy" or the reason it
+// failed.
+type ReadinessResponse struct {
+	Status  string            `json:"status"`
+	Service string            `json:"service"`
+	Checks  map[string]string `json:"checks"`
+	Lag     LagStatus         `json:"lag"`
+}
+
+// LivenessResponse is the body of the liveness probe
+type LivenessResponse struct {
+	Status    string `json:"status"`
+	Service   string `json:"service"`
+	Timestamp string `json:"timestamp"`
+}
+
+// HealthChecker serves the consumer's liveness and readiness probes
+type HealthChecker struct {
+	db         DBPinger
+	staleAfter time.Duration
+	conn       *connTracker
+	now        func() time.Time
+}
+
+// NewHealthChecker creates a HealthChecker. staleAfter <= 0 uses
+// DefaultStaleAfter.
+func NewHealthChecker(db DBPinger, staleAfter time.Duration) *HealthChecker {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	return &HealthChecker{
+		db:         db,
+		staleAfter: staleAfter,
+		conn:       defaultConn,
+		now:        time.Now,
+	}
+}
+
+// StaleAfterFromEnv reads the staleness window from CONSUMER_STALE_AFTER
+// (a Go duration such as "2m"), defaulting to DefaultStaleAfter
+func StaleAfterFromEnv() (time.Duration, error) {
+	value := os.Getenv("CONSUMER_STALE_AFTER")
+	if value == "" {
+		return DefaultStaleAfter, nil
+	}
+	staleAfter, err := time.ParseDuration(value)
+	if err != nil || staleAfter <= 0 {
+		return 0, fmt.Errorf("invalid CONSUMER_STALE_AFTER: %q", value)
+	}
+	return staleAfter, nil
+}
+
+// Readiness runs every check and reports whether all of them passed
+func (h *HealthChecker) Readiness(ctx context.Context) (ReadinessResponse, bool) {
+	checks := make(map[string]string)
+	ready := true
+	fail := func(name, reason string) {
+		checks[name] = "unhealthy: " + reason
+		ready = false
+	}
+
+	// Database responds to a ping
+	pingCtx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+	if err := h.db.PingContext(pingCtx); err != nil {
+		fail("database", err.Error())
+	} else {
+		checks["database"] = "healthy"
+	}
+
+	// Websocket is connected and has heard from Jetstream recently
+	connected, lastActivity := h.conn.state()
+	if !connected {
+		fail("websocket", "not connected")
+	} else {
+		checks["websocket"] = "healthy"
+	}
+
+	if lastActivity.IsZero() {
+		fail("activity", "nothing received yet")
+	} else if idle := h.now().Sub(lastActivity); idle > h.staleAfter {
+		fail("activity", fmt.Sprintf("nothing received for %v (limit %v)", idle.Round(time.Second), h.staleAfter))
+	} else {
+		checks["activity"] = "healthy"
+	}
+
+	status := "ready"
+	if !ready {
+		status = "not_ready"
+	}
+	return ReadinessResponse{
+		Status:  status,
+		Service: "survey-consumer",
+		Checks:  checks,
+		Lag:     CurrentLag(),
+	}, ready
+}
+
+// ReadyHandler serves the readiness probe: 200 when every check passes,
+// otherwise 503 with the failed checks in the body
+func (h *HealthChecker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	response, ready := h.Readiness(r.Context())
+
+	httpStatus := http.StatusOK
+	if !ready {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	writeJSON(w, httpStatus, response)
+}
+
+// LiveHandler serves the liveness probe. It only shows the process is
+// responsive, so a lagging or disconnected consumer isn't restarted for it.
+func (h *HealthChecker) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, LivenessResponse{
+		Status:    "healthy",
+		Service:   "survey-consumer",
+		Timestamp: h.now().UTC().Format(time.RFC3339),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
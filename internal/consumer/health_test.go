@@ -0,0 +1,207 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakePinger struct {
+	err   error
+	delay time.Duration
+}
+
+func (p fakePinger) PingContext(ctx context.Context) error {
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return p.err
+}
+
+func newTestHealthChecker(db DBPinger, now time.Time) *HealthChecker {
+	h := NewHealthChecker(db, time.Minute)
+	h.conn = &connTracker{}
+	h.now = func() time.Time { return now }
+	return h
+}
+
+func TestReadiness(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		db        DBPinger
+		setup     func(c *connTracker)
+		wantReady bool
+		wantFail  []string
+	}{
+		{
+			name:      "all healthy",
+			db:        fakePinger{},
+			setup:     func(c *connTracker) { c.setConnected(now.Add(-10 * time.Second)) },
+			wantReady: true,
+		},
+		{
+			name:     "database down",
+			db:       fakePinger{err: errors.New("connection refused")},
+			setup:    func(c *connTracker) { c.setConnected(now) },
+			wantFail: []string{"database"},
+		},
+		{
+			name:     "database ping times out",
+			db:       fakePinger{delay: 5 * time.Second},
+			setup:    func(c *connTracker) { c.setConnected(now) },
+			wantFail: []string{"database"},
+		},
+		{
+			name:     "never connected",
+			db:       fakePinger{},
+			setup:    func(c *connTracker) {},
+			wantFail: []string{"websocket", "activity"},
+		},
+		{
+			name: "disconnected",
+			db:   fakePinger{},
+			setup: func(c *connTracker) {
+				c.setConnected(now.Add(-5 * time.Second))
+				c.setDisconnected()
+			},
+			wantFail: []string{"websocket"},
+		},
+		{
+			name:     "stale",
+			db:       fakePinger{},
+			setup:    func(c *connTracker) { c.setConnected(now.Add(-2 * time.Minute)) },
+			wantFail: []string{"activity"},
+		},
+		{
+			name: "recent ping keeps a quiet stream fresh",
+			db:   fakePinger{},
+			setup: func(c *connTracker) {
+				c.setConnected(now.Add(-time.Hour))
+				c.touch(now.Add(-20 * time.Second))
+			},
+			wantReady: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHealthChecker(tt.db, now)
+			tt.setup(h.conn)
+
+			start := time.Now()
+			response, ready := h.Readiness(context.Background())
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Readiness took %v, expected the DB ping to be bounded", elapsed)
+			}
+
+			if ready != tt.wantReady {
+				t.Errorf("Expected ready=%v, got %v (checks: %v)", tt.wantReady, ready, response.Checks)
+			}
+			for _, name := range []string{"database", "websocket", "activity"} {
+				failed := strings.HasPrefix(response.Checks[name], "unhealthy")
+				want := false
+				for _, f := range tt.wantFail {
+					if f == name {
+						want = true
+					}
+				}
+				if failed != want {
+					t.Errorf("Check %s: expected failed=%v, got %q", name, want, response.Checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	now := time.Now()
+
+	t.Run("ready returns 200", func(t *testing.T) {
+		h := newTestHealthChecker(fakePinger{}, now)
+		h.conn.setConnected(now)
+
+		rec := httptest.NewRecorder()
+		h.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("failed check returns 503 naming it", func(t *testing.T) {
+		h := newTestHealthChecker(fakePinger{}, now)
+
+		rec := httptest.NewRecorder()
+		h.ReadyHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", ct)
+		}
+
+		var response ReadinessResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode body: %v", err)
+		}
+		if response.Status != "not_ready" {
+			t.Errorf("Expected status not_ready, got %q", response.Status)
+		}
+		if response.Checks["websocket"] != "unhealthy: not connected" {
+			t.Errorf("Expected websocket check to explain the failure, got %q", response.Checks["websocket"])
+		}
+		if response.Checks["database"] != "healthy" {
+			t.Errorf("Expected database check healthy, got %q", response.Checks["database"])
+		}
+	})
+}
+
+func TestLiveHandler(t *testing.T) {
+	// Live even when every readiness check would fail
+	h := newTestHealthChecker(fakePinger{err: errors.New("down")}, time.Now())
+
+	rec := httptest.NewRecorder()
+	h.LiveHandler(rec, httptest.NewRequest(http.MethodGet, "/live", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+	var response LivenessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if response.Status != "healthy" {
+		t.Errorf("Expected status healthy, got %q", response.Status)
+	}
+}
+
+func TestStaleAfterFromEnv(t *testing.T) {
+	t.Setenv("CONSUMER_STALE_AFTER", "")
+	if got, err := StaleAfterFromEnv(); err != nil || got != DefaultStaleAfter {
+		t.Errorf("Expected default %v, got %v (err %v)", DefaultStaleAfter, got, err)
+	}
+
+	t.Setenv("CONSUMER_STALE_AFTER", "90s")
+	if got, err := StaleAfterFromEnv(); err != nil || got != 90*time.Second {
+		t.Errorf("Expected 90s, got %v (err %v)", got, err)
+	}
+
+	for _, bad := range []string{"soon", "0s", "-1m"} {
+		t.Setenv("CONSUMER_STALE_AFTER", bad)
+		if _, err := StaleAfterFromEnv(); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
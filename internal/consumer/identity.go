@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+)
+
+// JetstreamIdentity represents the identity portion of a Jetstream message
+type JetstreamIdentity struct {
+	Did    string `json:"did"`
+	Handle string `json:"handle,omitempty"`
+	Seq    int64  `json:"seq,omitempty"`
+	Time   string `json:"time,omitempty"`
+}
+
+// processIdentityEvent keeps the did -> handle mapping current and tells the
+// API servers to drop their cached resolutions of the identity.
+// Jetstream delivers identity events for every account on the network, so only
+// DIDs we already track (survey authors or previously stored handles) are kept.
+func (p *Processor) processIdentityEvent(ctx context.Context, msg *JetstreamMessage) error {
+	identity := msg.Identity
+	if identity == nil {
+		return nil
+	}
+
+	did := identity.Did
+	if did == "" {
+		did = msg.Did
+	}
+	if did == "" {
+		return nil
+	}
+
+	tracked, err := p.queries.IsTrackedDID(ctx, did)
+	if err != nil {
+		return fmt.Errorf("failed to check tracked DID: %w", err)
+	}
+	if !tracked {
+		return nil
+	}
+
+	// Any identity event may mean resolutions cached by the API servers are
+	// stale, even one without a handle
+	if err := p.queries.NotifyIdentityChange(ctx, did, identity.Handle); err != nil {
+		return err
+	}
+
+	if identity.Handle == "" {
+		return nil // Handle may be omitted when it is unchanged or invalid
+	}
+
+	if err := p.queries.UpsertHandle(ctx, did, identity.Handle); err != nil {
+		return fmt.Errorf("failed to update handle for %s: %w", did, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestProcessIdentityEvent(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	identityEvent := func(did, handle string) *JetstreamMessage {
+		return &JetstreamMessage{
+			Kind: "identity",
+			Did:  did,
+			Identity: &JetstreamIdentity{
+				Did:    did,
+				Handle: handle,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+
+	t.Run("updates handle for survey author", func(t *testing.T) {
+		did := "did:plc:ident-" + uuid.New().String()[:8]
+		survey := &models.Survey{
+			ID:        uuid.New(),
+			URI:       stringPtr("at://" + did + "/net.openmeet.survey/ident1"),
+			CID:       stringPtr("bafyident1"),
+			AuthorDID: stringPtr(did),
+			Slug:      "test-identity-" + uuid.New().String()[:8],
+			Title:     "Identity Survey",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{
+						ID:       "q1",
+						Text:     "Question 1?",
+						Type:     models.QuestionTypeSingle,
+						Required: true,
+						Options: []models.Option{
+							{ID: "a", Text: "Option A"},
+						},
+					},
+				},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+
+		if err := processor.ProcessMessage(ctx, identityEvent(did, "old.bsky.social")); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if err := processor.ProcessMessage(ctx, identityEvent(did, "new.bsky.social")); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		handle, err := queries.GetHandle(ctx, did)
+		if err != nil {
+			t.Fatalf("GetHandle failed: %v", err)
+		}
+		if handle != "new.bsky.social" {
+			t.Errorf("Expected handle 'new.bsky.social', got %q", handle)
+		}
+	})
+
+	t.Run("ignores DIDs we don't track", func(t *testing.T) {
+		did := "did:plc:ident-unknown-" + uuid.New().String()[:8]
+
+		if err := processor.ProcessMessage(ctx, identityEvent(did, "stranger.bsky.social")); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		handle, err := queries.GetHandle(ctx, did)
+		if err != nil {
+			t.Fatalf("GetHandle failed: %v", err)
+		}
+		if handle != "" {
+			t.Errorf("Expected no handle for untracked DID, got %q", handle)
+		}
+	})
+
+	t.Run("ignores events without a handle", func(t *testing.T) {
+		did := "did:plc:ident-nohandle-" + uuid.New().String()[:8]
+		if err := queries.UpsertHandle(ctx, did, "kept.bsky.social"); err != nil {
+			t.Fatalf("UpsertHandle failed: %v", err)
+		}
+
+		if err := processor.ProcessMessage(ctx, identityEvent(did, "")); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		handle, err := queries.GetHandle(ctx, did)
+		if err != nil {
+			t.Fatalf("GetHandle failed: %v", err)
+		}
+		if handle != "kept.bsky.social" {
+			t.Errorf("Expected handle to be kept, got %q", handle)
+		}
+	})
+}
+
+func TestProcessIdentityEventNotify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("tracked DID is announced, with or without a handle", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT EXISTS").Rows([]string{"tracked"}, []interface{}{true})
+		fake.Expect("pg_notify").RowsAffected(1)
+		fake.Expect("INSERT INTO handles").RowsAffected(1)
+		processor := NewProcessor(db.NewQueries(fake))
+
+		messages := []*JetstreamMessage{
+			{Kind: "identity", Did: "did:plc:notify1", Identity: &JetstreamIdentity{Did: "did:plc:notify1", Handle: "new.bsky.social"}},
+			{Kind: "identity", Did: "did:plc:notify2", Identity: &JetstreamIdentity{}}, // handle omitted
+		}
+		for _, msg := range messages {
+			if err := processor.ProcessMessage(ctx, msg); err != nil {
+				t.Fatalf("ProcessMessage failed: %v", err)
+			}
+		}
+
+		notify := fake.CallsMatching("pg_notify")
+		want := []string{"did:plc:notify1 new.bsky.social", "did:plc:notify2"}
+		if len(notify) != len(want) {
+			t.Fatalf("Expected %d notifications, got %d", len(want), len(notify))
+		}
+		for i := range want {
+			if notify[i].Args[0] != db.IdentityChannel || notify[i].Args[1] != want[i] {
+				t.Errorf("Notification %d: expected %q on %s, got %v", i, want[i], db.IdentityChannel, notify[i].Args)
+			}
+		}
+		if upserts := fake.CallsMatching("INSERT INTO handles"); len(upserts) != 1 {
+			t.Errorf("Expected only the event with a handle to be stored, got %d upserts", len(upserts))
+		}
+	})
+
+	t.Run("untracked DID is ignored", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT EXISTS").Rows([]string{"tracked"}, []interface{}{false})
+		processor := NewProcessor(db.NewQueries(fake))
+
+		msg := &JetstreamMessage{Kind: "identity", Did: "did:plc:stranger", Identity: &JetstreamIdentity{Handle: "stranger.bsky.social"}}
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if notify := fake.CallsMatching("pg_notify"); len(notify) != 0 {
+			t.Errorf("Expected no notification for an untracked DID, got %d", len(notify))
+		}
+	})
+}
@@ -2,6 +2,7 @@ package consumer
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -13,37 +14,95 @@ import (
 	"github.com/openmeet-team/survey/internal/telemetry"
 )
 
+// Options configures how the consumer processes events
+type Options struct {
+	// Workers is the number of concurrent event workers. Events for the same
+	// repo DID are always processed in order. Defaults to DefaultWorkers.
+	Workers int
+
+	// Backfill, when set, replays from Backfill.From instead of the stored
+	// cursor and stops once caught up
+	Backfill *BackfillOptions
+
+	// Limits bounds the size of records accepted. Zero fields use the defaults.
+	Limits Limits
+
+	// DrainTimeout is how long shutdown waits for in-flight events to finish.
+	// Defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	// Batch, when enabled, writes new responses from concurrent workers in
+	// multi-row inserts. Off by default.
+	Batch BatchOptions
+}
+
+const (
+	// DefaultDrainTimeout bounds how long shutdown waits for in-flight events
+	DefaultDrainTimeout = 10 * time.Second
+
+	// cursorFlushTimeout bounds the final cursor write during shutdown
+	cursorFlushTimeout = 5 * time.Second
+)
+
 // JetstreamClient manages the WebSocket connection to Jetstream
 type JetstreamClient struct {
 	url       string
 	queries   *db.Queries
 	processor *Processor
+	opts      Options
+	backfill  *backfillProgress // nil unless running a backfill
 	conn      *websocket.Conn
 	done      chan struct{}
+
+	// Overridable in tests
+	handle     func(ctx context.Context, msg *JetstreamMessage)
+	saveCursor func(ctx context.Context, timeUs int64) error
 }
 
 // NewJetstreamClient creates a new Jetstream client
-func NewJetstreamClient(url string, queries *db.Queries) *JetstreamClient {
-	return &JetstreamClient{
+func NewJetstreamClient(url string, queries *db.Queries, opts Options) *JetstreamClient {
+	if opts.Workers < 1 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	c := &JetstreamClient{
 		url:       url,
 		queries:   queries,
-		processor: NewProcessor(queries),
+		processor: NewProcessor(queries).WithLimits(opts.Limits),
+		opts:      opts,
 		done:      make(chan struct{}),
 	}
+	c.handle = c.handleMessage
+	c.saveCursor = func(ctx context.Context, timeUs int64) error {
+		return db.ExecWithRetry(ctx, db.OpCursor, func() error {
+			return UpdateCursor(ctx, c.queries, timeUs)
+		})
+	}
+	return c
 }
 
 // Connect establishes the WebSocket connection with cursor resumption
 func (c *JetstreamClient) Connect(ctx context.Context) error {
-	// Get current cursor
-	cursor, err := GetCursor(ctx, c.queries)
-	if err != nil {
-		return fmt.Errorf("failed to get cursor: %w", err)
-	}
-
-	// Build URL with cursor if > 0
 	url := c.url
-	if cursor > 0 {
+	var cursor int64
+	if c.backfill != nil {
+		// Always pass the cursor: without one Jetstream live-tails
+		cursor = c.backfill.cursor()
 		url = fmt.Sprintf("%s&cursor=%d", c.url, cursor)
+	} else {
+		// Get current cursor
+		var err error
+		cursor, err = GetCursor(ctx, c.queries)
+		if err != nil {
+			return fmt.Errorf("failed to get cursor: %w", err)
+		}
+
+		// Build URL with cursor if > 0
+		if cursor > 0 {
+			url = fmt.Sprintf("%s&cursor=%d", c.url, cursor)
+		}
 	}
 
 	log.Printf("Connecting to Jetstream: %s", url)
@@ -56,7 +115,22 @@ func (c *JetstreamClient) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to dial websocket: %w", err)
 	}
 
+	// Pings and pongs count as activity for the readiness check
+	conn.SetPingHandler(func(data string) error {
+		defaultConn.touch(time.Now())
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+	conn.SetPongHandler(func(string) error {
+		defaultConn.touch(time.Now())
+		return nil
+	})
+
 	c.conn = conn
+	defaultConn.setConnected(time.Now())
 	telemetry.JetstreamConnectionStatus.Set(1)
 	log.Printf("Connected to Jetstream (resuming from cursor: %d)", cursor)
 
@@ -64,9 +138,50 @@ func (c *JetstreamClient) Connect(ctx context.Context) error {
 }
 
 // Run starts the message processing loop
+// Events are handed to a WorkerPool; the cursor is advanced to the pool's low
+// watermark so a restart never skips an event that was still in flight.
+// When ctx is cancelled, Run waits up to DrainTimeout for in-flight events,
+// drops queued ones (they're replayed on restart), and flushes the cursor.
 func (c *JetstreamClient) Run(ctx context.Context) error {
 	defer close(c.done)
 
+	// In-flight events finish after ctx is cancelled, so workers and cursor
+	// updates must not inherit its cancellation
+	workCtx := context.WithoutCancel(ctx)
+
+	// Registered before shutdown so the batcher outlives the workers using it
+	if c.opts.Batch.enabled() {
+		if database, ok := c.queries.GetDB().(*sql.DB); ok {
+			batcher, err := openResponseBatcher(workCtx, database, c.opts.Batch)
+			if err != nil {
+				return err
+			}
+			defer batcher.close()
+			c.processor.batcher = batcher
+		}
+	}
+
+	cursor := newCursorWriter(c.saveCursor)
+	pool := NewWorkerPool(c.opts.Workers, c.handle, func(timeUs int64) {
+		if c.backfill != nil {
+			c.backfill.advance(timeUs)
+			if !c.backfill.opts.CommitCursor {
+				return
+			}
+		}
+		cursor.advance(workCtx, timeUs)
+	})
+	pool.Start(workCtx)
+	defer c.shutdown(ctx, pool, cursor)
+
+	// ReadMessage blocks until the next event; unblock it on cancellation
+	stopUnblock := context.AfterFunc(ctx, func() {
+		c.conn.SetReadDeadline(time.Now())
+	})
+	defer stopUnblock()
+
+	go c.keepalive(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -76,8 +191,13 @@ func (c *JetstreamClient) Run(ctx context.Context) error {
 			// Read message from WebSocket
 			_, message, err := c.conn.ReadMessage()
 			if err != nil {
+				if ctx.Err() != nil {
+					log.Println("Shutting down Jetstream client...")
+					return nil
+				}
 				return fmt.Errorf("error reading message: %w", err)
 			}
+			defaultConn.touch(time.Now())
 
 			// Parse the message
 			var msg JetstreamMessage
@@ -86,42 +206,101 @@ func (c *JetstreamClient) Run(ctx context.Context) error {
 				continue
 			}
 
-			// Process the message with cursor update and metrics
-			collection := ""
-			operation := ""
-			if msg.Commit != nil {
-				collection = msg.Commit.Collection
-				operation = msg.Commit.Operation
-			}
+			// Track lag for every event, including non-commit kinds
+			defaultLag.observe(msg.TimeUs, time.Now())
 
-			startTime := time.Now()
-			if err := c.processor.ProcessMessageWithCursor(ctx, &msg, c.queries.GetDB); err != nil {
-				log.Printf("ERROR: Failed to process message: %v", err)
-				telemetry.JetstreamRecordsProcessed.WithLabelValues(collection, operation, "error").Inc()
-				continue
+			if err := pool.Submit(ctx, &msg); err != nil {
+				log.Println("Shutting down Jetstream client...")
+				return nil
 			}
 
-			// Record success metrics
-			if collection != "" {
-				telemetry.JetstreamRecordsProcessed.WithLabelValues(collection, operation, "success").Inc()
-				telemetry.JetstreamProcessingDuration.WithLabelValues(collection, operation).Observe(time.Since(startTime).Seconds())
+			// A backfill is done once it reaches recent events; the deferred
+			// pool.Close finishes everything already queued
+			if c.backfill != nil && c.backfill.observe(msg.TimeUs, time.Now()) {
+				log.Printf("Backfill caught up to within %v of now", c.backfill.opts.CatchUpLag)
+				return nil
 			}
+		}
+	}
+}
 
-			// Update cursor lag (time_us is microseconds since epoch)
-			if msg.TimeUs > 0 {
-				eventTime := time.UnixMicro(msg.TimeUs)
-				lagSeconds := time.Since(eventTime).Seconds()
-				if lagSeconds < 0 {
-					lagSeconds = 0 // Future events shouldn't happen but handle gracefully
-				}
-				telemetry.JetstreamCursorLag.Set(lagSeconds)
+// shutdown stops the pool and flushes the cursor. On cancellation only the
+// in-flight events are waited for, bounded by DrainTimeout; otherwise (a
+// finished backfill or a dropped connection) every queued event is finished.
+// The cursor flush uses a fresh context since ctx may already be cancelled.
+func (c *JetstreamClient) shutdown(ctx context.Context, pool *WorkerPool, cursor *cursorWriter) {
+	if ctx.Err() != nil {
+		if !pool.Drain(c.opts.DrainTimeout) {
+			log.Printf("WARNING: In-flight events still running after %v; cursor stays at the last completed event", c.opts.DrainTimeout)
+		}
+	} else {
+		pool.Close()
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), cursorFlushTimeout)
+	defer cancel()
+	if err := cursor.flush(flushCtx); err != nil {
+		log.Printf("ERROR: Failed to flush cursor: %v", err)
+	}
+}
+
+// keepalive pings Jetstream until Run returns so a quiet stream still shows
+// activity. A failed ping is left for the read loop to notice.
+func (c *JetstreamClient) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(keepalivePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second)); err != nil {
+				log.Printf("WARNING: Failed to ping Jetstream: %v", err)
 			}
 		}
 	}
 }
 
+// handleMessage processes a single event and records metrics. Failures are
+// logged and counted; the event still counts as complete for the cursor.
+func (c *JetstreamClient) handleMessage(ctx context.Context, msg *JetstreamMessage) {
+	collection := ""
+	operation := ""
+	if msg.Commit != nil {
+		collection = msg.Commit.Collection
+		operation = msg.Commit.Operation
+	}
+
+	startTime := time.Now()
+	if err := c.processor.ProcessMessageInTx(ctx, msg); err != nil {
+		log.Printf("ERROR: Failed to process message: %v", err)
+		telemetry.JetstreamRecordsProcessed.WithLabelValues(collection, operation, "error").Inc()
+		return
+	}
+
+	// Record success metrics
+	if collection != "" {
+		telemetry.JetstreamRecordsProcessed.WithLabelValues(collection, operation, "success").Inc()
+		telemetry.JetstreamProcessingDuration.WithLabelValues(collection, operation).Observe(time.Since(startTime).Seconds())
+	}
+
+	// Update cursor lag (time_us is microseconds since epoch)
+	if msg.TimeUs > 0 {
+		eventTime := time.UnixMicro(msg.TimeUs)
+		lagSeconds := time.Since(eventTime).Seconds()
+		if lagSeconds < 0 {
+			lagSeconds = 0 // Future events shouldn't happen but handle gracefully
+		}
+		telemetry.JetstreamCursorLag.Set(lagSeconds)
+	}
+}
+
 // Close closes the WebSocket connection
 func (c *JetstreamClient) Close() error {
+	defaultConn.setDisconnected()
 	telemetry.JetstreamConnectionStatus.Set(0)
 	if c.conn != nil {
 		err := c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
@@ -133,41 +312,67 @@ func (c *JetstreamClient) Close() error {
 	return nil
 }
 
-// RunWithReconnect runs the client with exponential backoff on connection errors
-func RunWithReconnect(ctx context.Context, url string, queries *db.Queries) error {
-	backoff := time.Second
-	maxBackoff := 60 * time.Second
+// RunWithReconnect runs the client with exponential backoff and jitter on connection errors.
+// The backoff resets once a connection has stayed healthy for Backoff.HealthyThreshold,
+// and pending retry sleeps are abandoned as soon as the context is cancelled.
+// With opts.Backfill set it returns once the backfill has caught up.
+func RunWithReconnect(ctx context.Context, url string, queries *db.Queries, opts Options) error {
+	backoff := NewBackoff()
+
+	var backfill *backfillProgress
+	if opts.Backfill != nil {
+		backfill = newBackfillProgress(*opts.Backfill, time.Now())
+		log.Printf("Backfilling from time_us %d (commit cursor: %v)", backfill.opts.From, backfill.opts.CommitCursor)
+
+		// Progress only moves the cursor forward, so rewind it explicitly first
+		if backfill.opts.CommitCursor {
+			err := db.ExecWithRetry(ctx, db.OpCursor, func() error {
+				return ForceSetCursor(ctx, queries, backfill.opts.Actor, backfill.opts.From)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to rewind cursor for backfill: %w", err)
+			}
+		}
+
+		reportCtx, stopReport := context.WithCancel(ctx)
+		defer stopReport()
+		go backfill.report(reportCtx)
+		defer func() { backfill.summary(time.Now()) }()
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
-			client := NewJetstreamClient(url, queries)
+			client := NewJetstreamClient(url, queries, opts)
+			client.backfill = backfill
 
 			// Try to connect
 			if err := client.Connect(ctx); err != nil {
-				log.Printf("Connection error: %v. Retrying in %v...", err, backoff)
+				delay := backoff.Next()
+				log.Printf("Connection error: %v. Reconnect attempt %d in %v...", err, backoff.Attempt(), delay)
 				telemetry.JetstreamReconnects.Inc()
-				time.Sleep(backoff)
-
-				// Exponential backoff
-				backoff = backoff * 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
+				telemetry.ConsumerReconnectAttempts.Inc()
+				if !sleepContext(ctx, delay) {
+					return nil
 				}
 				continue
 			}
 
-			// Reset backoff on successful connection
-			backoff = time.Second
-
 			// Run the client
+			connectedAt := time.Now()
 			if err := client.Run(ctx); err != nil {
-				log.Printf("Runtime error: %v. Reconnecting...", err)
-				telemetry.JetstreamReconnects.Inc()
 				client.Close()
-				time.Sleep(backoff)
+				backoff.ConnectionEnded(time.Since(connectedAt))
+
+				delay := backoff.Next()
+				log.Printf("Runtime error: %v. Reconnect attempt %d in %v...", err, backoff.Attempt(), delay)
+				telemetry.JetstreamReconnects.Inc()
+				telemetry.ConsumerReconnectAttempts.Inc()
+				if !sleepContext(ctx, delay) {
+					return nil
+				}
 				continue
 			}
 
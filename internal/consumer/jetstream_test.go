@@ -0,0 +1,188 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shutdownTestServer serves the given events over a websocket, then holds the
+// connection open like a live Jetstream would
+func shutdownTestServer(t *testing.T, events []*JetstreamMessage) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, event := range events {
+			data, _ := json.Marshal(event)
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+		// Block until the client goes away
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// shutdownTestClient connects a client whose handler blocks on the second
+// event until release is closed. It records which events were handled and
+// every cursor value saved.
+type shutdownTestClient struct {
+	*JetstreamClient
+
+	started chan struct{} // closed once the blocking event starts
+	release chan struct{} // close to let the blocking event finish
+
+	mu      sync.Mutex
+	handled []int64
+	cursors []int64
+}
+
+func newShutdownTestClient(t *testing.T, url string, blockOn int64, drainTimeout time.Duration) *shutdownTestClient {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial test server: %v", err)
+	}
+
+	tc := &shutdownTestClient{
+		JetstreamClient: NewJetstreamClient(url, nil, Options{DrainTimeout: drainTimeout}),
+		started:         make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+	tc.conn = conn
+	tc.handle = func(ctx context.Context, msg *JetstreamMessage) {
+		if msg.TimeUs == blockOn {
+			close(tc.started)
+			<-tc.release
+		}
+		tc.mu.Lock()
+		tc.handled = append(tc.handled, msg.TimeUs)
+		tc.mu.Unlock()
+	}
+	tc.saveCursor = func(ctx context.Context, timeUs int64) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tc.mu.Lock()
+		tc.cursors = append(tc.cursors, timeUs)
+		tc.mu.Unlock()
+		return nil
+	}
+	t.Cleanup(func() { tc.Close() })
+	return tc
+}
+
+func (tc *shutdownTestClient) lastCursor() int64 {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if len(tc.cursors) == 0 {
+		return 0
+	}
+	return tc.cursors[len(tc.cursors)-1]
+}
+
+func (tc *shutdownTestClient) handledEvents() []int64 {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return append([]int64(nil), tc.handled...)
+}
+
+func shutdownTestEvents() []*JetstreamMessage {
+	events := make([]*JetstreamMessage, 0, 3)
+	for _, timeUs := range []int64{1000, 2000, 3000} {
+		events = append(events, &JetstreamMessage{
+			Did:    "did:plc:shutdown",
+			TimeUs: timeUs,
+			Kind:   "commit",
+			Commit: &JetstreamCommit{Operation: "create", Collection: "net.openmeet.survey", RKey: "r"},
+		})
+	}
+	return events
+}
+
+// runUntilStarted runs the client and cancels it once the blocking event is
+// in flight (and the event queued behind it has been read)
+func runUntilStarted(t *testing.T, tc *shutdownTestClient) (<-chan error, context.CancelFunc) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- tc.Run(ctx) }()
+
+	select {
+	case <-tc.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Blocking event never started")
+	}
+	time.Sleep(50 * time.Millisecond) // let the reader queue the third event
+	return errCh, cancel
+}
+
+func TestRunDrainsInFlightEventOnCancel(t *testing.T) {
+	tc := newShutdownTestClient(t, shutdownTestServer(t, shutdownTestEvents()), 2000, 5*time.Second)
+	errCh, cancel := runUntilStarted(t, tc)
+
+	// Cancel mid-event; the event finishes shortly after
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(tc.release)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	if got := tc.lastCursor(); got != 2000 {
+		t.Errorf("Expected cursor at last fully-processed event 2000, got %d", got)
+	}
+	if handled := tc.handledEvents(); len(handled) != 2 || handled[1] != 2000 {
+		t.Errorf("Expected events [1000 2000] handled and the queued one dropped, got %v", handled)
+	}
+}
+
+func TestRunDrainTimeoutKeepsCursorAtCompletedEvent(t *testing.T) {
+	tc := newShutdownTestClient(t, shutdownTestServer(t, shutdownTestEvents()), 2000, 100*time.Millisecond)
+	defer close(tc.release)
+	errCh, cancel := runUntilStarted(t, tc)
+
+	// The in-flight event never finishes within the drain timeout
+	start := time.Now()
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not give up after the drain timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected Run to return soon after the drain timeout, took %v", elapsed)
+	}
+
+	if got := tc.lastCursor(); got != 1000 {
+		t.Errorf("Expected cursor at last fully-processed event 1000, got %d", got)
+	}
+}
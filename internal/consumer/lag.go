@@ -0,0 +1,67 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+)
+
+// LagStatus reports how far behind the consumer is, for the /health endpoint
+type LagStatus struct {
+	Status             string     `json:"status"`
+	LagSeconds         float64    `json:"lagSeconds"`         // -1 if no events yet
+	LastEventTimestamp float64    `json:"lastEventTimestamp"` // Unix seconds, -1 if no events yet
+	LastEventTime      *time.Time `json:"lastEventTime,omitempty"`
+}
+
+// lagTracker remembers the last event time seen by the consumer
+type lagTracker struct {
+	mu          sync.Mutex
+	lastEventUs int64
+	lag         time.Duration
+}
+
+// defaultLag is shared by the Jetstream client and the health handler
+var defaultLag = &lagTracker{}
+
+// observe records an event's time_us and updates the lag gauges
+func (l *lagTracker) observe(timeUs int64, now time.Time) {
+	if timeUs <= 0 {
+		return
+	}
+
+	lag := now.Sub(time.UnixMicro(timeUs))
+	if lag < 0 {
+		lag = 0 // Future events shouldn't happen but handle gracefully
+	}
+
+	l.mu.Lock()
+	l.lastEventUs = timeUs
+	l.lag = lag
+	l.mu.Unlock()
+
+	LagSeconds.Set(lag.Seconds())
+	LastEventTimestamp.Set(float64(timeUs) / 1e6)
+}
+
+// status returns the current lag, or -1 values if no events have been seen
+func (l *lagTracker) status() LagStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lastEventUs == 0 {
+		return LagStatus{Status: "ok", LagSeconds: -1, LastEventTimestamp: -1}
+	}
+
+	lastEvent := time.UnixMicro(l.lastEventUs).UTC()
+	return LagStatus{
+		Status:             "ok",
+		LagSeconds:         l.lag.Seconds(),
+		LastEventTimestamp: float64(l.lastEventUs) / 1e6,
+		LastEventTime:      &lastEvent,
+	}
+}
+
+// CurrentLag returns the consumer's lag as of the last handled event
+func CurrentLag() LagStatus {
+	return defaultLag.status()
+}
@@ -0,0 +1,63 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLagTracker(t *testing.T) {
+	t.Run("reports -1 before any events", func(t *testing.T) {
+		l := &lagTracker{}
+
+		status := l.status()
+		if status.LagSeconds != -1 {
+			t.Errorf("Expected lag -1, got %v", status.LagSeconds)
+		}
+		if status.LastEventTimestamp != -1 {
+			t.Errorf("Expected last event timestamp -1, got %v", status.LastEventTimestamp)
+		}
+		if status.LastEventTime != nil {
+			t.Errorf("Expected no last event time, got %v", status.LastEventTime)
+		}
+	})
+
+	t.Run("computes lag from event time_us", func(t *testing.T) {
+		l := &lagTracker{}
+		now := time.Unix(1700000100, 0)
+		eventTime := now.Add(-90 * time.Second)
+
+		l.observe(eventTime.UnixMicro(), now)
+
+		status := l.status()
+		if status.LagSeconds != 90 {
+			t.Errorf("Expected lag 90s, got %v", status.LagSeconds)
+		}
+		if status.LastEventTimestamp != float64(eventTime.Unix()) {
+			t.Errorf("Expected last event timestamp %d, got %v", eventTime.Unix(), status.LastEventTimestamp)
+		}
+		if status.LastEventTime == nil || !status.LastEventTime.Equal(eventTime) {
+			t.Errorf("Expected last event time %v, got %v", eventTime, status.LastEventTime)
+		}
+	})
+
+	t.Run("clamps future events to zero lag", func(t *testing.T) {
+		l := &lagTracker{}
+		now := time.Unix(1700000100, 0)
+
+		l.observe(now.Add(time.Minute).UnixMicro(), now)
+
+		if got := l.status().LagSeconds; got != 0 {
+			t.Errorf("Expected lag 0 for future event, got %v", got)
+		}
+	})
+
+	t.Run("ignores events without time_us", func(t *testing.T) {
+		l := &lagTracker{}
+
+		l.observe(0, time.Now())
+
+		if got := l.status().LagSeconds; got != -1 {
+			t.Errorf("Expected lag -1, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package consumer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	// DefaultMaxRecordBytes caps the serialized size of a record
+	DefaultMaxRecordBytes = 256 * 1024
+
+	// DefaultMaxQuestions caps the number of questions in a survey record
+	DefaultMaxQuestions = 100
+
+	// DefaultMaxOptionsPerQuestion caps the options (or rating labels) on a question
+	DefaultMaxOptionsPerQuestion = 50
+
+	// DefaultMaxTextLength caps survey names, descriptions, question text,
+	// option text, and rating labels, in bytes
+	DefaultMaxTextLength = 10000
+)
+
+// Limit names reported in LimitError and RecordsOverLimit
+const (
+	LimitRecordSize = "record_size"
+	LimitQuestions  = "questions"
+	LimitOptions    = "options"
+	LimitTextLength = "text_length"
+)
+
+// Limits bounds the records the consumer will parse, so an oversized record
+// is rejected before it reaches the database. The survey definition is still
+// validated against the (stricter) models limits after parsing; these guard
+// the parser itself. Zero fields use the defaults.
+type Limits struct {
+	MaxRecordBytes        int
+	MaxQuestions          int
+	MaxOptionsPerQuestion int
+	MaxTextLength         int
+}
+
+// DefaultLimits returns the limits used when none are configured
+func DefaultLimits() Limits {
+	return Limits{
+		MaxRecordBytes:        DefaultMaxRecordBytes,
+		MaxQuestions:          DefaultMaxQuestions,
+		MaxOptionsPerQuestion: DefaultMaxOptionsPerQuestion,
+		MaxTextLength:         DefaultMaxTextLength,
+	}
+}
+
+// LimitsFromEnv reads limits from CONSUMER_MAX_RECORD_BYTES,
+// CONSUMER_MAX_QUESTIONS, CONSUMER_MAX_OPTIONS, and CONSUMER_MAX_TEXT_LENGTH.
+// Unset variables keep their defaults.
+func LimitsFromEnv() (Limits, error) {
+	limits := DefaultLimits()
+
+	vars := []struct {
+		name  string
+		value *int
+	}{
+		{"CONSUMER_MAX_RECORD_BYTES", &limits.MaxRecordBytes},
+		{"CONSUMER_MAX_QUESTIONS", &limits.MaxQuestions},
+		{"CONSUMER_MAX_OPTIONS", &limits.MaxOptionsPerQuestion},
+		{"CONSUMER_MAX_TEXT_LENGTH", &limits.MaxTextLength},
+	}
+	for _, v := range vars {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Limits{}, fmt.Errorf("invalid %s: %q", v.name, raw)
+		}
+		*v.value = n
+	}
+
+	return limits, nil
+}
+
+// withDefaults fills zero fields from DefaultLimits
+func (l Limits) withDefaults() Limits {
+	defaults := DefaultLimits()
+	if l.MaxRecordBytes <= 0 {
+		l.MaxRecordBytes = defaults.MaxRecordBytes
+	}
+	if l.MaxQuestions <= 0 {
+		l.MaxQuestions = defaults.MaxQuestions
+	}
+	if l.MaxOptionsPerQuestion <= 0 {
+		l.MaxOptionsPerQuestion = defaults.MaxOptionsPerQuestion
+	}
+	if l.MaxTextLength <= 0 {
+		l.MaxTextLength = defaults.MaxTextLength
+	}
+	return l
+}
+
+// ErrLimitExceeded marks records rejected for exceeding a Limits bound
+var ErrLimitExceeded = errors.New("record limit exceeded")
+
+// LimitError reports which limit a record exceeded. It matches both
+// ErrLimitExceeded and ErrInvalidRecord.
+type LimitError struct {
+	Limit string // one of the Limit* constants
+	Field string // where in the record, e.g. "question 3 text"
+	Size  int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeds %s limit: %d > %d", e.Field, e.Limit, e.Size, e.Max)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded || target == ErrInvalidRecord
+}
+
+// checkRecordSize rejects records whose JSON encoding exceeds MaxRecordBytes
+func (l Limits) checkRecordSize(record map[string]interface{}) error {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return invalidRecord(fmt.Errorf("failed to encode record: %w", err))
+	}
+	if len(encoded) > l.MaxRecordBytes {
+		return &LimitError{Limit: LimitRecordSize, Field: "record", Size: len(encoded), Max: l.MaxRecordBytes}
+	}
+	return nil
+}
+
+// checkCount rejects counts above max
+func checkCount(limit, field string, count, max int) error {
+	if count > max {
+		return &LimitError{Limit: limit, Field: field, Size: count, Max: max}
+	}
+	return nil
+}
+
+// checkText rejects text longer than MaxTextLength bytes
+func (l Limits) checkText(field, text string) error {
+	return checkCount(LimitTextLength, field, len(text), l.MaxTextLength)
+}
@@ -0,0 +1,229 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLimitsFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		for _, name := range []string{"CONSUMER_MAX_RECORD_BYTES", "CONSUMER_MAX_QUESTIONS", "CONSUMER_MAX_OPTIONS", "CONSUMER_MAX_TEXT_LENGTH"} {
+			t.Setenv(name, "")
+		}
+		limits, err := LimitsFromEnv()
+		if err != nil {
+			t.Fatalf("LimitsFromEnv failed: %v", err)
+		}
+		if limits != DefaultLimits() {
+			t.Errorf("Expected defaults %+v, got %+v", DefaultLimits(), limits)
+		}
+		if limits.MaxQuestions != 100 || limits.MaxOptionsPerQuestion != 50 {
+			t.Errorf("Unexpected default limits %+v", limits)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("CONSUMER_MAX_RECORD_BYTES", "1024")
+		t.Setenv("CONSUMER_MAX_QUESTIONS", "10")
+		t.Setenv("CONSUMER_MAX_OPTIONS", "5")
+		t.Setenv("CONSUMER_MAX_TEXT_LENGTH", "200")
+		limits, err := LimitsFromEnv()
+		if err != nil {
+			t.Fatalf("LimitsFromEnv failed: %v", err)
+		}
+		want := Limits{MaxRecordBytes: 1024, MaxQuestions: 10, MaxOptionsPerQuestion: 5, MaxTextLength: 200}
+		if limits != want {
+			t.Errorf("Expected %+v, got %+v", want, limits)
+		}
+	})
+
+	for _, value := range []string{"abc", "0", "-5"} {
+		t.Run("rejects "+value, func(t *testing.T) {
+			t.Setenv("CONSUMER_MAX_QUESTIONS", value)
+			if _, err := LimitsFromEnv(); err == nil {
+				t.Errorf("Expected error for CONSUMER_MAX_QUESTIONS=%q", value)
+			}
+		})
+	}
+}
+
+// limitsTestRecord builds a survey record with the given number of questions,
+// options per question, and question text
+func limitsTestRecord(questions, options int, text string) map[string]interface{} {
+	qs := make([]interface{}, 0, questions)
+	for i := 0; i < questions; i++ {
+		opts := make([]interface{}, 0, options)
+		for j := 0; j < options; j++ {
+			opts = append(opts, map[string]interface{}{"id": fmt.Sprintf("o%d", j), "text": "Option"})
+		}
+		qs = append(qs, map[string]interface{}{
+			"id":      fmt.Sprintf("q%d", i),
+			"text":    text,
+			"type":    "net.openmeet.survey#single",
+			"options": opts,
+		})
+	}
+	return map[string]interface{}{
+		"name":      "Limits",
+		"questions": qs,
+	}
+}
+
+func TestParseSurveyRecordLimits(t *testing.T) {
+	limits := Limits{MaxRecordBytes: 1 << 20, MaxQuestions: 5, MaxOptionsPerQuestion: 3, MaxTextLength: 20}
+
+	tests := []struct {
+		name      string
+		record    map[string]interface{}
+		wantLimit string // empty if the record is within limits
+	}{
+		{"questions at limit", limitsTestRecord(5, 2, "Question?"), ""},
+		{"questions over limit", limitsTestRecord(6, 2, "Question?"), LimitQuestions},
+		{"options at limit", limitsTestRecord(1, 3, "Question?"), ""},
+		{"options over limit", limitsTestRecord(1, 4, "Question?"), LimitOptions},
+		{"question text at limit", limitsTestRecord(1, 2, strings.Repeat("x", 20)), ""},
+		{"question text over limit", limitsTestRecord(1, 2, strings.Repeat("x", 21)), LimitTextLength},
+		{"name over limit", func() map[string]interface{} {
+			r := limitsTestRecord(1, 2, "Question?")
+			r["name"] = strings.Repeat("n", 21)
+			return r
+		}(), LimitTextLength},
+		{"description over limit", func() map[string]interface{} {
+			r := limitsTestRecord(1, 2, "Question?")
+			r["description"] = strings.Repeat("d", 21)
+			return r
+		}(), LimitTextLength},
+		{"option text over limit", func() map[string]interface{} {
+			r := limitsTestRecord(1, 2, "Question?")
+			q := r["questions"].([]interface{})[0].(map[string]interface{})
+			q["options"].([]interface{})[1].(map[string]interface{})["text"] = strings.Repeat("o", 21)
+			return r
+		}(), LimitTextLength},
+		{"rating labels over limit", func() map[string]interface{} {
+			r := limitsTestRecord(1, 0, "Question?")
+			q := r["questions"].([]interface{})[0].(map[string]interface{})
+			q["labels"] = []interface{}{"a", "b", "c", "d"}
+			return r
+		}(), LimitOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, _, _, err := ParseSurveyRecordWithLimits(tt.record, limits)
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Errorf("Expected record within limits to parse, got %v", err)
+				}
+				return
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Expected *LimitError, got %v", err)
+			}
+			if limitErr.Limit != tt.wantLimit {
+				t.Errorf("Expected limit %q, got %q", tt.wantLimit, limitErr.Limit)
+			}
+			if !errors.Is(err, ErrLimitExceeded) || !errors.Is(err, ErrInvalidRecord) {
+				t.Errorf("Expected error to match ErrLimitExceeded and ErrInvalidRecord")
+			}
+		})
+	}
+}
+
+func TestParseSurveyRecordDefaultLimits(t *testing.T) {
+	if _, _, _, _, _, err := ParseSurveyRecord(limitsTestRecord(DefaultMaxQuestions, 1, "Q?")); err != nil {
+		t.Errorf("Expected %d questions to parse, got %v", DefaultMaxQuestions, err)
+	}
+	if _, _, _, _, _, err := ParseSurveyRecord(limitsTestRecord(DefaultMaxQuestions+1, 1, "Q?")); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected %d questions to exceed the limit, got %v", DefaultMaxQuestions+1, err)
+	}
+	if _, _, _, _, _, err := ParseSurveyRecord(limitsTestRecord(1, DefaultMaxOptionsPerQuestion+1, "Q?")); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected %d options to exceed the limit, got %v", DefaultMaxOptionsPerQuestion+1, err)
+	}
+}
+
+func TestCheckRecordSize(t *testing.T) {
+	record := limitsTestRecord(2, 2, "Question?")
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	size := len(encoded)
+
+	if err := (Limits{MaxRecordBytes: size}).checkRecordSize(record); err != nil {
+		t.Errorf("Expected record of exactly %d bytes to pass, got %v", size, err)
+	}
+
+	err = (Limits{MaxRecordBytes: size - 1}).checkRecordSize(record)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitRecordSize {
+		t.Fatalf("Expected record_size LimitError, got %v", err)
+	}
+	if limitErr.Size != size || limitErr.Max != size-1 {
+		t.Errorf("Expected size %d > %d, got %d > %d", size, size-1, limitErr.Size, limitErr.Max)
+	}
+}
+
+// TestProcessMessageRejectsOversizedRecords tests that limit violations take the ingest-failure path
+func TestProcessMessageRejectsOversizedRecords(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	ctx := context.Background()
+	run := uuid.NewString()[:8]
+
+	message := func(record map[string]interface{}) *JetstreamMessage {
+		rkey := "limits-" + uuid.NewString()[:8]
+		return &JetstreamMessage{
+			Kind: "commit",
+			Did:  "did:plc:limits" + run,
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Collection: "net.openmeet.survey",
+				RKey:       rkey,
+				CID:        "bafy_" + rkey,
+				Record:     record,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		limits Limits
+		record map[string]interface{}
+		limit  string
+	}{
+		{"record size", Limits{MaxRecordBytes: 64}, limitsTestRecord(2, 2, "Question?"), LimitRecordSize},
+		{"questions", Limits{MaxQuestions: 1}, limitsTestRecord(2, 2, "Question?"), LimitQuestions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(queries).WithLimits(tt.limits)
+			counter := RecordsOverLimit.WithLabelValues("net.openmeet.survey", tt.limit)
+			events := EventsTotal.WithLabelValues("net.openmeet.survey", "create", ResultLimitExceeded)
+			before, beforeEvents := testutil.ToFloat64(counter), testutil.ToFloat64(events)
+
+			err := processor.ProcessMessage(ctx, message(tt.record))
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("Expected limit exceeded error, got %v", err)
+			}
+			if got := testutil.ToFloat64(counter); got != before+1 {
+				t.Errorf("Expected over-limit counter %v, got %v", before+1, got)
+			}
+			if got := testutil.ToFloat64(events); got != beforeEvents+1 {
+				t.Errorf("Expected limit_exceeded events %v, got %v", beforeEvents+1, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package consumer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Event results for EventsTotal
+const (
+	ResultOK         = "ok"
+	ResultParseError = "parse_error"
+	ResultDBError    = "db_error"
+	ResultSkipped    = "skipped"
+	ResultDuplicate  = "duplicate" // replay of a record version already indexed
+
+	// ResultLimitExceeded is a parse error caused by a record exceeding Limits
+	ResultLimitExceeded = "limit_exceeded"
+)
+
+// Consumer metrics are registered here rather than in the telemetry package so
+// any binary that embeds the consumer gets them without extra wiring.
+var (
+	// EventsTotal counts processed commit events by outcome
+	EventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_events_total",
+			Help: "Total number of Jetstream commit events handled by the consumer",
+		},
+		[]string{"collection", "operation", "result"}, // result: ok, parse_error, limit_exceeded, db_error, skipped, duplicate
+	)
+
+	// EventDuration tracks time spent handling each commit event
+	EventDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "survey_consumer_event_duration_seconds",
+			Help:    "Time to handle a Jetstream commit event",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"collection", "operation"},
+	)
+
+	// AccountActions counts hide/unhide/purge actions taken for account events
+	AccountActions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_account_actions_total",
+			Help: "Total number of account events that hid, restored, or purged indexed records",
+		},
+		[]string{"action"}, // action: hide, unhide, purge
+	)
+
+	// SurveysPurged counts soft-deleted surveys permanently removed after the grace period
+	SurveysPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "survey_consumer_surveys_purged_total",
+		Help: "Total number of soft-deleted surveys purged after the delete grace period",
+	})
+
+	// ResponsesRejected counts ingested responses whose answers don't match the survey
+	ResponsesRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_responses_rejected_total",
+			Help: "Total number of response records rejected by answer validation",
+		},
+		[]string{"reason"}, // reason: unknown_question, unknown_option, multiple_selections, too_many_selections, wrong_answer_type, text_too_long, rating_out_of_range, unexpected_other_text
+	)
+
+	// BlockedEvents counts commit events skipped because the repo DID is blocked
+	BlockedEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_blocked_events_total",
+			Help: "Total number of commit events skipped because the repo DID is on the blocklist",
+		},
+		[]string{"collection"},
+	)
+
+	// RecordsOverLimit counts records rejected for exceeding a size limit
+	RecordsOverLimit = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_records_over_limit_total",
+			Help: "Total number of records rejected for exceeding a consumer size limit",
+		},
+		[]string{"collection", "limit"}, // limit: record_size, questions, options, text_length
+	)
+
+	// ResponseBatchSize tracks how many responses each batched insert wrote
+	ResponseBatchSize = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "survey_consumer_response_batch_size",
+			Help:    "Number of responses in each batched insert",
+			Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000},
+		},
+	)
+
+	// ResponseBatchFallbacks counts batches that failed and were retried row by row
+	ResponseBatchFallbacks = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_response_batch_fallbacks_total",
+			Help: "Total number of batched response inserts retried as per-row inserts after failing",
+		},
+	)
+
+	// LagSeconds is how far behind wall clock the last event was when handled.
+	// Reports -1 until the first event arrives after startup.
+	LagSeconds = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "survey_consumer_lag_seconds",
+			Help: "Seconds between the last event's time_us and when it was handled (-1 if no events yet)",
+		},
+	)
+
+	// LastEventTimestamp is the time_us of the last event as Unix seconds.
+	// Reports -1 until the first event arrives after startup.
+	LastEventTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "survey_consumer_last_event_timestamp_seconds",
+			Help: "Unix timestamp of the last event seen by the consumer (-1 if no events yet)",
+		},
+	)
+)
+
+func init() {
+	// Avoid reporting a misleading zero lag before any events arrive
+	LagSeconds.Set(-1)
+	LastEventTimestamp.Set(-1)
+}
+
+// ErrInvalidRecord marks errors caused by the event itself (unparseable or
+// invalid record, unauthorized author) rather than by our database
+var ErrInvalidRecord = errors.New("invalid record")
+
+// errAlreadyIndexed is returned by handlers when the event carries a record
+// version (same URI and CID) that is already indexed. ProcessMessage turns it
+// into a no-op so replays after a restart don't rewrite or double-count.
+var errAlreadyIndexed = errors.New("record already indexed at this CID")
+
+// recordError wraps an error so errors.Is(err, ErrInvalidRecord) reports true
+// while keeping the original message
+type recordError struct {
+	err error
+}
+
+func (e *recordError) Error() string { return e.err.Error() }
+
+func (e *recordError) Unwrap() error { return e.err }
+
+func (e *recordError) Is(target error) bool { return target == ErrInvalidRecord }
+
+// invalidRecord marks err as caused by a bad record
+func invalidRecord(err error) error {
+	return &recordError{err: err}
+}
+
+// eventResult maps a handler error to an EventsTotal result label
+func eventResult(err error) string {
+	switch {
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, ErrLimitExceeded):
+		return ResultLimitExceeded
+	case errors.Is(err, ErrInvalidRecord):
+		return ResultParseError
+	default:
+		return ResultDBError
+	}
+}
+
+// recordEvent records the outcome and duration of a handled commit event
+func recordEvent(commit *JetstreamCommit, result string, duration time.Duration) {
+	EventsTotal.WithLabelValues(commit.Collection, commit.Operation, result).Inc()
+	if result != ResultSkipped && result != ResultDuplicate {
+		EventDuration.WithLabelValues(commit.Collection, commit.Operation).Observe(duration.Seconds())
+	}
+}
@@ -0,0 +1,140 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEventMetrics(t *testing.T) {
+	processor := NewProcessor(nil) // no DB access needed for these events
+	ctx := context.Background()
+
+	t.Run("counts parse errors", func(t *testing.T) {
+		counter := EventsTotal.WithLabelValues("net.openmeet.survey", "create", ResultParseError)
+		before := testutil.ToFloat64(counter)
+
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Did:  "did:plc:metrics",
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Collection: "net.openmeet.survey",
+				RKey:       "metrics1",
+			},
+			TimeUs: 1234567920,
+		}
+
+		if err := processor.ProcessMessage(ctx, msg); err == nil {
+			t.Fatal("Expected error for create without record")
+		}
+
+		if got := testutil.ToFloat64(counter); got != before+1 {
+			t.Errorf("Expected parse_error counter %v, got %v", before+1, got)
+		}
+		if testutil.CollectAndCount(EventDuration) == 0 {
+			t.Error("Expected event duration to be observed")
+		}
+	})
+
+	t.Run("counts skipped collections", func(t *testing.T) {
+		counter := EventsTotal.WithLabelValues("other", "create", ResultSkipped)
+		before := testutil.ToFloat64(counter)
+
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Did:  "did:plc:metrics",
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Collection: "app.bsky.feed.post",
+				RKey:       "metrics2",
+			},
+			TimeUs: 1234567921,
+		}
+
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Expected skipped event without error, got: %v", err)
+		}
+
+		if got := testutil.ToFloat64(counter); got != before+1 {
+			t.Errorf("Expected skipped counter %v, got %v", before+1, got)
+		}
+	})
+
+	t.Run("ignores non-commit events", func(t *testing.T) {
+		before := testutil.CollectAndCount(EventsTotal)
+
+		msg := &JetstreamMessage{Kind: "identity", Did: "did:plc:metrics", TimeUs: 1234567922}
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+
+		if got := testutil.CollectAndCount(EventsTotal); got != before {
+			t.Errorf("Expected no new event series, got %d (was %d)", got, before)
+		}
+	})
+}
+
+func TestEventResult(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil error", nil, ResultOK},
+		{"invalid record", invalidRecord(errors.New("bad")), ResultParseError},
+		{"wrapped invalid record", fmt.Errorf("failed to process message: %w", invalidRecord(errors.New("bad"))), ResultParseError},
+		{"database error", errors.New("connection refused"), ResultDBError},
+		{"limit exceeded", invalidRecord(fmt.Errorf("failed to parse survey record: %w", &LimitError{Limit: LimitQuestions, Field: "questions", Size: 101, Max: 100})), ResultLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventResult(tt.err); got != tt.want {
+				t.Errorf("eventResult() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResponseAnswersCountsRejections(t *testing.T) {
+	def := &models.SurveyDefinition{
+		Questions: []models.Question{
+			{
+				ID:   "q1",
+				Text: "Pick one",
+				Type: models.QuestionTypeSingle,
+				Options: []models.Option{
+					{ID: "a", Text: "A"},
+					{ID: "b", Text: "B"},
+				},
+			},
+		},
+	}
+
+	counter := ResponsesRejected.WithLabelValues(models.RejectMultipleSelections)
+	before := testutil.ToFloat64(counter)
+
+	err := validateResponseAnswers(def, map[string]models.Answer{
+		"q1": {SelectedOptions: []string{"a", "b"}},
+	})
+	if err == nil {
+		t.Fatal("Expected error for multiple selections on single choice")
+	}
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("Expected rejection to be an invalid record, got: %v", err)
+	}
+	if got := testutil.ToFloat64(counter); got != before+1 {
+		t.Errorf("Expected rejected counter %v, got %v", before+1, got)
+	}
+
+	if err := validateResponseAnswers(def, map[string]models.Answer{
+		"q1": {SelectedOptions: []string{"a"}},
+	}); err != nil {
+		t.Errorf("Expected valid answers to pass, got: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWorkers processes events one at a time, in order
+const DefaultWorkers = 1
+
+// WorkerPool processes events concurrently while keeping events for the same
+// repo DID in order: each DID is hashed to a single worker. It reports a low
+// watermark, the latest time_us at or before which every submitted event has
+// completed, so the cursor never skips past an event that is still in flight.
+type WorkerPool struct {
+	queues    []chan *JetstreamMessage
+	handle    func(ctx context.Context, msg *JetstreamMessage)
+	onAdvance func(timeUs int64)
+	watermark *watermarkTracker
+
+	advanceMu sync.Mutex
+	reported  int64
+
+	draining atomic.Bool // set by Drain: skip events that haven't started
+	wg       sync.WaitGroup
+}
+
+// NewWorkerPool creates a pool with size workers. handle is called for every
+// event; onAdvance is called (serially, with increasing values) whenever the
+// low watermark moves forward.
+func NewWorkerPool(size int, handle func(ctx context.Context, msg *JetstreamMessage), onAdvance func(timeUs int64)) *WorkerPool {
+	if size < 1 {
+		size = DefaultWorkers
+	}
+
+	queues := make([]chan *JetstreamMessage, size)
+	for i := range queues {
+		// Small per-worker buffer; Submit blocks when a worker falls behind
+		queues[i] = make(chan *JetstreamMessage, 64)
+	}
+
+	return &WorkerPool{
+		queues:    queues,
+		handle:    handle,
+		onAdvance: onAdvance,
+		watermark: newWatermarkTracker(),
+	}
+}
+
+// Start launches the workers. They run until Close or Drain is called.
+func (p *WorkerPool) Start(ctx context.Context) {
+	for _, queue := range p.queues {
+		p.wg.Add(1)
+		go p.work(ctx, queue)
+	}
+}
+
+// Submit queues an event on the worker that owns its repo DID.
+// Blocks while that worker's queue is full; returns ctx.Err() if cancelled.
+func (p *WorkerPool) Submit(ctx context.Context, msg *JetstreamMessage) error {
+	msg.seq = p.watermark.add(msg.TimeUs)
+
+	select {
+	case p.queues[p.workerFor(messageDID(msg))] <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits for queued events to finish
+func (p *WorkerPool) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
+
+// Drain stops accepting events and waits up to timeout for the events
+// already being processed. Queued events that haven't started are dropped;
+// they never complete, so the watermark stays before them and they're
+// replayed on restart. Reports whether the in-flight events finished in time.
+func (p *WorkerPool) Drain(timeout time.Duration) bool {
+	p.draining.Store(true)
+	for _, queue := range p.queues {
+		close(queue)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// work processes events from a single queue in order
+func (p *WorkerPool) work(ctx context.Context, queue <-chan *JetstreamMessage) {
+	defer p.wg.Done()
+
+	for msg := range queue {
+		if p.draining.Load() {
+			continue
+		}
+		p.handle(ctx, msg)
+
+		if timeUs, advanced := p.watermark.done(msg.seq); advanced {
+			p.advance(timeUs)
+		}
+	}
+}
+
+// advance reports a new watermark, dropping stale values from racing workers
+func (p *WorkerPool) advance(timeUs int64) {
+	p.advanceMu.Lock()
+	defer p.advanceMu.Unlock()
+
+	if timeUs <= p.reported {
+		return
+	}
+	p.reported = timeUs
+	p.onAdvance(timeUs)
+}
+
+// workerFor hashes a DID to a worker index
+func (p *WorkerPool) workerFor(did string) int {
+	if len(p.queues) == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(did))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// messageDID returns the repo DID an event belongs to
+func messageDID(msg *JetstreamMessage) string {
+	if msg.Did != "" {
+		return msg.Did
+	}
+	if msg.Commit != nil {
+		return msg.Commit.Repo
+	}
+	return ""
+}
+
+// watermarkTracker tracks in-flight events in submission order
+type watermarkTracker struct {
+	mu      sync.Mutex
+	nextSeq uint64
+	pending []*pendingEvent // oldest first
+	byID    map[uint64]*pendingEvent
+}
+
+type pendingEvent struct {
+	timeUs int64
+	done   bool
+}
+
+func newWatermarkTracker() *watermarkTracker {
+	return &watermarkTracker{byID: make(map[uint64]*pendingEvent)}
+}
+
+// add registers a submitted event and returns its sequence number
+func (w *watermarkTracker) add(timeUs int64) uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextSeq++
+	event := &pendingEvent{timeUs: timeUs}
+	w.pending = append(w.pending, event)
+	w.byID[w.nextSeq] = event
+	return w.nextSeq
+}
+
+// done marks an event complete. If every earlier event is also complete, it
+// returns the time_us of the latest contiguous completed event and true.
+func (w *watermarkTracker) done(seq uint64) (int64, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	event, ok := w.byID[seq]
+	if !ok {
+		return 0, false
+	}
+	event.done = true
+	delete(w.byID, seq)
+
+	var watermark int64
+	advanced := false
+	for len(w.pending) > 0 && w.pending[0].done {
+		watermark = w.pending[0].timeUs
+		w.pending[0] = nil
+		w.pending = w.pending[1:]
+		advanced = true
+	}
+
+	return watermark, advanced
+}
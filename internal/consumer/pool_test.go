@@ -0,0 +1,260 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatermarkTracker(t *testing.T) {
+	t.Run("advances only past contiguous completed events", func(t *testing.T) {
+		w := newWatermarkTracker()
+		first := w.add(100)
+		second := w.add(200)
+		third := w.add(300)
+
+		if _, advanced := w.done(second); advanced {
+			t.Error("Watermark should not advance while an earlier event is in flight")
+		}
+		if _, advanced := w.done(third); advanced {
+			t.Error("Watermark should not advance while an earlier event is in flight")
+		}
+
+		got, advanced := w.done(first)
+		if !advanced {
+			t.Fatal("Expected watermark to advance once the earliest event completed")
+		}
+		if got != 300 {
+			t.Errorf("Expected watermark 300, got %d", got)
+		}
+	})
+
+	t.Run("advances event by event in order", func(t *testing.T) {
+		w := newWatermarkTracker()
+		first := w.add(100)
+		second := w.add(200)
+
+		if got, advanced := w.done(first); !advanced || got != 100 {
+			t.Errorf("Expected watermark 100, got %d (advanced=%v)", got, advanced)
+		}
+		if got, advanced := w.done(second); !advanced || got != 200 {
+			t.Errorf("Expected watermark 200, got %d (advanced=%v)", got, advanced)
+		}
+	})
+
+	t.Run("ignores unknown sequence numbers", func(t *testing.T) {
+		w := newWatermarkTracker()
+		if _, advanced := w.done(42); advanced {
+			t.Error("Unknown sequence should not advance watermark")
+		}
+	})
+}
+
+func TestNewWorkerPoolDefaultsSize(t *testing.T) {
+	pool := NewWorkerPool(0, func(context.Context, *JetstreamMessage) {}, func(int64) {})
+	if len(pool.queues) != DefaultWorkers {
+		t.Errorf("Expected %d worker(s), got %d", DefaultWorkers, len(pool.queues))
+	}
+}
+
+// TestWorkerPoolStress interleaves creates, updates, and deletes for many
+// records across a handful of DIDs and checks that each DID's events are
+// applied in submission order and the watermark ends at the last event.
+func TestWorkerPoolStress(t *testing.T) {
+	const (
+		workers       = 8
+		dids          = 5
+		recordsPerDID = 20
+	)
+
+	type recordState int
+	const (
+		absent recordState = iota
+		created
+		deleted
+	)
+
+	var (
+		mu         sync.Mutex
+		state      = make(map[string]recordState) // did/rkey -> state
+		lastSeen   = make(map[string]int64)       // did -> last time_us handled
+		violations []string
+	)
+
+	handle := func(ctx context.Context, msg *JetstreamMessage) {
+		// Random delay so workers finish out of order
+		time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		did := msg.Did
+		if msg.TimeUs <= lastSeen[did] {
+			violations = append(violations, fmt.Sprintf("%s: event %d handled after %d", did, msg.TimeUs, lastSeen[did]))
+		}
+		lastSeen[did] = msg.TimeUs
+
+		key := did + "/" + msg.Commit.RKey
+		switch msg.Commit.Operation {
+		case "create":
+			if state[key] != absent {
+				violations = append(violations, "create of existing record "+key)
+			}
+			state[key] = created
+		case "update":
+			if state[key] != created {
+				violations = append(violations, "update of missing record "+key)
+			}
+		case "delete":
+			if state[key] != created {
+				violations = append(violations, "delete of missing record "+key)
+			}
+			state[key] = deleted
+		}
+	}
+
+	var (
+		advanceMu sync.Mutex
+		advances  []int64
+	)
+	onAdvance := func(timeUs int64) {
+		advanceMu.Lock()
+		advances = append(advances, timeUs)
+		advanceMu.Unlock()
+	}
+
+	// Build create -> update -> update -> delete per record, interleaved across DIDs
+	type op struct {
+		did, rkey, operation string
+	}
+	var ops []op
+	for r := 0; r < recordsPerDID; r++ {
+		for _, operation := range []string{"create", "update", "update", "delete"} {
+			for d := 0; d < dids; d++ {
+				ops = append(ops, op{
+					did:       fmt.Sprintf("did:plc:stress%d", d),
+					rkey:      fmt.Sprintf("rec%d", r),
+					operation: operation,
+				})
+			}
+		}
+	}
+
+	ctx := context.Background()
+	pool := NewWorkerPool(workers, handle, onAdvance)
+	pool.Start(ctx)
+
+	var lastTimeUs int64
+	for i, o := range ops {
+		lastTimeUs = int64(1000 + i)
+		msg := &JetstreamMessage{
+			Kind:   "commit",
+			Did:    o.did,
+			TimeUs: lastTimeUs,
+			Commit: &JetstreamCommit{
+				Operation:  o.operation,
+				Collection: "net.openmeet.survey",
+				RKey:       o.rkey,
+			},
+		}
+		if err := pool.Submit(ctx, msg); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+
+	pool.Close()
+
+	for _, v := range violations {
+		t.Error(v)
+	}
+
+	for key, s := range state {
+		if s != deleted {
+			t.Errorf("Expected %s to end deleted, got state %d", key, s)
+		}
+	}
+
+	if len(advances) == 0 {
+		t.Fatal("Expected watermark to advance")
+	}
+	for i := 1; i < len(advances); i++ {
+		if advances[i] <= advances[i-1] {
+			t.Errorf("Watermark went backwards: %d after %d", advances[i], advances[i-1])
+		}
+	}
+	if got := advances[len(advances)-1]; got != lastTimeUs {
+		t.Errorf("Expected final watermark %d, got %d", lastTimeUs, got)
+	}
+}
+
+func TestWorkerPoolSubmitCancelled(t *testing.T) {
+	block := make(chan struct{})
+	pool := NewWorkerPool(1, func(context.Context, *JetstreamMessage) { <-block }, func(int64) {})
+	pool.Start(context.Background())
+	defer func() {
+		close(block)
+		pool.Close()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Fill the worker and its queue so the next Submit has to wait
+	var err error
+	for i := 0; i < 200 && err == nil; i++ {
+		err = pool.Submit(ctx, &JetstreamMessage{Did: "did:plc:blocked", TimeUs: int64(i + 1)})
+	}
+	if err == nil {
+		t.Fatal("Expected Submit to return an error once the context is cancelled and the queue is full")
+	}
+}
+
+func TestWorkerPoolDrain(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled []int64
+	var reported []int64
+
+	pool := NewWorkerPool(1, func(_ context.Context, msg *JetstreamMessage) {
+		if msg.TimeUs == 2 {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		handled = append(handled, msg.TimeUs)
+		mu.Unlock()
+	}, func(timeUs int64) {
+		mu.Lock()
+		reported = append(reported, timeUs)
+		mu.Unlock()
+	})
+	pool.Start(context.Background())
+
+	for i := int64(1); i <= 3; i++ {
+		if err := pool.Submit(context.Background(), &JetstreamMessage{Did: "did:plc:drain", TimeUs: i}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	<-started
+
+	// Times out while event 2 is still running
+	if pool.Drain(50 * time.Millisecond) {
+		t.Fatal("Expected Drain to time out while an event is in flight")
+	}
+
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(handled) != "[1 2]" {
+		t.Errorf("Expected in-flight event to finish and queued event to be dropped, got %v", handled)
+	}
+	if fmt.Sprint(reported) != "[1 2]" {
+		t.Errorf("Expected watermark to stop at the last completed event, got %v", reported)
+	}
+}
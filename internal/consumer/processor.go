@@ -3,6 +3,7 @@ package consumer
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,10 +15,14 @@ import (
 
 // JetstreamMessage represents a message from the Jetstream firehose
 type JetstreamMessage struct {
-	Did    string           `json:"did,omitempty"`
-	TimeUs int64            `json:"time_us"`
-	Kind   string           `json:"kind"`
-	Commit *JetstreamCommit `json:"commit,omitempty"`
+	Did      string             `json:"did,omitempty"`
+	TimeUs   int64              `json:"time_us"`
+	Kind     string             `json:"kind"`
+	Commit   *JetstreamCommit   `json:"commit,omitempty"`
+	Account  *JetstreamAccount  `json:"account,omitempty"`
+	Identity *JetstreamIdentity `json:"identity,omitempty"`
+
+	seq uint64 // Submission order within a WorkerPool
 }
 
 // JetstreamCommit represents the commit portion of a Jetstream message
@@ -29,22 +34,43 @@ type JetstreamCommit struct {
 	Record     map[string]interface{} `json:"record,omitempty"` // Present for create/update
 	CID        string                 `json:"cid,omitempty"`    // Present for create/update
 	Repo       string                 `json:"repo"`             // DID of the repo owner
+
+	timeUs int64 // Event time_us, copied from the message like Repo
 }
 
+// maxSlugInsertAttempts bounds retries when a survey insert loses a slug race
+const maxSlugInsertAttempts = 3
+
 // Processor handles processing of Jetstream messages
 type Processor struct {
 	queries *db.Queries
+	limits  Limits
+	batcher *responseBatcher // nil writes each new response directly
 }
 
-// NewProcessor creates a new Processor instance
+// NewProcessor creates a new Processor instance with DefaultLimits
 func NewProcessor(queries *db.Queries) *Processor {
 	return &Processor{
 		queries: queries,
+		limits:  DefaultLimits(),
 	}
 }
 
+// WithLimits sets the record size limits; zero fields keep their defaults
+func (p *Processor) WithLimits(limits Limits) *Processor {
+	p.limits = limits.withDefaults()
+	return p
+}
+
 // ProcessMessage processes a single Jetstream message
 func (p *Processor) ProcessMessage(ctx context.Context, msg *JetstreamMessage) error {
+	switch msg.Kind {
+	case "account":
+		return p.processAccountEvent(ctx, msg)
+	case "identity":
+		return p.processIdentityEvent(ctx, msg)
+	}
+
 	// Filter for commit messages only
 	if msg.Kind != "commit" || msg.Commit == nil {
 		return nil // Skip non-commit messages
@@ -54,17 +80,91 @@ func (p *Processor) ProcessMessage(ctx context.Context, msg *JetstreamMessage) e
 	if msg.Commit.Repo == "" && msg.Did != "" {
 		msg.Commit.Repo = msg.Did
 	}
+	msg.Commit.timeUs = msg.TimeUs
+
+	// Skip collections and operations we don't index
+	if !isSupportedCollection(msg.Commit.Collection) {
+		recordEvent(&JetstreamCommit{Collection: "other", Operation: msg.Commit.Operation}, ResultSkipped, 0)
+		return nil
+	}
+	if !isSupportedOperation(msg.Commit.Operation) {
+		recordEvent(msg.Commit, ResultSkipped, 0)
+		return nil
+	}
+
+	startTime := time.Now()
+
+	ctx, span := startEventSpan(ctx, msg)
+
+	// Reject missing and oversized records before spending any time (or a
+	// blocklist lookup) on them
+	var err error
+	if msg.Commit.Record != nil {
+		err = p.limits.checkRecordSize(msg.Commit.Record)
+	} else if msg.Commit.Operation != "delete" {
+		err = invalidRecord(fmt.Errorf("%s operation missing record", msg.Commit.Operation))
+	}
+
+	// Drop everything from blocked accounts before it reaches the index
+	if err == nil {
+		var blocked bool
+		blocked, err = p.queries.IsBlocked(ctx, msg.Commit.Repo)
+		if err == nil && blocked {
+			endSpan(span, nil)
+			BlockedEvents.WithLabelValues(msg.Commit.Collection).Inc()
+			recordEvent(msg.Commit, ResultSkipped, 0)
+			return nil
+		}
+	}
 
 	// Route to appropriate handler based on collection
-	switch msg.Commit.Collection {
-	case "net.openmeet.survey":
-		return p.processSurveyCommit(ctx, msg)
-	case "net.openmeet.survey.response":
-		return p.processResponseCommit(ctx, msg)
-	case "net.openmeet.survey.results":
-		return p.processResultsCommit(ctx, msg)
+	if err == nil {
+		switch msg.Commit.Collection {
+		case "net.openmeet.survey":
+			err = p.processSurveyCommit(ctx, msg)
+		case "net.openmeet.survey.response":
+			err = p.processResponseCommit(ctx, msg)
+		case "net.openmeet.survey.results":
+			err = p.processResultsCommit(ctx, msg)
+		case "net.openmeet.survey.comment":
+			err = p.processCommentCommit(ctx, msg)
+		}
+	}
+
+	// Replays of a record version we already hold are no-ops
+	if errors.Is(err, errAlreadyIndexed) {
+		endSpan(span, nil)
+		recordEvent(msg.Commit, ResultDuplicate, 0)
+		return nil
+	}
+
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		RecordsOverLimit.WithLabelValues(msg.Commit.Collection, limitErr.Limit).Inc()
+	}
+
+	endSpan(span, err)
+	recordEvent(msg.Commit, eventResult(err), time.Since(startTime))
+	return err
+}
+
+// isSupportedCollection reports whether the consumer indexes the collection
+func isSupportedCollection(collection string) bool {
+	switch collection {
+	case "net.openmeet.survey", "net.openmeet.survey.response", "net.openmeet.survey.results", "net.openmeet.survey.comment":
+		return true
 	default:
-		return nil // Skip other collections
+		return false
+	}
+}
+
+// isSupportedOperation reports whether the commit operation is handled
+func isSupportedOperation(operation string) bool {
+	switch operation {
+	case "create", "update", "delete":
+		return true
+	default:
+		return false
 	}
 }
 
@@ -87,7 +187,7 @@ func (p *Processor) processSurveyCommit(ctx context.Context, msg *JetstreamMessa
 // createSurvey indexes a new survey from ATProto
 func (p *Processor) createSurvey(ctx context.Context, commit *JetstreamCommit) error {
 	if commit.Record == nil {
-		return fmt.Errorf("create operation missing record")
+		return invalidRecord(fmt.Errorf("create operation missing record"))
 	}
 
 	// Construct record URI
@@ -96,62 +196,69 @@ func (p *Processor) createSurvey(ctx context.Context, commit *JetstreamCommit) e
 	// Check if survey already exists (we may have created it locally after PDS write)
 	existing, err := p.queries.GetSurveyByURI(ctx, uri)
 	if err == nil && existing != nil {
-		// Already exists, just update the CID (treat as update)
+		// Already exists: a replay at the same CID is a no-op, a new CID an update
 		return p.updateSurvey(ctx, commit)
 	}
 
 	// Parse the survey record
-	def, name, description, err := ParseSurveyRecord(commit.Record)
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	def, name, description, lang, declaredAt, err := ParseSurveyRecordWithLimits(commit.Record, p.limits)
+	endSpan(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse survey record: %w", err)
+		return invalidRecord(fmt.Errorf("failed to parse survey record: %w", err))
+	}
+	startsAt, endsAt, err := parseSchedule(commit.Record)
+	if err != nil {
+		return invalidRecord(fmt.Errorf("invalid survey schedule: %w", err))
 	}
 
 	// Validate the survey definition
 	if err := def.ValidateDefinition(); err != nil {
-		return fmt.Errorf("invalid survey definition: %w", err)
+		return invalidRecord(fmt.Errorf("invalid survey definition: %w", err))
 	}
 
-	// Generate slug from name
-	baseSlug := GenerateSlugFromTitle(name)
-	slug := baseSlug
-
-	// Handle slug collisions by appending -2, -3, etc.
-	suffix := 2
-	for {
-		exists, err := p.queries.SlugExists(ctx, slug)
+	// Create the survey
+	now := time.Now()
+	recordUpdatedAt := resolveEventTime(commit.timeUs, now)
+	survey := &models.Survey{
+		ID:              uuid.New(),
+		URI:             &uri,
+		CID:             &commit.CID,
+		AuthorDID:       &commit.Repo,
+		Title:           name,
+		Description:     &description,
+		Definition:      *def,
+		StartsAt:        startsAt,
+		EndsAt:          endsAt,
+		Lang:            optionalLang(lang),
+		CreatedAt:       resolveCreatedAt(declaredAt, commit.timeUs, now),
+		UpdatedAt:       now,
+		RecordUpdatedAt: &recordUpdatedAt,
+	}
+
+	// Resolve a unique slug and insert. Another writer (the API or a
+	// concurrent event) can claim the slug between the check and the insert,
+	// so retry with a freshly resolved slug if the insert reports it taken.
+	for attempt := 1; ; attempt++ {
+		slug, err := ResolveUniqueSlug(ctx, p.queries, name)
 		if err != nil {
-			return fmt.Errorf("failed to check slug existence: %w", err)
+			return err
 		}
-		if !exists {
+		survey.Slug = slug
+
+		err = traceDB(ctx, "consumer.db_insert", func(ctx context.Context) error {
+			return p.queries.CreateSurvey(ctx, survey)
+		})
+		if err == nil {
 			break
 		}
-		slug = fmt.Sprintf("%s-%d", baseSlug, suffix)
-		suffix++
-
-		// Safety limit to prevent infinite loop
-		if suffix > 100 {
-			return fmt.Errorf("too many slug collisions for %s", baseSlug)
+		if errors.Is(err, db.ErrSurveyExists) {
+			// Indexed since the lookup above (e.g. by the API); apply as an update
+			return p.updateSurvey(ctx, commit)
+		}
+		if !errors.Is(err, db.ErrSlugTaken) || attempt >= maxSlugInsertAttempts {
+			return fmt.Errorf("failed to create survey: %w", err)
 		}
-	}
-
-	// Create the survey
-	survey := &models.Survey{
-		ID:          uuid.New(),
-		URI:         &uri,
-		CID:         &commit.CID,
-		AuthorDID:   &commit.Repo,
-		Slug:        slug,
-		Title:       name,
-		Description: &description,
-		Definition:  *def,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	// TODO: Parse startsAt/endsAt from record if present
-
-	if err := p.queries.CreateSurvey(ctx, survey); err != nil {
-		return fmt.Errorf("failed to create survey: %w", err)
 	}
 
 	// Record business metrics
@@ -167,15 +274,15 @@ func (p *Processor) createSurvey(ctx context.Context, commit *JetstreamCommit) e
 // updateSurvey updates an existing indexed survey
 func (p *Processor) updateSurvey(ctx context.Context, commit *JetstreamCommit) error {
 	if commit.Record == nil {
-		return fmt.Errorf("update operation missing record")
+		return invalidRecord(fmt.Errorf("update operation missing record"))
 	}
 
 	// Construct record URI
-	uri := fmt.Sprintf("at://%s/%s/%s", commit.Repo, commit.Collection, commit.RKey)
+	uri := buildRecordURI(commit)
 
 	// Look up existing survey
 	survey, err := p.queries.GetSurveyByURI(ctx, uri)
-	if err != nil {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return fmt.Errorf("failed to get survey by URI: %w", err)
 	}
 	if survey == nil {
@@ -185,41 +292,94 @@ func (p *Processor) updateSurvey(ctx context.Context, commit *JetstreamCommit) e
 
 	// Authorization check: verify the update comes from the survey author
 	if survey.AuthorDID != nil && *survey.AuthorDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot update survey owned by %s", commit.Repo, *survey.AuthorDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot update survey owned by %s", commit.Repo, *survey.AuthorDID))
+	}
+	// A soft-deleted survey recreated at the same CID still needs restoring
+	if sameCID(survey.CID, commit.CID) && survey.DeletedAt == nil {
+		return errAlreadyIndexed
 	}
 
 	// Parse the updated survey record
-	def, name, description, err := ParseSurveyRecord(commit.Record)
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	def, name, description, lang, _, err := ParseSurveyRecordWithLimits(commit.Record, p.limits)
+	endSpan(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse survey record: %w", err)
+		return invalidRecord(fmt.Errorf("failed to parse survey record: %w", err))
+	}
+	startsAt, endsAt, err := parseSchedule(commit.Record)
+	if err != nil {
+		return invalidRecord(fmt.Errorf("invalid survey schedule: %w", err))
 	}
 
 	// Validate the survey definition
 	if err := def.ValidateDefinition(); err != nil {
-		return fmt.Errorf("invalid survey definition: %w", err)
+		return invalidRecord(fmt.Errorf("invalid survey definition: %w", err))
+	}
+
+	// If the edit drops questions that already have answers, keep the answers
+	// but bump the definition version so results can account for orphans
+	answered, err := p.queries.GetAnsweredQuestionIDs(ctx, survey.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get answered questions: %w", err)
+	}
+	if hasRemovedQuestions(def, answered) {
+		survey.DefinitionVersion++
 	}
 
-	// Update the survey (keep existing slug, ID, etc.)
+	// Update the survey (keep existing slug, ID, created_at, etc.); this also
+	// restores a soft-deleted survey
 	survey.CID = &commit.CID
 	survey.Title = name
 	survey.Description = &description
 	survey.Definition = *def
-
-	if err := p.queries.UpdateSurvey(ctx, survey); err != nil {
+	survey.StartsAt = startsAt
+	survey.EndsAt = endsAt
+	survey.Lang = optionalLang(lang)
+	recordUpdatedAt := resolveEventTime(commit.timeUs, time.Now())
+	survey.RecordUpdatedAt = &recordUpdatedAt
+
+	if err := traceDB(ctx, "consumer.db_update", func(ctx context.Context) error {
+		return p.queries.UpdateSurvey(ctx, survey)
+	}); err != nil {
 		return fmt.Errorf("failed to update survey: %w", err)
 	}
 
-	return nil
+	return p.invalidateSurvey(ctx, survey.URI)
+}
+
+// sameCID reports whether an indexed record is already at the event's CID
+func sameCID(indexed *string, cid string) bool {
+	return indexed != nil && cid != "" && *indexed == cid
 }
 
-// deleteSurvey removes a survey from the index
+// hasRemovedQuestions reports whether any of the answered question IDs are
+// missing from the definition
+func hasRemovedQuestions(def *models.SurveyDefinition, answeredIDs []string) bool {
+	current := make(map[string]bool, len(def.Questions))
+	for _, q := range def.Questions {
+		current[q.ID] = true
+	}
+	for _, id := range answeredIDs {
+		if !current[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSurvey soft-deletes a survey from the index; it stays restorable
+// until the purge worker removes it
 func (p *Processor) deleteSurvey(ctx context.Context, commit *JetstreamCommit) error {
 	// Construct record URI
-	uri := fmt.Sprintf("at://%s/%s/%s", commit.Repo, commit.Collection, commit.RKey)
+	uri := buildRecordURI(commit)
 
 	// Look up existing survey for authorization check
 	survey, err := p.queries.GetSurveyByURI(ctx, uri)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Survey was never indexed - nothing to delete (idempotent)
+			return nil
+		}
 		return fmt.Errorf("failed to get survey by URI: %w", err)
 	}
 	if survey == nil {
@@ -229,17 +389,37 @@ func (p *Processor) deleteSurvey(ctx context.Context, commit *JetstreamCommit) e
 
 	// Authorization check: verify the delete comes from the survey author
 	if survey.AuthorDID != nil && *survey.AuthorDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot delete survey owned by %s", commit.Repo, *survey.AuthorDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot delete survey owned by %s", commit.Repo, *survey.AuthorDID))
 	}
 
-	// Delete the survey (cascades to responses due to ON DELETE CASCADE)
-	if err := p.queries.DeleteSurveyByURI(ctx, uri); err != nil {
+	// Soft-delete the survey; responses are kept until it is purged
+	if err := traceDB(ctx, "consumer.db_delete", func(ctx context.Context) error {
+		return p.queries.DeleteSurveyByURI(ctx, uri)
+	}); err != nil {
 		return fmt.Errorf("failed to delete survey: %w", err)
 	}
 
+	return p.invalidateSurvey(ctx, &uri)
+}
+
+// invalidateSurvey tells API servers to drop their cached copy of the survey
+// at uri. Within the event's transaction the notification is only delivered
+// if it commits.
+func (p *Processor) invalidateSurvey(ctx context.Context, uri *string) error {
+	if uri == nil {
+		return nil
+	}
+	if err := p.queries.InvalidateSurvey(ctx, *uri); err != nil {
+		return fmt.Errorf("failed to invalidate cached survey: %w", err)
+	}
 	return nil
 }
 
+// buildRecordURI constructs the AT-URI for a commit: at://{did}/{collection}/{rkey}
+func buildRecordURI(commit *JetstreamCommit) string {
+	return fmt.Sprintf("at://%s/%s/%s", commit.Repo, commit.Collection, commit.RKey)
+}
+
 // processResponseCommit handles create/update/delete operations for survey responses
 func (p *Processor) processResponseCommit(ctx context.Context, msg *JetstreamMessage) error {
 	commit := msg.Commit
@@ -256,10 +436,26 @@ func (p *Processor) processResponseCommit(ctx context.Context, msg *JetstreamMes
 	}
 }
 
+// getSubjectSurvey looks up the survey a response or comment record refers
+// to. Records for surveys we don't index, or that were deleted, are invalid.
+func (p *Processor) getSubjectSurvey(ctx context.Context, surveyURI string) (*models.Survey, error) {
+	survey, err := p.queries.GetSurveyByURI(ctx, surveyURI)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, invalidRecord(fmt.Errorf("survey not found: %s", surveyURI))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get survey by URI %s: %w", surveyURI, err)
+	}
+	if survey.DeletedAt != nil {
+		return nil, invalidRecord(fmt.Errorf("survey deleted: %s", surveyURI))
+	}
+	return survey, nil
+}
+
 // createResponse indexes a new survey response from ATProto
 func (p *Processor) createResponse(ctx context.Context, commit *JetstreamCommit) error {
 	if commit.Record == nil {
-		return fmt.Errorf("create operation missing record")
+		return invalidRecord(fmt.Errorf("create operation missing record"))
 	}
 
 	// Construct record URI
@@ -268,44 +464,36 @@ func (p *Processor) createResponse(ctx context.Context, commit *JetstreamCommit)
 	// Check if response already exists (we may have created it locally after PDS write)
 	existing, err := p.queries.GetResponseByRecordURI(ctx, recordURI)
 	if err == nil && existing != nil {
-		// Already exists, just update the CID (treat as update)
+		// Already exists: a replay at the same CID is a no-op, a new CID an update
 		return p.updateResponse(ctx, commit)
 	}
 
 	// Parse the response record
-	surveyURI, answers, err := ParseResponseRecord(commit.Record)
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	surveyURI, answers, declaredAt, err := ParseResponseRecord(commit.Record)
+	endSpan(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse response record: %w", err)
+		return invalidRecord(fmt.Errorf("failed to parse response record: %w", err))
 	}
 
 	// Look up the survey by URI
-	survey, err := p.queries.GetSurveyByURI(ctx, surveyURI)
+	survey, err := p.getSubjectSurvey(ctx, surveyURI)
 	if err != nil {
-		return fmt.Errorf("failed to get survey by URI %s: %w", surveyURI, err)
-	}
-	if survey == nil {
-		return fmt.Errorf("survey not found: %s", surveyURI)
+		return err
 	}
 
 	// Validate answers against survey definition
-	if err := models.ValidateAnswers(&survey.Definition, answers); err != nil {
-		return fmt.Errorf("answer validation failed: %w", err)
+	if err := validateResponseAnswers(&survey.Definition, answers); err != nil {
+		return err
 	}
 
 	// Extract voter DID from commit.repo
 	voterDID := commit.Repo
 
-	// Check for duplicate response (user already voted on this survey)
-	existingVote, err := p.queries.GetResponseBySurveyAndVoter(ctx, survey.ID, voterDID, "")
-	if err != nil {
-		return fmt.Errorf("failed to check for existing response: %w", err)
-	}
-	if existingVote != nil {
-		// User already voted - this is a duplicate, skip it
-		return nil
-	}
+	// A second record from the same voter replaces their earlier answers
+	// rather than counting twice (see models.ResponseDedupKey)
+	dedupKey := models.ResponseDedupKey(&survey.Definition, survey.ID, voterDID, recordURI)
 
-	// Create the response
 	response := &models.Response{
 		ID:        uuid.New(),
 		SurveyID:  survey.ID,
@@ -313,12 +501,26 @@ func (p *Processor) createResponse(ctx context.Context, commit *JetstreamCommit)
 		RecordURI: &recordURI,
 		RecordCID: &commit.CID,
 		Answers:   answers,
-		CreatedAt: time.Now(),
+		CreatedAt: resolveCreatedAt(declaredAt, commit.timeUs, time.Now()),
+		DedupKey:  &dedupKey,
 	}
 
-	if err := p.queries.CreateResponse(ctx, response); err != nil {
+	var inserted bool
+	if err := traceDB(ctx, "consumer.db_insert", func(ctx context.Context) error {
+		var err error
+		inserted, err = p.upsertResponse(ctx, response)
+		return err
+	}); err != nil {
 		return fmt.Errorf("failed to create response: %w", err)
 	}
+	// Listeners hear about new and replaced responses alike, once the event commits
+	if err := p.queries.NotifySurveyResponses(ctx, survey.ID); err != nil {
+		return err
+	}
+	if !inserted {
+		// Replaced an earlier response; the vote count is unchanged
+		return nil
+	}
 
 	// Record business metrics
 	telemetry.VotesIndexed.Inc()
@@ -332,10 +534,41 @@ func (p *Processor) createResponse(ctx context.Context, commit *JetstreamCommit)
 	return nil
 }
 
+// upsertResponse writes a new response through the batcher when batching is
+// enabled. A batched row commits with its batch rather than with the event's
+// transaction, before add returns, so the NotifySurveyResponses that follows
+// never announces a row that isn't there. If the event's transaction then
+// rolls back, only that notification is lost; listeners catch up at their
+// fallback tick.
+func (p *Processor) upsertResponse(ctx context.Context, response *models.Response) (bool, error) {
+	if p.batcher != nil {
+		return p.batcher.add(ctx, response)
+	}
+	return p.queries.UpsertResponse(ctx, response)
+}
+
+// validateResponseAnswers checks a response's answers against the survey
+// definition. Rejections are invalid records and are counted by reason.
+func validateResponseAnswers(def *models.SurveyDefinition, answers map[string]models.Answer) error {
+	err := models.ValidateIngestedAnswers(def, answers)
+	if err == nil {
+		return nil
+	}
+
+	reason := "invalid"
+	var answerErr *models.AnswerError
+	if errors.As(err, &answerErr) {
+		reason = answerErr.Reason
+	}
+	ResponsesRejected.WithLabelValues(reason).Inc()
+
+	return invalidRecord(fmt.Errorf("answer validation failed: %w", err))
+}
+
 // updateResponse updates an existing indexed response
 func (p *Processor) updateResponse(ctx context.Context, commit *JetstreamCommit) error {
 	if commit.Record == nil {
-		return fmt.Errorf("update operation missing record")
+		return invalidRecord(fmt.Errorf("update operation missing record"))
 	}
 
 	// Construct record URI
@@ -353,41 +586,45 @@ func (p *Processor) updateResponse(ctx context.Context, commit *JetstreamCommit)
 
 	// Authorization check: verify the update comes from the original voter
 	if response.VoterDID != nil && *response.VoterDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot update response owned by %s", commit.Repo, *response.VoterDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot update response owned by %s", commit.Repo, *response.VoterDID))
+	}
+	if sameCID(response.RecordCID, commit.CID) {
+		return errAlreadyIndexed
 	}
 
 	// Parse the updated response record
-	surveyURI, answers, err := ParseResponseRecord(commit.Record)
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	surveyURI, answers, _, err := ParseResponseRecord(commit.Record)
+	endSpan(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse response record: %w", err)
+		return invalidRecord(fmt.Errorf("failed to parse response record: %w", err))
 	}
 
 	// Get the survey to validate answers
-	survey, err := p.queries.GetSurveyByURI(ctx, surveyURI)
+	survey, err := p.getSubjectSurvey(ctx, surveyURI)
 	if err != nil {
-		return fmt.Errorf("failed to get survey by URI %s: %w", surveyURI, err)
-	}
-	if survey == nil {
-		return fmt.Errorf("survey not found: %s", surveyURI)
+		return err
 	}
 
 	// Validate answers
-	if err := models.ValidateAnswers(&survey.Definition, answers); err != nil {
-		return fmt.Errorf("answer validation failed: %w", err)
+	if err := validateResponseAnswers(&survey.Definition, answers); err != nil {
+		return err
 	}
 
 	// Update the response
-	if err := p.queries.UpdateResponseAnswers(ctx, response.ID, answers, commit.CID); err != nil {
+	if err := traceDB(ctx, "consumer.db_update", func(ctx context.Context) error {
+		return p.queries.UpdateResponseAnswers(ctx, response.ID, answers, commit.CID)
+	}); err != nil {
 		return fmt.Errorf("failed to update response: %w", err)
 	}
 
-	return nil
+	return p.queries.NotifySurveyResponses(ctx, response.SurveyID)
 }
 
 // deleteResponse removes a response from the index
 func (p *Processor) deleteResponse(ctx context.Context, commit *JetstreamCommit) error {
 	// Construct record URI
-	recordURI := fmt.Sprintf("at://%s/%s/%s", commit.Repo, commit.Collection, commit.RKey)
+	recordURI := buildRecordURI(commit)
 
 	// Look up existing response for authorization check
 	response, err := p.queries.GetResponseByRecordURI(ctx, recordURI)
@@ -401,15 +638,17 @@ func (p *Processor) deleteResponse(ctx context.Context, commit *JetstreamCommit)
 
 	// Authorization check: verify the delete comes from the original voter
 	if response.VoterDID != nil && *response.VoterDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot delete response owned by %s", commit.Repo, *response.VoterDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot delete response owned by %s", commit.Repo, *response.VoterDID))
 	}
 
 	// Delete the response
-	if err := p.queries.DeleteResponseByRecordURI(ctx, recordURI); err != nil {
+	if err := traceDB(ctx, "consumer.db_delete", func(ctx context.Context) error {
+		return p.queries.DeleteResponseByURI(ctx, recordURI)
+	}); err != nil {
 		return fmt.Errorf("failed to delete response: %w", err)
 	}
 
-	return nil
+	return p.queries.NotifySurveyResponses(ctx, response.SurveyID)
 }
 
 // processResultsCommit handles create/update/delete operations for survey results
@@ -431,7 +670,7 @@ func (p *Processor) processResultsCommit(ctx context.Context, msg *JetstreamMess
 // createResults indexes a new results record from ATProto
 func (p *Processor) createResults(ctx context.Context, commit *JetstreamCommit) error {
 	if commit.Record == nil {
-		return fmt.Errorf("create operation missing record")
+		return invalidRecord(fmt.Errorf("create operation missing record"))
 	}
 
 	// Construct results record URI
@@ -445,29 +684,40 @@ func (p *Processor) createResults(ctx context.Context, commit *JetstreamCommit)
 	}
 
 	// Parse the results record to get the survey URI
-	surveyURI, err := ParseResultsRecord(commit.Record)
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	surveyURI, tallies, finalizedAt, err := ParseResultsRecord(commit.Record)
+	endSpan(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse results record: %w", err)
+		return invalidRecord(fmt.Errorf("failed to parse results record: %w", err))
 	}
 
 	// Look up the survey
 	survey, err := p.queries.GetSurveyByURI(ctx, surveyURI)
+	if errors.Is(err, sql.ErrNoRows) {
+		return invalidRecord(fmt.Errorf("survey not found: %s", surveyURI))
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get survey by URI %s: %w", surveyURI, err)
 	}
-	if survey == nil {
-		return fmt.Errorf("survey not found: %s", surveyURI)
-	}
 
 	// Authorization check: verify the results publish comes from the survey author
 	if survey.AuthorDID != nil && *survey.AuthorDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot publish results for survey owned by %s", commit.Repo, *survey.AuthorDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot publish results for survey owned by %s", commit.Repo, *survey.AuthorDID))
 	}
 
 	// Update the survey with results URI/CID
-	if err := p.queries.UpdateSurveyResults(ctx, survey.ID, resultsURI, commit.CID); err != nil {
+	if err := traceDB(ctx, "consumer.db_update", func(ctx context.Context) error {
+		return p.queries.UpdateSurveyResults(ctx, survey.ID, resultsURI, commit.CID)
+	}); err != nil {
 		return fmt.Errorf("failed to update survey results: %w", err)
 	}
+	if err := p.invalidateSurvey(ctx, survey.URI); err != nil {
+		return err
+	}
+
+	if err := p.storePublishedResults(ctx, commit, surveyURI, resultsURI, tallies, finalizedAt); err != nil {
+		return err
+	}
 
 	// Record business metric
 	telemetry.ResultsPublished.Inc()
@@ -478,7 +728,7 @@ func (p *Processor) createResults(ctx context.Context, commit *JetstreamCommit)
 // updateResults updates an existing results record
 func (p *Processor) updateResults(ctx context.Context, commit *JetstreamCommit) error {
 	if commit.Record == nil {
-		return fmt.Errorf("update operation missing record")
+		return invalidRecord(fmt.Errorf("update operation missing record"))
 	}
 
 	// Construct results record URI
@@ -496,24 +746,56 @@ func (p *Processor) updateResults(ctx context.Context, commit *JetstreamCommit)
 
 	// Authorization check: verify the update comes from the survey author
 	if survey.AuthorDID != nil && *survey.AuthorDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot update results for survey owned by %s", commit.Repo, *survey.AuthorDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot update results for survey owned by %s", commit.Repo, *survey.AuthorDID))
 	}
 
 	// Parse the results record to verify it still references the same survey
-	surveyURI, err := ParseResultsRecord(commit.Record)
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	surveyURI, tallies, finalizedAt, err := ParseResultsRecord(commit.Record)
+	endSpan(parseSpan, err)
 	if err != nil {
-		return fmt.Errorf("failed to parse results record: %w", err)
+		return invalidRecord(fmt.Errorf("failed to parse results record: %w", err))
 	}
 
 	if survey.URI != nil && *survey.URI != surveyURI {
-		return fmt.Errorf("results record cannot change survey reference")
+		return invalidRecord(fmt.Errorf("results record cannot change survey reference"))
 	}
 
 	// Update the CID
-	if err := p.queries.UpdateSurveyResults(ctx, survey.ID, resultsURI, commit.CID); err != nil {
+	if err := traceDB(ctx, "consumer.db_update", func(ctx context.Context) error {
+		return p.queries.UpdateSurveyResults(ctx, survey.ID, resultsURI, commit.CID)
+	}); err != nil {
 		return fmt.Errorf("failed to update survey results: %w", err)
 	}
+	if err := p.invalidateSurvey(ctx, survey.URI); err != nil {
+		return err
+	}
+
+	return p.storePublishedResults(ctx, commit, surveyURI, resultsURI, tallies, finalizedAt)
+}
+
+// storePublishedResults saves the tallies from a results record as the
+// survey's published snapshot. If the tallies were malformed, any snapshot
+// from an earlier version of the same record is dropped rather than left stale.
+func (p *Processor) storePublishedResults(ctx context.Context, commit *JetstreamCommit, surveyURI, resultsURI string, tallies *models.PublishedResults, finalizedAt *time.Time) error {
+	if tallies == nil {
+		if err := traceDB(ctx, "consumer.db_delete", func(ctx context.Context) error {
+			return p.queries.DeletePublishedResults(ctx, surveyURI, resultsURI)
+		}); err != nil {
+			return fmt.Errorf("failed to clear published results: %w", err)
+		}
+		return nil
+	}
 
+	tallies.ResultsURI = resultsURI
+	tallies.PublisherDID = commit.Repo
+	tallies.PublishedAt = resolveCreatedAt(finalizedAt, commit.timeUs, time.Now())
+
+	if err := traceDB(ctx, "consumer.db_update", func(ctx context.Context) error {
+		return p.queries.UpsertPublishedResults(ctx, tallies)
+	}); err != nil {
+		return fmt.Errorf("failed to store published results: %w", err)
+	}
 	return nil
 }
 
@@ -534,55 +816,175 @@ func (p *Processor) deleteResults(ctx context.Context, commit *JetstreamCommit)
 
 	// Authorization check: verify the delete comes from the survey author
 	if survey.AuthorDID != nil && *survey.AuthorDID != commit.Repo {
-		return fmt.Errorf("unauthorized: DID %s cannot delete results for survey owned by %s", commit.Repo, *survey.AuthorDID)
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot delete results for survey owned by %s", commit.Repo, *survey.AuthorDID))
 	}
 
 	// Clear the results URI/CID from the survey (set to NULL)
 	query := `UPDATE surveys SET results_uri = NULL, results_cid = NULL, updated_at = NOW() WHERE id = $1`
-	_, err = p.queries.GetDB().ExecContext(ctx, query, survey.ID)
+	err = traceDB(ctx, "consumer.db_delete", func(ctx context.Context) error {
+		_, err := p.queries.GetDB().ExecContext(ctx, query, survey.ID)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to clear survey results: %w", err)
 	}
 
+	if survey.URI != nil {
+		if err := traceDB(ctx, "consumer.db_delete", func(ctx context.Context) error {
+			return p.queries.DeletePublishedResults(ctx, *survey.URI, resultsURI)
+		}); err != nil {
+			return fmt.Errorf("failed to clear published results: %w", err)
+		}
+	}
+
 	return nil
 }
 
-// ProcessMessageWithCursor processes a message and updates the cursor atomically
-func (p *Processor) ProcessMessageWithCursor(ctx context.Context, msg *JetstreamMessage, getDB func() db.Querier) error {
-	// Start a transaction
-	dbConn, ok := p.queries.GetDB().(*sql.DB)
-	if !ok {
-		// If we're already in a transaction, just process the message
-		if err := p.ProcessMessage(ctx, msg); err != nil {
-			return fmt.Errorf("failed to process message: %w", err)
+// processCommentCommit handles create/update/delete operations for survey comments
+func (p *Processor) processCommentCommit(ctx context.Context, msg *JetstreamMessage) error {
+	commit := msg.Commit
+
+	switch commit.Operation {
+	case "create", "update":
+		return p.indexComment(ctx, commit)
+	case "delete":
+		return p.deleteComment(ctx, commit)
+	default:
+		return nil // Skip unknown operations
+	}
+}
+
+// indexComment stores a comment from ATProto. Creates and updates are handled
+// alike: the row is keyed by record URI, so an edit replaces the text.
+// Comments are indexed for every survey; the survey page only shows them when
+// the author has enabled allowComments.
+func (p *Processor) indexComment(ctx context.Context, commit *JetstreamCommit) error {
+	if commit.Record == nil {
+		return invalidRecord(fmt.Errorf("%s operation missing record", commit.Operation))
+	}
+
+	// Construct record URI
+	recordURI := buildRecordURI(commit)
+
+	existing, err := p.queries.GetCommentByRecordURI(ctx, recordURI)
+	if err != nil {
+		return fmt.Errorf("failed to get comment by URI: %w", err)
+	}
+	if existing != nil {
+		// Authorization check: verify the update comes from the comment author
+		if existing.AuthorDID != commit.Repo {
+			return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot update comment owned by %s", commit.Repo, existing.AuthorDID))
 		}
-		return UpdateCursor(ctx, p.queries, msg.TimeUs)
+		if sameCID(&existing.RecordCID, commit.CID) {
+			return errAlreadyIndexed
+		}
+	}
+
+	// Parse the comment record
+	_, parseSpan := startSpan(ctx, "consumer.parse")
+	surveyURI, text, declaredAt, err := ParseCommentRecord(commit.Record)
+	endSpan(parseSpan, err)
+	if err != nil {
+		return invalidRecord(fmt.Errorf("failed to parse comment record: %w", err))
 	}
 
-	tx, err := dbConn.BeginTx(ctx, nil)
+	// Look up the survey by URI
+	survey, err := p.getSubjectSurvey(ctx, surveyURI)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
+	}
+	if existing != nil && existing.SurveyID != survey.ID {
+		return invalidRecord(fmt.Errorf("comment record cannot change survey reference"))
+	}
+
+	comment := &models.Comment{
+		ID:        uuid.New(),
+		SurveyID:  survey.ID,
+		AuthorDID: commit.Repo,
+		RecordURI: recordURI,
+		RecordCID: commit.CID,
+		Text:      text,
+		CreatedAt: resolveCreatedAt(declaredAt, commit.timeUs, time.Now()),
+	}
+
+	if err := traceDB(ctx, "consumer.db_insert", func(ctx context.Context) error {
+		_, err := p.queries.UpsertComment(ctx, comment)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to store comment: %w", err)
 	}
-	defer tx.Rollback()
 
-	// Create transaction-scoped processor
-	txQueries := db.NewQueries(tx)
-	txProcessor := NewProcessor(txQueries)
+	return nil
+}
+
+// deleteComment removes a comment from the index
+func (p *Processor) deleteComment(ctx context.Context, commit *JetstreamCommit) error {
+	// Construct record URI
+	recordURI := buildRecordURI(commit)
 
-	// Process the message
-	if err := txProcessor.ProcessMessage(ctx, msg); err != nil {
-		return fmt.Errorf("failed to process message: %w", err)
+	// Look up existing comment for authorization check
+	comment, err := p.queries.GetCommentByRecordURI(ctx, recordURI)
+	if err != nil {
+		return fmt.Errorf("failed to get comment by URI: %w", err)
+	}
+	if comment == nil {
+		// Comment doesn't exist - nothing to delete (idempotent)
+		return nil
 	}
 
-	// Update cursor
-	if err := UpdateCursor(ctx, txQueries, msg.TimeUs); err != nil {
-		return fmt.Errorf("failed to update cursor: %w", err)
+	// Authorization check: verify the delete comes from the comment author
+	if comment.AuthorDID != commit.Repo {
+		return invalidRecord(fmt.Errorf("unauthorized: DID %s cannot delete comment owned by %s", commit.Repo, comment.AuthorDID))
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// Delete the comment
+	if err := traceDB(ctx, "consumer.db_delete", func(ctx context.Context) error {
+		return p.queries.DeleteCommentByURI(ctx, recordURI)
+	}); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
 	}
 
 	return nil
 }
+
+// ProcessMessageWithCursor processes a message and updates the cursor atomically
+func (p *Processor) ProcessMessageWithCursor(ctx context.Context, msg *JetstreamMessage) error {
+	return p.processInTx(ctx, msg, true)
+}
+
+// ProcessMessageInTx processes a message in its own transaction without
+// touching the cursor. Used by the WorkerPool, which advances the cursor
+// separately once all earlier events have completed.
+func (p *Processor) ProcessMessageInTx(ctx context.Context, msg *JetstreamMessage) error {
+	return p.processInTx(ctx, msg, false)
+}
+
+// processInTx runs ProcessMessage in a transaction, optionally updating the
+// cursor in the same transaction. A failure anywhere rolls back every row the
+// event wrote, so a replay starts from a clean slate, and a transient
+// database error (a failover, a deadlock) reruns the whole transaction.
+func (p *Processor) processInTx(ctx context.Context, msg *JetstreamMessage, updateCursor bool) error {
+	return db.ExecWithRetry(ctx, db.OpIngest, func() error {
+		return p.processOnce(ctx, msg, updateCursor)
+	})
+}
+
+// processOnce is a single attempt of processInTx
+func (p *Processor) processOnce(ctx context.Context, msg *JetstreamMessage, updateCursor bool) error {
+	return p.queries.WithTx(ctx, func(txQueries *db.Queries) error {
+		// Create transaction-scoped processor
+		txProcessor := NewProcessor(txQueries).WithLimits(p.limits)
+		txProcessor.batcher = p.batcher
+
+		if err := txProcessor.ProcessMessage(ctx, msg); err != nil {
+			return fmt.Errorf("failed to process message: %w", err)
+		}
+
+		if updateCursor {
+			if err := UpdateCursor(ctx, txQueries, msg.TimeUs); err != nil {
+				return fmt.Errorf("failed to update cursor: %w", err)
+			}
+		}
+		return nil
+	})
+}
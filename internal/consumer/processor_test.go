@@ -2,11 +2,16 @@ package consumer
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
 	"github.com/openmeet-team/survey/internal/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestProcessSurveyResponse(t *testing.T) {
@@ -66,7 +71,7 @@ func TestProcessSurveyResponse(t *testing.T) {
 					"answers": []interface{}{
 						map[string]interface{}{
 							"questionId": "q1",
-							"selected":   []interface{}{"mon"},
+							"selectedOptions": []interface{}{"mon"},
 						},
 					},
 					"createdAt": time.Now().Format(time.RFC3339),
@@ -130,7 +135,7 @@ func TestProcessSurveyResponse(t *testing.T) {
 					"answers": []interface{}{
 						map[string]interface{}{
 							"questionId": "q1",
-							"selected":   []interface{}{"mon"},
+							"selectedOptions": []interface{}{"mon"},
 						},
 					},
 					"createdAt": time.Now().Format(time.RFC3339),
@@ -166,7 +171,7 @@ func TestProcessSurveyResponse(t *testing.T) {
 					"answers": []interface{}{
 						map[string]interface{}{
 							"questionId": "q1",
-							"selected":   []interface{}{"invalid_option"}, // Invalid option
+							"selectedOptions": []interface{}{"invalid_option"}, // Invalid option
 						},
 					},
 					"createdAt": time.Now().Format(time.RFC3339),
@@ -496,7 +501,7 @@ func TestAuthorizationChecks(t *testing.T) {
 					"answers": []interface{}{
 						map[string]interface{}{
 							"questionId": "q1",
-							"selected":   []interface{}{"b"},
+							"selectedOptions": []interface{}{"b"},
 						},
 					},
 				},
@@ -582,7 +587,7 @@ func TestAuthorizationChecks(t *testing.T) {
 					"answers": []interface{}{
 						map[string]interface{}{
 							"questionId": "q1",
-							"selected":   []interface{}{"b"},
+							"selectedOptions": []interface{}{"b"},
 						},
 					},
 				},
@@ -784,7 +789,7 @@ func TestDeduplication(t *testing.T) {
 		}
 
 		// Verify only one survey exists with that URI
-		surveys, err := queries.ListSurveys(ctx, 100, 0)
+		surveys, _, err := queries.ListSurveys(ctx, db.ListSurveysParams{Limit: 100})
 		if err != nil {
 			t.Fatalf("Failed to list surveys: %v", err)
 		}
@@ -876,7 +881,7 @@ func TestDeduplication(t *testing.T) {
 					"answers": []interface{}{
 						map[string]interface{}{
 							"questionId": "q1",
-							"selected":   []interface{}{"a"},
+							"selectedOptions": []interface{}{"a"},
 						},
 					},
 					"createdAt": time.Now().Format(time.RFC3339),
@@ -999,3 +1004,1543 @@ func TestDeduplication(t *testing.T) {
 		}
 	})
 }
+
+// TestDeleteOperations tests that Jetstream delete commits remove indexed records
+func TestDeleteOperations(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	t.Run("deleteSurvey is a no-op for unknown survey", func(t *testing.T) {
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Repo:       "did:plc:author1",
+				Collection: "net.openmeet.survey",
+				RKey:       "never-indexed",
+			},
+			TimeUs: 1234567900,
+		}
+
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Expected no error deleting unknown survey, got: %v", err)
+		}
+	})
+
+	t.Run("deleteResponse is a no-op for unknown response", func(t *testing.T) {
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Repo:       "did:plc:voter1",
+				Collection: "net.openmeet.survey.response",
+				RKey:       "never-indexed",
+			},
+			TimeUs: 1234567901,
+		}
+
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Expected no error deleting unknown response, got: %v", err)
+		}
+	})
+
+	t.Run("deleteSurvey soft-deletes survey and keeps its responses", func(t *testing.T) {
+		survey := &models.Survey{
+			ID:        uuid.New(),
+			URI:       stringPtr("at://did:plc:delauthor/net.openmeet.survey/del1"),
+			CID:       stringPtr("bafydel1"),
+			AuthorDID: stringPtr("did:plc:delauthor"),
+			Slug:      "test-survey-delete-1",
+			Title:     "Delete Me",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{
+						ID:       "q1",
+						Text:     "Question 1?",
+						Type:     models.QuestionTypeSingle,
+						Required: true,
+						Options: []models.Option{
+							{ID: "a", Text: "Option A"},
+						},
+					},
+				},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create test survey: %v", err)
+		}
+
+		response := &models.Response{
+			ID:        uuid.New(),
+			SurveyID:  survey.ID,
+			VoterDID:  stringPtr("did:plc:delvoter"),
+			RecordURI: stringPtr("at://did:plc:delvoter/net.openmeet.survey.response/delresp1"),
+			RecordCID: stringPtr("bafydel2"),
+			Answers: map[string]models.Answer{
+				"q1": {SelectedOptions: []string{"a"}},
+			},
+			CreatedAt: time.Now(),
+		}
+		if err := queries.CreateResponse(ctx, response); err != nil {
+			t.Fatalf("Failed to create test response: %v", err)
+		}
+
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Repo:       "did:plc:delauthor",
+				Collection: "net.openmeet.survey",
+				RKey:       "del1",
+			},
+			TimeUs: 1234567902,
+		}
+
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		deleted, err := queries.GetSurveyByURI(ctx, *survey.URI)
+		if err != nil {
+			t.Fatalf("Soft-deleted survey should still be indexed: %v", err)
+		}
+		if deleted.DeletedAt == nil {
+			t.Error("Survey should be marked deleted")
+		}
+		if _, err := queries.GetSurveyBySlug(ctx, survey.Slug); err == nil {
+			t.Error("Deleted survey should not be found by slug")
+		}
+
+		kept, err := queries.GetResponseByRecordURI(ctx, *response.RecordURI)
+		if err != nil {
+			t.Fatalf("Failed to check response existence: %v", err)
+		}
+		if kept == nil {
+			t.Error("Response should be kept until the survey is purged")
+		}
+
+		// Recreating the record at the same CID restores the survey
+		msg.Commit.Operation = "create"
+		msg.Commit.CID = *survey.CID
+		msg.Commit.Record = testSurveyRecord("Delete Me", "q1")
+		msg.TimeUs++
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		restored, err := queries.GetSurveyByURI(ctx, *survey.URI)
+		if err != nil {
+			t.Fatalf("Failed to get restored survey: %v", err)
+		}
+		if restored.DeletedAt != nil {
+			t.Error("Recreated survey should be restored")
+		}
+	})
+
+	t.Run("deleteResponse removes response", func(t *testing.T) {
+		survey := &models.Survey{
+			ID:        uuid.New(),
+			URI:       stringPtr("at://did:plc:delauthor/net.openmeet.survey/del2"),
+			CID:       stringPtr("bafydel3"),
+			AuthorDID: stringPtr("did:plc:delauthor"),
+			Slug:      "test-survey-delete-2",
+			Title:     "Keep Me",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{
+						ID:       "q1",
+						Text:     "Question 1?",
+						Type:     models.QuestionTypeSingle,
+						Required: true,
+						Options: []models.Option{
+							{ID: "a", Text: "Option A"},
+						},
+					},
+				},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create test survey: %v", err)
+		}
+
+		response := &models.Response{
+			ID:        uuid.New(),
+			SurveyID:  survey.ID,
+			VoterDID:  stringPtr("did:plc:delvoter"),
+			RecordURI: stringPtr("at://did:plc:delvoter/net.openmeet.survey.response/delresp2"),
+			RecordCID: stringPtr("bafydel4"),
+			Answers: map[string]models.Answer{
+				"q1": {SelectedOptions: []string{"a"}},
+			},
+			CreatedAt: time.Now(),
+		}
+		if err := queries.CreateResponse(ctx, response); err != nil {
+			t.Fatalf("Failed to create test response: %v", err)
+		}
+
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Repo:       "did:plc:delvoter",
+				Collection: "net.openmeet.survey.response",
+				RKey:       "delresp2",
+			},
+			TimeUs: 1234567903,
+		}
+
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		gone, err := queries.GetResponseByRecordURI(ctx, *response.RecordURI)
+		if err != nil {
+			t.Fatalf("Failed to check response existence: %v", err)
+		}
+		if gone != nil {
+			t.Error("Response should be deleted")
+		}
+
+		count, err := queries.CountResponsesBySurvey(ctx, survey.ID)
+		if err != nil {
+			t.Fatalf("Failed to count responses: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("Expected 0 responses after delete, got %d", count)
+		}
+	})
+}
+
+// testSurveyRecord builds a minimal ATProto survey record with one
+// single-choice question per ID
+func testSurveyRecord(name string, questionIDs ...string) map[string]interface{} {
+	questions := make([]interface{}, 0, len(questionIDs))
+	for _, id := range questionIDs {
+		questions = append(questions, map[string]interface{}{
+			"id":       id,
+			"text":     "Question " + id + "?",
+			"type":     "net.openmeet.survey#single",
+			"required": true,
+			"options": []interface{}{
+				map[string]interface{}{"id": "a", "text": "Option A"},
+				map[string]interface{}{"id": "b", "text": "Option B"},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"$type":       "net.openmeet.survey",
+		"name":        name,
+		"description": name + " description",
+		"questions":   questions,
+		"createdAt":   time.Now().Format(time.RFC3339),
+	}
+}
+
+// TestUpdateOperations tests that Jetstream update commits re-parse and replace survey definitions
+func TestUpdateOperations(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	t.Run("create then update replaces definition but keeps slug and created_at", func(t *testing.T) {
+		create := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Repo:       "did:plc:updauthor",
+				Collection: "net.openmeet.survey",
+				RKey:       "upd1",
+				CID:        "bafyupd1",
+				Record:     testSurveyRecord("Original Title", "q1"),
+			},
+			TimeUs: 1234567910,
+		}
+		if err := processor.ProcessMessage(ctx, create); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+
+		original, err := queries.GetSurveyByURI(ctx, "at://did:plc:updauthor/net.openmeet.survey/upd1")
+		if err != nil {
+			t.Fatalf("Failed to get created survey: %v", err)
+		}
+
+		update := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "update",
+				Repo:       "did:plc:updauthor",
+				Collection: "net.openmeet.survey",
+				RKey:       "upd1",
+				CID:        "bafyupd2",
+				Record:     testSurveyRecord("Edited Title", "q1", "q2"),
+			},
+			TimeUs: 1234567911,
+		}
+		if err := processor.ProcessMessage(ctx, update); err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+
+		updated, err := queries.GetSurveyByURI(ctx, "at://did:plc:updauthor/net.openmeet.survey/upd1")
+		if err != nil {
+			t.Fatalf("Failed to get updated survey: %v", err)
+		}
+		if updated.Title != "Edited Title" {
+			t.Errorf("Expected title 'Edited Title', got: %s", updated.Title)
+		}
+		if updated.Description == nil || *updated.Description != "Edited Title description" {
+			t.Errorf("Expected description to be replaced, got: %v", updated.Description)
+		}
+		if len(updated.Definition.Questions) != 2 {
+			t.Errorf("Expected 2 questions, got %d", len(updated.Definition.Questions))
+		}
+		if updated.CID == nil || *updated.CID != "bafyupd2" {
+			t.Errorf("Expected CID 'bafyupd2', got: %v", updated.CID)
+		}
+		if updated.Slug != original.Slug {
+			t.Errorf("Expected slug %q to be kept, got %q", original.Slug, updated.Slug)
+		}
+		if !updated.CreatedAt.Equal(original.CreatedAt) {
+			t.Errorf("Expected created_at %v to be kept, got %v", original.CreatedAt, updated.CreatedAt)
+		}
+		if updated.DefinitionVersion != original.DefinitionVersion {
+			t.Errorf("Expected definition version unchanged when adding questions, got %d", updated.DefinitionVersion)
+		}
+	})
+
+	t.Run("update without prior create behaves like create", func(t *testing.T) {
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "update",
+				Repo:       "did:plc:updauthor",
+				Collection: "net.openmeet.survey",
+				RKey:       "upd-new",
+				CID:        "bafyupd3",
+				Record:     testSurveyRecord("Never Seen Before", "q1"),
+			},
+			TimeUs: 1234567912,
+		}
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+
+		survey, err := queries.GetSurveyByURI(ctx, "at://did:plc:updauthor/net.openmeet.survey/upd-new")
+		if err != nil {
+			t.Fatalf("Expected survey to be created, got: %v", err)
+		}
+		if survey.Title != "Never Seen Before" {
+			t.Errorf("Expected title 'Never Seen Before', got: %s", survey.Title)
+		}
+		if survey.Slug == "" {
+			t.Error("Expected slug to be generated")
+		}
+	})
+
+	t.Run("removing an answered question bumps definition version and keeps answers", func(t *testing.T) {
+		create := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Repo:       "did:plc:updauthor",
+				Collection: "net.openmeet.survey",
+				RKey:       "upd4",
+				CID:        "bafyupd4",
+				Record:     testSurveyRecord("Versioned Survey", "q1", "q2"),
+			},
+			TimeUs: 1234567913,
+		}
+		if err := processor.ProcessMessage(ctx, create); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+
+		survey, err := queries.GetSurveyByURI(ctx, "at://did:plc:updauthor/net.openmeet.survey/upd4")
+		if err != nil {
+			t.Fatalf("Failed to get created survey: %v", err)
+		}
+
+		response := &models.Response{
+			ID:        uuid.New(),
+			SurveyID:  survey.ID,
+			VoterDID:  stringPtr("did:plc:updvoter"),
+			RecordURI: stringPtr("at://did:plc:updvoter/net.openmeet.survey.response/updresp1"),
+			RecordCID: stringPtr("bafyupd5"),
+			Answers: map[string]models.Answer{
+				"q1": {SelectedOptions: []string{"a"}},
+				"q2": {SelectedOptions: []string{"b"}},
+			},
+			CreatedAt: time.Now(),
+		}
+		if err := queries.CreateResponse(ctx, response); err != nil {
+			t.Fatalf("Failed to create test response: %v", err)
+		}
+
+		update := &JetstreamMessage{
+			Kind: "commit",
+			Commit: &JetstreamCommit{
+				Operation:  "update",
+				Repo:       "did:plc:updauthor",
+				Collection: "net.openmeet.survey",
+				RKey:       "upd4",
+				CID:        "bafyupd6",
+				Record:     testSurveyRecord("Versioned Survey", "q1"),
+			},
+			TimeUs: 1234567914,
+		}
+		if err := processor.ProcessMessage(ctx, update); err != nil {
+			t.Fatalf("update failed: %v", err)
+		}
+
+		updated, err := queries.GetSurveyByURI(ctx, *survey.URI)
+		if err != nil {
+			t.Fatalf("Failed to get updated survey: %v", err)
+		}
+		if updated.DefinitionVersion != survey.DefinitionVersion+1 {
+			t.Errorf("Expected definition version %d, got %d", survey.DefinitionVersion+1, updated.DefinitionVersion)
+		}
+
+		kept, err := queries.GetResponseByRecordURI(ctx, *response.RecordURI)
+		if err != nil {
+			t.Fatalf("Failed to get response: %v", err)
+		}
+		if kept == nil {
+			t.Fatal("Response should be kept after question removal")
+		}
+		if _, ok := kept.Answers["q2"]; !ok {
+			t.Error("Answer to removed question should be kept")
+		}
+	})
+}
+
+func TestHasRemovedQuestions(t *testing.T) {
+	def := &models.SurveyDefinition{
+		Questions: []models.Question{{ID: "q1"}, {ID: "q2"}},
+	}
+
+	tests := []struct {
+		name     string
+		answered []string
+		want     bool
+	}{
+		{"no answers", nil, false},
+		{"all answered questions present", []string{"q1", "q2"}, false},
+		{"answered question removed", []string{"q1", "q3"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasRemovedQuestions(def, tt.answered); got != tt.want {
+				t.Errorf("hasRemovedQuestions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testResponseMessage(voterDID, rkey, surveyURI, option string) *JetstreamMessage {
+	return &JetstreamMessage{
+		Kind: "commit",
+		Did:  voterDID,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey.response",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey,
+			Record: map[string]interface{}{
+				"$type": "net.openmeet.survey.response",
+				"subject": map[string]interface{}{
+					"uri": surveyURI,
+				},
+				"answers": []interface{}{
+					map[string]interface{}{
+						"questionId":      "q1",
+						"selectedOptions": []interface{}{option},
+					},
+				},
+				"createdAt": time.Now().Format(time.RFC3339),
+			},
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}
+}
+
+// TestResponseDeduplication tests that responses are keyed per voter per survey
+func TestResponseDeduplication(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	// The test database isn't reset between runs, so keep records unique
+	run := uuid.NewString()[:8]
+
+	createSurvey := func(t *testing.T, rkey string, mutate func(record map[string]interface{})) *models.Survey {
+		t.Helper()
+		author := "did:plc:dedupauthor"
+		rkey = rkey + "-" + run
+		record := testSurveyRecord("Dedup "+rkey, "q1")
+		if mutate != nil {
+			mutate(record)
+		}
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Did:  author,
+			Commit: &JetstreamCommit{
+				Operation:  "create",
+				Collection: "net.openmeet.survey",
+				RKey:       rkey,
+				CID:        "bafy_" + rkey,
+				Record:     record,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+		survey, err := queries.GetSurveyByURI(ctx, "at://"+author+"/net.openmeet.survey/"+rkey)
+		if err != nil || survey == nil {
+			t.Fatalf("Failed to load survey: %v", err)
+		}
+		return survey
+	}
+
+	listResponses := func(t *testing.T, survey *models.Survey) []*models.Response {
+		t.Helper()
+		responses, err := queries.ListResponsesBySurvey(ctx, survey.ID)
+		if err != nil {
+			t.Fatalf("Failed to list responses: %v", err)
+		}
+		return responses
+	}
+
+	t.Run("second record from same DID replaces the first", func(t *testing.T) {
+		survey := createSurvey(t, "dedup-single", nil)
+		voter := "did:plc:dedupvoter1" + run
+
+		if err := processor.ProcessMessage(ctx, testResponseMessage(voter, "resp1", *survey.URI, "a")); err != nil {
+			t.Fatalf("First response failed: %v", err)
+		}
+		if err := processor.ProcessMessage(ctx, testResponseMessage(voter, "resp2", *survey.URI, "b")); err != nil {
+			t.Fatalf("Second response failed: %v", err)
+		}
+
+		responses := listResponses(t, survey)
+		if len(responses) != 1 {
+			t.Fatalf("Expected 1 response, got %d", len(responses))
+		}
+		got := responses[0]
+		if opts := got.Answers["q1"].SelectedOptions; len(opts) != 1 || opts[0] != "b" {
+			t.Errorf("Expected latest answers [b], got %v", opts)
+		}
+		wantURI := "at://" + voter + "/net.openmeet.survey.response/resp2"
+		if got.RecordURI == nil || *got.RecordURI != wantURI {
+			t.Errorf("Expected record URI %s, got %v", wantURI, got.RecordURI)
+		}
+
+		// Deleting the replaced record leaves the current response alone
+		del := &JetstreamMessage{
+			Kind: "commit",
+			Did:  voter,
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Collection: "net.openmeet.survey.response",
+				RKey:       "resp1",
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+		if err := processor.ProcessMessage(ctx, del); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if n := len(listResponses(t, survey)); n != 1 {
+			t.Errorf("Expected response to survive delete of replaced record, got %d responses", n)
+		}
+	})
+
+	t.Run("multiple responses allowed keeps each record", func(t *testing.T) {
+		survey := createSurvey(t, "dedup-multi", func(record map[string]interface{}) {
+			record["allowMultipleResponses"] = true
+		})
+		voter := "did:plc:dedupvoter2" + run
+
+		for _, rkey := range []string{"resp1", "resp2"} {
+			if err := processor.ProcessMessage(ctx, testResponseMessage(voter, rkey, *survey.URI, "a")); err != nil {
+				t.Fatalf("Response %s failed: %v", rkey, err)
+			}
+		}
+
+		// Update to an existing rkey replaces only that record's answers
+		update := testResponseMessage(voter, "resp1", *survey.URI, "b")
+		update.Commit.Operation = "update"
+		update.Commit.CID = "bafy_resp1_v2"
+		if err := processor.ProcessMessage(ctx, update); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		responses := listResponses(t, survey)
+		if len(responses) != 2 {
+			t.Fatalf("Expected 2 responses, got %d", len(responses))
+		}
+		for _, r := range responses {
+			want := "a"
+			if r.RecordURI != nil && *r.RecordURI == "at://"+voter+"/net.openmeet.survey.response/resp1" {
+				want = "b"
+			}
+			if opts := r.Answers["q1"].SelectedOptions; len(opts) != 1 || opts[0] != want {
+				t.Errorf("Response %v: expected [%s], got %v", r.RecordURI, want, opts)
+			}
+		}
+	})
+
+	t.Run("anonymous survey still keeps one response per DID", func(t *testing.T) {
+		survey := createSurvey(t, "dedup-anon", func(record map[string]interface{}) {
+			record["anonymous"] = true
+		})
+		voter := "did:plc:dedupvoter3" + run
+
+		if err := processor.ProcessMessage(ctx, testResponseMessage(voter, "resp1", *survey.URI, "a")); err != nil {
+			t.Fatalf("First response failed: %v", err)
+		}
+		if err := processor.ProcessMessage(ctx, testResponseMessage(voter, "resp2", *survey.URI, "b")); err != nil {
+			t.Fatalf("Second response failed: %v", err)
+		}
+
+		responses := listResponses(t, survey)
+		if len(responses) != 1 {
+			t.Fatalf("Expected 1 response, got %d", len(responses))
+		}
+		if opts := responses[0].Answers["q1"].SelectedOptions; len(opts) != 1 || opts[0] != "b" {
+			t.Errorf("Expected latest answers [b], got %v", opts)
+		}
+	})
+}
+
+// TestResponseCounts checks that surveys.response_count follows creates,
+// deduplicated replacements, deletes and hides
+func TestResponseCounts(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+	run := uuid.NewString()[:8]
+
+	author := "did:plc:countauthor"
+	rkey := "counts-" + run
+	surveyURI := "at://" + author + "/net.openmeet.survey/" + rkey
+	if err := processor.ProcessMessage(ctx, &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey,
+			Record:     testSurveyRecord("Counts "+rkey, "q1"),
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+
+	process := func(t *testing.T, msg *JetstreamMessage) {
+		t.Helper()
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+	}
+	deleteMessage := func(voter, rkey string) *JetstreamMessage {
+		return &JetstreamMessage{
+			Kind: "commit",
+			Did:  voter,
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Collection: "net.openmeet.survey.response",
+				RKey:       rkey,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+	assertCount := func(t *testing.T, want int) {
+		t.Helper()
+		counts, err := queries.GetSurveyCounts(ctx, []string{surveyURI, "at://did:plc:nobody/net.openmeet.survey/none"})
+		if err != nil {
+			t.Fatalf("GetSurveyCounts failed: %v", err)
+		}
+		if got, ok := counts[surveyURI]; !ok || got != want {
+			t.Errorf("Expected %d responses, got %d (found %v)", want, got, ok)
+		}
+		if len(counts) != 1 {
+			t.Errorf("Expected only indexed surveys in counts, got %v", counts)
+		}
+	}
+
+	voter1 := "did:plc:countvoter1" + run
+	voter2 := "did:plc:countvoter2" + run
+
+	t.Run("creates are counted", func(t *testing.T) {
+		process(t, testResponseMessage(voter1, "resp1", surveyURI, "a"))
+		process(t, testResponseMessage(voter2, "resp1", surveyURI, "a"))
+		assertCount(t, 2)
+	})
+
+	t.Run("replacements and replays are not", func(t *testing.T) {
+		process(t, testResponseMessage(voter1, "resp2", surveyURI, "b"))
+		process(t, testResponseMessage(voter1, "resp2", surveyURI, "b"))
+		assertCount(t, 2)
+	})
+
+	t.Run("deletes are uncounted", func(t *testing.T) {
+		// voter1's first record was replaced, so deleting it changes nothing
+		process(t, deleteMessage(voter1, "resp1"))
+		assertCount(t, 2)
+
+		process(t, deleteMessage(voter2, "resp1"))
+		assertCount(t, 1)
+
+		process(t, deleteMessage(voter2, "resp1"))
+		assertCount(t, 1)
+	})
+
+	t.Run("hidden responses are not counted", func(t *testing.T) {
+		if _, err := queries.SetHiddenForDID(ctx, voter1, true); err != nil {
+			t.Fatalf("SetHiddenForDID failed: %v", err)
+		}
+		assertCount(t, 0)
+
+		if _, err := queries.SetHiddenForDID(ctx, voter1, false); err != nil {
+			t.Fatalf("SetHiddenForDID failed: %v", err)
+		}
+		assertCount(t, 1)
+	})
+
+	t.Run("recount repairs drift", func(t *testing.T) {
+		if _, err := database.Exec(`UPDATE surveys SET response_count = 42 WHERE uri = $1`, surveyURI); err != nil {
+			t.Fatalf("Failed to corrupt count: %v", err)
+		}
+
+		count, err := queries.RecountSurveyResponses(ctx, surveyURI)
+		if err != nil {
+			t.Fatalf("RecountSurveyResponses failed: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected recount of 1, got %d", count)
+		}
+		assertCount(t, 1)
+	})
+}
+
+// TestPublishedResults tests that results record tallies are stored as the survey's published snapshot
+func TestPublishedResults(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	// The test database isn't reset between runs, so keep records unique
+	run := uuid.NewString()[:8]
+	author := "did:plc:resultsauthor"
+	surveyRKey := "results-survey-" + run
+	surveyURI := "at://" + author + "/net.openmeet.survey/" + surveyRKey
+	resultsRKey := "results-" + run
+	resultsURI := "at://" + author + "/net.openmeet.survey.results/" + resultsRKey
+
+	createSurvey := &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       surveyRKey,
+			CID:        "bafy_" + surveyRKey,
+			Record:     testSurveyRecord("Results "+run, "q1"),
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}
+	if err := processor.ProcessMessage(ctx, createSurvey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+
+	resultsMessage := func(operation string, record map[string]interface{}) *JetstreamMessage {
+		return &JetstreamMessage{
+			Kind: "commit",
+			Did:  author,
+			Commit: &JetstreamCommit{
+				Operation:  operation,
+				Collection: "net.openmeet.survey.results",
+				RKey:       resultsRKey,
+				CID:        "bafy_" + resultsRKey,
+				Record:     record,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+	resultsRecord := func(countA float64) map[string]interface{} {
+		return map[string]interface{}{
+			"$type":      "net.openmeet.survey.results",
+			"subject":    map[string]interface{}{"uri": surveyURI},
+			"totalVotes": countA,
+			"questionResults": []interface{}{
+				map[string]interface{}{
+					"questionId": "q1",
+					"optionCounts": []interface{}{
+						map[string]interface{}{"optionId": "a", "count": countA},
+					},
+				},
+			},
+			"finalizedAt": "2025-03-08T12:00:00Z",
+		}
+	}
+
+	t.Run("create stores snapshot", func(t *testing.T) {
+		if err := processor.ProcessMessage(ctx, resultsMessage("create", resultsRecord(3))); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		published, err := queries.GetPublishedResults(ctx, surveyURI)
+		if err != nil || published == nil {
+			t.Fatalf("Expected published results, got %v (err %v)", published, err)
+		}
+		if published.ResultsURI != resultsURI || published.PublisherDID != author || published.TotalVotes != 3 {
+			t.Errorf("Unexpected published results %+v", published)
+		}
+		if q1 := published.QuestionResults["q1"]; q1 == nil || q1.OptionCounts["a"] != 3 {
+			t.Errorf("Unexpected q1 tally %+v", q1)
+		}
+		if !published.PublishedAt.Equal(time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected publishedAt from finalizedAt, got %v", published.PublishedAt)
+		}
+	})
+
+	t.Run("update replaces snapshot", func(t *testing.T) {
+		if err := processor.ProcessMessage(ctx, resultsMessage("update", resultsRecord(7))); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		published, err := queries.GetPublishedResults(ctx, surveyURI)
+		if err != nil || published == nil {
+			t.Fatalf("Expected published results, got %v (err %v)", published, err)
+		}
+		if published.TotalVotes != 7 {
+			t.Errorf("Expected 7 total votes, got %d", published.TotalVotes)
+		}
+	})
+
+	t.Run("malformed tallies still track the record", func(t *testing.T) {
+		record := resultsRecord(7)
+		record["questionResults"] = "not an array"
+		if err := processor.ProcessMessage(ctx, resultsMessage("update", record)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		survey, err := queries.GetSurveyByURI(ctx, surveyURI)
+		if err != nil || survey == nil {
+			t.Fatalf("Failed to load survey: %v", err)
+		}
+		if survey.ResultsURI == nil || *survey.ResultsURI != resultsURI {
+			t.Errorf("Expected results URI %s, got %v", resultsURI, survey.ResultsURI)
+		}
+
+		published, err := queries.GetPublishedResults(ctx, surveyURI)
+		if err != nil {
+			t.Fatalf("GetPublishedResults failed: %v", err)
+		}
+		if published != nil {
+			t.Errorf("Expected stale snapshot to be dropped, got %+v", published)
+		}
+	})
+
+	t.Run("delete removes snapshot", func(t *testing.T) {
+		if err := processor.ProcessMessage(ctx, resultsMessage("update", resultsRecord(2))); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if err := processor.ProcessMessage(ctx, resultsMessage("delete", nil)); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+
+		published, err := queries.GetPublishedResults(ctx, surveyURI)
+		if err != nil {
+			t.Fatalf("GetPublishedResults failed: %v", err)
+		}
+		if published != nil {
+			t.Errorf("Expected snapshot to be deleted, got %+v", published)
+		}
+	})
+}
+
+func TestSurveyLang(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	run := uuid.NewString()[:8]
+	author := "did:plc:langauthor"
+	rkey := "lang-survey-" + run
+	uri := "at://" + author + "/net.openmeet.survey/" + rkey
+
+	surveyMessage := func(operation string, lang interface{}) *JetstreamMessage {
+		record := testSurveyRecord("Encuesta "+run, "q1")
+		if lang != nil {
+			record["lang"] = lang
+		}
+		return &JetstreamMessage{
+			Kind: "commit",
+			Did:  author,
+			Commit: &JetstreamCommit{
+				Operation:  operation,
+				Collection: "net.openmeet.survey",
+				RKey:       rkey,
+				CID:        "bafy_" + rkey + "_" + operation,
+				Record:     record,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+	storedLang := func() *string {
+		t.Helper()
+		survey, err := queries.GetSurveyByURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("Failed to get survey: %v", err)
+		}
+		return survey.Lang
+	}
+
+	t.Run("stores canonical lang", func(t *testing.T) {
+		if err := processor.ProcessMessage(ctx, surveyMessage("create", "es_mx")); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if lang := storedLang(); lang == nil || *lang != "es-MX" {
+			t.Errorf("Expected lang es-MX, got %v", lang)
+		}
+
+		surveys, _, err := queries.ListSurveys(ctx, db.ListSurveysParams{Lang: "es", Limit: 100})
+		if err != nil {
+			t.Fatalf("ListSurveys failed: %v", err)
+		}
+		found := false
+		for _, s := range surveys {
+			if s.URI != nil && *s.URI == uri {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("Expected lang=es listing to include the es-MX survey")
+		}
+
+		surveys, _, err = queries.ListSurveys(ctx, db.ListSurveysParams{Lang: "ja", Limit: 100})
+		if err != nil {
+			t.Fatalf("ListSurveys failed: %v", err)
+		}
+		for _, s := range surveys {
+			if s.URI != nil && *s.URI == uri {
+				t.Error("Expected lang=ja listing to exclude the es-MX survey")
+			}
+		}
+	})
+
+	t.Run("malformed lang is dropped on update", func(t *testing.T) {
+		if err := processor.ProcessMessage(ctx, surveyMessage("update", "not a language!")); err != nil {
+			t.Fatalf("Expected malformed lang not to fail the record: %v", err)
+		}
+		if lang := storedLang(); lang != nil {
+			t.Errorf("Expected lang cleared, got %q", *lang)
+		}
+	})
+}
+
+// TestSurveySchedule ensures a record's startsAt and endsAt are stored, so
+// listings can filter surveys by status
+func TestSurveySchedule(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	run := uuid.NewString()[:8]
+	author := "did:plc:schedule-" + run
+	rkey := "schedule-" + run
+	uri := "at://" + author + "/net.openmeet.survey/" + rkey
+	opens := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	surveyMessage := func(operation string, schedule map[string]interface{}) *JetstreamMessage {
+		record := testSurveyRecord("Schedule "+run, "q1")
+		for field, value := range schedule {
+			record[field] = value
+		}
+		return &JetstreamMessage{
+			Kind: "commit",
+			Did:  author,
+			Commit: &JetstreamCommit{
+				Operation:  operation,
+				Collection: "net.openmeet.survey",
+				RKey:       rkey,
+				CID:        "bafy_" + rkey + "_" + operation,
+				Record:     record,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+	}
+	listed := func(status models.SurveyStatus) bool {
+		t.Helper()
+		surveys, _, err := queries.ListSurveys(ctx, db.ListSurveysParams{Status: status, AuthorDID: author, Limit: 10})
+		if err != nil {
+			t.Fatalf("ListSurveys failed: %v", err)
+		}
+		return len(surveys) == 1 && surveys[0].URI != nil && *surveys[0].URI == uri
+	}
+
+	t.Run("create stores the schedule", func(t *testing.T) {
+		err := processor.ProcessMessage(ctx, surveyMessage("create", map[string]interface{}{"startsAt": opens.Format(time.RFC3339)}))
+		if err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		survey, err := queries.GetSurveyByURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("Failed to get survey: %v", err)
+		}
+		if survey.StartsAt == nil || !survey.StartsAt.Equal(opens) || survey.EndsAt != nil {
+			t.Errorf("Expected to open at %v, got %v to %v", opens, survey.StartsAt, survey.EndsAt)
+		}
+		if !listed(models.SurveyStatusScheduled) || listed(models.SurveyStatusOpen) {
+			t.Error("Expected the survey listed as scheduled only")
+		}
+	})
+
+	t.Run("update replaces the schedule", func(t *testing.T) {
+		closed := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		if err := processor.ProcessMessage(ctx, surveyMessage("update", map[string]interface{}{"endsAt": closed})); err != nil {
+			t.Fatalf("ProcessMessage failed: %v", err)
+		}
+		if !listed(models.SurveyStatusClosed) || listed(models.SurveyStatusScheduled) {
+			t.Error("Expected the survey listed as closed only")
+		}
+	})
+}
+
+// TestIngestClosedSurvey ensures a survey whose record closed it is indexed
+// closed, so its page shows it closed and refuses responses
+func TestIngestClosedSurvey(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("FROM blocked_dids").Rows([]string{"exists"}, []interface{}{false})
+	fake.Expect("WHERE uri").Rows([]string{"id"})
+	fake.Expect("FROM surveys WHERE slug").Rows([]string{"exists"}, []interface{}{false})
+	fake.Expect("INSERT INTO surveys").RowsAffected(1)
+	processor := NewProcessor(db.NewQueries(fake))
+
+	opened := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Second)
+	closed := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	record := testSurveyRecord("Last week's lunch", "q1")
+	record["startsAt"] = opened.Format(time.RFC3339)
+	record["endsAt"] = closed.Format(time.RFC3339)
+	err := processor.ProcessMessage(context.Background(), &JetstreamMessage{
+		Kind: "commit",
+		Did:  "did:plc:closedauthor",
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       "closed",
+			CID:        "bafyclosed",
+			Record:     record,
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProcessMessage failed: %v", err)
+	}
+
+	insert := fake.CallsMatching("INSERT INTO surveys")
+	if len(insert) != 1 {
+		t.Fatalf("Expected one insert, got %d", len(insert))
+	}
+	startsAt, _ := insert[0].Args[8].(*time.Time)
+	endsAt, _ := insert[0].Args[9].(*time.Time)
+	if startsAt == nil || !startsAt.Equal(opened) || endsAt == nil || !endsAt.Equal(closed) {
+		t.Fatalf("Expected the survey stored open from %v to %v, got %v to %v", opened, closed, startsAt, endsAt)
+	}
+	survey := &models.Survey{StartsAt: startsAt, EndsAt: endsAt}
+	if status := survey.StatusAt(time.Now()); status != models.SurveyStatusClosed {
+		t.Errorf("Expected the indexed survey closed, got %s", status)
+	}
+}
+
+// TestResponseToUnknownSurvey ensures a response to a survey we don't index
+// is rejected as an invalid record rather than counted as a database error
+func TestResponseToUnknownSurvey(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("FROM blocked_dids").Rows([]string{"exists"}, []interface{}{false})
+	fake.Expect("FROM responses").Rows([]string{"id"})
+	fake.Expect("WHERE uri").Rows([]string{"id"})
+	processor := NewProcessor(db.NewQueries(fake))
+
+	msg := testResponseMessage("did:plc:voter", "unknown", "at://did:plc:stranger/net.openmeet.survey/missing", "a")
+	err := processor.ProcessMessage(context.Background(), msg)
+	if !errors.Is(err, ErrInvalidRecord) {
+		t.Fatalf("Expected an invalid record error, got %v", err)
+	}
+	if result := eventResult(err); result != ResultParseError {
+		t.Errorf("Expected result %q, got %q", ResultParseError, result)
+	}
+	if inserts := fake.CallsMatching("INSERT INTO responses"); len(inserts) != 0 {
+		t.Errorf("Expected no response stored, got %d inserts", len(inserts))
+	}
+}
+
+// TestRecordsForDeletedSurvey ensures responses and comments to a survey in
+// its soft-delete window are rejected rather than indexed and counted
+func TestRecordsForDeletedSurvey(t *testing.T) {
+	surveyURI := "at://did:plc:author/net.openmeet.survey/deleted"
+	now := time.Now()
+	def := `{"questions":[{"id":"q1","text":"Question q1?","type":"single","required":true,"options":[{"id":"a","text":"Option A"},{"id":"b","text":"Option B"}]}]}`
+
+	tests := []struct {
+		name string
+		msg  *JetstreamMessage
+	}{
+		{"response", testResponseMessage("did:plc:voter", "late-response", surveyURI, "a")},
+		{"comment", testCommentMessage("did:plc:voter", "late-comment", surveyURI, "Too late?")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := queriestest.New(t)
+			fake.Expect("FROM blocked_dids").Rows([]string{"exists"}, []interface{}{false})
+			fake.Expect("FROM responses").Rows([]string{"id"})
+			fake.Expect("FROM survey_comments").Rows([]string{"id"})
+			fake.Expect("WHERE uri").Rows(
+				[]string{"id", "uri", "cid", "author_did", "slug", "title", "description", "definition", "starts_at", "ends_at", "results_uri", "results_cid", "definition_version", "version", "lang", "created_at", "updated_at", "record_updated_at", "deleted_at"},
+				[]interface{}{uuid.New(), surveyURI, "bafydeleted", "did:plc:author", "deleted", "Deleted", nil, def, nil, nil, nil, nil, 1, 1, nil, now, now, nil, now},
+			)
+			processor := NewProcessor(db.NewQueries(fake))
+
+			err := processor.ProcessMessage(context.Background(), tt.msg)
+			if !errors.Is(err, ErrInvalidRecord) || !strings.Contains(err.Error(), "survey deleted") {
+				t.Fatalf("Expected an invalid record error, got %v", err)
+			}
+			if writes := len(fake.CallsMatching("INSERT INTO")) + len(fake.CallsMatching("UPDATE surveys")); writes != 0 {
+				t.Errorf("Expected nothing written for a deleted survey, got %d writes", writes)
+			}
+		})
+	}
+}
+
+func TestIdempotentIngestion(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	run := uuid.NewString()[:8]
+	author := "did:plc:replayauthor"
+	surveyRKey := "replay-survey-" + run
+	surveyURI := "at://" + author + "/net.openmeet.survey/" + surveyRKey
+	voter := "did:plc:replayvoter" + run
+
+	countRows := func(t *testing.T, query string, args ...interface{}) int {
+		t.Helper()
+		var n int
+		if err := database.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		return n
+	}
+
+	surveyMessage := &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       surveyRKey,
+			CID:        "bafy_" + surveyRKey,
+			Record:     testSurveyRecord("Replay "+run, "q1"),
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}
+	responseMessage := testResponseMessage(voter, "resp-"+run, surveyURI, "a")
+
+	// Feed each event twice, as a replay from a coarse cursor would
+	for i := 0; i < 2; i++ {
+		if err := processor.ProcessMessage(ctx, surveyMessage); err != nil {
+			t.Fatalf("Survey event %d failed: %v", i+1, err)
+		}
+		if err := processor.ProcessMessage(ctx, responseMessage); err != nil {
+			t.Fatalf("Response event %d failed: %v", i+1, err)
+		}
+	}
+
+	if n := countRows(t, `SELECT COUNT(*) FROM surveys WHERE uri = $1`, surveyURI); n != 1 {
+		t.Errorf("Expected 1 survey row after replay, got %d", n)
+	}
+	survey, err := queries.GetSurveyByURI(ctx, surveyURI)
+	if err != nil {
+		t.Fatalf("Failed to get survey: %v", err)
+	}
+	if n := countRows(t, `SELECT COUNT(*) FROM responses WHERE survey_id = $1`, survey.ID); n != 1 {
+		t.Errorf("Expected 1 response row after replay, got %d", n)
+	}
+
+	t.Run("replay leaves the row untouched", func(t *testing.T) {
+		duplicates := EventsTotal.WithLabelValues("net.openmeet.survey", "create", ResultDuplicate)
+		beforeDuplicates := testutil.ToFloat64(duplicates)
+		before := survey.UpdatedAt
+
+		if err := processor.ProcessMessage(ctx, surveyMessage); err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if got := testutil.ToFloat64(duplicates); got != beforeDuplicates+1 {
+			t.Errorf("Expected duplicate counter %v, got %v", beforeDuplicates+1, got)
+		}
+		after, err := queries.GetSurveyByURI(ctx, surveyURI)
+		if err != nil {
+			t.Fatalf("Failed to get survey: %v", err)
+		}
+		if !after.UpdatedAt.Equal(before) {
+			t.Errorf("Expected replay not to rewrite the survey, updated_at moved from %v to %v", before, after.UpdatedAt)
+		}
+	})
+
+	t.Run("new CID for the same rkey is an update", func(t *testing.T) {
+		changed := testResponseMessage(voter, "resp-"+run, surveyURI, "b")
+		changed.Commit.CID = "bafy_resp-" + run + "_v2"
+		if err := processor.ProcessMessage(ctx, changed); err != nil {
+			t.Fatalf("Changed response failed: %v", err)
+		}
+
+		responses, err := queries.ListResponsesBySurvey(ctx, survey.ID)
+		if err != nil {
+			t.Fatalf("Failed to list responses: %v", err)
+		}
+		if len(responses) != 1 {
+			t.Fatalf("Expected 1 response, got %d", len(responses))
+		}
+		got := responses[0]
+		if got.RecordCID == nil || *got.RecordCID != changed.Commit.CID {
+			t.Errorf("Expected CID %s, got %v", changed.Commit.CID, got.RecordCID)
+		}
+		if opts := got.Answers["q1"].SelectedOptions; len(opts) != 1 || opts[0] != "b" {
+			t.Errorf("Expected updated answers [b], got %v", opts)
+		}
+	})
+}
+
+func testCommentMessage(authorDID, rkey, surveyURI, text string) *JetstreamMessage {
+	return &JetstreamMessage{
+		Kind: "commit",
+		Did:  authorDID,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey.comment",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey,
+			Record: map[string]interface{}{
+				"$type": "net.openmeet.survey.comment",
+				"subject": map[string]interface{}{
+					"uri": surveyURI,
+				},
+				"text":      text,
+				"createdAt": time.Now().Format(time.RFC3339),
+			},
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}
+}
+
+// TestSurveyComments tests create, replay, edit, and delete of comment records
+func TestSurveyComments(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	run := uuid.NewString()[:8]
+	author := "did:plc:commentauthor"
+	surveyRKey := "comment-survey-" + run
+	surveyURI := "at://" + author + "/net.openmeet.survey/" + surveyRKey
+	commenter := "did:plc:commenter" + run
+	commentRKey := "comment-" + run
+	commentURI := "at://" + commenter + "/net.openmeet.survey.comment/" + commentRKey
+
+	surveyMessage := &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       surveyRKey,
+			CID:        "bafy_" + surveyRKey,
+			Record:     testSurveyRecord("Comments "+run, "q1"),
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}
+	if err := processor.ProcessMessage(ctx, surveyMessage); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	survey, err := queries.GetSurveyByURI(ctx, surveyURI)
+	if err != nil {
+		t.Fatalf("Failed to get survey: %v", err)
+	}
+
+	t.Run("create indexes the comment", func(t *testing.T) {
+		if err := processor.ProcessMessage(ctx, testCommentMessage(commenter, commentRKey, surveyURI, " First! ")); err != nil {
+			t.Fatalf("Comment create failed: %v", err)
+		}
+
+		comments, err := queries.ListCommentsBySurvey(ctx, survey.ID, 10)
+		if err != nil {
+			t.Fatalf("Failed to list comments: %v", err)
+		}
+		if len(comments) != 1 {
+			t.Fatalf("Expected 1 comment, got %d", len(comments))
+		}
+		if comments[0].Text != "First!" || comments[0].AuthorDID != commenter || comments[0].RecordURI != commentURI {
+			t.Errorf("Unexpected comment %+v", comments[0])
+		}
+	})
+
+	t.Run("replay is a duplicate", func(t *testing.T) {
+		duplicates := EventsTotal.WithLabelValues("net.openmeet.survey.comment", "create", ResultDuplicate)
+		before := testutil.ToFloat64(duplicates)
+
+		if err := processor.ProcessMessage(ctx, testCommentMessage(commenter, commentRKey, surveyURI, " First! ")); err != nil {
+			t.Fatalf("Replay failed: %v", err)
+		}
+		if got := testutil.ToFloat64(duplicates); got != before+1 {
+			t.Errorf("Expected duplicate counter %v, got %v", before+1, got)
+		}
+	})
+
+	t.Run("update replaces the text", func(t *testing.T) {
+		msg := testCommentMessage(commenter, commentRKey, surveyURI, "Edited")
+		msg.Commit.Operation = "update"
+		msg.Commit.CID = "bafy_" + commentRKey + "_v2"
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Comment update failed: %v", err)
+		}
+
+		comment, err := queries.GetCommentByRecordURI(ctx, commentURI)
+		if err != nil || comment == nil {
+			t.Fatalf("Failed to get comment: %v", err)
+		}
+		if comment.Text != "Edited" || comment.RecordCID != msg.Commit.CID {
+			t.Errorf("Expected edited comment, got %+v", comment)
+		}
+	})
+
+	t.Run("comment on an unknown survey is rejected", func(t *testing.T) {
+		msg := testCommentMessage(commenter, "orphan-"+run, "at://"+author+"/net.openmeet.survey/missing-"+run, "Hello?")
+		if err := processor.ProcessMessage(ctx, msg); err == nil {
+			t.Error("Expected error for a comment on an unindexed survey")
+		}
+	})
+
+	t.Run("only the author can delete", func(t *testing.T) {
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Did:  "did:plc:someoneelse" + run,
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Collection: "net.openmeet.survey.comment",
+				RKey:       commentRKey,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+		// Another repo's rkey resolves to a different URI, so nothing is deleted
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Delete from another repo failed: %v", err)
+		}
+		if comment, _ := queries.GetCommentByRecordURI(ctx, commentURI); comment == nil {
+			t.Error("Expected comment to survive a delete from another repo")
+		}
+	})
+
+	t.Run("delete removes the row", func(t *testing.T) {
+		msg := &JetstreamMessage{
+			Kind: "commit",
+			Did:  commenter,
+			Commit: &JetstreamCommit{
+				Operation:  "delete",
+				Collection: "net.openmeet.survey.comment",
+				RKey:       commentRKey,
+			},
+			TimeUs: time.Now().UnixMicro(),
+		}
+		if err := processor.ProcessMessage(ctx, msg); err != nil {
+			t.Fatalf("Comment delete failed: %v", err)
+		}
+		comment, err := queries.GetCommentByRecordURI(ctx, commentURI)
+		if err != nil {
+			t.Fatalf("Failed to get comment: %v", err)
+		}
+		if comment != nil {
+			t.Errorf("Expected comment to be deleted, got %+v", comment)
+		}
+	})
+}
+
+// TestSurveyRecordTracking tests that creates and updates store the record's
+// CID and event time
+func TestSurveyRecordTracking(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	processor := NewProcessor(queries)
+	ctx := context.Background()
+
+	run := uuid.NewString()[:8]
+	author := "did:plc:trackauthor"
+	rkey := "track-" + run
+	uri := "at://" + author + "/net.openmeet.survey/" + rkey
+
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)
+	msg := &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey,
+			Record:     testSurveyRecord("Tracked "+run, "q1"),
+		},
+		TimeUs: createdAt.UnixMicro(),
+	}
+	if err := processor.ProcessMessage(ctx, msg); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+
+	cid, err := queries.GetSurveyCID(ctx, uri)
+	if err != nil {
+		t.Fatalf("GetSurveyCID failed: %v", err)
+	}
+	if cid != "bafy_"+rkey {
+		t.Errorf("Expected CID bafy_%s, got %q", rkey, cid)
+	}
+	survey, err := queries.GetSurveyByURI(ctx, uri)
+	if err != nil {
+		t.Fatalf("Failed to get survey: %v", err)
+	}
+	if survey.RecordUpdatedAt == nil || !survey.RecordUpdatedAt.Equal(createdAt) {
+		t.Errorf("Expected record_updated_at %v, got %v", createdAt, survey.RecordUpdatedAt)
+	}
+
+	editedAt := createdAt.Add(30 * time.Minute)
+	edit := &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "update",
+			Collection: "net.openmeet.survey",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey + "_v2",
+			Record:     testSurveyRecord("Tracked "+run+" (edited)", "q1"),
+		},
+		TimeUs: editedAt.UnixMicro(),
+	}
+	if err := processor.ProcessMessage(ctx, edit); err != nil {
+		t.Fatalf("Failed to update survey: %v", err)
+	}
+
+	if cid, _ := queries.GetSurveyCID(ctx, uri); cid != edit.Commit.CID {
+		t.Errorf("Expected CID %s after update, got %q", edit.Commit.CID, cid)
+	}
+	survey, err = queries.GetSurveyByURI(ctx, uri)
+	if err != nil {
+		t.Fatalf("Failed to get survey: %v", err)
+	}
+	if survey.RecordUpdatedAt == nil || !survey.RecordUpdatedAt.Equal(editedAt) {
+		t.Errorf("Expected record_updated_at %v after update, got %v", editedAt, survey.RecordUpdatedAt)
+	}
+
+	t.Run("unknown URI has no CID", func(t *testing.T) {
+		cid, err := queries.GetSurveyCID(ctx, "at://"+author+"/net.openmeet.survey/missing-"+run)
+		if err != nil || cid != "" {
+			t.Errorf("Expected empty CID and no error, got %q, %v", cid, err)
+		}
+	})
+}
+
+// TestIngestionRollsBackOnError tests that a failure part-way through an
+// event's transaction leaves none of its writes behind
+func TestIngestionRollsBackOnError(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	ctx := context.Background()
+	run := uuid.NewString()[:8]
+	author := "did:plc:txauthor"
+	rkey := "tx-" + run
+	surveyURI := "at://" + author + "/net.openmeet.survey/" + rkey
+	errCrash := errors.New("crash after write")
+
+	surveyMsg := &JetstreamMessage{
+		Kind: "commit",
+		Did:  author,
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       rkey,
+			CID:        "bafy_" + rkey,
+			Record:     testSurveyRecord("Transactional "+run, "q1"),
+		},
+		TimeUs: time.Now().UnixMicro(),
+	}
+
+	t.Run("survey insert is rolled back", func(t *testing.T) {
+		err := queries.WithTx(ctx, func(q *db.Queries) error {
+			if err := NewProcessor(q).ProcessMessage(ctx, surveyMsg); err != nil {
+				t.Fatalf("Failed to process survey: %v", err)
+			}
+			return errCrash
+		})
+		if !errors.Is(err, errCrash) {
+			t.Fatalf("Expected callback error, got %v", err)
+		}
+
+		if cid, err := queries.GetSurveyCID(ctx, surveyURI); err != nil || cid != "" {
+			t.Errorf("Expected no survey after rollback, got CID %q, err %v", cid, err)
+		}
+	})
+
+	// Replaying the event after the failed attempt indexes it normally
+	if err := NewProcessor(queries).ProcessMessageInTx(ctx, surveyMsg); err != nil {
+		t.Fatalf("Failed to replay survey: %v", err)
+	}
+	if cid, _ := queries.GetSurveyCID(ctx, surveyURI); cid != surveyMsg.Commit.CID {
+		t.Fatalf("Expected survey to be indexed on replay, got CID %q", cid)
+	}
+
+	t.Run("response insert is rolled back", func(t *testing.T) {
+		voter := "did:plc:txvoter" + run
+		msg := testResponseMessage(voter, "txresp-"+run, surveyURI, "a")
+		recordURI := "at://" + voter + "/net.openmeet.survey.response/txresp-" + run
+
+		err := queries.WithTx(ctx, func(q *db.Queries) error {
+			if err := NewProcessor(q).ProcessMessage(ctx, msg); err != nil {
+				t.Fatalf("Failed to process response: %v", err)
+			}
+			return errCrash
+		})
+		if !errors.Is(err, errCrash) {
+			t.Fatalf("Expected callback error, got %v", err)
+		}
+
+		response, err := queries.GetResponseByRecordURI(ctx, recordURI)
+		if err != nil {
+			t.Fatalf("GetResponseByRecordURI failed: %v", err)
+		}
+		if response != nil {
+			t.Errorf("Expected no response after rollback, got %v", response.ID)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db"
+)
+
+const (
+	// DefaultDeleteGracePeriod is how long a soft-deleted survey can be restored
+	DefaultDeleteGracePeriod = 30 * 24 * time.Hour
+
+	// SurveyPurgeInterval is how often the purge worker runs
+	SurveyPurgeInterval = 24 * time.Hour
+)
+
+// DeleteGracePeriodFromEnv reads SURVEY_DELETE_GRACE_DAYS, the number of days
+// a deleted survey is kept before it is purged (default 30)
+func DeleteGracePeriodFromEnv() (time.Duration, error) {
+	value := os.Getenv("SURVEY_DELETE_GRACE_DAYS")
+	if value == "" {
+		return DefaultDeleteGracePeriod, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("invalid SURVEY_DELETE_GRACE_DAYS: %q", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// RestoreSurvey undoes the soft delete of the survey at uri within its grace
+// period and records it in the audit log under actor, in one transaction
+func RestoreSurvey(ctx context.Context, queries *db.Queries, actor, uri string) error {
+	return queries.WithTx(ctx, func(q *db.Queries) error {
+		if err := q.RestoreSurvey(ctx, uri); err != nil {
+			return err
+		}
+		return q.RecordAudit(ctx, actor, db.AuditActionRestoreSurvey, uri, nil)
+	})
+}
+
+// SurveyPurger permanently removes soft-deleted surveys, recording purges in
+// the audit log
+type SurveyPurger interface {
+	PurgeDeletedSurveys(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// StartSurveyPurgeWorker purges surveys deleted more than grace ago, now and
+// then every interval until ctx is cancelled
+func StartSurveyPurgeWorker(ctx context.Context, purger SurveyPurger, grace, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Survey purge worker started (grace period: %v, interval: %v)", grace, interval)
+
+	runSurveyPurge(ctx, purger, grace, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Survey purge worker stopped")
+			return
+		case <-ticker.C:
+			runSurveyPurge(ctx, purger, grace, time.Now())
+		}
+	}
+}
+
+// runSurveyPurge purges once as of now and logs the result
+func runSurveyPurge(ctx context.Context, purger SurveyPurger, grace time.Duration, now time.Time) {
+	purged, err := purger.PurgeDeletedSurveys(ctx, now.Add(-grace))
+	if err != nil {
+		log.Printf("Error purging deleted surveys: %v", err)
+		return
+	}
+	SurveysPurged.Add(float64(purged))
+	if purged > 0 {
+		log.Printf("Purged %d deleted surveys", purged)
+	}
+}
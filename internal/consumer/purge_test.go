@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePurger records the cutoff it was asked to purge before
+type fakePurger struct {
+	deletedBefore time.Time
+	purged        int64
+	err           error
+}
+
+func (f *fakePurger) PurgeDeletedSurveys(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	f.deletedBefore = deletedBefore
+	return f.purged, f.err
+}
+
+func TestDeleteGracePeriodFromEnv(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		t.Setenv("SURVEY_DELETE_GRACE_DAYS", "")
+		grace, err := DeleteGracePeriodFromEnv()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if grace != DefaultDeleteGracePeriod {
+			t.Errorf("Expected %v, got %v", DefaultDeleteGracePeriod, grace)
+		}
+	})
+
+	t.Run("days", func(t *testing.T) {
+		t.Setenv("SURVEY_DELETE_GRACE_DAYS", "7")
+		grace, err := DeleteGracePeriodFromEnv()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if grace != 7*24*time.Hour {
+			t.Errorf("Expected 7 days, got %v", grace)
+		}
+	})
+
+	for _, value := range []string{"0", "-3", "a month"} {
+		t.Run("invalid "+value, func(t *testing.T) {
+			t.Setenv("SURVEY_DELETE_GRACE_DAYS", value)
+			if _, err := DeleteGracePeriodFromEnv(); err == nil {
+				t.Errorf("Expected an error for %q", value)
+			}
+		})
+	}
+}
+
+func TestRunSurveyPurge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	purger := &fakePurger{purged: 2}
+	runSurveyPurge(context.Background(), purger, 30*24*time.Hour, now)
+	if want := now.Add(-30 * 24 * time.Hour); !purger.deletedBefore.Equal(want) {
+		t.Errorf("Expected cutoff %v, got %v", want, purger.deletedBefore)
+	}
+
+	// Errors are logged, not fatal
+	runSurveyPurge(context.Background(), &fakePurger{err: errors.New("boom")}, time.Hour, now)
+}
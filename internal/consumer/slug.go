@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/openmeet-team/survey/internal/db"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxSlugLength matches the limit enforced by models.ValidateSlug
+	MaxSlugLength = 50
+
+	// maxSlugSuffix bounds how many numbered variants (-2 … -100) are tried
+	maxSlugSuffix = 100
+)
+
+var slugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// latinReplacer transliterates Latin letters that NFKD does not decompose
+var latinReplacer = strings.NewReplacer(
+	"ß", "ss",
+	"æ", "ae",
+	"œ", "oe",
+	"ø", "o",
+	"đ", "d",
+	"ð", "d",
+	"ł", "l",
+	"þ", "th",
+	"ı", "i",
+)
+
+// slugSuffixChars is the alphabet for random fallback slug suffixes
+const slugSuffixChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// GenerateSlugFromTitle creates a URL-friendly base slug from a survey title.
+// Accented Latin characters are folded to ASCII ("Étude café" -> "etude-cafe").
+// Titles with fewer than 3 usable characters (e.g. CJK or emoji only) get a
+// random fallback like "survey-x7k2q".
+// It does not check for collisions; use ResolveUniqueSlug for that.
+func GenerateSlugFromTitle(title string) string {
+	// Convert to lowercase and fold diacritics
+	slug := latinReplacer.Replace(strings.ToLower(title))
+	slug = stripDiacritics(slug)
+
+	// Replace non-alphanumeric characters with hyphens
+	slug = slugRegex.ReplaceAllString(slug, "-")
+
+	// Trim leading/trailing hyphens
+	slug = strings.Trim(slug, "-")
+
+	// Fall back to a random slug if too little survived
+	if len(slug) < 3 {
+		return "survey-" + randomSlugSuffix(5)
+	}
+
+	return truncateSlug(slug, MaxSlugLength)
+}
+
+// stripDiacritics decomposes s (NFKD) and drops combining marks
+func stripDiacritics(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// randomSlugSuffix returns n random lowercase alphanumeric characters
+func randomSlugSuffix(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = slugSuffixChars[rand.Intn(len(slugSuffixChars))]
+	}
+	return string(b)
+}
+
+// truncateSlug cuts slug to at most maxLen characters without leaving a trailing hyphen
+func truncateSlug(slug string, maxLen int) string {
+	if len(slug) <= maxLen {
+		return slug
+	}
+	return strings.TrimRight(slug[:maxLen], "-")
+}
+
+// slugWithSuffix appends -n to base, truncating base so the result still fits MaxSlugLength
+func slugWithSuffix(base string, n int) string {
+	suffix := "-" + strconv.Itoa(n)
+	return truncateSlug(base, MaxSlugLength-len(suffix)) + suffix
+}
+
+// ResolveUniqueSlug generates a slug from title and, if it is taken, tries
+// -2, -3, … up to -100. A concurrent insert can still claim the slug between
+// this check and the caller's insert; callers should retry when the insert
+// returns db.ErrSlugTaken.
+func ResolveUniqueSlug(ctx context.Context, q *db.Queries, title string) (string, error) {
+	base := GenerateSlugFromTitle(title)
+
+	exists, err := q.SlugExists(ctx, base)
+	if err != nil {
+		return "", fmt.Errorf("failed to check slug existence: %w", err)
+	}
+	if !exists {
+		return base, nil
+	}
+
+	for n := 2; n <= maxSlugSuffix; n++ {
+		slug := slugWithSuffix(base, n)
+		exists, err := q.SlugExists(ctx, slug)
+		if err != nil {
+			return "", fmt.Errorf("failed to check slug existence: %w", err)
+		}
+		if !exists {
+			return slug, nil
+		}
+	}
+
+	return "", fmt.Errorf("too many slug collisions for %s", base)
+}
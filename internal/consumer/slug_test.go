@@ -0,0 +1,173 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestGenerateSlugFromTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"simple title", "Team Feedback", "team-feedback"},
+		{"punctuation collapsed", "What's for lunch?!", "what-s-for-lunch"},
+		{"long title truncated", strings.Repeat("abcdefghij ", 10), "abcdefghij-abcdefghij-abcdefghij-abcdefghij-abcdef"},
+		{"french accents", "Étude café", "etude-cafe"},
+		{"french ligature", "Œuvre préférée", "oeuvre-preferee"},
+		{"german umlauts and eszett", "Größe der Äpfel", "grosse-der-apfel"},
+		{"full-width latin", "Ｓｕｒｖｅｙ ２０２５", "survey-2025"},
+		{"mixed japanese and latin", "2025 アンケート Tokyo", "2025-tokyo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateSlugFromTitle(tt.title)
+			if got != tt.want {
+				t.Errorf("GenerateSlugFromTitle(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+			if len(got) > MaxSlugLength {
+				t.Errorf("slug %q exceeds %d characters", got, MaxSlugLength)
+			}
+		})
+	}
+}
+
+func TestGenerateSlugFromTitle_RandomFallback(t *testing.T) {
+	fallback := regexp.MustCompile(`^survey-[a-z0-9]{5}$`)
+
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{"japanese", "アンケート調査"},
+		{"cyrillic", "Опрос"},
+		{"emoji only", "🎉🍕🚀"},
+		{"too short", "Hi"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateSlugFromTitle(tt.title)
+			if !fallback.MatchString(got) {
+				t.Errorf("GenerateSlugFromTitle(%q) = %q, want survey-xxxxx", tt.title, got)
+			}
+			if err := models.ValidateSlug(got); err != nil {
+				t.Errorf("fallback slug %q is not valid: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestSlugWithSuffix(t *testing.T) {
+	t.Run("appends suffix to short slug", func(t *testing.T) {
+		if got := slugWithSuffix("feedback", 2); got != "feedback-2" {
+			t.Errorf("Expected 'feedback-2', got %q", got)
+		}
+	})
+
+	t.Run("leaves room for suffix on max-length slug", func(t *testing.T) {
+		base := strings.Repeat("a", MaxSlugLength)
+		for _, n := range []int{2, 10, maxSlugSuffix} {
+			got := slugWithSuffix(base, n)
+			if len(got) > MaxSlugLength {
+				t.Errorf("slugWithSuffix(%d) = %q exceeds %d characters", n, got, MaxSlugLength)
+			}
+			if err := models.ValidateSlug(got); err != nil {
+				t.Errorf("slugWithSuffix(%d) = %q is not a valid slug: %v", n, got, err)
+			}
+		}
+	})
+
+	t.Run("does not leave a double hyphen after truncation", func(t *testing.T) {
+		base := strings.Repeat("a", MaxSlugLength-3) + "-bc"
+		got := slugWithSuffix(base, 2)
+		if strings.Contains(got, "--") {
+			t.Errorf("Expected no double hyphen, got %q", got)
+		}
+	})
+}
+
+func TestResolveUniqueSlug(t *testing.T) {
+	database, queries := setupTestDB(t)
+	defer database.Close()
+
+	ctx := context.Background()
+	title := "Slug Collision " + uuid.New().String()[:8]
+	base := GenerateSlugFromTitle(title)
+
+	createWithSlug := func(t *testing.T, slug string) {
+		t.Helper()
+		survey := &models.Survey{
+			ID:    uuid.New(),
+			Slug:  slug,
+			Title: title,
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{
+					{
+						ID:       "q1",
+						Text:     "Question 1?",
+						Type:     models.QuestionTypeSingle,
+						Required: true,
+						Options: []models.Option{
+							{ID: "a", Text: "Option A"},
+						},
+					},
+				},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create survey with slug %s: %v", slug, err)
+		}
+	}
+
+	t.Run("returns base slug when free", func(t *testing.T) {
+		slug, err := ResolveUniqueSlug(ctx, queries, title)
+		if err != nil {
+			t.Fatalf("ResolveUniqueSlug failed: %v", err)
+		}
+		if slug != base {
+			t.Errorf("Expected %q, got %q", base, slug)
+		}
+	})
+
+	t.Run("appends numeric suffix on collision", func(t *testing.T) {
+		createWithSlug(t, base)
+		createWithSlug(t, base+"-2")
+
+		slug, err := ResolveUniqueSlug(ctx, queries, title)
+		if err != nil {
+			t.Fatalf("ResolveUniqueSlug failed: %v", err)
+		}
+		if slug != base+"-3" {
+			t.Errorf("Expected %q, got %q", base+"-3", slug)
+		}
+	})
+
+	t.Run("CreateSurvey reports a taken slug", func(t *testing.T) {
+		survey := &models.Survey{
+			ID:         uuid.New(),
+			Slug:       base,
+			Title:      title,
+			Definition: models.SurveyDefinition{Questions: []models.Question{{ID: "q1", Text: "Q?", Type: models.QuestionTypeText}}},
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		err := queries.CreateSurvey(ctx, survey)
+		if !errors.Is(err, db.ErrSlugTaken) {
+			t.Errorf("Expected ErrSlugTaken, got %v", err)
+		}
+	})
+}
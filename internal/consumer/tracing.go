@@ -0,0 +1,54 @@
+package consumer
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/openmeet-team/survey/internal/consumer"
+
+// tracer returns the consumer tracer from the global provider. It is looked up
+// on each call so a provider installed after package init (e.g. in tests) is used.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startEventSpan starts the top-level span for a commit event
+func startEventSpan(ctx context.Context, msg *JetstreamMessage) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "consumer.handle_event",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("atproto.collection", msg.Commit.Collection),
+			attribute.String("atproto.operation", msg.Commit.Operation),
+			attribute.String("atproto.did", msg.Commit.Repo),
+			attribute.String("atproto.rkey", msg.Commit.RKey),
+			attribute.Int64("jetstream.time_us", msg.TimeUs),
+		),
+	)
+}
+
+// startSpan starts a child span for a step of event handling
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name)
+}
+
+// endSpan records err on the span (if any) and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceDB runs a database write inside a child span
+func traceDB(ctx context.Context, name string, fn func(context.Context) error) error {
+	ctx, span := startSpan(ctx, name)
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
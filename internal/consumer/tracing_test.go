@@ -0,0 +1,73 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEventSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	processor := NewProcessor(nil) // no DB access needed for these events
+	ctx := context.Background()
+
+	msg := &JetstreamMessage{
+		Kind: "commit",
+		Did:  "did:plc:tracing",
+		Commit: &JetstreamCommit{
+			Operation:  "create",
+			Collection: "net.openmeet.survey",
+			RKey:       "trace1",
+		},
+		TimeUs: 1234567930,
+	}
+
+	err := processor.ProcessMessage(ctx, msg)
+	if err == nil {
+		t.Fatal("Expected error for create without record")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "consumer.handle_event" {
+		t.Errorf("Expected span name 'consumer.handle_event', got %q", span.Name())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", span.Status().Code)
+	}
+	if len(span.Events()) == 0 || span.Events()[0].Name != "exception" {
+		t.Error("Expected error to be recorded on span")
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+
+	expected := map[string]string{
+		"atproto.collection": "net.openmeet.survey",
+		"atproto.operation":  "create",
+		"atproto.did":        "did:plc:tracing",
+		"atproto.rkey":       "trace1",
+		"jetstream.time_us":  "1234567930",
+	}
+	for key, want := range expected {
+		if got := attrs[key]; got != want {
+			t.Errorf("Expected attribute %s=%q, got %q", key, want, got)
+		}
+	}
+}
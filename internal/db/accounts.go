@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Account actions recorded in the account_actions audit table
+const (
+	AccountActionHide   = "hide"
+	AccountActionUnhide = "unhide"
+	AccountActionPurge  = "purge"
+)
+
+// AccountActionResult reports how many records an account action touched
+type AccountActionResult struct {
+	SurveysAffected   int64
+	ResponsesAffected int64
+	CommentsAffected  int64
+}
+
+// Total returns the number of surveys, responses and comments affected
+func (r *AccountActionResult) Total() int64 {
+	return r.SurveysAffected + r.ResponsesAffected + r.CommentsAffected
+}
+
+// DeleteAllForDID permanently removes all surveys authored by, and responses
+// and comments left by, a DID. Responses and comments from other users on the
+// DID's surveys are removed by the ON DELETE CASCADE on survey_id.
+func (q *Queries) DeleteAllForDID(ctx context.Context, did string) (*AccountActionResult, error) {
+	result := &AccountActionResult{}
+
+	// Responses on other authors' surveys come off those surveys' counts
+	responsesQuery := `
+		WITH changed AS (
+			DELETE FROM responses WHERE voter_did = $1
+			RETURNING survey_id, hidden_at
+		), ` + responseCountCTE(-1, "hidden_at IS NULL") + `
+		SELECT COUNT(*) FROM changed
+	`
+	if err := q.db.QueryRowContext(ctx, responsesQuery, did).Scan(&result.ResponsesAffected); err != nil {
+		return nil, fmt.Errorf("failed to delete responses for DID: %w", err)
+	}
+
+	res, err := q.db.ExecContext(ctx, `DELETE FROM survey_comments WHERE author_did = $1`, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete comments for DID: %w", err)
+	}
+	if result.CommentsAffected, err = res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	res, err = q.db.ExecContext(ctx, `DELETE FROM surveys WHERE author_did = $1`, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete surveys for DID: %w", err)
+	}
+	if result.SurveysAffected, err = res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return result, nil
+}
+
+// SetHiddenForDID hides (hidden=true) or restores (hidden=false) all surveys
+// authored by, and responses and comments left by, a DID. Hidden records are excluded from
+// listings, survey pages, and results but are kept so reactivation can restore them.
+func (q *Queries) SetHiddenForDID(ctx context.Context, did string, hidden bool) (*AccountActionResult, error) {
+	result := &AccountActionResult{}
+
+	// Only touch rows whose state actually changes so the counts are meaningful.
+	// Hidden responses don't count toward their survey's response_count.
+	responsesQuery := `
+		WITH changed AS (
+			UPDATE responses SET hidden_at = NOW() WHERE voter_did = $1 AND hidden_at IS NULL
+			RETURNING survey_id
+		), ` + responseCountCTE(-1, "TRUE") + `
+		SELECT COUNT(*) FROM changed
+	`
+	commentsQuery := `UPDATE survey_comments SET hidden_at = NOW() WHERE author_did = $1 AND hidden_at IS NULL`
+	surveysQuery := `UPDATE surveys SET hidden_at = NOW() WHERE author_did = $1 AND hidden_at IS NULL`
+	if !hidden {
+		responsesQuery = `
+			WITH changed AS (
+				UPDATE responses SET hidden_at = NULL WHERE voter_did = $1 AND hidden_at IS NOT NULL
+				RETURNING survey_id
+			), ` + responseCountCTE(1, "TRUE") + `
+			SELECT COUNT(*) FROM changed
+		`
+		commentsQuery = `UPDATE survey_comments SET hidden_at = NULL WHERE author_did = $1 AND hidden_at IS NOT NULL`
+		surveysQuery = `UPDATE surveys SET hidden_at = NULL WHERE author_did = $1 AND hidden_at IS NOT NULL`
+	}
+
+	if err := q.db.QueryRowContext(ctx, responsesQuery, did).Scan(&result.ResponsesAffected); err != nil {
+		return nil, fmt.Errorf("failed to update responses for DID: %w", err)
+	}
+
+	res, err := q.db.ExecContext(ctx, commentsQuery, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comments for DID: %w", err)
+	}
+	if result.CommentsAffected, err = res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	res, err = q.db.ExecContext(ctx, surveysQuery, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update surveys for DID: %w", err)
+	}
+	if result.SurveysAffected, err = res.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return result, nil
+}
+
+// LogAccountAction records an account action in the audit table
+func (q *Queries) LogAccountAction(ctx context.Context, did, action, status string, result *AccountActionResult) error {
+	query := `
+		INSERT INTO account_actions (did, action, status, surveys_affected, responses_affected, comments_affected)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := q.db.ExecContext(ctx, query, did, action, status, result.SurveysAffected, result.ResponsesAffected, result.CommentsAffected)
+	if err != nil {
+		return fmt.Errorf("failed to insert account action: %w", err)
+	}
+
+	return nil
+}
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/openmeet-team/survey/internal/generator"
@@ -15,10 +17,16 @@ func (q *Queries) LogGeneration(ctx context.Context, log *generator.AIGeneration
 	query := `
 		INSERT INTO ai_generation_logs (
 			id, user_id, user_type, input_prompt, system_prompt, raw_response,
-			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms, provider, model, fallback_from, attempts, output_validation, output_issues, kind,
+			temperature, prompt_version, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 	`
 
+	var temperature sql.NullFloat64
+	if log.Temperature != nil {
+		temperature = sql.NullFloat64{Float64: *log.Temperature, Valid: true}
+	}
+
 	_, err := q.db.ExecContext(
 		ctx,
 		query,
@@ -34,6 +42,15 @@ func (q *Queries) LogGeneration(ctx context.Context, log *generator.AIGeneration
 		log.OutputTokens,
 		log.CostUSD,
 		log.DurationMS,
+		log.Provider,
+		log.Model,
+		log.FallbackFrom,
+		max(log.Attempts, 1),
+		log.OutputValidation,
+		log.OutputIssues,
+		cmp.Or(log.Kind, generator.KindGenerate),
+		temperature,
+		sql.NullString{String: log.PromptVersion, Valid: log.PromptVersion != ""},
 		log.CreatedAt,
 	)
 
@@ -47,29 +64,12 @@ func (q *Queries) LogGeneration(ctx context.Context, log *generator.AIGeneration
 // GetGenerationLog retrieves a single AI generation log by ID
 func (q *Queries) GetGenerationLog(ctx context.Context, id uuid.UUID) (*generator.AIGenerationLog, error) {
 	query := `
-		SELECT id, user_id, user_type, input_prompt, system_prompt, raw_response,
-			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms, created_at
+		SELECT ` + generationLogSelect + `
 		FROM ai_generation_logs
 		WHERE id = $1
 	`
 
-	log := &generator.AIGenerationLog{}
-	err := q.db.QueryRowContext(ctx, query, id).Scan(
-		&log.ID,
-		&log.UserID,
-		&log.UserType,
-		&log.InputPrompt,
-		&log.SystemPrompt,
-		&log.RawResponse,
-		&log.Status,
-		&log.ErrorMessage,
-		&log.InputTokens,
-		&log.OutputTokens,
-		&log.CostUSD,
-		&log.DurationMS,
-		&log.CreatedAt,
-	)
-
+	log, err := scanGenerationLog(q.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, err
@@ -83,8 +83,7 @@ func (q *Queries) GetGenerationLog(ctx context.Context, id uuid.UUID) (*generato
 // GetGenerationLogsByUser retrieves AI generation logs for a specific user
 func (q *Queries) GetGenerationLogsByUser(ctx context.Context, userID string, limit, offset int) ([]*generator.AIGenerationLog, error) {
 	query := `
-		SELECT id, user_id, user_type, input_prompt, system_prompt, raw_response,
-			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms, created_at
+		SELECT ` + generationLogSelect + `
 		FROM ai_generation_logs
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -97,42 +96,13 @@ func (q *Queries) GetGenerationLogsByUser(ctx context.Context, userID string, li
 	}
 	defer rows.Close()
 
-	var logs []*generator.AIGenerationLog
-	for rows.Next() {
-		log := &generator.AIGenerationLog{}
-		err := rows.Scan(
-			&log.ID,
-			&log.UserID,
-			&log.UserType,
-			&log.InputPrompt,
-			&log.SystemPrompt,
-			&log.RawResponse,
-			&log.Status,
-			&log.ErrorMessage,
-			&log.InputTokens,
-			&log.OutputTokens,
-			&log.CostUSD,
-			&log.DurationMS,
-			&log.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan AI generation log: %w", err)
-		}
-		logs = append(logs, log)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating AI generation logs: %w", err)
-	}
-
-	return logs, nil
+	return scanGenerationLogs(rows)
 }
 
 // GetGenerationLogsByStatus retrieves AI generation logs by status
 func (q *Queries) GetGenerationLogsByStatus(ctx context.Context, status string, limit, offset int) ([]*generator.AIGenerationLog, error) {
 	query := `
-		SELECT id, user_id, user_type, input_prompt, system_prompt, raw_response,
-			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms, created_at
+		SELECT ` + generationLogSelect + `
 		FROM ai_generation_logs
 		WHERE status = $1
 		ORDER BY created_at DESC
@@ -145,42 +115,34 @@ func (q *Queries) GetGenerationLogsByStatus(ctx context.Context, status string,
 	}
 	defer rows.Close()
 
-	var logs []*generator.AIGenerationLog
-	for rows.Next() {
-		log := &generator.AIGenerationLog{}
-		err := rows.Scan(
-			&log.ID,
-			&log.UserID,
-			&log.UserType,
-			&log.InputPrompt,
-			&log.SystemPrompt,
-			&log.RawResponse,
-			&log.Status,
-			&log.ErrorMessage,
-			&log.InputTokens,
-			&log.OutputTokens,
-			&log.CostUSD,
-			&log.DurationMS,
-			&log.CreatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan AI generation log: %w", err)
-		}
-		logs = append(logs, log)
-	}
+	return scanGenerationLogs(rows)
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating AI generation logs: %w", err)
+// GetGenerationLogsByModel retrieves AI generation logs served by a model,
+// for comparing cost and output before and after a model switch
+func (q *Queries) GetGenerationLogsByModel(ctx context.Context, model string, limit, offset int) ([]*generator.AIGenerationLog, error) {
+	// Served by idx_ai_generation_logs_model_created
+	query := `
+		SELECT ` + generationLogSelect + `
+		FROM ai_generation_logs
+		WHERE model = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, model, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AI generation logs by model: %w", err)
 	}
+	defer rows.Close()
 
-	return logs, nil
+	return scanGenerationLogs(rows)
 }
 
 // GetRecentGenerationLogs retrieves recent AI generation logs
 func (q *Queries) GetRecentGenerationLogs(ctx context.Context, limit, offset int) ([]*generator.AIGenerationLog, error) {
 	query := `
-		SELECT id, user_id, user_type, input_prompt, system_prompt, raw_response,
-			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms, created_at
+		SELECT ` + generationLogSelect + `
 		FROM ai_generation_logs
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -192,33 +154,228 @@ func (q *Queries) GetRecentGenerationLogs(ctx context.Context, limit, offset int
 	}
 	defer rows.Close()
 
+	return scanGenerationLogs(rows)
+}
+
+// GetGenerationLogsByUserAfter is GetGenerationLogsByUser with keyset
+// pagination: it returns up to limit logs after cursor (see DecodeCursor) and
+// the cursor for the next page, or "" on the last page
+func (q *Queries) GetGenerationLogsByUserAfter(ctx context.Context, userID, cursor string, limit int) ([]*generator.AIGenerationLog, string, error) {
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	afterCreatedAt, afterID := cursorArgs(after)
+
+	query := `
+		SELECT ` + generationLogSelect + `
+		FROM ai_generation_logs
+		WHERE user_id = $1
+		  AND ($3::timestamptz IS NULL OR (created_at, id) < ($3::timestamptz, $4::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	// One extra row tells whether there's a next page
+	rows, err := q.db.QueryContext(ctx, query, userID, limit+1, afterCreatedAt, afterID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query AI generation logs by user: %w", err)
+	}
+	defer rows.Close()
+
+	return pageGenerationLogs(rows, limit)
+}
+
+// GetRecentGenerationLogsAfter is GetRecentGenerationLogs with keyset
+// pagination: it returns up to limit logs after cursor (see DecodeCursor) and
+// the cursor for the next page, or "" on the last page
+func (q *Queries) GetRecentGenerationLogsAfter(ctx context.Context, cursor string, limit int) ([]*generator.AIGenerationLog, string, error) {
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	afterCreatedAt, afterID := cursorArgs(after)
+
+	// Served by idx_ai_generation_logs_created_at_id
+	query := `
+		SELECT ` + generationLogSelect + `
+		FROM ai_generation_logs
+		WHERE ($2::timestamptz IS NULL OR (created_at, id) < ($2::timestamptz, $3::uuid))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, limit+1, afterCreatedAt, afterID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query recent AI generation logs: %w", err)
+	}
+	defer rows.Close()
+
+	return pageGenerationLogs(rows, limit)
+}
+
+// pageGenerationLogs reads a page fetched with limit+1 rows and returns it
+// with the next page's cursor
+func pageGenerationLogs(rows *sql.Rows, limit int) ([]*generator.AIGenerationLog, string, error) {
+	logs, err := scanGenerationLogs(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	keep, next := nextCursor(len(logs), limit, func(i int) (time.Time, uuid.UUID) {
+		return logs[i].CreatedAt, logs[i].ID
+	})
+	return logs[:keep], next, nil
+}
+
+// generationLogSelect lists the columns scanGenerationLog reads. Columns
+// added after logs were first written are NULL in old rows and read as
+// empty strings.
+const generationLogSelect = `id, user_id, user_type, input_prompt, system_prompt, raw_response,
+			status, error_message, input_tokens, output_tokens, cost_usd, duration_ms,
+			COALESCE(provider, ''), COALESCE(model, ''), fallback_from, attempts, output_validation, output_issues, kind,
+			temperature, COALESCE(prompt_version, ''), created_at`
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGenerationLog reads one row of generationLogSelect
+func scanGenerationLog(row rowScanner) (*generator.AIGenerationLog, error) {
+	log := &generator.AIGenerationLog{}
+	var temperature sql.NullFloat64
+	err := row.Scan(
+		&log.ID,
+		&log.UserID,
+		&log.UserType,
+		&log.InputPrompt,
+		&log.SystemPrompt,
+		&log.RawResponse,
+		&log.Status,
+		&log.ErrorMessage,
+		&log.InputTokens,
+		&log.OutputTokens,
+		&log.CostUSD,
+		&log.DurationMS,
+		&log.Provider,
+		&log.Model,
+		&log.FallbackFrom,
+		&log.Attempts,
+		&log.OutputValidation,
+		&log.OutputIssues,
+		&log.Kind,
+		&temperature,
+		&log.PromptVersion,
+		&log.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if temperature.Valid {
+		log.Temperature = &temperature.Float64
+	}
+	return log, nil
+}
+
+// scanGenerationLogs reads every row of an AI generation log listing query
+func scanGenerationLogs(rows *sql.Rows) ([]*generator.AIGenerationLog, error) {
 	var logs []*generator.AIGenerationLog
 	for rows.Next() {
-		log := &generator.AIGenerationLog{}
-		err := rows.Scan(
-			&log.ID,
-			&log.UserID,
-			&log.UserType,
-			&log.InputPrompt,
-			&log.SystemPrompt,
-			&log.RawResponse,
-			&log.Status,
-			&log.ErrorMessage,
-			&log.InputTokens,
-			&log.OutputTokens,
-			&log.CostUSD,
-			&log.DurationMS,
-			&log.CreatedAt,
-		)
+		log, err := scanGenerationLog(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan AI generation log: %w", err)
 		}
 		logs = append(logs, log)
 	}
 
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating AI generation logs: %w", err)
 	}
 
 	return logs, nil
 }
+
+// GetUserCostSince sums the cost of a user's generations created at or after
+// since, for enforcing spending limits. Every status is counted, since a
+// generation that failed validation or timed out may still have been billed;
+// calls counts the successful generations and any other that used tokens.
+// A user with no generations has zero cost and calls.
+func (q *Queries) GetUserCostSince(ctx context.Context, userID string, since time.Time) (costUSD float64, calls int, err error) {
+	// Served by idx_ai_generation_logs_user_created
+	query := `
+		SELECT COALESCE(SUM(COALESCE(cost_usd, 0)), 0),
+			COUNT(*) FILTER (WHERE ` + billedGeneration + `)
+		FROM ai_generation_logs
+		WHERE user_id = $1 AND created_at >= $2
+	`
+
+	err = q.db.QueryRowContext(ctx, query, userID, since).Scan(&costUSD, &calls)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get AI generation cost for user: %w", err)
+	}
+
+	return costUSD, calls, nil
+}
+
+// GetTotalCostSince sums the cost of all generations created at or after
+// since, counting them as GetUserCostSince does
+func (q *Queries) GetTotalCostSince(ctx context.Context, since time.Time) (costUSD float64, calls int, err error) {
+	query := `
+		SELECT COALESCE(SUM(COALESCE(cost_usd, 0)), 0),
+			COUNT(*) FILTER (WHERE ` + billedGeneration + `)
+		FROM ai_generation_logs
+		WHERE created_at >= $1
+	`
+
+	err = q.db.QueryRowContext(ctx, query, since).Scan(&costUSD, &calls)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get total AI generation cost: %w", err)
+	}
+
+	return costUSD, calls, nil
+}
+
+// billedGeneration matches the generation logs that reached a model
+const billedGeneration = `status = 'success' OR input_tokens > 0 OR output_tokens > 0 OR cost_usd > 0`
+
+// PruneGenerationLogs clears the prompt and raw model response of logs
+// created before olderThan, keeping the row so cost and token totals survive.
+// With keepFailures, only successful generations are pruned. Returns the
+// number of rows pruned; rows pruned by an earlier run aren't counted.
+// The columns are emptied rather than set to NULL because readers scan them
+// into strings. A prune that clears anything is recorded in the audit log in
+// the same transaction.
+func (q *Queries) PruneGenerationLogs(ctx context.Context, olderThan time.Time, keepFailures bool) (int64, error) {
+	query := `
+		UPDATE ai_generation_logs
+		SET input_prompt = '', raw_response = ''
+		WHERE created_at < $1
+		  AND (input_prompt <> '' OR COALESCE(raw_response, '') <> '')
+		  AND (NOT $2 OR status = 'success')
+	`
+
+	var rows int64
+	err := q.WithTx(ctx, func(q *Queries) error {
+		result, err := q.db.ExecContext(ctx, query, olderThan, keepFailures)
+		if err != nil {
+			return fmt.Errorf("failed to prune AI generation logs: %w", err)
+		}
+
+		rows, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rows == 0 {
+			return nil
+		}
+
+		details := AuditDetails(map[string]interface{}{"olderThan": olderThan, "keepFailures": keepFailures, "pruned": rows})
+		return q.RecordAudit(ctx, AuditActorSystem, AuditActionPruneGenerationLogs, "", details)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,273 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/generator"
+)
+
+// generationLogColumns are the columns GetGenerationLog and the listings scan
+var generationLogColumns = []string{
+	"id", "user_id", "user_type", "input_prompt", "system_prompt", "raw_response",
+	"status", "error_message", "input_tokens", "output_tokens", "cost_usd", "duration_ms", "provider", "model", "fallback_from", "attempts", "output_validation", "output_issues", "kind",
+	"temperature", "prompt_version", "created_at",
+}
+
+func TestLogGenerationFake(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("INSERT INTO ai_generation_logs").RowsAffected(1)
+	queries := NewQueries(fake)
+
+	temperature := 0.7
+	log := &generator.AIGenerationLog{
+		ID:           uuid.New(),
+		UserID:       "did:plc:test",
+		UserType:     "authenticated",
+		InputPrompt:  "Lunch poll",
+		SystemPrompt: "System",
+		Status:       "success",
+		InputTokens:  10,
+		OutputTokens: 20,
+		CostUSD:      0.001,
+		DurationMS:   1500,
+		Provider:     "anthropic",
+		Model:        "claude-haiku-4-5",
+		FallbackFrom: "openai",
+		Attempts:     3,
+		CreatedAt:    time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+
+		OutputValidation: "repaired",
+		OutputIssues:     "question 1: duplicate question ID 'q1'",
+		Kind:             "translate",
+		Temperature:      &temperature,
+		PromptVersion:    "v1",
+	}
+	if err := queries.LogGeneration(context.Background(), log); err != nil {
+		t.Fatalf("LogGeneration failed: %v", err)
+	}
+
+	calls := fake.CallsMatching("INSERT INTO ai_generation_logs")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 insert, got %d", len(calls))
+	}
+	args := calls[0].Args
+	if len(args) != 22 {
+		t.Fatalf("Expected 22 args, got %d", len(args))
+	}
+	if args[0] != log.ID.String() || args[1] != "did:plc:test" || args[6] != "success" {
+		t.Errorf("Unexpected args %v", args)
+	}
+	if args[12] != "anthropic" || args[13] != "claude-haiku-4-5" {
+		t.Errorf("Expected the provider and model to be recorded, got %v", args[12:14])
+	}
+	if args[14] != "openai" {
+		t.Errorf("Expected fallback_from=openai, got %v", args[14])
+	}
+	if fmt.Sprint(args[15]) != "3" {
+		t.Errorf("Expected attempts=3, got %v", args[15])
+	}
+	if args[16] != "repaired" || args[17] != log.OutputIssues {
+		t.Errorf("Expected the output validation to be recorded, got %v", args[16:18])
+	}
+	if args[18] != "translate" {
+		t.Errorf("Expected kind=translate, got %v", args[18])
+	}
+	if args[19] != 0.7 || args[20] != "v1" {
+		t.Errorf("Expected temperature 0.7 and prompt version v1, got %v", args[19:21])
+	}
+
+	// Logs without an attempt count took one request, and logs without a
+	// kind generated a survey. Unknown settings are NULL.
+	log.Attempts = 0
+	log.Kind = ""
+	log.Temperature = nil
+	log.PromptVersion = ""
+	if err := queries.LogGeneration(context.Background(), log); err != nil {
+		t.Fatalf("LogGeneration failed: %v", err)
+	}
+	args = fake.CallsMatching("INSERT INTO ai_generation_logs")[1].Args
+	if fmt.Sprint(args[15]) != "1" {
+		t.Errorf("Expected attempts=1, got %v", args[15])
+	}
+	if args[18] != "generate" {
+		t.Errorf("Expected kind=generate, got %v", args[18])
+	}
+	if args[19] != nil || args[20] != nil {
+		t.Errorf("Expected NULL temperature and prompt version, got %v", args[19:21])
+	}
+}
+
+func TestLogGenerationFakeError(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("INSERT INTO ai_generation_logs").Err(errors.New("connection reset"))
+	queries := NewQueries(fake)
+
+	err := queries.LogGeneration(context.Background(), &generator.AIGenerationLog{ID: uuid.New()})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestGetGenerationLogFake(t *testing.T) {
+	id := uuid.New()
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("scans the row", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM ai_generation_logs WHERE id = $1").Rows(generationLogColumns, []interface{}{
+			id, "did:plc:test", "authenticated", "Lunch poll", "System", `{"questions":[]}`,
+			"success", "", 10, 20, 0.001, 1500, "anthropic", "claude-haiku-4-5", "openai", 3, "reprompted", "question 0: text questions cannot have options", "translate",
+			0.2, "v1", createdAt,
+		})
+		queries := NewQueries(fake)
+
+		log, err := queries.GetGenerationLog(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetGenerationLog failed: %v", err)
+		}
+		if log.ID != id || log.UserID != "did:plc:test" || log.OutputTokens != 20 || log.DurationMS != 1500 {
+			t.Errorf("Unexpected log %+v", log)
+		}
+		if log.Provider != "anthropic" || log.Model != "claude-haiku-4-5" {
+			t.Errorf("Expected anthropic/claude-haiku-4-5, got %s/%s", log.Provider, log.Model)
+		}
+		if log.FallbackFrom != "openai" {
+			t.Errorf("Expected fallback_from=openai, got %q", log.FallbackFrom)
+		}
+		if log.Attempts != 3 {
+			t.Errorf("Expected attempts=3, got %d", log.Attempts)
+		}
+		if log.OutputValidation != "reprompted" || log.OutputIssues != "question 0: text questions cannot have options" {
+			t.Errorf("Expected the output validation, got %q: %q", log.OutputValidation, log.OutputIssues)
+		}
+		if log.Kind != "translate" {
+			t.Errorf("Expected kind=translate, got %q", log.Kind)
+		}
+		if log.Temperature == nil || *log.Temperature != 0.2 || log.PromptVersion != "v1" {
+			t.Errorf("Expected temperature 0.2 and prompt version v1, got %v and %q", log.Temperature, log.PromptVersion)
+		}
+		if !log.CreatedAt.Equal(createdAt) {
+			t.Errorf("Expected created_at %v, got %v", createdAt, log.CreatedAt)
+		}
+	})
+
+	t.Run("old rows without settings scan", func(t *testing.T) {
+		// The query coalesces NULL text columns; temperature stays NULL
+		fake := queriestest.New(t)
+		fake.Expect("FROM ai_generation_logs WHERE id = $1").Rows(generationLogColumns, []interface{}{
+			id, "did:plc:test", "authenticated", "Lunch poll", "System", `{"questions":[]}`,
+			"success", "", 10, 20, 0.001, 1500, "", "", "", 1, "", "", "generate",
+			nil, "", createdAt,
+		})
+		queries := NewQueries(fake)
+
+		log, err := queries.GetGenerationLog(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetGenerationLog failed: %v", err)
+		}
+		if log.Temperature != nil || log.PromptVersion != "" || log.Provider != "" || log.Model != "" {
+			t.Errorf("Expected empty settings, got %v, %q, %q, %q", log.Temperature, log.PromptVersion, log.Provider, log.Model)
+		}
+		for _, column := range []string{"COALESCE(provider, '')", "COALESCE(model, '')", "COALESCE(prompt_version, '')"} {
+			if len(fake.CallsMatching(column)) != 1 {
+				t.Errorf("Expected the query to read %s", column)
+			}
+		}
+	})
+
+	t.Run("missing log returns sql.ErrNoRows", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM ai_generation_logs WHERE id = $1").Rows(generationLogColumns)
+		queries := NewQueries(fake)
+
+		if _, err := queries.GetGenerationLog(context.Background(), id); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestGetUserCostSinceFake(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("FROM ai_generation_logs WHERE user_id = $1 AND created_at >= $2").
+		Rows([]string{"cost", "calls"}, []interface{}{0.25, 3})
+	queries := NewQueries(fake)
+
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cost, calls, err := queries.GetUserCostSince(context.Background(), "did:plc:test", since)
+	if err != nil {
+		t.Fatalf("GetUserCostSince failed: %v", err)
+	}
+	if cost != 0.25 || calls != 3 {
+		t.Errorf("Expected 0.25 over 3 calls, got %f over %d", cost, calls)
+	}
+
+	args := fake.Calls()[0].Args
+	if args[0] != "did:plc:test" || !args[1].(time.Time).Equal(since) {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	// Billed failures count towards the budget too
+	if query := fake.Calls()[0].Query; strings.Contains(query, "AND status = 'success'") {
+		t.Errorf("Expected every status to be summed, got %s", query)
+	}
+}
+
+func TestQueriesWithTxFake(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("FROM ai_generation_logs WHERE created_at >= $1").
+		Rows([]string{"cost", "calls"}, []interface{}{1.5, 2})
+	queries := NewQueries(fake)
+
+	// A *sql.Tx satisfies DBTX just as the *sql.DB does
+	var cost float64
+	err := queries.WithTx(context.Background(), func(tx *Queries) error {
+		var err error
+		cost, _, err = tx.GetTotalCostSince(context.Background(), time.Time{})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+	if cost != 1.5 {
+		t.Errorf("Expected cost 1.5, got %f", cost)
+	}
+}
+
+func TestGetGenerationLogsByModelFake(t *testing.T) {
+	fake := queriestest.New(t)
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake.Expect("FROM ai_generation_logs WHERE model = $1").Rows(generationLogColumns,
+		[]interface{}{
+			uuid.New(), "did:plc:test", "authenticated", "Lunch poll", "System", "{}",
+			"success", "", 10, 20, 0.001, 1500, "openai", "gpt-4.1-mini", "", 1, "valid", "", "generate",
+			0.7, "v1", createdAt,
+		},
+		[]interface{}{
+			uuid.New(), "192.168.1.1", "anonymous", "Team poll", "System", "",
+			"error", "timeout", 0, 0, 0.0, 30000, "openai", "gpt-4.1-mini", "", 2, "", "", "generate",
+			0.7, "v1", createdAt.Add(-time.Hour),
+		},
+	)
+	queries := NewQueries(fake)
+
+	logs, err := queries.GetGenerationLogsByModel(context.Background(), "gpt-4.1-mini", 20, 40)
+	if err != nil {
+		t.Fatalf("GetGenerationLogsByModel failed: %v", err)
+	}
+	if len(logs) != 2 || logs[0].Model != "gpt-4.1-mini" || logs[1].Status != "error" {
+		t.Errorf("Unexpected logs %+v", logs)
+	}
+
+	args := fake.Calls()[0].Args
+	if args[0] != "gpt-4.1-mini" || fmt.Sprint(args[1:]) != "[20 40]" {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
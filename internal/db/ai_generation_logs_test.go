@@ -5,6 +5,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
 	"time"
 
@@ -256,8 +259,242 @@ func TestGetRecentGenerationLogs(t *testing.T) {
 	}
 }
 
+// TestGetUserCostSince tests summing a user's generation costs
+func TestGetUserCostSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	userID := "did:plc:costtest-" + uuid.NewString()[:8]
+	now := time.Now()
+	since := now.Add(-24 * time.Hour)
+
+	logs := []struct {
+		status    string
+		cost      float64
+		createdAt time.Time
+	}{
+		{"success", 0.0025, now.Add(-time.Hour)},
+		{"success", 0.0010, now.Add(-2 * time.Hour)},
+		{"success", 0, now.Add(-3 * time.Hour)},            // free call still counts
+		{"validation_failed", 0.0040, now.Add(-time.Hour)}, // billed failures count
+		{"rate_limited", 0, now.Add(-time.Hour)},           // never reached a model
+		{"success", 0.5000, now.Add(-48 * time.Hour)},      // before since
+	}
+	for _, l := range logs {
+		log := &generator.AIGenerationLog{
+			ID:           uuid.New(),
+			UserID:       userID,
+			UserType:     "authenticated",
+			InputPrompt:  "Test",
+			SystemPrompt: "System",
+			Status:       l.status,
+			CostUSD:      l.cost,
+			CreatedAt:    l.createdAt,
+		}
+		if err := queries.LogGeneration(ctx, log); err != nil {
+			t.Fatalf("Failed to insert log: %v", err)
+		}
+	}
+
+	cost, calls, err := queries.GetUserCostSince(ctx, userID, since)
+	if err != nil {
+		t.Fatalf("Failed to get user cost: %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+	if math.Abs(cost-0.0075) > 1e-9 {
+		t.Errorf("Expected cost=0.0075, got %f", cost)
+	}
+
+	// A user with no generations has spent nothing
+	cost, calls, err = queries.GetUserCostSince(ctx, userID+"-none", since)
+	if err != nil {
+		t.Fatalf("Failed to get cost for unknown user: %v", err)
+	}
+	if cost != 0 || calls != 0 {
+		t.Errorf("Expected zero cost and calls, got %f and %d", cost, calls)
+	}
+}
+
+// TestGetTotalCostSince tests summing generation costs across users
+func TestGetTotalCostSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+	since := time.Now().Add(-time.Hour)
+
+	// Other tests leave rows behind, so compare against a baseline
+	baseCost, baseCalls, err := queries.GetTotalCostSince(ctx, since)
+	if err != nil {
+		t.Fatalf("Failed to get baseline cost: %v", err)
+	}
+
+	for i, status := range []string{"success", "timeout", "rate_limited"} {
+		log := &generator.AIGenerationLog{
+			ID:           uuid.New(),
+			UserID:       fmt.Sprintf("did:plc:totaltest%d", i),
+			UserType:     "authenticated",
+			InputPrompt:  "Test",
+			SystemPrompt: "System",
+			Status:       status,
+			CostUSD:      0.002,
+			CreatedAt:    time.Now(),
+		}
+		if err := queries.LogGeneration(ctx, log); err != nil {
+			t.Fatalf("Failed to insert log: %v", err)
+		}
+	}
+
+	cost, calls, err := queries.GetTotalCostSince(ctx, since)
+	if err != nil {
+		t.Fatalf("Failed to get total cost: %v", err)
+	}
+	if calls-baseCalls != 3 {
+		t.Errorf("Expected 3 new calls, got %d", calls-baseCalls)
+	}
+	if math.Abs((cost-baseCost)-0.006) > 1e-9 {
+		t.Errorf("Expected cost to rise by 0.006, got %f", cost-baseCost)
+	}
+}
+
+// TestBudgetLimiter tests the daily budget cutoff against logged spend
+func TestBudgetLimiter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	// Other tests leave rows behind, so the global budget is out of the way
+	limiter := generator.NewBudgetLimiter(queries, generator.BudgetConfig{UserDaily: 0.05, AnonDaily: 0.01, GlobalDaily: math.MaxFloat64})
+	userID := "did:plc:budgettest-" + uuid.NewString()[:8]
+
+	logCost := func(status string, cost float64) {
+		log := &generator.AIGenerationLog{
+			ID:           uuid.New(),
+			UserID:       userID,
+			UserType:     "authenticated",
+			InputPrompt:  "Test",
+			SystemPrompt: "System",
+			Status:       status,
+			CostUSD:      cost,
+			CreatedAt:    time.Now(),
+		}
+		if err := queries.LogGeneration(ctx, log); err != nil {
+			t.Fatalf("Failed to insert log: %v", err)
+		}
+	}
+
+	// Just under budget, with a failed call that doesn't count
+	logCost("success", 0.0499)
+	logCost("error", 0.01)
+	if err := limiter.Check(ctx, userID, "authenticated"); err != nil {
+		t.Fatalf("Expected a request under budget to be allowed, got %v", err)
+	}
+
+	// Reaching the budget cuts the user off
+	logCost("success", 0.0001)
+	var exceeded *generator.BudgetExceededError
+	if err := limiter.Check(ctx, userID, "authenticated"); !errors.As(err, &exceeded) {
+		t.Fatalf("Expected BudgetExceededError, got %v", err)
+	}
+	if exceeded.Scope != generator.BudgetScopeUser {
+		t.Errorf("Expected scope %q, got %q", generator.BudgetScopeUser, exceeded.Scope)
+	}
+}
+
+// TestPruneGenerationLogs tests clearing content from old logs
+func TestPruneGenerationLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	userID := "did:plc:prunetest-" + uuid.NewString()[:8]
+	now := time.Now()
+	cutoff := now.Add(-90 * 24 * time.Hour)
+
+	insert := func(status string, createdAt time.Time) uuid.UUID {
+		log := &generator.AIGenerationLog{
+			ID:           uuid.New(),
+			UserID:       userID,
+			UserType:     "authenticated",
+			InputPrompt:  "Survey about my private life",
+			SystemPrompt: "System",
+			RawResponse:  "{}",
+			Status:       status,
+			CostUSD:      0.001,
+			CreatedAt:    createdAt,
+		}
+		if err := queries.LogGeneration(ctx, log); err != nil {
+			t.Fatalf("Failed to insert log: %v", err)
+		}
+		return log.ID
+	}
+
+	oldSuccess := insert("success", cutoff.Add(-time.Hour))
+	oldFailure := insert("error", cutoff.Add(-time.Hour))
+	recent := insert("success", now.Add(-time.Hour))
+
+	assertPruned := func(id uuid.UUID, want bool) {
+		t.Helper()
+		log, err := queries.GetGenerationLog(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to retrieve log: %v", err)
+		}
+		if pruned := log.InputPrompt == "" && log.RawResponse == ""; pruned != want {
+			t.Errorf("Expected pruned=%v for %s log, got prompt=%q response=%q", want, log.Status, log.InputPrompt, log.RawResponse)
+		}
+		if log.CostUSD == 0 {
+			t.Errorf("Expected cost to survive pruning")
+		}
+	}
+
+	// Failures are kept when asked
+	pruned, err := queries.PruneGenerationLogs(ctx, cutoff, true)
+	if err != nil {
+		t.Fatalf("Failed to prune logs: %v", err)
+	}
+	if pruned < 1 {
+		t.Errorf("Expected at least 1 pruned log, got %d", pruned)
+	}
+	assertPruned(oldSuccess, true)
+	assertPruned(oldFailure, false)
+	assertPruned(recent, false)
+
+	// Then pruned at their own cutoff
+	if _, err := queries.PruneGenerationLogs(ctx, cutoff, false); err != nil {
+		t.Fatalf("Failed to prune logs: %v", err)
+	}
+	assertPruned(oldFailure, true)
+	assertPruned(recent, false)
+}
+
 // setupTestDB sets up a test database connection
-func setupTestDB(t *testing.T) *sql.DB {
+func setupTestDB(t testing.TB) *sql.DB {
 	t.Helper()
 
 	// Get DB config from environment
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// generationUserTypes are the user types every GenerationStatsBucket reports,
+// matching the ai_generation_logs.user_type check constraint
+var generationUserTypes = []string{"anonymous", "authenticated"}
+
+// GenerationStats summarizes AI generation calls
+type GenerationStats struct {
+	Calls         int64   `json:"calls"`
+	Successes     int64   `json:"successes"`
+	SuccessRate   float64 `json:"successRate"` // Successes / Calls, 0 without calls
+	InputTokens   int64   `json:"inputTokens"`
+	OutputTokens  int64   `json:"outputTokens"`
+	CostUSD       float64 `json:"costUsd"`
+	P50DurationMS float64 `json:"p50DurationMs"`
+	P95DurationMS float64 `json:"p95DurationMs"`
+}
+
+// GenerationStatsBucket is one UTC day of AI generation usage, in total and
+// per user type
+type GenerationStatsBucket struct {
+	Day time.Time `json:"day"`
+	GenerationStats
+	ByUserType map[string]GenerationStats `json:"byUserType"`
+}
+
+// newGenerationStatsBucket returns an empty bucket for day with every user type present
+func newGenerationStatsBucket(day time.Time) *GenerationStatsBucket {
+	bucket := &GenerationStatsBucket{Day: day, ByUserType: make(map[string]GenerationStats, len(generationUserTypes))}
+	for _, userType := range generationUserTypes {
+		bucket.ByUserType[userType] = GenerationStats{}
+	}
+	return bucket
+}
+
+// GetGenerationStats summarizes AI generation logs created in [from, to),
+// one bucket per UTC day in order. Days without any calls are included with
+// zeros so charts have no gaps. Durations are percentiles over every logged
+// call, including failures.
+func (q *Queries) GetGenerationStats(ctx context.Context, from, to time.Time) ([]GenerationStatsBucket, error) {
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	// GROUPING SETS yields a row per day and user type plus a total per day
+	// (user_type NULL); days without logs get a single all-NULL row
+	query := `
+		WITH days AS (
+			SELECT generate_series(
+				date_trunc('day', $1::timestamptz AT TIME ZONE 'UTC'),
+				date_trunc('day', ($2::timestamptz - interval '1 microsecond') AT TIME ZONE 'UTC'),
+				interval '1 day'
+			) AS day
+		), stats AS (
+			SELECT day,
+			       user_type,
+			       COUNT(*) AS calls,
+			       COUNT(*) FILTER (WHERE status = 'success') AS successes,
+			       SUM(input_tokens) AS input_tokens,
+			       SUM(output_tokens) AS output_tokens,
+			       SUM(cost_usd)::float8 AS cost_usd,
+			       percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms) AS p50,
+			       percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms) AS p95
+			FROM (
+				SELECT date_trunc('day', created_at AT TIME ZONE 'UTC') AS day, user_type, status,
+				       input_tokens, output_tokens, cost_usd, duration_ms
+				FROM ai_generation_logs
+				WHERE created_at >= $1 AND created_at < $2
+			) l
+			GROUP BY GROUPING SETS ((day, user_type), (day))
+		)
+		SELECT d.day, s.user_type,
+		       COALESCE(s.calls, 0), COALESCE(s.successes, 0),
+		       COALESCE(s.input_tokens, 0), COALESCE(s.output_tokens, 0), COALESCE(s.cost_usd, 0),
+		       COALESCE(s.p50, 0), COALESCE(s.p95, 0)
+		FROM days d
+		LEFT JOIN stats s ON s.day = d.day
+		ORDER BY d.day, s.user_type NULLS FIRST
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AI generation stats: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []GenerationStatsBucket
+	var current *GenerationStatsBucket
+	for rows.Next() {
+		var (
+			day      time.Time
+			userType sql.NullString
+			stats    GenerationStats
+		)
+		err := rows.Scan(
+			&day,
+			&userType,
+			&stats.Calls,
+			&stats.Successes,
+			&stats.InputTokens,
+			&stats.OutputTokens,
+			&stats.CostUSD,
+			&stats.P50DurationMS,
+			&stats.P95DurationMS,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan AI generation stats: %w", err)
+		}
+		if stats.Calls > 0 {
+			stats.SuccessRate = float64(stats.Successes) / float64(stats.Calls)
+		}
+
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if current == nil || !current.Day.Equal(day) {
+			if current != nil {
+				buckets = append(buckets, *current)
+			}
+			current = newGenerationStatsBucket(day)
+		}
+		if userType.Valid {
+			current.ByUserType[userType.String] = stats
+		} else {
+			current.GenerationStats = stats
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating AI generation stats: %w", err)
+	}
+	if current != nil {
+		buckets = append(buckets, *current)
+	}
+
+	return buckets, nil
+}
@@ -0,0 +1,115 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/generator"
+)
+
+// TestGetGenerationStats tests daily usage buckets, including empty days
+func TestGetGenerationStats(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	// A window long past so logs from other tests can't land in it
+	from := time.Date(2001, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 3)
+	if _, err := db.Exec(`DELETE FROM ai_generation_logs WHERE created_at >= $1 AND created_at < $2`, from, to); err != nil {
+		t.Fatalf("Failed to clear window: %v", err)
+	}
+
+	logs := []struct {
+		userType  string
+		status    string
+		tokens    int
+		cost      float64
+		duration  int
+		createdAt time.Time
+	}{
+		// Day 1: three calls, one failed
+		{"authenticated", "success", 100, 0.002, 1000, from.Add(time.Hour)},
+		{"authenticated", "success", 200, 0.004, 3000, from.Add(2 * time.Hour)},
+		{"anonymous", "error", 50, 0, 200, from.Add(23 * time.Hour)},
+		// Day 2: nothing
+		// Day 3: one anonymous call
+		{"anonymous", "success", 10, 0.001, 500, from.AddDate(0, 0, 2)},
+		// Outside the window
+		{"anonymous", "success", 10, 1, 500, to},
+	}
+	for _, l := range logs {
+		log := &generator.AIGenerationLog{
+			ID:           uuid.New(),
+			UserID:       "stats-test-" + l.userType,
+			UserType:     l.userType,
+			InputPrompt:  "Test",
+			SystemPrompt: "System",
+			Status:       l.status,
+			InputTokens:  l.tokens,
+			OutputTokens: l.tokens * 2,
+			CostUSD:      l.cost,
+			DurationMS:   l.duration,
+			CreatedAt:    l.createdAt,
+		}
+		if err := queries.LogGeneration(ctx, log); err != nil {
+			t.Fatalf("Failed to insert log: %v", err)
+		}
+	}
+	defer db.Exec(`DELETE FROM ai_generation_logs WHERE created_at >= $1 AND created_at <= $2`, from, to)
+
+	buckets, err := queries.GetGenerationStats(ctx, from, to)
+	if err != nil {
+		t.Fatalf("GetGenerationStats failed: %v", err)
+	}
+	if len(buckets) != 3 {
+		t.Fatalf("Expected 3 daily buckets, got %d", len(buckets))
+	}
+	for i, bucket := range buckets {
+		if want := from.AddDate(0, 0, i); !bucket.Day.Equal(want) {
+			t.Errorf("Bucket %d: expected day %v, got %v", i, want, bucket.Day)
+		}
+		if len(bucket.ByUserType) != 2 {
+			t.Errorf("Bucket %d: expected both user types, got %v", i, bucket.ByUserType)
+		}
+	}
+
+	day1 := buckets[0]
+	if day1.Calls != 3 || day1.Successes != 2 {
+		t.Errorf("Day 1: expected 3 calls and 2 successes, got %d and %d", day1.Calls, day1.Successes)
+	}
+	if math.Abs(day1.SuccessRate-2.0/3.0) > 1e-9 {
+		t.Errorf("Day 1: expected success rate 2/3, got %f", day1.SuccessRate)
+	}
+	if day1.InputTokens != 350 || day1.OutputTokens != 700 {
+		t.Errorf("Day 1: expected 350/700 tokens, got %d/%d", day1.InputTokens, day1.OutputTokens)
+	}
+	if math.Abs(day1.CostUSD-0.006) > 1e-9 {
+		t.Errorf("Day 1: expected cost 0.006, got %f", day1.CostUSD)
+	}
+	if day1.P50DurationMS != 1000 {
+		t.Errorf("Day 1: expected p50 1000ms, got %f", day1.P50DurationMS)
+	}
+	if auth := day1.ByUserType["authenticated"]; auth.Calls != 2 || auth.SuccessRate != 1 || auth.P50DurationMS != 2000 {
+		t.Errorf("Day 1: unexpected authenticated stats %+v", auth)
+	}
+
+	if day2 := buckets[1]; day2.Calls != 0 || day2.ByUserType["anonymous"].Calls != 0 {
+		t.Errorf("Day 2: expected zeros, got %+v", day2)
+	}
+
+	if day3 := buckets[2]; day3.Calls != 1 || day3.ByUserType["anonymous"].Calls != 1 || day3.ByUserType["authenticated"].Calls != 0 {
+		t.Errorf("Day 3: unexpected stats %+v", day3)
+	}
+}
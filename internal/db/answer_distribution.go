@@ -0,0 +1,280 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// ResultsTextAnswerPreview is how many text answers per question
+// GetSurveyResults includes; the rest are paged with GetTextAnswers
+const ResultsTextAnswerPreview = 50
+
+// Predicates selecting a survey's responses (aliased r) in the answer
+// aggregation queries, by survey ID or by survey URI in $1
+const (
+	responsesBySurveyID  = `r.survey_id = $1`
+	responsesBySurveyURI = `r.survey_id = (SELECT id FROM surveys WHERE uri = $1)`
+)
+
+// GetAnswerDistribution counts, in a single query, how often each option of
+// each question was selected in the visible responses to the survey at
+// surveyURI. The result is keyed by question ID, then option ID.
+func (q *Queries) GetAnswerDistribution(ctx context.Context, surveyURI string) (map[string]map[string]int, error) {
+	return q.answerDistribution(ctx, responsesBySurveyURI, surveyURI)
+}
+
+func (q *Queries) answerDistribution(ctx context.Context, responses string, survey interface{}) (map[string]map[string]int, error) {
+	query := `
+		SELECT a.key, o.option_id, COUNT(*)
+		FROM responses r
+		CROSS JOIN LATERAL jsonb_each(r.answers) AS a
+		CROSS JOIN LATERAL jsonb_array_elements_text(
+			CASE WHEN jsonb_typeof(a.value->'selectedOptions') = 'array' THEN a.value->'selectedOptions' ELSE '[]'::jsonb END
+		) AS o(option_id)
+		WHERE ` + responses + ` AND r.hidden_at IS NULL
+		GROUP BY a.key, o.option_id
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, survey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query answer distribution: %w", err)
+	}
+	defer rows.Close()
+
+	distribution := make(map[string]map[string]int)
+	for rows.Next() {
+		var questionID, optionID string
+		var count int
+		if err := rows.Scan(&questionID, &optionID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan answer distribution: %w", err)
+		}
+		if distribution[questionID] == nil {
+			distribution[questionID] = make(map[string]int)
+		}
+		distribution[questionID][optionID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating answer distribution: %w", err)
+	}
+
+	return distribution, nil
+}
+
+// GetTextAnswers returns a page of the non-empty text answers to one
+// question of the survey at surveyURI, oldest first
+func (q *Queries) GetTextAnswers(ctx context.Context, surveyURI, questionID string, limit, offset int) ([]string, error) {
+	query := `
+		SELECT r.answers->$2::text->>'text'
+		FROM responses r
+		WHERE ` + responsesBySurveyURI + `
+		  AND r.hidden_at IS NULL
+		  AND COALESCE(r.answers->$2::text->>'text', '') <> ''
+		ORDER BY r.created_at ASC, r.id ASC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, surveyURI, questionID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query text answers: %w", err)
+	}
+	defer rows.Close()
+
+	answers := []string{}
+	for rows.Next() {
+		var answer string
+		if err := rows.Scan(&answer); err != nil {
+			return nil, fmt.Errorf("failed to scan text answer: %w", err)
+		}
+		answers = append(answers, answer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating text answers: %w", err)
+	}
+
+	return answers, nil
+}
+
+// CountTextAnswers counts the non-empty text answers to one question of the
+// survey at surveyURI, as GetTextAnswers would page through them
+func (q *Queries) CountTextAnswers(ctx context.Context, surveyURI, questionID string) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM responses r
+		WHERE ` + responsesBySurveyURI + `
+		  AND r.hidden_at IS NULL
+		  AND COALESCE(r.answers->$2::text->>'text', '') <> ''
+	`
+
+	var count int
+	if err := q.db.QueryRowContext(ctx, query, surveyURI, questionID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count text answers: %w", err)
+	}
+	return count, nil
+}
+
+// addRatingDistribution fills in the rating counts and averages of results
+func (q *Queries) addRatingDistribution(ctx context.Context, responses string, survey interface{}, results map[string]*models.QuestionResult) error {
+	query := `
+		SELECT a.key, (a.value->>'rating')::int, COUNT(*)
+		FROM responses r
+		CROSS JOIN LATERAL jsonb_each(r.answers) AS a
+		WHERE ` + responses + ` AND r.hidden_at IS NULL
+		  AND jsonb_typeof(a.value->'rating') = 'number'
+		GROUP BY 1, 2
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, survey)
+	if err != nil {
+		return fmt.Errorf("failed to query rating distribution: %w", err)
+	}
+	defer rows.Close()
+
+	sums := make(map[string]int)
+	for rows.Next() {
+		var questionID string
+		var value, count int
+		if err := rows.Scan(&questionID, &value, &count); err != nil {
+			return fmt.Errorf("failed to scan rating distribution: %w", err)
+		}
+		qResult, ok := results[questionID]
+		if !ok {
+			continue // Skip answers for questions that no longer exist
+		}
+		if qResult.RatingCounts == nil {
+			qResult.RatingCounts = make(map[int]int)
+		}
+		qResult.RatingCounts[value] = count
+		sums[questionID] += value * count
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rating distribution: %w", err)
+	}
+
+	for questionID, sum := range sums {
+		qResult := results[questionID]
+		qResult.RatingAverage = float64(sum) / float64(qResult.RatingTotal())
+	}
+	return nil
+}
+
+// addTextAnswers fills in the first ResultsTextAnswerPreview text answers of
+// each question in results, and how many there are in all
+func (q *Queries) addTextAnswers(ctx context.Context, responses string, survey interface{}, results map[string]*models.QuestionResult) error {
+	query := `
+		SELECT question_id, text, total
+		FROM (
+			SELECT a.key AS question_id, a.value->>'text' AS text,
+			       COUNT(*) OVER (PARTITION BY a.key) AS total,
+			       ROW_NUMBER() OVER (PARTITION BY a.key ORDER BY r.created_at, r.id) AS n
+			FROM responses r
+			CROSS JOIN LATERAL jsonb_each(r.answers) AS a
+			WHERE ` + responses + ` AND r.hidden_at IS NULL
+			  AND COALESCE(a.value->>'text', '') <> ''
+		) t
+		WHERE n <= $2
+		ORDER BY question_id, n
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, survey, ResultsTextAnswerPreview)
+	if err != nil {
+		return fmt.Errorf("failed to query text answers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var questionID, text string
+		var total int
+		if err := rows.Scan(&questionID, &text, &total); err != nil {
+			return fmt.Errorf("failed to scan text answer: %w", err)
+		}
+		qResult, ok := results[questionID]
+		if !ok {
+			continue
+		}
+		qResult.TextAnswers = append(qResult.TextAnswers, text)
+		qResult.TextAnswerCount = total
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating text answers: %w", err)
+	}
+	return nil
+}
+
+// addOtherTexts fills in the free text given on options that allow it
+func (q *Queries) addOtherTexts(ctx context.Context, responses string, survey interface{}, results map[string]*models.QuestionResult) error {
+	query := `
+		SELECT a.key, o.key, o.value
+		FROM responses r
+		CROSS JOIN LATERAL jsonb_each(r.answers) AS a
+		CROSS JOIN LATERAL jsonb_each_text(
+			CASE WHEN jsonb_typeof(a.value->'otherText') = 'object' THEN a.value->'otherText' ELSE '{}'::jsonb END
+		) AS o
+		WHERE ` + responses + ` AND r.hidden_at IS NULL
+		ORDER BY r.created_at, r.id
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, survey)
+	if err != nil {
+		return fmt.Errorf("failed to query other text: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var questionID, optionID, text string
+		if err := rows.Scan(&questionID, &optionID, &text); err != nil {
+			return fmt.Errorf("failed to scan other text: %w", err)
+		}
+		if qResult, ok := results[questionID]; ok {
+			qResult.AddOtherText(optionID, text)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating other text: %w", err)
+	}
+	return nil
+}
+
+// addRemovedQuestions counts the visible responses answering each question
+// that's no longer in the survey's definition
+func (q *Queries) addRemovedQuestions(ctx context.Context, responses string, survey interface{}, results *models.SurveyResults) error {
+	query := `
+		SELECT a.key, COUNT(*)
+		FROM responses r
+		CROSS JOIN LATERAL jsonb_object_keys(r.answers) AS a(key)
+		WHERE ` + responses + ` AND r.hidden_at IS NULL
+		GROUP BY a.key
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, survey)
+	if err != nil {
+		return fmt.Errorf("failed to query removed questions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var questionID string
+		var count int
+		if err := rows.Scan(&questionID, &count); err != nil {
+			return fmt.Errorf("failed to scan removed question: %w", err)
+		}
+		if _, ok := results.QuestionResults[questionID]; ok {
+			continue
+		}
+		if results.RemovedQuestions == nil {
+			results.RemovedQuestions = make(map[string]int)
+		}
+		results.RemovedQuestions[questionID] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating removed questions: %w", err)
+	}
+	return nil
+}
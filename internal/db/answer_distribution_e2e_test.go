@@ -0,0 +1,297 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// syntheticResponses is how many responses the aggregation tests generate
+const syntheticResponses = 3000
+
+// countingDB counts the queries run through it
+type countingDB struct {
+	*sql.DB
+	queries int
+}
+
+func (c *countingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c.queries++
+	return c.DB.QueryContext(ctx, query, args...)
+}
+
+func (c *countingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	c.queries++
+	return c.DB.QueryRowContext(ctx, query, args...)
+}
+
+// createSyntheticSurvey creates a survey with n generated responses. Response
+// i picks option "a" when i is divisible by 3 and "b" otherwise, selects
+// both "x" and "y", answers the text question with "answer <i>" and rates
+// i%5+1.
+func createSyntheticSurvey(t testing.TB, db *sql.DB, n int) *models.Survey {
+	t.Helper()
+	ctx := context.Background()
+
+	uri := "at://did:plc:synthetic/net.openmeet.survey/" + uuid.NewString()[:8]
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		URI:   &uri,
+		Slug:  "synthetic-" + uuid.NewString()[:8],
+		Title: "Synthetic",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{
+				{ID: "q1", Text: "Pick one", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}}},
+				{ID: "q2", Text: "Pick some", Type: models.QuestionTypeMulti, Options: []models.Option{{ID: "x", Text: "X"}, {ID: "y", Text: "Y"}}},
+				{ID: "q3", Text: "Why?", Type: models.QuestionTypeText},
+				{ID: "q4", Text: "Rate it", Type: models.QuestionTypeRating, Min: 1, Max: 5},
+			},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := NewQueries(db).CreateSurvey(ctx, survey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM surveys WHERE id = $1`, survey.ID) })
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO responses (survey_id, voter_session, answers, created_at)
+		SELECT $1, 'synthetic-' || i,
+		       jsonb_build_object(
+		           'q1', jsonb_build_object('selectedOptions', jsonb_build_array(CASE WHEN i % 3 = 0 THEN 'a' ELSE 'b' END)),
+		           'q2', jsonb_build_object('selectedOptions', jsonb_build_array('x', 'y')),
+		           'q3', jsonb_build_object('text', 'answer ' || i),
+		           'q4', jsonb_build_object('rating', i % 5 + 1)),
+		       NOW() - INTERVAL '1 day' + i * INTERVAL '1 second'
+		FROM generate_series(1, $2::int) AS i
+	`, survey.ID, n)
+	if err != nil {
+		t.Fatalf("Failed to generate responses: %v", err)
+	}
+	return survey
+}
+
+// TestGetAnswerDistribution aggregates a few thousand responses in one query
+func TestGetAnswerDistribution(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	survey := createSyntheticSurvey(t, db, syntheticResponses)
+
+	counter := &countingDB{DB: db}
+	queries := NewQueries(counter)
+
+	start := time.Now()
+	distribution, err := queries.GetAnswerDistribution(ctx, *survey.URI)
+	if err != nil {
+		t.Fatalf("GetAnswerDistribution failed: %v", err)
+	}
+	t.Logf("Aggregated %d responses in %v", syntheticResponses, time.Since(start))
+
+	if counter.queries != 1 {
+		t.Errorf("Expected a single query, got %d", counter.queries)
+	}
+
+	want := map[string]map[string]int{
+		"q1": {"a": syntheticResponses / 3, "b": syntheticResponses - syntheticResponses/3},
+		"q2": {"x": syntheticResponses, "y": syntheticResponses},
+	}
+	for questionID, counts := range want {
+		for optionID, count := range counts {
+			if got := distribution[questionID][optionID]; got != count {
+				t.Errorf("Expected %s/%s = %d, got %d", questionID, optionID, count, got)
+			}
+		}
+	}
+	if _, ok := distribution["q3"]; ok {
+		t.Error("Expected no option counts for the text question")
+	}
+
+	t.Run("hidden responses aren't counted", func(t *testing.T) {
+		if _, err := db.ExecContext(ctx, `UPDATE responses SET hidden_at = NOW() WHERE survey_id = $1 AND voter_session = 'synthetic-3'`, survey.ID); err != nil {
+			t.Fatalf("Failed to hide response: %v", err)
+		}
+		defer db.ExecContext(ctx, `UPDATE responses SET hidden_at = NULL WHERE survey_id = $1`, survey.ID)
+
+		distribution, err := queries.GetAnswerDistribution(ctx, *survey.URI)
+		if err != nil {
+			t.Fatalf("GetAnswerDistribution failed: %v", err)
+		}
+		if got := distribution["q1"]["a"]; got != syntheticResponses/3-1 {
+			t.Errorf("Expected %d, got %d", syntheticResponses/3-1, got)
+		}
+	})
+
+	t.Run("unknown survey", func(t *testing.T) {
+		distribution, err := queries.GetAnswerDistribution(ctx, "at://did:plc:nobody/net.openmeet.survey/none")
+		if err != nil {
+			t.Fatalf("GetAnswerDistribution failed: %v", err)
+		}
+		if len(distribution) != 0 {
+			t.Errorf("Expected no counts, got %v", distribution)
+		}
+	})
+}
+
+// TestGetTextAnswers pages through text answers oldest first
+func TestGetTextAnswers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := NewQueries(db)
+	survey := createSyntheticSurvey(t, db, 120)
+
+	answers, err := queries.GetTextAnswers(ctx, *survey.URI, "q3", 10, 100)
+	if err != nil {
+		t.Fatalf("GetTextAnswers failed: %v", err)
+	}
+	if len(answers) != 10 {
+		t.Fatalf("Expected 10 answers, got %d", len(answers))
+	}
+	for i, answer := range answers {
+		if want := fmt.Sprintf("answer %d", 101+i); answer != want {
+			t.Errorf("Expected %q at %d, got %q", want, i, answer)
+		}
+	}
+
+	answers, err = queries.GetTextAnswers(ctx, *survey.URI, "q1", 10, 0)
+	if err != nil {
+		t.Fatalf("GetTextAnswers failed: %v", err)
+	}
+	if len(answers) != 0 {
+		t.Errorf("Expected no text answers to a choice question, got %v", answers)
+	}
+
+	count, err := queries.CountTextAnswers(ctx, *survey.URI, "q3")
+	if err != nil {
+		t.Fatalf("CountTextAnswers failed: %v", err)
+	}
+	if count != 120 {
+		t.Errorf("Expected 120 text answers, got %d", count)
+	}
+}
+
+// TestGetSurveyResultsAggregatesInSQL checks results no longer grow the
+// query count with the number of responses
+func TestGetSurveyResultsAggregatesInSQL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	survey := createSyntheticSurvey(t, db, syntheticResponses)
+
+	counter := &countingDB{DB: db}
+	results, err := NewQueries(counter).GetSurveyResults(ctx, survey.ID)
+	if err != nil {
+		t.Fatalf("GetSurveyResults failed: %v", err)
+	}
+
+	// The survey, the total and one query per kind of answer
+	if counter.queries > 6 {
+		t.Errorf("Expected at most 6 queries, got %d", counter.queries)
+	}
+
+	if results.TotalVotes != syntheticResponses {
+		t.Errorf("Expected %d votes, got %d", syntheticResponses, results.TotalVotes)
+	}
+	if got := results.QuestionResults["q1"].OptionCounts["a"]; got != syntheticResponses/3 {
+		t.Errorf("Expected %d votes for a, got %d", syntheticResponses/3, got)
+	}
+
+	text := results.QuestionResults["q3"]
+	if len(text.TextAnswers) != ResultsTextAnswerPreview || text.TextAnswerCount != syntheticResponses {
+		t.Errorf("Expected a preview of %d of %d answers, got %d of %d", ResultsTextAnswerPreview, syntheticResponses, len(text.TextAnswers), text.TextAnswerCount)
+	}
+	if text.TextAnswers[0] != "answer 1" {
+		t.Errorf("Expected the oldest answer first, got %q", text.TextAnswers[0])
+	}
+
+	rating := results.QuestionResults["q4"]
+	if rating.RatingTotal() != syntheticResponses || rating.RatingAverage != 3 {
+		t.Errorf("Expected %d ratings averaging 3, got %d averaging %v", syntheticResponses, rating.RatingTotal(), rating.RatingAverage)
+	}
+}
+
+// TestGetSurveyResultsRemovedQuestions counts the answers to questions an
+// edit removed
+func TestGetSurveyResultsRemovedQuestions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := NewQueries(db)
+	survey := createSyntheticSurvey(t, db, 10)
+
+	results, err := queries.GetSurveyResults(ctx, survey.ID)
+	if err != nil {
+		t.Fatalf("GetSurveyResults failed: %v", err)
+	}
+	if results.DefinitionVersion != 1 || results.RemovedQuestions != nil {
+		t.Errorf("Expected version 1 and no removed questions, got %d and %v", results.DefinitionVersion, results.RemovedQuestions)
+	}
+
+	// The edit the consumer applies when answered questions are removed
+	survey.Definition.Questions = []models.Question{survey.Definition.Questions[0], survey.Definition.Questions[3]}
+	survey.DefinitionVersion = 2
+	if err := queries.UpdateSurvey(ctx, survey); err != nil {
+		t.Fatalf("UpdateSurvey failed: %v", err)
+	}
+
+	results, err = queries.GetSurveyResults(ctx, survey.ID)
+	if err != nil {
+		t.Fatalf("GetSurveyResults failed: %v", err)
+	}
+	if results.DefinitionVersion != 2 {
+		t.Errorf("Expected version 2, got %d", results.DefinitionVersion)
+	}
+	want := map[string]int{"q2": 10, "q3": 10}
+	if fmt.Sprint(results.RemovedQuestions) != fmt.Sprint(want) {
+		t.Errorf("Expected removed questions %v, got %v", want, results.RemovedQuestions)
+	}
+	if results.QuestionResults["q1"].OptionCounts["b"] != 7 {
+		t.Errorf("Expected the remaining questions still aggregated, got %v", results.QuestionResults["q1"].OptionCounts)
+	}
+}
+
+// BenchmarkGetAnswerDistribution times the aggregation over synthetic responses
+func BenchmarkGetAnswerDistribution(b *testing.B) {
+	db := setupTestDB(b)
+	defer db.Close()
+
+	ctx := context.Background()
+	survey := createSyntheticSurvey(b, db, syntheticResponses)
+	queries := NewQueries(db)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := queries.GetAnswerDistribution(ctx, *survey.URI); err != nil {
+			b.Fatalf("GetAnswerDistribution failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditActorSystem is the actor recorded for scheduled jobs and automatic
+// handling of Jetstream events
+const AuditActorSystem = "system"
+
+// Actions recorded in the audit_log table
+const (
+	AuditActionBlockDID            = "did.block"
+	AuditActionUnblockDID          = "did.unblock"
+	AuditActionPurgeAccount        = "account.purge"
+	AuditActionForceSetCursor      = "cursor.force_set"
+	AuditActionRestoreSurvey       = "survey.restore"
+	AuditActionPurgeSurveys        = "survey.purge"
+	AuditActionPruneGenerationLogs = "generation_logs.prune"
+)
+
+// AuditEntry is one recorded administrative or destructive action
+type AuditEntry struct {
+	ID        uuid.UUID       `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target,omitempty"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// AuditFilter narrows ListAuditEntries. Empty fields and zero times match
+// every entry.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+}
+
+// AuditDetails encodes the details of an audit entry. Values must be
+// JSON-encodable; nil is returned for an empty map.
+func AuditDetails(details map[string]interface{}) json.RawMessage {
+	if len(details) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+// RecordAudit appends an entry to the audit log. actor is the admin DID that
+// took the action, or AuditActorSystem; target is what it was taken on (a DID,
+// a URI, or empty) and details is an optional JSON object. Record it through
+// the Queries the action ran on inside WithTx, so an action is never left
+// unaudited and an entry never outlives a rolled back action.
+func (q *Queries) RecordAudit(ctx context.Context, actor, action, target string, details json.RawMessage) error {
+	query := `
+		INSERT INTO audit_log (actor, action, target, details)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	// A nil RawMessage would be sent as an empty string, which isn't valid JSON
+	var detailsArg interface{}
+	if len(details) > 0 {
+		detailsArg = []byte(details)
+	}
+
+	if _, err := q.db.ExecContext(ctx, query, actor, action, target, detailsArg); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditEntries returns audit entries matching filter, newest first
+func (q *Queries) ListAuditEntries(ctx context.Context, filter AuditFilter, limit, offset int) ([]AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Actor != "" {
+		where("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.Target != "" {
+		where("target = $%d", filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < $%d", filter.Until)
+	}
+
+	query := `SELECT id, actor, action, target, details, created_at FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &details, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if len(details) > 0 {
+			entry.Details = json.RawMessage(details)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,88 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestAuditLog records entries and lists them back with filters
+func TestAuditLog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	// The test database isn't reset between runs, so keep actors unique
+	admin := "did:plc:admin" + uuid.NewString()[:8]
+	target := "did:plc:target" + uuid.NewString()[:8]
+	defer db.Exec(`DELETE FROM audit_log WHERE actor = $1`, admin)
+
+	if err := queries.RecordAudit(ctx, admin, AuditActionBlockDID, target, AuditDetails(map[string]interface{}{"reason": "spam"})); err != nil {
+		t.Fatalf("RecordAudit failed: %v", err)
+	}
+	if err := queries.RecordAudit(ctx, admin, AuditActionUnblockDID, target, nil); err != nil {
+		t.Fatalf("RecordAudit failed: %v", err)
+	}
+	if err := queries.RecordAudit(ctx, admin, AuditActionRestoreSurvey, "at://"+target+"/net.openmeet.survey/x", nil); err != nil {
+		t.Fatalf("RecordAudit failed: %v", err)
+	}
+
+	t.Run("newest first", func(t *testing.T) {
+		entries, err := queries.ListAuditEntries(ctx, AuditFilter{Actor: admin}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 3 {
+			t.Fatalf("Expected 3 entries, got %d", len(entries))
+		}
+		if entries[0].Action != AuditActionRestoreSurvey || entries[2].Action != AuditActionBlockDID {
+			t.Errorf("Expected newest first, got %s ... %s", entries[0].Action, entries[2].Action)
+		}
+		if string(entries[2].Details) != `{"reason": "spam"}` {
+			t.Errorf("Expected the details back, got %s", entries[2].Details)
+		}
+		if entries[1].Details != nil {
+			t.Errorf("Expected no details, got %s", entries[1].Details)
+		}
+	})
+
+	t.Run("by action and target", func(t *testing.T) {
+		entries, err := queries.ListAuditEntries(ctx, AuditFilter{Action: AuditActionUnblockDID, Target: target}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Actor != admin {
+			t.Errorf("Expected the unblock, got %+v", entries)
+		}
+	})
+
+	t.Run("by time", func(t *testing.T) {
+		entries, err := queries.ListAuditEntries(ctx, AuditFilter{Actor: admin, Until: time.Now().Add(-time.Hour)}, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected no entries before an hour ago, got %d", len(entries))
+		}
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		entries, err := queries.ListAuditEntries(ctx, AuditFilter{Actor: admin}, 2, 2)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if len(entries) != 1 || entries[0].Action != AuditActionBlockDID {
+			t.Errorf("Expected the oldest entry on the second page, got %+v", entries)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+)
+
+// auditLogColumns are the columns ListAuditEntries scans
+var auditLogColumns = []string{"id", "actor", "action", "target", "details", "created_at"}
+
+func TestRecordAuditFake(t *testing.T) {
+	t.Run("stores details as JSON", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("INSERT INTO audit_log").RowsAffected(1)
+
+		details := AuditDetails(map[string]interface{}{"reason": "spam"})
+		if err := NewQueries(fake).RecordAudit(context.Background(), "did:plc:admin", AuditActionBlockDID, "did:plc:spam", details); err != nil {
+			t.Fatalf("RecordAudit failed: %v", err)
+		}
+
+		args := fake.Calls()[0].Args
+		if args[0] != "did:plc:admin" || args[1] != AuditActionBlockDID || args[2] != "did:plc:spam" {
+			t.Errorf("Unexpected arguments %v", args)
+		}
+		if got := string(args[3].([]byte)); got != `{"reason":"spam"}` {
+			t.Errorf("Expected the details JSON, got %s", got)
+		}
+	})
+
+	t.Run("no details is NULL", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("INSERT INTO audit_log").RowsAffected(1)
+
+		if err := NewQueries(fake).RecordAudit(context.Background(), AuditActorSystem, AuditActionRestoreSurvey, "at://x", nil); err != nil {
+			t.Fatalf("RecordAudit failed: %v", err)
+		}
+		if args := fake.Calls()[0].Args; args[3] != nil {
+			t.Errorf("Expected NULL details, got %v", args[3])
+		}
+	})
+}
+
+func TestListAuditEntriesFake(t *testing.T) {
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	t.Run("without filters", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM audit_log").Rows(auditLogColumns,
+			[]interface{}{id, "system", AuditActionPurgeSurveys, "", []byte(`{"purged":2}`), createdAt},
+			[]interface{}{uuid.New(), "did:plc:admin", AuditActionRestoreSurvey, "at://x", nil, createdAt},
+		)
+
+		entries, err := NewQueries(fake).ListAuditEntries(context.Background(), AuditFilter{}, 20, 40)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+
+		call := fake.Calls()[0]
+		want := "SELECT id, actor, action, target, details, created_at FROM audit_log ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2"
+		if call.Query != want {
+			t.Errorf("Expected %q, got %q", want, call.Query)
+		}
+		if call.Args[0] != 20 || call.Args[1] != 40 {
+			t.Errorf("Expected limit 20 and offset 40, got %v", call.Args)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 entries, got %d", len(entries))
+		}
+		if entries[0].ID != id || !json.Valid(entries[0].Details) || !entries[0].CreatedAt.Equal(createdAt) {
+			t.Errorf("Unexpected first entry %+v", entries[0])
+		}
+		if entries[1].Details != nil {
+			t.Errorf("Expected no details, got %s", entries[1].Details)
+		}
+	})
+
+	t.Run("filters are ANDed in order", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM audit_log").Rows(auditLogColumns)
+
+		since := createdAt.Add(-time.Hour)
+		filter := AuditFilter{Actor: "did:plc:admin", Target: "did:plc:spam", Since: since, Until: createdAt}
+		entries, err := NewQueries(fake).ListAuditEntries(context.Background(), filter, 10, 0)
+		if err != nil {
+			t.Fatalf("ListAuditEntries failed: %v", err)
+		}
+		if entries == nil || len(entries) != 0 {
+			t.Errorf("Expected an empty, non-nil page, got %v", entries)
+		}
+
+		call := fake.Calls()[0]
+		want := "WHERE actor = $1 AND target = $2 AND created_at >= $3 AND created_at < $4 ORDER BY created_at DESC, id DESC LIMIT $5 OFFSET $6"
+		if !strings.Contains(call.Query, want) {
+			t.Errorf("Expected %q in %q", want, call.Query)
+		}
+		if call.Args[0] != "did:plc:admin" || call.Args[1] != "did:plc:spam" || call.Args[4] != 10 {
+			t.Errorf("Unexpected arguments %v", call.Args)
+		}
+	})
+}
+
+func TestPurgeDeletedSurveysAuditFake(t *testing.T) {
+	deletedBefore := time.Date(2025, 5, 2, 3, 0, 0, 0, time.UTC)
+
+	t.Run("records the purge", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM surveys").RowsAffected(2)
+		fake.Expect("INSERT INTO audit_log").RowsAffected(1)
+
+		purged, err := NewQueries(fake).PurgeDeletedSurveys(context.Background(), deletedBefore)
+		if err != nil || purged != 2 {
+			t.Fatalf("Expected 2 purged, got %d (%v)", purged, err)
+		}
+
+		audits := fake.CallsMatching("INSERT INTO audit_log")
+		if len(audits) != 1 {
+			t.Fatalf("Expected the purge to be audited, got %v", fake.Calls())
+		}
+		args := audits[0].Args
+		if args[0] != AuditActorSystem || args[1] != AuditActionPurgeSurveys {
+			t.Errorf("Expected a purge entry by the system, got %v", args)
+		}
+		if got := string(args[3].([]byte)); got != `{"deletedBefore":"2025-05-02T03:00:00Z","purged":2}` {
+			t.Errorf("Expected the cutoff and count in the details, got %s", got)
+		}
+	})
+
+	t.Run("nothing purged isn't audited", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM surveys").RowsAffected(0)
+
+		if _, err := NewQueries(fake).PurgeDeletedSurveys(context.Background(), deletedBefore); err != nil {
+			t.Fatalf("PurgeDeletedSurveys failed: %v", err)
+		}
+		if audits := fake.CallsMatching("INSERT INTO audit_log"); len(audits) != 0 {
+			t.Errorf("Expected no audit entry, got %v", audits)
+		}
+	})
+
+	t.Run("failed audit fails the purge", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM surveys").RowsAffected(2)
+		fake.Expect("INSERT INTO audit_log").Err(errors.New("connection reset"))
+
+		if _, err := NewQueries(fake).PurgeDeletedSurveys(context.Background(), deletedBefore); err == nil {
+			t.Error("Expected an error so the purge is rolled back")
+		}
+	})
+}
+
+func TestPruneGenerationLogsAuditFake(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("UPDATE ai_generation_logs").RowsAffected(5)
+	fake.Expect("INSERT INTO audit_log").RowsAffected(1)
+
+	olderThan := time.Date(2025, 5, 2, 3, 0, 0, 0, time.UTC)
+	if _, err := NewQueries(fake).PruneGenerationLogs(context.Background(), olderThan, true); err != nil {
+		t.Fatalf("PruneGenerationLogs failed: %v", err)
+	}
+
+	audits := fake.CallsMatching("INSERT INTO audit_log")
+	if len(audits) != 1 {
+		t.Fatalf("Expected the prune to be audited, got %v", fake.Calls())
+	}
+	args := audits[0].Args
+	if args[0] != AuditActorSystem || args[1] != AuditActionPruneGenerationLogs {
+		t.Errorf("Expected a prune entry by the system, got %v", args)
+	}
+	if got := string(args[3].([]byte)); got != `{"keepFailures":true,"olderThan":"2025-05-02T03:00:00Z","pruned":5}` {
+		t.Errorf("Expected the cutoff and count in the details, got %s", got)
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// AuthorSurveyFilter widens an author's survey listing. The zero value lists
+// what anyone may see: discoverable, non-deleted surveys.
+type AuthorSurveyFilter struct {
+	// IncludeUnlisted also lists surveys that are only reachable by direct link
+	IncludeUnlisted bool
+	// IncludeDeleted also lists soft-deleted surveys
+	IncludeDeleted bool
+	// Status keeps only surveys in that status; empty keeps every status
+	Status models.SurveyStatus
+}
+
+// GetSurveysByAuthor returns a page of the surveys published by did, newest
+// first, with their status and response counts. Hidden surveys are never
+// listed.
+func (q *Queries) GetSurveysByAuthor(ctx context.Context, did string, limit, offset int, filter AuthorSurveyFilter) ([]*models.AuthorSurvey, error) {
+	// Served by idx_surveys_author_did
+	query := `
+		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, definition_version, version, lang, created_at, updated_at, record_updated_at, deleted_at, response_count,
+		       ` + surveyStatusSQL("$7") + ` AS status
+		FROM surveys
+		WHERE author_did = $1
+		  AND hidden_at IS NULL
+		  AND ($4 OR deleted_at IS NULL)
+		  AND ($5 OR (definition->>'discoverable')::boolean IS TRUE)
+		  AND ($6 = '' OR ` + surveyStatusSQL("$7") + ` = $6)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, did, limit, offset, filter.IncludeDeleted, filter.IncludeUnlisted, string(filter.Status), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query surveys by author: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*models.AuthorSurvey
+	for rows.Next() {
+		survey := &models.Survey{}
+		result := &models.AuthorSurvey{Survey: survey}
+		var defJSON []byte
+
+		err := rows.Scan(
+			&survey.ID,
+			&survey.URI,
+			&survey.CID,
+			&survey.AuthorDID,
+			&survey.Slug,
+			&survey.Title,
+			&survey.Description,
+			&defJSON,
+			&survey.StartsAt,
+			&survey.EndsAt,
+			&survey.ResultsURI,
+			&survey.ResultsCID,
+			&survey.DefinitionVersion,
+			&survey.Version,
+			&survey.Lang,
+			&survey.CreatedAt,
+			&survey.UpdatedAt,
+			&survey.RecordUpdatedAt,
+			&survey.DeletedAt,
+			&result.ResponseCount,
+			&survey.Status,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan survey: %w", err)
+		}
+
+		// Unmarshal JSONB definition
+		if err := json.Unmarshal(defJSON, &survey.Definition); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal survey definition: %w", err)
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating surveys: %w", err)
+	}
+
+	return results, nil
+}
+
+// CountSurveysByAuthor counts the surveys GetSurveysByAuthor lists for did
+// with the same filter, for pagination
+func (q *Queries) CountSurveysByAuthor(ctx context.Context, did string, filter AuthorSurveyFilter) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM surveys
+		WHERE author_did = $1
+		  AND hidden_at IS NULL
+		  AND ($2 OR deleted_at IS NULL)
+		  AND ($3 OR (definition->>'discoverable')::boolean IS TRUE)
+		  AND ($4 = '' OR ` + surveyStatusSQL("$5") + ` = $4)
+	`
+
+	var count int
+	if err := q.db.QueryRowContext(ctx, query, did, filter.IncludeDeleted, filter.IncludeUnlisted, string(filter.Status), time.Now()).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count surveys by author: %w", err)
+	}
+	return count, nil
+}
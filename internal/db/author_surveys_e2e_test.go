@@ -0,0 +1,153 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestGetSurveysByAuthor lists the surveys of two authors and checks neither
+// sees the other's
+func TestGetSurveysByAuthor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	// Fresh DIDs keep results independent of other surveys in the database
+	alice := "did:plc:alice" + uuid.NewString()[:8]
+	bob := "did:plc:bob" + uuid.NewString()[:8]
+
+	base := time.Now().Add(-time.Hour)
+	create := func(author string, n int, discoverable bool) *models.Survey {
+		uri := "at://" + author + "/net.openmeet.survey/" + uuid.NewString()[:8]
+		survey := &models.Survey{
+			ID:        uuid.New(),
+			URI:       &uri,
+			AuthorDID: &author,
+			Slug:      "author-" + uuid.NewString()[:8],
+			Title:     "Survey by " + author,
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{{
+					ID:      "q1",
+					Text:    "Pick one",
+					Type:    models.QuestionTypeSingle,
+					Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+				}},
+				Discoverable: discoverable,
+			},
+			CreatedAt: base.Add(time.Duration(n) * time.Minute),
+			UpdatedAt: base.Add(time.Duration(n) * time.Minute),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+		t.Cleanup(func() { db.Exec(`DELETE FROM surveys WHERE id = $1`, survey.ID) })
+		return survey
+	}
+
+	aliceOld := create(alice, 1, true)
+	aliceUnlisted := create(alice, 2, false)
+	aliceNew := create(alice, 3, true)
+	aliceDeleted := create(alice, 4, true)
+	bobOnly := create(bob, 5, true)
+
+	session := "author-session"
+	if err := queries.CreateResponse(ctx, &models.Response{
+		ID:           uuid.New(),
+		SurveyID:     aliceNew.ID,
+		VoterSession: &session,
+		Answers:      map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}},
+		CreatedAt:    time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to create response: %v", err)
+	}
+	if err := queries.DeleteSurveyByURI(ctx, *aliceDeleted.URI); err != nil {
+		t.Fatalf("Failed to delete survey: %v", err)
+	}
+
+	ids := func(results []*models.AuthorSurvey) []uuid.UUID {
+		var out []uuid.UUID
+		for _, r := range results {
+			out = append(out, r.Survey.ID)
+		}
+		return out
+	}
+	expectIDs := func(t *testing.T, got []*models.AuthorSurvey, want ...*models.Survey) {
+		t.Helper()
+		gotIDs := ids(got)
+		if len(gotIDs) != len(want) {
+			t.Fatalf("Expected %d surveys, got %d", len(want), len(gotIDs))
+		}
+		for i, s := range want {
+			if gotIDs[i] != s.ID {
+				t.Errorf("Expected %q at position %d, got %s", s.Slug, i, gotIDs[i])
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		did    string
+		filter AuthorSurveyFilter
+		want   []*models.Survey
+	}{
+		{"public view", alice, AuthorSurveyFilter{}, []*models.Survey{aliceNew, aliceOld}},
+		{"with unlisted", alice, AuthorSurveyFilter{IncludeUnlisted: true}, []*models.Survey{aliceNew, aliceUnlisted, aliceOld}},
+		{"with deleted", alice, AuthorSurveyFilter{IncludeUnlisted: true, IncludeDeleted: true}, []*models.Survey{aliceDeleted, aliceNew, aliceUnlisted, aliceOld}},
+		{"other author", bob, AuthorSurveyFilter{IncludeUnlisted: true, IncludeDeleted: true}, []*models.Survey{bobOnly}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := queries.GetSurveysByAuthor(ctx, tt.did, 10, 0, tt.filter)
+			if err != nil {
+				t.Fatalf("GetSurveysByAuthor failed: %v", err)
+			}
+			expectIDs(t, results, tt.want...)
+
+			count, err := queries.CountSurveysByAuthor(ctx, tt.did, tt.filter)
+			if err != nil {
+				t.Fatalf("CountSurveysByAuthor failed: %v", err)
+			}
+			if count != len(tt.want) {
+				t.Errorf("Expected count %d, got %d", len(tt.want), count)
+			}
+		})
+	}
+
+	t.Run("response counts", func(t *testing.T) {
+		results, err := queries.GetSurveysByAuthor(ctx, alice, 10, 0, AuthorSurveyFilter{})
+		if err != nil {
+			t.Fatalf("GetSurveysByAuthor failed: %v", err)
+		}
+		expectIDs(t, results, aliceNew, aliceOld)
+		if results[0].ResponseCount != 1 || results[1].ResponseCount != 0 {
+			t.Errorf("Expected response counts 1 and 0, got %d and %d", results[0].ResponseCount, results[1].ResponseCount)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		filter := AuthorSurveyFilter{IncludeUnlisted: true}
+		first, err := queries.GetSurveysByAuthor(ctx, alice, 2, 0, filter)
+		if err != nil {
+			t.Fatalf("GetSurveysByAuthor failed: %v", err)
+		}
+		expectIDs(t, first, aliceNew, aliceUnlisted)
+
+		second, err := queries.GetSurveysByAuthor(ctx, alice, 2, 2, filter)
+		if err != nil {
+			t.Fatalf("GetSurveysByAuthor failed: %v", err)
+		}
+		expectIDs(t, second, aliceOld)
+	})
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// blockCacheTTL bounds how long a block or unblock made by another process
+// takes to reach a running consumer
+const blockCacheTTL = 30 * time.Second
+
+// blockCacheMaxEntries caps memory use; the cache is cleared when it fills up
+const blockCacheMaxEntries = 10000
+
+// BlockDID adds a DID to the moderation blocklist. Blocking an already
+// blocked DID updates the reason.
+func (q *Queries) BlockDID(ctx context.Context, did, reason string) error {
+	query := `
+		INSERT INTO blocked_dids (did, reason, blocked_at)
+		VALUES ($1, NULLIF($2, ''), NOW())
+		ON CONFLICT (did) DO UPDATE SET reason = EXCLUDED.reason
+	`
+
+	if _, err := q.db.ExecContext(ctx, query, did, reason); err != nil {
+		return fmt.Errorf("failed to block DID: %w", err)
+	}
+
+	q.blocked.set(did, true, time.Now())
+	return nil
+}
+
+// UnblockDID removes a DID from the moderation blocklist
+func (q *Queries) UnblockDID(ctx context.Context, did string) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM blocked_dids WHERE did = $1`, did); err != nil {
+		return fmt.Errorf("failed to unblock DID: %w", err)
+	}
+
+	q.blocked.set(did, false, time.Now())
+	return nil
+}
+
+// IsBlocked reports whether a DID is on the moderation blocklist.
+// Answers are cached for blockCacheTTL.
+func (q *Queries) IsBlocked(ctx context.Context, did string) (bool, error) {
+	now := time.Now()
+	if blocked, ok := q.blocked.get(did, now); ok {
+		return blocked, nil
+	}
+
+	var blocked bool
+	query := `SELECT EXISTS(SELECT 1 FROM blocked_dids WHERE did = $1)`
+	if err := q.db.QueryRowContext(ctx, query, did).Scan(&blocked); err != nil {
+		return false, fmt.Errorf("failed to check blocked DID: %w", err)
+	}
+
+	q.blocked.set(did, blocked, now)
+	return blocked, nil
+}
+
+// blockCache remembers IsBlocked answers for a short time so the consumer
+// doesn't query blocked_dids for every event
+type blockCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]blockCacheEntry
+}
+
+type blockCacheEntry struct {
+	blocked bool
+	expires time.Time
+}
+
+func newBlockCache(ttl time.Duration) *blockCache {
+	return &blockCache{ttl: ttl, entries: make(map[string]blockCacheEntry)}
+}
+
+// get returns the cached answer for did, if there is one that hasn't expired
+func (c *blockCache) get(did string, now time.Time) (blocked, ok bool) {
+	if c == nil {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[did]
+	if !ok || !now.Before(entry.expires) {
+		return false, false
+	}
+	return entry.blocked, true
+}
+
+func (c *blockCache) set(did string, blocked bool, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= blockCacheMaxEntries {
+		c.entries = make(map[string]blockCacheEntry)
+	}
+	c.entries[did] = blockCacheEntry{blocked: blocked, expires: now.Add(c.ttl)}
+}
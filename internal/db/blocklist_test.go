@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBlockCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newBlockCache(30 * time.Second)
+
+	if _, ok := cache.get("did:plc:spam", now); ok {
+		t.Fatal("Expected miss on empty cache")
+	}
+
+	cache.set("did:plc:spam", true, now)
+	cache.set("did:plc:ok", false, now)
+
+	if blocked, ok := cache.get("did:plc:spam", now.Add(10*time.Second)); !ok || !blocked {
+		t.Errorf("Expected cached blocked=true, got blocked=%v ok=%v", blocked, ok)
+	}
+	if blocked, ok := cache.get("did:plc:ok", now.Add(10*time.Second)); !ok || blocked {
+		t.Errorf("Expected cached blocked=false, got blocked=%v ok=%v", blocked, ok)
+	}
+	if _, ok := cache.get("did:plc:spam", now.Add(30*time.Second)); ok {
+		t.Error("Expected entry to expire after TTL")
+	}
+
+	// Unblocking overwrites the cached answer immediately
+	cache.set("did:plc:spam", false, now)
+	if blocked, _ := cache.get("did:plc:spam", now); blocked {
+		t.Error("Expected unblock to replace cached answer")
+	}
+}
+
+func TestBlockCacheBounded(t *testing.T) {
+	now := time.Now()
+	cache := newBlockCache(time.Minute)
+
+	for i := 0; i < blockCacheMaxEntries+1; i++ {
+		cache.set(fmt.Sprintf("did:plc:%d", i), false, now)
+	}
+	if n := len(cache.entries); n > blockCacheMaxEntries {
+		t.Errorf("Expected at most %d entries, got %d", blockCacheMaxEntries, n)
+	}
+}
+
+func TestBlockCacheNil(t *testing.T) {
+	// Queries built without NewQueries have no cache; lookups always miss
+	var cache *blockCache
+	cache.set("did:plc:spam", true, time.Now())
+	if _, ok := cache.get("did:plc:spam", time.Now()); ok {
+		t.Error("Expected nil cache to miss")
+	}
+}
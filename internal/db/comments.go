@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// UpsertComment stores a comment, or if its record is already indexed,
+// replaces the text and CID (an edited comment keeps its created_at). On
+// return c.ID is the stored row's ID; inserted reports whether a new row was
+// created.
+func (q *Queries) UpsertComment(ctx context.Context, c *models.Comment) (inserted bool, err error) {
+	query := `
+		INSERT INTO survey_comments (id, survey_id, author_did, record_uri, record_cid, text, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (record_uri) DO UPDATE
+		SET text = EXCLUDED.text,
+		    record_cid = EXCLUDED.record_cid
+		RETURNING id, (xmax = 0)
+	`
+
+	err = q.db.QueryRowContext(ctx, query,
+		c.ID,
+		c.SurveyID,
+		c.AuthorDID,
+		c.RecordURI,
+		c.RecordCID,
+		c.Text,
+		c.CreatedAt,
+	).Scan(&c.ID, &inserted)
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert comment: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// GetCommentByRecordURI retrieves a comment by its ATProto record URI
+// Returns nil (no error) if the comment isn't indexed
+func (q *Queries) GetCommentByRecordURI(ctx context.Context, recordURI string) (*models.Comment, error) {
+	query := `
+		SELECT id, survey_id, author_did, record_uri, record_cid, text, created_at
+		FROM survey_comments
+		WHERE record_uri = $1
+	`
+
+	var c models.Comment
+	err := q.db.QueryRowContext(ctx, query, recordURI).Scan(
+		&c.ID,
+		&c.SurveyID,
+		&c.AuthorDID,
+		&c.RecordURI,
+		&c.RecordCID,
+		&c.Text,
+		&c.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query comment: %w", err)
+	}
+
+	return &c, nil
+}
+
+// ListCommentsBySurvey returns up to limit of a survey's comments, newest
+// first. Comments hidden by an account deactivation are excluded.
+func (q *Queries) ListCommentsBySurvey(ctx context.Context, surveyID uuid.UUID, limit int) ([]*models.Comment, error) {
+	query := `
+		SELECT id, survey_id, author_did, record_uri, record_cid, text, created_at
+		FROM survey_comments
+		WHERE survey_id = $1 AND hidden_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, surveyID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []*models.Comment
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(
+			&c.ID,
+			&c.SurveyID,
+			&c.AuthorDID,
+			&c.RecordURI,
+			&c.RecordCID,
+			&c.Text,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, &c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// DeleteCommentByURI removes a comment by its ATProto record URI. Deleting a
+// comment that isn't indexed is not an error.
+func (q *Queries) DeleteCommentByURI(ctx context.Context, recordURI string) error {
+	query := `DELETE FROM survey_comments WHERE record_uri = $1`
+
+	if _, err := q.db.ExecContext(ctx, query, recordURI); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	return nil
+}
@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/XSAM/otelsql"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -13,6 +16,25 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.28.0"
 )
 
+// Connection pool defaults
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 30 * time.Minute
+	DefaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// Connect retry policy
+const (
+	// DefaultConnectTimeout is how long Connect keeps retrying an unreachable
+	// database
+	DefaultConnectTimeout = 30 * time.Second
+
+	// connectAttemptTimeout bounds a single ping, so a server that accepts
+	// connections but never answers doesn't use up the whole budget
+	connectAttemptTimeout = 5 * time.Second
+)
+
 // Config holds database connection configuration
 type Config struct {
 	Host     string
@@ -20,7 +42,26 @@ type Config struct {
 	User     string
 	Password string
 	Database string
-	SSLMode  string
+
+	// TLS settings, as the libpq parameters of the same names. The root
+	// certificate verifies the server under verify-ca and verify-full (the
+	// system pool is used if it's unset); the certificate and key
+	// authenticate the client.
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	// Connection pool settings; see the database/sql setters of the same
+	// names. Zero means no limit.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectTimeout is how long Connect retries before giving up; zero
+	// means a single attempt
+	ConnectTimeout time.Duration
 }
 
 // ConfigFromEnv creates a Config from environment variables with sensible defaults
@@ -30,7 +71,11 @@ func ConfigFromEnv() (Config, error) {
 		User:     getEnvOrDefault("DATABASE_USER", "postgres"),
 		Password: os.Getenv("DATABASE_PASSWORD"),
 		Database: getEnvOrDefault("DATABASE_NAME", "survey"),
-		SSLMode:  getEnvOrDefault("DATABASE_SSLMODE", "disable"),
+		// DATABASE_SSLMODE predates the DB_SSL* variables
+		SSLMode:     getEnvOrDefault("DB_SSLMODE", getEnvOrDefault("DATABASE_SSLMODE", "disable")),
+		SSLRootCert: os.Getenv("DB_SSLROOTCERT"),
+		SSLCert:     os.Getenv("DB_SSLCERT"),
+		SSLKey:      os.Getenv("DB_SSLKEY"),
 	}
 
 	// Parse port with default
@@ -41,6 +86,23 @@ func ConfigFromEnv() (Config, error) {
 	}
 	cfg.Port = port
 
+	// Parse pool settings with defaults
+	if cfg.MaxOpenConns, err = envInt("DB_MAX_OPEN_CONNS", DefaultMaxOpenConns); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxIdleConns, err = envInt("DB_MAX_IDLE_CONNS", DefaultMaxIdleConns); err != nil {
+		return Config{}, err
+	}
+	if cfg.ConnMaxLifetime, err = envDuration("DB_CONN_MAX_LIFETIME", DefaultConnMaxLifetime); err != nil {
+		return Config{}, err
+	}
+	if cfg.ConnMaxIdleTime, err = envDuration("DB_CONN_MAX_IDLE_TIME", DefaultConnMaxIdleTime); err != nil {
+		return Config{}, err
+	}
+	if cfg.ConnectTimeout, err = envDuration("DB_CONNECT_TIMEOUT", DefaultConnectTimeout); err != nil {
+		return Config{}, err
+	}
+
 	// Validate the config
 	if err := cfg.Validate(); err != nil {
 		return Config{}, err
@@ -57,18 +119,92 @@ func (c Config) Validate() error {
 	if c.Port <= 0 {
 		return fmt.Errorf("port must be positive, got %d", c.Port)
 	}
+	if c.MaxOpenConns < 0 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must not be negative, got %d", c.MaxOpenConns)
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must not be negative, got %d", c.MaxIdleConns)
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	if c.ConnMaxLifetime < 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME must not be negative, got %s", c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("DB_CONN_MAX_IDLE_TIME must not be negative, got %s", c.ConnMaxIdleTime)
+	}
+	if c.ConnectTimeout < 0 {
+		return fmt.Errorf("DB_CONNECT_TIMEOUT must not be negative, got %s", c.ConnectTimeout)
+	}
+	return c.validateTLS()
+}
+
+// sslModes are the sslmode values Postgres accepts
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// validateTLS checks the TLS settings, including that the files they name
+// exist, so a bad mount fails at startup rather than on the first query
+func (c Config) validateTLS() error {
+	if c.SSLMode != "" && !slices.Contains(sslModes, c.SSLMode) {
+		return fmt.Errorf("DB_SSLMODE must be one of %s, got %q", strings.Join(sslModes, ", "), c.SSLMode)
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return fmt.Errorf("DB_SSLCERT and DB_SSLKEY must be set together")
+	}
+	if c.SSLMode == "disable" && (c.SSLRootCert != "" || c.SSLCert != "") {
+		return fmt.Errorf("DB_SSLROOTCERT, DB_SSLCERT and DB_SSLKEY have no effect with DB_SSLMODE=disable")
+	}
+
+	files := []struct{ name, path string }{
+		{"DB_SSLROOTCERT", c.SSLRootCert},
+		{"DB_SSLCERT", c.SSLCert},
+		{"DB_SSLKEY", c.SSLKey},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			if f.name == "DB_SSLROOTCERT" && strings.HasPrefix(c.SSLMode, "verify-") {
+				return fmt.Errorf("DB_SSLMODE=%s needs the CA bundle in DB_SSLROOTCERT, which can't be read: %w", c.SSLMode, err)
+			}
+			return fmt.Errorf("%s can't be read: %w", f.name, err)
+		}
+	}
 	return nil
 }
 
 // ConnectionString returns a PostgreSQL connection string
 func (c Config) ConnectionString() string {
-	return fmt.Sprintf(
+	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.Host, c.Port, c.User, c.Password, c.Database, c.SSLMode,
+		dsnValue(c.Host), c.Port, dsnValue(c.User), dsnValue(c.Password), dsnValue(c.Database), dsnValue(c.SSLMode),
 	)
+	if c.SSLRootCert != "" {
+		dsn += " sslrootcert=" + dsnValue(c.SSLRootCert)
+	}
+	if c.SSLCert != "" {
+		dsn += " sslcert=" + dsnValue(c.SSLCert) + " sslkey=" + dsnValue(c.SSLKey)
+	}
+	return dsn
 }
 
-// Connect establishes a database connection with OpenTelemetry instrumentation
+// dsnValue quotes a connection string value if it contains characters that
+// would otherwise end or break it, such as a space in a file path
+func dsnValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// Connect establishes a database connection with OpenTelemetry instrumentation.
+// A database that isn't reachable yet, say while it restarts during a deploy,
+// is pinged again with backoff for up to cfg.ConnectTimeout. Cancelling ctx
+// stops waiting.
 func Connect(ctx context.Context, cfg Config) (*sql.DB, error) {
 	// Validate config before attempting connection
 	if err := cfg.Validate(); err != nil {
@@ -94,22 +230,52 @@ func Connect(ctx context.Context, cfg Config) (*sql.DB, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	// SetConnMaxIdleTime sets the maximum amount of time a connection may be idle
-	// db.SetConnMaxIdleTime(5 * time.Minute)
-	// SetConnMaxLifetime sets the maximum amount of time a connection may be reused
-	// db.SetConnMaxLifetime(30 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
 	// Verify connection
-	if err := db.PingContext(ctx); err != nil {
+	if err := waitForDatabase(ctx, db.PingContext, cfg.ConnectTimeout, time.Now, sleepContext); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
 	}
 
 	return db, nil
 }
 
+// waitForDatabase calls ping, each attempt limited to connectAttemptTimeout,
+// until it succeeds, timeout has passed or ctx is done. The returned error
+// wraps the last ping failure.
+func waitForDatabase(ctx context.Context, ping func(context.Context) error, timeout time.Duration, now func() time.Time, sleep func(context.Context, time.Duration) bool) error {
+	deadline := now().Add(timeout)
+	delay := RetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, connectAttemptTimeout)
+		err := ping(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("gave up connecting to database: %w (last error: %v)", ctx.Err(), err)
+		}
+		if now().Add(delay).After(deadline) {
+			return fmt.Errorf("failed to ping database after %d attempt(s) in %s: %w", attempt, timeout, err)
+		}
+
+		if !sleep(ctx, delay) {
+			return fmt.Errorf("gave up connecting to database: %w (last error: %v)", ctx.Err(), err)
+		}
+
+		delay *= 2
+		if delay > RetryMaxDelay {
+			delay = RetryMaxDelay
+		}
+	}
+}
+
 // Close closes the database connection
 func Close(db *sql.DB) error {
 	if db == nil {
@@ -125,3 +291,31 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// envInt parses an integer environment variable, returning defaultValue if
+// it is unset
+func envInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// envDuration parses a duration environment variable such as "30m",
+// returning defaultValue if it is unset
+func envDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
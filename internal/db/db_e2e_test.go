@@ -0,0 +1,60 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// TestConnectAppliesPoolSettings checks that Connect configures the pool from
+// Config
+func TestConnectAppliesPoolSettings(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to load database config: %v", err)
+	}
+	cfg.MaxOpenConns = 3
+	cfg.MaxIdleConns = 1
+	cfg.ConnMaxLifetime = time.Minute
+	cfg.ConnMaxIdleTime = time.Minute
+
+	ctx := context.Background()
+	db, err := Connect(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", got)
+	}
+
+	// Check out more connections than may idle, then return them all
+	conns := make([]*sql.Conn, 3)
+	for i := range conns {
+		if conns[i], err = db.Conn(ctx); err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+	}
+	if got := db.Stats().InUse; got != 3 {
+		t.Errorf("InUse = %d, want 3", got)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	stats := db.Stats()
+	if stats.Idle != 1 {
+		t.Errorf("Idle = %d, want 1", stats.Idle)
+	}
+	if stats.MaxIdleClosed < 2 {
+		t.Errorf("MaxIdleClosed = %d, want at least 2", stats.MaxIdleClosed)
+	}
+}
@@ -1,8 +1,15 @@
 package db
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestConfigFromEnv(t *testing.T) {
@@ -105,6 +112,86 @@ func TestConfigFromEnv(t *testing.T) {
 	}
 }
 
+func TestConfigFromEnvPool(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		clearDBEnv()
+		defer clearDBEnv()
+		os.Setenv("DATABASE_PASSWORD", "testpass")
+
+		got, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("ConfigFromEnv() error = %v", err)
+		}
+		if got.MaxOpenConns != DefaultMaxOpenConns || got.MaxIdleConns != DefaultMaxIdleConns ||
+			got.ConnMaxLifetime != DefaultConnMaxLifetime || got.ConnMaxIdleTime != DefaultConnMaxIdleTime {
+			t.Errorf("ConfigFromEnv() pool = %d/%d/%s/%s, want defaults",
+				got.MaxOpenConns, got.MaxIdleConns, got.ConnMaxLifetime, got.ConnMaxIdleTime)
+		}
+		if got.ConnectTimeout != DefaultConnectTimeout {
+			t.Errorf("ConfigFromEnv() ConnectTimeout = %s, want %s", got.ConnectTimeout, DefaultConnectTimeout)
+		}
+	})
+
+	t.Run("reads pool settings", func(t *testing.T) {
+		clearDBEnv()
+		defer clearDBEnv()
+		os.Setenv("DATABASE_PASSWORD", "testpass")
+		os.Setenv("DB_MAX_OPEN_CONNS", "50")
+		os.Setenv("DB_MAX_IDLE_CONNS", "10")
+		os.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+		os.Setenv("DB_CONN_MAX_IDLE_TIME", "90s")
+		os.Setenv("DB_CONNECT_TIMEOUT", "2m")
+
+		got, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("ConfigFromEnv() error = %v", err)
+		}
+		if got.MaxOpenConns != 50 || got.MaxIdleConns != 10 ||
+			got.ConnMaxLifetime != time.Hour || got.ConnMaxIdleTime != 90*time.Second {
+			t.Errorf("ConfigFromEnv() pool = %d/%d/%s/%s", got.MaxOpenConns, got.MaxIdleConns, got.ConnMaxLifetime, got.ConnMaxIdleTime)
+		}
+		if got.ConnectTimeout != 2*time.Minute {
+			t.Errorf("ConfigFromEnv() ConnectTimeout = %s, want 2m", got.ConnectTimeout)
+		}
+	})
+
+	// Each error must name the variable so a bad deploy is easy to fix
+	tests := []struct {
+		name    string
+		envVars map[string]string
+		wantVar string
+	}{
+		{"non-numeric max open", map[string]string{"DB_MAX_OPEN_CONNS": "lots"}, "DB_MAX_OPEN_CONNS"},
+		{"negative max open", map[string]string{"DB_MAX_OPEN_CONNS": "-1"}, "DB_MAX_OPEN_CONNS"},
+		{"non-numeric max idle", map[string]string{"DB_MAX_IDLE_CONNS": "5.5"}, "DB_MAX_IDLE_CONNS"},
+		{"idle above open", map[string]string{"DB_MAX_OPEN_CONNS": "4", "DB_MAX_IDLE_CONNS": "8"}, "DB_MAX_IDLE_CONNS"},
+		{"lifetime without unit", map[string]string{"DB_CONN_MAX_LIFETIME": "30"}, "DB_CONN_MAX_LIFETIME"},
+		{"negative lifetime", map[string]string{"DB_CONN_MAX_LIFETIME": "-5m"}, "DB_CONN_MAX_LIFETIME"},
+		{"bad idle time", map[string]string{"DB_CONN_MAX_IDLE_TIME": "soon"}, "DB_CONN_MAX_IDLE_TIME"},
+		{"bad connect timeout", map[string]string{"DB_CONNECT_TIMEOUT": "30"}, "DB_CONNECT_TIMEOUT"},
+		{"negative connect timeout", map[string]string{"DB_CONNECT_TIMEOUT": "-1s"}, "DB_CONNECT_TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearDBEnv()
+			defer clearDBEnv()
+			os.Setenv("DATABASE_PASSWORD", "testpass")
+			for k, v := range tt.envVars {
+				os.Setenv(k, v)
+			}
+
+			_, err := ConfigFromEnv()
+			if err == nil {
+				t.Fatal("ConfigFromEnv() expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantVar) {
+				t.Errorf("ConfigFromEnv() error = %q, want it to name %s", err, tt.wantVar)
+			}
+		})
+	}
+}
+
 func TestConfigValidate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -197,4 +284,334 @@ func clearDBEnv() {
 	os.Unsetenv("DATABASE_PASSWORD")
 	os.Unsetenv("DATABASE_NAME")
 	os.Unsetenv("DATABASE_SSLMODE")
+	os.Unsetenv("DB_MAX_OPEN_CONNS")
+	os.Unsetenv("DB_MAX_IDLE_CONNS")
+	os.Unsetenv("DB_CONN_MAX_LIFETIME")
+	os.Unsetenv("DB_CONN_MAX_IDLE_TIME")
+	os.Unsetenv("DB_CONNECT_TIMEOUT")
+	os.Unsetenv("DB_SSLMODE")
+	os.Unsetenv("DB_SSLROOTCERT")
+	os.Unsetenv("DB_SSLCERT")
+	os.Unsetenv("DB_SSLKEY")
+}
+
+func TestConfigConnectionStringTLS(t *testing.T) {
+	base := Config{
+		Host:     "db.example.com",
+		Port:     5432,
+		User:     "survey",
+		Password: "secret",
+		Database: "survey",
+	}
+	prefix := "host=db.example.com port=5432 user=survey password=secret dbname=survey "
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"disable", func(c *Config) { c.SSLMode = "disable" }, "sslmode=disable"},
+		{"allow", func(c *Config) { c.SSLMode = "allow" }, "sslmode=allow"},
+		{"prefer", func(c *Config) { c.SSLMode = "prefer" }, "sslmode=prefer"},
+		{"require", func(c *Config) { c.SSLMode = "require" }, "sslmode=require"},
+		{"verify-ca", func(c *Config) {
+			c.SSLMode = "verify-ca"
+			c.SSLRootCert = "/etc/ssl/db/ca.pem"
+		}, "sslmode=verify-ca sslrootcert=/etc/ssl/db/ca.pem"},
+		{"verify-full with client certificate", func(c *Config) {
+			c.SSLMode = "verify-full"
+			c.SSLRootCert = "/etc/ssl/db/ca.pem"
+			c.SSLCert = "/etc/ssl/db/client.pem"
+			c.SSLKey = "/etc/ssl/db/client.key"
+		}, "sslmode=verify-full sslrootcert=/etc/ssl/db/ca.pem sslcert=/etc/ssl/db/client.pem sslkey=/etc/ssl/db/client.key"},
+		{"paths with spaces and quotes", func(c *Config) {
+			c.SSLMode = "verify-full"
+			c.SSLRootCert = `/Users/me/My Certs/ca's.pem`
+		}, `sslmode=verify-full sslrootcert='/Users/me/My Certs/ca\'s.pem'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.modify(&cfg)
+			if got := cfg.ConnectionString(); got != prefix+tt.want {
+				t.Errorf("Config.ConnectionString() = %v, want %v", got, prefix+tt.want)
+			}
+		})
+	}
+
+	t.Run("password needing quotes", func(t *testing.T) {
+		cfg := base
+		cfg.SSLMode = "require"
+		cfg.Password = `p@ss word\`
+		want := `host=db.example.com port=5432 user=survey password='p@ss word\\' dbname=survey sslmode=require`
+		if got := cfg.ConnectionString(); got != want {
+			t.Errorf("Config.ConnectionString() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestConfigValidateTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := dir + "/ca.pem"
+	if err := os.WriteFile(ca, []byte("test"), 0o600); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+	missing := dir + "/missing.pem"
+
+	base := Config{Host: "localhost", Port: 5432, User: "postgres", Password: "secret", Database: "survey"}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"prefer", func(c *Config) { c.SSLMode = "prefer" }, ""},
+		{"unknown mode", func(c *Config) { c.SSLMode = "verify" }, "DB_SSLMODE must be one of"},
+		{"verify-full with CA", func(c *Config) {
+			c.SSLMode = "verify-full"
+			c.SSLRootCert = ca
+		}, ""},
+		{"verify-full with system roots", func(c *Config) { c.SSLMode = "verify-full" }, ""},
+		{"verify-full with missing CA", func(c *Config) {
+			c.SSLMode = "verify-full"
+			c.SSLRootCert = missing
+		}, "DB_SSLMODE=verify-full needs the CA bundle in DB_SSLROOTCERT"},
+		{"missing client key", func(c *Config) {
+			c.SSLMode = "require"
+			c.SSLCert = ca
+			c.SSLKey = missing
+		}, "DB_SSLKEY can't be read"},
+		{"certificate without key", func(c *Config) {
+			c.SSLMode = "require"
+			c.SSLCert = ca
+		}, "DB_SSLCERT and DB_SSLKEY must be set together"},
+		{"files with TLS disabled", func(c *Config) {
+			c.SSLMode = "disable"
+			c.SSLRootCert = ca
+		}, "no effect with DB_SSLMODE=disable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Config.Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Config.Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("all modes", func(t *testing.T) {
+		for _, mode := range []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"} {
+			cfg := base
+			cfg.SSLMode = mode
+			if err := cfg.Validate(); err != nil {
+				t.Errorf("Config.Validate() with %s error = %v", mode, err)
+			}
+		}
+	})
+}
+
+func TestConfigFromEnvTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := dir + "/ca.pem"
+	if err := os.WriteFile(ca, []byte("test"), 0o600); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+
+	t.Run("reads TLS settings", func(t *testing.T) {
+		clearDBEnv()
+		defer clearDBEnv()
+		os.Setenv("DATABASE_PASSWORD", "testpass")
+		os.Setenv("DB_SSLMODE", "verify-full")
+		os.Setenv("DB_SSLROOTCERT", ca)
+
+		got, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("ConfigFromEnv() error = %v", err)
+		}
+		if got.SSLMode != "verify-full" || got.SSLRootCert != ca {
+			t.Errorf("ConfigFromEnv() TLS = %s/%s", got.SSLMode, got.SSLRootCert)
+		}
+	})
+
+	t.Run("DB_SSLMODE overrides DATABASE_SSLMODE", func(t *testing.T) {
+		clearDBEnv()
+		defer clearDBEnv()
+		os.Setenv("DATABASE_PASSWORD", "testpass")
+		os.Setenv("DATABASE_SSLMODE", "require")
+
+		got, err := ConfigFromEnv()
+		if err != nil {
+			t.Fatalf("ConfigFromEnv() error = %v", err)
+		}
+		if got.SSLMode != "require" {
+			t.Errorf("ConfigFromEnv() SSLMode = %s, want the legacy variable", got.SSLMode)
+		}
+
+		os.Setenv("DB_SSLMODE", "verify-ca")
+		if got, err = ConfigFromEnv(); err != nil || got.SSLMode != "verify-ca" {
+			t.Errorf("ConfigFromEnv() SSLMode = %s (%v), want verify-ca", got.SSLMode, err)
+		}
+	})
+
+	t.Run("missing CA fails at startup", func(t *testing.T) {
+		clearDBEnv()
+		defer clearDBEnv()
+		os.Setenv("DATABASE_PASSWORD", "testpass")
+		os.Setenv("DB_SSLMODE", "verify-full")
+		os.Setenv("DB_SSLROOTCERT", dir+"/nope.pem")
+
+		if _, err := ConfigFromEnv(); err == nil || !strings.Contains(err.Error(), "DB_SSLROOTCERT") {
+			t.Errorf("ConfigFromEnv() error = %v, want it to name DB_SSLROOTCERT", err)
+		}
+	})
+}
+
+// dropListener accepts TCP connections and closes them straight away, like a
+// database that is up but not ready
+func dropListener(t *testing.T) (port int, accepted *atomic.Int32) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	accepted = &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, accepted
+}
+
+func TestConnectRetries(t *testing.T) {
+	cfg := Config{
+		Host:     "127.0.0.1",
+		User:     "postgres",
+		Password: "testpass",
+		Database: "survey",
+		SSLMode:  "disable",
+	}
+
+	t.Run("gives up after the connect timeout", func(t *testing.T) {
+		port, accepted := dropListener(t)
+		cfg := cfg
+		cfg.Port = port
+		cfg.ConnectTimeout = 500 * time.Millisecond
+
+		start := time.Now()
+		database, err := Connect(context.Background(), cfg)
+		elapsed := time.Since(start)
+
+		if err == nil {
+			database.Close()
+			t.Fatal("Connect() expected an error")
+		}
+		if !strings.Contains(err.Error(), "failed to ping database after") {
+			t.Errorf("Connect() error = %q, want it to report the attempts", err)
+		}
+		if elapsed > 5*time.Second {
+			t.Errorf("Connect() took %s, want it to stop near the timeout", elapsed)
+		}
+		if accepted.Load() < 2 {
+			t.Errorf("Expected several connection attempts, got %d", accepted.Load())
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		port, _ := dropListener(t)
+		cfg := cfg
+		cfg.Port = port
+		cfg.ConnectTimeout = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		database, err := Connect(ctx, cfg)
+		if err == nil {
+			database.Close()
+			t.Fatal("Connect() expected an error")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Connect() error = %v, want the context's error", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Connect() took %s after cancellation", elapsed)
+		}
+	})
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	t.Run("retries until the database answers", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		attempts := 0
+		err := waitForDatabase(context.Background(), func(ctx context.Context) error {
+			attempts++
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("Expected each ping to have a deadline")
+			}
+			if attempts < 3 {
+				return refused
+			}
+			return nil
+		}, time.Minute, clock.Now, clock.Sleep)
+
+		if err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+		want := []time.Duration{RetryBaseDelay, 2 * RetryBaseDelay}
+		if fmt.Sprint(clock.sleeps) != fmt.Sprint(want) {
+			t.Errorf("Expected backoff %v, got %v", want, clock.sleeps)
+		}
+	})
+
+	t.Run("wraps the last failure at the deadline", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		attempts := 0
+		err := waitForDatabase(context.Background(), func(context.Context) error {
+			attempts++
+			return fmt.Errorf("attempt %d: %w", attempts, refused)
+		}, time.Second, clock.Now, clock.Sleep)
+
+		if !errors.Is(err, refused) {
+			t.Errorf("Expected the ping error, got %v", err)
+		}
+		// 50+100+200+400ms fits in a second; the next 800ms wait would not
+		if attempts != 5 || !strings.Contains(err.Error(), "attempt 5") {
+			t.Errorf("Expected 5 attempts ending with the last, got %d: %v", attempts, err)
+		}
+	})
+
+	t.Run("zero timeout tries once", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		attempts := 0
+		err := waitForDatabase(context.Background(), func(context.Context) error {
+			attempts++
+			return refused
+		}, 0, clock.Now, clock.Sleep)
+
+		if !errors.Is(err, refused) || attempts != 1 {
+			t.Errorf("Expected one failed attempt, got %d: %v", attempts, err)
+		}
+	})
 }
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IdentityChannel is the Postgres NOTIFY channel the consumer uses to tell
+// API servers that a tracked identity changed. The payload is the DID,
+// followed by a space and the new handle when the event carried one.
+const IdentityChannel = "identity_changed"
+
+// IdentityCache is a cache of identity resolutions that
+// ListenForIdentityChanges keeps current. *oauth.Resolver implements it.
+type IdentityCache interface {
+	Invalidate(did, handle string) // drops resolutions of did and handle
+	Purge()                        // drops every resolution
+}
+
+// NotifyIdentityChange tells every process listening on IdentityChannel that
+// did's identity changed; handle is its new handle, or empty if unknown.
+// Inside a transaction the notification is only sent when it commits.
+func (q *Queries) NotifyIdentityChange(ctx context.Context, did, handle string) error {
+	payload := did
+	if handle != "" {
+		payload += " " + handle
+	}
+	if _, err := q.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, IdentityChannel, payload); err != nil {
+		return fmt.Errorf("failed to notify identity change: %w", err)
+	}
+	return nil
+}
+
+// ListenForIdentityChanges applies identity changes announced by the consumer
+// to cache until ctx is cancelled. It holds its own connection, reconnecting
+// after errors; the cache is purged on every (re)connect since notifications
+// sent while disconnected are lost.
+func ListenForIdentityChanges(ctx context.Context, cfg Config, cache IdentityCache) {
+	listen(ctx, cfg, IdentityChannel, cache.Purge, func(payload string) {
+		did, handle, _ := strings.Cut(payload, " ")
+		cache.Invalidate(did, handle)
+	})
+}
+
+// UpsertHandle stores the current handle for a DID
+func (q *Queries) UpsertHandle(ctx context.Context, did, handle string) error {
+	query := `
+		INSERT INTO handles (did, handle, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (did) DO UPDATE SET handle = EXCLUDED.handle, updated_at = NOW()
+	`
+
+	if _, err := q.db.ExecContext(ctx, query, did, handle); err != nil {
+		return fmt.Errorf("failed to upsert handle: %w", err)
+	}
+
+	return nil
+}
+
+// GetHandle returns the stored handle for a DID
+// Returns empty string (no error) if no handle is known
+func (q *Queries) GetHandle(ctx context.Context, did string) (string, error) {
+	query := `SELECT handle FROM handles WHERE did = $1`
+
+	var handle string
+	err := q.db.QueryRowContext(ctx, query, did).Scan(&handle)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get handle: %w", err)
+	}
+
+	return handle, nil
+}
+
+// GetHandles returns the stored handle for each of dids, for listings that
+// show many authors. DIDs without a known handle are missing from the result.
+func (q *Queries) GetHandles(ctx context.Context, dids []string) (map[string]string, error) {
+	handles := make(map[string]string, len(dids))
+	if len(dids) == 0 {
+		return handles, nil
+	}
+
+	placeholders := make([]string, len(dids))
+	args := make([]interface{}, len(dids))
+	for i, did := range dids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = did
+	}
+	query := `SELECT did, handle FROM handles WHERE did IN (` + strings.Join(placeholders, ", ") + `)`
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query handles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var did, handle string
+		if err := rows.Scan(&did, &handle); err != nil {
+			return nil, fmt.Errorf("failed to scan handle: %w", err)
+		}
+		handles[did] = handle
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating handles: %w", err)
+	}
+
+	return handles, nil
+}
+
+// GetDIDForHandle returns the DID most recently stored for a handle
+// Returns empty string (no error) if the handle is unknown
+func (q *Queries) GetDIDForHandle(ctx context.Context, handle string) (string, error) {
+	query := `SELECT did FROM handles WHERE handle = $1 ORDER BY updated_at DESC LIMIT 1`
+
+	var did string
+	err := q.db.QueryRowContext(ctx, query, handle).Scan(&did)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get DID for handle: %w", err)
+	}
+
+	return did, nil
+}
+
+// IsTrackedDID reports whether we keep a handle for a DID: either we've stored
+// one before or the DID has authored a survey
+func (q *Queries) IsTrackedDID(ctx context.Context, did string) (bool, error) {
+	query := `
+		SELECT EXISTS(SELECT 1 FROM handles WHERE did = $1)
+			OR EXISTS(SELECT 1 FROM surveys WHERE author_did = $1)
+	`
+
+	var tracked bool
+	if err := q.db.QueryRowContext(ctx, query, did).Scan(&tracked); err != nil {
+		return false, fmt.Errorf("failed to check tracked DID: %w", err)
+	}
+
+	return tracked, nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+)
+
+func TestGetHandlesFake(t *testing.T) {
+	t.Run("maps known DIDs to handles", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM handles").Rows([]string{"did", "handle"},
+			[]interface{}{"did:plc:alice", "alice.bsky.social"},
+		)
+
+		handles, err := NewQueries(fake).GetHandles(context.Background(), []string{"did:plc:alice", "did:plc:bob"})
+		if err != nil {
+			t.Fatalf("GetHandles failed: %v", err)
+		}
+		if len(handles) != 1 || handles["did:plc:alice"] != "alice.bsky.social" {
+			t.Errorf("Expected only alice's handle, got %v", handles)
+		}
+
+		call := fake.Calls()[0]
+		if !strings.Contains(call.Query, "did IN ($1, $2)") || len(call.Args) != 2 {
+			t.Errorf("Unexpected query %s with %v", call.Query, call.Args)
+		}
+	})
+
+	t.Run("no DIDs, no query", func(t *testing.T) {
+		fake := queriestest.New(t)
+		handles, err := NewQueries(fake).GetHandles(context.Background(), nil)
+		if err != nil || len(handles) != 0 || len(fake.Calls()) != 0 {
+			t.Errorf("Expected an empty map without querying, got %v, %v", handles, err)
+		}
+	})
+}
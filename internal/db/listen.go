@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// listenRetry is how long a listener waits before reconnecting
+const listenRetry = 5 * time.Second
+
+// listen passes the payload of each notification sent on channel to onNotify
+// until ctx is cancelled. It holds its own connection, reconnecting after
+// errors, and calls onConnect on every (re)connect since notifications sent
+// while disconnected are lost.
+func listen(ctx context.Context, cfg Config, channel string, onConnect func(), onNotify func(payload string)) {
+	for {
+		err := listenOnce(ctx, cfg, channel, onConnect, onNotify)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Listener on %s failed, retrying in %s: %v", channel, listenRetry, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetry):
+		}
+	}
+}
+
+// listenOnce listens on channel over a single connection until it fails
+func listenOnce(ctx context.Context, cfg Config, channel string, onConnect func(), onNotify func(payload string)) error {
+	conn, err := pgx.Connect(ctx, cfg.ConnectionString())
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	onConnect()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		onNotify(notification.Payload)
+	}
+}
@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ResponsesChannel is the NOTIFY channel announcing that a survey's
+	// responses changed; the payload is the survey's URI
+	ResponsesChannel = "survey_responses"
+
+	// DefaultListenerFallback is how often Listener wakes every subscriber
+	// regardless of notifications, bounding how stale a missed one leaves them
+	DefaultListenerFallback = 30 * time.Second
+)
+
+// NotifySurveyResponses announces on ResponsesChannel that the responses of
+// the survey with the given ID changed. Inside a transaction Postgres holds
+// the notification until commit and drops it on rollback, so listeners never
+// hear about writes that didn't happen. Surveys without a URI aren't
+// announced.
+func (q *Queries) NotifySurveyResponses(ctx context.Context, surveyID uuid.UUID) error {
+	query := `SELECT pg_notify($1, uri) FROM surveys WHERE id = $2 AND uri IS NOT NULL`
+
+	rows, err := q.db.QueryContext(ctx, query, ResponsesChannel, surveyID)
+	if err != nil {
+		return fmt.Errorf("failed to notify survey responses: %w", err)
+	}
+	return rows.Close()
+}
+
+// Listener fans notifications on ResponsesChannel out to subscribers keyed by
+// survey URI. It holds its own connection, reconnecting when it drops (see
+// listen). Notifications sent while disconnected are lost, so on every
+// (re)connect, and every fallback interval, every subscriber is woken as if
+// its survey changed.
+type Listener struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan struct{}]struct{}
+	closed bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewListener listens on ResponsesChannel of the database described by cfg,
+// connecting in the background. fallback is the interval at which every
+// subscriber is woken regardless; zero uses DefaultListenerFallback.
+func NewListener(cfg Config, fallback time.Duration) *Listener {
+	return newListener(func(ctx context.Context, onConnect func(), onNotify func(payload string)) {
+		listen(ctx, cfg, ResponsesChannel, onConnect, onNotify)
+	}, fallback)
+}
+
+// newListener starts dispatching the notifications run delivers until Close.
+// run behaves like listen: it calls onConnect on every (re)connect and
+// onNotify for each notification until ctx is cancelled.
+func newListener(run func(ctx context.Context, onConnect func(), onNotify func(payload string)), fallback time.Duration) *Listener {
+	if fallback <= 0 {
+		fallback = DefaultListenerFallback
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listener{
+		subs:   make(map[string]map[chan struct{}]struct{}),
+		cancel: cancel,
+	}
+
+	l.wg.Add(2)
+	go func() {
+		defer l.wg.Done()
+		run(ctx, l.wakeAll, l.wake)
+	}()
+	go func() {
+		defer l.wg.Done()
+		l.tick(ctx, fallback)
+	}()
+	return l
+}
+
+// Subscribe returns a channel that receives a value whenever the responses of
+// the survey at uri may have changed, and a function that ends the
+// subscription. Signals are coalesced: a subscriber that is slow to receive
+// sees one signal for any number of changes.
+func (l *Listener) Subscribe(uri string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		signal(ch)
+		return ch, func() {}
+	}
+	if l.subs[uri] == nil {
+		l.subs[uri] = make(map[chan struct{}]struct{})
+	}
+	l.subs[uri][ch] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			delete(l.subs[uri], ch)
+			if len(l.subs[uri]) == 0 {
+				delete(l.subs, uri)
+			}
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Close stops listening and closes the listener's connection. Every
+// subscriber is woken one last time so nothing waits on it past shutdown, and
+// later subscriptions are signalled at once. Subscriber channels aren't
+// closed.
+func (l *Listener) Close() error {
+	l.cancel()
+	l.wg.Wait()
+
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	l.wakeAll()
+
+	return nil
+}
+
+// tick wakes every subscriber each fallback interval until ctx is cancelled
+func (l *Listener) tick(ctx context.Context, fallback time.Duration) {
+	ticker := time.NewTicker(fallback)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.wakeAll()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// wake signals the subscribers of one survey
+func (l *Listener) wake(uri string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.subs[uri] {
+		signal(ch)
+	}
+}
+
+// wakeAll signals every subscriber
+func (l *Listener) wakeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, subs := range l.subs {
+		for ch := range subs {
+			signal(ch)
+		}
+	}
+}
+
+// signal sends to ch unless a signal is already pending
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,114 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestListenerReceivesCommittedNotifications notifies on one connection and
+// listens on another
+func TestListenerReceivesCommittedNotifications(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := NewQueries(db)
+
+	uri := "at://did:plc:listen" + uuid.NewString()[:8] + "/net.openmeet.survey/1"
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		URI:   &uri,
+		Slug:  "listen-" + uuid.NewString()[:8],
+		Title: "Live results",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{{
+				ID:      "q1",
+				Text:    "Pick one",
+				Type:    models.QuestionTypeSingle,
+				Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+			}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := queries.CreateSurvey(ctx, survey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	defer db.Exec(`DELETE FROM surveys WHERE id = $1`, survey.ID)
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to load database config: %v", err)
+	}
+	listener := NewListener(cfg, time.Hour)
+	defer listener.Close()
+
+	updates, unsubscribe := listener.Subscribe(uri)
+	defer unsubscribe()
+
+	// Connecting wakes every subscriber; once it has, notifications are heard
+	select {
+	case <-updates:
+	case <-time.After(2 * time.Second):
+	}
+
+	expectSignal := func(want bool) {
+		t.Helper()
+		select {
+		case <-updates:
+			if !want {
+				t.Error("Expected no notification")
+			}
+		case <-time.After(500 * time.Millisecond):
+			if want {
+				t.Error("Expected a notification")
+			}
+		}
+	}
+
+	t.Run("rolled back transaction sends nothing", func(t *testing.T) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		if err := queries.withDB(tx).NotifySurveyResponses(ctx, survey.ID); err != nil {
+			t.Fatalf("NotifySurveyResponses failed: %v", err)
+		}
+		tx.Rollback()
+		expectSignal(false)
+	})
+
+	t.Run("committed transaction notifies after commit", func(t *testing.T) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		if err := queries.withDB(tx).NotifySurveyResponses(ctx, survey.ID); err != nil {
+			t.Fatalf("NotifySurveyResponses failed: %v", err)
+		}
+		expectSignal(false)
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		expectSignal(true)
+	})
+
+	t.Run("other surveys don't wake the subscriber", func(t *testing.T) {
+		other := uri + "-other"
+		if _, err := db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, ResponsesChannel, other); err != nil {
+			t.Fatalf("pg_notify failed: %v", err)
+		}
+		expectSignal(false)
+	})
+}
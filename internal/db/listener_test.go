@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// received reports whether ch has a pending signal
+func received(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitFor waits for a signal on ch, failing after a second
+func waitFor(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a signal")
+	}
+}
+
+// testListen is a fake listen loop: each value sent on notify is delivered
+// as a notification, and each value sent on connects as a reconnect
+type testListen struct {
+	notify   chan string
+	connects chan struct{}
+}
+
+func (f testListen) run(ctx context.Context, onConnect func(), onNotify func(payload string)) {
+	for {
+		select {
+		case payload := <-f.notify:
+			onNotify(payload)
+		case <-f.connects:
+			onConnect()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func newTestListener(t *testing.T, fallback time.Duration) (*Listener, testListen) {
+	t.Helper()
+	fake := testListen{notify: make(chan string), connects: make(chan struct{})}
+	l := newListener(fake.run, fallback)
+	t.Cleanup(func() { l.Close() })
+	return l, fake
+}
+
+func TestListenerFansOutBySurvey(t *testing.T) {
+	l, fake := newTestListener(t, time.Hour)
+
+	a1, _ := l.Subscribe("at://did:plc:a/net.openmeet.survey/1")
+	a2, _ := l.Subscribe("at://did:plc:a/net.openmeet.survey/1")
+	b, _ := l.Subscribe("at://did:plc:b/net.openmeet.survey/2")
+
+	fake.notify <- "at://did:plc:a/net.openmeet.survey/1"
+	waitFor(t, a1)
+	waitFor(t, a2)
+
+	// The send above is unbuffered, so another round trip orders the check
+	fake.notify <- "at://did:plc:unknown/net.openmeet.survey/3"
+	if received(b) {
+		t.Error("Expected other surveys' subscribers not to be woken")
+	}
+}
+
+func TestListenerCoalescesSignals(t *testing.T) {
+	l, fake := newTestListener(t, time.Hour)
+
+	ch, _ := l.Subscribe("at://survey")
+	for i := 0; i < 5; i++ {
+		fake.notify <- "at://survey"
+	}
+	fake.notify <- "at://other"
+
+	if !received(ch) {
+		t.Fatal("Expected a signal")
+	}
+	if received(ch) {
+		t.Error("Expected pending signals to coalesce into one")
+	}
+}
+
+func TestListenerUnsubscribe(t *testing.T) {
+	l, fake := newTestListener(t, time.Hour)
+
+	ch, unsubscribe := l.Subscribe("at://survey")
+	unsubscribe()
+	unsubscribe() // safe to call twice
+
+	fake.notify <- "at://survey"
+	fake.notify <- "at://other"
+	if received(ch) {
+		t.Error("Expected no signal after unsubscribing")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.subs) != 0 {
+		t.Errorf("Expected subscriptions to be cleaned up, got %v", l.subs)
+	}
+}
+
+func TestListenerWakesAllOnReconnect(t *testing.T) {
+	l, fake := newTestListener(t, time.Hour)
+
+	a, _ := l.Subscribe("at://a")
+	b, _ := l.Subscribe("at://b")
+
+	// Notifications may have been missed while disconnected
+	fake.connects <- struct{}{}
+	waitFor(t, a)
+	waitFor(t, b)
+}
+
+func TestListenerFallbackTicker(t *testing.T) {
+	l, _ := newTestListener(t, 10*time.Millisecond)
+
+	ch, _ := l.Subscribe("at://survey")
+	waitFor(t, ch)
+}
+
+func TestListenerCloseWakesSubscribers(t *testing.T) {
+	l := newListener(testListen{}.run, time.Hour)
+
+	before, _ := l.Subscribe("at://survey")
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	waitFor(t, before)
+
+	after, unsubscribe := l.Subscribe("at://survey")
+	defer unsubscribe()
+	waitFor(t, after)
+}
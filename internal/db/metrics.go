@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Tables labelled on the write metrics
+const (
+	TableSurveys   = "surveys"
+	TableResponses = "responses"
+	TableCursor    = "jetstream_cursor"
+)
+
+// Error classes for DBWriteErrors
+const (
+	ErrorClassConstraint = "constraint"
+	ErrorClassTimeout    = "timeout"
+	ErrorClassConnection = "connection"
+	ErrorClassOther      = "other"
+)
+
+// The names keep the consumer prefix they were introduced under; the API
+// server records into the same series when it writes through these helpers.
+var (
+	// DBWriteDuration tracks time spent in database write statements
+	DBWriteDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "survey_consumer_db_write_duration_seconds",
+			Help:    "Time to execute a database write",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"table"},
+	)
+
+	// DBWriteErrors counts failed database writes by cause
+	DBWriteErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_db_errors_total",
+			Help: "Total number of database writes that returned an error",
+		},
+		[]string{"table", "class"}, // class: constraint, timeout, connection, other
+	)
+)
+
+// ObserveWrite runs fn, recording its duration against table and counting
+// any error it returns. The error is passed through unchanged.
+func ObserveWrite(table string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	DBWriteDuration.WithLabelValues(table).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DBWriteErrors.WithLabelValues(table, classifyError(err)).Inc()
+	}
+	return err
+}
+
+// classifyError buckets a write error into one of the ErrorClass values
+func classifyError(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "23"): // integrity_constraint_violation
+			return ErrorClassConstraint
+		case pgErr.Code == "57014": // query_canceled, raised by statement_timeout
+			return ErrorClassTimeout
+		case strings.HasPrefix(pgErr.Code, "08"), // connection_exception
+			pgErr.Code == "57P01", pgErr.Code == "57P02", pgErr.Code == "57P03": // server shutting down
+			return ErrorClassConnection
+		}
+		return ErrorClassOther
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrorClassTimeout
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassConnection
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassOther
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveWrite(t *testing.T) {
+	// A table label of its own keeps counts independent of other tests
+	const table = "test_observe_write"
+
+	if err := ObserveWrite(table, func() error { return nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := testutil.CollectAndCount(DBWriteDuration, "survey_consumer_db_write_duration_seconds"); got == 0 {
+		t.Fatal("Expected a duration series after a write")
+	}
+	if got := testutil.ToFloat64(DBWriteErrors.WithLabelValues(table, ErrorClassConstraint)); got != 0 {
+		t.Errorf("Expected no errors after a successful write, got %v", got)
+	}
+
+	uniqueViolation := fmt.Errorf("failed to insert: %w", &pgconn.PgError{Code: "23505"})
+	err := ObserveWrite(table, func() error { return uniqueViolation })
+	if !errors.Is(err, uniqueViolation) {
+		t.Errorf("Expected error to pass through, got %v", err)
+	}
+	if got := testutil.ToFloat64(DBWriteErrors.WithLabelValues(table, ErrorClassConstraint)); got != 1 {
+		t.Errorf("Expected 1 constraint error, got %v", got)
+	}
+
+	ObserveWrite(table, func() error { return context.DeadlineExceeded })
+	if got := testutil.ToFloat64(DBWriteErrors.WithLabelValues(table, ErrorClassTimeout)); got != 1 {
+		t.Errorf("Expected 1 timeout error, got %v", got)
+	}
+}
+
+type timeoutError struct{ timeout bool }
+
+func (e timeoutError) Error() string   { return "network error" }
+func (e timeoutError) Timeout() bool   { return e.timeout }
+func (e timeoutError) Temporary() bool { return false }
+
+var _ net.Error = timeoutError{}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505"}, ErrorClassConstraint},
+		{"foreign key violation", &pgconn.PgError{Code: "23503"}, ErrorClassConstraint},
+		{"statement timeout", &pgconn.PgError{Code: "57014"}, ErrorClassTimeout},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, ErrorClassConnection},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, ErrorClassConnection},
+		{"syntax error", &pgconn.PgError{Code: "42601"}, ErrorClassOther},
+		{"wrapped pg error", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23514"}), ErrorClassConstraint},
+		{"context deadline", context.DeadlineExceeded, ErrorClassTimeout},
+		{"context canceled", fmt.Errorf("query: %w", context.Canceled), ErrorClassTimeout},
+		{"network timeout", timeoutError{timeout: true}, ErrorClassTimeout},
+		{"network error", timeoutError{timeout: false}, ErrorClassConnection},
+		{"bad conn", driver.ErrBadConn, ErrorClassConnection},
+		{"unexpected eof", io.ErrUnexpectedEOF, ErrorClassConnection},
+		{"anything else", errors.New("boom"), ErrorClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openmeet-team/survey/internal/db/migrations"
+)
+
+// migrateLockID is the Postgres advisory lock held while migrating, so the
+// API and consumer starting together don't both apply the same migration
+const migrateLockID = 7_241_962_330
+
+// migration is one up migration file
+type migration struct {
+	version int64
+	name    string
+	sql     string
+}
+
+// Migrate applies the embedded up migrations that haven't been applied yet,
+// in version order, each in its own transaction. It records progress in the
+// schema_migrations table golang-migrate uses, so databases migrated with
+// `make migrate-up` pick up where they left off and running it again is a
+// no-op. A database left dirty by a failed golang-migrate run is refused.
+func Migrate(ctx context.Context, database *sql.DB) error {
+	pending, err := loadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	// Advisory locks belong to a session, so everything runs on one connection
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migrations: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrateLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrateLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int64
+	var dirty bool
+	err = conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&current, &dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty; fix the schema by hand, then run make migrate-force VERSION=%d", current, current)
+	}
+
+	for _, m := range pending {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %s", m.name)
+	}
+
+	return nil
+}
+
+// applyMigration runs m and records its version in one transaction
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s: %w", m.name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, m.version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.name, err)
+	}
+	return nil
+}
+
+// loadMigrations reads the up migrations in fsys, sorted by version
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	names, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	var loaded []migration
+	seen := make(map[int64]string, len(names))
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(path.Base(name), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must start with a version and an underscore", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, prefix)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		contents, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		loaded = append(loaded, migration{version: version, name: name, sql: string(contents)})
+	}
+
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].version < loaded[j].version })
+	return loaded, nil
+}
+
+// AutoMigrateFromEnv reports whether DB_AUTO_MIGRATE asks the binaries to
+// apply migrations at startup. It is off by default.
+func AutoMigrateFromEnv() (bool, error) {
+	value := os.Getenv("DB_AUTO_MIGRATE")
+	if value == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid DB_AUTO_MIGRATE: %w", err)
+	}
+	return enabled, nil
+}
@@ -0,0 +1,103 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	testcontainers "github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/openmeet-team/survey/internal/db/migrations"
+)
+
+// TestMigrateFreshDatabase applies every migration to an empty Postgres
+func TestMigrateFreshDatabase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping container test in short mode")
+	}
+
+	ctx := context.Background()
+
+	postgresC, err := postgres.Run(ctx,
+		"postgres:16-alpine",
+		postgres.WithDatabase("survey_migrate_test"),
+		postgres.WithUsername("test"),
+		postgres.WithPassword("test"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(30*time.Second)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL container: %v", err)
+	}
+	defer func() {
+		if err := postgresC.Terminate(ctx); err != nil {
+			t.Logf("Failed to terminate container: %v", err)
+		}
+	}()
+
+	connStr, err := postgresC.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to get connection string: %v", err)
+	}
+	database, err := sql.Open("pgx", connStr)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	if err := Migrate(ctx, database); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	for _, table := range []string{"jetstream_cursor", "surveys", "responses", "ai_generation_logs", "oauth_sessions"} {
+		var exists bool
+		if err := database.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+			t.Fatalf("Failed to look up %s: %v", table, err)
+		}
+		if !exists {
+			t.Errorf("Expected table %s to exist", table)
+		}
+	}
+
+	var timeUs int64
+	if err := database.QueryRowContext(ctx, `SELECT time_us FROM jetstream_cursor WHERE id = 1`).Scan(&timeUs); err != nil {
+		t.Fatalf("Expected the cursor seed row: %v", err)
+	}
+	if timeUs != 0 {
+		t.Errorf("Expected seeded cursor 0, got %d", timeUs)
+	}
+
+	loaded, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	latest := loaded[len(loaded)-1].version
+
+	var version int64
+	var dirty bool
+	if err := database.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations`).Scan(&version, &dirty); err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if version != latest || dirty {
+		t.Errorf("Expected clean version %d, got %d (dirty=%v)", latest, version, dirty)
+	}
+
+	// Running again applies nothing, so the seed row isn't inserted twice
+	if err := Migrate(ctx, database); err != nil {
+		t.Fatalf("Second Migrate failed: %v", err)
+	}
+	var cursors int
+	if err := database.QueryRowContext(ctx, `SELECT COUNT(*) FROM jetstream_cursor`).Scan(&cursors); err != nil {
+		t.Fatalf("Failed to count cursor rows: %v", err)
+	}
+	if cursors != 1 {
+		t.Errorf("Expected 1 cursor row, got %d", cursors)
+	}
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/openmeet-team/survey/internal/db/migrations"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	loaded, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+	if len(loaded) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+
+	for i, m := range loaded {
+		if m.version != int64(i+1) {
+			t.Errorf("Expected version %d at position %d, got %d (%s)", i+1, i, m.version, m.name)
+		}
+		if strings.TrimSpace(m.sql) == "" {
+			t.Errorf("Migration %s is empty", m.name)
+		}
+
+		// Every up migration keeps its down migration for make migrate-down
+		down := strings.TrimSuffix(m.name, ".up.sql") + ".down.sql"
+		if _, err := fs.Stat(migrations.FS, down); err != nil {
+			t.Errorf("Missing %s", down)
+		}
+	}
+
+	if !strings.Contains(loaded[0].sql, "INSERT INTO jetstream_cursor (id, time_us) VALUES (1, 0)") {
+		t.Error("Expected the first migration to seed the cursor row")
+	}
+}
+
+func TestLoadMigrationsOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"010_ten.up.sql":   {Data: []byte("SELECT 10")},
+		"002_two.up.sql":   {Data: []byte("SELECT 2")},
+		"002_two.down.sql": {Data: []byte("SELECT -2")},
+		"001_one.up.sql":   {Data: []byte("SELECT 1")},
+		"README.md":        {Data: []byte("not a migration")},
+	}
+
+	loaded, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+
+	var versions []int64
+	for _, m := range loaded {
+		versions = append(versions, m.version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 10 {
+		t.Errorf("Expected versions [1 2 10], got %v", versions)
+	}
+}
+
+func TestLoadMigrationsRejectsBadNames(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"duplicate version": {
+			"001_one.up.sql":   {Data: []byte("SELECT 1")},
+			"001_other.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"no version": {
+			"initial.up.sql": {Data: []byte("SELECT 1")},
+		},
+		"non-numeric version": {
+			"one_initial.up.sql": {Data: []byte("SELECT 1")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadMigrations(fsys); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestAutoMigrateFromEnv(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"true", true, false},
+		{"1", true, false},
+		{"false", false, false},
+		{"yes please", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("DB_AUTO_MIGRATE", tt.value)
+			got, err := AutoMigrateFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+-- Remove definition_version column from surveys
+
+ALTER TABLE surveys
+DROP COLUMN definition_version;
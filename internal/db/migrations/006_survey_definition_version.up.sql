@@ -0,0 +1,7 @@
+-- Add definition_version to surveys
+-- Bumped when an edit removes questions that already have answers, so the
+-- results page knows stored responses may reference questions no longer in
+-- the definition
+
+ALTER TABLE surveys
+ADD COLUMN definition_version INTEGER NOT NULL DEFAULT 1;
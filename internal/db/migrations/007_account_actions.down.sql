@@ -0,0 +1,12 @@
+-- Remove account lifecycle handling
+
+DROP TABLE IF EXISTS account_actions;
+
+DROP INDEX IF EXISTS idx_responses_voter_did;
+DROP INDEX IF EXISTS idx_surveys_author_did;
+
+ALTER TABLE responses
+DROP COLUMN hidden_at;
+
+ALTER TABLE surveys
+DROP COLUMN hidden_at;
@@ -0,0 +1,27 @@
+-- Account lifecycle handling
+-- Jetstream account events tell us when a DID is deactivated or deleted.
+-- Deactivation hides that DID's surveys and responses; deletion purges them.
+
+ALTER TABLE surveys
+ADD COLUMN hidden_at TIMESTAMPTZ;
+
+ALTER TABLE responses
+ADD COLUMN hidden_at TIMESTAMPTZ;
+
+-- Indexes for finding a DID's records when an account event arrives
+CREATE INDEX idx_surveys_author_did ON surveys(author_did) WHERE author_did IS NOT NULL;
+CREATE INDEX idx_responses_voter_did ON responses(voter_did) WHERE voter_did IS NOT NULL;
+
+-- Audit trail of hide/unhide/purge actions taken for account events
+CREATE TABLE account_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    did TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('hide', 'unhide', 'purge')),
+    status TEXT, -- account status from the event (e.g. deactivated, deleted)
+    surveys_affected INT NOT NULL DEFAULT 0,
+    responses_affected INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Index for looking up actions for a DID
+CREATE INDEX idx_account_actions_did ON account_actions(did);
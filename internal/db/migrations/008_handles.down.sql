@@ -0,0 +1,3 @@
+-- Remove DID -> handle mapping
+
+DROP TABLE IF EXISTS handles;
@@ -0,0 +1,9 @@
+-- DID -> handle mapping
+-- Kept current from Jetstream identity events so survey pages show the
+-- author's current handle rather than one captured at ingestion time
+
+CREATE TABLE handles (
+    did TEXT PRIMARY KEY,
+    handle TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,11 @@
+-- Remove response deduplication key
+-- Fails if a survey allowing multiple responses has several rows per DID
+
+DROP INDEX IF EXISTS idx_responses_survey_dedup_key;
+
+CREATE UNIQUE INDEX idx_responses_survey_voter_did
+    ON responses(survey_id, voter_did)
+    WHERE voter_did IS NOT NULL;
+
+ALTER TABLE responses
+DROP COLUMN dedup_key;
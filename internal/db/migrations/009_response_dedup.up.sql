@@ -0,0 +1,28 @@
+-- Response deduplication key
+-- ATProto voters can publish more than one response record to a survey.
+-- Responses are keyed by (survey_id, dedup_key) so a later record replaces
+-- the earlier answers instead of being counted twice. See
+-- models.ResponseDedupKey for how the key is built. Web guests keep using
+-- voter_session and leave dedup_key NULL.
+
+ALTER TABLE responses
+ADD COLUMN dedup_key TEXT;
+
+-- Backfill existing ATProto responses: anonymous surveys use a per-survey
+-- salted hash of the DID, everything else the DID itself
+UPDATE responses r
+SET dedup_key = CASE
+    WHEN (s.definition->>'anonymous')::boolean IS TRUE
+        THEN 'anon:' || encode(sha256((r.survey_id::text || ':' || r.voter_did)::bytea), 'hex')
+    ELSE 'did:' || r.voter_did
+END
+FROM surveys s
+WHERE s.id = r.survey_id AND r.voter_did IS NOT NULL;
+
+-- Surveys that allow multiple responses may hold several rows per DID, so
+-- uniqueness moves from (survey_id, voter_did) to the dedup key
+DROP INDEX IF EXISTS idx_responses_survey_voter_did;
+
+CREATE UNIQUE INDEX idx_responses_survey_dedup_key
+    ON responses(survey_id, dedup_key)
+    WHERE dedup_key IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Remove published results snapshots
+
+DROP TABLE IF EXISTS published_results;
@@ -0,0 +1,14 @@
+-- Published results snapshots
+-- Tallies from net.openmeet.survey.results records, keyed by the survey they
+-- describe so the results page can show the author's official numbers next
+-- to live counts. Only the latest snapshot per survey is kept.
+
+CREATE TABLE published_results (
+    survey_uri TEXT PRIMARY KEY,
+    results_uri TEXT NOT NULL,
+    publisher_did TEXT NOT NULL,
+    total_votes INTEGER NOT NULL DEFAULT 0,
+    question_results JSONB NOT NULL,
+    published_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Remove moderation blocklist
+
+DROP TABLE IF EXISTS blocked_dids;
@@ -0,0 +1,8 @@
+-- Moderation blocklist
+-- Commits from blocked DIDs are skipped by the consumer
+
+CREATE TABLE blocked_dids (
+    did TEXT PRIMARY KEY,
+    reason TEXT,
+    blocked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+-- Remove lang column from surveys
+
+DROP INDEX IF EXISTS idx_surveys_lang;
+
+ALTER TABLE surveys
+DROP COLUMN lang;
@@ -0,0 +1,8 @@
+-- Add lang to surveys
+-- The BCP-47 language tag declared by the survey record, or NULL if none.
+-- Used for <html lang> on the survey page and for filtering listings.
+
+ALTER TABLE surveys
+ADD COLUMN lang TEXT;
+
+CREATE INDEX idx_surveys_lang ON surveys (lang) WHERE lang IS NOT NULL;
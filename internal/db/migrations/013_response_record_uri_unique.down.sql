@@ -0,0 +1,5 @@
+-- Restore the non-unique record_uri index
+
+DROP INDEX IF EXISTS idx_responses_record_uri;
+
+CREATE INDEX idx_responses_record_uri ON responses(record_uri) WHERE record_uri IS NOT NULL;
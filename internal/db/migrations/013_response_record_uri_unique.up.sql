@@ -0,0 +1,15 @@
+-- One row per ingested response record
+-- The consumer replays events after a restart, so record_uri must identify a
+-- single response row; replays then update it instead of adding another.
+
+-- Keep only the newest row for any record ingested more than once
+DELETE FROM responses r
+USING responses newer
+WHERE r.record_uri = newer.record_uri
+  AND (r.created_at, r.id) < (newer.created_at, newer.id);
+
+DROP INDEX IF EXISTS idx_responses_record_uri;
+
+CREATE UNIQUE INDEX idx_responses_record_uri
+    ON responses(record_uri)
+    WHERE record_uri IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Remove survey comments
+
+ALTER TABLE account_actions
+DROP COLUMN comments_affected;
+
+DROP TABLE IF EXISTS survey_comments;
@@ -0,0 +1,27 @@
+-- Survey comments
+-- Free-form feedback about a survey from net.openmeet.survey.comment records.
+-- Comments are indexed for every survey but only shown on the survey page
+-- when the author allows them (definition.allowComments).
+
+CREATE TABLE survey_comments (
+    id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    author_did TEXT NOT NULL,
+    record_uri TEXT NOT NULL,
+    record_cid TEXT NOT NULL,
+    text TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    hidden_at TIMESTAMPTZ
+);
+
+-- One row per comment record; replays and edits update it in place
+CREATE UNIQUE INDEX idx_survey_comments_record_uri ON survey_comments(record_uri);
+
+-- Recent comments for the survey page
+CREATE INDEX idx_survey_comments_survey_created ON survey_comments(survey_id, created_at DESC);
+
+-- Finding a DID's comments when an account event arrives
+CREATE INDEX idx_survey_comments_author_did ON survey_comments(author_did);
+
+ALTER TABLE account_actions
+ADD COLUMN comments_affected INT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Remove record_updated_at from surveys
+
+ALTER TABLE surveys
+DROP COLUMN record_updated_at;
@@ -0,0 +1,7 @@
+-- Add record_updated_at to surveys
+-- The Jetstream event time of the create or update that produced surveys.cid,
+-- so the survey page can show when the record was last edited. NULL for
+-- surveys not yet indexed from a record.
+
+ALTER TABLE surveys
+ADD COLUMN record_updated_at TIMESTAMPTZ;
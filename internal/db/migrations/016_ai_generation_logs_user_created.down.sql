@@ -0,0 +1,5 @@
+-- Restore the single-column user index
+
+CREATE INDEX idx_ai_generation_logs_user_id ON ai_generation_logs(user_id);
+
+DROP INDEX IF EXISTS idx_ai_generation_logs_user_created;
@@ -0,0 +1,7 @@
+-- Index AI generation logs by user and time
+-- Spending limits sum a user's cost since a point in time; the composite
+-- index serves that range scan and also covers lookups by user_id alone.
+
+CREATE INDEX idx_ai_generation_logs_user_created ON ai_generation_logs(user_id, created_at);
+
+DROP INDEX IF EXISTS idx_ai_generation_logs_user_id;
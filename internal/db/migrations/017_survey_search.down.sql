@@ -0,0 +1,6 @@
+-- Remove survey full-text search
+
+DROP INDEX IF EXISTS idx_surveys_search_vector;
+
+ALTER TABLE surveys
+DROP COLUMN search_vector;
@@ -0,0 +1,12 @@
+-- Full-text search over survey titles and descriptions
+-- Surveys are multilingual (see surveys.lang), so the 'simple' configuration
+-- is used: no stemming or stop words, but no English-only assumptions either.
+-- Titles rank above descriptions.
+
+ALTER TABLE surveys
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_surveys_search_vector ON surveys USING GIN (search_vector);
@@ -0,0 +1,5 @@
+-- Remove keyset pagination indexes
+
+DROP INDEX IF EXISTS idx_ai_generation_logs_created_at_id;
+
+DROP INDEX IF EXISTS idx_surveys_created_at_id;
@@ -0,0 +1,7 @@
+-- Indexes for keyset pagination
+-- Listings page by (created_at, id) descending so rows inserted between
+-- requests never shift a page. id breaks ties between equal timestamps.
+
+CREATE INDEX idx_surveys_created_at_id ON surveys(created_at, id);
+
+CREATE INDEX idx_ai_generation_logs_created_at_id ON ai_generation_logs(created_at, id);
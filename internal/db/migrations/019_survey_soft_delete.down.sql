@@ -0,0 +1,9 @@
+-- Remove survey soft delete; soft-deleted surveys are purged first so they
+-- don't reappear
+
+DROP INDEX IF EXISTS idx_surveys_deleted_at;
+
+DELETE FROM surveys WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE surveys
+DROP COLUMN IF EXISTS deleted_at;
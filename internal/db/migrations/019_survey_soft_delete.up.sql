@@ -0,0 +1,10 @@
+-- Soft delete for surveys
+-- Deleting a survey record stamps deleted_at instead of removing the row, so
+-- an accidental delete can be restored with its responses. Rows deleted for
+-- longer than the grace period are purged by the consumer.
+
+ALTER TABLE surveys
+ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- Finding surveys due for purging
+CREATE INDEX idx_surveys_deleted_at ON surveys(deleted_at) WHERE deleted_at IS NOT NULL;
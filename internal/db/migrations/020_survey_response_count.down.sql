@@ -0,0 +1,4 @@
+-- Remove cached response counts
+
+ALTER TABLE surveys
+DROP COLUMN IF EXISTS response_count;
@@ -0,0 +1,10 @@
+-- Cached response counts
+-- surveys.response_count is the number of visible (not hidden) responses,
+-- adjusted by the same statement that inserts, deletes, hides or restores a
+-- response so listings don't need a COUNT(*) per survey.
+
+ALTER TABLE surveys
+ADD COLUMN response_count INT NOT NULL DEFAULT 0;
+
+UPDATE surveys s
+SET response_count = (SELECT COUNT(*) FROM responses r WHERE r.survey_id = s.id AND r.hidden_at IS NULL);
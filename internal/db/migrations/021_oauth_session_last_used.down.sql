@@ -0,0 +1,6 @@
+-- Remove OAuth session activity tracking
+
+DROP INDEX IF EXISTS idx_oauth_sessions_last_used_at;
+
+ALTER TABLE oauth_sessions
+DROP COLUMN IF EXISTS last_used_at;
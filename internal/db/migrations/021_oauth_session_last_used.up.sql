@@ -0,0 +1,10 @@
+-- Track OAuth session activity
+-- last_used_at is bumped when a request authenticates with the session, so
+-- the cleanup worker can drop idle sessions without touching active ones.
+
+ALTER TABLE oauth_sessions
+ADD COLUMN last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+UPDATE oauth_sessions SET last_used_at = COALESCE(created_at, NOW());
+
+CREATE INDEX idx_oauth_sessions_last_used_at ON oauth_sessions(last_used_at);
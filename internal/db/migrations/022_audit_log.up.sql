@@ -0,0 +1,19 @@
+-- Audit log of administrative and destructive actions
+-- Blocking a DID, purging an account, rewinding the Jetstream cursor,
+-- restoring or purging deleted surveys and pruning AI logs each record who did
+-- it (an admin DID, or 'system' for scheduled jobs) and on what.
+
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL DEFAULT '',
+    details JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Newest first, optionally narrowed to an actor, action or target
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at DESC);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor, created_at DESC);
+CREATE INDEX idx_audit_log_action ON audit_log(action, created_at DESC);
+CREATE INDEX idx_audit_log_target ON audit_log(target, created_at DESC) WHERE target <> '';
@@ -0,0 +1,4 @@
+-- Remove survey edit versions
+
+ALTER TABLE surveys
+DROP COLUMN IF EXISTS version;
@@ -0,0 +1,7 @@
+-- Optimistic concurrency for survey edits
+-- version is bumped whenever a survey's title, description or definition
+-- changes, whether through the API or from the PDS. Editors send back the
+-- version they read and the API refuses the edit if it has moved on.
+
+ALTER TABLE surveys
+ADD COLUMN version INT NOT NULL DEFAULT 1;
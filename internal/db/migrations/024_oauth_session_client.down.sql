@@ -0,0 +1,5 @@
+-- Remove OAuth session client details
+
+ALTER TABLE oauth_sessions
+DROP COLUMN IF EXISTS user_agent,
+DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,7 @@
+-- Record the client a session was created from
+-- user_agent and ip_address are a snapshot taken at login, shown to users in
+-- their list of active sessions so they can tell devices apart.
+
+ALTER TABLE oauth_sessions
+ADD COLUMN user_agent TEXT,
+ADD COLUMN ip_address TEXT;
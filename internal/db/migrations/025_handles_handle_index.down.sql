@@ -0,0 +1,3 @@
+-- Remove the handle lookup index
+
+DROP INDEX IF EXISTS idx_handles_handle;
@@ -0,0 +1,5 @@
+-- Look up DIDs by handle
+-- The identity resolver persists handle -> DID resolutions in the handles
+-- table, so it needs to find rows by handle as well as by DID.
+
+CREATE INDEX idx_handles_handle ON handles (handle);
@@ -0,0 +1,3 @@
+-- Remove cached profiles
+
+DROP TABLE IF EXISTS profiles;
@@ -0,0 +1,12 @@
+-- Cached Bluesky profiles
+-- Survey pages show the author's display name and avatar. Profiles are served
+-- from here and refreshed from the AppView in the background once stale, so
+-- page renders don't wait on (or fail with) the AppView.
+
+CREATE TABLE profiles (
+    did TEXT PRIMARY KEY,
+    handle TEXT NOT NULL,
+    display_name TEXT NOT NULL DEFAULT '',
+    avatar TEXT NOT NULL DEFAULT '',
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,7 @@
+-- Remove the OAuth login host
+
+ALTER TABLE oauth_sessions
+DROP COLUMN IF EXISTS host;
+
+ALTER TABLE oauth_requests
+DROP COLUMN IF EXISTS host;
@@ -0,0 +1,11 @@
+-- Record the host an OAuth login went through
+-- The app is served under more than one host, each with its own client ID
+-- (https://<host>/oauth/client-metadata.json). A session must refresh and
+-- revoke its tokens with the client ID it was issued to. NULL (sessions from
+-- before this migration) means the primary host.
+
+ALTER TABLE oauth_requests
+ADD COLUMN host TEXT;
+
+ALTER TABLE oauth_sessions
+ADD COLUMN host TEXT;
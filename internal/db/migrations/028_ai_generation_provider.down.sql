@@ -0,0 +1,5 @@
+-- Remove the AI generation provider and model
+
+ALTER TABLE ai_generation_logs
+DROP COLUMN IF EXISTS provider,
+DROP COLUMN IF EXISTS model;
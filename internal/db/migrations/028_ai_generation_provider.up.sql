@@ -0,0 +1,9 @@
+-- Record which provider and model served each AI generation
+-- Generation can run on OpenAI or Anthropic (AI_PROVIDER). Earlier logs all
+-- came from OpenAI's gpt-4o-mini, the only model used before this migration.
+
+ALTER TABLE ai_generation_logs
+ADD COLUMN provider TEXT NOT NULL DEFAULT '',
+ADD COLUMN model TEXT NOT NULL DEFAULT '';
+
+UPDATE ai_generation_logs SET provider = 'openai', model = 'gpt-4o-mini';
@@ -0,0 +1,4 @@
+-- Remove the AI generation fallback marker
+
+ALTER TABLE ai_generation_logs
+DROP COLUMN IF EXISTS fallback_from;
@@ -0,0 +1,7 @@
+-- Mark generations served by a fallback provider
+-- When the primary AI provider is unavailable the generator retries on the
+-- next one in AI_PROVIDER. The failed attempt is logged as its own error row;
+-- the successful one records the provider it fell back from here.
+
+ALTER TABLE ai_generation_logs
+ADD COLUMN fallback_from TEXT NOT NULL DEFAULT '';
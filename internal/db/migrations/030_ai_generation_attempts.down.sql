@@ -0,0 +1,4 @@
+-- Remove the AI generation attempt count
+
+ALTER TABLE ai_generation_logs
+DROP COLUMN IF EXISTS attempts;
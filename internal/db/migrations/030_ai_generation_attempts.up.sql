@@ -0,0 +1,7 @@
+-- Count the requests each AI generation took
+-- The generator retries transient provider errors (429, 5xx, connection
+-- resets) up to twice. Only the final outcome is logged, with the number of
+-- requests sent to its provider here, so retry frequency stays visible.
+
+ALTER TABLE ai_generation_logs
+ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
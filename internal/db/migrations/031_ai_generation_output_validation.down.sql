@@ -0,0 +1,5 @@
+-- Remove the AI output validation outcome
+
+ALTER TABLE ai_generation_logs
+DROP COLUMN IF EXISTS output_issues,
+DROP COLUMN IF EXISTS output_validation;
@@ -0,0 +1,10 @@
+-- Record how AI output passed validation
+-- The generator checks each survey the model writes, repairs missing or
+-- duplicate IDs and empty options, and re-prompts the model once when the
+-- output can't be repaired. output_validation is the outcome (valid,
+-- repaired, reprompted or invalid; empty when there was no output) and
+-- output_issues the problems with the model's first response.
+
+ALTER TABLE ai_generation_logs
+ADD COLUMN output_validation TEXT NOT NULL DEFAULT '',
+ADD COLUMN output_issues TEXT NOT NULL DEFAULT '';
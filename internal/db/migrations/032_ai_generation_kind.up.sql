@@ -0,0 +1,6 @@
+-- Record what each AI generation was for
+-- generate for new and modified surveys, translate for translations of an
+-- existing survey.
+
+ALTER TABLE ai_generation_logs
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'generate';
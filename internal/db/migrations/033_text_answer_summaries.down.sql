@@ -0,0 +1,3 @@
+-- Remove AI summaries of text answers
+
+DROP TABLE IF EXISTS text_answer_summaries;
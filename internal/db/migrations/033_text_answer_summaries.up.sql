@@ -0,0 +1,14 @@
+-- AI summaries of text answers
+-- The author of a survey can ask AI to summarize the answers to a text
+-- question. The latest summary of each question is kept with the number of
+-- answers it covered, so asking again before new answers arrive reuses it
+-- instead of paying for another.
+
+CREATE TABLE text_answer_summaries (
+    survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    question_id TEXT NOT NULL,
+    response_count INT NOT NULL,
+    summary JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (survey_id, question_id)
+);
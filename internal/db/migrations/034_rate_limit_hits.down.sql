@@ -0,0 +1,3 @@
+-- Remove shared rate limit counts
+
+DROP TABLE IF EXISTS rate_limit_hits;
@@ -0,0 +1,11 @@
+-- Shared rate limit counts
+-- Rate limits kept in memory reset on deploy and are per replica. Each
+-- request a limit admits is recorded here instead, and a key's requests in
+-- a sliding window are counted with a range scan of the index.
+
+CREATE TABLE rate_limit_hits (
+    key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_rate_limit_hits_key_created ON rate_limit_hits(key, created_at);
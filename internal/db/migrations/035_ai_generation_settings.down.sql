@@ -0,0 +1,7 @@
+-- Remove AI generation settings
+
+DROP INDEX IF EXISTS idx_ai_generation_logs_model_created;
+
+ALTER TABLE ai_generation_logs
+DROP COLUMN IF EXISTS prompt_version,
+DROP COLUMN IF EXISTS temperature;
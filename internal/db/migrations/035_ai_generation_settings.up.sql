@@ -0,0 +1,11 @@
+-- Record the settings each AI generation ran with
+-- The sampling temperature and system prompt version, alongside the provider
+-- and model, let output and cost changes be traced to a setting change.
+-- Earlier logs have NULLs: what they ran with wasn't recorded.
+
+ALTER TABLE ai_generation_logs
+ADD COLUMN temperature DOUBLE PRECISION,
+ADD COLUMN prompt_version TEXT;
+
+-- Cost analysis and regression triage list a model's logs, newest first
+CREATE INDEX idx_ai_generation_logs_model_created ON ai_generation_logs(model, created_at);
@@ -0,0 +1,11 @@
+-- Remove the cancelled and timeout AI generation statuses
+-- Stopped generations are kept as errors, so the constraint can be restored.
+
+UPDATE ai_generation_logs SET status = 'error' WHERE status IN ('cancelled', 'timeout');
+
+ALTER TABLE ai_generation_logs
+DROP CONSTRAINT ai_generation_logs_status_check;
+
+ALTER TABLE ai_generation_logs
+ADD CONSTRAINT ai_generation_logs_status_check
+CHECK (status IN ('success', 'error', 'rate_limited', 'validation_failed'));
@@ -0,0 +1,10 @@
+-- Record AI generations that were stopped before the model answered
+-- cancelled when the client went away, timeout when AI_GENERATION_TIMEOUT
+-- passed. Both keep whatever token usage was known when they stopped.
+
+ALTER TABLE ai_generation_logs
+DROP CONSTRAINT ai_generation_logs_status_check;
+
+ALTER TABLE ai_generation_logs
+ADD CONSTRAINT ai_generation_logs_status_check
+CHECK (status IN ('success', 'error', 'rate_limited', 'validation_failed', 'cancelled', 'timeout'));
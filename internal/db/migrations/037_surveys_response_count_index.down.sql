@@ -0,0 +1,3 @@
+-- Remove the response count listing index
+
+DROP INDEX IF EXISTS idx_surveys_response_count;
@@ -0,0 +1,5 @@
+-- Index for listing surveys by response count
+-- The browse page can sort by most responses, paging by
+-- (response_count, created_at, id) descending like the newest-first order.
+
+CREATE INDEX idx_surveys_response_count ON surveys(response_count, created_at, id);
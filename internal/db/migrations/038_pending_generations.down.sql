@@ -0,0 +1,3 @@
+-- Remove shared pending AI generations
+
+DROP TABLE IF EXISTS pending_generations;
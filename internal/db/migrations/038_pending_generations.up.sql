@@ -0,0 +1,16 @@
+-- Shared pending AI generations
+-- A streamed generation is started with a POST and run when its event
+-- stream is opened with a GET, which any replica may serve. The request
+-- waits here in between, and is deleted when its stream claims it.
+
+CREATE TABLE pending_generations (
+    id UUID PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    user_type TEXT NOT NULL,
+    description TEXT NOT NULL,
+    existing JSONB,
+    translate_to TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_pending_generations_expires_at ON pending_generations(expires_at);
@@ -0,0 +1,12 @@
+// Package migrations embeds the SQL schema migrations applied by db.Migrate.
+//
+// Files are named NNN_description.up.sql and NNN_description.down.sql, the
+// layout golang-migrate creates with `make migrate-create`.
+package migrations
+
+import "embed"
+
+// FS holds every migration file
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// Cursor is the position of the last row on a page of a list ordered by
+// (created_at, id) descending. The next page starts at the row after it, so
+// rows inserted between requests never shift a page the way offsets do.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"i"`
+	// ResponseCount is set on cursors of lists ordered by response count
+	// first, such as ListSurveys with SortMostResponses
+	ResponseCount *int `json:"r,omitempty"`
+}
+
+// EncodeCursor returns the opaque cursor for the row with createdAt and id
+func EncodeCursor(createdAt time.Time, id uuid.UUID) string {
+	data, _ := json.Marshal(Cursor{CreatedAt: createdAt.UTC(), ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// EncodeResponsesCursor returns the opaque cursor for the row with
+// responseCount, createdAt and id in a list ordered by response count
+func EncodeResponsesCursor(responseCount int, createdAt time.Time, id uuid.UUID) string {
+	data, _ := json.Marshal(Cursor{CreatedAt: createdAt.UTC(), ID: id, ResponseCount: &responseCount})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor from EncodeCursor. An empty string is the
+// first page and decodes to nil.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.CreatedAt.IsZero() || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// cursorArgs returns the keyset query arguments for cursor: NULLs on the
+// first page, which the queries treat as "no lower bound"
+func cursorArgs(cursor *Cursor) (createdAt *time.Time, id *uuid.UUID) {
+	if cursor == nil {
+		return nil, nil
+	}
+	return &cursor.CreatedAt, &cursor.ID
+}
+
+// nextCursor trims a page fetched with limit+1 rows back to limit and returns
+// the cursor for the following page, or "" if this is the last one. key
+// returns the (created_at, id) of row i.
+func nextCursor(n, limit int, key func(i int) (time.Time, uuid.UUID)) (keep int, next string) {
+	if n <= limit {
+		return n, ""
+	}
+	createdAt, id := key(limit - 1)
+	return limit, EncodeCursor(createdAt, id)
+}
+
+// nextResponsesCursor is nextCursor for lists ordered by (response_count,
+// created_at, id) descending
+func nextResponsesCursor(n, limit int, key func(i int) (int, time.Time, uuid.UUID)) (keep int, next string) {
+	if n <= limit {
+		return n, ""
+	}
+	responseCount, createdAt, id := key(limit - 1)
+	return limit, EncodeResponsesCursor(responseCount, createdAt, id)
+}
@@ -0,0 +1,162 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/generator"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestListSurveysPagination pages through surveys that share timestamps while new
+// surveys arrive between pages, and checks none is skipped or repeated
+func TestListSurveysPagination(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	// A unique lang keeps other surveys in the database out of the listing
+	lang := "x-" + uuid.NewString()[:8]
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	create := func(createdAt time.Time) uuid.UUID {
+		survey := &models.Survey{
+			ID:    uuid.New(),
+			Slug:  "page-" + uuid.NewString()[:8],
+			Title: "Pagination",
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{{
+					ID:      "q1",
+					Text:    "Pick one",
+					Type:    models.QuestionTypeSingle,
+					Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+				}},
+			},
+			Lang:      &lang,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+		return survey.ID
+	}
+
+	// Three surveys per timestamp so page boundaries fall inside ties
+	want := map[uuid.UUID]bool{}
+	for i := 0; i < 12; i++ {
+		want[create(base.Add(-time.Duration(i/3)*time.Minute))] = true
+	}
+
+	seen := map[uuid.UUID]int{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+		surveys, next, err := queries.ListSurveys(ctx, ListSurveysParams{Lang: lang, Limit: 5, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListSurveys failed: %v", err)
+		}
+		for _, s := range surveys {
+			seen[s.ID]++
+		}
+
+		// Newer surveys must not shift later pages; older ones beyond the
+		// cursor must still be reached
+		create(base.Add(time.Minute))
+		older := create(base.Add(-time.Hour))
+		want[older] = true
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for id := range want {
+		if seen[id] != 1 {
+			t.Errorf("survey %s listed %d times, want once", id, seen[id])
+		}
+	}
+	for id := range seen {
+		if !want[id] {
+			t.Errorf("survey %s created after the first page was listed", id)
+		}
+	}
+
+	if _, _, err := queries.ListSurveys(ctx, ListSurveysParams{Lang: lang, Limit: 5, Cursor: "garbage"}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// TestGetGenerationLogsByUserAfter checks keyset pagination over logs that
+// share timestamps
+func TestGetGenerationLogsByUserAfter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	userID := "did:plc:page-" + uuid.NewString()[:8]
+	base := time.Now().Truncate(time.Second)
+
+	want := map[uuid.UUID]bool{}
+	for i := 0; i < 7; i++ {
+		log := &generator.AIGenerationLog{
+			ID:          uuid.New(),
+			UserID:      userID,
+			UserType:    "authenticated",
+			InputPrompt: "prompt",
+			Status:      "success",
+			CreatedAt:   base.Add(-time.Duration(i/2) * time.Second),
+		}
+		if err := queries.LogGeneration(ctx, log); err != nil {
+			t.Fatalf("Failed to log generation: %v", err)
+		}
+		want[log.ID] = true
+	}
+
+	seen := map[uuid.UUID]int{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("pagination did not terminate")
+		}
+		logs, next, err := queries.GetGenerationLogsByUserAfter(ctx, userID, cursor, 2)
+		if err != nil {
+			t.Fatalf("GetGenerationLogsByUserAfter failed: %v", err)
+		}
+		for _, log := range logs {
+			seen[log.ID]++
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(seen) != len(want) {
+		t.Errorf("Expected %d logs, got %d", len(want), len(seen))
+	}
+	for id, n := range seen {
+		if !want[id] || n != 1 {
+			t.Errorf("log %s listed %d times", id, n)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.FixedZone("PDT", -7*3600))
+	id := uuid.New()
+
+	cursor, err := DecodeCursor(EncodeCursor(createdAt, id))
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if !cursor.CreatedAt.Equal(createdAt) {
+		t.Errorf("CreatedAt = %v, want %v", cursor.CreatedAt, createdAt)
+	}
+	if cursor.ID != id {
+		t.Errorf("ID = %v, want %v", cursor.ID, id)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	t.Run("empty is the first page", func(t *testing.T) {
+		cursor, err := DecodeCursor("")
+		if err != nil || cursor != nil {
+			t.Errorf("DecodeCursor(\"\") = %v, %v; want nil, nil", cursor, err)
+		}
+	})
+
+	for name, s := range map[string]string{
+		"not base64":   "not a cursor!",
+		"not json":     "bm90IGpzb24",
+		"missing id":   "eyJ0IjoiMjAyNS0wMS0wMVQwMDowMDowMFoifQ",
+		"missing time": "eyJpIjoiNmJhN2I4MTAtOWRhZC0xMWQxLTgwYjQtMDBjMDRmZDQzMGM4In0",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", s, err)
+			}
+		})
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	key := func(i int) (time.Time, uuid.UUID) {
+		return base.Add(-time.Duration(i) * time.Minute), ids[i]
+	}
+
+	if keep, next := nextCursor(3, 3, key); keep != 3 || next != "" {
+		t.Errorf("full last page: got keep=%d next=%q, want 3 and no cursor", keep, next)
+	}
+
+	keep, next := nextCursor(4, 3, key)
+	if keep != 3 {
+		t.Errorf("keep = %d, want 3", keep)
+	}
+	cursor, err := DecodeCursor(next)
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if cursor.ID != ids[2] {
+		t.Errorf("cursor points at %v, want the last kept row %v", cursor.ID, ids[2])
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// PendingGeneration is a streamed AI generation that was started but whose
+// event stream hasn't been opened yet
+type PendingGeneration struct {
+	ID          uuid.UUID
+	UserID      string // DID for authenticated, IP for anonymous
+	UserType    string
+	Description string
+	Existing    *models.SurveyDefinition // The survey to modify or translate; nil for a new survey
+	TranslateTo string
+	ExpiresAt   time.Time
+}
+
+// SavePendingGeneration stores g until it is claimed or expires. Expired
+// generations are deleted first, so unclaimed ones don't pile up.
+func (q *Queries) SavePendingGeneration(ctx context.Context, g *PendingGeneration) error {
+	var existingJSON []byte
+	if g.Existing != nil {
+		var err error
+		existingJSON, err = json.Marshal(g.Existing)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending generation survey: %w", err)
+		}
+	}
+
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM pending_generations WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to delete expired pending generations: %w", err)
+	}
+
+	query := `
+		INSERT INTO pending_generations (id, user_id, user_type, description, existing, translate_to, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := q.db.ExecContext(ctx, query, g.ID, g.UserID, g.UserType, g.Description, existingJSON, g.TranslateTo, g.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save pending generation: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimPendingGeneration deletes and returns the generation with id if
+// userID started it. Returns nil (no error) if there is none or it expired.
+// Each generation can be claimed once, by whichever process asks first.
+func (q *Queries) ClaimPendingGeneration(ctx context.Context, id uuid.UUID, userID string) (*PendingGeneration, error) {
+	query := `
+		DELETE FROM pending_generations
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, user_type, description, existing, translate_to, expires_at
+	`
+
+	var g PendingGeneration
+	var existingJSON []byte
+	err := q.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&g.ID, &g.UserID, &g.UserType, &g.Description, &existingJSON, &g.TranslateTo, &g.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim pending generation: %w", err)
+	}
+
+	if !time.Now().Before(g.ExpiresAt) {
+		return nil, nil
+	}
+
+	if existingJSON != nil {
+		if err := json.Unmarshal(existingJSON, &g.Existing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending generation survey: %w", err)
+		}
+	}
+
+	return &g, nil
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestPendingGenerationsFake(t *testing.T) {
+	id := uuid.New()
+	columns := []string{"id", "user_id", "user_type", "description", "existing", "translate_to", "expires_at"}
+
+	t.Run("saving deletes expired generations first", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("WHERE expires_at < NOW()").RowsAffected(2)
+		fake.Expect("INSERT INTO pending_generations").RowsAffected(1)
+
+		err := NewQueries(fake).SavePendingGeneration(context.Background(), &PendingGeneration{
+			ID:          id,
+			UserID:      "did:plc:alice",
+			UserType:    "authenticated",
+			Description: "A poll about pizza",
+			Existing:    &models.SurveyDefinition{Questions: []models.Question{{ID: "q1", Text: "Pizza?", Type: models.QuestionTypeText}}},
+			ExpiresAt:   time.Now().Add(time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("SavePendingGeneration failed: %v", err)
+		}
+
+		calls := fake.Calls()
+		if len(calls) != 2 || !strings.HasPrefix(calls[0].Query, "DELETE") {
+			t.Fatalf("Expected a delete then an insert, got %v", calls)
+		}
+		if existing, _ := calls[1].Args[4].([]byte); !strings.Contains(string(existing), `"Pizza?"`) {
+			t.Errorf("Expected the survey as JSON, got %v", calls[1].Args[4])
+		}
+	})
+
+	t.Run("claims the requester's generation", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM pending_generations").Rows(columns,
+			[]interface{}{id, "did:plc:alice", "authenticated", "A poll about pizza", []byte(`{"questions":[{"id":"q1","text":"Pizza?","type":"text"}]}`), "fr", time.Now().Add(time.Minute)},
+		)
+
+		g, err := NewQueries(fake).ClaimPendingGeneration(context.Background(), id, "did:plc:alice")
+		if err != nil {
+			t.Fatalf("ClaimPendingGeneration failed: %v", err)
+		}
+		if g == nil || g.Description != "A poll about pizza" || g.TranslateTo != "fr" {
+			t.Fatalf("Unexpected generation %+v", g)
+		}
+		if g.Existing == nil || g.Existing.Questions[0].Text != "Pizza?" {
+			t.Errorf("Expected the survey to modify, got %+v", g.Existing)
+		}
+
+		call := fake.Calls()[0]
+		if !strings.Contains(call.Query, "WHERE id = $1 AND user_id = $2") || call.Args[1] != "did:plc:alice" {
+			t.Errorf("Unexpected query %s with %v", call.Query, call.Args)
+		}
+	})
+
+	t.Run("nothing to claim", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM pending_generations").Rows(columns)
+
+		g, err := NewQueries(fake).ClaimPendingGeneration(context.Background(), id, "192.0.2.2")
+		if err != nil || g != nil {
+			t.Errorf("Expected nil, got %+v, %v", g, err)
+		}
+	})
+
+	t.Run("expired generations can't be claimed", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM pending_generations").Rows(columns,
+			[]interface{}{id, "192.0.2.1", "anonymous", "A poll", nil, "", time.Now().Add(-time.Second)},
+		)
+
+		g, err := NewQueries(fake).ClaimPendingGeneration(context.Background(), id, "192.0.2.1")
+		if err != nil || g != nil {
+			t.Errorf("Expected nil, got %+v, %v", g, err)
+		}
+	})
+}
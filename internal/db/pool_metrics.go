@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Pool labels for RegisterPoolStats
+const (
+	PoolAPI      = "api"
+	PoolConsumer = "consumer"
+)
+
+// statsSource is satisfied by *sql.DB
+type statsSource interface {
+	Stats() sql.DBStats
+}
+
+// PoolStatsCollector exports a connection pool's sql.DBStats, read fresh on
+// every scrape. Each collector carries its pool as a constant label so
+// several pools can share a registry.
+type PoolStatsCollector struct {
+	db statsSource
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// NewPoolStatsCollector returns a collector for db labelled with pool
+func NewPoolStatsCollector(pool string, db statsSource) *PoolStatsCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("survey_db_pool_"+name, help, nil, prometheus.Labels{"pool": pool})
+	}
+	return &PoolStatsCollector{
+		db:                db,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections allowed"),
+		open:              desc("open_connections", "Number of established connections, in use or idle"),
+		inUse:             desc("in_use_connections", "Number of connections currently in use"),
+		idle:              desc("idle_connections", "Number of idle connections"),
+		waitCount:         desc("wait_count_total", "Total number of times a query waited for a connection"),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time spent waiting for a connection"),
+		maxIdleClosed:     desc("max_idle_closed_total", "Total connections closed because the idle pool was full"),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "Total connections closed for exceeding the idle time limit"),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Total connections closed for exceeding the lifetime limit"),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(c.maxOpen, float64(stats.MaxOpenConnections))
+	gauge(c.open, float64(stats.OpenConnections))
+	gauge(c.inUse, float64(stats.InUse))
+	gauge(c.idle, float64(stats.Idle))
+	counter(c.waitCount, float64(stats.WaitCount))
+	counter(c.waitDuration, stats.WaitDuration.Seconds())
+	counter(c.maxIdleClosed, float64(stats.MaxIdleClosed))
+	counter(c.maxIdleTimeClosed, float64(stats.MaxIdleTimeClosed))
+	counter(c.maxLifetimeClosed, float64(stats.MaxLifetimeClosed))
+}
+
+// RegisterPoolStats exports db's pool statistics under pool on the default
+// registry
+func RegisterPoolStats(pool string, db *sql.DB) error {
+	return prometheus.Register(NewPoolStatsCollector(pool, db))
+}
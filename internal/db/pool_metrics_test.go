@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeStats returns whatever statistics the test sets
+type fakeStats struct {
+	stats sql.DBStats
+}
+
+func (f *fakeStats) Stats() sql.DBStats { return f.stats }
+
+func TestPoolStatsCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	// Two pools in one registry must not collide
+	reg.MustRegister(NewPoolStatsCollector(PoolAPI, &fakeStats{stats: sql.DBStats{
+		MaxOpenConnections: 25,
+		OpenConnections:    7,
+		InUse:              4,
+		Idle:               3,
+		WaitCount:          12,
+		WaitDuration:       1500 * time.Millisecond,
+		MaxIdleClosed:      2,
+		MaxIdleTimeClosed:  5,
+		MaxLifetimeClosed:  1,
+	}}))
+	reg.MustRegister(NewPoolStatsCollector(PoolConsumer, &fakeStats{stats: sql.DBStats{MaxOpenConnections: 10}}))
+
+	expected := `
+# HELP survey_db_pool_in_use_connections Number of connections currently in use
+# TYPE survey_db_pool_in_use_connections gauge
+survey_db_pool_in_use_connections{pool="api"} 4
+survey_db_pool_in_use_connections{pool="consumer"} 0
+# HELP survey_db_pool_wait_count_total Total number of times a query waited for a connection
+# TYPE survey_db_pool_wait_count_total counter
+survey_db_pool_wait_count_total{pool="api"} 12
+survey_db_pool_wait_count_total{pool="consumer"} 0
+# HELP survey_db_pool_wait_duration_seconds_total Total time spent waiting for a connection
+# TYPE survey_db_pool_wait_duration_seconds_total counter
+survey_db_pool_wait_duration_seconds_total{pool="api"} 1.5
+survey_db_pool_wait_duration_seconds_total{pool="consumer"} 0
+# HELP survey_db_pool_max_lifetime_closed_total Total connections closed for exceeding the lifetime limit
+# TYPE survey_db_pool_max_lifetime_closed_total counter
+survey_db_pool_max_lifetime_closed_total{pool="api"} 1
+survey_db_pool_max_lifetime_closed_total{pool="consumer"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"survey_db_pool_in_use_connections",
+		"survey_db_pool_wait_count_total",
+		"survey_db_pool_wait_duration_seconds_total",
+		"survey_db_pool_max_lifetime_closed_total",
+	); err != nil {
+		t.Error(err)
+	}
+
+	// Every stat is exported for both pools
+	count, err := testutil.GatherAndCount(reg)
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	if count != 18 {
+		t.Errorf("Expected 9 series per pool, got %d in total", count)
+	}
+}
+
+func TestPoolStatsCollectorReadsOnScrape(t *testing.T) {
+	stats := &fakeStats{}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewPoolStatsCollector(PoolAPI, stats))
+
+	stats.stats.InUse = 9
+	expected := `
+# HELP survey_db_pool_in_use_connections Number of connections currently in use
+# TYPE survey_db_pool_in_use_connections gauge
+survey_db_pool_in_use_connections{pool="api"} 9
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "survey_db_pool_in_use_connections"); err != nil {
+		t.Error(err)
+	}
+}
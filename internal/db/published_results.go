@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// UpsertPublishedResults stores a published results snapshot, replacing any
+// earlier snapshot for the same survey
+func (q *Queries) UpsertPublishedResults(ctx context.Context, r *models.PublishedResults) error {
+	questionResultsJSON, err := json.Marshal(r.QuestionResults)
+	if err != nil {
+		return fmt.Errorf("failed to marshal question results: %w", err)
+	}
+
+	query := `
+		INSERT INTO published_results (survey_uri, results_uri, publisher_did, total_votes, question_results, published_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (survey_uri) DO UPDATE SET
+			results_uri = EXCLUDED.results_uri,
+			publisher_did = EXCLUDED.publisher_did,
+			total_votes = EXCLUDED.total_votes,
+			question_results = EXCLUDED.question_results,
+			published_at = EXCLUDED.published_at,
+			updated_at = NOW()
+	`
+
+	_, err = q.db.ExecContext(ctx, query,
+		r.SurveyURI,
+		r.ResultsURI,
+		r.PublisherDID,
+		r.TotalVotes,
+		questionResultsJSON,
+		r.PublishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert published results: %w", err)
+	}
+
+	return nil
+}
+
+// GetPublishedResults returns the published results snapshot for a survey
+// Returns nil (no error) if the author hasn't published results
+func (q *Queries) GetPublishedResults(ctx context.Context, surveyURI string) (*models.PublishedResults, error) {
+	query := `
+		SELECT survey_uri, results_uri, publisher_did, total_votes, question_results, published_at
+		FROM published_results
+		WHERE survey_uri = $1
+	`
+
+	var r models.PublishedResults
+	var questionResultsJSON []byte
+	err := q.db.QueryRowContext(ctx, query, surveyURI).Scan(
+		&r.SurveyURI,
+		&r.ResultsURI,
+		&r.PublisherDID,
+		&r.TotalVotes,
+		&questionResultsJSON,
+		&r.PublishedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get published results: %w", err)
+	}
+
+	if err := json.Unmarshal(questionResultsJSON, &r.QuestionResults); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal question results: %w", err)
+	}
+
+	return &r, nil
+}
+
+// DeletePublishedResults removes a survey's published results snapshot if it
+// came from the given results record. A snapshot already replaced by a newer
+// record is left alone.
+func (q *Queries) DeletePublishedResults(ctx context.Context, surveyURI, resultsURI string) error {
+	query := `DELETE FROM published_results WHERE survey_uri = $1 AND results_uri = $2`
+
+	if _, err := q.db.ExecContext(ctx, query, surveyURI, resultsURI); err != nil {
+		return fmt.Errorf("failed to delete published results: %w", err)
+	}
+
+	return nil
+}
@@ -6,36 +6,94 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/openmeet-team/survey/internal/models"
 )
 
-// Querier interface represents a database connection or transaction
-type Querier interface {
-	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
-	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+// DBTX is what Queries runs statements against. *sql.DB, *sql.Conn and
+// *sql.Tx all satisfy it, as does the fake in the queriestest package.
+type DBTX interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 }
 
+// ErrSlugTaken is returned by CreateSurvey when another survey already has the slug
+var ErrSlugTaken = errors.New("slug already taken")
+
+// ErrSurveyExists is returned by CreateSurvey when a survey with the same URI
+// is already indexed
+var ErrSurveyExists = errors.New("survey already exists")
+
+// ErrVersionConflict is returned by UpdateSurveyIfVersion when the survey was
+// changed since the editor read it
+var ErrVersionConflict = errors.New("survey was changed by someone else")
+
 // Queries provides database query methods
 type Queries struct {
-	db Querier
+	db      DBTX
+	blocked *blockCache  // shared with transaction-scoped copies, see withDB
+	surveys *surveyCache // nil unless EnableSurveyCache was called
 }
 
 // NewQueries creates a new Queries instance
-func NewQueries(db Querier) *Queries {
-	return &Queries{db: db}
+func NewQueries(db DBTX) *Queries {
+	return &Queries{db: db, blocked: newBlockCache(blockCacheTTL)}
+}
+
+// withDB returns a Queries that runs against db, typically a transaction,
+// but shares this instance's in-memory caches
+func (q *Queries) withDB(db DBTX) *Queries {
+	return &Queries{db: db, blocked: q.blocked, surveys: q.surveys}
+}
+
+// txBeginner is satisfied by *sql.DB and *sql.Conn
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// WithTx runs fn with a Queries scoped to a new transaction, committing if fn
+// returns nil and rolling back otherwise (including when fn panics). If q is
+// already transaction-scoped, fn joins that transaction and the caller that
+// opened it decides whether it commits.
+func (q *Queries) WithTx(ctx context.Context, fn func(q *Queries) error) error {
+	beginner, ok := q.db.(txBeginner)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(q.withDB(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
-// GetDB returns the underlying database connection
-func (q *Queries) GetDB() Querier {
+// GetDB returns the connection or transaction q runs against
+func (q *Queries) GetDB() DBTX {
 	return q.db
 }
 
 // Survey Queries
 
 // CreateSurvey inserts a new survey into the database
+// Returns ErrSlugTaken if the slug was claimed concurrently, or ErrSurveyExists
+// if the URI is already indexed. Conflicts are handled with ON CONFLICT rather
+// than a unique violation so a caller inside a transaction can retry (or fall
+// back to an update) without the transaction aborting.
 func (q *Queries) CreateSurvey(ctx context.Context, s *models.Survey) error {
 	// Marshal definition to JSON for JSONB storage
 	defJSON, err := json.Marshal(s.Definition)
@@ -44,38 +102,65 @@ func (q *Queries) CreateSurvey(ctx context.Context, s *models.Survey) error {
 	}
 
 	query := `
-		INSERT INTO surveys (id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO surveys (id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, lang, created_at, updated_at, record_updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT DO NOTHING
 	`
 
-	_, err = q.db.ExecContext(
-		ctx,
-		query,
-		s.ID,
-		s.URI,
-		s.CID,
-		s.AuthorDID,
-		s.Slug,
-		s.Title,
-		s.Description,
-		defJSON,
-		s.StartsAt,
-		s.EndsAt,
-		s.CreatedAt,
-		s.UpdatedAt,
-	)
+	var result sql.Result
+	err = ObserveWrite(TableSurveys, func() (err error) {
+		result, err = q.db.ExecContext(
+			ctx,
+			query,
+			s.ID,
+			s.URI,
+			s.CID,
+			s.AuthorDID,
+			s.Slug,
+			s.Title,
+			s.Description,
+			defJSON,
+			s.StartsAt,
+			s.EndsAt,
+			s.Lang,
+			s.CreatedAt,
+			s.UpdatedAt,
+			s.RecordUpdatedAt,
+		)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to insert survey: %w", err)
 	}
 
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		if s.URI != nil {
+			var exists bool
+			err := q.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM surveys WHERE uri = $1)`, *s.URI).Scan(&exists)
+			if err != nil {
+				return fmt.Errorf("failed to check survey existence: %w", err)
+			}
+			if exists {
+				return ErrSurveyExists
+			}
+		}
+		return ErrSlugTaken
+	}
+
+	s.Version = 1 // the column default
 	return nil
 }
 
-// GetSurveyByURI retrieves a survey by its ATProto URI
+// GetSurveyByURI retrieves a survey by its ATProto URI, including a
+// soft-deleted one (DeletedAt set) so the consumer can apply later events to it
 func (q *Queries) GetSurveyByURI(ctx context.Context, uri string) (*models.Survey, error) {
 	query := `
-		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, created_at, updated_at
+		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, definition_version, version, lang, created_at, updated_at, record_updated_at, deleted_at
 		FROM surveys
 		WHERE uri = $1
 	`
@@ -96,8 +181,13 @@ func (q *Queries) GetSurveyByURI(ctx context.Context, uri string) (*models.Surve
 		&survey.EndsAt,
 		&survey.ResultsURI,
 		&survey.ResultsCID,
+		&survey.DefinitionVersion,
+		&survey.Version,
+		&survey.Lang,
 		&survey.CreatedAt,
 		&survey.UpdatedAt,
+		&survey.RecordUpdatedAt,
+		&survey.DeletedAt,
 	)
 
 	if err != nil {
@@ -115,12 +205,33 @@ func (q *Queries) GetSurveyByURI(ctx context.Context, uri string) (*models.Surve
 	return survey, nil
 }
 
+// GetSurveyCID returns the CID of the indexed record for a survey URI
+// Returns "" (no error) if the survey isn't indexed or has no CID
+func (q *Queries) GetSurveyCID(ctx context.Context, uri string) (string, error) {
+	var cid sql.NullString
+	err := q.db.QueryRowContext(ctx, `SELECT cid FROM surveys WHERE uri = $1`, uri).Scan(&cid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to query survey CID: %w", err)
+	}
+
+	return cid.String, nil
+}
+
 // GetSurveyBySlug retrieves a survey by its slug
+// Surveys hidden by an account deactivation or soft-deleted are treated as not found
+// Served from the survey cache when enabled, see EnableSurveyCache
 func (q *Queries) GetSurveyBySlug(ctx context.Context, slug string) (*models.Survey, error) {
+	if survey, ok := q.surveys.get(slug, time.Now()); ok {
+		return survey, nil
+	}
+
 	query := `
-		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, created_at, updated_at
+		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, definition_version, version, lang, created_at, updated_at, record_updated_at
 		FROM surveys
-		WHERE slug = $1
+		WHERE slug = $1 AND hidden_at IS NULL AND deleted_at IS NULL
 	`
 
 	survey := &models.Survey{}
@@ -139,8 +250,12 @@ func (q *Queries) GetSurveyBySlug(ctx context.Context, slug string) (*models.Sur
 		&survey.EndsAt,
 		&survey.ResultsURI,
 		&survey.ResultsCID,
+		&survey.DefinitionVersion,
+		&survey.Version,
+		&survey.Lang,
 		&survey.CreatedAt,
 		&survey.UpdatedAt,
+		&survey.RecordUpdatedAt,
 	)
 
 	if err != nil {
@@ -155,21 +270,34 @@ func (q *Queries) GetSurveyBySlug(ctx context.Context, slug string) (*models.Sur
 		return nil, fmt.Errorf("failed to unmarshal survey definition: %w", err)
 	}
 
+	q.surveys.set(slug, survey, time.Now())
+
 	return survey, nil
 }
 
 // GetSurveyByID retrieves a survey by its ID
+// Soft-deleted surveys are treated as not found
 func (q *Queries) GetSurveyByID(ctx context.Context, id uuid.UUID) (*models.Survey, error) {
+	return q.getSurveyByID(ctx, id, false)
+}
+
+// GetSurveyByIDIncludingDeleted is GetSurveyByID for admin use, also
+// returning soft-deleted surveys
+func (q *Queries) GetSurveyByIDIncludingDeleted(ctx context.Context, id uuid.UUID) (*models.Survey, error) {
+	return q.getSurveyByID(ctx, id, true)
+}
+
+func (q *Queries) getSurveyByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*models.Survey, error) {
 	query := `
-		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, created_at, updated_at
+		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, definition_version, version, lang, created_at, updated_at, record_updated_at, deleted_at
 		FROM surveys
-		WHERE id = $1
+		WHERE id = $1 AND ($2 OR deleted_at IS NULL)
 	`
 
 	survey := &models.Survey{}
 	var defJSON []byte
 
-	err := q.db.QueryRowContext(ctx, query, id).Scan(
+	err := q.db.QueryRowContext(ctx, query, id, includeDeleted).Scan(
 		&survey.ID,
 		&survey.URI,
 		&survey.CID,
@@ -182,8 +310,13 @@ func (q *Queries) GetSurveyByID(ctx context.Context, id uuid.UUID) (*models.Surv
 		&survey.EndsAt,
 		&survey.ResultsURI,
 		&survey.ResultsCID,
+		&survey.DefinitionVersion,
+		&survey.Version,
+		&survey.Lang,
 		&survey.CreatedAt,
 		&survey.UpdatedAt,
+		&survey.RecordUpdatedAt,
+		&survey.DeletedAt,
 	)
 
 	if err != nil {
@@ -201,21 +334,118 @@ func (q *Queries) GetSurveyByID(ctx context.Context, id uuid.UUID) (*models.Surv
 	return survey, nil
 }
 
-// ListSurveys retrieves surveys with pagination
-func (q *Queries) ListSurveys(ctx context.Context, limit, offset int) ([]*models.Survey, error) {
+// ListSurveysParams selects a page of ListSurveys. Zero values don't filter.
+type ListSurveysParams struct {
+	// Status keeps only surveys in that status. Empty lists every status but
+	// deleted; SurveyStatusDeleted lists only soft-deleted surveys.
+	Status models.SurveyStatus
+	// AuthorDID keeps only surveys published by that DID
+	AuthorDID string
+	// Lang keeps only surveys in that language, including its regional
+	// variants ("es" matches "es" and "es-MX")
+	Lang string
+	// IncludeDeleted also lists soft-deleted surveys, for admin use
+	IncludeDeleted bool
+	// Discoverable keeps only surveys their authors made discoverable, the
+	// only ones listed publicly
+	Discoverable bool
+	// Sort is the order of the list; anything but SortMostResponses is
+	// SortNewest. A cursor only continues a list in the order it came from.
+	Sort ListSurveysSort
+	// Limit is the page size; Cursor is "" for the first page or the cursor
+	// returned with the previous one (see DecodeCursor)
+	Limit  int
+	Cursor string
+}
+
+// ListSurveysSort is an order of ListSurveys
+type ListSurveysSort string
+
+const (
+	SortNewest        ListSurveysSort = "newest"    // created_at, newest first
+	SortMostResponses ListSurveysSort = "responses" // response_count, most first, then newest
+)
+
+// ListSurveys returns a page of non-hidden surveys, newest first unless
+// params.Sort says otherwise, with their status and response count, and the
+// cursor for the next page, or "" on the last page. Returns
+// ErrInvalidCursor for a malformed cursor or one from the other order.
+func (q *Queries) ListSurveys(ctx context.Context, params ListSurveysParams) ([]*models.Survey, string, error) {
+	return q.listSurveys(ctx, params, time.Now())
+}
+
+// listSurveys is ListSurveys with statuses computed as of now
+func (q *Queries) listSurveys(ctx context.Context, params ListSurveysParams, now time.Time) ([]*models.Survey, string, error) {
+	after, err := DecodeCursor(params.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	byResponses := params.Sort == SortMostResponses
+	if after != nil && (after.ResponseCount != nil) != byResponses {
+		return nil, "", ErrInvalidCursor
+	}
+	afterCreatedAt, afterID := cursorArgs(after)
+	includeDeleted := params.IncludeDeleted || params.Status == models.SurveyStatusDeleted
+
+	// Served by idx_surveys_created_at_id, or idx_surveys_response_count
+	// when sorted by responses
+	keyset := `($3::timestamptz IS NULL OR (created_at, id) < ($3::timestamptz, $4::uuid))`
+	order := `created_at DESC, id DESC`
+	args := []interface{}{params.Limit + 1, params.Lang, afterCreatedAt, afterID, includeDeleted,
+		params.AuthorDID, string(params.Status), now, params.Discoverable}
+	if byResponses {
+		keyset = `($3::timestamptz IS NULL OR (response_count, created_at, id) < ($10::int, $3::timestamptz, $4::uuid))`
+		order = `response_count DESC, created_at DESC, id DESC`
+		var afterCount *int
+		if after != nil {
+			afterCount = after.ResponseCount
+		}
+		args = append(args, afterCount)
+	}
+
 	query := `
-		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, created_at, updated_at
+		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, definition_version, version, lang, created_at, updated_at, record_updated_at, deleted_at,
+		       ` + surveyStatusSQL("$8") + ` AS status, response_count
 		FROM surveys
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
+		WHERE hidden_at IS NULL
+		  AND ($5 OR deleted_at IS NULL)
+		  AND ($2 = '' OR lang = $2 OR lang LIKE $2 || '-%')
+		  AND ` + keyset + `
+		  AND ($6 = '' OR author_did = $6)
+		  AND ($7 = '' OR ` + surveyStatusSQL("$8") + ` = $7)
+		  AND (NOT $9 OR (definition->>'discoverable')::boolean IS TRUE)
+		ORDER BY ` + order + `
+		LIMIT $1
 	`
 
-	rows, err := q.db.QueryContext(ctx, query, limit, offset)
+	// One extra row tells whether there's a next page
+	rows, err := q.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query surveys: %w", err)
+		return nil, "", fmt.Errorf("failed to query surveys: %w", err)
 	}
 	defer rows.Close()
 
+	surveys, err := scanSurveys(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var keep int
+	var next string
+	if byResponses {
+		keep, next = nextResponsesCursor(len(surveys), params.Limit, func(i int) (int, time.Time, uuid.UUID) {
+			return surveys[i].ResponseCount, surveys[i].CreatedAt, surveys[i].ID
+		})
+	} else {
+		keep, next = nextCursor(len(surveys), params.Limit, func(i int) (time.Time, uuid.UUID) {
+			return surveys[i].CreatedAt, surveys[i].ID
+		})
+	}
+	return surveys[:keep], next, nil
+}
+
+// scanSurveys reads every row of a survey listing query
+func scanSurveys(rows *sql.Rows) ([]*models.Survey, error) {
 	var surveys []*models.Survey
 	for rows.Next() {
 		survey := &models.Survey{}
@@ -234,8 +464,15 @@ func (q *Queries) ListSurveys(ctx context.Context, limit, offset int) ([]*models
 			&survey.EndsAt,
 			&survey.ResultsURI,
 			&survey.ResultsCID,
+			&survey.DefinitionVersion,
+			&survey.Version,
+			&survey.Lang,
 			&survey.CreatedAt,
 			&survey.UpdatedAt,
+			&survey.RecordUpdatedAt,
+			&survey.DeletedAt,
+			&survey.Status,
+			&survey.ResponseCount,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan survey: %w", err)
@@ -269,7 +506,13 @@ func (q *Queries) SlugExists(ctx context.Context, slug string) (bool, error) {
 	return exists, nil
 }
 
-// UpdateSurvey updates an existing survey and sets updated_at
+// UpdateSurvey updates an existing survey and sets updated_at. A new record
+// for a soft-deleted survey restores it. This is the consumer's path: the PDS
+// is the source of truth, so the update always applies, but it bumps the
+// version (stored in s.Version) when the title, description or definition
+// changed so editors holding the old version see a conflict. Replaying an
+// edit made through UpdateSurveyIfVersion leaves the version alone; a missing
+// description and an empty one count as the same.
 func (q *Queries) UpdateSurvey(ctx context.Context, s *models.Survey) error {
 	// Marshal definition to JSON for JSONB storage
 	defJSON, err := json.Marshal(s.Definition)
@@ -281,36 +524,105 @@ func (q *Queries) UpdateSurvey(ctx context.Context, s *models.Survey) error {
 		UPDATE surveys
 		SET uri = $2, cid = $3, author_did = $4, slug = $5, title = $6,
 		    description = $7, definition = $8, starts_at = $9, ends_at = $10,
-		    updated_at = NOW()
+		    definition_version = $11, lang = $12, record_updated_at = $13, deleted_at = NULL, updated_at = NOW(),
+		    version = version + CASE
+		        WHEN (title, COALESCE(description, ''), definition) IS DISTINCT FROM ($6, COALESCE($7, ''), $8::jsonb) THEN 1
+		        ELSE 0
+		    END
 		WHERE id = $1
+		RETURNING version
 	`
 
-	result, err := q.db.ExecContext(
-		ctx,
-		query,
-		s.ID,
-		s.URI,
-		s.CID,
-		s.AuthorDID,
-		s.Slug,
-		s.Title,
-		s.Description,
-		defJSON,
-		s.StartsAt,
-		s.EndsAt,
-	)
+	found := true
+	err = ObserveWrite(TableSurveys, func() error {
+		err := q.db.QueryRowContext(
+			ctx,
+			query,
+			s.ID,
+			s.URI,
+			s.CID,
+			s.AuthorDID,
+			s.Slug,
+			s.Title,
+			s.Description,
+			defJSON,
+			s.StartsAt,
+			s.EndsAt,
+			s.DefinitionVersion,
+			s.Lang,
+			s.RecordUpdatedAt,
+		).Scan(&s.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			found = false
+			return nil
+		}
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to update survey: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
+	if !found {
+		return fmt.Errorf("survey not found")
+	}
+
+	return nil
+}
+
+// UpdateSurveyIfVersion saves an edit of s's title, description and
+// definition made by its author, along with the CID of the record the edit
+// was written as (kept if s.CID is nil), provided the survey is still at
+// version.
+// On success the version is bumped and stored in s.Version. Returns
+// ErrVersionConflict if the survey changed since version was read, or an
+// error wrapping sql.ErrNoRows if it is gone.
+func (q *Queries) UpdateSurveyIfVersion(ctx context.Context, s *models.Survey, version int) error {
+	defJSON, err := json.Marshal(s.Definition)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to marshal survey definition: %w", err)
 	}
 
-	if rows == 0 {
-		return fmt.Errorf("survey not found")
+	query := `
+		UPDATE surveys
+		SET title = $2, description = $3, definition = $4, cid = COALESCE($6, cid), starts_at = $7, ends_at = $8,
+		    version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND version = $5 AND hidden_at IS NULL AND deleted_at IS NULL
+		RETURNING version
+	`
+
+	updated := true
+	err = ObserveWrite(TableSurveys, func() error {
+		err := q.db.QueryRowContext(ctx, query, s.ID, s.Title, s.Description, defJSON, version, s.CID, s.StartsAt, s.EndsAt).Scan(&s.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			updated = false
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update survey: %w", err)
+	}
+
+	if !updated {
+		// Either the version moved on or the survey is gone
+		var exists bool
+		existsQuery := `SELECT EXISTS(SELECT 1 FROM surveys WHERE id = $1 AND hidden_at IS NULL AND deleted_at IS NULL)`
+		if err := q.db.QueryRowContext(ctx, existsQuery, s.ID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check survey existence: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("survey not found: %w", sql.ErrNoRows)
+		}
+		return ErrVersionConflict
+	}
+
+	// The edit didn't come through the consumer, so drop cached copies here
+	q.surveys.invalidate(func(cached *models.Survey) bool { return cached.ID == s.ID })
+	if s.URI != nil {
+		if err := q.notifySurveyInvalidation(ctx, *s.URI); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -318,7 +630,8 @@ func (q *Queries) UpdateSurvey(ctx context.Context, s *models.Survey) error {
 
 // Response Queries
 
-// CreateResponse inserts a new response into the database
+// CreateResponse inserts a new response into the database and counts it
+// toward its survey's response_count
 func (q *Queries) CreateResponse(ctx context.Context, r *models.Response) error {
 	// Marshal answers to JSON for JSONB storage
 	answersJSON, err := json.Marshal(r.Answers)
@@ -327,22 +640,30 @@ func (q *Queries) CreateResponse(ctx context.Context, r *models.Response) error
 	}
 
 	query := `
-		INSERT INTO responses (id, survey_id, voter_did, voter_session, record_uri, record_cid, answers, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		WITH changed AS (
+			INSERT INTO responses (id, survey_id, voter_did, voter_session, record_uri, record_cid, answers, created_at, dedup_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING survey_id
+		), ` + responseCountCTE(1, "TRUE") + `
+		SELECT COUNT(*) FROM changed
 	`
 
-	_, err = q.db.ExecContext(
-		ctx,
-		query,
-		r.ID,
-		r.SurveyID,
-		r.VoterDID,
-		r.VoterSession,
-		r.RecordURI,
-		r.RecordCID,
-		answersJSON,
-		r.CreatedAt,
-	)
+	err = ObserveWrite(TableResponses, func() error {
+		_, err := q.db.ExecContext(
+			ctx,
+			query,
+			r.ID,
+			r.SurveyID,
+			r.VoterDID,
+			r.VoterSession,
+			r.RecordURI,
+			r.RecordCID,
+			answersJSON,
+			r.CreatedAt,
+			r.DedupKey,
+		)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to insert response: %w", err)
@@ -351,6 +672,157 @@ func (q *Queries) CreateResponse(ctx context.Context, r *models.Response) error
 	return nil
 }
 
+// UpsertResponse inserts a response, or if one with the same survey and
+// DedupKey already exists, replaces its answers and record reference.
+// r.DedupKey must be set. On return r.ID is the stored row's ID; inserted
+// reports whether a new row was created, in which case it is counted toward
+// the survey's response_count; a replaced response leaves the count unchanged.
+func (q *Queries) UpsertResponse(ctx context.Context, r *models.Response) (inserted bool, err error) {
+	if r.DedupKey == nil {
+		return false, fmt.Errorf("upsert requires a dedup key")
+	}
+
+	answersJSON, err := json.Marshal(r.Answers)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal response answers: %w", err)
+	}
+
+	// xmax is 0 only for rows inserted by this statement
+	query := `
+		WITH changed AS (
+			INSERT INTO responses (id, survey_id, voter_did, voter_session, record_uri, record_cid, answers, created_at, dedup_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (survey_id, dedup_key) WHERE dedup_key IS NOT NULL DO UPDATE
+			SET answers = EXCLUDED.answers,
+			    record_uri = EXCLUDED.record_uri,
+			    record_cid = EXCLUDED.record_cid,
+			    voter_did = EXCLUDED.voter_did
+			RETURNING id, survey_id, (xmax = 0) AS inserted
+		), ` + responseCountCTE(1, "inserted") + `
+		SELECT id, inserted FROM changed
+	`
+
+	err = ObserveWrite(TableResponses, func() error {
+		return q.db.QueryRowContext(
+			ctx,
+			query,
+			r.ID,
+			r.SurveyID,
+			r.VoterDID,
+			r.VoterSession,
+			r.RecordURI,
+			r.RecordCID,
+			answersJSON,
+			r.CreatedAt,
+			r.DedupKey,
+		).Scan(&r.ID, &inserted)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert response: %w", err)
+	}
+
+	return inserted, nil
+}
+
+// UpsertResponses is UpsertResponse for many rows in a single statement.
+// Every row must have a DedupKey, and no two rows may share a survey and
+// DedupKey (Postgres refuses to update the same row twice in one statement).
+// On return each r.ID is the stored row's ID and inserted[i] reports whether
+// rs[i] created a new row; new rows are counted toward their surveys'
+// response_count. The statement fails as a whole if any row fails.
+func (q *Queries) UpsertResponses(ctx context.Context, rs []*models.Response) (inserted []bool, err error) {
+	if len(rs) == 0 {
+		return nil, nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`
+		WITH changed AS (
+			INSERT INTO responses (id, survey_id, voter_did, voter_session, record_uri, record_cid, answers, created_at, dedup_key)
+			VALUES `)
+
+	args := make([]interface{}, 0, len(rs)*9)
+	index := make(map[string]int, len(rs))
+	for i, r := range rs {
+		if r.DedupKey == nil {
+			return nil, fmt.Errorf("upsert requires a dedup key")
+		}
+		answersJSON, err := json.Marshal(r.Answers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal response answers: %w", err)
+		}
+
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+		args = append(args,
+			r.ID,
+			r.SurveyID,
+			r.VoterDID,
+			r.VoterSession,
+			r.RecordURI,
+			r.RecordCID,
+			answersJSON,
+			r.CreatedAt,
+			r.DedupKey,
+		)
+		index[r.SurveyID.String()+"|"+*r.DedupKey] = i
+	}
+
+	// RETURNING order isn't guaranteed, so rows are matched back by key
+	query.WriteString(`
+			ON CONFLICT (survey_id, dedup_key) WHERE dedup_key IS NOT NULL DO UPDATE
+			SET answers = EXCLUDED.answers,
+			    record_uri = EXCLUDED.record_uri,
+			    record_cid = EXCLUDED.record_cid,
+			    voter_did = EXCLUDED.voter_did
+			RETURNING id, survey_id, dedup_key, (xmax = 0) AS inserted
+		), ` + responseCountCTE(1, "inserted") + `
+		SELECT id, survey_id, dedup_key, inserted FROM changed
+	`)
+
+	// The statement runs until its RETURNING rows are drained, so the whole
+	// read is timed as one write
+	inserted = make([]bool, len(rs))
+	err = ObserveWrite(TableResponses, func() error {
+		rows, err := q.db.QueryContext(ctx, query.String(), args...)
+		if err != nil {
+			return fmt.Errorf("failed to upsert responses: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				id       uuid.UUID
+				surveyID uuid.UUID
+				dedupKey string
+				isNew    bool
+			)
+			if err := rows.Scan(&id, &surveyID, &dedupKey, &isNew); err != nil {
+				return fmt.Errorf("failed to scan upserted response: %w", err)
+			}
+			i, ok := index[surveyID.String()+"|"+dedupKey]
+			if !ok {
+				return fmt.Errorf("upsert returned unexpected response %s", id)
+			}
+			rs[i].ID = id
+			inserted[i] = isNew
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to upsert responses: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
 // GetResponseByID retrieves a response by its ID
 func (q *Queries) GetResponseByID(ctx context.Context, id uuid.UUID) (*models.Response, error) {
 	query := `
@@ -440,12 +912,12 @@ func (q *Queries) GetResponseBySurveyAndVoter(ctx context.Context, surveyID uuid
 	return response, nil
 }
 
-// ListResponsesBySurvey retrieves all responses for a survey
+// ListResponsesBySurvey retrieves all visible responses for a survey
 func (q *Queries) ListResponsesBySurvey(ctx context.Context, surveyID uuid.UUID) ([]*models.Response, error) {
 	query := `
 		SELECT id, survey_id, voter_did, voter_session, record_uri, record_cid, answers, created_at
 		FROM responses
-		WHERE survey_id = $1
+		WHERE survey_id = $1 AND hidden_at IS NULL
 		ORDER BY created_at ASC
 	`
 
@@ -489,9 +961,9 @@ func (q *Queries) ListResponsesBySurvey(ctx context.Context, surveyID uuid.UUID)
 	return responses, nil
 }
 
-// CountResponsesBySurvey counts the number of responses for a survey
+// CountResponsesBySurvey counts the number of visible responses for a survey
 func (q *Queries) CountResponsesBySurvey(ctx context.Context, surveyID uuid.UUID) (int, error) {
-	query := `SELECT COUNT(*) FROM responses WHERE survey_id = $1`
+	query := `SELECT COUNT(*) FROM responses WHERE survey_id = $1 AND hidden_at IS NULL`
 
 	var count int
 	err := q.db.QueryRowContext(ctx, query, surveyID).Scan(&count)
@@ -502,6 +974,37 @@ func (q *Queries) CountResponsesBySurvey(ctx context.Context, surveyID uuid.UUID
 	return count, nil
 }
 
+// GetAnsweredQuestionIDs returns the distinct question IDs that have at least
+// one stored answer for a survey
+func (q *Queries) GetAnsweredQuestionIDs(ctx context.Context, surveyID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT DISTINCT jsonb_object_keys(answers)
+		FROM responses
+		WHERE survey_id = $1
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query answered questions: %w", err)
+	}
+	defer rows.Close()
+
+	var questionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan question ID: %w", err)
+		}
+		questionIDs = append(questionIDs, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating answered questions: %w", err)
+	}
+
+	return questionIDs, nil
+}
+
 // GetResponseByRecordURI retrieves a response by its ATProto record URI
 func (q *Queries) GetResponseByRecordURI(ctx context.Context, recordURI string) (*models.Response, error) {
 	query := `
@@ -552,7 +1055,11 @@ func (q *Queries) UpdateResponseAnswers(ctx context.Context, id uuid.UUID, answe
 		WHERE id = $1
 	`
 
-	result, err := q.db.ExecContext(ctx, query, id, answersJSON, cid)
+	var result sql.Result
+	err = ObserveWrite(TableResponses, func() (err error) {
+		result, err = q.db.ExecContext(ctx, query, id, answersJSON, cid)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update response: %w", err)
 	}
@@ -569,35 +1076,48 @@ func (q *Queries) UpdateResponseAnswers(ctx context.Context, id uuid.UUID, answe
 	return nil
 }
 
-// DeleteResponseByRecordURI deletes a response by its ATProto record URI
-func (q *Queries) DeleteResponseByRecordURI(ctx context.Context, recordURI string) error {
-	query := `DELETE FROM responses WHERE record_uri = $1`
+// DeleteResponseByURI deletes a response by its ATProto record URI
+// (at://{did}/net.openmeet.survey.response/{rkey}) and removes it from its
+// survey's response_count. Deleting a response that was never indexed is a
+// no-op.
+func (q *Queries) DeleteResponseByURI(ctx context.Context, recordURI string) error {
+	query := `
+		WITH changed AS (
+			DELETE FROM responses WHERE record_uri = $1
+			RETURNING survey_id, hidden_at
+		), ` + responseCountCTE(-1, "hidden_at IS NULL") + `
+		SELECT COUNT(*) FROM changed
+	`
 
-	result, err := q.db.ExecContext(ctx, query, recordURI)
-	if err != nil {
+	if _, err := q.db.ExecContext(ctx, query, recordURI); err != nil {
 		return fmt.Errorf("failed to delete response: %w", err)
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
+	return nil
+}
 
-	// Not an error if response doesn't exist
-	if rows == 0 {
-		return nil
+// DeleteSurveyByURI soft-deletes a survey by its ATProto URI
+// (at://{did}/net.openmeet.survey/{rkey}), keeping the row and its responses
+// until RestoreSurvey or PurgeDeletedSurveys. Deleting a survey that was never
+// indexed or is already deleted is a no-op.
+func (q *Queries) DeleteSurveyByURI(ctx context.Context, uri string) error {
+	query := `UPDATE surveys SET deleted_at = NOW(), updated_at = NOW() WHERE uri = $1 AND deleted_at IS NULL`
+
+	if _, err := q.db.ExecContext(ctx, query, uri); err != nil {
+		return fmt.Errorf("failed to delete survey: %w", err)
 	}
 
 	return nil
 }
 
-// DeleteSurveyByURI deletes a survey by its ATProto URI
-func (q *Queries) DeleteSurveyByURI(ctx context.Context, uri string) error {
-	query := `DELETE FROM surveys WHERE uri = $1`
+// RestoreSurvey undoes DeleteSurveyByURI. Returns an error wrapping
+// sql.ErrNoRows if there is no soft-deleted survey with uri.
+func (q *Queries) RestoreSurvey(ctx context.Context, uri string) error {
+	query := `UPDATE surveys SET deleted_at = NULL, updated_at = NOW() WHERE uri = $1 AND deleted_at IS NOT NULL`
 
 	result, err := q.db.ExecContext(ctx, query, uri)
 	if err != nil {
-		return fmt.Errorf("failed to delete survey: %w", err)
+		return fmt.Errorf("failed to restore survey: %w", err)
 	}
 
 	rows, err := result.RowsAffected()
@@ -605,35 +1125,80 @@ func (q *Queries) DeleteSurveyByURI(ctx context.Context, uri string) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
-	// Not an error if survey doesn't exist
 	if rows == 0 {
-		return nil
+		return fmt.Errorf("deleted survey not found: %w", sql.ErrNoRows)
 	}
 
 	return nil
 }
 
+// PurgeDeletedSurveys permanently removes surveys soft-deleted before
+// deletedBefore and returns how many were removed. Responses are removed by
+// the ON DELETE CASCADE on responses.survey_id. A purge that removes anything
+// is recorded in the audit log in the same transaction.
+func (q *Queries) PurgeDeletedSurveys(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	// Served by idx_surveys_deleted_at
+	query := `DELETE FROM surveys WHERE deleted_at < $1`
+
+	var rows int64
+	err := q.WithTx(ctx, func(q *Queries) error {
+		result, err := q.db.ExecContext(ctx, query, deletedBefore)
+		if err != nil {
+			return fmt.Errorf("failed to purge deleted surveys: %w", err)
+		}
+
+		rows, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rows == 0 {
+			return nil
+		}
+
+		details := AuditDetails(map[string]interface{}{"deletedBefore": deletedBefore, "purged": rows})
+		return q.RecordAudit(ctx, AuditActorSystem, AuditActionPurgeSurveys, "", details)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
 // Results Aggregation
 
 // GetSurveyResults aggregates all responses for a survey into results
+// Soft-deleted surveys are treated as not found. Text answers are limited to
+// the first ResultsTextAnswerPreview per question; page through the rest
+// with GetTextAnswers.
 func (q *Queries) GetSurveyResults(ctx context.Context, surveyID uuid.UUID) (*models.SurveyResults, error) {
+	return q.getSurveyResults(ctx, surveyID, false)
+}
+
+// GetSurveyResultsIncludingDeleted is GetSurveyResults for admin use, also
+// aggregating soft-deleted surveys
+func (q *Queries) GetSurveyResultsIncludingDeleted(ctx context.Context, surveyID uuid.UUID) (*models.SurveyResults, error) {
+	return q.getSurveyResults(ctx, surveyID, true)
+}
+
+func (q *Queries) getSurveyResults(ctx context.Context, surveyID uuid.UUID, includeDeleted bool) (*models.SurveyResults, error) {
 	// First, get the survey to understand question structure
-	survey, err := q.GetSurveyByID(ctx, surveyID)
+	survey, err := q.getSurveyByID(ctx, surveyID, includeDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get survey: %w", err)
 	}
 
-	// Get all responses
-	responses, err := q.ListResponsesBySurvey(ctx, surveyID)
+	total, err := q.CountResponsesBySurvey(ctx, surveyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get responses: %w", err)
+		return nil, fmt.Errorf("failed to count responses: %w", err)
 	}
 
 	// Initialize results structure
 	results := &models.SurveyResults{
-		SurveyID:        surveyID,
-		TotalVotes:      len(responses),
-		QuestionResults: make(map[string]*models.QuestionResult),
+		SurveyID:          surveyID,
+		TotalVotes:        total,
+		QuestionResults:   make(map[string]*models.QuestionResult),
+		DefinitionVersion: survey.DefinitionVersion,
 	}
 
 	// Initialize question results based on survey definition
@@ -645,23 +1210,33 @@ func (q *Queries) GetSurveyResults(ctx context.Context, surveyID uuid.UUID) (*mo
 		}
 	}
 
-	// Aggregate responses
-	for _, response := range responses {
-		for questionID, answer := range response.Answers {
-			qResult, exists := results.QuestionResults[questionID]
-			if !exists {
-				continue // Skip answers for questions that no longer exist
-			}
-
-			// Count selected options
-			for _, optionID := range answer.SelectedOptions {
-				qResult.OptionCounts[optionID]++
-			}
+	// Aggregate in SQL, one query per kind of answer rather than loading
+	// every response
+	distribution, err := q.answerDistribution(ctx, responsesBySurveyID, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	for questionID, counts := range distribution {
+		qResult, exists := results.QuestionResults[questionID]
+		if !exists {
+			continue // Skip answers for questions that no longer exist
+		}
+		qResult.OptionCounts = counts
+	}
 
-			// Collect text answers
-			if answer.Text != "" {
-				qResult.TextAnswers = append(qResult.TextAnswers, answer.Text)
-			}
+	if err := q.addRatingDistribution(ctx, responsesBySurveyID, surveyID, results.QuestionResults); err != nil {
+		return nil, err
+	}
+	if err := q.addTextAnswers(ctx, responsesBySurveyID, surveyID, results.QuestionResults); err != nil {
+		return nil, err
+	}
+	if err := q.addOtherTexts(ctx, responsesBySurveyID, surveyID, results.QuestionResults); err != nil {
+		return nil, err
+	}
+	// Only an edit that removed answered questions leaves answers to count
+	if survey.DefinitionVersion > 1 {
+		if err := q.addRemovedQuestions(ctx, responsesBySurveyID, surveyID, results); err != nil {
+			return nil, err
 		}
 	}
 
@@ -690,13 +1265,17 @@ func (q *Queries) UpdateSurveyResults(ctx context.Context, surveyID uuid.UUID, r
 		return fmt.Errorf("survey not found")
 	}
 
+	// The API server publishes results itself, so drop its cached copy now
+	// rather than waiting for the consumer's invalidation
+	q.surveys.invalidate(func(s *models.Survey) bool { return s.ID == surveyID })
+
 	return nil
 }
 
 // GetSurveyByResultsURI retrieves a survey by its results URI
 func (q *Queries) GetSurveyByResultsURI(ctx context.Context, resultsURI string) (*models.Survey, error) {
 	query := `
-		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, created_at, updated_at
+		SELECT id, uri, cid, author_did, slug, title, description, definition, starts_at, ends_at, results_uri, results_cid, definition_version, version, lang, created_at, updated_at, record_updated_at, deleted_at
 		FROM surveys
 		WHERE results_uri = $1
 	`
@@ -717,8 +1296,13 @@ func (q *Queries) GetSurveyByResultsURI(ctx context.Context, resultsURI string)
 		&survey.EndsAt,
 		&survey.ResultsURI,
 		&survey.ResultsCID,
+		&survey.DefinitionVersion,
+		&survey.Version,
+		&survey.Lang,
 		&survey.CreatedAt,
 		&survey.UpdatedAt,
+		&survey.RecordUpdatedAt,
+		&survey.DeletedAt,
 	)
 
 	if err != nil {
@@ -740,7 +1324,7 @@ func (q *Queries) GetSurveyByResultsURI(ctx context.Context, resultsURI string)
 func (q *Queries) GetStats(ctx context.Context) (*models.Stats, error) {
 	query := `
 		SELECT
-			(SELECT COUNT(*) FROM surveys) as survey_count,
+			(SELECT COUNT(*) FROM surveys WHERE deleted_at IS NULL) as survey_count,
 			(SELECT COUNT(*) FROM responses) as response_count,
 			(
 				(SELECT COUNT(DISTINCT voter_did) FROM responses WHERE voter_did IS NOT NULL) +
@@ -0,0 +1,307 @@
+// Package queriestest provides an in-memory fake database for unit testing
+// code built on db.Queries without Postgres.
+//
+// The fake is a database/sql driver, so the *sql.DB it returns satisfies
+// db.DBTX and Rows, Row and Result behave exactly as they do against a real
+// database. Statements are answered by stubs matched on a fragment of their
+// SQL:
+//
+//	fake := queriestest.New(t)
+//	fake.Expect("FROM jetstream_cursor").Rows([]string{"time_us"}, []interface{}{int64(42)})
+//	fake.Expect("UPDATE jetstream_cursor").RowsAffected(1)
+//	queries := db.NewQueries(fake)
+//
+// A statement that matches no stub fails with ErrUnexpectedQuery.
+package queriestest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ErrUnexpectedQuery is returned for statements that match no stub
+var ErrUnexpectedQuery = errors.New("queriestest: unexpected query")
+
+// DB is an in-memory fake database. The embedded *sql.DB satisfies db.DBTX
+// and supports transactions, which commit and roll back as no-ops.
+type DB struct {
+	*sql.DB
+
+	mu    sync.Mutex
+	stubs []*Stub
+	calls []Call
+}
+
+// Call is a statement the fake received
+type Call struct {
+	Query string // whitespace collapsed to single spaces
+	Args  []interface{}
+}
+
+// Stub answers every statement containing its SQL fragment
+type Stub struct {
+	fragment     string
+	columns      []string
+	rows         [][]driver.Value
+	rowsAffected int64
+	err          error
+}
+
+// New returns an empty fake database, closed when the test ends
+func New(t testing.TB) *DB {
+	t.Helper()
+
+	fake := &DB{}
+	fake.DB = sql.OpenDB(connector{fake: fake})
+	t.Cleanup(func() { fake.DB.Close() })
+	return fake
+}
+
+// Expect adds a stub for statements containing fragment. Whitespace in both is
+// collapsed before matching. When several stubs match, the first added wins.
+func (f *DB) Expect(fragment string) *Stub {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stub := &Stub{fragment: normalize(fragment)}
+	f.stubs = append(f.stubs, stub)
+	return stub
+}
+
+// Calls returns the statements received so far, in order
+func (f *DB) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// CallsMatching returns the received statements containing fragment
+func (f *DB) CallsMatching(fragment string) []Call {
+	fragment = normalize(fragment)
+
+	var matching []Call
+	for _, call := range f.Calls() {
+		if strings.Contains(call.Query, fragment) {
+			matching = append(matching, call)
+		}
+	}
+	return matching
+}
+
+// Rows makes queries return the given rows. Each row has one value per
+// column; values are converted as database/sql converts query arguments, so
+// ints, strings, time.Time and driver.Valuer types such as uuid.UUID can be
+// used directly. Panics on a value that can't be converted.
+func (s *Stub) Rows(columns []string, rows ...[]interface{}) *Stub {
+	s.columns = columns
+	s.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			panic(fmt.Sprintf("queriestest: row %d has %d values for %d columns", i, len(row), len(columns)))
+		}
+		s.rows[i] = make([]driver.Value, len(row))
+		for j, value := range row {
+			converted, err := driver.DefaultParameterConverter.ConvertValue(value)
+			if err != nil {
+				panic(fmt.Sprintf("queriestest: row %d column %s: %v", i, columns[j], err))
+			}
+			s.rows[i][j] = converted
+		}
+	}
+	return s
+}
+
+// RowsAffected sets the rows affected reported to exec calls
+func (s *Stub) RowsAffected(n int64) *Stub {
+	s.rowsAffected = n
+	return s
+}
+
+// Err makes matching statements fail with err
+func (s *Stub) Err(err error) *Stub {
+	s.err = err
+	return s
+}
+
+// answer records a statement and returns the stub for it
+func (f *DB) answer(query string, args []driver.NamedValue) (*Stub, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query = normalize(query)
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.calls = append(f.calls, Call{Query: query, Args: values})
+
+	for _, stub := range f.stubs {
+		if strings.Contains(query, stub.fragment) {
+			if stub.err != nil {
+				return nil, stub.err
+			}
+			return stub, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnexpectedQuery, query)
+}
+
+// normalize collapses runs of whitespace to single spaces
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// connector hands database/sql connections to the fake
+type connector struct {
+	fake *DB
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{fake: c.fake}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+// fakeDriver only exists to satisfy driver.Connector; connections come from
+// the connector
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("queriestest: use queriestest.New")
+}
+
+type conn struct {
+	fake *DB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+// CheckNamedValue accepts any argument, resolving driver.Valuer types so
+// recorded args look like what Postgres would receive. Like database/sql, a
+// nil pointer is NULL rather than a call to its Value method.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if rv := reflect.ValueOf(nv.Value); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		nv.Value = nil
+		return nil
+	}
+	if valuer, ok := nv.Value.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		nv.Value = value
+	}
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stub, err := c.fake.answer(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(stub.rowsAffected), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stub, err := c.fake.answer(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: stub.columns, values: stub.rows}, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// named converts positional arguments from the legacy Stmt methods
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
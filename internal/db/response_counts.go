@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// responseCountCTE returns a CTE that adds delta (+1 or -1) to
+// surveys.response_count once for every row of the preceding CTE named
+// "changed" that matches where. changed must return survey_id. Keeping the
+// adjustment in the same statement as the write means the count can't drift
+// even outside a transaction.
+func responseCountCTE(delta int, where string) string {
+	return fmt.Sprintf(`counted AS (
+			UPDATE surveys s
+			SET response_count = s.response_count + c.n * %d
+			FROM (SELECT survey_id, COUNT(*) AS n FROM changed WHERE %s GROUP BY survey_id) c
+			WHERE s.id = c.survey_id
+		)`, delta, where)
+}
+
+// GetSurveyCounts returns the number of visible responses for each survey
+// URI, read from the count maintained as responses are written. URIs that
+// aren't indexed are missing from the result.
+func (q *Queries) GetSurveyCounts(ctx context.Context, uris []string) (map[string]int, error) {
+	counts := make(map[string]int, len(uris))
+	if len(uris) == 0 {
+		return counts, nil
+	}
+
+	placeholders := make([]string, len(uris))
+	args := make([]interface{}, len(uris))
+	for i, uri := range uris {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = uri
+	}
+	query := `SELECT uri, response_count FROM surveys WHERE uri IN (` + strings.Join(placeholders, ", ") + `)`
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query survey counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uri string
+		var count int
+		if err := rows.Scan(&uri, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan survey count: %w", err)
+		}
+		counts[uri] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating survey counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetResponseCount returns the number of visible responses to a survey, read
+// from the count maintained as responses are written. Returns an error
+// wrapping sql.ErrNoRows if there's no such survey.
+func (q *Queries) GetResponseCount(ctx context.Context, surveyID uuid.UUID) (int, error) {
+	var count int
+	if err := q.db.QueryRowContext(ctx, `SELECT response_count FROM surveys WHERE id = $1`, surveyID).Scan(&count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("survey not found: %w", err)
+		}
+		return 0, fmt.Errorf("failed to get response count: %w", err)
+	}
+	return count, nil
+}
+
+// RecountSurveyResponses recomputes a survey's response count from its
+// responses, repairing any drift, and returns the new count. Returns an error
+// wrapping sql.ErrNoRows if the survey isn't indexed.
+func (q *Queries) RecountSurveyResponses(ctx context.Context, uri string) (int, error) {
+	query := `
+		UPDATE surveys s
+		SET response_count = (SELECT COUNT(*) FROM responses r WHERE r.survey_id = s.id AND r.hidden_at IS NULL)
+		WHERE s.uri = $1
+		RETURNING s.response_count
+	`
+
+	var count int
+	if err := q.db.QueryRowContext(ctx, query, uri).Scan(&count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("survey not found: %w", err)
+		}
+		return 0, fmt.Errorf("failed to recount survey responses: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Retry policy for ExecWithRetry
+const (
+	// RetryBaseDelay is the wait before the first retry; it doubles each time
+	RetryBaseDelay = 50 * time.Millisecond
+
+	// RetryMaxDelay caps the wait between retries
+	RetryMaxDelay = 2 * time.Second
+
+	// DefaultRetryBudget bounds retries when the context has no deadline
+	DefaultRetryBudget = 30 * time.Second
+)
+
+// Operations labelled on DBRetries
+const (
+	OpIngest        = "ingest"
+	OpCursor        = "cursor"
+	OpResponseBatch = "response_batch"
+)
+
+// Transient error reasons for DBRetries
+const (
+	RetryReasonConnection         = "connection"
+	RetryReasonSerialization      = "serialization"
+	RetryReasonDeadlock           = "deadlock"
+	RetryReasonTooManyConnections = "too_many_connections"
+)
+
+// DBRetries counts retries of transient database errors
+var DBRetries = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "survey_db_retries_total",
+		Help: "Total number of database operations retried after a transient error",
+	},
+	[]string{"op", "reason"}, // reason: connection, serialization, deadlock, too_many_connections
+)
+
+// sqlStater is implemented by both pgconn.PgError and pq.Error
+type sqlStater interface {
+	SQLState() string
+}
+
+// transientReason reports whether err is worth retrying, and why. Constraint
+// violations, syntax errors and the like are permanent and return false, as do
+// context errors: the caller has given up.
+func transientReason(err error) (string, bool) {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "", false
+	}
+
+	var stater sqlStater
+	if errors.As(err, &stater) {
+		code := stater.SQLState()
+		switch {
+		case code == "40001": // serialization_failure
+			return RetryReasonSerialization, true
+		case code == "40P01": // deadlock_detected
+			return RetryReasonDeadlock, true
+		case code == "53300": // too_many_connections
+			return RetryReasonTooManyConnections, true
+		case strings.HasPrefix(code, "08"), // connection_exception
+			code == "57P01", code == "57P02", code == "57P03": // server shutting down or starting up
+			return RetryReasonConnection, true
+		}
+		return "", false
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return RetryReasonConnection, true
+	}
+
+	return "", false
+}
+
+// ExecWithRetry runs fn, retrying with exponential backoff while it fails
+// with a transient error (see transientReason). It gives up when the next
+// attempt would start after ctx's deadline, or after DefaultRetryBudget if
+// ctx has none, and returns fn's last error.
+//
+// fn must be a whole unit of work that is safe to repeat, such as an
+// idempotent statement or a complete WithTx call: once a statement fails inside
+// a transaction, Postgres rejects everything else in it, so retrying a single
+// statement there can't succeed.
+func ExecWithRetry(ctx context.Context, op string, fn func() error) error {
+	return execWithRetry(ctx, op, fn, time.Now, sleepContext)
+}
+
+func execWithRetry(ctx context.Context, op string, fn func() error, now func() time.Time, sleep func(context.Context, time.Duration) bool) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = now().Add(DefaultRetryBudget)
+	}
+
+	delay := RetryBaseDelay
+	for {
+		err := fn()
+		reason, transient := transientReason(err)
+		if !transient {
+			return err
+		}
+		if now().Add(delay).After(deadline) {
+			return err
+		}
+
+		DBRetries.WithLabelValues(op, reason).Inc()
+		if !sleep(ctx, delay) {
+			return err
+		}
+
+		delay *= 2
+		if delay > RetryMaxDelay {
+			delay = RetryMaxDelay
+		}
+	}
+}
+
+// sleepContext waits for d or until ctx is cancelled, reporting whether the
+// full delay elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTransientReason(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+		transient  bool
+	}{
+		{"serialization failure", &pq.Error{Code: "40001"}, RetryReasonSerialization, true},
+		{"deadlock", &pq.Error{Code: "40P01"}, RetryReasonDeadlock, true},
+		{"too many connections", &pq.Error{Code: "53300"}, RetryReasonTooManyConnections, true},
+		{"connection failure", &pq.Error{Code: "08006"}, RetryReasonConnection, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, RetryReasonConnection, true},
+		{"cannot connect now", &pq.Error{Code: "57P03"}, RetryReasonConnection, true},
+		{"pgx deadlock", &pgconn.PgError{Code: "40P01"}, RetryReasonDeadlock, true},
+		{"wrapped", fmt.Errorf("failed to insert: %w", &pq.Error{Code: "40001"}), RetryReasonSerialization, true},
+		{"connection refused", fmt.Errorf("failed to connect: %w", refused), RetryReasonConnection, true},
+		{"connection reset", reset, RetryReasonConnection, true},
+		{"bad connection", driver.ErrBadConn, RetryReasonConnection, true},
+
+		{"unique violation", &pq.Error{Code: "23505"}, "", false},
+		{"foreign key violation", &pgconn.PgError{Code: "23503"}, "", false},
+		{"syntax error", &pq.Error{Code: "42601"}, "", false},
+		{"undefined table", &pq.Error{Code: "42P01"}, "", false},
+		{"statement timeout", &pq.Error{Code: "57014"}, "", false},
+		{"no rows", sql.ErrNoRows, "", false},
+		{"deadline exceeded", context.DeadlineExceeded, "", false},
+		{"canceled", fmt.Errorf("query: %w", context.Canceled), "", false},
+		{"nil", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, transient := transientReason(tt.err)
+			if transient != tt.transient || reason != tt.wantReason {
+				t.Errorf("transientReason(%v) = %q, %v; want %q, %v", tt.err, reason, transient, tt.wantReason, tt.transient)
+			}
+		})
+	}
+}
+
+// fakeClock advances only when the retry loop sleeps
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) bool {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err() == nil
+}
+
+func TestExecWithRetry(t *testing.T) {
+	deadlock := &pq.Error{Code: "40P01"}
+
+	t.Run("retries transient errors until success", func(t *testing.T) {
+		const op = "test_retry_success"
+		clock := &fakeClock{now: time.Now()}
+		attempts := 0
+		err := execWithRetry(context.Background(), op, func() error {
+			attempts++
+			if attempts < 4 {
+				return deadlock
+			}
+			return nil
+		}, clock.Now, clock.Sleep)
+
+		if err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+		if attempts != 4 {
+			t.Errorf("Expected 4 attempts, got %d", attempts)
+		}
+		want := []time.Duration{RetryBaseDelay, 2 * RetryBaseDelay, 4 * RetryBaseDelay}
+		if fmt.Sprint(clock.sleeps) != fmt.Sprint(want) {
+			t.Errorf("Expected backoff %v, got %v", want, clock.sleeps)
+		}
+		if got := testutil.ToFloat64(DBRetries.WithLabelValues(op, RetryReasonDeadlock)); got != 3 {
+			t.Errorf("Expected 3 retries counted, got %v", got)
+		}
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		attempts := 0
+		violation := &pq.Error{Code: "23505"}
+		err := execWithRetry(context.Background(), "test_retry_permanent", func() error {
+			attempts++
+			return violation
+		}, clock.Now, clock.Sleep)
+
+		if !errors.Is(err, violation) {
+			t.Errorf("Expected the constraint violation, got %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("stops at the context deadline", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(time.Second))
+		defer cancel()
+
+		attempts := 0
+		err := execWithRetry(ctx, "test_retry_deadline", func() error {
+			attempts++
+			return deadlock
+		}, clock.Now, clock.Sleep)
+
+		if !errors.Is(err, deadlock) {
+			t.Errorf("Expected the last error, got %v", err)
+		}
+		// 50+100+200+400ms fits in a second; the next 800ms wait would not
+		if attempts != 5 {
+			t.Errorf("Expected 5 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("caps the delay and bounds retries without a deadline", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		start := clock.now
+		execWithRetry(context.Background(), "test_retry_budget", func() error {
+			return deadlock
+		}, clock.Now, clock.Sleep)
+
+		for _, d := range clock.sleeps {
+			if d > RetryMaxDelay {
+				t.Errorf("Delay %v exceeds RetryMaxDelay", d)
+			}
+		}
+		if elapsed := clock.now.Sub(start); elapsed > DefaultRetryBudget {
+			t.Errorf("Retried for %v, longer than DefaultRetryBudget", elapsed)
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		ctx, cancel := context.WithCancel(context.Background())
+		attempts := 0
+		execWithRetry(ctx, "test_retry_cancel", func() error {
+			attempts++
+			cancel()
+			return deadlock
+		}, clock.Now, clock.Sleep)
+
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// normalizeSearchQuery collapses whitespace and truncates the query to
+// models.MaxSearchQueryLength runes
+func normalizeSearchQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if runes := []rune(query); len(runes) > models.MaxSearchQueryLength {
+		query = strings.TrimSpace(string(runes[:models.MaxSearchQueryLength]))
+	}
+	return query
+}
+
+// SearchSurveys finds discoverable, non-hidden, non-deleted surveys whose title or
+// description matches query, most relevant first. The query uses web search
+// syntax ("quoted phrases", -exclusions, or), so any user input is safe to
+// pass; an empty query matches nothing.
+func (q *Queries) SearchSurveys(ctx context.Context, query string, limit, offset int) ([]*models.SurveySearchResult, error) {
+	query = normalizeSearchQuery(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	// Served by idx_surveys_search_vector
+	sqlQuery := `
+		SELECT s.id, s.uri, s.cid, s.author_did, s.slug, s.title, s.description, s.definition, s.starts_at, s.ends_at, s.results_uri, s.results_cid, s.definition_version, s.version, s.lang, s.created_at, s.updated_at, s.record_updated_at,
+		       (SELECT COUNT(*) FROM responses r WHERE r.survey_id = s.id AND r.hidden_at IS NULL) AS response_count,
+		       ` + surveyStatusSQL("$4") + ` AS status
+		FROM surveys s, websearch_to_tsquery('simple', $1) AS tsq
+		WHERE s.search_vector @@ tsq
+		  AND s.hidden_at IS NULL
+		  AND s.deleted_at IS NULL
+		  AND (s.definition->>'discoverable')::boolean IS TRUE
+		ORDER BY ts_rank(s.search_vector, tsq) DESC, s.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := q.db.QueryContext(ctx, sqlQuery, query, limit, offset, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to search surveys: %w", err)
+	}
+	defer rows.Close()
+
+	var results []*models.SurveySearchResult
+	for rows.Next() {
+		survey := &models.Survey{}
+		result := &models.SurveySearchResult{Survey: survey}
+		var defJSON []byte
+
+		err := rows.Scan(
+			&survey.ID,
+			&survey.URI,
+			&survey.CID,
+			&survey.AuthorDID,
+			&survey.Slug,
+			&survey.Title,
+			&survey.Description,
+			&defJSON,
+			&survey.StartsAt,
+			&survey.EndsAt,
+			&survey.ResultsURI,
+			&survey.ResultsCID,
+			&survey.DefinitionVersion,
+			&survey.Version,
+			&survey.Lang,
+			&survey.CreatedAt,
+			&survey.UpdatedAt,
+			&survey.RecordUpdatedAt,
+			&result.ResponseCount,
+			&survey.Status,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan survey: %w", err)
+		}
+
+		// Unmarshal JSONB definition
+		if err := json.Unmarshal(defJSON, &survey.Definition); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal survey definition: %w", err)
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating surveys: %w", err)
+	}
+
+	return results, nil
+}
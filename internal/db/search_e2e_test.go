@@ -0,0 +1,95 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestSearchSurveys tests full-text search over discoverable surveys
+func TestSearchSurveys(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	// A made-up word keeps results independent of other surveys in the database
+	word := "zq" + uuid.NewString()[:8]
+
+	create := func(title, description string, discoverable bool) *models.Survey {
+		survey := &models.Survey{
+			ID:          uuid.New(),
+			Slug:        "search-" + uuid.NewString()[:8],
+			Title:       title,
+			Description: &description,
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{{
+					ID:      "q1",
+					Text:    "Pick one",
+					Type:    models.QuestionTypeSingle,
+					Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+				}},
+				Discoverable: discoverable,
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+		return survey
+	}
+
+	inTitle := create("Lunch "+word, "Where should we eat?", true)
+	inDescription := create("Team lunch", "Vote for the "+word+" place", true)
+	create("Private "+word, "Only reachable by link", false)
+
+	session := "search-session"
+	if err := queries.CreateResponse(ctx, &models.Response{
+		ID:           uuid.New(),
+		SurveyID:     inDescription.ID,
+		VoterSession: &session,
+		Answers:      map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}},
+		CreatedAt:    time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to create response: %v", err)
+	}
+
+	results, err := queries.SearchSurveys(ctx, word, 10, 0)
+	if err != nil {
+		t.Fatalf("SearchSurveys failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 discoverable matches, got %d", len(results))
+	}
+	if results[0].Survey.ID != inTitle.ID {
+		t.Errorf("Expected the title match to rank first, got %q", results[0].Survey.Title)
+	}
+	if results[1].ResponseCount != 1 {
+		t.Errorf("Expected 1 response on the description match, got %d", results[1].ResponseCount)
+	}
+
+	t.Run("empty and odd queries", func(t *testing.T) {
+		for _, query := range []string{"", "   ", `"unterminated`, "!!! & | :*", word + " -" + word} {
+			results, err := queries.SearchSurveys(ctx, query, 10, 0)
+			if err != nil {
+				t.Errorf("SearchSurveys(%q) failed: %v", query, err)
+			}
+			for _, r := range results {
+				if r.Survey.ID == inTitle.ID || r.Survey.ID == inDescription.ID {
+					t.Errorf("SearchSurveys(%q) unexpectedly matched %q", query, r.Survey.Title)
+				}
+			}
+		}
+	})
+}
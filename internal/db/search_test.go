@@ -0,0 +1,41 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestNormalizeSearchQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"whitespace only", " \t\n ", ""},
+		{"collapses whitespace", "  coffee \t  tea\n", "coffee tea"},
+		{"keeps search syntax", `"team lunch" -pizza or tacos`, `"team lunch" -pizza or tacos`},
+		{"keeps special characters", "50% off & <b>!", "50% off & <b>!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeSearchQuery(tt.query); got != tt.want {
+				t.Errorf("normalizeSearchQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("truncates long queries by rune", func(t *testing.T) {
+		got := normalizeSearchQuery(strings.Repeat("é", models.MaxSearchQueryLength+50))
+		if n := utf8.RuneCountInString(got); n != models.MaxSearchQueryLength {
+			t.Errorf("Expected %d runes, got %d", models.MaxSearchQueryLength, n)
+		}
+		if !utf8.ValidString(got) {
+			t.Error("Expected truncation to keep valid UTF-8")
+		}
+	})
+}
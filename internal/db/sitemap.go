@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SitemapSurvey is a survey's entry in the sitemap
+type SitemapSurvey struct {
+	Slug      string
+	UpdatedAt time.Time
+}
+
+// sitemapSurveysWhere selects the surveys search engines may list: those
+// their authors made discoverable, and not hidden or deleted
+const sitemapSurveysWhere = `
+		WHERE hidden_at IS NULL
+		  AND deleted_at IS NULL
+		  AND (definition->>'discoverable')::boolean IS TRUE`
+
+// CountSitemapSurveys counts the surveys StreamSitemapSurveys lists
+func (q *Queries) CountSitemapSurveys(ctx context.Context) (int, error) {
+	var count int
+	if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM surveys`+sitemapSurveysWhere).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sitemap surveys: %w", err)
+	}
+	return count, nil
+}
+
+// StreamSitemapSurveys calls fn with up to limit discoverable surveys,
+// oldest first, after skipping offset of them. Oldest first keeps each
+// survey on the same page of the sitemap as newer ones are added. Rows are
+// read from the database as fn consumes them. An error returned by fn stops
+// the iteration and is returned as is.
+func (q *Queries) StreamSitemapSurveys(ctx context.Context, offset, limit int, fn func(SitemapSurvey) error) error {
+	// Served by idx_surveys_created_at_id
+	query := `
+		SELECT slug, updated_at
+		FROM surveys` + sitemapSurveysWhere + `
+		ORDER BY created_at ASC, id ASC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return fmt.Errorf("failed to query sitemap surveys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var survey SitemapSurvey
+		if err := rows.Scan(&survey.Slug, &survey.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan sitemap survey: %w", err)
+		}
+		if err := fn(survey); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating sitemap surveys: %w", err)
+	}
+
+	return nil
+}
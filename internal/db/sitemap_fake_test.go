@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+)
+
+func TestSitemapSurveysFake(t *testing.T) {
+	updated := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("streams a page of discoverable surveys", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT slug, updated_at").Rows([]string{"slug", "updated_at"},
+			[]interface{}{"lunch", updated},
+			[]interface{}{"retro", updated},
+		)
+
+		var slugs []string
+		err := NewQueries(fake).StreamSitemapSurveys(context.Background(), 100000, 50000, func(s SitemapSurvey) error {
+			if !s.UpdatedAt.Equal(updated) {
+				t.Errorf("Expected lastmod %v, got %v", updated, s.UpdatedAt)
+			}
+			slugs = append(slugs, s.Slug)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamSitemapSurveys failed: %v", err)
+		}
+		if strings.Join(slugs, ",") != "lunch,retro" {
+			t.Errorf("Expected lunch,retro, got %v", slugs)
+		}
+
+		call := fake.Calls()[0]
+		for _, want := range []string{"deleted_at IS NULL", "hidden_at IS NULL", "'discoverable'", "ORDER BY created_at ASC, id ASC"} {
+			if !strings.Contains(call.Query, want) {
+				t.Errorf("Expected query to contain %q, got %s", want, call.Query)
+			}
+		}
+		if call.Args[0] != 50000 || call.Args[1] != 100000 {
+			t.Errorf("Unexpected arguments %v", call.Args)
+		}
+	})
+
+	t.Run("stops at fn's error", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT slug, updated_at").Rows([]string{"slug", "updated_at"},
+			[]interface{}{"lunch", updated},
+			[]interface{}{"retro", updated},
+		)
+
+		stop := errors.New("client went away")
+		calls := 0
+		err := NewQueries(fake).StreamSitemapSurveys(context.Background(), 0, 10, func(SitemapSurvey) error {
+			calls++
+			return stop
+		})
+		if err != stop || calls != 1 {
+			t.Errorf("Expected to stop after one survey with %v, got %v after %d", stop, err, calls)
+		}
+	})
+
+	t.Run("counts the same surveys", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("SELECT COUNT(*)").Rows([]string{"count"}, []interface{}{3})
+
+		count, err := NewQueries(fake).CountSitemapSurveys(context.Background())
+		if err != nil || count != 3 {
+			t.Fatalf("Expected 3, got %d, %v", count, err)
+		}
+		if !strings.Contains(fake.Calls()[0].Query, strings.Join(strings.Fields(sitemapSurveysWhere), " ")) {
+			t.Errorf("Expected the sitemap's filter, got %s", fake.Calls()[0].Query)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// ResponseRow is one response as StreamResponses reads it
+type ResponseRow struct {
+	ID          uuid.UUID
+	VoterDID    *string // nil for web guests
+	VoterHandle *string // last known handle of VoterDID, nil if none is stored
+	Answers     map[string]models.Answer
+	CreatedAt   time.Time
+}
+
+// StreamResponses calls fn with each visible response to the survey at
+// surveyURI, oldest first. Rows are read from the database as fn consumes
+// them rather than loaded up front, so it suits surveys of any size. An
+// error returned by fn stops the iteration and is returned as is.
+func (q *Queries) StreamResponses(ctx context.Context, surveyURI string, fn func(ResponseRow) error) error {
+	query := `
+		SELECT r.id, r.voter_did, h.handle, r.answers, r.created_at
+		FROM responses r
+		JOIN surveys s ON s.id = r.survey_id
+		LEFT JOIN handles h ON h.did = r.voter_did
+		WHERE s.uri = $1 AND r.hidden_at IS NULL
+		ORDER BY r.created_at ASC, r.id ASC
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, surveyURI)
+	if err != nil {
+		return fmt.Errorf("failed to query responses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row ResponseRow
+		var answersJSON []byte
+
+		if err := rows.Scan(&row.ID, &row.VoterDID, &row.VoterHandle, &answersJSON, &row.CreatedAt); err != nil {
+			return fmt.Errorf("failed to scan response: %w", err)
+		}
+
+		// Unmarshal JSONB answers
+		if err := json.Unmarshal(answersJSON, &row.Answers); err != nil {
+			return fmt.Errorf("failed to unmarshal response answers: %w", err)
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating responses: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestStreamResponses streams a survey's responses in order with handles
+func TestStreamResponses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	queries := NewQueries(db)
+	ctx := context.Background()
+
+	author := "did:plc:export" + uuid.NewString()[:8]
+	uri := "at://" + author + "/net.openmeet.survey/" + uuid.NewString()[:8]
+	survey := &models.Survey{
+		ID:        uuid.New(),
+		URI:       &uri,
+		AuthorDID: &author,
+		Slug:      "export-" + uuid.NewString()[:8],
+		Title:     "Export",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{{
+				ID:      "q1",
+				Text:    "Pick one",
+				Type:    models.QuestionTypeSingle,
+				Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+			}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := queries.CreateSurvey(ctx, survey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	defer db.Exec(`DELETE FROM surveys WHERE id = $1`, survey.ID)
+
+	named := "did:plc:named" + uuid.NewString()[:8]
+	hidden := "did:plc:hidden" + uuid.NewString()[:8]
+	if err := queries.UpsertHandle(ctx, named, "named.test"); err != nil {
+		t.Fatalf("Failed to store handle: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	guest := "export-guest"
+	responses := []*models.Response{
+		{ID: uuid.New(), VoterDID: &named, Answers: map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}}, CreatedAt: base},
+		{ID: uuid.New(), VoterDID: &hidden, Answers: map[string]models.Answer{"q1": {SelectedOptions: []string{"b"}}}, CreatedAt: base.Add(time.Minute)},
+		{ID: uuid.New(), VoterSession: &guest, Answers: map[string]models.Answer{"q1": {SelectedOptions: []string{"b"}}}, CreatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, r := range responses {
+		r.SurveyID = survey.ID
+		if err := queries.CreateResponse(ctx, r); err != nil {
+			t.Fatalf("Failed to create response: %v", err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE responses SET hidden_at = NOW() WHERE voter_did = $1`, hidden); err != nil {
+		t.Fatalf("Failed to hide response: %v", err)
+	}
+
+	var rows []ResponseRow
+	err := queries.StreamResponses(ctx, uri, func(row ResponseRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamResponses failed: %v", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 visible responses, got %d", len(rows))
+	}
+	if rows[0].ID != responses[0].ID || rows[1].ID != responses[2].ID {
+		t.Errorf("Expected responses oldest first without the hidden one")
+	}
+	if rows[0].VoterHandle == nil || *rows[0].VoterHandle != "named.test" {
+		t.Errorf("Expected the stored handle, got %v", rows[0].VoterHandle)
+	}
+	if rows[1].VoterDID != nil || rows[1].VoterHandle != nil {
+		t.Errorf("Expected no DID or handle for a guest")
+	}
+	if got := rows[0].Answers["q1"].SelectedOptions; len(got) != 1 || got[0] != "a" {
+		t.Errorf("Expected answer a, got %v", got)
+	}
+
+	t.Run("callback error stops the stream", func(t *testing.T) {
+		stop := errors.New("stop")
+		calls := 0
+		err := queries.StreamResponses(ctx, uri, func(ResponseRow) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) {
+			t.Errorf("Expected the callback's error, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("Expected 1 call, got %d", calls)
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Survey cache defaults
+const (
+	DefaultSurveyCacheSize = 1000
+	DefaultSurveyCacheTTL  = time.Minute
+)
+
+// SurveyInvalidationChannel is the Postgres NOTIFY channel the consumer uses
+// to tell API servers which cached surveys changed. The payload is a survey
+// URI or an author DID.
+const SurveyInvalidationChannel = "survey_cache_invalidate"
+
+// Eviction reasons for SurveyCacheEvictions
+const (
+	EvictionCapacity    = "capacity"
+	EvictionExpired     = "expired"
+	EvictionInvalidated = "invalidated"
+)
+
+var (
+	// SurveyCacheHits counts GetSurveyBySlug calls answered from the cache
+	SurveyCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "survey_cache_hits_total",
+		Help: "Total number of survey lookups by slug served from the cache",
+	})
+
+	// SurveyCacheMisses counts GetSurveyBySlug calls that went to the database
+	SurveyCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "survey_cache_misses_total",
+		Help: "Total number of survey lookups by slug that queried the database",
+	})
+
+	// SurveyCacheEvictions counts surveys dropped from the cache
+	SurveyCacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_cache_evictions_total",
+			Help: "Total number of surveys removed from the cache",
+		},
+		[]string{"reason"}, // capacity, expired, invalidated
+	)
+)
+
+// SurveyCacheConfig sizes the GetSurveyBySlug cache. A zero TTL disables it.
+type SurveyCacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+// Enabled reports whether the config turns the cache on
+func (c SurveyCacheConfig) Enabled() bool {
+	return c.TTL > 0 && c.Size > 0
+}
+
+// SurveyCacheConfigFromEnv reads SURVEY_CACHE_SIZE (entries) and
+// SURVEY_CACHE_TTL (a duration such as "30s"; "0" disables the cache)
+func SurveyCacheConfigFromEnv() (SurveyCacheConfig, error) {
+	config := SurveyCacheConfig{Size: DefaultSurveyCacheSize, TTL: DefaultSurveyCacheTTL}
+
+	if value := os.Getenv("SURVEY_CACHE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			return SurveyCacheConfig{}, fmt.Errorf("invalid SURVEY_CACHE_SIZE: %q", value)
+		}
+		config.Size = size
+	}
+
+	if value := os.Getenv("SURVEY_CACHE_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return SurveyCacheConfig{}, fmt.Errorf("invalid SURVEY_CACHE_TTL: %q", value)
+		}
+		config.TTL = ttl
+	}
+
+	return config, nil
+}
+
+// surveyCache is a least-recently-used cache of surveys by slug whose
+// entries also expire after a TTL, bounding how stale a survey can be when an
+// invalidation is missed
+type surveyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type surveyCacheEntry struct {
+	slug    string
+	survey  *models.Survey
+	expires time.Time
+}
+
+// newSurveyCache returns nil when config disables the cache; a nil
+// *surveyCache is a valid, always-empty cache
+func newSurveyCache(config SurveyCacheConfig) *surveyCache {
+	if !config.Enabled() {
+		return nil
+	}
+	return &surveyCache{
+		ttl:     config.TTL,
+		size:    config.Size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the cached survey for slug, if there is one that
+// hasn't expired
+func (c *surveyCache) get(slug string, now time.Time) (*models.Survey, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[slug]
+	if !ok {
+		SurveyCacheMisses.Inc()
+		return nil, false
+	}
+	entry := elem.Value.(*surveyCacheEntry)
+	if !now.Before(entry.expires) {
+		c.remove(elem, EvictionExpired)
+		SurveyCacheMisses.Inc()
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	SurveyCacheHits.Inc()
+	survey := *entry.survey
+	return &survey, true
+}
+
+// set caches a copy of survey under slug, evicting the least recently used
+// entry if the cache is full
+func (c *surveyCache) set(slug string, survey *models.Survey, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := *survey
+	entry := &surveyCacheEntry{slug: slug, survey: &cached, expires: now.Add(c.ttl)}
+	if elem, ok := c.entries[slug]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[slug] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back(), EvictionCapacity)
+	}
+}
+
+// invalidate drops every cached survey for which match returns true
+func (c *surveyCache) invalidate(match func(*models.Survey) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if match(elem.Value.(*surveyCacheEntry).survey) {
+			c.remove(elem, EvictionInvalidated)
+		}
+		elem = next
+	}
+}
+
+// invalidateKey drops the survey with URI key, or every survey authored by
+// DID key
+func (c *surveyCache) invalidateKey(key string) {
+	c.invalidate(func(s *models.Survey) bool {
+		if strings.HasPrefix(key, "did:") {
+			return s.AuthorDID != nil && *s.AuthorDID == key
+		}
+		return s.URI != nil && *s.URI == key
+	})
+}
+
+// purge empties the cache
+func (c *surveyCache) purge() {
+	c.invalidate(func(*models.Survey) bool { return true })
+}
+
+// remove must be called with mu held
+func (c *surveyCache) remove(elem *list.Element, reason string) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*surveyCacheEntry).slug)
+	SurveyCacheEvictions.WithLabelValues(reason).Inc()
+}
+
+// EnableSurveyCache puts a cache in front of GetSurveyBySlug. Call it once at
+// startup, before q is shared; it does nothing if config disables the cache.
+// Other processes that change surveys must call InvalidateSurvey or
+// InvalidateSurveysByAuthor, and ListenForSurveyInvalidations must be running
+// to receive them.
+func (q *Queries) EnableSurveyCache(config SurveyCacheConfig) {
+	q.surveys = newSurveyCache(config)
+}
+
+// InvalidateSurvey drops the survey with uri from this process's cache and
+// notifies every process listening on SurveyInvalidationChannel. Inside a
+// transaction the notification is only sent when it commits.
+func (q *Queries) InvalidateSurvey(ctx context.Context, uri string) error {
+	q.surveys.invalidateKey(uri)
+	return q.notifySurveyInvalidation(ctx, uri)
+}
+
+// InvalidateSurveysByAuthor is InvalidateSurvey for every survey authored by did
+func (q *Queries) InvalidateSurveysByAuthor(ctx context.Context, did string) error {
+	q.surveys.invalidateKey(did)
+	return q.notifySurveyInvalidation(ctx, did)
+}
+
+func (q *Queries) notifySurveyInvalidation(ctx context.Context, key string) error {
+	if _, err := q.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, SurveyInvalidationChannel, key); err != nil {
+		return fmt.Errorf("failed to notify survey invalidation: %w", err)
+	}
+	return nil
+}
+
+// ListenForSurveyInvalidations applies invalidations sent by other processes
+// to q's survey cache until ctx is cancelled. It holds its own connection,
+// reconnecting after errors; the cache is emptied on every (re)connect since
+// notifications sent while disconnected are lost. Returns immediately if the
+// cache is disabled.
+func (q *Queries) ListenForSurveyInvalidations(ctx context.Context, cfg Config) {
+	if q.surveys == nil {
+		return
+	}
+
+	listen(ctx, cfg, SurveyInvalidationChannel, q.surveys.purge, q.surveys.invalidateKey)
+}
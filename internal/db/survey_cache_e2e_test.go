@@ -0,0 +1,127 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestSurveyCacheInvalidation checks that cached surveys are served until the
+// consumer's invalidation, which reaches listeners only when it commits
+func TestSurveyCacheInvalidation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to load database config: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// The API server's view: a cached Queries with its listener running
+	api := NewQueries(db)
+	api.EnableSurveyCache(SurveyCacheConfig{Size: 10, TTL: time.Hour})
+	go api.ListenForSurveyInvalidations(ctx, cfg)
+
+	// A second listener so the test can tell when notifications arrive
+	listener, err := pgx.Connect(ctx, cfg.ConnectionString())
+	if err != nil {
+		t.Fatalf("Failed to connect listener: %v", err)
+	}
+	defer listener.Close(context.Background())
+	if _, err := listener.Exec(ctx, "LISTEN "+SurveyInvalidationChannel); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	consumer := NewQueries(db)
+	uri := "at://did:plc:cache" + uuid.NewString()[:8] + "/net.openmeet.survey/1"
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		URI:   &uri,
+		Slug:  "cache-" + uuid.NewString()[:8],
+		Title: "Before",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{{
+				ID:      "q1",
+				Text:    "Pick one",
+				Type:    models.QuestionTypeSingle,
+				Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+			}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := consumer.CreateSurvey(ctx, survey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+
+	title := func() string {
+		t.Helper()
+		got, err := api.GetSurveyBySlug(ctx, survey.Slug)
+		if err != nil {
+			t.Fatalf("GetSurveyBySlug failed: %v", err)
+		}
+		return got.Title
+	}
+
+	// Give the API listener time to connect, then warm the cache
+	time.Sleep(500 * time.Millisecond)
+	if got := title(); got != "Before" {
+		t.Fatalf("Expected %q, got %q", "Before", got)
+	}
+
+	update := func(newTitle string, fail bool) error {
+		return consumer.WithTx(ctx, func(q *Queries) error {
+			survey.Title = newTitle
+			if err := q.UpdateSurvey(ctx, survey); err != nil {
+				return err
+			}
+			if err := q.InvalidateSurvey(ctx, uri); err != nil {
+				return err
+			}
+			if fail {
+				return errors.New("rolled back")
+			}
+			return nil
+		})
+	}
+
+	// A rolled back update sends nothing and the cached copy stays
+	update("Rolled back", true)
+	if got := title(); got != "Before" {
+		t.Errorf("Expected the cached %q, got %q", "Before", got)
+	}
+
+	// A committed update evicts the cached copy
+	if err := update("After", false); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	notification, err := listener.WaitForNotification(ctx)
+	if err != nil {
+		t.Fatalf("No invalidation received: %v", err)
+	}
+	if notification.Payload != uri {
+		t.Errorf("Expected payload %q, got %q", uri, notification.Payload)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for title() != "After" {
+		if time.Now().After(deadline) {
+			t.Fatal("Cached survey was not invalidated")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
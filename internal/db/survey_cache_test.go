@@ -0,0 +1,144 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func cachedSurvey(slug, uri, authorDID string) *models.Survey {
+	return &models.Survey{ID: uuid.New(), Slug: slug, URI: &uri, AuthorDID: &authorDID, Title: slug}
+}
+
+func TestSurveyCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("disabled by zero TTL", func(t *testing.T) {
+		cache := newSurveyCache(SurveyCacheConfig{Size: 10, TTL: 0})
+		if cache != nil {
+			t.Fatal("Expected a nil cache")
+		}
+		cache.set("a", cachedSurvey("a", "at://did:plc:a/net.openmeet.survey/1", "did:plc:a"), now)
+		if _, ok := cache.get("a", now); ok {
+			t.Error("Expected a disabled cache to miss")
+		}
+		cache.purge()
+	})
+
+	t.Run("hits return copies", func(t *testing.T) {
+		cache := newSurveyCache(SurveyCacheConfig{Size: 10, TTL: time.Minute})
+		cache.set("a", cachedSurvey("a", "at://did:plc:a/net.openmeet.survey/1", "did:plc:a"), now)
+
+		got, ok := cache.get("a", now)
+		if !ok || got.Title != "a" {
+			t.Fatalf("Expected a hit, got %v, %v", got, ok)
+		}
+		got.Title = "changed"
+		if again, _ := cache.get("a", now); again.Title != "a" {
+			t.Errorf("Caller mutation leaked into the cache: %q", again.Title)
+		}
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		cache := newSurveyCache(SurveyCacheConfig{Size: 10, TTL: time.Minute})
+		cache.set("a", cachedSurvey("a", "at://did:plc:a/net.openmeet.survey/1", "did:plc:a"), now)
+
+		if _, ok := cache.get("a", now.Add(59*time.Second)); !ok {
+			t.Error("Expected a hit before the TTL")
+		}
+		if _, ok := cache.get("a", now.Add(time.Minute)); ok {
+			t.Error("Expected a miss at the TTL")
+		}
+		if len(cache.entries) != 0 {
+			t.Errorf("Expected the expired entry to be removed, have %d", len(cache.entries))
+		}
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		cache := newSurveyCache(SurveyCacheConfig{Size: 2, TTL: time.Minute})
+		cache.set("a", cachedSurvey("a", "at://did:plc:a/net.openmeet.survey/1", "did:plc:a"), now)
+		cache.set("b", cachedSurvey("b", "at://did:plc:a/net.openmeet.survey/2", "did:plc:a"), now)
+		cache.get("a", now) // b is now least recently used
+		cache.set("c", cachedSurvey("c", "at://did:plc:a/net.openmeet.survey/3", "did:plc:a"), now)
+
+		if _, ok := cache.get("b", now); ok {
+			t.Error("Expected b to be evicted")
+		}
+		for _, slug := range []string{"a", "c"} {
+			if _, ok := cache.get(slug, now); !ok {
+				t.Errorf("Expected %s to be cached", slug)
+			}
+		}
+	})
+
+	t.Run("invalidates by URI or author", func(t *testing.T) {
+		cache := newSurveyCache(SurveyCacheConfig{Size: 10, TTL: time.Minute})
+		cache.set("a", cachedSurvey("a", "at://did:plc:a/net.openmeet.survey/1", "did:plc:a"), now)
+		cache.set("b", cachedSurvey("b", "at://did:plc:a/net.openmeet.survey/2", "did:plc:a"), now)
+		cache.set("c", cachedSurvey("c", "at://did:plc:c/net.openmeet.survey/1", "did:plc:c"), now)
+
+		cache.invalidateKey("at://did:plc:c/net.openmeet.survey/1")
+		if _, ok := cache.get("c", now); ok {
+			t.Error("Expected c to be invalidated by URI")
+		}
+		if _, ok := cache.get("a", now); !ok {
+			t.Error("Expected a to survive another survey's invalidation")
+		}
+
+		cache.invalidateKey("did:plc:a")
+		if len(cache.entries) != 0 {
+			t.Errorf("Expected the author's surveys to be invalidated, have %d", len(cache.entries))
+		}
+	})
+}
+
+func TestSurveyCacheConfigFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, err := SurveyCacheConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Size != DefaultSurveyCacheSize || config.TTL != DefaultSurveyCacheTTL {
+			t.Errorf("Expected defaults, got %+v", config)
+		}
+	})
+
+	t.Run("zero TTL disables", func(t *testing.T) {
+		t.Setenv("SURVEY_CACHE_TTL", "0")
+		config, err := SurveyCacheConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Enabled() {
+			t.Error("Expected the cache to be disabled")
+		}
+	})
+
+	t.Run("reads size and TTL", func(t *testing.T) {
+		t.Setenv("SURVEY_CACHE_SIZE", "50")
+		t.Setenv("SURVEY_CACHE_TTL", "5m")
+		config, err := SurveyCacheConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Size != 50 || config.TTL != 5*time.Minute {
+			t.Errorf("Got %+v", config)
+		}
+	})
+
+	for _, env := range []struct{ key, value string }{
+		{"SURVEY_CACHE_SIZE", "0"},
+		{"SURVEY_CACHE_SIZE", "many"},
+		{"SURVEY_CACHE_TTL", "-1s"},
+		{"SURVEY_CACHE_TTL", "60"},
+	} {
+		t.Run("rejects "+env.key+"="+env.value, func(t *testing.T) {
+			t.Setenv(env.key, env.value)
+			if _, err := SurveyCacheConfigFromEnv(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestSurveySoftDelete(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := NewQueries(db)
+
+	uri := "at://did:plc:softdel" + uuid.NewString()[:8] + "/net.openmeet.survey/1"
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		URI:   &uri,
+		Slug:  "softdel-" + uuid.NewString()[:8],
+		Title: "Oops",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{{
+				ID:      "q1",
+				Text:    "Pick one",
+				Type:    models.QuestionTypeSingle,
+				Options: []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}},
+			}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := queries.CreateSurvey(ctx, survey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	voter := "did:plc:softdelvoter"
+	if err := queries.CreateResponse(ctx, &models.Response{
+		ID:        uuid.New(),
+		SurveyID:  survey.ID,
+		VoterDID:  &voter,
+		Answers:   map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}},
+		CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to create response: %v", err)
+	}
+
+	if err := queries.DeleteSurveyByURI(ctx, uri); err != nil {
+		t.Fatalf("DeleteSurveyByURI failed: %v", err)
+	}
+
+	t.Run("public queries exclude deleted surveys", func(t *testing.T) {
+		if _, err := queries.GetSurveyBySlug(ctx, survey.Slug); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSurveyBySlug: expected sql.ErrNoRows, got %v", err)
+		}
+		if _, err := queries.GetSurveyResults(ctx, survey.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSurveyResults: expected sql.ErrNoRows, got %v", err)
+		}
+		surveys, _, err := queries.ListSurveys(ctx, ListSurveysParams{Limit: 1000})
+		if err != nil {
+			t.Fatalf("ListSurveys failed: %v", err)
+		}
+		for _, s := range surveys {
+			if s.ID == survey.ID {
+				t.Error("ListSurveys returned a deleted survey")
+			}
+		}
+	})
+
+	t.Run("admin variants include deleted surveys", func(t *testing.T) {
+		results, err := queries.GetSurveyResultsIncludingDeleted(ctx, survey.ID)
+		if err != nil {
+			t.Fatalf("GetSurveyResultsIncludingDeleted failed: %v", err)
+		}
+		if results.TotalVotes != 1 {
+			t.Errorf("Expected the response to be kept, got %d votes", results.TotalVotes)
+		}
+
+		surveys, _, err := queries.ListSurveys(ctx, ListSurveysParams{IncludeDeleted: true, Limit: 1000})
+		if err != nil {
+			t.Fatalf("ListSurveys failed: %v", err)
+		}
+		found := false
+		for _, s := range surveys {
+			if s.ID == survey.ID {
+				found = s.DeletedAt != nil && s.Status == models.SurveyStatusDeleted
+			}
+		}
+		if !found {
+			t.Error("ListSurveys with IncludeDeleted did not return the deleted survey")
+		}
+
+		got, err := queries.GetSurveyByURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("GetSurveyByURI failed: %v", err)
+		}
+		if got.DeletedAt == nil {
+			t.Error("Expected GetSurveyByURI to report DeletedAt")
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		if err := queries.RestoreSurvey(ctx, uri); err != nil {
+			t.Fatalf("RestoreSurvey failed: %v", err)
+		}
+		if _, err := queries.GetSurveyBySlug(ctx, survey.Slug); err != nil {
+			t.Errorf("Expected restored survey by slug, got %v", err)
+		}
+
+		// Restoring a survey that isn't deleted is an error
+		if err := queries.RestoreSurvey(ctx, uri); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows restoring a live survey, got %v", err)
+		}
+	})
+
+	t.Run("purge removes only surveys past the grace period", func(t *testing.T) {
+		if err := queries.DeleteSurveyByURI(ctx, uri); err != nil {
+			t.Fatalf("DeleteSurveyByURI failed: %v", err)
+		}
+
+		if _, err := queries.PurgeDeletedSurveys(ctx, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("PurgeDeletedSurveys failed: %v", err)
+		}
+		if _, err := queries.GetSurveyByURI(ctx, uri); err != nil {
+			t.Fatalf("Survey deleted within the grace period was purged: %v", err)
+		}
+
+		if _, err := queries.PurgeDeletedSurveys(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("PurgeDeletedSurveys failed: %v", err)
+		}
+		if _, err := queries.GetSurveyByURI(ctx, uri); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected survey to be purged, got %v", err)
+		}
+		count, err := queries.CountResponsesBySurvey(ctx, survey.ID)
+		if err != nil {
+			t.Fatalf("CountResponsesBySurvey failed: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("Expected responses purged with the survey, got %d", count)
+		}
+	})
+}
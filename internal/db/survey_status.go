@@ -0,0 +1,22 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// surveyStatusSQL returns the SQL expression for a survey's
+// models.SurveyStatus as of the timestamptz parameter now (e.g. "$3").
+// Listings select it for display and compare against it to filter, so the
+// two can't disagree. A survey opens at the instant starts_at is reached and
+// is closed from the instant ends_at is reached, as Survey.StatusAt decides
+// for a single survey already loaded.
+func surveyStatusSQL(now string) string {
+	return fmt.Sprintf(`(CASE
+		WHEN deleted_at IS NOT NULL THEN '%[2]s'
+		WHEN starts_at > %[1]s::timestamptz THEN '%[3]s'
+		WHEN ends_at <= %[1]s::timestamptz THEN '%[4]s'
+		ELSE '%[5]s'
+	END)`, now, models.SurveyStatusDeleted, models.SurveyStatusScheduled, models.SurveyStatusClosed, models.SurveyStatusOpen)
+}
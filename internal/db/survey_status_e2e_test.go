@@ -0,0 +1,143 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// TestSurveyStatus checks the status of surveys whose starts_at or ends_at
+// is exactly now, and that filtering agrees with the reported status
+func TestSurveyStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := NewQueries(db)
+
+	// Postgres stores microseconds
+	now := time.Now().Truncate(time.Microsecond)
+	before, after := now.Add(-time.Microsecond), now.Add(time.Microsecond)
+	author := "did:plc:status" + uuid.NewString()[:8]
+
+	create := func(slug string, startsAt, endsAt *time.Time) uuid.UUID {
+		t.Helper()
+		survey := &models.Survey{
+			ID:        uuid.New(),
+			AuthorDID: &author,
+			Slug:      slug + "-" + uuid.NewString()[:8],
+			Title:     slug,
+			StartsAt:  startsAt,
+			EndsAt:    endsAt,
+			Definition: models.SurveyDefinition{
+				Questions: []models.Question{{ID: "q1", Text: "Why?", Type: models.QuestionTypeText}},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := queries.CreateSurvey(ctx, survey); err != nil {
+			t.Fatalf("Failed to create survey: %v", err)
+		}
+		return survey.ID
+	}
+
+	want := map[uuid.UUID]models.SurveyStatus{
+		create("undated", nil, nil):                 models.SurveyStatusOpen,
+		create("starts-now", &now, nil):             models.SurveyStatusOpen,
+		create("starts-later", &after, nil):         models.SurveyStatusScheduled,
+		create("ends-now", &before, &now):           models.SurveyStatusClosed,
+		create("ends-later", nil, &after):           models.SurveyStatusOpen,
+		create("ended", nil, &before):               models.SurveyStatusClosed,
+		create("starts-after-end", &after, &before): models.SurveyStatusScheduled,
+	}
+	uri := "at://" + author + "/net.openmeet.survey/deleted"
+	deleted := &models.Survey{
+		ID:        uuid.New(),
+		URI:       &uri,
+		AuthorDID: &author,
+		Slug:      "deleted-" + uuid.NewString()[:8],
+		Title:     "deleted",
+		EndsAt:    &before,
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{{ID: "q1", Text: "Why?", Type: models.QuestionTypeText}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := queries.CreateSurvey(ctx, deleted); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	if err := queries.DeleteSurveyByURI(ctx, uri); err != nil {
+		t.Fatalf("DeleteSurveyByURI failed: %v", err)
+	}
+	want[deleted.ID] = models.SurveyStatusDeleted
+
+	t.Run("reported status", func(t *testing.T) {
+		surveys, _, err := queries.listSurveys(ctx, ListSurveysParams{AuthorDID: author, IncludeDeleted: true, Limit: 100}, now)
+		if err != nil {
+			t.Fatalf("listSurveys failed: %v", err)
+		}
+		if len(surveys) != len(want) {
+			t.Fatalf("Expected %d surveys, got %d", len(want), len(surveys))
+		}
+		for _, s := range surveys {
+			if s.Status != want[s.ID] {
+				t.Errorf("%s: expected status %q, got %q", s.Title, want[s.ID], s.Status)
+			}
+		}
+	})
+
+	for _, status := range []models.SurveyStatus{models.SurveyStatusOpen, models.SurveyStatusClosed, models.SurveyStatusScheduled, models.SurveyStatusDeleted} {
+		t.Run("filter "+string(status), func(t *testing.T) {
+			surveys, _, err := queries.listSurveys(ctx, ListSurveysParams{AuthorDID: author, Status: status, Limit: 100}, now)
+			if err != nil {
+				t.Fatalf("listSurveys failed: %v", err)
+			}
+
+			expected := 0
+			for _, s := range want {
+				if s == status {
+					expected++
+				}
+			}
+			if len(surveys) != expected {
+				t.Errorf("Expected %d %s surveys, got %d", expected, status, len(surveys))
+			}
+			for _, s := range surveys {
+				if want[s.ID] != status || s.Status != status {
+					t.Errorf("%s: listed as %q, reported %q, expected %q", s.Title, status, s.Status, want[s.ID])
+				}
+			}
+		})
+	}
+
+	t.Run("author listing", func(t *testing.T) {
+		filter := AuthorSurveyFilter{IncludeUnlisted: true, Status: models.SurveyStatusClosed}
+		results, err := queries.GetSurveysByAuthor(ctx, author, 100, 0, filter)
+		if err != nil {
+			t.Fatalf("GetSurveysByAuthor failed: %v", err)
+		}
+		count, err := queries.CountSurveysByAuthor(ctx, author, filter)
+		if err != nil {
+			t.Fatalf("CountSurveysByAuthor failed: %v", err)
+		}
+		// Both closed surveys ended before the real now
+		if len(results) != 2 || count != 2 {
+			t.Errorf("Expected 2 closed surveys, got %d (count %d)", len(results), count)
+		}
+		for _, r := range results {
+			if r.Survey.Status != models.SurveyStatusClosed {
+				t.Errorf("%s: expected closed, got %q", r.Survey.Title, r.Survey.Status)
+			}
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// surveyListColumns are the columns scanSurveys reads
+var surveyListColumns = []string{"id", "uri", "cid", "author_did", "slug", "title", "description", "definition", "starts_at", "ends_at",
+	"results_uri", "results_cid", "definition_version", "version", "lang", "created_at", "updated_at", "record_updated_at", "deleted_at", "status", "response_count"}
+
+func TestListSurveysFake(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("filters on the status it reports", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM surveys").Rows(surveyListColumns,
+			[]interface{}{uuid.New(), nil, nil, "did:plc:alice", "lunch", "Lunch", nil, []byte(`{"questions":[]}`), nil, nil,
+				nil, nil, 1, 1, nil, now, now, nil, nil, "open", 0},
+		)
+
+		surveys, next, err := NewQueries(fake).listSurveys(context.Background(), ListSurveysParams{
+			Status:    models.SurveyStatusOpen,
+			AuthorDID: "did:plc:alice",
+			Limit:     20,
+		}, now)
+		if err != nil {
+			t.Fatalf("listSurveys failed: %v", err)
+		}
+		if len(surveys) != 1 || surveys[0].Status != models.SurveyStatusOpen || next != "" {
+			t.Fatalf("Expected one open survey and no next page, got %v %q", surveys, next)
+		}
+
+		call := fake.Calls()[0]
+		status := surveyStatusSQL("$8")
+		if strings.Count(call.Query, strings.Join(strings.Fields(status), " ")) != 2 {
+			t.Errorf("Expected the status expression to be both selected and filtered on, got %s", call.Query)
+		}
+		if call.Args[0] != 21 || call.Args[4] != false || call.Args[5] != "did:plc:alice" || call.Args[6] != "open" {
+			t.Errorf("Unexpected arguments %v", call.Args)
+		}
+		if at, ok := call.Args[7].(time.Time); !ok || !at.Equal(now) {
+			t.Errorf("Expected statuses as of %v, got %v", now, call.Args[7])
+		}
+	})
+
+	t.Run("deleted status includes deleted surveys", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM surveys").Rows(surveyListColumns)
+
+		if _, _, err := NewQueries(fake).listSurveys(context.Background(), ListSurveysParams{Status: models.SurveyStatusDeleted, Limit: 20}, now); err != nil {
+			t.Fatalf("listSurveys failed: %v", err)
+		}
+		if args := fake.Calls()[0].Args; args[4] != true || args[6] != "deleted" {
+			t.Errorf("Unexpected arguments %v", args)
+		}
+	})
+
+	t.Run("sorts by responses and pages by count", func(t *testing.T) {
+		first, second := uuid.New(), uuid.New()
+		fake := queriestest.New(t)
+		fake.Expect("FROM surveys").Rows(surveyListColumns,
+			[]interface{}{first, nil, nil, nil, "lunch", "Lunch", nil, []byte(`{"discoverable":true}`), nil, nil,
+				nil, nil, 1, 1, nil, now, now, nil, nil, "open", 12},
+			[]interface{}{second, nil, nil, nil, "retro", "Retro", nil, []byte(`{"discoverable":true}`), nil, nil,
+				nil, nil, 1, 1, nil, now, now, nil, nil, "open", 3},
+		)
+
+		surveys, next, err := NewQueries(fake).listSurveys(context.Background(), ListSurveysParams{
+			Discoverable: true,
+			Sort:         SortMostResponses,
+			Limit:        1,
+		}, now)
+		if err != nil {
+			t.Fatalf("listSurveys failed: %v", err)
+		}
+		if len(surveys) != 1 || surveys[0].ResponseCount != 12 {
+			t.Fatalf("Expected the survey with 12 responses, got %v", surveys)
+		}
+
+		call := fake.Calls()[0]
+		for _, want := range []string{"ORDER BY response_count DESC, created_at DESC, id DESC", "(response_count, created_at, id) <", "'discoverable'"} {
+			if !strings.Contains(call.Query, want) {
+				t.Errorf("Expected query to contain %q, got %s", want, call.Query)
+			}
+		}
+		if call.Args[8] != true || len(call.Args) != 10 {
+			t.Errorf("Unexpected arguments %v", call.Args)
+		}
+
+		cursor, err := DecodeCursor(next)
+		if err != nil || cursor.ID != first || cursor.ResponseCount == nil || *cursor.ResponseCount != 12 {
+			t.Errorf("Expected a cursor after the first survey's 12 responses, got %+v, %v", cursor, err)
+		}
+	})
+
+	t.Run("rejects a cursor from the other order", func(t *testing.T) {
+		fake := queriestest.New(t)
+		byNewest := EncodeCursor(now, uuid.New())
+		if _, _, err := NewQueries(fake).ListSurveys(context.Background(), ListSurveysParams{Sort: SortMostResponses, Limit: 20, Cursor: byNewest}); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+		byResponses := EncodeResponsesCursor(3, now, uuid.New())
+		if _, _, err := NewQueries(fake).ListSurveys(context.Background(), ListSurveysParams{Limit: 20, Cursor: byResponses}); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("rejects a malformed cursor", func(t *testing.T) {
+		fake := queriestest.New(t)
+		if _, _, err := NewQueries(fake).ListSurveys(context.Background(), ListSurveysParams{Limit: 20, Cursor: "garbage"}); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+//go:build e2e
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestSurveyVersion(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	db := setupTestDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := NewQueries(db)
+
+	uri := "at://did:plc:version" + uuid.NewString()[:8] + "/net.openmeet.survey/1"
+	survey := &models.Survey{
+		ID:    uuid.New(),
+		URI:   &uri,
+		Slug:  "version-" + uuid.NewString()[:8],
+		Title: "Team lunch",
+		Definition: models.SurveyDefinition{
+			Questions: []models.Question{{
+				ID:      "q1",
+				Text:    "Where?",
+				Type:    models.QuestionTypeSingle,
+				Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}},
+			}},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := queries.CreateSurvey(ctx, survey); err != nil {
+		t.Fatalf("Failed to create survey: %v", err)
+	}
+	if survey.Version != 1 {
+		t.Fatalf("Expected a new survey at version 1, got %d", survey.Version)
+	}
+
+	t.Run("consumer update without content change keeps the version", func(t *testing.T) {
+		cid := "bafynochange"
+		survey.CID = &cid
+		if err := queries.UpdateSurvey(ctx, survey); err != nil {
+			t.Fatalf("UpdateSurvey failed: %v", err)
+		}
+		if survey.Version != 1 {
+			t.Errorf("Expected version 1, got %d", survey.Version)
+		}
+	})
+
+	t.Run("consumer update with new content bumps the version", func(t *testing.T) {
+		survey.Title = "Team lunch (Friday)"
+		if err := queries.UpdateSurvey(ctx, survey); err != nil {
+			t.Fatalf("UpdateSurvey failed: %v", err)
+		}
+		if survey.Version != 2 {
+			t.Errorf("Expected version 2, got %d", survey.Version)
+		}
+	})
+
+	t.Run("edit at the current version is saved", func(t *testing.T) {
+		edit, err := queries.GetSurveyByURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("GetSurveyByURI failed: %v", err)
+		}
+		edit.Title = "Team lunch (Thursday)"
+		if err := queries.UpdateSurveyIfVersion(ctx, edit, 2); err != nil {
+			t.Fatalf("UpdateSurveyIfVersion failed: %v", err)
+		}
+		if edit.Version != 3 {
+			t.Errorf("Expected version 3, got %d", edit.Version)
+		}
+	})
+
+	t.Run("edit at a stale version conflicts", func(t *testing.T) {
+		edit, err := queries.GetSurveyByURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("GetSurveyByURI failed: %v", err)
+		}
+		edit.Title = "Overwritten"
+		if err := queries.UpdateSurveyIfVersion(ctx, edit, 2); !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("Expected ErrVersionConflict, got %v", err)
+		}
+
+		stored, err := queries.GetSurveyByURI(ctx, uri)
+		if err != nil {
+			t.Fatalf("GetSurveyByURI failed: %v", err)
+		}
+		if stored.Title != "Team lunch (Thursday)" || stored.Version != 3 {
+			t.Errorf("Expected the earlier edit to survive, got %q at version %d", stored.Title, stored.Version)
+		}
+	})
+
+	t.Run("edit of a missing survey", func(t *testing.T) {
+		missing := &models.Survey{ID: uuid.New(), Title: "Nope"}
+		if err := queries.UpdateSurveyIfVersion(ctx, missing, 1); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+func TestUpdateSurveyIfVersionFake(t *testing.T) {
+	uri := "at://did:plc:author/net.openmeet.survey/3kedit"
+	newSurvey := func() *models.Survey {
+		return &models.Survey{ID: uuid.New(), URI: &uri, Title: "Team lunch", Version: 3}
+	}
+
+	t.Run("saves and bumps the version", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("UPDATE surveys").Rows([]string{"version"}, []interface{}{int64(4)})
+		fake.Expect("pg_notify").RowsAffected(1)
+
+		s := newSurvey()
+		cid := "bafyedited"
+		s.CID = &cid
+		closes := time.Date(2025, 3, 8, 9, 0, 0, 0, time.UTC)
+		s.EndsAt = &closes
+		if err := NewQueries(fake).UpdateSurveyIfVersion(context.Background(), s, 3); err != nil {
+			t.Fatalf("UpdateSurveyIfVersion failed: %v", err)
+		}
+		if s.Version != 4 {
+			t.Errorf("Expected version 4, got %d", s.Version)
+		}
+
+		update := fake.CallsMatching("UPDATE surveys")[0]
+		if update.Args[4] != 3 {
+			t.Errorf("Expected the update to be conditional on version 3, got %v", update.Args[4])
+		}
+		if got, ok := update.Args[5].(*string); !ok || *got != cid {
+			t.Errorf("Expected the edited record's CID to be saved, got %v", update.Args[5])
+		}
+		if got, ok := update.Args[7].(*time.Time); !ok || !got.Equal(closes) {
+			t.Errorf("Expected the edited schedule to be saved, got %v", update.Args[7])
+		}
+		if notify := fake.CallsMatching("pg_notify"); len(notify) != 1 || notify[0].Args[1] != uri {
+			t.Errorf("Expected an invalidation for %s, got %v", uri, notify)
+		}
+	})
+
+	t.Run("stale version conflicts", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("UPDATE surveys").Rows([]string{"version"})
+		fake.Expect("SELECT EXISTS").Rows([]string{"exists"}, []interface{}{true})
+
+		err := NewQueries(fake).UpdateSurveyIfVersion(context.Background(), newSurvey(), 2)
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Fatalf("Expected ErrVersionConflict, got %v", err)
+		}
+		if len(fake.CallsMatching("pg_notify")) != 0 {
+			t.Error("Expected no invalidation after a conflict")
+		}
+	})
+
+	t.Run("missing survey", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("UPDATE surveys").Rows([]string{"version"})
+		fake.Expect("SELECT EXISTS").Rows([]string{"exists"}, []interface{}{false})
+
+		err := NewQueries(fake).UpdateSurveyIfVersion(context.Background(), newSurvey(), 3)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestUpdateSurveyReturnsVersionFake(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("UPDATE surveys").Rows([]string{"version"}, []interface{}{int64(2)})
+
+	s := &models.Survey{ID: uuid.New(), Title: "Team lunch", Version: 1}
+	if err := NewQueries(fake).UpdateSurvey(context.Background(), s); err != nil {
+		t.Fatalf("UpdateSurvey failed: %v", err)
+	}
+	if s.Version != 2 {
+		t.Errorf("Expected the stored version 2, got %d", s.Version)
+	}
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// SaveTextAnswerSummary stores the summary of a question's text answers,
+// replacing the question's earlier summary
+func (q *Queries) SaveTextAnswerSummary(ctx context.Context, s *models.TextAnswerSummary) error {
+	summaryJSON, err := json.Marshal(s.Summary)
+	if err != nil {
+		return fmt.Errorf("failed to marshal text answer summary: %w", err)
+	}
+
+	query := `
+		INSERT INTO text_answer_summaries (survey_id, question_id, response_count, summary, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (survey_id, question_id) DO UPDATE SET
+			response_count = EXCLUDED.response_count,
+			summary = EXCLUDED.summary,
+			created_at = EXCLUDED.created_at
+	`
+
+	_, err = q.db.ExecContext(ctx, query, s.SurveyID, s.QuestionID, s.ResponseCount, summaryJSON, s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save text answer summary: %w", err)
+	}
+
+	return nil
+}
+
+// GetTextAnswerSummaries returns the latest summary of each of a survey's
+// text questions that has one, keyed by question ID
+func (q *Queries) GetTextAnswerSummaries(ctx context.Context, surveyID uuid.UUID) (map[string]*models.TextAnswerSummary, error) {
+	query := `
+		SELECT survey_id, question_id, response_count, summary, created_at
+		FROM text_answer_summaries
+		WHERE survey_id = $1
+	`
+
+	rows, err := q.db.QueryContext(ctx, query, surveyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query text answer summaries: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := map[string]*models.TextAnswerSummary{}
+	for rows.Next() {
+		s, err := scanTextAnswerSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		summaries[s.QuestionID] = s
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating text answer summaries: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// GetTextAnswerSummary returns the latest summary of a question's text
+// answers. Returns nil (no error) if the question hasn't been summarized.
+func (q *Queries) GetTextAnswerSummary(ctx context.Context, surveyID uuid.UUID, questionID string) (*models.TextAnswerSummary, error) {
+	query := `
+		SELECT survey_id, question_id, response_count, summary, created_at
+		FROM text_answer_summaries
+		WHERE survey_id = $1 AND question_id = $2
+	`
+
+	s, err := scanTextAnswerSummary(q.db.QueryRowContext(ctx, query, surveyID, questionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// scanTextAnswerSummary reads a text_answer_summaries row
+func scanTextAnswerSummary(row interface{ Scan(...any) error }) (*models.TextAnswerSummary, error) {
+	var s models.TextAnswerSummary
+	var summaryJSON []byte
+	err := row.Scan(&s.SurveyID, &s.QuestionID, &s.ResponseCount, &summaryJSON, &s.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan text answer summary: %w", err)
+	}
+
+	if err := json.Unmarshal(summaryJSON, &s.Summary); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal text answer summary: %w", err)
+	}
+
+	return &s, nil
+}
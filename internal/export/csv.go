@@ -0,0 +1,130 @@
+// Package export writes survey responses in formats meant for other tools.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// multiSeparator joins the options selected in a multi-choice answer
+const multiSeparator = ";"
+
+// CSVWriter writes a survey's responses as CSV: the submission time, the
+// responder's handle and DID unless the survey is anonymous, then one column
+// per question. Rows go through a small buffer to the underlying writer, so
+// any number of responses can be written in constant memory.
+type CSVWriter struct {
+	w      *csv.Writer
+	survey *models.Survey
+
+	// options maps question ID, then option ID, to the option's text
+	options map[string]map[string]string
+}
+
+// NewCSVWriter returns a CSVWriter for survey's responses writing to w
+func NewCSVWriter(w io.Writer, survey *models.Survey) *CSVWriter {
+	options := make(map[string]map[string]string, len(survey.Definition.Questions))
+	for _, question := range survey.Definition.Questions {
+		texts := make(map[string]string, len(question.Options))
+		for _, option := range question.Options {
+			texts[option.ID] = option.Text
+		}
+		options[question.ID] = texts
+	}
+
+	return &CSVWriter{
+		w:       csv.NewWriter(w),
+		survey:  survey,
+		options: options,
+	}
+}
+
+// WriteHeader writes the column names. Call it once, before any response.
+func (cw *CSVWriter) WriteHeader() error {
+	header := []string{"Submitted At"}
+	if !cw.survey.Definition.Anonymous {
+		header = append(header, "Handle", "DID")
+	}
+	for _, question := range cw.survey.Definition.Questions {
+		header = append(header, safeCell(question.Text))
+	}
+	return cw.w.Write(header)
+}
+
+// Write writes one response. Its signature fits db.Queries.StreamResponses.
+func (cw *CSVWriter) Write(row db.ResponseRow) error {
+	record := []string{row.CreatedAt.UTC().Format(time.RFC3339)}
+	if !cw.survey.Definition.Anonymous {
+		record = append(record, safeCell(stringOrEmpty(row.VoterHandle)), stringOrEmpty(row.VoterDID))
+	}
+	for _, question := range cw.survey.Definition.Questions {
+		answer, ok := row.Answers[question.ID]
+		if !ok {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, cw.answerCell(question, answer))
+	}
+
+	if err := cw.w.Write(record); err != nil {
+		return fmt.Errorf("failed to write response %s: %w", row.ID, err)
+	}
+	return nil
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// answerCell formats an answer to question for a single cell
+func (cw *CSVWriter) answerCell(question models.Question, answer models.Answer) string {
+	switch question.Type {
+	case models.QuestionTypeText:
+		return safeCell(answer.Text)
+	case models.QuestionTypeRating:
+		if answer.Rating == nil {
+			return ""
+		}
+		return strconv.Itoa(*answer.Rating)
+	default:
+		selected := make([]string, 0, len(answer.SelectedOptions))
+		for _, optionID := range answer.SelectedOptions {
+			// Options removed by an edit keep their ID
+			text, ok := cw.options[question.ID][optionID]
+			if !ok {
+				text = optionID
+			}
+			if other := answer.OtherText[optionID]; other != "" {
+				text += ": " + other
+			}
+			selected = append(selected, text)
+		}
+		return safeCell(strings.Join(selected, multiSeparator))
+	}
+}
+
+// safeCell keeps user-supplied text from being evaluated as a formula when
+// the file is opened in a spreadsheet, by prefixing a quote to text starting
+// with a formula character
+func safeCell(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
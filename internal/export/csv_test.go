@@ -0,0 +1,115 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSurvey(anonymous bool) *models.Survey {
+	return &models.Survey{
+		ID:   uuid.New(),
+		Slug: "lunch",
+		Definition: models.SurveyDefinition{
+			Anonymous: anonymous,
+			Questions: []models.Question{
+				{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{
+					{ID: "a", Text: "Tacos"},
+					{ID: "b", Text: "Other", AllowFreeText: true},
+				}},
+				{ID: "q2", Text: "Drinks", Type: models.QuestionTypeMulti, Options: []models.Option{
+					{ID: "x", Text: "Coffee"},
+					{ID: "y", Text: "Tea"},
+				}},
+				{ID: "q3", Text: "Comments", Type: models.QuestionTypeText},
+				{ID: "q4", Text: "Rate it", Type: models.QuestionTypeRating, Min: 1, Max: 5},
+			},
+		},
+	}
+}
+
+// export writes rows and parses the result back
+func export(t *testing.T, survey *models.Survey, rows ...db.ResponseRow) [][]string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	cw := NewCSVWriter(&buf, survey)
+	require.NoError(t, cw.WriteHeader())
+	for _, row := range rows {
+		require.NoError(t, cw.Write(row))
+	}
+	require.NoError(t, cw.Flush())
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestCSVWriter(t *testing.T) {
+	did := "did:plc:voter"
+	handle := "voter.bsky.social"
+	rating := 4
+	createdAt := time.Date(2025, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	row := db.ResponseRow{
+		ID:          uuid.New(),
+		VoterDID:    &did,
+		VoterHandle: &handle,
+		CreatedAt:   createdAt,
+		Answers: map[string]models.Answer{
+			"q1": {SelectedOptions: []string{"b"}, OtherText: map[string]string{"b": "Pizza"}},
+			"q2": {SelectedOptions: []string{"x", "y", "gone"}},
+			"q3": {Text: "Great, \"really\"\nsee you there"},
+			"q4": {Rating: &rating},
+		},
+	}
+
+	t.Run("named responses", func(t *testing.T) {
+		records := export(t, testSurvey(false), row)
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"Submitted At", "Handle", "DID", "Where?", "Drinks", "Comments", "Rate it"}, records[0])
+		assert.Equal(t, []string{
+			"2025-03-01T11:30:00Z",
+			"voter.bsky.social",
+			"did:plc:voter",
+			"Other: Pizza",
+			"Coffee;Tea;gone",
+			"Great, \"really\"\nsee you there",
+			"4",
+		}, records[1])
+	})
+
+	t.Run("anonymous survey omits the responder", func(t *testing.T) {
+		records := export(t, testSurvey(true), row)
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"Submitted At", "Where?", "Drinks", "Comments", "Rate it"}, records[0])
+		assert.NotContains(t, records[1], "did:plc:voter")
+		assert.NotContains(t, records[1], "voter.bsky.social")
+	})
+
+	t.Run("guest with unanswered questions", func(t *testing.T) {
+		records := export(t, testSurvey(false), db.ResponseRow{
+			ID:        uuid.New(),
+			CreatedAt: createdAt,
+			Answers:   map[string]models.Answer{"q1": {SelectedOptions: []string{"a"}}},
+		})
+		require.Len(t, records, 2)
+		assert.Equal(t, []string{"2025-03-01T11:30:00Z", "", "", "Tacos", "", "", ""}, records[1])
+	})
+
+	t.Run("formulas are neutralized", func(t *testing.T) {
+		records := export(t, testSurvey(true), db.ResponseRow{
+			ID:        uuid.New(),
+			CreatedAt: createdAt,
+			Answers:   map[string]models.Answer{"q3": {Text: "=HYPERLINK(\"http://evil\")"}},
+		})
+		assert.Equal(t, "'=HYPERLINK(\"http://evil\")", records[1][3])
+	})
+}
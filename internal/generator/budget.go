@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultUserDailyBudget is what one authenticated user may spend per day
+	// Override with AI_USER_DAILY_BUDGET_USD
+	DefaultUserDailyBudget = 0.50
+
+	// DefaultAnonDailyBudget is what one anonymous IP may spend per day
+	// Override with AI_ANON_DAILY_BUDGET_USD
+	DefaultAnonDailyBudget = 0.10
+
+	// DefaultGlobalDailyBudget is what all users together may spend per day
+	// Override with AI_GLOBAL_DAILY_BUDGET_USD
+	DefaultGlobalDailyBudget = 10.0
+)
+
+// Budget scopes, reported in BudgetExceededError
+const (
+	BudgetScopeUser      = "user"
+	BudgetScopeAnonymous = "anonymous"
+	BudgetScopeGlobal    = "global"
+)
+
+// BudgetConfig holds the daily spending budgets in USD. Days are UTC.
+type BudgetConfig struct {
+	UserDaily   float64 // Per authenticated user (DID)
+	AnonDaily   float64 // Per anonymous user (IP)
+	GlobalDaily float64 // Across everyone; exceeding it disables generation
+}
+
+// BudgetConfigFromEnv creates a BudgetConfig from environment variables
+// Environment variables:
+//   - AI_USER_DAILY_BUDGET_USD: daily budget per authenticated user (default: 0.50)
+//   - AI_ANON_DAILY_BUDGET_USD: daily budget per anonymous IP (default: 0.10)
+//   - AI_GLOBAL_DAILY_BUDGET_USD: daily budget across all users (default: 10.00)
+func BudgetConfigFromEnv() BudgetConfig {
+	return BudgetConfig{
+		UserDaily:   budgetFromEnv("AI_USER_DAILY_BUDGET_USD", DefaultUserDailyBudget),
+		AnonDaily:   budgetFromEnv("AI_ANON_DAILY_BUDGET_USD", DefaultAnonDailyBudget),
+		GlobalDaily: budgetFromEnv("AI_GLOBAL_DAILY_BUDGET_USD", DefaultGlobalDailyBudget),
+	}
+}
+
+// budgetFromEnv reads a non-negative dollar amount, or returns def
+func budgetFromEnv(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if val, err := strconv.ParseFloat(v, 64); err == nil && val >= 0 {
+			return val
+		}
+	}
+	return def
+}
+
+// BudgetExceededError is returned by BudgetLimiter.Check when a request would
+// go over a daily budget
+type BudgetExceededError struct {
+	Scope    string    // BudgetScopeUser, BudgetScopeAnonymous or BudgetScopeGlobal
+	SpentUSD float64   // Spent so far today
+	LimitUSD float64   // The budget
+	ResetsAt time.Time // When the budget resets (next UTC midnight)
+}
+
+func (e *BudgetExceededError) Error() string {
+	switch e.Scope {
+	case BudgetScopeGlobal:
+		return fmt.Sprintf("global daily AI budget exceeded: $%.4f of $%.2f spent", e.SpentUSD, e.LimitUSD)
+	case BudgetScopeAnonymous:
+		return fmt.Sprintf("anonymous daily AI budget exceeded: $%.4f of $%.2f spent", e.SpentUSD, e.LimitUSD)
+	default:
+		return fmt.Sprintf("user daily AI budget exceeded: $%.4f of $%.2f spent", e.SpentUSD, e.LimitUSD)
+	}
+}
+
+// GenerationCostDB sums the cost of generations, failed ones included, from
+// the generation logs
+type GenerationCostDB interface {
+	GetUserCostSince(ctx context.Context, userID string, since time.Time) (costUSD float64, calls int, err error)
+	GetTotalCostSince(ctx context.Context, since time.Time) (costUSD float64, calls int, err error)
+}
+
+// BudgetLimiter enforces daily spending budgets from the generation logs, so
+// unlike CostLimiter they hold across replicas and restarts
+type BudgetLimiter struct {
+	db     GenerationCostDB
+	config BudgetConfig
+	now    func() time.Time
+}
+
+// NewBudgetLimiter creates a budget limiter reading spend from db
+func NewBudgetLimiter(db GenerationCostDB, config BudgetConfig) *BudgetLimiter {
+	return &BudgetLimiter{db: db, config: config, now: time.Now}
+}
+
+// Check returns a *BudgetExceededError if today's spend has reached the
+// global budget or the budget for userID, an IP when userType is "anonymous"
+// and a DID otherwise. A budget of zero blocks every request in its scope.
+// The request being checked isn't counted, so it can take spend slightly
+// past the budget.
+func (l *BudgetLimiter) Check(ctx context.Context, userID, userType string) error {
+	now := l.now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	resetsAt := dayStart.AddDate(0, 0, 1)
+
+	// The global budget goes first: when it's spent, no one can generate
+	total, _, err := l.db.GetTotalCostSince(ctx, dayStart)
+	if err != nil {
+		return err
+	}
+	if total >= l.config.GlobalDaily {
+		return &BudgetExceededError{Scope: BudgetScopeGlobal, SpentUSD: total, LimitUSD: l.config.GlobalDaily, ResetsAt: resetsAt}
+	}
+
+	scope, limit := BudgetScopeUser, l.config.UserDaily
+	if userType == "anonymous" {
+		scope, limit = BudgetScopeAnonymous, l.config.AnonDaily
+	}
+	spent, _, err := l.db.GetUserCostSince(ctx, userID, dayStart)
+	if err != nil {
+		return err
+	}
+	if spent >= limit {
+		return &BudgetExceededError{Scope: scope, SpentUSD: spent, LimitUSD: limit, ResetsAt: resetsAt}
+	}
+
+	return nil
+}
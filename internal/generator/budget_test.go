@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCostDB sums logs the way the generation log queries do
+type MockCostDB struct {
+	logs []AIGenerationLog
+	err  error
+}
+
+func (m *MockCostDB) log(userID, status string, cost float64, createdAt time.Time) {
+	m.logs = append(m.logs, AIGenerationLog{UserID: userID, Status: status, CostUSD: cost, CreatedAt: createdAt})
+}
+
+func (m *MockCostDB) GetUserCostSince(ctx context.Context, userID string, since time.Time) (float64, int, error) {
+	return m.sum(since, func(l AIGenerationLog) bool { return l.UserID == userID })
+}
+
+func (m *MockCostDB) GetTotalCostSince(ctx context.Context, since time.Time) (float64, int, error) {
+	return m.sum(since, func(AIGenerationLog) bool { return true })
+}
+
+func (m *MockCostDB) sum(since time.Time, match func(AIGenerationLog) bool) (float64, int, error) {
+	var cost float64
+	var calls int
+	for _, l := range m.logs {
+		if match(l) && l.Status == "success" && !l.CreatedAt.Before(since) {
+			cost += l.CostUSD
+			calls++
+		}
+	}
+	return cost, calls, m.err
+}
+
+func TestBudgetLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 15, 30, 0, 0, time.UTC)
+	midnight := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	config := BudgetConfig{UserDaily: 0.50, AnonDaily: 0.10, GlobalDaily: 10.0}
+
+	newLimiter := func(db *MockCostDB) *BudgetLimiter {
+		l := NewBudgetLimiter(db, config)
+		l.now = func() time.Time { return now }
+		return l
+	}
+
+	t.Run("allows a user just under budget", func(t *testing.T) {
+		db := &MockCostDB{}
+		db.log("did:plc:alice", "success", 0.30, now.Add(-time.Hour))
+		db.log("did:plc:alice", "success", 0.1999, now.Add(-time.Minute))
+
+		assert.NoError(t, newLimiter(db).Check(ctx, "did:plc:alice", "authenticated"))
+	})
+
+	t.Run("rejects a user at budget", func(t *testing.T) {
+		db := &MockCostDB{}
+		db.log("did:plc:alice", "success", 0.30, now.Add(-time.Hour))
+		db.log("did:plc:alice", "success", 0.20, now.Add(-time.Minute))
+
+		err := newLimiter(db).Check(ctx, "did:plc:alice", "authenticated")
+		var exceeded *BudgetExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, BudgetScopeUser, exceeded.Scope)
+		assert.InDelta(t, 0.50, exceeded.SpentUSD, 1e-9)
+		assert.Equal(t, 0.50, exceeded.LimitUSD)
+		assert.Equal(t, midnight, exceeded.ResetsAt)
+		assert.Contains(t, err.Error(), "user daily AI budget exceeded")
+
+		// Other users aren't affected
+		assert.NoError(t, newLimiter(db).Check(ctx, "did:plc:bob", "authenticated"))
+	})
+
+	t.Run("anonymous users have the stricter budget", func(t *testing.T) {
+		db := &MockCostDB{}
+		db.log("192.0.2.1", "success", 0.10, now.Add(-time.Hour))
+
+		err := newLimiter(db).Check(ctx, "192.0.2.1", "anonymous")
+		var exceeded *BudgetExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, BudgetScopeAnonymous, exceeded.Scope)
+		assert.Contains(t, err.Error(), "anonymous daily AI budget exceeded")
+	})
+
+	t.Run("spend before today and failures don't count", func(t *testing.T) {
+		db := &MockCostDB{}
+		db.log("did:plc:alice", "success", 5.0, now.Add(-16*time.Hour)) // yesterday, UTC
+		db.log("did:plc:alice", "error", 1.0, now.Add(-time.Hour))
+		db.log("did:plc:alice", "success", 0.49, now.Add(-time.Hour))
+
+		assert.NoError(t, newLimiter(db).Check(ctx, "did:plc:alice", "authenticated"))
+	})
+
+	t.Run("the global budget blocks everyone", func(t *testing.T) {
+		db := &MockCostDB{}
+		for i := 0; i < 40; i++ {
+			db.log("did:plc:someone", "success", 0.25, now.Add(-time.Hour))
+		}
+
+		err := newLimiter(db).Check(ctx, "did:plc:newcomer", "authenticated")
+		var exceeded *BudgetExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, BudgetScopeGlobal, exceeded.Scope)
+		assert.Equal(t, midnight, exceeded.ResetsAt)
+		assert.Contains(t, err.Error(), "global daily AI budget exceeded")
+	})
+
+	t.Run("allows spending just under the global budget", func(t *testing.T) {
+		db := &MockCostDB{}
+		for i := 0; i < 40; i++ {
+			db.log("did:plc:someone", "success", 0.2499, now.Add(-time.Hour))
+		}
+
+		assert.NoError(t, newLimiter(db).Check(ctx, "did:plc:newcomer", "authenticated"))
+	})
+
+	t.Run("returns database errors", func(t *testing.T) {
+		db := &MockCostDB{err: errors.New("connection refused")}
+
+		err := newLimiter(db).Check(ctx, "did:plc:alice", "authenticated")
+		require.Error(t, err)
+		var exceeded *BudgetExceededError
+		assert.False(t, errors.As(err, &exceeded))
+	})
+}
+
+func TestBudgetConfigFromEnv(t *testing.T) {
+	t.Run("uses defaults when env vars not set", func(t *testing.T) {
+		config := BudgetConfigFromEnv()
+		assert.Equal(t, BudgetConfig{UserDaily: 0.50, AnonDaily: 0.10, GlobalDaily: 10.0}, config)
+	})
+
+	t.Run("reads env vars", func(t *testing.T) {
+		t.Setenv("AI_USER_DAILY_BUDGET_USD", "1.25")
+		t.Setenv("AI_ANON_DAILY_BUDGET_USD", "0")
+		t.Setenv("AI_GLOBAL_DAILY_BUDGET_USD", "50")
+
+		config := BudgetConfigFromEnv()
+		assert.Equal(t, BudgetConfig{UserDaily: 1.25, AnonDaily: 0, GlobalDaily: 50}, config)
+	})
+
+	t.Run("ignores invalid values", func(t *testing.T) {
+		t.Setenv("AI_USER_DAILY_BUDGET_USD", "lots")
+		t.Setenv("AI_GLOBAL_DAILY_BUDGET_USD", "-1")
+
+		config := BudgetConfigFromEnv()
+		assert.Equal(t, DefaultUserDailyBudget, config.UserDaily)
+		assert.Equal(t, DefaultGlobalDailyBudget, config.GlobalDaily)
+	})
+}
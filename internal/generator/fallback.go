@@ -0,0 +1,140 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// DefaultProviderTimeout is how long a provider may take before the request
+// falls back to the next one
+const DefaultProviderTimeout = 30 * time.Second
+
+// ProviderTimeoutFromEnv reads AI_PROVIDER_TIMEOUT, a duration like "45s"
+// (default 30s). It only applies to providers with a fallback after them.
+func ProviderTimeoutFromEnv() time.Duration {
+	if v := os.Getenv("AI_PROVIDER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultProviderTimeout
+}
+
+// DefaultGenerationTimeout is how long a generation request may take in all,
+// across retries and fallbacks, before it's abandoned
+const DefaultGenerationTimeout = 30 * time.Second
+
+// GenerationTimeoutFromEnv reads AI_GENERATION_TIMEOUT, a duration like
+// "45s" (default 30s)
+func GenerationTimeoutFromEnv() time.Duration {
+	if v := os.Getenv("AI_GENERATION_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultGenerationTimeout
+}
+
+// attemptTimeout returns how long the i-th provider may take: the provider
+// timeout, cut to an even share of what's left of ctx's deadline between it
+// and the providers after it, so a slow provider can't use up the time its
+// fallbacks need. The last provider has no limit of its own (0).
+func (g *SurveyGenerator) attemptTimeout(ctx context.Context, i int) time.Duration {
+	remaining := len(g.providers) - i
+	if remaining <= 1 {
+		return 0
+	}
+	timeout := g.providerTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if share := time.Until(deadline) / time.Duration(remaining); timeout <= 0 || share < timeout {
+			timeout = share
+		}
+	}
+	return timeout
+}
+
+// FailedAttempt is a provider call that failed while SurveyGenerator worked
+// through its provider chain
+type FailedAttempt struct {
+	Provider      string
+	Model         string
+	Err           error
+	RawResponse   string // Set when the provider answered with invalid output
+	InputTokens   int
+	OutputTokens  int
+	CostUSD       float64
+	DurationMS    int
+	Attempts      int // Requests sent, counting retries of transient errors
+	Temperature   float64
+	PromptVersion string
+
+	// Set when the provider answered with invalid output, as in GenerateResult
+	OutputValidation string
+	OutputIssues     []string
+}
+
+// newFailedAttempt records provider's failed call with the promptVersion
+// system prompt, started at start and taking attempts requests. result is
+// the partial result of invalid output, or nil.
+func (g *SurveyGenerator) newFailedAttempt(provider Provider, promptVersion string, result *GenerateResult, err error, start time.Time, attempts int) FailedAttempt {
+	attempt := FailedAttempt{
+		Provider:      provider.Name(),
+		Model:         provider.Model(),
+		Err:           err,
+		DurationMS:    int(time.Since(start).Milliseconds()),
+		Attempts:      attempts,
+		Temperature:   g.temperature,
+		PromptVersion: promptVersion,
+	}
+	if result != nil {
+		attempt.RawResponse = result.RawResponse
+		attempt.InputTokens = result.InputTokens
+		attempt.OutputTokens = result.OutputTokens
+		attempt.CostUSD = result.EstimatedCost
+		attempt.OutputValidation = result.OutputValidation
+		attempt.OutputIssues = result.OutputIssues
+	}
+	return attempt
+}
+
+// statusCodePattern matches the status langchaingo's OpenAI and Anthropic
+// clients put in their errors, e.g. "API returned unexpected status code: 529"
+var statusCodePattern = regexp.MustCompile(`status code: (\d{3})`)
+
+// IsFallbackError reports whether err means the provider is unavailable, so
+// the request may succeed on the next provider: a timeout, a rate limit (429),
+// or a server error (5xx). Anything else, like a bad API key or a rejected
+// request, would fail the same way on retry.
+func IsFallbackError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var llmErr *llms.Error
+	if errors.As(err, &llmErr) {
+		switch llmErr.Code {
+		case llms.ErrCodeTimeout, llms.ErrCodeRateLimit, llms.ErrCodeProviderUnavailable:
+			return true
+		}
+	}
+
+	if match := statusCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		status, _ := strconv.Atoi(match[1])
+		return status == 429 || status >= 500
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeProvider is a Provider whose Generate runs generate, counting calls
+type fakeProvider struct {
+	name     string
+	generate func(ctx context.Context) (*GenerationResult, error)
+	calls    int
+}
+
+func (p *fakeProvider) Name() string     { return p.name }
+func (p *fakeProvider) Model() string    { return p.name + "-model" }
+func (p *fakeProvider) Pricing() Pricing { return Pricing{InputPer1M: 1, OutputPer1M: 1} }
+
+func (p *fakeProvider) Generate(ctx context.Context, _ GenerationRequest) (*GenerationResult, error) {
+	p.calls++
+	return p.generate(ctx)
+}
+
+func answering(name, json string) *fakeProvider {
+	return &fakeProvider{name: name, generate: func(context.Context) (*GenerationResult, error) {
+		return &GenerationResult{JSON: json, InputTokens: 100, OutputTokens: 50, CostUSD: 0.0002}, nil
+	}}
+}
+
+func failing(name string, err error) *fakeProvider {
+	return &fakeProvider{name: name, generate: func(context.Context) (*GenerationResult, error) {
+		return nil, err
+	}}
+}
+
+func TestSurveyGenerator_Fallback(t *testing.T) {
+	ctx := context.Background()
+	unavailable := fmt.Errorf("openai generation failed: %w", errors.New("API returned unexpected status code: 503: overloaded"))
+
+	t.Run("falls back when the primary is unavailable", func(t *testing.T) {
+		primary := failing("openai", unavailable)
+		secondary := answering("anthropic", pizzaPollJSON)
+		gen := NewSurveyGeneratorWithProvider(primary, secondary)
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		require.NotNil(t, result.Definition)
+		assert.Equal(t, "anthropic", result.Provider)
+		assert.Equal(t, "openai", result.FallbackFrom)
+		require.Len(t, result.FailedAttempts, 1)
+		assert.Equal(t, "openai", result.FailedAttempts[0].Provider)
+		assert.Equal(t, "openai-model", result.FailedAttempts[0].Model)
+		assert.ErrorIs(t, result.FailedAttempts[0].Err, unavailable)
+		assert.Equal(t, 1, primary.calls)
+		assert.Equal(t, 1, secondary.calls)
+	})
+
+	t.Run("falls back when the primary is rate limited", func(t *testing.T) {
+		primary := failing("openai", errors.New("API returned unexpected status code: 429: slow down"))
+		secondary := answering("anthropic", pizzaPollJSON)
+
+		result, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "anthropic", result.Provider)
+	})
+
+	t.Run("falls back when the primary times out", func(t *testing.T) {
+		primary := &fakeProvider{name: "openai", generate: func(ctx context.Context) (*GenerationResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}
+		secondary := answering("anthropic", pizzaPollJSON)
+		gen := NewSurveyGeneratorWithProvider(primary, secondary)
+		gen.SetProviderTimeout(10 * time.Millisecond)
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "anthropic", result.Provider)
+		require.Len(t, result.FailedAttempts, 1)
+		assert.ErrorIs(t, result.FailedAttempts[0].Err, context.DeadlineExceeded)
+		assert.GreaterOrEqual(t, result.FailedAttempts[0].DurationMS, 10)
+	})
+
+	t.Run("leaves the fallback a share of the request deadline", func(t *testing.T) {
+		// The provider timeout alone would use up the whole request
+		ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		primary := &fakeProvider{name: "openai", generate: func(ctx context.Context) (*GenerationResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}
+		secondary := answering("anthropic", pizzaPollJSON)
+		gen := NewSurveyGeneratorWithProvider(primary, secondary)
+		gen.SetProviderTimeout(time.Second)
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "anthropic", result.Provider)
+		require.Len(t, result.FailedAttempts, 1)
+		assert.Less(t, result.FailedAttempts[0].DurationMS, 150)
+	})
+
+	t.Run("answers without fallback when the primary works", func(t *testing.T) {
+		primary := answering("openai", pizzaPollJSON)
+		secondary := answering("anthropic", pizzaPollJSON)
+
+		result, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "openai", result.Provider)
+		assert.Empty(t, result.FallbackFrom)
+		assert.Empty(t, result.FailedAttempts)
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("invalid output doesn't fall back", func(t *testing.T) {
+		invalid := `{"questions":[{"id":"q1","text":"Pick one","type":"single","required":false,"options":[]}],"anonymous":false}`
+		primary := answering("openai", invalid)
+		secondary := answering("anthropic", pizzaPollJSON)
+
+		result, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(ctx, "Create a poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid LLM output")
+		require.NotNil(t, result)
+		assert.Equal(t, invalid, result.RawResponse)
+		assert.Empty(t, result.FailedAttempts)
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("other errors don't fall back", func(t *testing.T) {
+		primary := failing("openai", errors.New("API returned unexpected status code: 401: invalid_api_key"))
+		secondary := answering("anthropic", pizzaPollJSON)
+
+		result, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(ctx, "Create a poll")
+		require.Error(t, err)
+		assert.Nil(t, result)
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("a canceled request doesn't fall back", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		primary := &fakeProvider{name: "openai", generate: func(context.Context) (*GenerationResult, error) {
+			cancel()
+			return nil, unavailable
+		}}
+		secondary := answering("anthropic", pizzaPollJSON)
+
+		_, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(canceled, "Create a poll")
+		require.Error(t, err)
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("records every attempt when all providers fail", func(t *testing.T) {
+		primary := failing("openai", unavailable)
+		secondary := failing("anthropic", errors.New("API returned unexpected status code: 529: overloaded"))
+
+		result, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(ctx, "Create a poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "529")
+		require.NotNil(t, result)
+		assert.Nil(t, result.Definition)
+		assert.Empty(t, result.FallbackFrom)
+		require.Len(t, result.FailedAttempts, 2)
+		assert.Equal(t, "openai", result.FailedAttempts[0].Provider)
+		assert.Equal(t, "anthropic", result.FailedAttempts[1].Provider)
+	})
+
+	t.Run("records invalid output from the fallback", func(t *testing.T) {
+		invalid := `{"questions":[]}`
+		primary := failing("openai", unavailable)
+		secondary := answering("anthropic", invalid)
+
+		result, err := NewSurveyGeneratorWithProvider(primary, secondary).Generate(ctx, "Create a poll")
+		require.Error(t, err)
+		require.NotNil(t, result)
+		require.Len(t, result.FailedAttempts, 2)
+		assert.Equal(t, "anthropic", result.FailedAttempts[1].Provider)
+		assert.Equal(t, invalid, result.FailedAttempts[1].RawResponse)
+		assert.Equal(t, 200, result.FailedAttempts[1].InputTokens, "with the re-prompt")
+	})
+
+	t.Run("follows the chain order", func(t *testing.T) {
+		first := failing("openai", unavailable)
+		second := failing("anthropic", unavailable)
+		third := answering("backup", pizzaPollJSON)
+
+		result, err := NewSurveyGeneratorWithProvider(first, second, third).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "backup", result.Provider)
+		assert.Equal(t, "anthropic", result.FallbackFrom)
+		require.Len(t, result.FailedAttempts, 2)
+		assert.Equal(t, []int{1, 1, 1}, []int{first.calls, second.calls, third.calls})
+	})
+}
+
+func TestIsFallbackError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"server error", errors.New("API returned unexpected status code: 500: internal error"), true},
+		{"overloaded", errors.New("API returned unexpected status code: 529"), true},
+		{"rate limited", errors.New("API returned unexpected status code: 429"), true},
+		{"deadline exceeded", fmt.Errorf("anthropic generation failed: %w", context.DeadlineExceeded), true},
+		{"llms rate limit", llms.NewError(llms.ErrCodeRateLimit, "openai", "slow down"), true},
+		{"llms unavailable", llms.NewError(llms.ErrCodeProviderUnavailable, "anthropic", "down"), true},
+		{"unauthorized", errors.New("API returned unexpected status code: 401"), false},
+		{"bad request", errors.New("API returned unexpected status code: 400"), false},
+		{"llms authentication", llms.NewError(llms.ErrCodeAuthentication, "openai", "bad key"), false},
+		{"canceled", context.Canceled, false},
+		{"empty response", ErrEmptyResponse, false},
+		{"cost limit", ErrCostLimitExceeded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsFallbackError(tt.err))
+		})
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	gen := NewSurveyGeneratorWithProvider(answering("openai", "{}"), answering("anthropic", "{}"), answering("gemini", "{}"))
+	gen.SetProviderTimeout(30 * time.Second)
+
+	t.Run("provider timeout without a deadline", func(t *testing.T) {
+		assert.Equal(t, 30*time.Second, gen.attemptTimeout(context.Background(), 0))
+	})
+
+	t.Run("last provider has no limit of its own", func(t *testing.T) {
+		assert.Zero(t, gen.attemptTimeout(context.Background(), 2))
+	})
+
+	t.Run("even share of a deadline shorter than the timeouts", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		assert.InDelta(t, float64(10*time.Second), float64(gen.attemptTimeout(ctx, 0)), float64(time.Second))
+		assert.InDelta(t, float64(15*time.Second), float64(gen.attemptTimeout(ctx, 1)), float64(time.Second))
+	})
+
+	t.Run("provider timeout under a long deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		assert.Equal(t, 30*time.Second, gen.attemptTimeout(ctx, 0))
+	})
+}
+
+func TestProviderTimeoutFromEnv(t *testing.T) {
+	t.Setenv("AI_PROVIDER_TIMEOUT", "")
+	assert.Equal(t, DefaultProviderTimeout, ProviderTimeoutFromEnv())
+
+	t.Setenv("AI_PROVIDER_TIMEOUT", "45s")
+	assert.Equal(t, 45*time.Second, ProviderTimeoutFromEnv())
+
+	t.Setenv("AI_PROVIDER_TIMEOUT", "soon")
+	assert.Equal(t, DefaultProviderTimeout, ProviderTimeoutFromEnv())
+}
+
+func TestGenerationTimeoutFromEnv(t *testing.T) {
+	t.Setenv("AI_GENERATION_TIMEOUT", "")
+	assert.Equal(t, DefaultGenerationTimeout, GenerationTimeoutFromEnv())
+
+	t.Setenv("AI_GENERATION_TIMEOUT", "2m")
+	assert.Equal(t, 2*time.Minute, GenerationTimeoutFromEnv())
+
+	t.Setenv("AI_GENERATION_TIMEOUT", "-5s")
+	assert.Equal(t, DefaultGenerationTimeout, GenerationTimeoutFromEnv())
+}
@@ -3,6 +3,7 @@ package generator
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,38 @@ var (
 	ErrDatabaseError = errors.New("database error while logging AI generation")
 )
 
+// Generation kinds, logged with each generation
+const (
+	KindGenerate  = "generate"  // A new or modified survey
+	KindTranslate = "translate" // A survey translated into another language
+	KindSummarize = "summarize" // A summary of a question's text answers
+)
+
+type kindKey struct{}
+
+// WithKind returns a context that makes GenerationLogger log generations
+// as kind instead of KindGenerate
+func WithKind(ctx context.Context, kind string) context.Context {
+	return context.WithValue(ctx, kindKey{}, kind)
+}
+
+// kindFrom returns the kind set by WithKind, or KindGenerate
+func kindFrom(ctx context.Context) string {
+	if kind, ok := ctx.Value(kindKey{}).(string); ok {
+		return kind
+	}
+	return KindGenerate
+}
+
+type promptVersionKey struct{}
+
+// WithPromptVersion returns a context that makes GenerationLogger.LogError
+// record version, the system prompt a failed generation was sent, instead
+// of the logger's default
+func WithPromptVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, promptVersionKey{}, version)
+}
+
 // AIGenerationLog represents a single AI generation request/response log entry
 type AIGenerationLog struct {
 	ID           uuid.UUID
@@ -21,13 +54,31 @@ type AIGenerationLog struct {
 	InputPrompt  string
 	SystemPrompt string
 	RawResponse  string // Empty if generation failed
-	Status       string // "success", "error", "rate_limited", "validation_failed"
+	Status       string // "success", "error", "rate_limited", "validation_failed", "cancelled", "timeout"
 	ErrorMessage string
 	InputTokens  int
 	OutputTokens int
 	CostUSD      float64
 	DurationMS   int
-	CreatedAt    time.Time
+	Provider     string // Provider that served the request, e.g. "openai"; empty if unknown
+	Model        string // Model that served the request; empty if unknown
+	FallbackFrom string // Provider that failed before this one served the request; empty without fallback
+	Attempts     int    // Requests sent to Provider, counting retries; zero is logged as 1
+	Kind         string // What was generated: a Kind constant; empty is logged as KindGenerate
+
+	// Temperature is the sampling temperature sent, nil for logs from before
+	// it was recorded. PromptVersion is the version of the system prompt
+	// (see PromptSelector), empty when unknown.
+	Temperature   *float64
+	PromptVersion string
+
+	// OutputValidation is how the response passed validation (an Output
+	// constant), empty when there was no response. OutputIssues lists the
+	// problems with the model's first response, "; "-separated.
+	OutputValidation string
+	OutputIssues     string
+
+	CreatedAt time.Time
 }
 
 // Validate checks if the log entry is valid
@@ -37,9 +88,15 @@ func (l *AIGenerationLog) Validate() error {
 		"error":              true,
 		"rate_limited":       true,
 		"validation_failed":  true,
+		"cancelled":          true,
+		"timeout":            true,
 	}
 	if !validStatuses[l.Status] {
-		return errors.New("invalid status: must be success, error, rate_limited, or validation_failed")
+		return errors.New("invalid status: must be success, error, rate_limited, validation_failed, cancelled, or timeout")
+	}
+
+	if l.Kind != "" && l.Kind != KindGenerate && l.Kind != KindTranslate && l.Kind != KindSummarize {
+		return errors.New("invalid kind: must be generate, translate or summarize")
 	}
 
 	validUserTypes := map[string]bool{
@@ -60,12 +117,50 @@ type GenerationLogDB interface {
 
 // GenerationLogger logs AI generation requests and responses
 type GenerationLogger struct {
-	db GenerationLogDB
+	db            GenerationLogDB
+	provider      string
+	model         string
+	temperature   *float64
+	promptVersion string
+	redactPII     bool
 }
 
-// NewGenerationLogger creates a new generation logger
+// NewGenerationLogger creates a new generation logger, which redacts
+// probable PII from the prompts and responses it stores
 func NewGenerationLogger(db GenerationLogDB) *GenerationLogger {
-	return &GenerationLogger{db: db}
+	return &GenerationLogger{db: db, redactPII: true}
+}
+
+// SetRedactPII sets whether prompts and raw responses are stored with emails,
+// phone numbers and street addresses redacted (see RedactPII), since a model
+// may echo what the user wrote. Only the stored copies change; the model is
+// always sent what the user wrote.
+func (l *GenerationLogger) SetRedactPII(redact bool) {
+	l.redactPII = redact
+}
+
+// stored returns a prompt or raw response as it's stored in the logs
+func (l *GenerationLogger) stored(text string) string {
+	if l.redactPII {
+		return RedactPII(text)
+	}
+	return text
+}
+
+// SetProvider sets the provider and model recorded on failed generations,
+// which have no GenerateResult to take them from
+func (l *GenerationLogger) SetProvider(provider, model string) {
+	l.provider = provider
+	l.model = model
+}
+
+// SetGenerationSettings sets the temperature and prompt version recorded on
+// failed generations, which have no GenerateResult to take them from. With
+// prompt versions split, pass the primary version; generations that got as
+// far as a prompt record theirs with WithPromptVersion.
+func (l *GenerationLogger) SetGenerationSettings(temperature float64, promptVersion string) {
+	l.temperature = &temperature
+	l.promptVersion = promptVersion
 }
 
 // LogSuccess logs a successful AI generation
@@ -88,16 +183,31 @@ func (l *GenerationLogger) LogSuccess(
 		ID:           uuid.New(),
 		UserID:       userID,
 		UserType:     userType,
-		InputPrompt:  inputPrompt,
+		InputPrompt:  l.stored(inputPrompt),
 		SystemPrompt: systemPrompt,
-		RawResponse:  rawResponse,
+		RawResponse:  l.stored(rawResponse),
 		Status:       "success",
 		ErrorMessage: "",
 		InputTokens:  result.InputTokens,
 		OutputTokens: result.OutputTokens,
 		CostUSD:      result.EstimatedCost,
 		DurationMS:   durationMS,
+		Provider:     result.Provider,
+		Model:        result.Model,
+		FallbackFrom: result.FallbackFrom,
+		Attempts:     result.Attempts,
+		Kind:         kindFrom(ctx),
 		CreatedAt:    time.Now(),
+
+		Temperature:   &result.Temperature,
+		PromptVersion: result.PromptVersion,
+
+		OutputValidation: result.OutputValidation,
+		OutputIssues:     strings.Join(result.OutputIssues, "; "),
+	}
+	if log.Provider == "" {
+		log.Provider, log.Model = l.provider, l.model
+		log.Temperature, log.PromptVersion = l.temperature, l.promptVersion
 	}
 
 	if err := log.Validate(); err != nil {
@@ -115,7 +225,7 @@ func (l *GenerationLogger) LogError(
 	inputPrompt string,
 	systemPrompt string,
 	rawResponse string, // LLM response even if validation failed
-	status string,      // "error", "rate_limited", "validation_failed"
+	status string,      // "error", "rate_limited", "validation_failed", "cancelled", "timeout"
 	errorMessage string,
 	inputTokens int,
 	outputTokens int,
@@ -131,16 +241,25 @@ func (l *GenerationLogger) LogError(
 		ID:           uuid.New(),
 		UserID:       userID,
 		UserType:     userType,
-		InputPrompt:  inputPrompt,
+		InputPrompt:  l.stored(inputPrompt),
 		SystemPrompt: systemPrompt,
-		RawResponse:  rawResponse,
+		RawResponse:  l.stored(rawResponse),
 		Status:       status,
 		ErrorMessage: errorMessage,
 		InputTokens:  inputTokens,
 		OutputTokens: outputTokens,
 		CostUSD:      costUSD,
 		DurationMS:   durationMS,
+		Provider:     l.provider,
+		Model:        l.model,
+		Kind:         kindFrom(ctx),
 		CreatedAt:    time.Now(),
+
+		Temperature:   l.temperature,
+		PromptVersion: l.promptVersion,
+	}
+	if version, ok := ctx.Value(promptVersionKey{}).(string); ok && version != "" {
+		log.PromptVersion = version
 	}
 
 	if err := log.Validate(); err != nil {
@@ -149,3 +268,59 @@ func (l *GenerationLogger) LogError(
 
 	return l.db.LogGeneration(ctx, log)
 }
+
+// LogFailedAttempts logs the provider calls a generation fell back from, one
+// "error" entry each, recorded against the provider that failed. When the
+// generation failed too, its final attempt is among them.
+func (l *GenerationLogger) LogFailedAttempts(
+	ctx context.Context,
+	userID string,
+	userType string,
+	inputPrompt string,
+	systemPrompt string,
+	attempts []FailedAttempt,
+) error {
+	// Allow nil logger (no-op)
+	if l == nil {
+		return nil
+	}
+
+	prompt := l.stored(inputPrompt)
+	var errs []error
+	for _, attempt := range attempts {
+		log := &AIGenerationLog{
+			ID:           uuid.New(),
+			UserID:       userID,
+			UserType:     userType,
+			InputPrompt:  prompt,
+			SystemPrompt: systemPrompt,
+			RawResponse:  l.stored(attempt.RawResponse),
+			Status:       "error",
+			ErrorMessage: attempt.Err.Error(),
+			InputTokens:  attempt.InputTokens,
+			OutputTokens: attempt.OutputTokens,
+			CostUSD:      attempt.CostUSD,
+			DurationMS:   attempt.DurationMS,
+			Provider:     attempt.Provider,
+			Model:        attempt.Model,
+			Attempts:     attempt.Attempts,
+			Kind:         kindFrom(ctx),
+			CreatedAt:    time.Now(),
+
+			Temperature:   &attempt.Temperature,
+			PromptVersion: attempt.PromptVersion,
+
+			OutputValidation: attempt.OutputValidation,
+			OutputIssues:     strings.Join(attempt.OutputIssues, "; "),
+		}
+
+		if err := log.Validate(); err != nil {
+			return err
+		}
+		if err := l.db.LogGeneration(ctx, log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
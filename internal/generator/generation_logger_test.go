@@ -2,6 +2,7 @@ package generator
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 // MockLogDB is a mock database for testing the logger
 type MockLogDB struct {
 	lastLog *AIGenerationLog
+	logs []*AIGenerationLog
 	callCount int
 	shouldError bool
 }
@@ -19,6 +21,7 @@ type MockLogDB struct {
 func (m *MockLogDB) LogGeneration(ctx context.Context, log *AIGenerationLog) error {
 	m.callCount++
 	m.lastLog = log
+	m.logs = append(m.logs, log)
 	if m.shouldError {
 		return ErrDatabaseError
 	}
@@ -160,6 +163,173 @@ func TestGenerationLogger_LogError(t *testing.T) {
 	}
 }
 
+func TestGenerationLogger_RecordsProvider(t *testing.T) {
+	mockDB := &MockLogDB{}
+	logger := NewGenerationLogger(mockDB)
+	logger.SetProvider("anthropic", "claude-haiku-4-5")
+	ctx := context.Background()
+
+	// A success records the provider that served it
+	result := &GenerateResult{Provider: "openai", Model: "gpt-4o-mini"}
+	if err := logger.LogSuccess(ctx, "did:test", "authenticated", "prompt", "system", "response", result, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockDB.lastLog.Provider != "openai" || mockDB.lastLog.Model != "gpt-4o-mini" {
+		t.Errorf("Expected openai/gpt-4o-mini, got %s/%s", mockDB.lastLog.Provider, mockDB.lastLog.Model)
+	}
+
+	// A failure records the configured provider
+	if err := logger.LogError(ctx, "did:test", "authenticated", "prompt", "", "", "error", "Cost limit exceeded", 0, 0, 0, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockDB.lastLog.Provider != "anthropic" || mockDB.lastLog.Model != "claude-haiku-4-5" {
+		t.Errorf("Expected anthropic/claude-haiku-4-5, got %s/%s", mockDB.lastLog.Provider, mockDB.lastLog.Model)
+	}
+}
+
+func TestGenerationLogger_RecordsSettings(t *testing.T) {
+	mockDB := &MockLogDB{}
+	logger := NewGenerationLogger(mockDB)
+	ctx := context.Background()
+
+	// Without configured settings, failures don't know them
+	if err := logger.LogError(ctx, "did:test", "authenticated", "prompt", "", "", "rate_limited", "Rate limit exceeded", 0, 0, 0, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockDB.lastLog.Temperature != nil || mockDB.lastLog.PromptVersion != "" {
+		t.Errorf("Expected no settings, got %v and %q", mockDB.lastLog.Temperature, mockDB.lastLog.PromptVersion)
+	}
+
+	logger.SetProvider("openai", "gpt-4o-mini")
+	logger.SetGenerationSettings(0.7, "v1")
+
+	// A success records the settings it ran with
+	result := &GenerateResult{Provider: "openai", Model: "gpt-4.1", Temperature: 0.2, PromptVersion: "v2"}
+	if err := logger.LogSuccess(ctx, "did:test", "authenticated", "prompt", "system", "response", result, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := mockDB.lastLog; got.Model != "gpt-4.1" || got.Temperature == nil || *got.Temperature != 0.2 || got.PromptVersion != "v2" {
+		t.Errorf("Expected gpt-4.1 at 0.2 with v2, got %s at %v with %q", got.Model, got.Temperature, got.PromptVersion)
+	}
+
+	// A failure records the configured settings
+	if err := logger.LogError(ctx, "did:test", "authenticated", "prompt", "", "", "error", "failed", 0, 0, 0, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := mockDB.lastLog; got.Temperature == nil || *got.Temperature != 0.7 || got.PromptVersion != "v1" {
+		t.Errorf("Expected 0.7 with v1, got %v with %q", got.Temperature, got.PromptVersion)
+	}
+
+	// A failure that got as far as a prompt records its version
+	if err := logger.LogError(WithPromptVersion(ctx, "v2"), "did:test", "authenticated", "prompt", "", "", "error", "failed", 0, 0, 0, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := mockDB.lastLog.PromptVersion; got != "v2" {
+		t.Errorf("Expected v2, got %q", got)
+	}
+
+	// A failed attempt records its own
+	attempts := []FailedAttempt{{Provider: "openai", Model: "gpt-4.1", Err: errors.New("overloaded"), Temperature: 0.2, PromptVersion: "v2"}}
+	if err := logger.LogFailedAttempts(ctx, "did:test", "authenticated", "prompt", "system", attempts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := mockDB.lastLog; got.Temperature == nil || *got.Temperature != 0.2 || got.PromptVersion != "v2" {
+		t.Errorf("Expected 0.2 with v2, got %v with %q", got.Temperature, got.PromptVersion)
+	}
+}
+
+func TestGenerationLogger_RecordsKind(t *testing.T) {
+	mockDB := &MockLogDB{}
+	logger := NewGenerationLogger(mockDB)
+	ctx := context.Background()
+
+	if err := logger.LogSuccess(ctx, "did:test", "authenticated", "prompt", "system", "response", &GenerateResult{}, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mockDB.lastLog.Kind != KindGenerate {
+		t.Errorf("Expected kind %q, got %q", KindGenerate, mockDB.lastLog.Kind)
+	}
+
+	// Every kind of log records the context's kind
+	ctx = WithKind(ctx, KindTranslate)
+	if err := logger.LogSuccess(ctx, "did:test", "authenticated", "prompt", "system", "response", &GenerateResult{}, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := logger.LogError(ctx, "did:test", "authenticated", "prompt", "", "", "error", "failed", 0, 0, 0, 100); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	attempts := []FailedAttempt{{Provider: "openai", Err: errors.New("overloaded")}}
+	if err := logger.LogFailedAttempts(ctx, "did:test", "authenticated", "prompt", "system", attempts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, log := range mockDB.logs[1:] {
+		if log.Kind != KindTranslate {
+			t.Errorf("Expected kind %q, got %q", KindTranslate, log.Kind)
+		}
+	}
+
+	if err := (&AIGenerationLog{Status: "success", UserType: "anonymous", Kind: "poem"}).Validate(); err == nil {
+		t.Error("Expected an unknown kind to be invalid")
+	}
+}
+
+func TestGenerationLogger_LogFailedAttempts(t *testing.T) {
+	mockDB := &MockLogDB{}
+	logger := NewGenerationLogger(mockDB)
+	logger.SetProvider("openai", "gpt-4o-mini")
+	ctx := context.Background()
+
+	attempts := []FailedAttempt{
+		{Provider: "openai", Model: "gpt-4o-mini", Err: errors.New("API returned unexpected status code: 503"), DurationMS: 40, Attempts: 3},
+	}
+	if err := logger.LogFailedAttempts(ctx, "did:test", "authenticated", "prompt", "system", attempts); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	result := &GenerateResult{Provider: "anthropic", Model: "claude-haiku-4-5", FallbackFrom: "openai", Attempts: 2}
+	if err := logger.LogSuccess(ctx, "did:test", "authenticated", "prompt", "system", "response", result, 900); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(mockDB.logs) != 2 {
+		t.Fatalf("Expected 2 logs, got %d", len(mockDB.logs))
+	}
+	failed, success := mockDB.logs[0], mockDB.logs[1]
+	if failed.Status != "error" || failed.Provider != "openai" || failed.DurationMS != 40 {
+		t.Errorf("Unexpected failed attempt log %+v", failed)
+	}
+	if failed.ErrorMessage != "API returned unexpected status code: 503" {
+		t.Errorf("Expected the attempt's error, got %q", failed.ErrorMessage)
+	}
+	if failed.FallbackFrom != "" {
+		t.Errorf("Expected no fallback_from on the failed attempt, got %q", failed.FallbackFrom)
+	}
+	if success.Status != "success" || success.Provider != "anthropic" || success.FallbackFrom != "openai" {
+		t.Errorf("Unexpected success log %+v", success)
+	}
+	if failed.Attempts != 3 || success.Attempts != 2 {
+		t.Errorf("Expected attempts 3 and 2, got %d and %d", failed.Attempts, success.Attempts)
+	}
+
+	// The output validation outcome is logged with the response
+	result.OutputValidation = OutputRepaired
+	result.OutputIssues = []string{"question 0: question ID is required", "question 1: duplicate option ID 'opt1'"}
+	if err := logger.LogSuccess(ctx, "did:test", "authenticated", "prompt", "system", "response", result, 900); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	repaired := mockDB.logs[2]
+	if repaired.OutputValidation != OutputRepaired || repaired.OutputIssues != "question 0: question ID is required; question 1: duplicate option ID 'opt1'" {
+		t.Errorf("Expected the output validation, got %q: %q", repaired.OutputValidation, repaired.OutputIssues)
+	}
+
+	// Nothing to log without attempts
+	if err := logger.LogFailedAttempts(ctx, "did:test", "authenticated", "prompt", "system", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mockDB.logs) != 3 {
+		t.Errorf("Expected no more logs, got %d", len(mockDB.logs))
+	}
+}
+
 func TestGenerationLogger_LogRateLimited(t *testing.T) {
 	mockDB := &MockLogDB{}
 	logger := NewGenerationLogger(mockDB)
@@ -182,6 +352,44 @@ func TestGenerationLogger_LogRateLimited(t *testing.T) {
 	}
 }
 
+func TestGenerationLogger_RedactsPII(t *testing.T) {
+	ctx := context.Background()
+	prompt := "Survey for our block party at 12 Elm Lane, RSVP to jane@example.com or 555-123-4567"
+	redacted := "Survey for our block party at [ADDRESS], RSVP to [EMAIL] or [PHONE]"
+	// Models echo the prompt, so the responses are redacted too
+	response := `{"title":"Block party","description":"RSVP to jane@example.com or 555-123-4567"}`
+	redactedResponse := `{"title":"Block party","description":"RSVP to [EMAIL] or [PHONE]"}`
+	result := &GenerateResult{InputTokens: 100, OutputTokens: 50}
+	attempts := []FailedAttempt{{Provider: ProviderOpenAI, Model: "gpt-4o-mini", Err: errors.New("timeout"), RawResponse: response}}
+
+	mockDB := &MockLogDB{}
+	logger := NewGenerationLogger(mockDB)
+	_ = logger.LogSuccess(ctx, "did:plc:test", "authenticated", prompt, "system", response, result, 100)
+	_ = logger.LogError(ctx, "did:plc:test", "authenticated", prompt, "system", response, "error", "failed", 0, 0, 0, 100)
+	_ = logger.LogFailedAttempts(ctx, "did:plc:test", "authenticated", prompt, "system", attempts)
+	if len(mockDB.logs) != 3 {
+		t.Fatalf("Expected 3 logs, got %d", len(mockDB.logs))
+	}
+	for _, log := range mockDB.logs {
+		if log.InputPrompt != redacted {
+			t.Errorf("Expected input_prompt=%q, got %q", redacted, log.InputPrompt)
+		}
+		if log.RawResponse != redactedResponse {
+			t.Errorf("Expected raw_response=%q, got %q", redactedResponse, log.RawResponse)
+		}
+	}
+
+	t.Run("can be turned off", func(t *testing.T) {
+		mockDB := &MockLogDB{}
+		logger := NewGenerationLogger(mockDB)
+		logger.SetRedactPII(false)
+		_ = logger.LogSuccess(ctx, "did:plc:test", "authenticated", prompt, "system", "{}", result, 100)
+		if mockDB.lastLog.InputPrompt != prompt {
+			t.Errorf("Expected input_prompt=%q, got %q", prompt, mockDB.lastLog.InputPrompt)
+		}
+	})
+}
+
 func TestGenerationLogger_NilLogger(t *testing.T) {
 	// Nil logger should be safe to call (no-op)
 	var logger *GenerationLogger
@@ -0,0 +1,314 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+const (
+	// ProviderOpenAI, ProviderAnthropic and ProviderOllama are the
+	// AI_PROVIDER values, also recorded in ai_generation_logs.provider
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+
+	// DefaultOpenAIModel, DefaultAnthropicModel and DefaultOllamaModel are
+	// used when OPENAI_MODEL, ANTHROPIC_MODEL or OLLAMA_MODEL is unset
+	DefaultOpenAIModel    = "gpt-4o-mini"
+	DefaultAnthropicModel = "claude-haiku-4-5"
+	DefaultOllamaModel    = "llama3.2"
+
+	// generationMaxTokens caps a response; the Anthropic API requires a cap,
+	// and a 50-question survey fits well within it
+	generationMaxTokens = 4096
+)
+
+// DefaultTemperature is the sampling temperature sent with every request
+// unless AI_TEMPERATURE is set: a little variety in wording, while keeping
+// to the JSON structure asked for
+const DefaultTemperature = 0.7
+
+// TemperatureFromEnv reads AI_TEMPERATURE, from 0 to 1, the range OpenAI and
+// Anthropic share (default 0.7)
+func TemperatureFromEnv() float64 {
+	if v := os.Getenv("AI_TEMPERATURE"); v != "" {
+		if t, err := strconv.ParseFloat(v, 64); err == nil && t >= 0 && t <= 1 {
+			return t
+		}
+	}
+	return DefaultTemperature
+}
+
+// Pricing is a model's price in USD per million tokens
+type Pricing struct {
+	InputPer1M  float64
+	OutputPer1M float64
+}
+
+// Cost returns the price of a call with the given token counts
+func (p Pricing) Cost(inputTokens, outputTokens int) float64 {
+	return float64(inputTokens)*p.InputPer1M/1_000_000 + float64(outputTokens)*p.OutputPer1M/1_000_000
+}
+
+// OpenAIPricing lists the OpenAI models OPENAI_MODEL may name
+// https://openai.com/api/pricing/
+var OpenAIPricing = map[string]Pricing{
+	"gpt-4o-mini":  {InputPer1M: InputTokenCostPer1M, OutputPer1M: OutputTokenCostPer1M},
+	"gpt-4o":       {InputPer1M: 2.50, OutputPer1M: 10.00},
+	"gpt-4.1-mini": {InputPer1M: 0.40, OutputPer1M: 1.60},
+	"gpt-4.1":      {InputPer1M: 2.00, OutputPer1M: 8.00},
+}
+
+// AnthropicPricing lists the Anthropic models ANTHROPIC_MODEL may name
+// https://www.anthropic.com/pricing#api
+var AnthropicPricing = map[string]Pricing{
+	"claude-haiku-4-5":        {InputPer1M: 1.00, OutputPer1M: 5.00},
+	"claude-3-5-haiku-latest": {InputPer1M: 0.80, OutputPer1M: 4.00},
+	"claude-sonnet-4-5":       {InputPer1M: 3.00, OutputPer1M: 15.00},
+	"claude-sonnet-4-0":       {InputPer1M: 3.00, OutputPer1M: 15.00},
+}
+
+// GenerationRequest is one survey generation call to a Provider
+type GenerationRequest struct {
+	SystemPrompt string
+	Prompt       string
+	Temperature  float64
+
+	// OnChunk, if set, streams the response: it receives the output as it
+	// arrives. Generate still returns the whole response.
+	OnChunk func(chunk string)
+}
+
+// GenerationResult is a Provider's response: the survey JSON as the model
+// wrote it, before sanitization, and what the call cost
+type GenerationResult struct {
+	JSON         string
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
+}
+
+// Provider generates survey JSON with one LLM vendor. SurveyGenerator
+// validates whatever a provider returns the same way.
+type Provider interface {
+	Name() string // ProviderOpenAI, ProviderAnthropic or ProviderOllama
+	Model() string
+	Pricing() Pricing
+	Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error)
+}
+
+// LLMProvider is a Provider backed by a langchaingo model
+type LLMProvider struct {
+	name    string
+	model   string
+	llm     llms.Model
+	pricing Pricing
+	options []llms.CallOption
+}
+
+// NewLLMProvider creates a Provider named name that calls model on llm
+func NewLLMProvider(name, model string, llm llms.Model, pricing Pricing, options ...llms.CallOption) *LLMProvider {
+	return &LLMProvider{
+		name:    name,
+		model:   model,
+		llm:     llm,
+		pricing: pricing,
+		options: append([]llms.CallOption{llms.WithModel(model)}, options...),
+	}
+}
+
+// Name returns the provider's name, e.g. "openai"
+func (p *LLMProvider) Name() string { return p.name }
+
+// Model returns the model the provider calls
+func (p *LLMProvider) Model() string { return p.model }
+
+// Pricing returns the model's price
+func (p *LLMProvider) Pricing() Pricing { return p.pricing }
+
+// Generate sends the system prompt and prompt to the model. Token counts come
+// from the provider's usage report, which streamed responses include too, or
+// are estimated when it has none.
+func (p *LLMProvider) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, req.SystemPrompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, req.Prompt),
+	}
+
+	options := append(p.options[:len(p.options):len(p.options)], llms.WithTemperature(req.Temperature))
+	if req.OnChunk != nil {
+		options = append(options, llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			req.OnChunk(string(chunk))
+			return nil
+		}))
+	}
+
+	resp, err := p.llm.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, fmt.Errorf("%s generation failed: %w", p.name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, ErrEmptyResponse
+	}
+
+	choice := resp.Choices[0]
+	inputTokens := usageTokens(choice.GenerationInfo, "PromptTokens", "InputTokens")
+	if inputTokens == 0 {
+		inputTokens = estimateTokens(req.SystemPrompt + req.Prompt)
+	}
+	outputTokens := usageTokens(choice.GenerationInfo, "CompletionTokens", "OutputTokens")
+	if outputTokens == 0 {
+		outputTokens = estimateTokens(choice.Content)
+	}
+
+	return &GenerationResult{
+		JSON:         stripCodeFence(choice.Content),
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		CostUSD:      p.pricing.Cost(inputTokens, outputTokens),
+	}, nil
+}
+
+// usageTokens reads a token count from a langchaingo GenerationInfo, which
+// names it differently per vendor (OpenAI: PromptTokens, Anthropic:
+// InputTokens). Zero means no usage was reported.
+func usageTokens(info map[string]any, keys ...string) int {
+	for _, key := range keys {
+		if n, ok := info[key].(int); ok && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// stripCodeFence removes a markdown code fence around the JSON; models
+// sometimes add one despite the system prompt
+func stripCodeFence(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "```") || !strings.HasSuffix(trimmed, "```") || len(trimmed) < 6 {
+		return text
+	}
+	trimmed = strings.TrimSuffix(strings.TrimPrefix(trimmed, "```"), "```")
+	// Drop the language tag, e.g. ```json
+	if newline := strings.IndexByte(trimmed, '\n'); newline >= 0 && !strings.ContainsAny(trimmed[:newline], "{[") {
+		trimmed = trimmed[newline+1:]
+	}
+	return strings.TrimSpace(trimmed)
+}
+
+// ProvidersFromEnv creates the provider chain AI_PROVIDER lists, primary
+// first: a comma-separated list of "openai" (the default), "anthropic" and
+// "ollama", e.g. "anthropic,openai" to fall back to OpenAI when Anthropic is
+// down.
+//   - openai: OPENAI_API_KEY, and OPENAI_MODEL (default gpt-4o-mini)
+//   - anthropic: ANTHROPIC_API_KEY, and ANTHROPIC_MODEL (default claude-haiku-4-5)
+//   - ollama: OLLAMA_HOST (default 127.0.0.1:11434), and OLLAMA_MODEL
+//     (default llama3.2); needs no key, and costs nothing
+//
+// Returns nil without an error when the primary provider's API key is unset,
+// leaving AI generation disabled; a fallback without its key is an error. The
+// model must be in the provider's pricing table, so generation costs are
+// known; any Ollama model is free.
+func ProvidersFromEnv() ([]Provider, error) {
+	value := os.Getenv("AI_PROVIDER")
+	if strings.TrimSpace(value) == "" {
+		value = ProviderOpenAI
+	}
+
+	var providers []Provider
+	seen := make(map[string]bool)
+	for i, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			return nil, fmt.Errorf("invalid AI_PROVIDER: %q is listed twice", name)
+		}
+		seen[name] = true
+
+		provider, err := providerFromEnv(name)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			if i == 0 {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid AI_PROVIDER: fallback %q has no API key", name)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// providerFromEnv creates the named provider, or returns nil if its API key
+// is unset
+func providerFromEnv(name string) (Provider, error) {
+	switch name {
+	case ProviderOpenAI:
+		key := os.Getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, nil
+		}
+		model, pricing, err := modelFromEnv("OPENAI_MODEL", DefaultOpenAIModel, OpenAIPricing)
+		if err != nil {
+			return nil, err
+		}
+		llm, err := openai.New(openai.WithToken(key), openai.WithModel(model), openai.WithHTTPClient(newRetryClient()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize OpenAI client: %w", err)
+		}
+		return NewLLMProvider(ProviderOpenAI, model, llm, pricing), nil
+
+	case ProviderAnthropic:
+		key := os.Getenv("ANTHROPIC_API_KEY")
+		if key == "" {
+			return nil, nil
+		}
+		model, pricing, err := modelFromEnv("ANTHROPIC_MODEL", DefaultAnthropicModel, AnthropicPricing)
+		if err != nil {
+			return nil, err
+		}
+		llm, err := anthropic.New(anthropic.WithToken(key), anthropic.WithModel(model), anthropic.WithHTTPClient(newRetryClient()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Anthropic client: %w", err)
+		}
+		return NewLLMProvider(ProviderAnthropic, model, llm, pricing, llms.WithMaxTokens(generationMaxTokens)), nil
+
+	case ProviderOllama:
+		model := strings.TrimSpace(os.Getenv("OLLAMA_MODEL"))
+		if model == "" {
+			model = DefaultOllamaModel
+		}
+		// The client reads OLLAMA_HOST itself. Asking for JSON keeps small
+		// models from wrapping the survey in prose.
+		llm, err := ollama.New(ollama.WithModel(model), ollama.WithFormat("json"), ollama.WithHTTPClient(newRetryClient()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Ollama client: %w", err)
+		}
+		return NewLLMProvider(ProviderOllama, model, llm, Pricing{}, llms.WithMaxTokens(generationMaxTokens)), nil
+
+	default:
+		return nil, fmt.Errorf("invalid AI_PROVIDER: %q (must be %s, %s or %s)", name, ProviderOpenAI, ProviderAnthropic, ProviderOllama)
+	}
+}
+
+// modelFromEnv reads a model name from envVar and looks up its pricing
+func modelFromEnv(envVar, defaultModel string, pricing map[string]Pricing) (string, Pricing, error) {
+	model := strings.TrimSpace(os.Getenv(envVar))
+	if model == "" {
+		model = defaultModel
+	}
+	price, ok := pricing[model]
+	if !ok {
+		return "", Pricing{}, errors.New("invalid " + envVar + ": no pricing for model " + model)
+	}
+	return model, price, nil
+}
@@ -0,0 +1,445 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// usageLLM is an llms.Model returning a fixed response with usage info, and
+// recording the call options it was given. It streams the response when asked.
+type usageLLM struct {
+	content string
+	info    map[string]any
+	err     error
+	options llms.CallOptions
+}
+
+func (m *usageLLM) GenerateContent(ctx context.Context, _ []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	for _, option := range options {
+		option(&m.options)
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.options.StreamingFunc != nil {
+		// Stream in small chunks that split tokens, like a real provider
+		for i := 0; i < len(m.content); i += 7 {
+			if err := m.options.StreamingFunc(ctx, []byte(m.content[i:min(i+7, len(m.content))])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{
+		{Content: m.content, GenerationInfo: m.info},
+	}}, nil
+}
+
+func (m *usageLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+const pizzaPollJSON = `{"questions":[{"id":"q1","text":"Do you like pizza?","type":"single","required":false,"options":[{"id":"opt1","text":"Yes"},{"id":"opt2","text":"No"}]}],"anonymous":false}`
+
+func TestPricingCost(t *testing.T) {
+	pricing := Pricing{InputPer1M: 1.00, OutputPer1M: 5.00}
+	assert.InDelta(t, 0.0, pricing.Cost(0, 0), 1e-12)
+	assert.InDelta(t, 1.00, pricing.Cost(1_000_000, 0), 1e-12)
+	assert.InDelta(t, 0.0035, pricing.Cost(1000, 500), 1e-12)
+}
+
+func TestLLMProvider_Generate(t *testing.T) {
+	ctx := context.Background()
+	req := GenerationRequest{SystemPrompt: "system", Prompt: "pizza poll", Temperature: 0.4}
+
+	t.Run("reads Anthropic usage", func(t *testing.T) {
+		llm := &usageLLM{content: pizzaPollJSON, info: map[string]any{"InputTokens": 1200, "OutputTokens": 300}}
+		provider := NewLLMProvider(ProviderAnthropic, "claude-haiku-4-5", llm, AnthropicPricing["claude-haiku-4-5"], llms.WithMaxTokens(generationMaxTokens))
+
+		result, err := provider.Generate(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, pizzaPollJSON, result.JSON)
+		assert.Equal(t, 1200, result.InputTokens)
+		assert.Equal(t, 300, result.OutputTokens)
+		assert.InDelta(t, 0.0027, result.CostUSD, 1e-12) // 1200 * $1/1M + 300 * $5/1M
+		assert.Equal(t, "claude-haiku-4-5", llm.options.Model)
+		assert.Equal(t, generationMaxTokens, llm.options.MaxTokens)
+		assert.Equal(t, 0.4, llm.options.Temperature)
+	})
+
+	t.Run("reads OpenAI usage", func(t *testing.T) {
+		llm := &usageLLM{content: pizzaPollJSON, info: map[string]any{"PromptTokens": 1000, "CompletionTokens": 100}}
+		provider := NewLLMProvider(ProviderOpenAI, "gpt-4o-mini", llm, OpenAIPricing["gpt-4o-mini"])
+
+		result, err := provider.Generate(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, result.InputTokens)
+		assert.Equal(t, 100, result.OutputTokens)
+		assert.InDelta(t, 0.00021, result.CostUSD, 1e-12) // 1000 * $0.15/1M + 100 * $0.60/1M
+	})
+
+	t.Run("estimates tokens without usage", func(t *testing.T) {
+		llm := &usageLLM{content: pizzaPollJSON}
+		provider := NewLLMProvider(ProviderOpenAI, "gpt-4o-mini", llm, OpenAIPricing["gpt-4o-mini"])
+
+		result, err := provider.Generate(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, estimateTokens("systempizza poll"), result.InputTokens)
+		assert.Equal(t, estimateTokens(pizzaPollJSON), result.OutputTokens)
+		assert.Greater(t, result.CostUSD, 0.0)
+	})
+
+	t.Run("wraps vendor errors", func(t *testing.T) {
+		llm := &usageLLM{err: errors.New("overloaded")}
+		provider := NewLLMProvider(ProviderAnthropic, "claude-haiku-4-5", llm, AnthropicPricing["claude-haiku-4-5"])
+
+		_, err := provider.Generate(ctx, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "anthropic generation failed")
+	})
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain JSON", pizzaPollJSON, pizzaPollJSON},
+		{"json fence", "```json\n" + pizzaPollJSON + "\n```", pizzaPollJSON},
+		{"bare fence", "```\n" + pizzaPollJSON + "\n```", pizzaPollJSON},
+		{"fence on one line", "```" + pizzaPollJSON + "```", pizzaPollJSON},
+		{"unterminated fence", "```json\n" + pizzaPollJSON, "```json\n" + pizzaPollJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stripCodeFence(tt.input))
+		})
+	}
+}
+
+func TestSurveyGenerator_AnthropicProvider(t *testing.T) {
+	ctx := context.Background()
+	newGenerator := func(content string) *SurveyGenerator {
+		llm := &usageLLM{content: content, info: map[string]any{"InputTokens": 800, "OutputTokens": 120}}
+		return NewSurveyGeneratorWithProvider(NewLLMProvider(ProviderAnthropic, "claude-haiku-4-5", llm, AnthropicPricing["claude-haiku-4-5"]))
+	}
+
+	t.Run("generates a survey and records the provider", func(t *testing.T) {
+		result, err := newGenerator("```json\n"+pizzaPollJSON+"\n```").Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		require.NotNil(t, result.Definition)
+		assert.Equal(t, "Do you like pizza?", result.Definition.Questions[0].Text)
+		assert.Equal(t, ProviderAnthropic, result.Provider)
+		assert.Equal(t, "claude-haiku-4-5", result.Model)
+		assert.Equal(t, 800, result.InputTokens)
+		assert.InDelta(t, AnthropicPricing["claude-haiku-4-5"].Cost(800, 120), result.EstimatedCost, 1e-12)
+	})
+
+	t.Run("validates output like any other provider", func(t *testing.T) {
+		// A choice question without options doesn't match the lexicon
+		invalid := `{"questions":[{"id":"q1","text":"Pick one","type":"single","required":false,"options":[]}],"anonymous":false}`
+		result, err := newGenerator(invalid).Generate(ctx, "Create a poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid LLM output")
+		require.NotNil(t, result)
+		assert.Nil(t, result.Definition)
+		assert.Equal(t, invalid, result.RawResponse)
+		assert.Equal(t, ProviderAnthropic, result.Provider)
+	})
+
+	t.Run("sanitizes output like any other provider", func(t *testing.T) {
+		malicious := `{"questions":[{"id":"q1","text":"<script>alert('xss')</script>Your name?","type":"text","required":false,"options":[]}],"anonymous":false}`
+		result, err := newGenerator(malicious).Generate(ctx, "Create a survey")
+		require.NoError(t, err)
+		assert.NotContains(t, result.Definition.Questions[0].Text, "<script>")
+	})
+}
+
+// fakeOllama is an Ollama server answering /api/chat with responses in
+// order, recording the requests it got
+type fakeOllama struct {
+	mu        sync.Mutex
+	responses []string
+	requests  []map[string]any
+}
+
+func newFakeOllama(t *testing.T, responses ...string) (*fakeOllama, *httptest.Server) {
+	fake := &fakeOllama{responses: responses}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(srv.Close)
+	t.Setenv("OLLAMA_HOST", srv.URL)
+	return fake, srv
+}
+
+func (f *fakeOllama) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/chat" {
+		http.NotFound(w, r)
+		return
+	}
+	var req map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	if len(f.requests) > len(f.responses) {
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"llama3.2\" not found, try pulling it first"}`)
+		return
+	}
+	content := f.responses[len(f.requests)-1]
+	f.mu.Unlock()
+
+	// Ollama streams newline-delimited chunks unless asked not to; the last
+	// one has the eval counts
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	if stream, _ := req["stream"].(bool); stream {
+		for i := 0; i < len(content); i += 16 {
+			_ = enc.Encode(map[string]any{"message": map[string]any{"role": "assistant", "content": content[i:min(i+16, len(content))]}, "done": false})
+		}
+		content = ""
+	}
+	_ = enc.Encode(map[string]any{
+		"model":             req["model"],
+		"message":           map[string]any{"role": "assistant", "content": content},
+		"done":              true,
+		"prompt_eval_count": 420,
+		"eval_count":        85,
+	})
+}
+
+func TestOllamaProvider(t *testing.T) {
+	ctx := context.Background()
+	newGenerator := func(t *testing.T) *SurveyGenerator {
+		t.Helper()
+		t.Setenv("AI_PROVIDER", "ollama")
+		t.Setenv("OLLAMA_MODEL", "")
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+		require.Len(t, providers, 1)
+		return NewSurveyGeneratorWithProvider(providers[0])
+	}
+
+	t.Run("generates a survey for free", func(t *testing.T) {
+		fake, _ := newFakeOllama(t, pizzaPollJSON)
+		gen := newGenerator(t)
+		gen.SetTemperature(0.3)
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		require.NotNil(t, result.Definition)
+		assert.Equal(t, "Do you like pizza?", result.Definition.Questions[0].Text)
+		assert.Equal(t, ProviderOllama, result.Provider)
+		assert.Equal(t, DefaultOllamaModel, result.Model)
+		assert.Equal(t, 420, result.InputTokens, "prompt_eval_count")
+		assert.Equal(t, 85, result.OutputTokens, "eval_count")
+		assert.Zero(t, result.EstimatedCost)
+		assert.Equal(t, OutputValid, result.OutputValidation)
+
+		require.Len(t, fake.requests, 1)
+		req := fake.requests[0]
+		assert.Equal(t, DefaultOllamaModel, req["model"])
+		assert.Equal(t, "json", req["format"])
+		messages := req["messages"].([]any)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "system", messages[0].(map[string]any)["role"])
+		assert.Equal(t, "Create a pizza poll", messages[1].(map[string]any)["content"])
+		options := req["options"].(map[string]any)
+		assert.InDelta(t, 0.3, options["temperature"], 1e-6)
+		assert.EqualValues(t, generationMaxTokens, options["num_predict"])
+	})
+
+	t.Run("uses OLLAMA_MODEL", func(t *testing.T) {
+		fake, _ := newFakeOllama(t, pizzaPollJSON)
+		t.Setenv("AI_PROVIDER", "ollama")
+		t.Setenv("OLLAMA_MODEL", "qwen2.5:7b")
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+
+		result, err := NewSurveyGeneratorWithProvider(providers[0]).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "qwen2.5:7b", result.Model)
+		assert.Equal(t, "qwen2.5:7b", fake.requests[0]["model"])
+	})
+
+	t.Run("repairs messy output", func(t *testing.T) {
+		missingIDs := "```json\n" + `{"questions":[{"text":"Favorite topping?","type":"single","options":[{"text":"Cheese"},{"text":"Basil"}]}]}` + "\n```"
+		newFakeOllama(t, missingIDs)
+
+		result, err := newGenerator(t).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, OutputRepaired, result.OutputValidation)
+		assert.Equal(t, "q1", result.Definition.Questions[0].ID)
+	})
+
+	t.Run("re-prompts invalid output", func(t *testing.T) {
+		textWithOptions := `{"questions":[{"id":"q1","text":"Favorite book?","type":"text","required":false,"options":[{"id":"opt1","text":"Fiction"},{"id":"opt2","text":"Poetry"}]}],"anonymous":false}`
+		fake, _ := newFakeOllama(t, textWithOptions, pizzaPollJSON)
+
+		result, err := newGenerator(t).Generate(ctx, "Create a book survey")
+		require.NoError(t, err)
+		assert.Equal(t, OutputReprompted, result.OutputValidation)
+		assert.Equal(t, 840, result.InputTokens, "both calls are counted")
+		assert.Zero(t, result.EstimatedCost)
+
+		require.Len(t, fake.requests, 2)
+		retry := fake.requests[1]["messages"].([]any)[1].(map[string]any)["content"].(string)
+		assert.Contains(t, retry, "text questions cannot have options")
+	})
+
+	t.Run("streams progress", func(t *testing.T) {
+		newFakeOllama(t, pizzaPollJSON)
+		var progress []QuestionProgress
+		ctx := WithProgress(ctx, func(p QuestionProgress) { progress = append(progress, p) })
+
+		result, err := newGenerator(t).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, pizzaPollJSON, result.RawResponse)
+		assert.Equal(t, []QuestionProgress{{Number: 1, Text: "Do you like pizza?"}}, progress)
+		assert.Equal(t, 85, result.OutputTokens)
+	})
+
+	t.Run("reports server errors", func(t *testing.T) {
+		newFakeOllama(t)
+
+		_, err := newGenerator(t).Generate(ctx, "Create a pizza poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ollama generation failed")
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestProvidersFromEnv(t *testing.T) {
+	clear := func(t *testing.T) {
+		for _, name := range []string{"AI_PROVIDER", "OPENAI_API_KEY", "OPENAI_MODEL", "ANTHROPIC_API_KEY", "ANTHROPIC_MODEL", "OLLAMA_MODEL"} {
+			t.Setenv(name, "")
+		}
+	}
+
+	t.Run("disabled without an API key", func(t *testing.T) {
+		clear(t)
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+		assert.Nil(t, providers)
+
+		t.Setenv("AI_PROVIDER", "anthropic")
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		providers, err = ProvidersFromEnv()
+		require.NoError(t, err)
+		assert.Nil(t, providers, "the OpenAI key doesn't enable Anthropic")
+	})
+
+	t.Run("defaults to OpenAI", func(t *testing.T) {
+		clear(t)
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+		require.Len(t, providers, 1)
+		assert.Equal(t, ProviderOpenAI, providers[0].Name())
+		assert.Equal(t, DefaultOpenAIModel, providers[0].Model())
+	})
+
+	t.Run("selects Anthropic", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "anthropic")
+		t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test")
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+		require.Len(t, providers, 1)
+		assert.Equal(t, ProviderAnthropic, providers[0].Name())
+		assert.Equal(t, DefaultAnthropicModel, providers[0].Model())
+		assert.Equal(t, AnthropicPricing[DefaultAnthropicModel], providers[0].Pricing())
+
+		t.Setenv("ANTHROPIC_MODEL", "claude-sonnet-4-5")
+		providers, err = ProvidersFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, "claude-sonnet-4-5", providers[0].Model())
+	})
+
+	t.Run("selects Ollama without a key", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "ollama,openai")
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+		require.Len(t, providers, 2)
+		assert.Equal(t, ProviderOllama, providers[0].Name())
+		assert.Equal(t, DefaultOllamaModel, providers[0].Model())
+		assert.Equal(t, Pricing{}, providers[0].Pricing())
+	})
+
+	t.Run("builds a fallback chain in order", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "anthropic, openai")
+		t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test")
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		providers, err := ProvidersFromEnv()
+		require.NoError(t, err)
+		require.Len(t, providers, 2)
+		assert.Equal(t, ProviderAnthropic, providers[0].Name())
+		assert.Equal(t, ProviderOpenAI, providers[1].Name())
+	})
+
+	t.Run("rejects a fallback without an API key", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "openai,anthropic")
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		_, err := ProvidersFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "anthropic")
+	})
+
+	t.Run("rejects a provider listed twice", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "openai,openai")
+		t.Setenv("OPENAI_API_KEY", "sk-test")
+		_, err := ProvidersFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "twice")
+	})
+
+	t.Run("rejects a model without pricing", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "anthropic")
+		t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test")
+		t.Setenv("ANTHROPIC_MODEL", "claude-unreleased")
+		_, err := ProvidersFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ANTHROPIC_MODEL")
+	})
+
+	t.Run("rejects an unknown provider", func(t *testing.T) {
+		clear(t)
+		t.Setenv("AI_PROVIDER", "llama")
+		_, err := ProvidersFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "AI_PROVIDER")
+	})
+}
+
+func TestTemperatureFromEnv(t *testing.T) {
+	assert.Equal(t, DefaultTemperature, TemperatureFromEnv())
+
+	t.Setenv("AI_TEMPERATURE", "0.2")
+	assert.Equal(t, 0.2, TemperatureFromEnv())
+
+	t.Setenv("AI_TEMPERATURE", "0")
+	assert.Equal(t, 0.0, TemperatureFromEnv())
+
+	for _, invalid := range []string{"1.5", "-0.1", "warm"} {
+		t.Setenv("AI_TEMPERATURE", invalid)
+		assert.Equal(t, DefaultTemperature, TemperatureFromEnv(), invalid)
+	}
+}
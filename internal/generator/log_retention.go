@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultLogRetention is how long prompts and responses are kept
+	DefaultLogRetention = 90 * 24 * time.Hour
+
+	// LogRetentionInterval is how often the retention worker runs
+	LogRetentionInterval = 24 * time.Hour
+)
+
+// LogRetentionConfig controls how long generation log content is kept
+type LogRetentionConfig struct {
+	Retention        time.Duration // Successful generations
+	FailureRetention time.Duration // Failed generations; never shorter than Retention
+}
+
+// LogRetentionConfigFromEnv creates a retention config from environment variables
+// Environment variables:
+//   - AI_LOG_RETENTION_DAYS: days to keep prompts and responses (default: 90)
+//   - AI_LOG_FAILURE_RETENTION_DAYS: days to keep failed generations (default: AI_LOG_RETENTION_DAYS)
+func LogRetentionConfigFromEnv() LogRetentionConfig {
+	config := LogRetentionConfig{Retention: DefaultLogRetention}
+
+	if v := os.Getenv("AI_LOG_RETENTION_DAYS"); v != "" {
+		if val, err := strconv.Atoi(v); err == nil && val > 0 {
+			config.Retention = time.Duration(val) * 24 * time.Hour
+		}
+	}
+
+	config.FailureRetention = config.Retention
+	if v := os.Getenv("AI_LOG_FAILURE_RETENTION_DAYS"); v != "" {
+		if val, err := strconv.Atoi(v); err == nil && val > 0 {
+			config.FailureRetention = time.Duration(val) * 24 * time.Hour
+		}
+	}
+	if config.FailureRetention < config.Retention {
+		config.FailureRetention = config.Retention
+	}
+
+	return config
+}
+
+// GenerationLogPruner removes prompt and response content from old logs,
+// recording prunes in the audit log
+type GenerationLogPruner interface {
+	PruneGenerationLogs(ctx context.Context, olderThan time.Time, keepFailures bool) (int64, error)
+}
+
+// PruneGenerationLogs applies config as of now and returns the number of logs pruned.
+// Failures get a second, later cutoff when FailureRetention is longer.
+func PruneGenerationLogs(ctx context.Context, pruner GenerationLogPruner, config LogRetentionConfig, now time.Time) (int64, error) {
+	keepFailures := config.FailureRetention > config.Retention
+
+	pruned, err := pruner.PruneGenerationLogs(ctx, now.Add(-config.Retention), keepFailures)
+	if err != nil || !keepFailures {
+		return pruned, err
+	}
+
+	failures, err := pruner.PruneGenerationLogs(ctx, now.Add(-config.FailureRetention), false)
+	return pruned + failures, err
+}
+
+// StartLogRetentionWorker prunes generation logs now and then every interval
+// until ctx is cancelled
+func StartLogRetentionWorker(ctx context.Context, pruner GenerationLogPruner, config LogRetentionConfig, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("AI log retention worker started (retention: %v, failures: %v, interval: %v)",
+		config.Retention, config.FailureRetention, interval)
+
+	runLogRetention(ctx, pruner, config, time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("AI log retention worker stopped")
+			return
+		case <-ticker.C:
+			runLogRetention(ctx, pruner, config, time.Now())
+		}
+	}
+}
+
+// runLogRetention prunes once as of now and logs the result
+func runLogRetention(ctx context.Context, pruner GenerationLogPruner, config LogRetentionConfig, now time.Time) {
+	pruned, err := PruneGenerationLogs(ctx, pruner, config, now)
+	if err != nil {
+		log.Printf("Error pruning AI generation logs: %v", err)
+		return
+	}
+	log.Printf("Pruned %d AI generation logs", pruned)
+}
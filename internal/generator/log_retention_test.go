@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pruneCall records one PruneGenerationLogs call
+type pruneCall struct {
+	olderThan    time.Time
+	keepFailures bool
+}
+
+// MockLogPruner is a mock pruner that returns a fixed count per call
+type MockLogPruner struct {
+	calls []pruneCall
+	count int64
+	err   error
+}
+
+func (m *MockLogPruner) PruneGenerationLogs(ctx context.Context, olderThan time.Time, keepFailures bool) (int64, error) {
+	m.calls = append(m.calls, pruneCall{olderThan: olderThan, keepFailures: keepFailures})
+	return m.count, m.err
+}
+
+func TestLogRetentionConfigFromEnv(t *testing.T) {
+	t.Run("uses defaults when env vars not set", func(t *testing.T) {
+		config := LogRetentionConfigFromEnv()
+		assert.Equal(t, DefaultLogRetention, config.Retention)
+		assert.Equal(t, DefaultLogRetention, config.FailureRetention)
+	})
+
+	t.Run("reads from env vars", func(t *testing.T) {
+		t.Setenv("AI_LOG_RETENTION_DAYS", "30")
+		t.Setenv("AI_LOG_FAILURE_RETENTION_DAYS", "180")
+
+		config := LogRetentionConfigFromEnv()
+		assert.Equal(t, 30*24*time.Hour, config.Retention)
+		assert.Equal(t, 180*24*time.Hour, config.FailureRetention)
+	})
+
+	t.Run("failure retention follows retention by default", func(t *testing.T) {
+		t.Setenv("AI_LOG_RETENTION_DAYS", "14")
+
+		config := LogRetentionConfigFromEnv()
+		assert.Equal(t, 14*24*time.Hour, config.FailureRetention)
+	})
+
+	t.Run("failure retention is never shorter than retention", func(t *testing.T) {
+		t.Setenv("AI_LOG_RETENTION_DAYS", "60")
+		t.Setenv("AI_LOG_FAILURE_RETENTION_DAYS", "7")
+
+		config := LogRetentionConfigFromEnv()
+		assert.Equal(t, 60*24*time.Hour, config.FailureRetention)
+	})
+
+	t.Run("ignores invalid values", func(t *testing.T) {
+		t.Setenv("AI_LOG_RETENTION_DAYS", "forever")
+		t.Setenv("AI_LOG_FAILURE_RETENTION_DAYS", "-1")
+
+		config := LogRetentionConfigFromEnv()
+		assert.Equal(t, DefaultLogRetention, config.Retention)
+		assert.Equal(t, DefaultLogRetention, config.FailureRetention)
+	})
+}
+
+func TestPruneGenerationLogs(t *testing.T) {
+	now := time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Run("single pass when failures share the retention", func(t *testing.T) {
+		pruner := &MockLogPruner{count: 4}
+		config := LogRetentionConfig{Retention: 90 * 24 * time.Hour, FailureRetention: 90 * 24 * time.Hour}
+
+		pruned, err := PruneGenerationLogs(ctx, pruner, config, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), pruned)
+		require.Len(t, pruner.calls, 1)
+		assert.Equal(t, now.Add(-90*24*time.Hour), pruner.calls[0].olderThan)
+		assert.False(t, pruner.calls[0].keepFailures)
+	})
+
+	t.Run("failures pruned at their own cutoff", func(t *testing.T) {
+		pruner := &MockLogPruner{count: 3}
+		config := LogRetentionConfig{Retention: 30 * 24 * time.Hour, FailureRetention: 180 * 24 * time.Hour}
+
+		pruned, err := PruneGenerationLogs(ctx, pruner, config, now)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), pruned)
+		require.Len(t, pruner.calls, 2)
+		assert.Equal(t, pruneCall{now.Add(-30 * 24 * time.Hour), true}, pruner.calls[0])
+		assert.Equal(t, pruneCall{now.Add(-180 * 24 * time.Hour), false}, pruner.calls[1])
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		pruner := &MockLogPruner{err: errors.New("db down")}
+		config := LogRetentionConfig{Retention: 30 * 24 * time.Hour, FailureRetention: 180 * 24 * time.Hour}
+
+		_, err := PruneGenerationLogs(ctx, pruner, config, now)
+		assert.Error(t, err)
+		assert.Len(t, pruner.calls, 1)
+	})
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pizzaSurvey is a two question survey to modify
+func pizzaSurvey() *models.SurveyDefinition {
+	return &models.SurveyDefinition{
+		Questions: []models.Question{
+			{ID: "q1", Text: "Do you like pizza?", Type: models.QuestionTypeSingle, Options: []models.Option{
+				{ID: "opt1", Text: "Yes"},
+				{ID: "opt2", Text: "No"},
+			}},
+			{ID: "q2", Text: "Any comments?", Type: models.QuestionTypeText},
+		},
+	}
+}
+
+func TestDiffDefinitions(t *testing.T) {
+	t.Run("identical surveys", func(t *testing.T) {
+		diff := DiffDefinitions(pizzaSurvey(), pizzaSurvey())
+		assert.Equal(t, DefinitionDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}, diff)
+	})
+
+	t.Run("added, removed and changed questions", func(t *testing.T) {
+		modified := pizzaSurvey()
+		modified.Questions[0].Text = "Do you love pizza?"
+		modified.Questions = append(modified.Questions[:1],
+			models.Question{ID: "q3", Text: "Favorite topping?", Type: models.QuestionTypeText})
+
+		diff := DiffDefinitions(pizzaSurvey(), modified)
+		assert.Equal(t, []string{"q3"}, diff.Added)
+		assert.Equal(t, []string{"q2"}, diff.Removed)
+		assert.Equal(t, []string{"q1"}, diff.Changed)
+	})
+
+	t.Run("reordering isn't a change", func(t *testing.T) {
+		modified := pizzaSurvey()
+		modified.Questions[0], modified.Questions[1] = modified.Questions[1], modified.Questions[0]
+
+		diff := DiffDefinitions(pizzaSurvey(), modified)
+		assert.Empty(t, diff.Added)
+		assert.Empty(t, diff.Removed)
+		assert.Empty(t, diff.Changed)
+	})
+
+	changes := map[string]func(q *models.Question){
+		"type":           func(q *models.Question) { q.Type = models.QuestionTypeMulti },
+		"required":       func(q *models.Question) { q.Required = true },
+		"max selections": func(q *models.Question) { q.MaxSelections = 1 },
+		"option text":    func(q *models.Question) { q.Options[1].Text = "Not really" },
+		"option order":   func(q *models.Question) { q.Options[0], q.Options[1] = q.Options[1], q.Options[0] },
+		"added option": func(q *models.Question) {
+			q.Options = append(q.Options, models.Option{ID: "opt3", Text: "Sometimes"})
+		},
+	}
+	for name, change := range changes {
+		t.Run("changed "+name, func(t *testing.T) {
+			modified := pizzaSurvey()
+			change(&modified.Questions[0])
+
+			diff := DiffDefinitions(pizzaSurvey(), modified)
+			assert.Equal(t, []string{"q1"}, diff.Changed)
+			assert.Empty(t, diff.Added)
+			assert.Empty(t, diff.Removed)
+		})
+	}
+
+	t.Run("changed rating scale", func(t *testing.T) {
+		old := &models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Rate it", Type: models.QuestionTypeRating, Min: 1, Max: 5},
+		}}
+		modified := &models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Rate it", Type: models.QuestionTypeRating, Min: 1, Max: 10},
+		}}
+		assert.Equal(t, []string{"q1"}, DiffDefinitions(old, modified).Changed)
+	})
+}
+
+func TestPreserveQuestionIDs(t *testing.T) {
+	t.Run("restores IDs of copied questions and options", func(t *testing.T) {
+		modified := &models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q5", Text: "Favorite topping?", Type: models.QuestionTypeText},
+			{ID: "q6", Text: "Do you  like PIZZA?", Type: models.QuestionTypeSingle, Options: []models.Option{
+				{ID: "a", Text: "yes"},
+				{ID: "b", Text: "No"},
+				{ID: "c", Text: "Sometimes"},
+			}},
+		}}
+
+		preserveQuestionIDs(pizzaSurvey(), modified)
+		assert.Equal(t, "q5", modified.Questions[0].ID, "new questions keep their IDs")
+		assert.Equal(t, "q1", modified.Questions[1].ID)
+		assert.Equal(t, []models.Option{
+			{ID: "opt1", Text: "yes"},
+			{ID: "opt2", Text: "No"},
+			{ID: "c", Text: "Sometimes"},
+		}, modified.Questions[1].Options)
+	})
+
+	t.Run("takes an ID back from a new question", func(t *testing.T) {
+		modified := &models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Favorite topping?", Type: models.QuestionTypeText},
+			{ID: "q9", Text: "Do you like pizza?", Type: models.QuestionTypeText},
+			{ID: "q2", Text: "Anything else?", Type: models.QuestionTypeText},
+		}}
+
+		preserveQuestionIDs(pizzaSurvey(), modified)
+		assert.Equal(t, "q3", modified.Questions[0].ID, "not an ID the old survey used")
+		assert.Equal(t, "q1", modified.Questions[1].ID)
+		assert.Equal(t, "q2", modified.Questions[2].ID, "a reworded question keeps its ID")
+	})
+
+	t.Run("restores option IDs of kept questions", func(t *testing.T) {
+		modified := pizzaSurvey()
+		modified.Questions[0].Options = []models.Option{{ID: "o2", Text: "No"}, {ID: "o1", Text: "Yes"}}
+
+		preserveQuestionIDs(pizzaSurvey(), modified)
+		assert.Equal(t, []models.Option{{ID: "opt2", Text: "No"}, {ID: "opt1", Text: "Yes"}}, modified.Questions[0].Options)
+	})
+}
+
+func TestBuildModifyPrompt(t *testing.T) {
+	prompt, err := buildModifyPrompt(pizzaSurvey(), "Add a question about toppings")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "<current_survey>\n{\n  \"questions\": [")
+	assert.Contains(t, prompt, `"text": "Any comments?"`)
+	assert.Contains(t, prompt, "<modification>\nAdd a question about toppings\n</modification>")
+	assert.Contains(t, prompt, "complete modified survey definition")
+}
+
+func TestSurveyGenerator_Modify(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the modified survey and its diff", func(t *testing.T) {
+		// The model renumbered the kept question and dropped the comments one
+		output := `{"questions":[
+			{"id":"q1","text":"What's your favorite topping?","type":"text","required":false},
+			{"id":"q2","text":"Do you like pizza?","type":"single","required":false,"options":[{"id":"opt1","text":"Yes"},{"id":"opt2","text":"No"}]}
+		],"anonymous":false}`
+		gen := NewSurveyGeneratorWithProvider(answering(ProviderOpenAI, output))
+
+		result, err := gen.Modify(ctx, pizzaSurvey(), "Add a topping question first and drop the comments")
+		require.NoError(t, err)
+		require.Len(t, result.Definition.Questions, 2)
+		assert.Equal(t, "q3", result.Definition.Questions[0].ID, "a new question can't take a kept question's ID")
+		assert.Equal(t, "q1", result.Definition.Questions[1].ID)
+		require.NotNil(t, result.Diff)
+		assert.Equal(t, []string{"q3"}, result.Diff.Added)
+		assert.Equal(t, []string{"q2"}, result.Diff.Removed)
+		assert.Empty(t, result.Diff.Changed)
+	})
+
+	t.Run("validates the instruction", func(t *testing.T) {
+		provider := answering(ProviderOpenAI, pizzaPollJSON)
+		gen := NewSurveyGeneratorWithProvider(provider)
+
+		_, err := gen.Modify(ctx, pizzaSurvey(), "   ")
+		require.ErrorIs(t, err, ErrEmptyInput)
+		assert.Equal(t, 0, provider.calls)
+	})
+}
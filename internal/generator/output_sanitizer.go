@@ -1,9 +1,21 @@
 package generator
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/openmeet-team/survey/internal/models"
 )
 
+// Output validation outcomes, logged with each generation
+const (
+	OutputValid      = "valid"      // The model's first response was valid
+	OutputRepaired   = "repaired"   // Repair fixed the first response
+	OutputReprompted = "reprompted" // The model fixed its response when re-prompted
+	OutputInvalid    = "invalid"    // The response was still invalid after re-prompting
+)
+
 // OutputSanitizer sanitizes and validates LLM output
 type OutputSanitizer struct{}
 
@@ -27,3 +39,146 @@ func (s *OutputSanitizer) Sanitize(llmOutput string) (*models.SurveyDefinition,
 
 	return def, nil
 }
+
+// OutputCheck is the result of checking a model's survey JSON
+type OutputCheck struct {
+	Definition *models.SurveyDefinition // The sanitized survey, after any repair; nil if invalid
+	Issues     []string                 // Problems with the output as the model wrote it
+	Repaired   bool                     // Repair fixed every issue
+	Err        error                    // Why Definition is nil
+}
+
+// Check parses a model's survey JSON and validates it with validateOutput,
+// which is stricter than ValidateDefinition, then ValidateDefinition.
+// Missing or duplicate IDs and empty options are repaired; any other issue
+// leaves Definition nil.
+func (s *OutputSanitizer) Check(llmOutput string) OutputCheck {
+	def, err := models.ParseSurveyDefinition([]byte(llmOutput))
+	if err != nil {
+		return OutputCheck{Issues: []string{err.Error()}, Err: err}
+	}
+
+	check := OutputCheck{Issues: validateOutput(def)}
+	if len(check.Issues) > 0 {
+		repairOutput(def)
+		if remaining := validateOutput(def); len(remaining) > 0 {
+			check.Err = errors.New(strings.Join(remaining, "; "))
+			return check
+		}
+		check.Repaired = true
+	}
+
+	// Length limits, rating scales and text sanitization
+	if err := def.ValidateDefinition(); err != nil {
+		check.Issues = append(check.Issues, err.Error())
+		check.Repaired = false
+		check.Err = err
+		return check
+	}
+
+	check.Definition = def
+	return check
+}
+
+// validateOutput lists every way def breaks the rules generated surveys must
+// follow: question and option IDs present and unique, a known question type,
+// at least 2 options on choice questions and none on text questions, and no
+// empty options
+func validateOutput(def *models.SurveyDefinition) []string {
+	var issues []string
+	if len(def.Questions) == 0 {
+		issues = append(issues, "survey must have at least one question")
+	}
+
+	questionIDs := make(map[string]bool)
+	for i, q := range def.Questions {
+		if q.ID == "" {
+			issues = append(issues, fmt.Sprintf("question %d: question ID is required", i))
+		} else if questionIDs[q.ID] {
+			issues = append(issues, fmt.Sprintf("question %d: duplicate question ID '%s'", i, q.ID))
+		}
+		questionIDs[q.ID] = true
+
+		switch q.Type {
+		case models.QuestionTypeSingle, models.QuestionTypeMulti:
+			if len(q.Options) < 2 {
+				issues = append(issues, fmt.Sprintf("question %d: choice questions must have at least 2 options", i))
+			}
+		case models.QuestionTypeText:
+			if len(q.Options) > 0 {
+				issues = append(issues, fmt.Sprintf("question %d: text questions cannot have options", i))
+			}
+		case models.QuestionTypeRating:
+		default:
+			issues = append(issues, fmt.Sprintf("question %d: invalid question type '%s'", i, q.Type))
+		}
+
+		optionIDs := make(map[string]bool)
+		for j, opt := range q.Options {
+			if strings.TrimSpace(opt.Text) == "" {
+				issues = append(issues, fmt.Sprintf("question %d, option %d: option text is required", i, j))
+			}
+			if opt.ID == "" {
+				issues = append(issues, fmt.Sprintf("question %d, option %d: option ID is required", i, j))
+			} else if optionIDs[opt.ID] {
+				issues = append(issues, fmt.Sprintf("question %d: duplicate option ID '%s'", i, opt.ID))
+			}
+			optionIDs[opt.ID] = true
+		}
+	}
+	return issues
+}
+
+// repairOutput fixes what validateOutput finds without guessing at the
+// model's intent: it drops options with no text and gives missing or
+// duplicate question and option IDs new ones (q1, q2... and opt1, opt2...)
+func repairOutput(def *models.SurveyDefinition) {
+	questionIDs := make(map[string]bool)
+	for _, q := range def.Questions {
+		questionIDs[q.ID] = true
+	}
+	seen := make(map[string]bool)
+	for i := range def.Questions {
+		q := &def.Questions[i]
+		if q.ID == "" || seen[q.ID] {
+			q.ID = freeID("q", i+1, questionIDs)
+		}
+		seen[q.ID] = true
+
+		options := q.Options[:0]
+		for _, opt := range q.Options {
+			if strings.TrimSpace(opt.Text) != "" {
+				options = append(options, opt)
+			}
+		}
+		if len(options) == 0 {
+			options = nil
+		}
+		q.Options = options
+
+		optionIDs := make(map[string]bool)
+		for _, opt := range q.Options {
+			optionIDs[opt.ID] = true
+		}
+		seenOptions := make(map[string]bool)
+		for j := range q.Options {
+			opt := &q.Options[j]
+			if opt.ID == "" || seenOptions[opt.ID] {
+				opt.ID = freeID("opt", j+1, optionIDs)
+			}
+			seenOptions[opt.ID] = true
+		}
+	}
+}
+
+// freeID returns the first prefix+n, counting up from n, that isn't in
+// taken, and takes it
+func freeID(prefix string, n int, taken map[string]bool) string {
+	for ; ; n++ {
+		id := fmt.Sprintf("%s%d", prefix, n)
+		if !taken[id] {
+			taken[id] = true
+			return id
+		}
+	}
+}
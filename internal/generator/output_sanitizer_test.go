@@ -2,6 +2,8 @@ package generator
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/openmeet-team/survey/internal/models"
@@ -190,3 +192,99 @@ func TestOutputSanitizer_Sanitize(t *testing.T) {
 		assert.Equal(t, models.QuestionTypeMulti, def.Questions[2].Type)
 	})
 }
+
+// badOutputs are model responses kept in testdata/bad_outputs, and whether
+// Check repairs them. Responses it can't repair make the generator re-prompt.
+var badOutputs = []struct {
+	file     string
+	repaired bool
+}{
+	{"missing_option_ids.json", true},
+	{"missing_question_id.json", true},
+	{"duplicate_question_ids.json", true},
+	{"duplicate_option_ids.json", true},
+	{"empty_options.json", true},
+	{"text_with_empty_options.json", true},
+	{"unknown_type.json", false},
+	{"choice_with_one_option.json", false},
+	{"choice_without_options.json", false},
+	{"empty_options_leave_one.json", false},
+	{"text_with_options.json", false},
+	{"rating_without_scale.json", false},
+	{"no_questions.json", false},
+	{"markdown_fence.json", false},
+	{"truncated.json", false},
+}
+
+func TestOutputSanitizer_Check(t *testing.T) {
+	sanitizer := NewOutputSanitizer()
+
+	t.Run("valid output", func(t *testing.T) {
+		check := sanitizer.Check(pizzaPollJSON)
+		require.NotNil(t, check.Definition)
+		assert.Empty(t, check.Issues)
+		assert.False(t, check.Repaired)
+		assert.NoError(t, check.Err)
+	})
+
+	files, err := filepath.Glob(filepath.Join("testdata", "bad_outputs", "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, len(badOutputs), "every fixture should be in badOutputs")
+
+	for _, tt := range badOutputs {
+		t.Run(tt.file, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", "bad_outputs", tt.file))
+			require.NoError(t, err)
+
+			check := sanitizer.Check(string(output))
+			assert.NotEmpty(t, check.Issues)
+			assert.Equal(t, tt.repaired, check.Repaired)
+			if !tt.repaired {
+				assert.Nil(t, check.Definition)
+				assert.Error(t, check.Err)
+				return
+			}
+
+			require.NotNil(t, check.Definition)
+			assert.Empty(t, validateOutput(check.Definition))
+			assert.NoError(t, check.Definition.ValidateDefinition())
+		})
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	def := &models.SurveyDefinition{Questions: []models.Question{
+		{ID: "q1", Text: "Pick", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "opt1", Text: "A"}}},
+		{ID: "q1", Text: "Say", Type: models.QuestionTypeText, Options: []models.Option{{ID: "opt1", Text: "A"}}},
+		{Text: "Choose", Type: "dropdown", Options: []models.Option{{Text: "A"}, {ID: "x", Text: " "}}},
+	}}
+
+	assert.Equal(t, []string{
+		"question 0: choice questions must have at least 2 options",
+		"question 1: duplicate question ID 'q1'",
+		"question 1: text questions cannot have options",
+		"question 2: question ID is required",
+		"question 2: invalid question type 'dropdown'",
+		"question 2, option 0: option ID is required",
+		"question 2, option 1: option text is required",
+	}, validateOutput(def))
+}
+
+func TestRepairOutput(t *testing.T) {
+	def := &models.SurveyDefinition{Questions: []models.Question{
+		{ID: "q2", Text: "First", Type: models.QuestionTypeText},
+		{ID: "q2", Text: "Second", Type: models.QuestionTypeMulti, Options: []models.Option{
+			{ID: "opt2", Text: "A"}, {Text: "B"}, {ID: "opt9", Text: ""}, {ID: "opt2", Text: "C"},
+		}},
+		{Text: "Third", Type: models.QuestionTypeText},
+	}}
+
+	repairOutput(def)
+
+	// New IDs follow the position, skipping ones already taken
+	assert.Equal(t, "q2", def.Questions[0].ID)
+	assert.Equal(t, "q3", def.Questions[1].ID)
+	assert.Equal(t, "q4", def.Questions[2].ID)
+	assert.Equal(t, []models.Option{{ID: "opt2", Text: "A"}, {ID: "opt3", Text: "B"}, {ID: "opt4", Text: "C"}}, def.Questions[1].Options)
+	assert.Empty(t, validateOutput(def))
+}
@@ -0,0 +1,185 @@
+package generator
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// DefaultPromptVersion is the system prompt used when AI_PROMPT_VERSION is
+// unset or names a version that doesn't exist. Translations and summaries
+// have prompts of their own, which are recorded under this version too.
+const DefaultPromptVersion = "v1"
+
+// promptFiles holds the survey generation system prompts, one
+// text/template file per version: prompts/<version>.tmpl
+//
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+// promptVersionPattern is what a version may look like, so a version from
+// the environment can't name a path outside prompts/
+var promptVersionPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// PromptData is what system prompt templates are rendered with
+type PromptData struct {
+	MaxQuestions      int
+	MaxOptions        int
+	MaxQuestionLength int // Kept shorter than the survey limit, for readable polls
+	MaxOptionLength   int
+	MaxRating         int
+}
+
+// defaultPromptData asks for surveys well within the limits the survey
+// definition is validated against
+var defaultPromptData = PromptData{
+	MaxQuestions:      models.MaxQuestions,
+	MaxOptions:        models.MaxOptionsPerQuestion,
+	MaxQuestionLength: 300,
+	MaxOptionLength:   150,
+	MaxRating:         models.MaxRatingValue,
+}
+
+// versionedPrompt is a system prompt and the version it's logged under
+type versionedPrompt struct {
+	Version string
+	Text    string
+}
+
+// LoadSystemPrompt renders the system prompt template for version
+func LoadSystemPrompt(version string) (string, error) {
+	return loadSystemPrompt(promptFiles, version)
+}
+
+func loadSystemPrompt(fsys fs.FS, version string) (string, error) {
+	if !promptVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("invalid prompt version %q", version)
+	}
+	src, err := fs.ReadFile(fsys, "prompts/"+version+".tmpl")
+	if err != nil {
+		return "", fmt.Errorf("prompt version %q: %w", version, err)
+	}
+	tmpl, err := template.New(version).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return "", fmt.Errorf("prompt version %q: %w", version, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, defaultPromptData); err != nil {
+		return "", fmt.Errorf("prompt version %q: %w", version, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// PromptConfig selects the system prompt version, optionally splitting
+// requests between two versions
+type PromptConfig struct {
+	Version string // Version most requests get
+	// AltVersion gets AltPercent of requests, from 0 to 100; no split when
+	// either is unset
+	AltVersion string
+	AltPercent int
+}
+
+// PromptConfigFromEnv reads AI_PROMPT_VERSION (default DefaultPromptVersion),
+// and AI_PROMPT_VERSION_B and AI_PROMPT_B_PERCENT for an A/B split
+func PromptConfigFromEnv() PromptConfig {
+	config := PromptConfig{
+		Version:    strings.TrimSpace(os.Getenv("AI_PROMPT_VERSION")),
+		AltVersion: strings.TrimSpace(os.Getenv("AI_PROMPT_VERSION_B")),
+	}
+	if config.Version == "" {
+		config.Version = DefaultPromptVersion
+	}
+	if v := os.Getenv("AI_PROMPT_B_PERCENT"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil && p >= 0 && p <= 100 {
+			config.AltPercent = p
+		}
+	}
+	return config
+}
+
+// PromptShare is a prompt version and the percentage of requests it gets
+type PromptShare struct {
+	Version string
+	Percent int
+}
+
+// PromptSelector picks the system prompt for each generation. Prompts are
+// rendered once, when the selector is created.
+type PromptSelector struct {
+	primary    versionedPrompt
+	alt        versionedPrompt
+	altPercent int
+	roll       func() int // 0 to 99
+}
+
+// NewPromptSelector loads the prompt versions config names. A version that
+// doesn't exist is logged and replaced: the primary by DefaultPromptVersion,
+// the alternative by no split.
+func NewPromptSelector(config PromptConfig) *PromptSelector {
+	return newPromptSelector(promptFiles, config)
+}
+
+func newPromptSelector(fsys fs.FS, config PromptConfig) *PromptSelector {
+	s := &PromptSelector{roll: func() int { return rand.IntN(100) }}
+
+	text, err := loadSystemPrompt(fsys, config.Version)
+	if err != nil {
+		log.Printf("Warning: %v, using prompt version %s", err, DefaultPromptVersion)
+		config.Version = DefaultPromptVersion
+		if text, err = loadSystemPrompt(fsys, DefaultPromptVersion); err != nil {
+			panic(fmt.Sprintf("default system prompt: %v", err))
+		}
+	}
+	s.primary = versionedPrompt{Version: config.Version, Text: text}
+
+	if config.AltVersion == "" || config.AltVersion == config.Version || config.AltPercent <= 0 {
+		return s
+	}
+	text, err = loadSystemPrompt(fsys, config.AltVersion)
+	if err != nil {
+		log.Printf("Warning: %v, not splitting prompt versions", err)
+		return s
+	}
+	s.alt = versionedPrompt{Version: config.AltVersion, Text: text}
+	s.altPercent = min(config.AltPercent, 100)
+	return s
+}
+
+// defaultPrompts serves every generator that isn't given a selector
+var defaultPrompts = NewPromptSelector(PromptConfig{Version: DefaultPromptVersion})
+
+// pick picks the prompt for one generation
+func (s *PromptSelector) pick() versionedPrompt {
+	if s.altPercent > 0 && s.roll() < s.altPercent {
+		return s.alt
+	}
+	return s.primary
+}
+
+// Version returns the primary version, which requests outside the split get
+func (s *PromptSelector) Version() string {
+	return s.primary.Version
+}
+
+// Active lists the versions in use and the percentage of requests each gets
+func (s *PromptSelector) Active() []PromptShare {
+	if s.altPercent == 0 {
+		return []PromptShare{{Version: s.primary.Version, Percent: 100}}
+	}
+	shares := []PromptShare{{Version: s.alt.Version, Percent: s.altPercent}}
+	if s.altPercent < 100 {
+		shares = append([]PromptShare{{Version: s.primary.Version, Percent: 100 - s.altPercent}}, shares...)
+	}
+	return shares
+}
@@ -0,0 +1,136 @@
+package generator
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPromptFiles has the embedded default and an experimental version
+var testPromptFiles = fstest.MapFS{
+	"prompts/v1.tmpl":       {Data: []byte("Default prompt, up to {{.MaxQuestions}} questions\n")},
+	"prompts/v2-terse.tmpl": {Data: []byte("Terse prompt, {{.MaxOptions}} options at most")},
+	"prompts/broken.tmpl":   {Data: []byte("Unknown {{.NoSuchField}}")},
+}
+
+func TestLoadSystemPrompt(t *testing.T) {
+	t.Run("renders the embedded default", func(t *testing.T) {
+		prompt, err := LoadSystemPrompt(DefaultPromptVersion)
+		require.NoError(t, err)
+		assert.Contains(t, prompt, "Maximum 50 questions per survey")
+		assert.Contains(t, prompt, "provide 2-20 options")
+		assert.Contains(t, prompt, `set "min" and "max" (0-10`)
+		assert.NotContains(t, prompt, "{{")
+	})
+
+	t.Run("substitutes template data", func(t *testing.T) {
+		prompt, err := loadSystemPrompt(testPromptFiles, "v2-terse")
+		require.NoError(t, err)
+		assert.Equal(t, "Terse prompt, 20 options at most", prompt)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, version := range []string{"v9", "", "../prompts/v1", "broken"} {
+			_, err := loadSystemPrompt(testPromptFiles, version)
+			assert.Error(t, err, version)
+		}
+	})
+}
+
+func TestPromptSelector(t *testing.T) {
+	t.Run("uses the configured version", func(t *testing.T) {
+		s := newPromptSelector(testPromptFiles, PromptConfig{Version: "v2-terse"})
+		assert.Equal(t, "v2-terse", s.pick().Version)
+		assert.Equal(t, "Terse prompt, 20 options at most", s.pick().Text)
+		assert.Equal(t, []PromptShare{{Version: "v2-terse", Percent: 100}}, s.Active())
+	})
+
+	t.Run("falls back to the default for a missing version", func(t *testing.T) {
+		for _, version := range []string{"v9", "broken"} {
+			s := newPromptSelector(testPromptFiles, PromptConfig{Version: version})
+			assert.Equal(t, DefaultPromptVersion, s.Version())
+			assert.Equal(t, "Default prompt, up to 50 questions", s.pick().Text)
+		}
+	})
+
+	t.Run("drops a split to a missing version", func(t *testing.T) {
+		s := newPromptSelector(testPromptFiles, PromptConfig{Version: "v1", AltVersion: "v9", AltPercent: 50})
+		assert.Equal(t, []PromptShare{{Version: "v1", Percent: 100}}, s.Active())
+	})
+
+	t.Run("splits by percentage", func(t *testing.T) {
+		s := newPromptSelector(testPromptFiles, PromptConfig{Version: "v1", AltVersion: "v2-terse", AltPercent: 25})
+		assert.Equal(t, []PromptShare{{Version: "v1", Percent: 75}, {Version: "v2-terse", Percent: 25}}, s.Active())
+
+		counts := map[string]int{}
+		for roll := 0; roll < 100; roll++ {
+			s.roll = func() int { return roll }
+			counts[s.pick().Version]++
+		}
+		assert.Equal(t, map[string]int{"v1": 75, "v2-terse": 25}, counts)
+	})
+
+	t.Run("sends everyone to the alternative at 100%", func(t *testing.T) {
+		s := newPromptSelector(testPromptFiles, PromptConfig{Version: "v1", AltVersion: "v2-terse", AltPercent: 100})
+		s.roll = func() int { return 99 }
+		assert.Equal(t, "v2-terse", s.pick().Version)
+		assert.Equal(t, []PromptShare{{Version: "v2-terse", Percent: 100}}, s.Active())
+	})
+
+	t.Run("no split at 0% or to the same version", func(t *testing.T) {
+		for _, config := range []PromptConfig{
+			{Version: "v1", AltVersion: "v2-terse"},
+			{Version: "v1", AltVersion: "v1", AltPercent: 50},
+		} {
+			s := newPromptSelector(testPromptFiles, config)
+			s.roll = func() int { return 0 }
+			assert.Equal(t, "v1", s.pick().Version)
+			assert.Len(t, s.Active(), 1)
+		}
+	})
+
+	t.Run("generations record the version they used", func(t *testing.T) {
+		s := newPromptSelector(testPromptFiles, PromptConfig{Version: "v1", AltVersion: "v2-terse", AltPercent: 50})
+		provider := &respondingInOrder{responses: []string{pizzaPollJSON, pizzaPollJSON}}
+		gen := NewSurveyGeneratorWithProvider(provider)
+		gen.SetPrompts(s)
+
+		s.roll = func() int { return 10 }
+		result, err := gen.Generate(context.Background(), "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "v2-terse", result.PromptVersion)
+		assert.Equal(t, "Terse prompt, 20 options at most", result.SystemPrompt)
+
+		s.roll = func() int { return 60 }
+		result, err = gen.Generate(context.Background(), "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", result.PromptVersion)
+	})
+}
+
+func TestPromptConfigFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		t.Setenv("AI_PROMPT_VERSION", "")
+		t.Setenv("AI_PROMPT_VERSION_B", "")
+		t.Setenv("AI_PROMPT_B_PERCENT", "")
+		assert.Equal(t, PromptConfig{Version: DefaultPromptVersion}, PromptConfigFromEnv())
+	})
+
+	t.Run("reads a split", func(t *testing.T) {
+		t.Setenv("AI_PROMPT_VERSION", "v1")
+		t.Setenv("AI_PROMPT_VERSION_B", "v2")
+		t.Setenv("AI_PROMPT_B_PERCENT", "10")
+		assert.Equal(t, PromptConfig{Version: "v1", AltVersion: "v2", AltPercent: 10}, PromptConfigFromEnv())
+	})
+
+	t.Run("ignores invalid percentages", func(t *testing.T) {
+		t.Setenv("AI_PROMPT_VERSION_B", "v2")
+		for _, v := range []string{"-1", "101", "half"} {
+			t.Setenv("AI_PROMPT_B_PERCENT", v)
+			assert.Zero(t, PromptConfigFromEnv().AltPercent, v)
+		}
+	})
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/openmeet-team/survey/internal/models"
 	"github.com/tmc/langchaingo/llms"
@@ -29,176 +32,365 @@ type GenerateResult struct {
 	EstimatedCost float64
 	SystemPrompt  string // The system prompt sent to the LLM
 	RawResponse   string // The raw LLM response before sanitization
+	Provider      string // Provider that served the request, e.g. "openai"
+	Model         string // Model that served the request
+	FallbackFrom  string // Provider that failed before Provider served the request; empty without fallback
+	Attempts      int    // Requests sent to Provider, counting retries of transient errors and a re-prompt
+	Temperature   float64
+	PromptVersion string // Version of the system prompt sent
+
+	// OutputValidation is how the survey JSON passed validation: one of the
+	// Output constants. OutputIssues lists the problems with the model's
+	// first response, if any.
+	OutputValidation string
+	OutputIssues     []string
+
+	// Diff is how Definition differs from the survey Modify modified; nil
+	// for new surveys
+	Diff *DefinitionDiff
+
+	// Summary is what Summarize returned, for which Definition is nil
+	Summary *models.TextSummary
+
+	// EstimatedInputTokens is the input tokens counted before the request;
+	// InputTokens is what the provider reported
+	EstimatedInputTokens int
+
+	// FailedAttempts lists the provider calls that failed, in chain order,
+	// when the request fell back or a failed call had been retried. If the
+	// request failed as well, the last attempt is that failure. Empty when
+	// the first provider answered.
+	FailedAttempts []FailedAttempt
 }
 
-// SurveyGenerator generates surveys using an LLM
+// SurveyGenerator generates surveys using an LLM. It tries its providers in
+// order, falling back to the next when one is unavailable (IsFallbackError).
 type SurveyGenerator struct {
-	llm          llms.Model
-	model        string
-	validator    *InputValidator
-	sanitizer    *OutputSanitizer
-	costLimiter  *CostLimiter
+	providers       []Provider
+	providerTimeout time.Duration // per-attempt limit when there's a fallback
+	validator       *InputValidator
+	moderator       Moderator // optional content policy check in ScreenInput
+	sanitizer       *OutputSanitizer
+	costLimiter     *CostLimiter
+	maxInputTokens  int // 0 for no limit
+	temperature     float64
+	prompts         *PromptSelector
 }
 
-// NewSurveyGenerator creates a new survey generator
+// NewSurveyGenerator creates a survey generator calling an OpenAI model on llm
 func NewSurveyGenerator(llm llms.Model, model string) *SurveyGenerator {
+	return NewSurveyGeneratorWithProvider(NewLLMProvider(ProviderOpenAI, model, llm, OpenAIPricing[model]))
+}
+
+// NewSurveyGeneratorWithProvider creates a survey generator backed by
+// provider, falling back to fallbacks in order
+func NewSurveyGeneratorWithProvider(provider Provider, fallbacks ...Provider) *SurveyGenerator {
 	return &SurveyGenerator{
-		llm:         llm,
-		model:       model,
-		validator:   NewInputValidator(),
-		sanitizer:   NewOutputSanitizer(),
-		costLimiter: NewCostLimiter(10.0), // $10/day default
+		providers:       append([]Provider{provider}, fallbacks...),
+		providerTimeout: DefaultProviderTimeout,
+		validator:       NewInputValidator(),
+		sanitizer:       NewOutputSanitizer(),
+		costLimiter:     NewCostLimiter(10.0), // $10/day default
+		maxInputTokens:  DefaultMaxInputTokens,
+		temperature:     DefaultTemperature,
+		prompts:         defaultPrompts,
 	}
 }
 
+// SetProviderTimeout sets how long a provider may take before the request
+// falls back to the next one. The last provider has no limit of its own, and
+// under a request deadline each provider gets at most an even share of what's
+// left of it.
+func (g *SurveyGenerator) SetProviderTimeout(timeout time.Duration) {
+	g.providerTimeout = timeout
+}
+
+// SetMaxInputTokens sets the most input tokens a prompt may take; larger
+// ones return ErrTooManyInputTokens without calling a provider. 0 turns the
+// limit off.
+func (g *SurveyGenerator) SetMaxInputTokens(limit int) {
+	g.maxInputTokens = limit
+}
+
+// SetTemperature sets the sampling temperature sent to providers
+func (g *SurveyGenerator) SetTemperature(temperature float64) {
+	g.temperature = temperature
+}
+
+// SetPrompts sets the selector system prompts are picked from; the
+// version each generation used is returned in its result
+func (g *SurveyGenerator) SetPrompts(prompts *PromptSelector) {
+	g.prompts = prompts
+}
+
+// SetModerator makes ScreenInput also check descriptions with moderator
+func (g *SurveyGenerator) SetModerator(moderator Moderator) {
+	g.moderator = moderator
+}
+
+// Provider returns the name of the primary provider generating surveys
+func (g *SurveyGenerator) Provider() string {
+	return g.providers[0].Name()
+}
+
+// Model returns the primary provider's model
+func (g *SurveyGenerator) Model() string {
+	return g.providers[0].Model()
+}
+
 // ValidateInput validates user input before generation
 // Use this to pre-validate input when building refinement prompts
 func (g *SurveyGenerator) ValidateInput(input string) error {
 	return g.validator.Validate(input)
 }
 
+// ScreenInput screens a user's description before generation: the checks
+// in the ScreenInput function, then the moderator if one is set. Flagged
+// descriptions return a *ScreenFlag. Moderation errors let the description
+// through, since the other checks still apply.
+func (g *SurveyGenerator) ScreenInput(ctx context.Context, input string) error {
+	if flag := ScreenInput(input); flag != nil {
+		return flag
+	}
+	if g.moderator == nil {
+		return nil
+	}
+
+	categories, err := g.moderator.Moderate(ctx, input)
+	if err != nil {
+		log.Printf("Warning: AI input moderation failed, skipping it: %v", err)
+		return nil
+	}
+	if len(categories) > 0 {
+		return &ScreenFlag{Reason: ScreenReasonModeration, Detail: strings.Join(categories, ", ")}
+	}
+	return nil
+}
+
 // Generate creates a survey from a natural language prompt
 func (g *SurveyGenerator) Generate(ctx context.Context, prompt string) (*GenerateResult, error) {
 	// Validate input first
 	if err := g.validator.Validate(prompt); err != nil {
 		return nil, fmt.Errorf("invalid input: %w", err)
 	}
-	return g.generateInternal(ctx, prompt)
+	return g.generateInternal(ctx, g.buildSystemPrompt(), prompt)
 }
 
 // GenerateRaw creates a survey without validating the prompt
 // Use this when the prompt has already been validated or is a refinement prompt
 // containing pre-validated user input combined with trusted existing JSON
 func (g *SurveyGenerator) GenerateRaw(ctx context.Context, prompt string) (*GenerateResult, error) {
-	return g.generateInternal(ctx, prompt)
+	return g.generateInternal(ctx, g.buildSystemPrompt(), prompt)
 }
 
-// generateInternal is the shared implementation for Generate and GenerateRaw
-func (g *SurveyGenerator) generateInternal(ctx context.Context, prompt string) (*GenerateResult, error) {
+// generateInternal is the shared implementation for Generate, GenerateRaw,
+// Modify and Translate
+func (g *SurveyGenerator) generateInternal(ctx context.Context, system versionedPrompt, prompt string) (*GenerateResult, error) {
 	// Check context first
 	if ctx.Err() != nil {
 		return nil, ErrContextCanceled
 	}
 
+	req := GenerationRequest{SystemPrompt: system.Text, Prompt: prompt, Temperature: g.temperature}
+
+	// Reject oversized prompts before paying for them. The estimate is
+	// returned, so the rejection is logged with it.
+	if estimate := countInputTokens(g.Provider(), req); g.maxInputTokens > 0 && estimate > g.maxInputTokens {
+		return &GenerateResult{
+			SystemPrompt:         system.Text,
+			InputTokens:          estimate,
+			EstimatedInputTokens: estimate,
+			Provider:             g.Provider(),
+			Model:                g.Model(),
+			Temperature:          g.temperature,
+			PromptVersion:        system.Version,
+		}, fmt.Errorf("%w: about %d tokens, limit %d",
+			ErrTooManyInputTokens, estimate, g.maxInputTokens)
+	}
+
+	var (
+		result *GenerateResult
+		err    error
+		failed []FailedAttempt
+	)
+	for i, provider := range g.providers {
+		hasFallback := i < len(g.providers)-1
+		start := time.Now()
+		var requests atomic.Int32
+		result, err = g.attempt(withAttemptCounter(ctx, &requests), provider, req, g.attemptTimeout(ctx, i))
+		attempts := max(int(requests.Load()), 1)
+		if err == nil {
+			result.Attempts = attempts
+			break
+		}
+
+		// Only an unavailable provider falls back; invalid output is a prompt
+		// problem another provider wouldn't fix. A retried failure is
+		// recorded too, so its attempts are logged, unless the caller
+		// stopped it: the result is then logged as stopped.
+		fallback := hasFallback && IsFallbackError(err) && ctx.Err() == nil
+		if fallback || (ctx.Err() == nil && (len(failed) > 0 || attempts > 1)) {
+			failed = append(failed, g.newFailedAttempt(provider, system.Version, result, err, start, attempts))
+		}
+		if !fallback {
+			break
+		}
+	}
+
+	if len(failed) > 0 {
+		if result == nil {
+			result = &GenerateResult{SystemPrompt: system.Text}
+		}
+		result.FailedAttempts = failed
+		if err == nil {
+			result.FallbackFrom = failed[len(failed)-1].Provider
+		}
+	}
+	if result != nil {
+		result.Temperature = g.temperature
+		result.PromptVersion = system.Version
+	}
+	return result, err
+}
+
+// attempt generates a survey with one provider, within timeout if it's not
+// 0 (see attemptTimeout). A provider error returns a nil result, unless it
+// failed the re-prompt: the result then has the first call's usage. Invalid
+// output returns a partial result with the raw response, and a call its
+// context stopped a partial result with the usage known so far.
+func (g *SurveyGenerator) attempt(ctx context.Context, provider Provider, req GenerationRequest, timeout time.Duration) (*GenerateResult, error) {
 	// Estimate cost before making the call
-	systemPrompt := g.buildSystemPrompt()
-	inputTokens := g.estimateTokens(systemPrompt + prompt)
+	inputTokens := countInputTokens(provider.Name(), req)
 	outputTokens := 500 // Conservative estimate for survey JSON
-	estimatedCost := g.costLimiter.EstimateTokenCost(inputTokens, outputTokens)
+	estimatedCost := provider.Pricing().Cost(inputTokens, outputTokens)
 
 	// Check cost limit
 	if !g.costLimiter.AllowRequest(estimatedCost) {
 		return nil, ErrCostLimitExceeded
 	}
 
-	// Build messages
-	messages := []llms.MessageContent{
-		{
-			Role: llms.ChatMessageTypeSystem,
-			Parts: []llms.ContentPart{
-				llms.TextContent{Text: systemPrompt},
-			},
-		},
-		{
-			Role: llms.ChatMessageTypeHuman,
-			Parts: []llms.ContentPart{
-				llms.TextContent{Text: prompt},
-			},
-		},
+	// Stream when the caller wants progress, keeping what has arrived in
+	// case the call is stopped
+	var streamed strings.Builder
+	streamTo := func(progress ProgressFunc) func(string) {
+		streamed.Reset()
+		scanner := newQuestionScanner(progress)
+		return func(chunk string) {
+			streamed.WriteString(chunk)
+			scanner.Write(chunk)
+		}
+	}
+	if progress := progressFrom(ctx); progress != nil {
+		req.OnChunk = streamTo(progress)
 	}
 
 	// Call LLM
-	resp, err := g.llm.GenerateContent(ctx, messages, llms.WithModel(g.model))
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result := &GenerateResult{
+		SystemPrompt: req.SystemPrompt,
+		Provider:     provider.Name(),
+		Model:        provider.Model(),
+
+		EstimatedInputTokens: inputTokens,
+	}
+	resp, err := provider.Generate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("LLM generation failed: %w", err)
+		if ctx.Err() != nil {
+			addStoppedUsage(result, provider.Pricing(), inputTokens, streamed.String())
+			return result, err
+		}
+		return nil, err
 	}
 
-	if len(resp.Choices) == 0 {
+	result.InputTokens = resp.InputTokens
+	result.OutputTokens = resp.OutputTokens
+	result.EstimatedCost = resp.CostUSD
+	result.RawResponse = resp.JSON
+
+	if strings.TrimSpace(resp.JSON) == "" {
 		return nil, ErrEmptyResponse
 	}
 
-	responseText := resp.Choices[0].Content
-	if strings.TrimSpace(responseText) == "" {
-		return nil, ErrEmptyResponse
+	// Validate output the same way whichever provider wrote it, repairing
+	// what can be repaired
+	check := g.sanitizer.Check(resp.JSON)
+	result.OutputIssues = check.Issues
+	switch {
+	case check.Definition != nil && check.Repaired:
+		result.OutputValidation = OutputRepaired
+	case check.Definition != nil:
+		result.OutputValidation = OutputValid
+	default:
+		// Give the model one chance to fix its response
+		retry := req
+		retry.Prompt = repairPrompt(req.Prompt, resp.JSON, check.Issues)
+		if req.OnChunk != nil {
+			retry.OnChunk = streamTo(progressFrom(ctx))
+		}
+		retryTokens := inputTokens + estimateTokens(resp.JSON)
+		if !g.costLimiter.AllowRequest(provider.Pricing().Cost(retryTokens, outputTokens)) {
+			result.OutputValidation = OutputInvalid
+			return result, fmt.Errorf("invalid LLM output: %w", check.Err)
+		}
+		resp, err = provider.Generate(ctx, retry)
+		if err != nil {
+			if ctx.Err() != nil {
+				addStoppedUsage(result, provider.Pricing(), retryTokens, streamed.String())
+			}
+			// The first call was paid for whatever happened to the re-prompt
+			return result, err
+		}
+		result.InputTokens += resp.InputTokens
+		result.OutputTokens += resp.OutputTokens
+		result.EstimatedCost += resp.CostUSD
+		result.RawResponse = resp.JSON
+
+		check = g.sanitizer.Check(resp.JSON)
+		if check.Definition == nil {
+			// Return partial result with raw response for debugging/logging
+			result.OutputValidation = OutputInvalid
+			return result, fmt.Errorf("invalid LLM output after re-prompt: %w", check.Err)
+		}
+		result.OutputValidation = OutputReprompted
 	}
 
-	// Sanitize and validate output
-	definition, err := g.sanitizer.Sanitize(responseText)
-	if err != nil {
-		// Return partial result with raw response for debugging/logging
-		return &GenerateResult{
-			Definition:    nil,
-			InputTokens:   inputTokens,
-			OutputTokens:  g.estimateTokens(responseText),
-			EstimatedCost: estimatedCost,
-			SystemPrompt:  systemPrompt,
-			RawResponse:   responseText,
-		}, fmt.Errorf("invalid LLM output: %w", err)
-	}
-
-	// Count actual tokens from response metadata if available
-	actualInputTokens := inputTokens
-	actualOutputTokens := g.estimateTokens(responseText)
-
-	return &GenerateResult{
-		Definition:    definition,
-		InputTokens:   actualInputTokens,
-		OutputTokens:  actualOutputTokens,
-		EstimatedCost: estimatedCost,
-		SystemPrompt:  systemPrompt,
-		RawResponse:   responseText,
-	}, nil
-}
-
-// buildSystemPrompt creates the system prompt for the LLM
-// This matches the lexicon schema in lexicon/net.openmeet.survey.json
-func (g *SurveyGenerator) buildSystemPrompt() string {
-	return `You are a helpful assistant that creates survey definitions in JSON format.
-
-Given a natural language description of a survey, generate a valid JSON object that matches this structure:
-
-{
-  "questions": [
-    {
-      "id": "q1",
-      "text": "Question text here",
-      "type": "single" | "multi" | "text",
-      "required": false,
-      "options": [
-        {"id": "opt1", "text": "Option 1"},
-        {"id": "opt2", "text": "Option 2"}
-      ]
-    }
-  ],
-  "anonymous": false
-}
-
-Question Types:
-- "single": Single-choice question (radio buttons) - user picks ONE option
-- "multi": Multiple-choice question (checkboxes) - user picks MULTIPLE options
-- "text": Free-text response - no options needed
-
-Rules:
-1. Always return ONLY valid JSON, no markdown, no additional text
-2. Generate unique IDs for questions (q1, q2, q3...) and options (opt1, opt2, opt3...)
-3. Keep questions clear and concise (max 300 characters)
-4. For choice questions (single/multi), provide 2-20 options
-5. Options should be distinct and clear (max 150 characters each)
-6. Use "single" for yes/no, rating scales, or pick-one questions
-7. Use "multi" for check-all-that-apply or select-multiple questions
-8. Use "text" for open-ended questions (options array should be empty)
-9. Maximum 50 questions per survey (typically 1-5 for polls)
-10. Keep all text safe and appropriate - no offensive, dangerous, or inappropriate content
-11. Set "required" to false by default unless specified
-12. Set "anonymous" to false by default
-
-Generate ONLY the JSON, nothing else. No markdown formatting.`
+	result.Definition = check.Definition
+	return result, nil
+}
+
+// addStoppedUsage adds the usage known of a call its context stopped to
+// result: the input tokens estimated for it, and the output that streamed
+// before it stopped. The provider's own count never arrived.
+func addStoppedUsage(result *GenerateResult, pricing Pricing, inputTokens int, streamed string) {
+	outputTokens := 0
+	if streamed != "" {
+		outputTokens = estimateTokens(streamed)
+		result.RawResponse = streamed
+	}
+	result.InputTokens += inputTokens
+	result.OutputTokens += outputTokens
+	result.EstimatedCost += pricing.Cost(inputTokens, outputTokens)
+}
+
+// repairPrompt asks the model to fix output, its invalid response to prompt
+func repairPrompt(prompt, output string, issues []string) string {
+	return prompt + "\n\nYour previous response was not a valid survey:\n" + output +
+		"\n\nFix these problems and return ONLY the corrected JSON:\n- " + strings.Join(issues, "\n- ")
+}
+
+// buildSystemPrompt picks the system prompt for a generation. The
+// templates in prompts/ match the lexicon schema in
+// lexicon/net.openmeet.survey.json.
+func (g *SurveyGenerator) buildSystemPrompt() versionedPrompt {
+	return g.prompts.pick()
 }
 
 // estimateTokens provides a rough token count estimate
 // This is approximate - actual tokenization depends on the model
 func (g *SurveyGenerator) estimateTokens(text string) int {
-	// Rough heuristic: ~1 token per 4 characters for English text
-	// This is conservative and works reasonably well for GPT models
-	return len(text) / 4
+	return estimateTokens(text)
 }
@@ -2,7 +2,11 @@ package generator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -125,7 +129,7 @@ func TestSurveyGenerator_SystemPrompt(t *testing.T) {
 		validJSON := `{"questions":[{"id":"q1","text":"Test?","type":"single","required":false,"options":[{"id":"opt1","text":"Yes"}]}],"anonymous":false}`
 		fakeLLM := fake.NewFakeLLM([]string{validJSON})
 		generator := NewSurveyGenerator(fakeLLM, "gpt-4o-mini")
-		prompt := generator.buildSystemPrompt()
+		prompt := generator.buildSystemPrompt().Text
 
 		// Should contain JSON format instructions
 		assert.Contains(t, prompt, "JSON")
@@ -137,8 +141,230 @@ func TestSurveyGenerator_SystemPrompt(t *testing.T) {
 		assert.Contains(t, prompt, "single")
 		assert.Contains(t, prompt, "multi")
 		assert.Contains(t, prompt, "text")
+		assert.Contains(t, prompt, "rating")
 
 		// Should contain security/safety instructions
 		assert.Contains(t, prompt, "safe")
 	})
 }
+
+// respondingInOrder is a provider that answers with responses in order,
+// recording the prompts it was sent
+type respondingInOrder struct {
+	responses    []string
+	prompts      []string
+	temperatures []float64
+}
+
+func (p *respondingInOrder) Name() string     { return ProviderOpenAI }
+func (p *respondingInOrder) Model() string    { return "gpt-4o-mini" }
+func (p *respondingInOrder) Pricing() Pricing { return Pricing{InputPer1M: 1, OutputPer1M: 1} }
+
+func (p *respondingInOrder) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	response := p.responses[len(p.prompts)]
+	p.prompts = append(p.prompts, req.Prompt)
+	p.temperatures = append(p.temperatures, req.Temperature)
+	return &GenerationResult{JSON: response, InputTokens: 100, OutputTokens: 50, CostUSD: 0.0002}, nil
+}
+
+func TestSurveyGenerator_OutputValidation(t *testing.T) {
+	ctx := context.Background()
+	textWithOptions := `{"questions":[{"id":"q1","text":"Favorite book?","type":"text","required":false,"options":[{"id":"opt1","text":"Fiction"},{"id":"opt2","text":"Poetry"}]}],"anonymous":false}`
+
+	t.Run("valid output", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{pizzaPollJSON}}
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, OutputValid, result.OutputValidation)
+		assert.Empty(t, result.OutputIssues)
+		assert.Len(t, provider.prompts, 1)
+	})
+
+	t.Run("repairs output without re-prompting", func(t *testing.T) {
+		missingIDs := `{"questions":[{"text":"Favorite topping?","type":"single","required":false,"options":[{"text":"Cheese"},{"text":"Basil"}]}],"anonymous":false}`
+		provider := &respondingInOrder{responses: []string{missingIDs}}
+
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, OutputRepaired, result.OutputValidation)
+		assert.Contains(t, result.OutputIssues, "question 0: question ID is required")
+		assert.Equal(t, "q1", result.Definition.Questions[0].ID)
+		assert.Equal(t, "opt2", result.Definition.Questions[0].Options[1].ID)
+		assert.Len(t, provider.prompts, 1)
+	})
+
+	t.Run("re-prompts with the validation errors", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{textWithOptions, pizzaPollJSON}}
+
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(ctx, "Create a book survey")
+		require.NoError(t, err)
+		assert.Equal(t, OutputReprompted, result.OutputValidation)
+		assert.Equal(t, []string{"question 0: text questions cannot have options"}, result.OutputIssues)
+		require.NotNil(t, result.Definition)
+
+		require.Len(t, provider.prompts, 2)
+		retry := provider.prompts[1]
+		assert.True(t, strings.HasPrefix(retry, "Create a book survey"))
+		assert.Contains(t, retry, textWithOptions)
+		assert.Contains(t, retry, "- question 0: text questions cannot have options")
+
+		// Both calls are paid for
+		assert.Equal(t, 200, result.InputTokens)
+		assert.Equal(t, 100, result.OutputTokens)
+		assert.Equal(t, pizzaPollJSON, result.RawResponse)
+	})
+
+	t.Run("re-prompts only once", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{textWithOptions, textWithOptions, pizzaPollJSON}}
+
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(ctx, "Create a book survey")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid LLM output after re-prompt")
+		require.NotNil(t, result)
+		assert.Nil(t, result.Definition)
+		assert.Equal(t, OutputInvalid, result.OutputValidation)
+		assert.Len(t, provider.prompts, 2)
+	})
+}
+
+func TestSurveyGenerator_RecordsSettings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends and returns the temperature and prompt version", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{pizzaPollJSON}}
+		gen := NewSurveyGeneratorWithProvider(provider)
+		gen.SetTemperature(0.3)
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, []float64{0.3}, provider.temperatures)
+		assert.Equal(t, 0.3, result.Temperature)
+		assert.Equal(t, DefaultPromptVersion, result.PromptVersion)
+		assert.Equal(t, "gpt-4o-mini", result.Model)
+	})
+
+	t.Run("defaults the temperature", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{pizzaPollJSON}}
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, []float64{DefaultTemperature}, provider.temperatures)
+		assert.Equal(t, DefaultTemperature, result.Temperature)
+	})
+
+	t.Run("records settings on failed results and attempts", func(t *testing.T) {
+		primary := failing(ProviderOpenAI, errors.New("API returned unexpected status code: 503"))
+		gen := NewSurveyGeneratorWithProvider(primary, answering(ProviderAnthropic, "not json"))
+		gen.SetTemperature(0.5)
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.Error(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, 0.5, result.Temperature)
+		assert.Equal(t, DefaultPromptVersion, result.PromptVersion)
+		require.NotEmpty(t, result.FailedAttempts)
+		assert.Equal(t, 0.5, result.FailedAttempts[0].Temperature)
+		assert.Equal(t, DefaultPromptVersion, result.FailedAttempts[0].PromptVersion)
+	})
+}
+
+// slowProvider streams partial, then waits for its context to be done,
+// like a provider that hasn't finished answering
+type slowProvider struct {
+	partial string
+}
+
+func (p *slowProvider) Name() string     { return ProviderOpenAI }
+func (p *slowProvider) Model() string    { return "gpt-4o-mini" }
+func (p *slowProvider) Pricing() Pricing { return Pricing{InputPer1M: 1, OutputPer1M: 1} }
+
+func (p *slowProvider) Generate(ctx context.Context, req GenerationRequest) (*GenerationResult, error) {
+	if req.OnChunk != nil && p.partial != "" {
+		req.OnChunk(p.partial)
+	}
+	<-ctx.Done()
+	return nil, fmt.Errorf("openai generation failed: %w", ctx.Err())
+}
+
+func TestSurveyGenerator_Stopped(t *testing.T) {
+	partial := `{"questions":[{"id":"q1","text":"Do you like pizza?"`
+
+	t.Run("cancelled mid-call returns the usage known", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var progressed []QuestionProgress
+		ctx = WithProgress(ctx, func(p QuestionProgress) {
+			progressed = append(progressed, p)
+			cancel()
+		})
+		gen := NewSurveyGeneratorWithProvider(&slowProvider{partial: partial + `,"type":"single"},`})
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotNil(t, result)
+		assert.Len(t, progressed, 1)
+		assert.Equal(t, ProviderOpenAI, result.Provider)
+		assert.Greater(t, result.InputTokens, 0)
+		assert.Equal(t, result.EstimatedInputTokens, result.InputTokens)
+		assert.Equal(t, estimateTokens(result.RawResponse), result.OutputTokens)
+		assert.True(t, strings.HasPrefix(result.RawResponse, partial))
+		assert.Greater(t, result.EstimatedCost, 0.0)
+		assert.Equal(t, DefaultPromptVersion, result.PromptVersion)
+		assert.Empty(t, result.FailedAttempts)
+	})
+
+	t.Run("timed out before any output", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		gen := NewSurveyGeneratorWithProvider(&slowProvider{})
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotNil(t, result)
+		assert.Greater(t, result.InputTokens, 0)
+		assert.Zero(t, result.OutputTokens)
+		assert.Empty(t, result.RawResponse)
+	})
+
+	t.Run("a failed re-prompt keeps the first call's usage", func(t *testing.T) {
+		textWithOptions := `{"questions":[{"id":"q1","text":"Favorite book?","type":"text","required":false,"options":[{"id":"opt1","text":"Fiction"},{"id":"opt2","text":"Poetry"}]}],"anonymous":false}`
+		calls := 0
+		provider := &fakeProvider{name: ProviderOpenAI, generate: func(ctx context.Context) (*GenerationResult, error) {
+			calls++
+			if calls == 1 {
+				return &GenerationResult{JSON: textWithOptions, InputTokens: 100, OutputTokens: 50, CostUSD: 0.0002}, nil
+			}
+			return nil, errors.New("API returned unexpected status code: 400")
+		}}
+
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(context.Background(), "Create a book survey")
+		require.Error(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, 100, result.InputTokens)
+		assert.Equal(t, 50, result.OutputTokens)
+		assert.Equal(t, 0.0002, result.EstimatedCost)
+		assert.Equal(t, textWithOptions, result.RawResponse)
+	})
+
+	t.Run("a stopped re-prompt keeps the first call's usage", func(t *testing.T) {
+		textWithOptions := `{"questions":[{"id":"q1","text":"Favorite book?","type":"text","required":false,"options":[{"id":"opt1","text":"Fiction"},{"id":"opt2","text":"Poetry"}]}],"anonymous":false}`
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		provider := &fakeProvider{name: ProviderOpenAI, generate: func(ctx context.Context) (*GenerationResult, error) {
+			calls++
+			if calls == 1 {
+				return &GenerationResult{JSON: textWithOptions, InputTokens: 100, OutputTokens: 50, CostUSD: 0.0002}, nil
+			}
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}
+
+		result, err := NewSurveyGeneratorWithProvider(provider).Generate(ctx, "Create a book survey")
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotNil(t, result)
+		assert.Equal(t, 2, calls)
+		assert.Greater(t, result.InputTokens, 100)
+		assert.Equal(t, 50, result.OutputTokens)
+		assert.Greater(t, result.EstimatedCost, 0.0002)
+	})
+}
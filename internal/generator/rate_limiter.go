@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"context"
 	"os"
 	"strconv"
 	"sync"
@@ -21,24 +22,31 @@ const (
 	DefaultAuthWindow = 24 * time.Hour
 )
 
-// rateLimitEntry tracks requests for a single identifier (IP or DID)
-type rateLimitEntry struct {
-	count       int
-	windowStart time.Time
+// WindowCounter counts requests per key in a window. ratelimit.DBLimiter
+// is one shared by every API replica.
+type WindowCounter interface {
+	// Allow records a request for key if fewer than limit were recorded in
+	// the last window, or returns false and how long until one may be
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, retryAfter time.Duration, err error)
 }
 
+// Key prefixes keep DIDs and IPs apart, and apart from other users of a
+// shared WindowCounter
+const (
+	anonKeyPrefix = "ai:anon:"
+	authKeyPrefix = "ai:auth:"
+)
+
 // RateLimiter tracks and enforces rate limits for AI generation
 // This is separate from OpenAI's API rate limits - this is business logic
 // to prevent abuse of our OpenAI budget by limiting how many AI generations
 // a user can request.
 type RateLimiter struct {
-	mu           sync.RWMutex
-	anonLimit    int
-	anonWindow   time.Duration
-	authLimit    int
-	authWindow   time.Duration
-	anonTracking map[string]*rateLimitEntry // keyed by IP
-	authTracking map[string]*rateLimitEntry // keyed by DID
+	anonLimit  int
+	anonWindow time.Duration
+	authLimit  int
+	authWindow time.Duration
+	counter    WindowCounter
 }
 
 // RateLimiterConfig holds configuration for rate limiting
@@ -95,76 +103,75 @@ func NewRateLimiter() *RateLimiter {
 	return NewRateLimiterWithConfig(RateLimiterConfigFromEnv())
 }
 
-// NewRateLimiterWithConfig creates a new rate limiter with the given configuration
+// NewRateLimiterWithConfig creates a new rate limiter with the given
+// configuration, counting in memory. Counts are per process and reset on
+// restart; use NewSharedRateLimiter when running more than one replica.
 func NewRateLimiterWithConfig(config RateLimiterConfig) *RateLimiter {
+	return NewSharedRateLimiter(newMemoryCounter(), config)
+}
+
+// NewSharedRateLimiter creates a rate limiter with the given configuration
+// that counts with counter
+func NewSharedRateLimiter(counter WindowCounter, config RateLimiterConfig) *RateLimiter {
 	return &RateLimiter{
-		anonLimit:    config.AnonLimit,
-		anonWindow:   config.AnonWindow,
-		authLimit:    config.AuthLimit,
-		authWindow:   config.AuthWindow,
-		anonTracking: make(map[string]*rateLimitEntry),
-		authTracking: make(map[string]*rateLimitEntry),
+		anonLimit:  config.AnonLimit,
+		anonWindow: config.AnonWindow,
+		authLimit:  config.AuthLimit,
+		authWindow: config.AuthWindow,
+		counter:    counter,
 	}
 }
 
 // AllowAnonymous checks if an anonymous request (by IP) is allowed
-func (rl *RateLimiter) AllowAnonymous(ip string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	return rl.checkLimit(ip, rl.anonTracking, rl.anonLimit, rl.anonWindow)
+func (rl *RateLimiter) AllowAnonymous(ctx context.Context, ip string) (bool, time.Duration, error) {
+	return rl.counter.Allow(ctx, anonKeyPrefix+ip, rl.anonLimit, rl.anonWindow)
 }
 
 // AllowAuthenticated checks if an authenticated request (by DID) is allowed
-func (rl *RateLimiter) AllowAuthenticated(did string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+func (rl *RateLimiter) AllowAuthenticated(ctx context.Context, did string) (bool, time.Duration, error) {
+	return rl.counter.Allow(ctx, authKeyPrefix+did, rl.authLimit, rl.authWindow)
+}
+
+// rateLimitEntry tracks requests for a single key
+type rateLimitEntry struct {
+	count       int
+	windowStart time.Time
+}
 
-	return rl.checkLimit(did, rl.authTracking, rl.authLimit, rl.authWindow)
+// memoryCounter is a WindowCounter for a single process. Windows are fixed:
+// each starts with a key's first request after the last one ended.
+type memoryCounter struct {
+	mu       sync.Mutex
+	tracking map[string]*rateLimitEntry
 }
 
-// checkLimit is the internal logic for checking and updating rate limits
-func (rl *RateLimiter) checkLimit(key string, tracking map[string]*rateLimitEntry, limit int, window time.Duration) bool {
+func newMemoryCounter() *memoryCounter {
+	return &memoryCounter{tracking: make(map[string]*rateLimitEntry)}
+}
+
+// Allow implements WindowCounter
+func (m *memoryCounter) Allow(_ context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	now := time.Now()
 
-	entry, exists := tracking[key]
-	if !exists {
-		// First request
-		tracking[key] = &rateLimitEntry{
+	entry, exists := m.tracking[key]
+	if !exists || now.Sub(entry.windowStart) > window {
+		// First request, or the window expired
+		m.tracking[key] = &rateLimitEntry{
 			count:       1,
 			windowStart: now,
 		}
-		return true
-	}
-
-	// Check if window has expired
-	if now.Sub(entry.windowStart) > window {
-		// Reset window
-		entry.count = 1
-		entry.windowStart = now
-		return true
+		return true, 0, nil
 	}
 
 	// Window still valid, check limit
 	if entry.count >= limit {
-		return false
+		return false, entry.windowStart.Add(window).Sub(now), nil
 	}
 
 	// Increment counter
 	entry.count++
-	return true
-}
-
-// ResetAnonymous resets the rate limit for a specific IP (for testing)
-func (rl *RateLimiter) ResetAnonymous(ip string) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	delete(rl.anonTracking, ip)
-}
-
-// ResetAuthenticated resets the rate limit for a specific DID (for testing)
-func (rl *RateLimiter) ResetAuthenticated(did string) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	delete(rl.authTracking, did)
+	return true, 0, nil
 }
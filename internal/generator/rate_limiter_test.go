@@ -1,12 +1,18 @@
 package generator
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// isAllowed drops a rate limit check's retry delay and error
+func isAllowed(ok bool, _ time.Duration, err error) bool {
+	return ok && err == nil
+}
+
 func TestRateLimiter_Anonymous(t *testing.T) {
 	// Use explicit config for predictable tests
 	config := RateLimiterConfig{
@@ -21,15 +27,15 @@ func TestRateLimiter_Anonymous(t *testing.T) {
 		ip := "192.168.1.1"
 
 		// First request should succeed
-		allowed := limiter.AllowAnonymous(ip)
+		allowed := isAllowed(limiter.AllowAnonymous(context.Background(), ip))
 		assert.True(t, allowed, "first request should be allowed")
 
 		// Second request should succeed
-		allowed = limiter.AllowAnonymous(ip)
+		allowed = isAllowed(limiter.AllowAnonymous(context.Background(), ip))
 		assert.True(t, allowed, "second request should be allowed")
 
 		// Third request should be denied (config is 2)
-		allowed = limiter.AllowAnonymous(ip)
+		allowed = isAllowed(limiter.AllowAnonymous(context.Background(), ip))
 		assert.False(t, allowed, "third request should be denied (exceeds configured limit)")
 	})
 
@@ -38,39 +44,37 @@ func TestRateLimiter_Anonymous(t *testing.T) {
 		ip2 := "192.168.1.11"
 
 		// Both IPs should be able to make requests
-		assert.True(t, limiter.AllowAnonymous(ip1))
-		assert.True(t, limiter.AllowAnonymous(ip2))
-		assert.True(t, limiter.AllowAnonymous(ip1))
-		assert.True(t, limiter.AllowAnonymous(ip2))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip1)))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip2)))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip1)))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip2)))
 
 		// Both should hit their limits independently
-		assert.False(t, limiter.AllowAnonymous(ip1))
-		assert.False(t, limiter.AllowAnonymous(ip2))
+		assert.False(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip1)))
+		assert.False(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip2)))
 	})
 
 	t.Run("reset after window expires", func(t *testing.T) {
 		// Create a limiter with custom window for testing
-		limiter := &RateLimiter{
-			anonLimit:     2,
-			anonWindow:    100 * time.Millisecond, // Short window for testing
-			authLimit:     10,
-			authWindow:    time.Hour,
-			anonTracking:  make(map[string]*rateLimitEntry),
-			authTracking:  make(map[string]*rateLimitEntry),
-		}
+		limiter := NewRateLimiterWithConfig(RateLimiterConfig{
+			AnonLimit:  2,
+			AnonWindow: 100 * time.Millisecond, // Short window for testing
+			AuthLimit:  10,
+			AuthWindow: time.Hour,
+		})
 
 		ip := "192.168.1.20"
 
 		// Use up the limit
-		assert.True(t, limiter.AllowAnonymous(ip))
-		assert.True(t, limiter.AllowAnonymous(ip))
-		assert.False(t, limiter.AllowAnonymous(ip))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip)))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip)))
+		assert.False(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip)))
 
 		// Wait for window to expire
 		time.Sleep(150 * time.Millisecond)
 
 		// Should be able to make requests again
-		assert.True(t, limiter.AllowAnonymous(ip))
+		assert.True(t, isAllowed(limiter.AllowAnonymous(context.Background(), ip)))
 	})
 }
 
@@ -89,12 +93,12 @@ func TestRateLimiter_Authenticated(t *testing.T) {
 
 		// Should allow 10 requests (configured limit)
 		for i := 0; i < 10; i++ {
-			allowed := limiter.AllowAuthenticated(did)
+			allowed := isAllowed(limiter.AllowAuthenticated(context.Background(), did))
 			assert.True(t, allowed, "request %d should be allowed", i+1)
 		}
 
 		// 11th request should be denied
-		allowed := limiter.AllowAuthenticated(did)
+		allowed := isAllowed(limiter.AllowAuthenticated(context.Background(), did))
 		assert.False(t, allowed, "11th request should be denied (exceeds configured limit)")
 	})
 
@@ -103,28 +107,26 @@ func TestRateLimiter_Authenticated(t *testing.T) {
 		did2 := "did:plc:user2"
 
 		// Both DIDs should be able to make requests
-		assert.True(t, limiter.AllowAuthenticated(did1))
-		assert.True(t, limiter.AllowAuthenticated(did2))
-		assert.True(t, limiter.AllowAuthenticated(did1))
-		assert.True(t, limiter.AllowAuthenticated(did2))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did1)))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did2)))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did1)))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did2)))
 	})
 
 	t.Run("reset after window expires", func(t *testing.T) {
-		limiter := &RateLimiter{
-			anonLimit:     2,
-			anonWindow:    time.Hour,
-			authLimit:     10,
-			authWindow:    100 * time.Millisecond, // Short window for testing
-			anonTracking:  make(map[string]*rateLimitEntry),
-			authTracking:  make(map[string]*rateLimitEntry),
-		}
+		limiter := NewRateLimiterWithConfig(RateLimiterConfig{
+			AnonLimit:  2,
+			AnonWindow: time.Hour,
+			AuthLimit:  10,
+			AuthWindow: 100 * time.Millisecond, // Short window for testing
+		})
 
 		did := "did:plc:test456"
 
 		// Use up some of the limit
-		assert.True(t, limiter.AllowAuthenticated(did))
-		assert.True(t, limiter.AllowAuthenticated(did))
-		assert.True(t, limiter.AllowAuthenticated(did))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did)))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did)))
+		assert.True(t, isAllowed(limiter.AllowAuthenticated(context.Background(), did)))
 
 		// Wait for window to expire
 		time.Sleep(150 * time.Millisecond)
@@ -132,7 +134,7 @@ func TestRateLimiter_Authenticated(t *testing.T) {
 		// Counter should be reset
 		// Make 10 requests to verify full limit is available
 		for i := 0; i < 10; i++ {
-			allowed := limiter.AllowAuthenticated(did)
+			allowed := isAllowed(limiter.AllowAuthenticated(context.Background(), did))
 			assert.True(t, allowed, "request %d should be allowed after reset", i+1)
 		}
 	})
@@ -154,7 +156,7 @@ func TestRateLimiter_Concurrent(t *testing.T) {
 		results := make(chan bool, 5)
 		for i := 0; i < 5; i++ {
 			go func() {
-				results <- limiter.AllowAnonymous(ip)
+				results <- isAllowed(limiter.AllowAnonymous(context.Background(), ip))
 			}()
 		}
 
@@ -213,3 +215,44 @@ func TestRateLimiterConfigFromEnv(t *testing.T) {
 		assert.Equal(t, DefaultAuthWindow, config.AuthWindow)
 	})
 }
+
+func TestRateLimiter_RetryAfter(t *testing.T) {
+	limiter := NewRateLimiterWithConfig(RateLimiterConfig{AnonLimit: 1, AnonWindow: time.Hour, AuthLimit: 1, AuthWindow: time.Hour})
+	ctx := context.Background()
+
+	ok, _, err := limiter.AllowAnonymous(ctx, "192.168.1.30")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, retryAfter, err := limiter.AllowAnonymous(ctx, "192.168.1.30")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.InDelta(t, time.Hour, retryAfter, float64(time.Second), "the rest of the window")
+}
+
+// recordingCounter records the checks a shared RateLimiter makes
+type recordingCounter struct {
+	keys    []string
+	limits  []int
+	windows []time.Duration
+}
+
+func (c *recordingCounter) Allow(_ context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	c.keys = append(c.keys, key)
+	c.limits = append(c.limits, limit)
+	c.windows = append(c.windows, window)
+	return true, 0, nil
+}
+
+func TestSharedRateLimiter(t *testing.T) {
+	counter := &recordingCounter{}
+	limiter := NewSharedRateLimiter(counter, RateLimiterConfig{AnonLimit: 5, AnonWindow: time.Hour, AuthLimit: 20, AuthWindow: 24 * time.Hour})
+	ctx := context.Background()
+
+	isAllowed(limiter.AllowAnonymous(ctx, "192.168.1.40"))
+	isAllowed(limiter.AllowAuthenticated(ctx, "did:plc:shared"))
+
+	assert.Equal(t, []string{"ai:anon:192.168.1.40", "ai:auth:did:plc:shared"}, counter.keys, "IPs and DIDs are counted apart")
+	assert.Equal(t, []int{5, 20}, counter.limits)
+	assert.Equal(t, []time.Duration{time.Hour, 24 * time.Hour}, counter.windows)
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Placeholders RedactPII replaces probable personal information with
+const (
+	RedactedEmail   = "[EMAIL]"
+	RedactedPhone   = "[PHONE]"
+	RedactedAddress = "[ADDRESS]"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+	// A house number, one to four capitalized words and a street type
+	addressPattern = regexp.MustCompile(`\b\d{1,5}[A-Za-z]?\s+(?:[A-Z][A-Za-z'-]*\.?\s+){1,4}(?i:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|way|place|pl|terrace|circle|highway|hwy)\b\.?`)
+
+	// Phone number candidates: an optional country code and area code, then
+	// up to six digit groups split by single spaces, dots or dashes.
+	// looksLikePhone decides which candidates are phone numbers.
+	phoneCandidate = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{1,5}\)[\s.-]?)?\d{1,10}(?:[\s.-]\d{1,10}){0,5}`)
+
+	// A local number without its area code: an exchange, which never starts
+	// with 0 or 1, and four digits
+	localPhonePattern = regexp.MustCompile(`^[2-9]\d{2}[.-]\d{4}$`)
+
+	datePattern = regexp.MustCompile(`^(?:\d{4}[.-]\d{1,2}[.-]\d{1,2}|\d{1,2}[.-]\d{1,2}[.-]\d{2,4})$`)
+	digitGroups = regexp.MustCompile(`\d+`)
+)
+
+// RedactPII replaces probable email addresses, phone numbers and street
+// addresses in text with [EMAIL], [PHONE] and [ADDRESS]. It's a regex pass
+// tuned to catch what people paste into a survey description, and errs
+// towards redacting: a long run of digits is taken for a phone number.
+func RedactPII(text string) string {
+	text = emailPattern.ReplaceAllString(text, RedactedEmail)
+	text = addressPattern.ReplaceAllString(text, RedactedAddress)
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range phoneCandidate.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if !standsAlone(text, start, end) || !looksLikePhone(text[start:end]) {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(RedactedPhone)
+		last = end
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// standsAlone reports whether text[start:end] isn't part of a longer word,
+// number or decimal
+func standsAlone(text string, start, end int) bool {
+	if start > 0 {
+		prev := rune(text[start-1])
+		if unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_' || prev == '.' || prev == ',' {
+			return false
+		}
+	}
+	if end < len(text) {
+		next := rune(text[end])
+		if unicode.IsLetter(next) || unicode.IsDigit(next) || next == '_' {
+			return false
+		}
+		// A trailing dot ends a sentence, unless more digits follow
+		if (next == '.' || next == ',') && end+1 < len(text) && unicode.IsDigit(rune(text[end+1])) {
+			return false
+		}
+	}
+	return true
+}
+
+// looksLikePhone reports whether a phone number candidate has the digits and
+// grouping of one. Numbers with a country code or area code in parentheses
+// need 7 to 15 digits, as E.164 allows. Without either, they need 10 to 15
+// digits in one run, at least three groups with one of three digits or more,
+// or a local number like 555-1234, so dates, ranges like 1000-2000 and lists
+// of small numbers are kept.
+func looksLikePhone(candidate string) bool {
+	if datePattern.MatchString(candidate) {
+		return false
+	}
+
+	groups := digitGroups.FindAllString(candidate, -1)
+	digits := 0
+	longest := 0
+	for _, g := range groups {
+		digits += len(g)
+		longest = max(longest, len(g))
+	}
+	if digits < 7 || digits > 15 {
+		return false
+	}
+
+	if strings.HasPrefix(candidate, "+") || strings.Contains(candidate, "(") {
+		return true
+	}
+	if len(groups) == 1 {
+		return digits >= 10
+	}
+	if localPhonePattern.MatchString(candidate) {
+		return true
+	}
+	return len(groups) >= 3 && longest >= 3
+}
+
+// RedactPIIFromEnv reads AI_LOG_REDACT_PII, whether prompts are redacted
+// before they're stored in the generation logs (default true; set it to
+// false to keep prompts verbatim, e.g. when debugging locally)
+func RedactPIIFromEnv() bool {
+	return os.Getenv("AI_LOG_REDACT_PII") != "false"
+}
@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxRetries is how many times a transient provider error is retried
+	maxRetries = 2
+
+	// retryBaseDelay is the wait before the first retry, doubling after it,
+	// unless the provider sends Retry-After
+	retryBaseDelay = time.Second
+
+	// retryMaxDelay is the longest single wait; a longer Retry-After isn't
+	// waited for
+	retryMaxDelay = 8 * time.Second
+
+	// retryBudget caps the time from the first request to the last retry, so
+	// a generation with retries stays under ~20s of waiting and requests
+	retryBudget = 20 * time.Second
+)
+
+// retryTransport retries provider requests that fail transiently: 429 (after
+// Retry-After when sent), 500, 502, 503, 504, 529 (Anthropic's overloaded),
+// and connections reset before a response. Other errors, like 400 for an
+// invalid request or a content filter, are returned at once.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	budget     time.Duration
+}
+
+// newRetryClient returns an HTTP client for the provider SDKs that retries
+// transient errors
+func newRetryClient() *http.Client {
+	return &http.Client{Transport: &retryTransport{
+		base:       http.DefaultTransport,
+		maxRetries: maxRetries,
+		baseDelay:  retryBaseDelay,
+		maxDelay:   retryMaxDelay,
+		budget:     retryBudget,
+	}}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+
+	for retry := 0; ; retry++ {
+		countAttempt(ctx)
+		resp, err := t.base.RoundTrip(req)
+		if !isTransient(resp, err) || retry == t.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := t.baseDelay << retry
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = after
+			}
+		}
+		if delay > t.maxDelay || time.Since(start)+delay > t.budget {
+			return resp, err
+		}
+
+		// The body must be resent, so the SDK request needs to support it
+		body, bodyErr := rewindBody(req)
+		if bodyErr != nil {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		req = req.Clone(ctx)
+		req.Body = body
+	}
+}
+
+// isTransient reports whether a provider request failed in a way that may
+// succeed if sent again
+func isTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// rewindBody returns a fresh copy of req's body for resending it
+func rewindBody(req *http.Request) (io.ReadCloser, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req.Body, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("request body can't be resent")
+	}
+	return req.GetBody()
+}
+
+type attemptCounterKey struct{}
+
+// withAttemptCounter returns a context in which retryTransport counts the
+// requests it sends to counter
+func withAttemptCounter(ctx context.Context, counter *atomic.Int32) context.Context {
+	return context.WithValue(ctx, attemptCounterKey{}, counter)
+}
+
+// countAttempt adds a request to ctx's attempt counter, if it has one
+func countAttempt(ctx context.Context) {
+	if counter, ok := ctx.Value(attemptCounterKey{}).(*atomic.Int32); ok {
+		counter.Add(1)
+	}
+}
@@ -0,0 +1,200 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// scriptedOpenAI is an OpenAI chat completions server that answers with the
+// scripted responses in order, then with the survey
+type scriptedOpenAI struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newScriptedOpenAI(t *testing.T, script ...func(w http.ResponseWriter)) *scriptedOpenAI {
+	t.Helper()
+	s := &scriptedOpenAI{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(s.requests.Add(1))
+		if n <= len(script) {
+			script[n-1](w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"created": 1700000000,
+			"model":   "gpt-4o-mini",
+			"choices": []map[string]any{{
+				"index":         0,
+				"message":       map[string]any{"role": "assistant", "content": pizzaPollJSON},
+				"finish_reason": "stop",
+			}},
+			"usage": map[string]any{"prompt_tokens": 100, "completion_tokens": 50, "total_tokens": 150},
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func status(code int, headers ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(`{"error":{"message":"scripted failure"}}`))
+	}
+}
+
+// resetConnection closes the connection without a response
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// testRetryTransport retries like newRetryClient, with short delays
+func testRetryTransport() *retryTransport {
+	return &retryTransport{
+		base:       http.DefaultTransport,
+		maxRetries: maxRetries,
+		baseDelay:  time.Millisecond,
+		maxDelay:   100 * time.Millisecond,
+		budget:     time.Second,
+	}
+}
+
+func newScriptedGenerator(t *testing.T, server *scriptedOpenAI, transport *retryTransport) *SurveyGenerator {
+	t.Helper()
+	llm, err := openai.New(
+		openai.WithToken("sk-test"),
+		openai.WithModel("gpt-4o-mini"),
+		openai.WithBaseURL(server.URL),
+		openai.WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	require.NoError(t, err)
+	return NewSurveyGenerator(llm, "gpt-4o-mini")
+}
+
+func TestRetryTransport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retries transient errors until success", func(t *testing.T) {
+		server := newScriptedOpenAI(t, status(http.StatusBadGateway), status(http.StatusTooManyRequests, "Retry-After", "0"))
+		gen := newScriptedGenerator(t, server, testRetryTransport())
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		require.NotNil(t, result.Definition)
+		assert.Equal(t, 3, result.Attempts)
+		assert.Equal(t, 100, result.InputTokens)
+		assert.Empty(t, result.FailedAttempts)
+		assert.Equal(t, int32(3), server.requests.Load())
+	})
+
+	t.Run("retries reset connections", func(t *testing.T) {
+		server := newScriptedOpenAI(t, resetConnection)
+		gen := newScriptedGenerator(t, server, testRetryTransport())
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Attempts)
+	})
+
+	t.Run("a first-time success takes one attempt", func(t *testing.T) {
+		server := newScriptedOpenAI(t)
+		gen := newScriptedGenerator(t, server, testRetryTransport())
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Attempts)
+	})
+
+	t.Run("doesn't retry invalid requests", func(t *testing.T) {
+		server := newScriptedOpenAI(t, status(http.StatusBadRequest))
+		gen := newScriptedGenerator(t, server, testRetryTransport())
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "status code: 400")
+		assert.Nil(t, result)
+		assert.Equal(t, int32(1), server.requests.Load())
+	})
+
+	t.Run("gives up after two retries", func(t *testing.T) {
+		unavailable := status(http.StatusServiceUnavailable)
+		server := newScriptedOpenAI(t, unavailable, unavailable, unavailable, unavailable)
+		gen := newScriptedGenerator(t, server, testRetryTransport())
+
+		result, err := gen.Generate(ctx, "Create a pizza poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "status code: 503")
+		assert.Equal(t, int32(3), server.requests.Load())
+
+		// The final failure is recorded once, with its attempts
+		require.NotNil(t, result)
+		require.Len(t, result.FailedAttempts, 1)
+		assert.Equal(t, 3, result.FailedAttempts[0].Attempts)
+	})
+
+	t.Run("doesn't wait past the delay cap", func(t *testing.T) {
+		server := newScriptedOpenAI(t, status(http.StatusTooManyRequests, "Retry-After", "60"))
+		gen := newScriptedGenerator(t, server, testRetryTransport())
+
+		_, err := gen.Generate(ctx, "Create a pizza poll")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "status code: 429")
+		assert.Equal(t, int32(1), server.requests.Load())
+	})
+
+	t.Run("stops when the request is canceled", func(t *testing.T) {
+		server := newScriptedOpenAI(t, status(http.StatusServiceUnavailable))
+		transport := testRetryTransport()
+		transport.baseDelay = 50 * time.Millisecond
+		gen := newScriptedGenerator(t, server, transport)
+
+		canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := gen.Generate(canceled, "Create a pizza poll")
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, int32(1), server.requests.Load())
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"3", 3 * time.Second, true},
+		{"soon", 0, false},
+		{"Thu, 01 Jan 1970 00:00:00 GMT", 0, true}, // in the past
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := retryAfter(tt.value)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
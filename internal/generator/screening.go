@@ -0,0 +1,257 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Screening flag reasons
+const (
+	ScreenReasonInjection  = "prompt_injection"
+	ScreenReasonEncoded    = "encoded_content"
+	ScreenReasonRepetitive = "repetitive_content"
+	ScreenReasonModeration = "moderation"
+)
+
+// ScreenFlag is returned when screening rejects a description. Error gives
+// the reason and what triggered it, for the generation log; UserMessage is
+// what to tell the user.
+type ScreenFlag struct {
+	Reason string // One of the ScreenReason constants
+	Detail string // What triggered the flag, e.g. the matched pattern's name
+}
+
+func (f *ScreenFlag) Error() string {
+	return fmt.Sprintf("input flagged by screening: %s: %s", f.Reason, f.Detail)
+}
+
+// UserMessage explains the rejection without saying exactly what matched
+func (f *ScreenFlag) UserMessage() string {
+	switch f.Reason {
+	case ScreenReasonInjection:
+		return "Please describe the survey you want. Descriptions can't include instructions to the AI."
+	case ScreenReasonModeration:
+		return "This description asks for content we can't generate."
+	default:
+		return "This description doesn't look like a survey description. Please describe your survey in plain words."
+	}
+}
+
+// injectionPattern is a named phrase that tries to override the system prompt
+type injectionPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// injectionPatterns match against lowercased input with whitespace collapsed.
+// They aim at obvious attempts; subtler ones are left to the system prompt
+// and output validation.
+var injectionPatterns = []injectionPattern{
+	{"ignore instructions", regexp.MustCompile(`\b(ignore|disregard|forget|override|bypass)\s+(all\s+|any\s+|the\s+|of\s+)*(your\s+|previous\s+|prior\s+|above\s+|earlier\s+|preceding\s+|system\s+)+(instructions|rules|prompts?|directions|guidelines)\b`)},
+	{"reveal system prompt", regexp.MustCompile(`\b(reveal|show|print|output|repeat|display|leak|tell\s+me|give\s+me|what\s+(is|are))\s+(me\s+)?(your|the)\s+(system\s+|initial\s+|hidden\s+|original\s+)+(prompt|instructions|message)s?\b`)},
+	{"your system prompt", regexp.MustCompile(`\byour\s+(system|hidden|initial)\s+(prompt|instructions)\b`)},
+	{"role override", regexp.MustCompile(`\b(you\s+are\s+now|from\s+now\s+on,?\s+you\s+(are|will|must))\b`)},
+	{"new instructions", regexp.MustCompile(`\bnew\s+(system\s+)?instructions\s*:`)},
+	{"jailbreak", regexp.MustCompile(`\b(jailbreak|jailbroken|dan\s+mode|developer\s+mode)\b`)},
+	{"chat role marker", regexp.MustCompile(`(?m)^\s*(system|assistant)\s*:`)},
+	{"chat markup", regexp.MustCompile(`<\|(im_start|im_end|system|endoftext)\|>|\[/?inst\]|</?(system|instructions)>`)},
+}
+
+const (
+	// minEntropyRunes is the fewest ASCII non-space characters the entropy
+	// checks need; shorter descriptions don't give a meaningful measure
+	minEntropyRunes = 100
+
+	// maxEntropyBits flags text denser than prose, like base64 or random
+	// characters. English runs around 4-4.5 bits per character.
+	maxEntropyBits = 5.2
+
+	// minEntropyBits flags text that repeats a few characters, like padding
+	minEntropyBits = 2.0
+
+	// maxTokenLength is the longest word allowed, outside URLs; longer ones
+	// are usually encoded payloads
+	maxTokenLength = 80
+)
+
+// ScreenInput checks a generation description for obvious prompt injection
+// and for content that isn't a description: encoded payloads and padding.
+// It returns nil when the description passes. The entropy checks only
+// consider ASCII, so descriptions in other scripts aren't flagged for it.
+func ScreenInput(input string) *ScreenFlag {
+	normalized := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	// Role markers are matched at line starts, so keep line breaks
+	lines := strings.ToLower(input)
+
+	for _, pattern := range injectionPatterns {
+		if pattern.re.MatchString(normalized) || pattern.re.MatchString(lines) {
+			return &ScreenFlag{Reason: ScreenReasonInjection, Detail: pattern.name}
+		}
+	}
+
+	for _, word := range strings.Fields(input) {
+		if len(word) > maxTokenLength && isASCII(word) && !strings.Contains(word, "://") {
+			return &ScreenFlag{Reason: ScreenReasonEncoded, Detail: fmt.Sprintf("%d-character word", len(word))}
+		}
+	}
+
+	if bits, n := asciiEntropy(input); n >= minEntropyRunes {
+		if bits > maxEntropyBits {
+			return &ScreenFlag{Reason: ScreenReasonEncoded, Detail: fmt.Sprintf("entropy %.2f bits per character", bits)}
+		}
+		if bits < minEntropyBits {
+			return &ScreenFlag{Reason: ScreenReasonRepetitive, Detail: fmt.Sprintf("entropy %.2f bits per character", bits)}
+		}
+	}
+
+	return nil
+}
+
+// asciiEntropy returns the Shannon entropy in bits per character of the
+// input's printable, non-space ASCII characters, and how many there were
+func asciiEntropy(input string) (float64, int) {
+	var counts [128]int
+	n := 0
+	for _, r := range input {
+		if r < 128 && unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			counts[r]++
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+
+	var bits float64
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / float64(n)
+			bits -= p * math.Log2(p)
+		}
+	}
+	return bits, n
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 128 {
+			return false
+		}
+	}
+	return true
+}
+
+// Moderator checks text against a content policy, returning the categories
+// it violates; none means the text is allowed
+type Moderator interface {
+	Moderate(ctx context.Context, input string) ([]string, error)
+}
+
+const (
+	// DefaultModerationModel is OpenAI's current moderation model
+	DefaultModerationModel = "omni-moderation-latest"
+
+	openAIModerationURL = "https://api.openai.com/v1/moderations"
+)
+
+// OpenAIModerator checks text with OpenAI's moderation endpoint, which is
+// free to call
+type OpenAIModerator struct {
+	apiKey string
+	model  string
+	url    string
+	client *http.Client
+}
+
+// NewOpenAIModerator creates a moderator using the given OpenAI API key
+func NewOpenAIModerator(apiKey string) *OpenAIModerator {
+	return &OpenAIModerator{
+		apiKey: apiKey,
+		model:  DefaultModerationModel,
+		url:    openAIModerationURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Moderate implements Moderator
+func (m *OpenAIModerator) Moderate(ctx context.Context, input string) ([]string, error) {
+	body, err := json.Marshal(map[string]string{"model": m.model, "input": input})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("moderation returned status code: %d: %s", resp.StatusCode, detail)
+	}
+
+	var result struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation response: %w", err)
+	}
+
+	var flagged []string
+	for _, r := range result.Results {
+		if !r.Flagged {
+			continue
+		}
+		for category, hit := range r.Categories {
+			if hit {
+				flagged = append(flagged, category)
+			}
+		}
+		if len(flagged) == 0 {
+			flagged = append(flagged, "flagged")
+		}
+	}
+	sort.Strings(flagged)
+	return flagged, nil
+}
+
+// ModeratorFromEnv creates the moderator AI_MODERATION selects: "openai" for
+// OpenAI's moderation endpoint (needs OPENAI_API_KEY, whichever provider
+// generates), or unset/"off" for none, which returns nil
+func ModeratorFromEnv() (Moderator, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("AI_MODERATION"))) {
+	case "", "off", "false":
+		return nil, nil
+	case ProviderOpenAI:
+		key := os.Getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, errors.New("AI_MODERATION=openai requires OPENAI_API_KEY")
+		}
+		return NewOpenAIModerator(key), nil
+	default:
+		return nil, fmt.Errorf("invalid AI_MODERATION %q: must be openai or off", os.Getenv("AI_MODERATION"))
+	}
+}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openmeet-team/survey/internal/models"
+)
+
+// QuestionProgress reports a question the model has finished writing while
+// a generation streams
+type QuestionProgress struct {
+	Number int    // 1-based position in the survey
+	Text   string // Question text as the model wrote it, before sanitization
+}
+
+// ProgressFunc receives streaming progress. It is called on the generating
+// goroutine, so it should return quickly.
+type ProgressFunc func(QuestionProgress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes Generate and GenerateRaw stream
+// the provider's output, calling progress as each question's text arrives.
+// The result is the same as without streaming. When the request falls back
+// to another provider, or the model is re-prompted to fix invalid output,
+// numbering starts over.
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// progressFrom returns the ProgressFunc set by WithProgress, or nil
+func progressFrom(ctx context.Context) ProgressFunc {
+	progress, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return progress
+}
+
+// questionScanner picks question texts out of survey JSON as it streams in.
+// It tracks just enough JSON structure to find the "text" of each object in
+// the top-level "questions" array, ignoring anything outside it (like a
+// markdown fence).
+type questionScanner struct {
+	onQuestion func(QuestionProgress)
+
+	stack     []scanFrame
+	inString  bool
+	escaped   bool
+	isKey     bool
+	str       strings.Builder
+	questions int
+}
+
+// scanFrame is an open JSON object or array
+type scanFrame struct {
+	array     bool
+	key       string // object: the key of the current member
+	expectKey bool   // object: the next string is a key
+}
+
+func newQuestionScanner(onQuestion func(QuestionProgress)) *questionScanner {
+	return &questionScanner{onQuestion: onQuestion}
+}
+
+// Write feeds the next chunk of model output to the scanner
+func (s *questionScanner) Write(chunk string) {
+	for i := 0; i < len(chunk); i++ {
+		c := chunk[i]
+		if s.inString {
+			s.str.WriteByte(c)
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				s.inString = false
+				s.endString()
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			s.inString = true
+			s.isKey = len(s.stack) > 0 && !s.top().array && s.top().expectKey
+			s.str.Reset()
+			s.str.WriteByte(c)
+		case '{':
+			if s.inQuestions() {
+				s.questions++
+			}
+			s.stack = append(s.stack, scanFrame{expectKey: true})
+		case '[':
+			s.stack = append(s.stack, scanFrame{array: true})
+		case '}', ']':
+			if len(s.stack) > 0 {
+				s.stack = s.stack[:len(s.stack)-1]
+			}
+		case ',':
+			if len(s.stack) > 0 && !s.top().array {
+				s.top().expectKey = true
+			}
+		case ':':
+			if len(s.stack) > 0 && !s.top().array {
+				s.top().expectKey = false
+			}
+		}
+	}
+}
+
+// endString handles a complete string token
+func (s *questionScanner) endString() {
+	var value string
+	if err := json.Unmarshal([]byte(s.str.String()), &value); err != nil {
+		return
+	}
+
+	if s.isKey {
+		s.top().key = value
+		return
+	}
+	// A question's text: root object > "questions" array > question object
+	if len(s.stack) == 3 && s.stack[0].key == "questions" && s.stack[2].key == "text" {
+		s.onQuestion(QuestionProgress{Number: s.questions, Text: value})
+	}
+}
+
+// inQuestions reports whether the scanner is directly inside the questions array
+func (s *questionScanner) inQuestions() bool {
+	return len(s.stack) == 2 && s.stack[0].key == "questions" && s.stack[1].array
+}
+
+func (s *questionScanner) top() *scanFrame {
+	return &s.stack[len(s.stack)-1]
+}
+
+// questionCountPattern finds a requested number of questions, e.g. "5 questions"
+var questionCountPattern = regexp.MustCompile(`(?i)\b(\d{1,2})\s+(?:\w+\s+)?questions?\b`)
+
+// EstimateQuestionCount guesses how many questions a generation will write,
+// for showing progress: the number of questions the description asks for,
+// else the existing survey's count when refining. Zero means no estimate.
+func EstimateQuestionCount(description string, existing *models.SurveyDefinition) int {
+	if match := questionCountPattern.FindStringSubmatch(description); match != nil {
+		if n, err := strconv.Atoi(match[1]); err == nil && n > 0 && n <= 50 {
+			return n
+		}
+	}
+
+	if existing != nil {
+		return len(existing.Questions)
+	}
+	return 0
+}
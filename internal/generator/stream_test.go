@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanQuestions(chunks ...string) []QuestionProgress {
+	var progress []QuestionProgress
+	scanner := newQuestionScanner(func(p QuestionProgress) { progress = append(progress, p) })
+	for _, chunk := range chunks {
+		scanner.Write(chunk)
+	}
+	return progress
+}
+
+func TestQuestionScanner(t *testing.T) {
+	twoQuestions := `{"questions":[` +
+		`{"id":"q1","text":"Favorite \"pizza\"?","type":"single","options":[{"id":"opt1","text":"Margherita"},{"id":"opt2","text":"Pepperoni"}]},` +
+		`{"id":"q2","type":"text","text":"Anything else? é","options":[]}` +
+		`],"anonymous":false}`
+	want := []QuestionProgress{
+		{Number: 1, Text: `Favorite "pizza"?`},
+		{Number: 2, Text: "Anything else? é"},
+	}
+
+	t.Run("whole response", func(t *testing.T) {
+		assert.Equal(t, want, scanQuestions(twoQuestions))
+	})
+
+	t.Run("one byte at a time", func(t *testing.T) {
+		var chunks []string
+		for i := range len(twoQuestions) {
+			chunks = append(chunks, twoQuestions[i:i+1])
+		}
+		assert.Equal(t, want, scanQuestions(chunks...))
+	})
+
+	t.Run("inside a code fence", func(t *testing.T) {
+		assert.Equal(t, want, scanQuestions("```json\n", twoQuestions, "\n```"))
+	})
+
+	t.Run("reports a question when its text is complete", func(t *testing.T) {
+		var progress []QuestionProgress
+		scanner := newQuestionScanner(func(p QuestionProgress) { progress = append(progress, p) })
+		scanner.Write(`{"questions":[{"id":"q1","text":"Do you like`)
+		assert.Empty(t, progress)
+		scanner.Write(` pizza?","type":"single"`)
+		assert.Equal(t, []QuestionProgress{{Number: 1, Text: "Do you like pizza?"}}, progress)
+	})
+
+	t.Run("ignores text outside questions", func(t *testing.T) {
+		assert.Empty(t, scanQuestions(`{"text":"not a question","other":[{"text":"nor this"}]}`))
+	})
+}
+
+func TestEstimateQuestionCount(t *testing.T) {
+	existing := &models.SurveyDefinition{Questions: []models.Question{{ID: "q1"}, {ID: "q2"}, {ID: "q3"}}}
+
+	assert.Equal(t, 6, EstimateQuestionCount("A 6 question survey about lunch", nil))
+	assert.Equal(t, 5, EstimateQuestionCount("Ask 5 short questions about pizza", nil))
+	assert.Equal(t, 3, EstimateQuestionCount("Make the tone friendlier", existing))
+	assert.Equal(t, 4, EstimateQuestionCount("Expand it to 4 questions", existing))
+	assert.Equal(t, 0, EstimateQuestionCount("A poll about pizza", nil))
+	assert.Equal(t, 0, EstimateQuestionCount("Ask 500 questions", nil))
+}
+
+func TestSurveyGenerator_StreamsProgress(t *testing.T) {
+	llm := &usageLLM{content: pizzaPollJSON, info: map[string]any{"PromptTokens": 900, "CompletionTokens": 80}}
+	gen := NewSurveyGeneratorWithProvider(NewLLMProvider(ProviderOpenAI, "gpt-4o-mini", llm, OpenAIPricing["gpt-4o-mini"]))
+
+	var progress []QuestionProgress
+	ctx := WithProgress(context.Background(), func(p QuestionProgress) { progress = append(progress, p) })
+
+	result, err := gen.Generate(ctx, "Create a pizza poll")
+	require.NoError(t, err)
+	assert.Equal(t, []QuestionProgress{{Number: 1, Text: "Do you like pizza?"}}, progress)
+
+	// The streamed result is validated and accounted like any other
+	require.NotNil(t, result.Definition)
+	assert.Equal(t, "Do you like pizza?", result.Definition.Questions[0].Text)
+	assert.Equal(t, 900, result.InputTokens)
+	assert.Equal(t, 80, result.OutputTokens)
+	assert.InDelta(t, OpenAIPricing["gpt-4o-mini"].Cost(900, 80), result.EstimatedCost, 1e-12)
+	assert.NotNil(t, llm.options.StreamingFunc)
+}
+
+func TestSurveyGenerator_DoesNotStreamWithoutProgress(t *testing.T) {
+	llm := &usageLLM{content: pizzaPollJSON}
+	gen := NewSurveyGeneratorWithProvider(NewLLMProvider(ProviderOpenAI, "gpt-4o-mini", llm, OpenAIPricing["gpt-4o-mini"]))
+
+	_, err := gen.Generate(context.Background(), "Create a pizza poll")
+	require.NoError(t, err)
+	assert.Nil(t, llm.options.StreamingFunc)
+}
@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const coffeeSummaryJSON = `{"overview":"People want better coffee.","themes":[{"theme":"Coffee","summary":"The office coffee is bad.","count":4},{"theme":"Snacks","summary":"More snacks.","count":1}]}`
+
+func coffeeAnswers(n int) []string {
+	answers := make([]string, n)
+	for i := range answers {
+		answers[i] = fmt.Sprintf("Answer %d: the coffee could be better", i+1)
+	}
+	return answers
+}
+
+func TestSurveyGenerator_Summarize(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("summarizes answers in one call", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{coffeeSummaryJSON}}
+		gen := NewSurveyGeneratorWithProvider(provider)
+
+		result, err := gen.Summarize(ctx, "What would you change?", coffeeAnswers(5))
+		require.NoError(t, err)
+		require.NotNil(t, result.Summary)
+		assert.Equal(t, "People want better coffee.", result.Summary.Overview)
+		require.Len(t, result.Summary.Themes, 2)
+		assert.Equal(t, 4, result.Summary.Themes[0].Count)
+		assert.Nil(t, result.Definition)
+		assert.Equal(t, 100, result.InputTokens)
+		assert.Equal(t, ProviderOpenAI, result.Provider)
+
+		require.Len(t, provider.prompts, 1)
+		assert.Contains(t, provider.prompts[0], "<question>\nWhat would you change?\n</question>")
+		assert.Contains(t, provider.prompts[0], "<answer>Answer 5: the coffee could be better</answer>")
+	})
+
+	t.Run("short-circuits with too few answers", func(t *testing.T) {
+		provider := &respondingInOrder{}
+		_, err := NewSurveyGeneratorWithProvider(provider).Summarize(ctx, "What would you change?", coffeeAnswers(4))
+		require.ErrorIs(t, err, ErrNotEnoughAnswers)
+		assert.Empty(t, provider.prompts, "no provider call")
+	})
+
+	t.Run("chunks answers over the token limit and merges the chunks", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{coffeeSummaryJSON, coffeeSummaryJSON, coffeeSummaryJSON, coffeeSummaryJSON}}
+		gen := NewSurveyGeneratorWithProvider(provider)
+		// Room for the system prompt and a few answers per chunk
+		gen.SetMaxInputTokens(CountTokens(ProviderOpenAI, buildSummarySystemPrompt()) + summaryPromptOverhead + 60)
+
+		result, err := gen.Summarize(ctx, "What would you change?", coffeeAnswers(8))
+		require.NoError(t, err)
+		require.Greater(t, len(provider.prompts), 2, "at least two chunks and a merge")
+
+		answered := 0
+		for _, prompt := range provider.prompts[:len(provider.prompts)-1] {
+			answered += strings.Count(prompt, "<answer>")
+		}
+		assert.Equal(t, 8, answered, "every answer is in one chunk")
+		assert.Contains(t, provider.prompts[len(provider.prompts)-1], "<summaries>")
+		assert.Equal(t, 100*len(provider.prompts), result.InputTokens, "usage covers every call")
+	})
+
+	t.Run("rejects output that isn't a summary", func(t *testing.T) {
+		provider := &respondingInOrder{responses: []string{`{"overview":"Nothing","themes":[]}`}}
+		result, err := NewSurveyGeneratorWithProvider(provider).Summarize(ctx, "What would you change?", coffeeAnswers(5))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid LLM output")
+		require.NotNil(t, result, "returned for logging")
+		assert.Equal(t, `{"overview":"Nothing","themes":[]}`, result.RawResponse)
+	})
+
+	t.Run("falls back when the provider is unavailable", func(t *testing.T) {
+		primary := failing(ProviderOpenAI, errors.New("API returned unexpected status code: 503"))
+		gen := NewSurveyGeneratorWithProvider(primary, answering(ProviderAnthropic, coffeeSummaryJSON))
+
+		result, err := gen.Summarize(ctx, "What would you change?", coffeeAnswers(5))
+		require.NoError(t, err)
+		assert.Equal(t, ProviderAnthropic, result.Provider)
+		assert.Equal(t, ProviderOpenAI, result.FallbackFrom)
+		assert.Len(t, result.FailedAttempts, 1)
+	})
+}
+
+func TestTruncateRunes(t *testing.T) {
+	assert.Equal(t, "short", truncateRunes("short", 10))
+	assert.Equal(t, "héll…", truncateRunes("héllo wörld", 4))
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+const (
+	// DefaultMaxInputTokens is the largest prompt, system prompt included,
+	// sent to a provider. The system prompt is about 500 tokens and a
+	// description at most ~600; refinements add the current survey's JSON.
+	DefaultMaxInputTokens = 16000
+
+	// tokensPerMessage is the chat framing each message adds: role and
+	// separators. The reply is primed with as many again.
+	tokensPerMessage = 3
+)
+
+// ErrTooManyInputTokens is returned, before calling a provider, when a
+// prompt's estimated input tokens exceed the generator's limit
+var ErrTooManyInputTokens = errors.New("prompt exceeds input token limit")
+
+// MaxInputTokensFromEnv reads AI_MAX_INPUT_TOKENS (default 16000). 0 turns
+// the limit off.
+func MaxInputTokensFromEnv() int {
+	if v := os.Getenv("AI_MAX_INPUT_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return DefaultMaxInputTokens
+}
+
+// tokenizer is OpenAI's cl100k_base encoding once LoadTokenizer loads it
+var tokenizer atomic.Pointer[tiktoken.Tiktoken]
+
+// LoadTokenizer loads the cl100k_base encoding used to count OpenAI tokens.
+// Its ranks are downloaded on first use and cached in TIKTOKEN_CACHE_DIR (the
+// temp directory by default). Until it loads, or if it fails, CountTokens
+// approximates OpenAI tokens too.
+func LoadTokenizer() error {
+	encoding, err := tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+	if err != nil {
+		return err
+	}
+	tokenizer.Store(encoding)
+	return nil
+}
+
+// CountTokens estimates how many tokens provider's model reads for text.
+// OpenAI text is counted with tiktoken when the tokenizer is loaded; other
+// providers don't publish their tokenizers, so their text is approximated.
+func CountTokens(provider, text string) int {
+	if provider == ProviderOpenAI {
+		if encoding := tokenizer.Load(); encoding != nil {
+			return len(encoding.EncodeOrdinary(text))
+		}
+	}
+	return estimateTokens(text)
+}
+
+// countInputTokens estimates the input tokens of req for provider, counting
+// the chat framing of the system and user messages
+func countInputTokens(provider string, req GenerationRequest) int {
+	return CountTokens(provider, req.SystemPrompt) + CountTokens(provider, req.Prompt) + 3*tokensPerMessage
+}
+
+// pretokenizer splits text the way cl100k_base does before merging byte
+// pairs, less its lookahead for trailing whitespace
+var pretokenizer = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// estimateTokens approximates a token count without a tokenizer. It splits
+// text like cl100k_base and guesses the tokens in each piece: one for a word
+// of up to 8 ASCII letters, and one per 8 letters after; one per non-ASCII
+// letter; one per number of up to 3 digits; one per 2 punctuation
+// characters. English prose lands within ~15% of cl100k_base.
+func estimateTokens(text string) int {
+	tokens := 0
+	for _, piece := range pretokenizer.FindAllString(text, -1) {
+		tokens += pieceTokens(piece)
+	}
+	return tokens
+}
+
+// pieceTokens guesses the tokens in one pretokenizer piece
+func pieceTokens(piece string) int {
+	// A leading space or punctuation character usually joins what follows
+	if r, size := utf8.DecodeRuneInString(piece); size < len(piece) && !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+		if next, _ := utf8.DecodeRuneInString(piece[size:]); !unicode.IsSpace(next) {
+			piece = piece[size:]
+		}
+	}
+
+	first, _ := utf8.DecodeRuneInString(piece)
+	switch {
+	case unicode.IsSpace(first), unicode.IsNumber(first):
+		return 1
+	case unicode.IsLetter(first):
+		ascii, other := 0, 0
+		for _, r := range piece {
+			if r < utf8.RuneSelf {
+				ascii++
+			} else {
+				other++
+			}
+		}
+		return (ascii+7)/8 + other
+	default:
+		return (utf8.RuneCountInString(piece) + 1) / 2
+	}
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	// Where cl100k_base's count is the same, it's noted
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"empty", "", 0},
+		{"two words", "Hello world", 2},                 // cl100k_base: 2
+		{"description", "Create a poll about pizza", 5}, // cl100k_base: 5
+		{"sentence", "This is a test prompt with about twenty words in it for testing purposes and validation.", 18}, // cl100k_base: 18
+		{"contraction", "don't stop", 3},        // cl100k_base: 3
+		{"lines", "line one\nline two", 5},      // cl100k_base: 5
+		{"JSON", `{"id":"q1"}`, 6},              // cl100k_base: 6
+		{"numbers split in threes", "12345", 2}, // cl100k_base: 2
+		{"long word", "internationalization", 3},
+		{"non-Latin script", "请创建一个调查", 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, estimateTokens(tt.input))
+		})
+	}
+}
+
+// stubBpeLoader serves byte-pair ranks for the cl100k_base encoding without
+// downloading them: every byte, and merges up to "hello" and " world"
+type stubBpeLoader struct{}
+
+func (stubBpeLoader) LoadTiktokenBpe(string) (map[string]int, error) {
+	ranks := make(map[string]int)
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+	for _, merge := range []string{"he", "ll", "hell", "hello", " w", "or", "ld", " wor", " world"} {
+		ranks[merge] = len(ranks)
+	}
+	return ranks, nil
+}
+
+func TestCountTokens_Tiktoken(t *testing.T) {
+	tiktoken.SetBpeLoader(stubBpeLoader{})
+	t.Cleanup(func() {
+		tiktoken.SetBpeLoader(tiktoken.NewDefaultBpeLoader())
+		tokenizer.Store(nil)
+	})
+
+	// Until the tokenizer loads, OpenAI tokens are approximated
+	assert.Equal(t, estimateTokens("hello there"), CountTokens(ProviderOpenAI, "hello there"))
+
+	require.NoError(t, LoadTokenizer())
+	assert.Equal(t, 2, CountTokens(ProviderOpenAI, "hello world"))
+	assert.Equal(t, 6, CountTokens(ProviderOpenAI, "hello there"), "\" there\" only merges \"he\"")
+
+	// Other providers are still approximated
+	assert.Equal(t, 2, CountTokens(ProviderAnthropic, "hello there"))
+}
+
+func TestSurveyGenerator_MaxInputTokens(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rejects oversized prompts without calling the provider", func(t *testing.T) {
+		provider := answering(ProviderOpenAI, pizzaPollJSON)
+		gen := NewSurveyGeneratorWithProvider(provider)
+		gen.SetMaxInputTokens(600)
+
+		result, err := gen.GenerateRaw(ctx, strings.Repeat("Ask about pizza toppings. ", 40))
+		require.ErrorIs(t, err, ErrTooManyInputTokens)
+		assert.Contains(t, err.Error(), "limit 600")
+		assert.Equal(t, 0, provider.calls)
+
+		// The estimate is returned for logging
+		require.NotNil(t, result)
+		assert.Greater(t, result.InputTokens, 600)
+		assert.Equal(t, result.InputTokens, result.EstimatedInputTokens)
+		assert.Equal(t, ProviderOpenAI, result.Provider)
+	})
+
+	t.Run("records the estimate beside the provider's count", func(t *testing.T) {
+		gen := NewSurveyGeneratorWithProvider(answering(ProviderOpenAI, pizzaPollJSON))
+
+		result, err := gen.Generate(ctx, "Create a poll about pizza")
+		require.NoError(t, err)
+		assert.Equal(t, 100, result.InputTokens)
+		want := estimateTokens(gen.buildSystemPrompt().Text) + estimateTokens("Create a poll about pizza") + 3*tokensPerMessage
+		assert.Equal(t, want, result.EstimatedInputTokens)
+	})
+
+	t.Run("0 turns the limit off", func(t *testing.T) {
+		gen := NewSurveyGeneratorWithProvider(answering(ProviderOpenAI, pizzaPollJSON))
+		gen.SetMaxInputTokens(0)
+
+		_, err := gen.GenerateRaw(ctx, strings.Repeat("Ask about pizza toppings. ", 4000))
+		assert.NoError(t, err)
+	})
+}
+
+func TestMaxInputTokensFromEnv(t *testing.T) {
+	t.Setenv("AI_MAX_INPUT_TOKENS", "")
+	assert.Equal(t, DefaultMaxInputTokens, MaxInputTokensFromEnv())
+
+	t.Setenv("AI_MAX_INPUT_TOKENS", "4000")
+	assert.Equal(t, 4000, MaxInputTokensFromEnv())
+
+	t.Setenv("AI_MAX_INPUT_TOKENS", "0")
+	assert.Equal(t, 0, MaxInputTokensFromEnv())
+
+	t.Setenv("AI_MAX_INPUT_TOKENS", "lots")
+	assert.Equal(t, DefaultMaxInputTokens, MaxInputTokensFromEnv())
+}
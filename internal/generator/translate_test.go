@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSurveyGenerator_Translate(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns the survey with translated text", func(t *testing.T) {
+		// The model also made the first question required; only text is kept
+		output := `{"questions":[
+			{"id":"q1","text":"¿Te gusta la pizza?","type":"single","required":true,"options":[{"id":"opt1","text":"Sí"},{"id":"opt2","text":"No"}]},
+			{"id":"q2","text":"¿Algún comentario?","type":"text","required":false}
+		],"anonymous":false}`
+		gen := NewSurveyGeneratorWithProvider(answering(ProviderOpenAI, output))
+
+		result, err := gen.Translate(ctx, pizzaSurvey(), "es")
+		require.NoError(t, err)
+		want := pizzaSurvey()
+		want.Questions[0].Text = "¿Te gusta la pizza?"
+		want.Questions[0].Options[0].Text = "Sí"
+		want.Questions[1].Text = "¿Algún comentario?"
+		assert.Equal(t, want, result.Definition)
+		assert.Contains(t, result.SystemPrompt, `BCP-47 tag "es"`)
+		assert.Nil(t, result.Diff)
+	})
+
+	t.Run("rejects a dropped option", func(t *testing.T) {
+		output := `{"questions":[
+			{"id":"q1","text":"¿Te gusta la pizza?","type":"single","required":false,"options":[{"id":"opt1","text":"Sí"},{"id":"opt3","text":"Más o menos"}]},
+			{"id":"q2","text":"¿Algún comentario?","type":"text","required":false}
+		],"anonymous":false}`
+		gen := NewSurveyGeneratorWithProvider(answering(ProviderOpenAI, output))
+
+		result, err := gen.Translate(ctx, pizzaSurvey(), "es")
+		require.ErrorIs(t, err, ErrStructureChanged)
+		assert.Contains(t, err.Error(), "question 0, option 1: ID 'opt3' instead of 'opt2'")
+		require.NotNil(t, result, "returned for logging")
+		assert.Nil(t, result.Definition)
+		assert.Equal(t, output, result.RawResponse)
+	})
+}
+
+func TestApplyTranslation(t *testing.T) {
+	rating := func(labels ...string) *models.SurveyDefinition {
+		return &models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Rate it", Type: models.QuestionTypeRating, Min: 1, Max: 3, Labels: labels},
+		}}
+	}
+
+	t.Run("translates rating labels", func(t *testing.T) {
+		original := rating("Bad", "OK", "Good")
+		translated, err := applyTranslation(original, rating("Mal", "Regular", "Bien"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Mal", "Regular", "Bien"}, translated.Questions[0].Labels)
+		assert.Equal(t, []string{"Bad", "OK", "Good"}, original.Questions[0].Labels, "the original is unchanged")
+	})
+
+	drift := map[string]struct {
+		change func(def *models.SurveyDefinition)
+		want   string
+	}{
+		"added question": {
+			func(def *models.SurveyDefinition) {
+				def.Questions = append(def.Questions, models.Question{ID: "q3", Text: "¿Y?", Type: models.QuestionTypeText})
+			},
+			"3 questions instead of 2",
+		},
+		"dropped question": {
+			func(def *models.SurveyDefinition) { def.Questions = def.Questions[:1] },
+			"1 questions instead of 2",
+		},
+		"reordered questions": {
+			func(def *models.SurveyDefinition) {
+				def.Questions[0], def.Questions[1] = def.Questions[1], def.Questions[0]
+			},
+			"question 0: ID 'q2' instead of 'q1'",
+		},
+		"changed type": {
+			func(def *models.SurveyDefinition) { def.Questions[0].Type = models.QuestionTypeMulti },
+			"question 0: type 'multi' instead of 'single'",
+		},
+		"added option": {
+			func(def *models.SurveyDefinition) {
+				def.Questions[0].Options = append(def.Questions[0].Options, models.Option{ID: "opt3", Text: "Quizás"})
+			},
+			"question 0: 3 options instead of 2",
+		},
+		"renamed option": {
+			func(def *models.SurveyDefinition) { def.Questions[0].Options[1].ID = "no" },
+			"question 0, option 1: ID 'no' instead of 'opt2'",
+		},
+	}
+	for name, tt := range drift {
+		t.Run(name, func(t *testing.T) {
+			translated := pizzaSurvey()
+			tt.change(translated)
+
+			_, err := applyTranslation(pizzaSurvey(), translated)
+			require.ErrorIs(t, err, ErrStructureChanged)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+
+	t.Run("dropped label", func(t *testing.T) {
+		_, err := applyTranslation(rating("Bad", "OK", "Good"), rating("Mal", "Bien"))
+		require.ErrorIs(t, err, ErrStructureChanged)
+		assert.Contains(t, err.Error(), "question 0: 2 labels instead of 3")
+	})
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxCommentLength caps the text of a comment, in bytes
+const MaxCommentLength = 2000
+
+// Comment is free-form feedback about a survey itself, published by a
+// responder as a net.openmeet.survey.comment record. It isn't an answer and
+// doesn't count as a vote.
+type Comment struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	SurveyID  uuid.UUID `db:"survey_id" json:"surveyId"`
+	AuthorDID string    `db:"author_did" json:"authorDid"`
+	RecordURI string    `db:"record_uri" json:"recordUri"`
+	RecordCID string    `db:"record_cid" json:"recordCid"`
+	Text      string    `db:"text" json:"text"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
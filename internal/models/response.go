@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
@@ -20,12 +21,22 @@ type Response struct {
 	RecordCID    *string           `db:"record_cid" json:"recordCid,omitempty"`
 	Answers      map[string]Answer `db:"answers" json:"answers"`
 	CreatedAt    time.Time         `db:"created_at" json:"createdAt"`
+
+	// DedupKey identifies "the same response" within a survey for ATProto
+	// voters; see ResponseDedupKey. Nil for web guests, who are deduplicated
+	// by VoterSession.
+	DedupKey *string `db:"dedup_key" json:"-"`
 }
 
 // Answer represents a response to a single question
 type Answer struct {
 	SelectedOptions []string `json:"selectedOptions,omitempty"`
 	Text            string   `json:"text,omitempty"`
+	Rating          *int     `json:"rating,omitempty"` // for rating questions; pointer since 0 is a valid rating
+
+	// OtherText holds free text for selected options that allow it, keyed by
+	// option ID
+	OtherText map[string]string `json:"otherText,omitempty"`
 }
 
 // GenerateVoterSession creates a SHA256 hash for anonymous voter identification
@@ -36,6 +47,32 @@ func GenerateVoterSession(surveyID uuid.UUID, ip string, userAgent string) strin
 	return hex.EncodeToString(hash[:])
 }
 
+// ResponseDedupKey returns the key under which an ATProto voter's response to
+// a survey is stored. A later response with the same key replaces the earlier
+// one's answers instead of being counted again.
+//
+//   - Default: "did:<voterDID>", one response per voter; re-publishing
+//     replaces the previous answers.
+//   - AllowMultipleResponses: "record:<recordURI>", one response per record;
+//     only an update to the same rkey replaces answers.
+//   - Anonymous: "anon:<hash>", still one response per voter, but the key is a
+//     per-survey salted hash of the DID (like GenerateVoterSession) so it
+//     can't be used to link a voter's responses across anonymous surveys.
+//
+// AllowMultipleResponses takes precedence over Anonymous, since record URIs
+// already differ per survey.
+func ResponseDedupKey(def *SurveyDefinition, surveyID uuid.UUID, voterDID, recordURI string) string {
+	switch {
+	case def.AllowMultipleResponses:
+		return "record:" + recordURI
+	case def.Anonymous:
+		hash := sha256.Sum256([]byte(surveyID.String() + ":" + voterDID))
+		return "anon:" + hex.EncodeToString(hash[:])
+	default:
+		return "did:" + voterDID
+	}
+}
+
 // ValidateAnswers validates that the answers are valid for the survey definition
 func ValidateAnswers(def *SurveyDefinition, answers map[string]Answer) error {
 	// Create a map of question IDs for quick lookup
@@ -76,11 +113,27 @@ func ValidateAnswers(def *SurveyDefinition, answers map[string]Answer) error {
 				return fmt.Errorf("question '%s': %w", question.ID, err)
 			}
 		case QuestionTypeText:
+			if len(answer.OtherText) > 0 {
+				return fmt.Errorf("question '%s': free text is only allowed on choice options", question.ID)
+			}
 			if err := validateTextAnswer(&question, &answer); err != nil {
 				return fmt.Errorf("question '%s': %w", question.ID, err)
 			}
 			// Write back the sanitized answer
 			answers[question.ID] = answer
+		case QuestionTypeRating:
+			if len(answer.OtherText) > 0 {
+				return fmt.Errorf("question '%s': free text is only allowed on choice options", question.ID)
+			}
+			if err := validateRatingAnswer(&question, &answer); err != nil {
+				return fmt.Errorf("question '%s': %w", question.ID, err)
+			}
+		}
+
+		if question.Type == QuestionTypeSingle || question.Type == QuestionTypeMulti {
+			if err := validateOtherText(&question, &answer); err != nil {
+				return fmt.Errorf("question '%s': %w", question.ID, err)
+			}
 		}
 	}
 
@@ -121,6 +174,51 @@ func validateMultiChoice(question *Question, answer *Answer) error {
 		}
 	}
 
+	if question.MaxSelections > 0 && len(answer.SelectedOptions) > question.MaxSelections {
+		return fmt.Errorf("at most %d options may be selected, got %d", question.MaxSelections, len(answer.SelectedOptions))
+	}
+
+	return nil
+}
+
+// errOtherTextTooLong is wrapped by validateOtherText for text over MaxOtherTextLength
+var errOtherTextTooLong = fmt.Errorf("free text exceeds maximum length of %d characters", MaxOtherTextLength)
+
+// validateOtherText checks that free text is only given for selected options
+// that allow it, sanitizing it in place. Blank entries are dropped.
+func validateOtherText(question *Question, answer *Answer) error {
+	if len(answer.OtherText) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(question.Options))
+	for _, opt := range question.Options {
+		allowed[opt.ID] = opt.AllowFreeText
+	}
+	selected := make(map[string]bool, len(answer.SelectedOptions))
+	for _, id := range answer.SelectedOptions {
+		selected[id] = true
+	}
+
+	for optionID, text := range answer.OtherText {
+		if !allowed[optionID] {
+			return fmt.Errorf("option '%s' does not allow free text", optionID)
+		}
+		if !selected[optionID] {
+			return fmt.Errorf("free text given for unselected option '%s'", optionID)
+		}
+
+		text = SanitizeText(text)
+		if text == "" {
+			delete(answer.OtherText, optionID)
+			continue
+		}
+		if utf8.RuneCountInString(text) > MaxOtherTextLength {
+			return fmt.Errorf("option '%s': %w", optionID, errOtherTextTooLong)
+		}
+		answer.OtherText[optionID] = text
+	}
+
 	return nil
 }
 
@@ -134,13 +232,116 @@ func validateTextAnswer(question *Question, answer *Answer) error {
 	}
 
 	// Check length limit
-	if len(answer.Text) > MaxTextAnswerLength {
+	if utf8.RuneCountInString(answer.Text) > MaxTextAnswerLength {
 		return fmt.Errorf("text answer exceeds maximum length of %d characters", MaxTextAnswerLength)
 	}
 
 	return nil
 }
 
+func validateRatingAnswer(question *Question, answer *Answer) error {
+	if answer.Rating == nil {
+		return errors.New("rating question must have a rating")
+	}
+	if *answer.Rating < question.Min || *answer.Rating > question.Max {
+		return fmt.Errorf("rating %d is outside %d-%d", *answer.Rating, question.Min, question.Max)
+	}
+	return nil
+}
+
+// Reasons an ingested response is rejected, reported in AnswerError.Reason
+const (
+	RejectUnknownQuestion    = "unknown_question"
+	RejectUnknownOption      = "unknown_option"
+	RejectMultipleSelections = "multiple_selections"
+	RejectTooManySelections  = "too_many_selections"
+	RejectWrongAnswerType    = "wrong_answer_type"
+	RejectTextTooLong        = "text_too_long"
+	RejectRatingOutOfRange   = "rating_out_of_range"
+	RejectUnexpectedOther    = "unexpected_other_text"
+)
+
+// AnswerError describes why an answer in an ingested response was rejected
+type AnswerError struct {
+	Reason     string // one of the Reject* constants
+	QuestionID string
+	Detail     string
+}
+
+func (e *AnswerError) Error() string {
+	return fmt.Sprintf("question '%s': %s (%s)", e.QuestionID, e.Detail, e.Reason)
+}
+
+// ValidateIngestedAnswers validates answers from a response record written to
+// a PDS by any client. Every answer must match a question in the definition
+// and carry the right kind of value for it. Unlike ValidateAnswers, missing
+// required answers are accepted: the record already exists on the network and
+// an incomplete response is the responder's problem, not a malformed record.
+// Returns an *AnswerError; text answers are sanitized in place.
+func ValidateIngestedAnswers(def *SurveyDefinition, answers map[string]Answer) error {
+	questionMap := make(map[string]*Question, len(def.Questions))
+	for i := range def.Questions {
+		questionMap[def.Questions[i].ID] = &def.Questions[i]
+	}
+
+	for questionID, answer := range answers {
+		question, exists := questionMap[questionID]
+		if !exists {
+			return &AnswerError{Reason: RejectUnknownQuestion, QuestionID: questionID, Detail: "unknown question ID"}
+		}
+
+		switch question.Type {
+		case QuestionTypeSingle, QuestionTypeMulti:
+			if answer.Text != "" || answer.Rating != nil {
+				return &AnswerError{Reason: RejectWrongAnswerType, QuestionID: questionID, Detail: "non-choice answer on a choice question"}
+			}
+			if question.Type == QuestionTypeSingle && len(answer.SelectedOptions) > 1 {
+				return &AnswerError{Reason: RejectMultipleSelections, QuestionID: questionID, Detail: "single-choice question has more than one option selected"}
+			}
+			if question.MaxSelections > 0 && len(answer.SelectedOptions) > question.MaxSelections {
+				return &AnswerError{Reason: RejectTooManySelections, QuestionID: questionID, Detail: fmt.Sprintf("%d options selected, at most %d allowed", len(answer.SelectedOptions), question.MaxSelections)}
+			}
+
+			validOptions := make(map[string]bool, len(question.Options))
+			for _, opt := range question.Options {
+				validOptions[opt.ID] = true
+			}
+			for _, selected := range answer.SelectedOptions {
+				if !validOptions[selected] {
+					return &AnswerError{Reason: RejectUnknownOption, QuestionID: questionID, Detail: fmt.Sprintf("invalid option '%s'", selected)}
+				}
+			}
+
+			if err := validateOtherText(question, &answer); err != nil {
+				reason := RejectUnexpectedOther
+				if errors.Is(err, errOtherTextTooLong) {
+					reason = RejectTextTooLong
+				}
+				return &AnswerError{Reason: reason, QuestionID: questionID, Detail: err.Error()}
+			}
+		case QuestionTypeText:
+			if len(answer.SelectedOptions) > 0 || answer.Rating != nil || len(answer.OtherText) > 0 {
+				return &AnswerError{Reason: RejectWrongAnswerType, QuestionID: questionID, Detail: "non-text answer on a text question"}
+			}
+
+			answer.Text = SanitizeText(answer.Text)
+			if utf8.RuneCountInString(answer.Text) > MaxTextAnswerLength {
+				return &AnswerError{Reason: RejectTextTooLong, QuestionID: questionID, Detail: fmt.Sprintf("text answer exceeds maximum length of %d characters", MaxTextAnswerLength)}
+			}
+			answers[questionID] = answer
+		case QuestionTypeRating:
+			if len(answer.SelectedOptions) > 0 || answer.Text != "" || len(answer.OtherText) > 0 {
+				return &AnswerError{Reason: RejectWrongAnswerType, QuestionID: questionID, Detail: "non-rating answer on a rating question"}
+			}
+			if answer.Rating != nil && (*answer.Rating < question.Min || *answer.Rating > question.Max) {
+				return &AnswerError{Reason: RejectRatingOutOfRange, QuestionID: questionID, Detail: fmt.Sprintf("rating %d is outside %d-%d", *answer.Rating, question.Min, question.Max)}
+			}
+		}
+	}
+
+	return nil
+}
+
 // Stats represents statistics about the survey service
 type Stats struct {
 	SurveyCount     int `json:"surveyCount"`
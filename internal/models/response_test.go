@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -428,3 +429,348 @@ func TestValidateAnswers_AcceptsTextAnswerAtMaxLength(t *testing.T) {
 	err := ValidateAnswers(def, answers)
 	require.NoError(t, err)
 }
+
+// TestTextAnswerLengthCountsCharacters ensures a text answer at the limit in
+// characters but over it in bytes is accepted, submitted or ingested
+func TestTextAnswerLengthCountsCharacters(t *testing.T) {
+	text := strings.Repeat("日", MaxTextAnswerLength)
+	def := ingestTestDefinition()
+
+	answers := map[string]Answer{"q1": {SelectedOptions: []string{"a"}}, "q3": {Text: text}}
+	require.NoError(t, ValidateAnswers(def, answers))
+	assert.Equal(t, text, answers["q3"].Text)
+
+	answers = map[string]Answer{"q3": {Text: text}}
+	require.NoError(t, ValidateIngestedAnswers(def, answers))
+	assert.Equal(t, text, answers["q3"].Text)
+
+	answers = map[string]Answer{"q3": {Text: text + "日"}}
+	var answerErr *AnswerError
+	require.ErrorAs(t, ValidateIngestedAnswers(def, answers), &answerErr)
+	assert.Equal(t, RejectTextTooLong, answerErr.Reason)
+}
+
+func ingestTestDefinition() *SurveyDefinition {
+	return &SurveyDefinition{
+		Questions: []Question{
+			{
+				ID:       "q1",
+				Text:     "Choose one",
+				Type:     QuestionTypeSingle,
+				Required: true,
+				Options: []Option{
+					{ID: "a", Text: "Option A"},
+					{ID: "b", Text: "Option B"},
+				},
+			},
+			{
+				ID:   "q2",
+				Text: "Choose multiple",
+				Type: QuestionTypeMulti,
+				Options: []Option{
+					{ID: "x", Text: "Option X"},
+					{ID: "y", Text: "Option Y"},
+				},
+			},
+			{
+				ID:   "q3",
+				Text: "Write something",
+				Type: QuestionTypeText,
+			},
+		},
+	}
+}
+
+func TestValidateIngestedAnswers_Valid(t *testing.T) {
+	answers := map[string]Answer{
+		"q1": {SelectedOptions: []string{"a"}},
+		"q2": {SelectedOptions: []string{"x", "y"}},
+		"q3": {Text: "My answer"},
+	}
+
+	err := ValidateIngestedAnswers(ingestTestDefinition(), answers)
+	assert.NoError(t, err)
+}
+
+func TestValidateIngestedAnswers_AllowsMissingRequiredQuestion(t *testing.T) {
+	answers := map[string]Answer{
+		"q2": {SelectedOptions: []string{"x"}},
+	}
+
+	err := ValidateIngestedAnswers(ingestTestDefinition(), answers)
+	assert.NoError(t, err)
+}
+
+func TestValidateIngestedAnswers_SanitizesText(t *testing.T) {
+	answers := map[string]Answer{
+		"q3": {Text: "<script>alert('xss')</script>Hello"},
+	}
+
+	err := ValidateIngestedAnswers(ingestTestDefinition(), answers)
+	require.NoError(t, err)
+	assert.NotContains(t, answers["q3"].Text, "<script>")
+}
+
+func TestValidateIngestedAnswers_Rejections(t *testing.T) {
+	tests := []struct {
+		name       string
+		answers    map[string]Answer
+		reason     string
+		questionID string
+	}{
+		{
+			name:       "unknown question",
+			answers:    map[string]Answer{"q99": {SelectedOptions: []string{"a"}}},
+			reason:     RejectUnknownQuestion,
+			questionID: "q99",
+		},
+		{
+			name:       "unknown option on single choice",
+			answers:    map[string]Answer{"q1": {SelectedOptions: []string{"nope"}}},
+			reason:     RejectUnknownOption,
+			questionID: "q1",
+		},
+		{
+			name:       "option from another question",
+			answers:    map[string]Answer{"q2": {SelectedOptions: []string{"x", "a"}}},
+			reason:     RejectUnknownOption,
+			questionID: "q2",
+		},
+		{
+			name:       "multiple selections on single choice",
+			answers:    map[string]Answer{"q1": {SelectedOptions: []string{"a", "b"}}},
+			reason:     RejectMultipleSelections,
+			questionID: "q1",
+		},
+		{
+			name:       "text answer on choice question",
+			answers:    map[string]Answer{"q1": {Text: "free text"}},
+			reason:     RejectWrongAnswerType,
+			questionID: "q1",
+		},
+		{
+			name:       "selected options on text question",
+			answers:    map[string]Answer{"q3": {SelectedOptions: []string{"a"}}},
+			reason:     RejectWrongAnswerType,
+			questionID: "q3",
+		},
+		{
+			name:       "text answer too long",
+			answers:    map[string]Answer{"q3": {Text: strings.Repeat("a", MaxTextAnswerLength+1)}},
+			reason:     RejectTextTooLong,
+			questionID: "q3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIngestedAnswers(ingestTestDefinition(), tt.answers)
+			require.Error(t, err)
+
+			var answerErr *AnswerError
+			require.ErrorAs(t, err, &answerErr)
+			assert.Equal(t, tt.reason, answerErr.Reason)
+			assert.Equal(t, tt.questionID, answerErr.QuestionID)
+		})
+	}
+}
+
+func TestResponseDedupKey(t *testing.T) {
+	surveyID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	otherSurveyID := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	voter := "did:plc:voter"
+	uri1 := "at://did:plc:voter/net.openmeet.survey.response/rkey1"
+	uri2 := "at://did:plc:voter/net.openmeet.survey.response/rkey2"
+
+	t.Run("keys by DID by default", func(t *testing.T) {
+		def := &SurveyDefinition{}
+		key1 := ResponseDedupKey(def, surveyID, voter, uri1)
+		key2 := ResponseDedupKey(def, surveyID, voter, uri2)
+		assert.Equal(t, "did:"+voter, key1)
+		assert.Equal(t, key1, key2, "records from the same DID should share a key")
+	})
+
+	t.Run("keys by record when multiple responses are allowed", func(t *testing.T) {
+		def := &SurveyDefinition{AllowMultipleResponses: true}
+		key1 := ResponseDedupKey(def, surveyID, voter, uri1)
+		key2 := ResponseDedupKey(def, surveyID, voter, uri2)
+		assert.Equal(t, "record:"+uri1, key1)
+		assert.NotEqual(t, key1, key2)
+	})
+
+	t.Run("hashes DID per survey when anonymous", func(t *testing.T) {
+		def := &SurveyDefinition{Anonymous: true}
+		key1 := ResponseDedupKey(def, surveyID, voter, uri1)
+		key2 := ResponseDedupKey(def, surveyID, voter, uri2)
+		other := ResponseDedupKey(def, otherSurveyID, voter, uri1)
+
+		assert.Equal(t, key1, key2, "records from the same DID should share a key")
+		assert.NotContains(t, key1, voter)
+		assert.NotEqual(t, key1, other, "key should differ between surveys")
+		assert.True(t, strings.HasPrefix(key1, "anon:"))
+	})
+}
+
+func ratingTestDefinition() *SurveyDefinition {
+	return &SurveyDefinition{
+		Questions: []Question{
+			{ID: "q1", Text: "Rate it", Type: QuestionTypeRating, Required: true, Min: 1, Max: 5},
+		},
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidateAnswers_Rating(t *testing.T) {
+	t.Run("accepts rating within scale", func(t *testing.T) {
+		for _, v := range []int{1, 3, 5} {
+			err := ValidateAnswers(ratingTestDefinition(), map[string]Answer{"q1": {Rating: intPtr(v)}})
+			assert.NoError(t, err, "rating %d", v)
+		}
+	})
+
+	t.Run("rejects rating outside scale", func(t *testing.T) {
+		for _, v := range []int{0, 6} {
+			err := ValidateAnswers(ratingTestDefinition(), map[string]Answer{"q1": {Rating: intPtr(v)}})
+			require.Error(t, err, "rating %d", v)
+			assert.Contains(t, err.Error(), "outside 1-5")
+		}
+	})
+
+	t.Run("rejects answer without rating", func(t *testing.T) {
+		err := ValidateAnswers(ratingTestDefinition(), map[string]Answer{"q1": {}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must have a rating")
+	})
+}
+
+func TestValidateIngestedAnswers_Rating(t *testing.T) {
+	t.Run("accepts rating within scale", func(t *testing.T) {
+		err := ValidateIngestedAnswers(ratingTestDefinition(), map[string]Answer{"q1": {Rating: intPtr(4)}})
+		assert.NoError(t, err)
+	})
+
+	tests := []struct {
+		name   string
+		answer Answer
+		reason string
+	}{
+		{"rating above max", Answer{Rating: intPtr(6)}, RejectRatingOutOfRange},
+		{"rating below min", Answer{Rating: intPtr(0)}, RejectRatingOutOfRange},
+		{"selected options on rating question", Answer{SelectedOptions: []string{"a"}}, RejectWrongAnswerType},
+		{"text on rating question", Answer{Text: "five"}, RejectWrongAnswerType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIngestedAnswers(ratingTestDefinition(), map[string]Answer{"q1": tt.answer})
+			var answerErr *AnswerError
+			require.ErrorAs(t, err, &answerErr)
+			assert.Equal(t, tt.reason, answerErr.Reason)
+		})
+	}
+
+	t.Run("rejects rating on choice question", func(t *testing.T) {
+		err := ValidateIngestedAnswers(ingestTestDefinition(), map[string]Answer{"q1": {Rating: intPtr(1)}})
+		var answerErr *AnswerError
+		require.ErrorAs(t, err, &answerErr)
+		assert.Equal(t, RejectWrongAnswerType, answerErr.Reason)
+	})
+}
+
+func TestValidateAnswers_MaxSelections(t *testing.T) {
+	def := ingestTestDefinition()
+	def.Questions = append(def.Questions, Question{
+		ID:            "q4",
+		Text:          "Pick up to two",
+		Type:          QuestionTypeMulti,
+		Options:       []Option{{ID: "x", Text: "X"}, {ID: "y", Text: "Y"}, {ID: "z", Text: "Z"}},
+		MaxSelections: 2,
+	})
+
+	t.Run("accepts selections up to the cap", func(t *testing.T) {
+		answers := map[string]Answer{
+			"q1": {SelectedOptions: []string{"a"}},
+			"q4": {SelectedOptions: []string{"x", "y"}},
+		}
+		assert.NoError(t, ValidateAnswers(def, answers))
+		assert.NoError(t, ValidateIngestedAnswers(def, answers))
+	})
+
+	t.Run("submission over the cap is rejected", func(t *testing.T) {
+		err := ValidateAnswers(def, map[string]Answer{
+			"q1": {SelectedOptions: []string{"a"}},
+			"q4": {SelectedOptions: []string{"x", "y", "z"}},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "at most 2")
+	})
+
+	t.Run("ingested response over the cap is rejected", func(t *testing.T) {
+		err := ValidateIngestedAnswers(def, map[string]Answer{"q4": {SelectedOptions: []string{"x", "y", "z"}}})
+		var answerErr *AnswerError
+		require.ErrorAs(t, err, &answerErr)
+		assert.Equal(t, RejectTooManySelections, answerErr.Reason)
+		assert.Equal(t, "q4", answerErr.QuestionID)
+	})
+}
+
+func TestValidateAnswers_OtherText(t *testing.T) {
+	def := ingestTestDefinition()
+	def.Questions[0].Options = append(def.Questions[0].Options, Option{ID: "other", Text: "Other", AllowFreeText: true})
+
+	t.Run("accepts free text on a selected option that allows it", func(t *testing.T) {
+		answers := map[string]Answer{
+			"q1": {SelectedOptions: []string{"other"}, OtherText: map[string]string{"other": "  Thursday <script>x</script> "}},
+		}
+		require.NoError(t, ValidateAnswers(def, answers))
+		assert.Equal(t, "Thursday", answers["q1"].OtherText["other"], "free text is sanitized")
+	})
+
+	t.Run("free text length is counted in characters", func(t *testing.T) {
+		text := strings.Repeat("ü", MaxOtherTextLength)
+		answers := map[string]Answer{
+			"q1": {SelectedOptions: []string{"other"}, OtherText: map[string]string{"other": text}},
+		}
+		require.NoError(t, ValidateAnswers(def, answers))
+		assert.Equal(t, text, answers["q1"].OtherText["other"])
+	})
+
+	t.Run("blank free text is dropped", func(t *testing.T) {
+		answers := map[string]Answer{
+			"q1": {SelectedOptions: []string{"other"}, OtherText: map[string]string{"other": "   "}},
+		}
+		require.NoError(t, ValidateAnswers(def, answers))
+		assert.Empty(t, answers["q1"].OtherText)
+	})
+
+	rejected := []struct {
+		name   string
+		answer Answer
+		reason string
+	}{
+		{"option doesn't allow free text", Answer{SelectedOptions: []string{"a"}, OtherText: map[string]string{"a": "text"}}, RejectUnexpectedOther},
+		{"option not selected", Answer{SelectedOptions: []string{"a"}, OtherText: map[string]string{"other": "text"}}, RejectUnexpectedOther},
+		{"text too long", Answer{SelectedOptions: []string{"other"}, OtherText: map[string]string{"other": strings.Repeat("x", MaxOtherTextLength+1)}}, RejectTextTooLong},
+	}
+	for _, tt := range rejected {
+		t.Run("submission rejected: "+tt.name, func(t *testing.T) {
+			assert.Error(t, ValidateAnswers(def, map[string]Answer{"q1": tt.answer}))
+		})
+		t.Run("ingested response rejected: "+tt.name, func(t *testing.T) {
+			err := ValidateIngestedAnswers(def, map[string]Answer{"q1": tt.answer})
+			var answerErr *AnswerError
+			require.ErrorAs(t, err, &answerErr)
+			assert.Equal(t, tt.reason, answerErr.Reason)
+		})
+	}
+
+	t.Run("free text on a text question is the wrong answer type", func(t *testing.T) {
+		err := ValidateIngestedAnswers(def, map[string]Answer{"q3": {Text: "hi", OtherText: map[string]string{"other": "text"}}})
+		var answerErr *AnswerError
+		require.ErrorAs(t, err, &answerErr)
+		assert.Equal(t, RejectWrongAnswerType, answerErr.Reason)
+	})
+}
@@ -0,0 +1,12 @@
+package models
+
+// MaxSearchQueryLength caps a survey search query, in runes. Longer queries
+// are truncated rather than rejected.
+const MaxSearchQueryLength = 200
+
+// SurveySearchResult is a survey matched by keyword search, with its live
+// response count
+type SurveySearchResult struct {
+	Survey        *Survey
+	ResponseCount int
+}
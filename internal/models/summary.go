@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MinSummaryAnswers is the fewest text answers worth summarizing
+const MinSummaryAnswers = 5
+
+// TextSummary is an AI summary of the answers to a text question: the
+// themes the answers share, each with roughly how many answers raised it
+type TextSummary struct {
+	Overview string         `json:"overview"`
+	Themes   []SummaryTheme `json:"themes"`
+}
+
+// SummaryTheme is one theme of a TextSummary. Count is the model's estimate
+// of the answers that mention it; one answer can mention several themes.
+type SummaryTheme struct {
+	Theme   string `json:"theme"`
+	Summary string `json:"summary"`
+	Count   int    `json:"count"`
+}
+
+// TextAnswerSummary is the summary of one question's text answers, as of
+// when it had ResponseCount answers
+type TextAnswerSummary struct {
+	SurveyID      uuid.UUID   `json:"surveyId"`
+	QuestionID    string      `json:"questionId"`
+	ResponseCount int         `json:"responseCount"`
+	Summary       TextSummary `json:"summary"`
+	CreatedAt     time.Time   `json:"createdAt"`
+}
@@ -21,6 +21,7 @@ const (
 	QuestionTypeSingle QuestionType = "single"
 	QuestionTypeMulti  QuestionType = "multi"
 	QuestionTypeText   QuestionType = "text"
+	QuestionTypeRating QuestionType = "rating"
 )
 
 // Survey represents a survey definition stored in the database
@@ -37,14 +38,105 @@ type Survey struct {
 	EndsAt      *time.Time        `db:"ends_at" json:"endsAt,omitempty"`
 	ResultsURI  *string           `db:"results_uri" json:"resultsUri,omitempty"`
 	ResultsCID  *string           `db:"results_cid" json:"resultsCid,omitempty"`
+	DefinitionVersion int       `db:"definition_version" json:"definitionVersion"` // bumped when an edit removes answered questions
+	Version     int               `db:"version" json:"version"` // bumped on every title, description or definition change, for optimistic concurrency
+	Lang        *string           `db:"lang" json:"lang,omitempty"` // BCP-47 tag from the record, nil if none
 	CreatedAt   time.Time         `db:"created_at" json:"createdAt"`
 	UpdatedAt   time.Time         `db:"updated_at" json:"updatedAt"`
+	RecordUpdatedAt *time.Time `db:"record_updated_at" json:"recordUpdatedAt,omitempty"` // event time of the commit that produced CID, nil if never indexed
+	DeletedAt       *time.Time `db:"deleted_at" json:"deletedAt,omitempty"`              // set when the record was deleted, until restored or purged
+	Status          SurveyStatus `db:"status" json:"status,omitempty"`                   // computed by listing queries, empty elsewhere
+	ResponseCount   int          `db:"response_count" json:"-"`                          // read by listing queries, zero elsewhere
+}
+
+// SurveyStatus is where a survey is in its lifecycle, derived from its
+// starts_at, ends_at and deleted_at columns. The derivation is a SQL
+// expression in the db package, so listings report and filter on the same
+// value.
+type SurveyStatus string
+
+const (
+	SurveyStatusScheduled SurveyStatus = "scheduled" // starts_at is in the future
+	SurveyStatusOpen      SurveyStatus = "open"      // started (or no start) and not yet ended
+	SurveyStatusClosed    SurveyStatus = "closed"    // ends_at has passed
+	SurveyStatusDeleted   SurveyStatus = "deleted"   // soft-deleted, whatever its dates
+)
+
+// ParseSurveyStatus validates a status name, e.g. from a query parameter
+func ParseSurveyStatus(s string) (SurveyStatus, error) {
+	switch status := SurveyStatus(s); status {
+	case SurveyStatusScheduled, SurveyStatusOpen, SurveyStatusClosed, SurveyStatusDeleted:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown survey status %q", s)
+}
+
+// StatusAt returns the survey's status as of now, by the same rule as the
+// db package's listings: open from the instant StartsAt is reached, closed
+// from the instant EndsAt is
+func (s *Survey) StatusAt(now time.Time) SurveyStatus {
+	switch {
+	case s.DeletedAt != nil:
+		return SurveyStatusDeleted
+	case s.StartsAt != nil && s.StartsAt.After(now):
+		return SurveyStatusScheduled
+	case s.EndsAt != nil && !s.EndsAt.After(now):
+		return SurveyStatusClosed
+	}
+	return SurveyStatusOpen
+}
+
+// ValidateSchedule checks a survey's optional opening and closing times: a
+// survey can't close before, or as, it opens
+func ValidateSchedule(startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+	return nil
+}
+
+// AuthorSurvey is a survey in its author's listing, with its response count
+type AuthorSurvey struct {
+	Survey        *Survey
+	ResponseCount int
 }
 
 // SurveyDefinition represents the survey structure stored as JSONB
 type SurveyDefinition struct {
 	Questions []Question `json:"questions"`
 	Anonymous bool       `json:"anonymous"`
+	// AllowMultipleResponses counts every response record separately instead
+	// of keeping only the latest response per voter
+	AllowMultipleResponses bool `json:"allowMultipleResponses,omitempty"`
+	// AllowComments shows net.openmeet.survey.comment records on the survey page
+	AllowComments bool `json:"allowComments,omitempty"`
+	// Discoverable lists the survey in keyword search and on the browse page.
+	// Other surveys are only reachable by direct link.
+	Discoverable bool `json:"discoverable,omitempty"`
+	// PageSize splits the form into pages of this many questions, 0 for one
+	// page. Questions marked PageBreak start a page either way.
+	PageSize int `json:"pageSize,omitempty" yaml:"pageSize,omitempty"`
+}
+
+// Pages groups the questions into the form's pages, as indexes into
+// Questions. A page ends after PageSize questions or before a question
+// marked PageBreak, whichever comes first. Surveys without either are one
+// page.
+func (d *SurveyDefinition) Pages() [][]int {
+	var pages [][]int
+	var page []int
+	for i, q := range d.Questions {
+		full := d.PageSize > 0 && len(page) >= d.PageSize
+		if len(page) > 0 && (full || q.PageBreak) {
+			pages = append(pages, page)
+			page = nil
+		}
+		page = append(page, i)
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
 }
 
 // Question represents a survey question
@@ -54,12 +146,29 @@ type Question struct {
 	Type     QuestionType `json:"type"`
 	Required bool         `json:"required"`
 	Options  []Option     `json:"options,omitempty"`
+
+	// Multi-choice questions: the most options that may be selected, 0 for
+	// no limit. Never more than len(Options).
+	MaxSelections int `json:"maxSelections,omitempty" yaml:"maxSelections,omitempty"`
+
+	// Rating questions: answers are integers in [Min, Max]. Labels, if set,
+	// has one entry per value from Min to Max.
+	Min    int      `json:"min,omitempty"`
+	Max    int      `json:"max,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+
+	// PageBreak starts a new page of the form at this question
+	PageBreak bool `json:"pageBreak,omitempty" yaml:"pageBreak,omitempty"`
 }
 
 // Option represents a choice option for a question
 type Option struct {
 	ID   string `json:"id"`
 	Text string `json:"text"`
+
+	// AllowFreeText lets a respondent who selects this option add their own
+	// text ("Other (please specify)"), sent in Answer.OtherText
+	AllowFreeText bool `json:"allowFreeText,omitempty" yaml:"allowFreeText,omitempty"`
 }
 
 // Security limits for YAML bomb protection
@@ -70,6 +179,9 @@ const (
 	MaxQuestionTextLength   = 1000
 	MaxOptionTextLength     = 500
 	MaxTextAnswerLength     = 5000 // Maximum length for free-form text answers
+	MaxOtherTextLength      = 500  // Maximum length for an option's free text
+	OtherTextPreviewLength  = 100  // Free text is truncated to this many characters in results
+	MaxRatingValue          = 10   // Rating scales fit within 0-10
 )
 
 // Regex patterns for sanitization (compiled once for performance)
@@ -149,6 +261,10 @@ func (d *SurveyDefinition) ValidateDefinition() error {
 		return fmt.Errorf("too many questions: %d exceeds maximum of 50", len(d.Questions))
 	}
 
+	if d.PageSize < 0 {
+		return fmt.Errorf("pageSize must be 0 (one page) or more, got %d", d.PageSize)
+	}
+
 	questionIDs := make(map[string]bool)
 
 	for i, q := range d.Questions {
@@ -177,10 +293,43 @@ func (d *SurveyDefinition) ValidateDefinition() error {
 		}
 
 		// Validate question type
-		if q.Type != QuestionTypeSingle && q.Type != QuestionTypeMulti && q.Type != QuestionTypeText {
+		if q.Type != QuestionTypeSingle && q.Type != QuestionTypeMulti && q.Type != QuestionTypeText && q.Type != QuestionTypeRating {
 			return fmt.Errorf("question %d: invalid question type '%s'", i, q.Type)
 		}
 
+		// Validate scale for rating questions
+		if q.Type == QuestionTypeRating {
+			if q.Min < 0 || q.Max > MaxRatingValue || q.Min >= q.Max {
+				return fmt.Errorf("question %d: rating scale must satisfy 0 <= min < max <= %d, got %d-%d", i, MaxRatingValue, q.Min, q.Max)
+			}
+			if len(q.Options) > 0 {
+				return fmt.Errorf("question %d: rating questions cannot have options", i)
+			}
+			if len(q.Labels) > 0 && len(q.Labels) != q.Max-q.Min+1 {
+				return fmt.Errorf("question %d: rating labels must have one entry per value (%d), got %d", i, q.Max-q.Min+1, len(q.Labels))
+			}
+			for j, label := range q.Labels {
+				d.Questions[i].Labels[j] = SanitizeText(label)
+				if len(d.Questions[i].Labels[j]) > MaxOptionTextLength {
+					return fmt.Errorf("question %d, label %d: label too long: %d characters exceeds maximum of 500", i, j, len(d.Questions[i].Labels[j]))
+				}
+			}
+		}
+
+		// A selection cap only makes sense where several options can be picked.
+		// One above the option count is no cap at all, so it's clamped.
+		if q.MaxSelections != 0 {
+			if q.Type != QuestionTypeMulti {
+				return fmt.Errorf("question %d: maxSelections only applies to multi-choice questions", i)
+			}
+			if q.MaxSelections < 0 {
+				return fmt.Errorf("question %d: maxSelections must be 0 (no limit) or more, got %d", i, q.MaxSelections)
+			}
+			if q.MaxSelections > len(q.Options) {
+				d.Questions[i].MaxSelections = len(q.Options)
+			}
+		}
+
 		// Validate options for choice questions
 		if q.Type == QuestionTypeSingle || q.Type == QuestionTypeMulti {
 			if len(q.Options) < 2 {
@@ -242,11 +391,85 @@ type SurveyResults struct {
 	SurveyID        uuid.UUID                  `json:"surveyId"`
 	TotalVotes      int                        `json:"totalVotes"`
 	QuestionResults map[string]*QuestionResult `json:"questionResults"` // keyed by question ID
+
+	// DefinitionVersion is the survey's, bumped each time an edit removed
+	// questions that had been answered
+	DefinitionVersion int `json:"definitionVersion"`
+
+	// RemovedQuestions counts the responses answering each question those
+	// edits removed, keyed by question ID. The answers are kept, but not
+	// aggregated, since their question's text and options are gone.
+	RemovedQuestions map[string]int `json:"removedQuestions,omitempty"`
 }
 
 // QuestionResult represents aggregated results for a single question
 type QuestionResult struct {
 	QuestionID   string         `json:"questionId"`
 	OptionCounts map[string]int `json:"optionCounts"` // keyed by option ID, value is count
-	TextAnswers  []string       `json:"textAnswers"`  // for text questions
+	TextAnswers  []string       `json:"textAnswers"`  // for text questions, at most the first db.ResultsTextAnswerPreview
+
+	// TextAnswerCount is how many text answers there are, including those
+	// left out of TextAnswers
+	TextAnswerCount int `json:"textAnswerCount"`
+
+	// OtherTexts collects free text on options that allow it, keyed by option
+	// ID and truncated to OtherTextPreviewLength. Only the survey author sees
+	// it, so it's left out of the public JSON.
+	OtherTexts map[string][]string `json:"-"`
+
+	// Rating questions
+	RatingCounts  map[int]int `json:"ratingCounts,omitempty"`  // keyed by rating value, value is count
+	RatingAverage float64     `json:"ratingAverage,omitempty"` // mean of all ratings, 0 if none
+}
+
+// RatingTotal returns the number of ratings counted
+func (r *QuestionResult) RatingTotal() int {
+	total := 0
+	for _, count := range r.RatingCounts {
+		total += count
+	}
+	return total
+}
+
+// AddRating counts a rating and updates the running average
+func (r *QuestionResult) AddRating(value int) {
+	if r.RatingCounts == nil {
+		r.RatingCounts = make(map[int]int)
+	}
+	n := r.RatingTotal()
+	r.RatingCounts[value]++
+	r.RatingAverage += (float64(value) - r.RatingAverage) / float64(n+1)
+}
+
+// AddOtherText collects an option's free text, truncated to
+// OtherTextPreviewLength characters
+func (r *QuestionResult) AddOtherText(optionID, text string) {
+	if r.OtherTexts == nil {
+		r.OtherTexts = make(map[string][]string)
+	}
+	if runes := []rune(text); len(runes) > OtherTextPreviewLength {
+		text = string(runes[:OtherTextPreviewLength]) + "…"
+	}
+	r.OtherTexts[optionID] = append(r.OtherTexts[optionID], text)
+}
+
+// PublishedResults is a results snapshot a survey author published to their
+// PDS (net.openmeet.survey.results). It's stored as published so it can be
+// shown, and compared, next to our live aggregates.
+type PublishedResults struct {
+	SurveyURI       string                              `db:"survey_uri" json:"surveyUri"`
+	ResultsURI      string                              `db:"results_uri" json:"resultsUri"`
+	PublisherDID    string                              `db:"publisher_did" json:"publisherDid"`
+	TotalVotes      int                                 `db:"total_votes" json:"totalVotes"`
+	QuestionResults map[string]*PublishedQuestionResult `db:"question_results" json:"questionResults"` // keyed by question ID
+	PublishedAt     time.Time                           `db:"published_at" json:"publishedAt"`
+}
+
+// PublishedQuestionResult is the published tally for a single question.
+// Text answers aren't published, only how many there were.
+type PublishedQuestionResult struct {
+	QuestionID        string         `json:"questionId"`
+	OptionCounts      map[string]int `json:"optionCounts,omitempty"` // keyed by option ID, value is count
+	TextResponseCount int            `json:"textResponseCount,omitempty"`
+	RatingCounts      map[int]int    `json:"ratingCounts,omitempty"` // keyed by rating value, value is count
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -875,3 +876,231 @@ func TestValidateDefinition_SanitizationPreservesValidation(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "question text is required")
 }
+
+func TestParseSurveyDefinition_RatingQuestion(t *testing.T) {
+	yamlData := []byte(`
+questions:
+  - id: q1
+    text: How satisfied are you?
+    type: rating
+    min: 1
+    max: 5
+    labels: [Very unhappy, Unhappy, Neutral, Happy, Very happy]
+`)
+
+	def, err := ParseSurveyDefinition(yamlData)
+	require.NoError(t, err)
+	require.Len(t, def.Questions, 1)
+	assert.Equal(t, QuestionTypeRating, def.Questions[0].Type)
+	assert.Equal(t, 1, def.Questions[0].Min)
+	assert.Equal(t, 5, def.Questions[0].Max)
+	assert.Len(t, def.Questions[0].Labels, 5)
+	assert.NoError(t, def.ValidateDefinition())
+}
+
+func TestValidateDefinition_RatingQuestion(t *testing.T) {
+	rating := func(min, max int, labels ...string) *SurveyDefinition {
+		return &SurveyDefinition{
+			Questions: []Question{
+				{ID: "q1", Text: "Rate it", Type: QuestionTypeRating, Min: min, Max: max, Labels: labels},
+			},
+		}
+	}
+
+	t.Run("accepts 1-5 scale", func(t *testing.T) {
+		assert.NoError(t, rating(1, 5).ValidateDefinition())
+	})
+
+	t.Run("accepts 0-10 scale", func(t *testing.T) {
+		assert.NoError(t, rating(0, 10).ValidateDefinition())
+	})
+
+	t.Run("accepts one label per value", func(t *testing.T) {
+		assert.NoError(t, rating(1, 3, "Bad", "OK", "Good").ValidateDefinition())
+	})
+
+	t.Run("rejects max above limit", func(t *testing.T) {
+		err := rating(1, 11).ValidateDefinition()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "rating scale")
+	})
+
+	t.Run("rejects negative min", func(t *testing.T) {
+		assert.Error(t, rating(-1, 5).ValidateDefinition())
+	})
+
+	t.Run("rejects min not below max", func(t *testing.T) {
+		assert.Error(t, rating(5, 5).ValidateDefinition())
+		assert.Error(t, rating(0, 0).ValidateDefinition())
+	})
+
+	t.Run("rejects label count mismatch", func(t *testing.T) {
+		err := rating(1, 5, "Bad", "Good").ValidateDefinition()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "one entry per value")
+	})
+
+	t.Run("rejects options", func(t *testing.T) {
+		def := rating(1, 5)
+		def.Questions[0].Options = []Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}}
+		assert.Error(t, def.ValidateDefinition())
+	})
+}
+
+func TestParseSurveyDefinition_MaxSelections(t *testing.T) {
+	yamlData := []byte(`
+questions:
+  - id: q1
+    text: Pick your favourites
+    type: multi
+    maxSelections: 2
+    options:
+      - {id: a, text: A}
+      - {id: b, text: B}
+      - {id: c, text: C}
+`)
+
+	def, err := ParseSurveyDefinition(yamlData)
+	require.NoError(t, err)
+	assert.Equal(t, 2, def.Questions[0].MaxSelections)
+	assert.NoError(t, def.ValidateDefinition())
+}
+
+func TestValidateDefinition_MaxSelections(t *testing.T) {
+	question := func(questionType QuestionType, maxSelections int) *SurveyDefinition {
+		return &SurveyDefinition{
+			Questions: []Question{
+				{
+					ID:            "q1",
+					Text:          "Pick some",
+					Type:          questionType,
+					Options:       []Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}, {ID: "c", Text: "C"}},
+					MaxSelections: maxSelections,
+				},
+			},
+		}
+	}
+
+	t.Run("accepts cap within option count", func(t *testing.T) {
+		def := question(QuestionTypeMulti, 2)
+		require.NoError(t, def.ValidateDefinition())
+		assert.Equal(t, 2, def.Questions[0].MaxSelections)
+	})
+
+	t.Run("clamps cap above option count", func(t *testing.T) {
+		def := question(QuestionTypeMulti, 10)
+		require.NoError(t, def.ValidateDefinition())
+		assert.Equal(t, 3, def.Questions[0].MaxSelections)
+	})
+
+	t.Run("rejects negative cap", func(t *testing.T) {
+		assert.Error(t, question(QuestionTypeMulti, -1).ValidateDefinition())
+	})
+
+	t.Run("rejects cap on single choice", func(t *testing.T) {
+		err := question(QuestionTypeSingle, 1).ValidateDefinition()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "multi-choice")
+	})
+}
+
+func TestParseSurveyDefinition_Pages(t *testing.T) {
+	yamlData := []byte(`
+pageSize: 2
+questions:
+  - {id: q1, text: Name, type: text}
+  - {id: q2, text: Email, type: text, pageBreak: true}
+`)
+
+	def, err := ParseSurveyDefinition(yamlData)
+	require.NoError(t, err)
+	assert.Equal(t, 2, def.PageSize)
+	assert.True(t, def.Questions[1].PageBreak)
+	assert.NoError(t, def.ValidateDefinition())
+
+	def.PageSize = -1
+	assert.Error(t, def.ValidateDefinition())
+}
+
+func TestSurveyDefinition_Pages(t *testing.T) {
+	questions := func(n int, breaks ...int) []Question {
+		qs := make([]Question, n)
+		for i := range qs {
+			qs[i] = Question{ID: fmt.Sprintf("q%d", i)}
+		}
+		for _, i := range breaks {
+			qs[i].PageBreak = true
+		}
+		return qs
+	}
+
+	tests := []struct {
+		name string
+		def  SurveyDefinition
+		want [][]int
+	}{
+		{"one page by default", SurveyDefinition{Questions: questions(3)}, [][]int{{0, 1, 2}}},
+		{"page size", SurveyDefinition{Questions: questions(5), PageSize: 2}, [][]int{{0, 1}, {2, 3}, {4}}},
+		{"page size above question count", SurveyDefinition{Questions: questions(3), PageSize: 10}, [][]int{{0, 1, 2}}},
+		{"page breaks", SurveyDefinition{Questions: questions(4, 1, 3)}, [][]int{{0}, {1, 2}, {3}}},
+		{"break on the first question is no empty page", SurveyDefinition{Questions: questions(2, 0)}, [][]int{{0, 1}}},
+		{"page breaks restart the count", SurveyDefinition{Questions: questions(5, 1), PageSize: 2}, [][]int{{0}, {1, 2}, {3, 4}}},
+		{"no questions", SurveyDefinition{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.def.Pages())
+		})
+	}
+}
+
+func TestQuestionResult_AddRating(t *testing.T) {
+	result := &QuestionResult{QuestionID: "q1"}
+	for _, v := range []int{5, 4, 4, 1} {
+		result.AddRating(v)
+	}
+
+	assert.Equal(t, 4, result.RatingTotal())
+	assert.Equal(t, map[int]int{5: 1, 4: 2, 1: 1}, result.RatingCounts)
+	assert.InDelta(t, 3.5, result.RatingAverage, 1e-9)
+}
+
+func TestQuestionResult_AddOtherText(t *testing.T) {
+	result := &QuestionResult{QuestionID: "q1"}
+	result.AddOtherText("other", "Thursday")
+	result.AddOtherText("other", strings.Repeat("é", OtherTextPreviewLength+10))
+
+	require.Len(t, result.OtherTexts["other"], 2)
+	assert.Equal(t, "Thursday", result.OtherTexts["other"][0])
+	assert.Equal(t, strings.Repeat("é", OtherTextPreviewLength)+"…", result.OtherTexts["other"][1], "truncated by character, not byte")
+}
+
+func TestParseSurveyStatus(t *testing.T) {
+	for _, name := range []string{"open", "closed", "scheduled", "deleted"} {
+		status, err := ParseSurveyStatus(name)
+		require.NoError(t, err)
+		assert.Equal(t, SurveyStatus(name), status)
+	}
+
+	_, err := ParseSurveyStatus("Open")
+	assert.Error(t, err)
+	_, err = ParseSurveyStatus("")
+	assert.Error(t, err)
+}
+
+func TestSurvey_StatusAt(t *testing.T) {
+	now := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	assert.Equal(t, SurveyStatusOpen, (&Survey{}).StatusAt(now))
+	assert.Equal(t, SurveyStatusOpen, (&Survey{StartsAt: at(-time.Hour), EndsAt: at(time.Hour)}).StatusAt(now))
+	assert.Equal(t, SurveyStatusOpen, (&Survey{StartsAt: at(0)}).StatusAt(now), "opens the instant it starts")
+	assert.Equal(t, SurveyStatusScheduled, (&Survey{StartsAt: at(time.Second)}).StatusAt(now))
+	assert.Equal(t, SurveyStatusClosed, (&Survey{EndsAt: at(0)}).StatusAt(now), "closes the instant it ends")
+	assert.Equal(t, SurveyStatusClosed, (&Survey{StartsAt: at(-2 * time.Hour), EndsAt: at(-time.Hour)}).StatusAt(now))
+	assert.Equal(t, SurveyStatusDeleted, (&Survey{EndsAt: at(-time.Hour), DeletedAt: at(-time.Minute)}).StatusAt(now))
+}
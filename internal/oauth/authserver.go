@@ -0,0 +1,106 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/openmeet-team/survey/internal/telemetry"
+)
+
+// authServerEndpoint fetches the authorization server metadata and returns
+// the endpoint named by field, e.g. "token_endpoint"
+func authServerEndpoint(ctx context.Context, authServer, field string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", authServer+"/.well-known/oauth-authorization-server", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var metadata map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return "", err
+	}
+
+	endpoint, ok := metadata[field].(string)
+	if !ok {
+		return "", fmt.Errorf("missing %s", field)
+	}
+
+	return endpoint, nil
+}
+
+// postToAuthServer posts form to one of authServerURL's endpoints as clientID,
+// authenticated with a client assertion signed by clientKey and bound to
+// session's DPoP key. It starts with the last DPoP nonce the server sent and
+// retries once if the server demands a new one. Returns the final response's
+// status and body.
+func postToAuthServer(ctx context.Context, session *OAuthSession, authServerURL, endpoint, clientID, clientKey string, form url.Values) (int, []byte, error) {
+	clientAssertion, err := SignClientAssertion(clientKey, clientID, authServerURL)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create client assertion: %w", err)
+	}
+
+	form.Set("client_id", clientID)
+	form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	form.Set("client_assertion", clientAssertion)
+
+	client := &http.Client{}
+	post := func(dpopNonce string) (*http.Response, []byte, error) {
+		// Create DPoP proof (no access token for auth server endpoints)
+		dpopProof, err := CreateDPoPProof(session.DPoPKey, "POST", endpoint, dpopNonce, "")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create DPoP proof: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("DPoP", dpopProof)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		// Servers rotate nonces and may send a fresh one with any response
+		dpopNonces.set(authServerURL, resp.Header.Get("DPoP-Nonce"))
+		return resp, body, nil
+	}
+
+	// Start with the last nonce this server gave us so the first attempt
+	// usually succeeds
+	resp, body, err := post(dpopNonces.get(authServerURL))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Retry once if the server wants a (new) DPoP nonce
+	if nonce := resp.Header.Get("DPoP-Nonce"); nonce != "" && requiresDPoPNonce(resp.StatusCode, body) {
+		telemetry.OAuthDPoPNonceRetries.Inc()
+		resp, body, err = post(nonce)
+		if err != nil {
+			return 0, nil, fmt.Errorf("retry failed: %w", err)
+		}
+	}
+
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,106 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+)
+
+func TestAuthRequestExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		want      bool
+	}{
+		{"just created", now, false},
+		{"exactly at the window", now.Add(-AuthRequestTTL), false},
+		{"just past the window", now.Add(-AuthRequestTTL - time.Nanosecond), true},
+		{"long abandoned", now.Add(-24 * time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := authRequestExpired(tt.createdAt, now); got != tt.want {
+				t.Errorf("authRequestExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCallbackRejectsExpiredState tests that a login coming back after
+// AuthRequestTTL gets a "login expired" page and its request is discarded
+func TestCallbackRejectsExpiredState(t *testing.T) {
+	callback := func(t *testing.T, createdAt time.Time) (*httptest.ResponseRecorder, *queriestest.DB, error) {
+		t.Helper()
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM oauth_requests").RowsAffected(1)
+		fake.Expect("FROM oauth_requests WHERE state = $1").Rows(
+			[]string{"state", "issuer", "pkce_verifier", "dpop_private_key", "destination", "host", "created_at", "expires_at"},
+			[]interface{}{"expiry-state", "https://auth.example.com", "verifier", `{"kty":"EC"}`, "/", "", createdAt, createdAt.Add(AuthRequestTTL)},
+		)
+		handlers := NewHandlers(NewStorage(fake.DB), Config{Host: "survey.example.com", SecretJWK: "test-key"})
+
+		req := httptest.NewRequest(http.MethodGet, "/oauth/callback?iss=https://other.example.com&code=code&state=expiry-state", nil)
+		req.AddCookie(&http.Cookie{Name: "oauth_state", Value: "expiry-state"})
+		rec := httptest.NewRecorder()
+		err := handlers.Callback(echo.New().NewContext(req, rec))
+		return rec, fake, err
+	}
+
+	t.Run("rejects a state just past the window", func(t *testing.T) {
+		rec, fake, err := callback(t, time.Now().Add(-AuthRequestTTL-time.Second))
+		if err != nil {
+			t.Fatalf("Expected the expired page, got error: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "Login expired") || !strings.Contains(rec.Body.String(), "try again") {
+			t.Errorf("Expected a login expired page, got %s", rec.Body.String())
+		}
+		if n := len(fake.CallsMatching("DELETE FROM oauth_requests")); n != 1 {
+			t.Errorf("Expected the expired request to be deleted, got %d deletes", n)
+		}
+	})
+
+	t.Run("accepts a state within the window", func(t *testing.T) {
+		// Just inside the window the callback carries on to the issuer check
+		rec, _, err := callback(t, time.Now().Add(-AuthRequestTTL+time.Second))
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Message != "issuer mismatch" {
+			t.Errorf("Expected the callback to reach the issuer check, got %v", err)
+		}
+		if strings.Contains(rec.Body.String(), "Login expired") {
+			t.Error("Expected no login expired page")
+		}
+	})
+
+	t.Run("shows the expired page for a request that's gone", func(t *testing.T) {
+		// The cookie outlives the request, which the janitor may already have removed
+		fake := queriestest.New(t)
+		fake.Expect("FROM oauth_requests WHERE state = $1").Rows(
+			[]string{"state", "issuer", "pkce_verifier", "dpop_private_key", "destination", "host", "created_at", "expires_at"},
+		)
+		handlers := NewHandlers(NewStorage(fake.DB), Config{Host: "survey.example.com", SecretJWK: "test-key"})
+
+		req := httptest.NewRequest(http.MethodGet, "/oauth/callback?iss=https://auth.example.com&code=code&state=gone-state", nil)
+		req.AddCookie(&http.Cookie{Name: "oauth_state", Value: "gone-state"})
+		rec := httptest.NewRecorder()
+		if err := handlers.Callback(echo.New().NewContext(req, rec)); err != nil {
+			t.Fatalf("Expected the expired page, got error: %v", err)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400, got %d", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "Login expired") {
+			t.Errorf("Expected a login expired page, got %s", rec.Body.String())
+		}
+	})
+}
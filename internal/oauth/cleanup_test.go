@@ -11,8 +11,8 @@ import (
 	_ "github.com/lib/pq"
 )
 
-// TestCleanupExpiredRequests verifies expired requests are deleted
-func TestCleanupExpiredRequests(t *testing.T) {
+// TestDeleteExpiredAuthRequests verifies expired requests are deleted
+func TestDeleteExpiredAuthRequests(t *testing.T) {
 	dbConn := setupTestDB(t)
 	defer dbConn.Close()
 
@@ -51,9 +51,9 @@ func TestCleanupExpiredRequests(t *testing.T) {
 		}
 
 		// Run cleanup
-		count, err := storage.CleanupExpiredRequests(ctx)
+		count, err := storage.DeleteExpiredAuthRequests(ctx)
 		if err != nil {
-			t.Fatalf("CleanupExpiredRequests failed: %v", err)
+			t.Fatalf("DeleteExpiredAuthRequests failed: %v", err)
 		}
 
 		if count < 1 {
@@ -78,6 +78,42 @@ func TestCleanupExpiredRequests(t *testing.T) {
 		// Cleanup test data
 		storage.DeleteOAuthRequest(ctx, "cleanup-test-valid")
 	})
+
+	t.Run("removes requests older than AuthRequestTTL", func(t *testing.T) {
+		for _, state := range []string{"cleanup-test-abandoned", "cleanup-test-recent"} {
+			err := storage.SaveOAuthRequest(ctx, OAuthRequest{
+				State:          state,
+				Issuer:         "https://bsky.social",
+				PKCEVerifier:   "verifier",
+				DPoPPrivateKey: `{"kty":"EC"}`,
+				Destination:    "/",
+				ExpiresAt:      time.Now().Add(1 * time.Hour), // not yet expired
+			})
+			if err != nil {
+				t.Fatalf("SaveOAuthRequest failed: %v", err)
+			}
+		}
+		defer storage.DeleteOAuthRequest(ctx, "cleanup-test-recent")
+
+		// The user clicked "Log in" and closed the tab just over the window ago
+		_, err := dbConn.ExecContext(ctx,
+			`UPDATE oauth_requests SET created_at = $1 WHERE state = 'cleanup-test-abandoned'`,
+			time.Now().Add(-AuthRequestTTL-time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to age request: %v", err)
+		}
+
+		if _, err := storage.DeleteExpiredAuthRequests(ctx); err != nil {
+			t.Fatalf("DeleteExpiredAuthRequests failed: %v", err)
+		}
+
+		if _, err := storage.GetOAuthRequest(ctx, "cleanup-test-abandoned"); err != sql.ErrNoRows {
+			t.Errorf("Expected abandoned request to be deleted, got error: %v", err)
+		}
+		if _, err := storage.GetOAuthRequest(ctx, "cleanup-test-recent"); err != nil {
+			t.Errorf("Expected recent request to still exist, got error: %v", err)
+		}
+	})
 }
 
 // TestCleanupExpiredSessions verifies expired sessions are deleted
@@ -143,6 +179,63 @@ func TestCleanupExpiredSessions(t *testing.T) {
 	})
 }
 
+// TestDeleteExpiredSessions verifies idle and dead sessions are deleted while
+// recently used ones are kept
+func TestDeleteExpiredSessions(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	storage := NewStorage(dbConn)
+	ctx := context.Background()
+
+	sessions := []OAuthSession{
+		// Unused for 40 days
+		{ID: "idle-session-old", DID: "did:plc:idle", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)},
+		// Expired, but refreshable and used recently
+		{ID: "idle-session-refreshable", DID: "did:plc:active", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour)},
+		// Expired with no refresh token
+		{ID: "idle-session-dead", DID: "did:plc:dead", ExpiresAt: time.Now().Add(-time.Hour)},
+		// Valid and used recently
+		{ID: "idle-session-active", DID: "did:plc:active", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)},
+		// Unused for 40 days until touched just now
+		{ID: "idle-session-touched", DID: "did:plc:touched", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	for _, session := range sessions {
+		if err := storage.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		defer storage.DeleteSession(ctx, session.ID)
+	}
+
+	_, err := dbConn.ExecContext(ctx,
+		`UPDATE oauth_sessions SET last_used_at = NOW() - INTERVAL '40 days' WHERE id IN ('idle-session-old', 'idle-session-touched')`)
+	if err != nil {
+		t.Fatalf("Failed to age sessions: %v", err)
+	}
+	if err := storage.TouchSession(ctx, "idle-session-touched"); err != nil {
+		t.Fatalf("TouchSession failed: %v", err)
+	}
+
+	count, err := storage.DeleteExpiredSessions(ctx, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("DeleteExpiredSessions failed: %v", err)
+	}
+	if count < 2 {
+		t.Errorf("Expected at least 2 sessions deleted, got %d", count)
+	}
+
+	for _, id := range []string{"idle-session-old", "idle-session-dead"} {
+		if _, err := storage.GetSessionByID(ctx, id); err != sql.ErrNoRows {
+			t.Errorf("Expected %s to be deleted, got error: %v", id, err)
+		}
+	}
+	for _, id := range []string{"idle-session-refreshable", "idle-session-active", "idle-session-touched"} {
+		if _, err := storage.GetSessionByID(ctx, id); err != nil {
+			t.Errorf("Expected %s to be kept, got error: %v", id, err)
+		}
+	}
+}
+
 // TestCleanupWorkerCancellation verifies worker stops on context cancellation
 func TestCleanupWorkerCancellation(t *testing.T) {
 	dbConn := setupTestDB(t)
@@ -156,7 +249,7 @@ func TestCleanupWorkerCancellation(t *testing.T) {
 		// Start worker with very short interval
 		done := make(chan bool)
 		go func() {
-			StartCleanupWorker(ctx, storage, 10*time.Millisecond)
+			StartCleanupWorker(ctx, storage, 10*time.Millisecond, DefaultSessionIdleTimeout)
 			done <- true
 		}()
 
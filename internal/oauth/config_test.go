@@ -1,9 +1,12 @@
 package oauth
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNormalizeHost(t *testing.T) {
@@ -46,3 +49,165 @@ func TestNormalizeHost(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshThresholdFromEnv(t *testing.T) {
+	t.Run("defaults to 5 minutes", func(t *testing.T) {
+		threshold, err := RefreshThresholdFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, DefaultRefreshThreshold, threshold)
+		assert.Equal(t, 5*time.Minute, threshold)
+	})
+
+	t.Run("reads a duration", func(t *testing.T) {
+		t.Setenv("OAUTH_REFRESH_THRESHOLD", "90s")
+		threshold, err := RefreshThresholdFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, 90*time.Second, threshold)
+	})
+
+	t.Run("accepts the cap", func(t *testing.T) {
+		t.Setenv("OAUTH_REFRESH_THRESHOLD", "1h")
+		threshold, err := RefreshThresholdFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, MaxRefreshThreshold, threshold)
+	})
+
+	for _, value := range []string{"0", "-1m", "61m", "soon"} {
+		t.Run("rejects "+value, func(t *testing.T) {
+			t.Setenv("OAUTH_REFRESH_THRESHOLD", value)
+			_, err := RefreshThresholdFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConfigRefreshThreshold(t *testing.T) {
+	assert.Equal(t, DefaultRefreshThreshold, Config{}.refreshThreshold(), "zero uses the default")
+	assert.Equal(t, 2*time.Minute, Config{RefreshThreshold: 2 * time.Minute}.refreshThreshold())
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			Host:      "survey.openmeet.net",
+			SecretJWK: GenerateSecretJWK(),
+		}
+	}
+
+	t.Run("accepts a valid config", func(t *testing.T) {
+		assert.NoError(t, valid().Validate())
+	})
+
+	t.Run("accepts a host with an https prefix", func(t *testing.T) {
+		config := valid()
+		config.Host = "https://survey.openmeet.net/"
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("accepts http on localhost", func(t *testing.T) {
+		config := valid()
+		config.Host = "http://localhost:8080"
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("accepts additional hosts", func(t *testing.T) {
+		config := valid()
+		config.AdditionalHosts = []string{"staging.openmeet.net", "https://survey.openmeet.org"}
+		assert.NoError(t, config.Validate())
+	})
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		message string
+	}{
+		{"empty host", func(c *Config) { c.Host = "" }, "host is empty"},
+		{"host with a path", func(c *Config) { c.Host = "survey.openmeet.net/app" }, "not a bare hostname"},
+		{"host with spaces", func(c *Config) { c.Host = "survey openmeet net" }, "not a bare hostname"},
+		{"http host outside localhost", func(c *Config) { c.Host = "http://survey.openmeet.net" }, "must be HTTPS"},
+		{"empty JWK", func(c *Config) { c.SecretJWK = "" }, "secret JWK is invalid: it is empty"},
+		{"malformed JWK", func(c *Config) { c.SecretJWK = "{not json" }, "does not parse as a JWK"},
+		{"public JWK", func(c *Config) {
+			public, err := PrivateJWKToPublicJWK(GenerateSecretJWK())
+			require.NoError(t, err)
+			c.SecretJWK = public
+		}, "must be an EC private key"},
+		{"symmetric JWK", func(c *Config) { c.SecretJWK = `{"kty":"oct","k":"c2VjcmV0LXNlY3JldC1zZWNyZXQ"}` }, "must be an EC private key"},
+		{"missing atproto scope", func(c *Config) { c.Scope = "transition:generic" }, `missing required scope "atproto"`},
+		{"missing transition scope", func(c *Config) { c.Scope = "atproto" }, `missing required scope "transition:generic"`},
+		{"refresh threshold too long", func(c *Config) { c.RefreshThreshold = 2 * time.Hour }, "refresh threshold"},
+		{"additional host with a path", func(c *Config) { c.AdditionalHosts = []string{"staging.openmeet.net/app"} }, "additional host"},
+		{"http additional host", func(c *Config) { c.AdditionalHosts = []string{"http://staging.openmeet.net"} }, "must be HTTPS"},
+		{"duplicate additional host", func(c *Config) { c.AdditionalHosts = []string{"Survey.openmeet.net"} }, "listed more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid()
+			tt.modify(&config)
+			err := config.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+
+	t.Run("reports every problem at once", func(t *testing.T) {
+		config := Config{Host: "", SecretJWK: "{not json", Scope: "atproto"}
+		err := config.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "host is empty")
+		assert.Contains(t, err.Error(), "does not parse as a JWK")
+		assert.Contains(t, err.Error(), "transition:generic")
+		assert.Len(t, strings.Split(err.Error(), "\n"), 3)
+	})
+}
+
+func TestConfigHosts(t *testing.T) {
+	config := Config{
+		Host:            "survey.openmeet.net",
+		AdditionalHosts: []string{"https://staging.openmeet.net/"},
+	}
+
+	t.Run("serves configured hosts", func(t *testing.T) {
+		host, ok := config.requestHost("survey.openmeet.net")
+		assert.True(t, ok)
+		assert.Equal(t, "survey.openmeet.net", host)
+
+		host, ok = config.requestHost("Staging.OpenMeet.net")
+		assert.True(t, ok)
+		assert.Equal(t, "staging.openmeet.net", host)
+	})
+
+	t.Run("rejects an unknown host", func(t *testing.T) {
+		_, ok := config.requestHost("evil.example.com")
+		assert.False(t, ok)
+	})
+
+	t.Run("serves every host as the primary without additional hosts", func(t *testing.T) {
+		host, ok := Config{Host: "survey.openmeet.net"}.requestHost("10.0.0.5:8080")
+		assert.True(t, ok)
+		assert.Equal(t, "survey.openmeet.net", host)
+	})
+
+	t.Run("derives client IDs per host", func(t *testing.T) {
+		assert.Equal(t, "https://survey.openmeet.net/oauth/client-metadata.json", config.clientID("survey.openmeet.net"))
+		assert.Equal(t, "https://staging.openmeet.net/oauth/client-metadata.json", config.clientID("staging.openmeet.net"))
+		assert.Equal(t, "https://staging.openmeet.net/oauth/callback", config.redirectURI("staging.openmeet.net"))
+	})
+
+	t.Run("falls back to the primary host", func(t *testing.T) {
+		assert.Equal(t, "https://survey.openmeet.net/oauth/client-metadata.json", config.clientID(""), "sessions stored without a host")
+		assert.Equal(t, "https://survey.openmeet.net/oauth/client-metadata.json", config.clientID("removed.openmeet.net"), "hosts no longer configured")
+	})
+}
+
+func TestAdditionalHostsFromEnv(t *testing.T) {
+	t.Run("defaults to none", func(t *testing.T) {
+		assert.Empty(t, AdditionalHostsFromEnv())
+	})
+
+	t.Run("reads a comma-separated list", func(t *testing.T) {
+		t.Setenv("OAUTH_ADDITIONAL_HOSTS", " staging.openmeet.net, ,survey.openmeet.org ")
+		assert.Equal(t, []string{"staging.openmeet.net", "survey.openmeet.org"}, AdditionalHostsFromEnv())
+	})
+}
@@ -0,0 +1,48 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// dpopNonces remembers the last DPoP nonce each authorization server sent.
+// Servers reject proofs without a current nonce, so starting with the last
+// one seen saves a round trip on most requests.
+var dpopNonces = &nonceCache{nonces: make(map[string]string)}
+
+// nonceCache maps a server URL to the last DPoP nonce it sent
+type nonceCache struct {
+	mu     sync.Mutex
+	nonces map[string]string
+}
+
+// get returns the last nonce seen from server, or "" if none
+func (c *nonceCache) get(server string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nonces[server]
+}
+
+// set records nonce as server's current nonce. An empty nonce is ignored.
+func (c *nonceCache) set(server, nonce string) {
+	if nonce == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nonces[server] = nonce
+}
+
+// requiresDPoPNonce reports whether a response rejected the request's DPoP
+// proof for lacking a current nonce. Authorization servers answer 400 and
+// resource servers 401, both with the use_dpop_nonce error.
+func requiresDPoPNonce(status int, body []byte) bool {
+	if status != http.StatusBadRequest && status != http.StatusUnauthorized {
+		return false
+	}
+	var errorResp struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(body, &errorResp) == nil && errorResp.Error == "use_dpop_nonce"
+}
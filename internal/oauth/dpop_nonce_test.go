@@ -0,0 +1,190 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// dpopProofNonce returns the nonce claim of a DPoP proof JWT
+func dpopProofNonce(t *testing.T, proof string) string {
+	t.Helper()
+	parts := strings.Split(proof, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Malformed DPoP proof %q", proof)
+	}
+	payload, err := decodeJWTPart(parts[1])
+	if err != nil {
+		t.Fatalf("Failed to decode DPoP proof: %v", err)
+	}
+	var claims struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("Failed to parse DPoP proof claims: %v", err)
+	}
+	return claims.Nonce
+}
+
+// nonceAuthServer is an authorization server that rejects token and
+// revocation requests whose DPoP proof lacks its current nonce with the given
+// status
+type nonceAuthServer struct {
+	*httptest.Server
+	status int
+
+	mu       sync.Mutex
+	nonce    string
+	attempts []string // nonce of each request's proof
+}
+
+func newNonceAuthServer(t *testing.T, status int) *nonceAuthServer {
+	s := &nonceAuthServer{status: status, nonce: "nonce-1"}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token_endpoint":"` + s.URL + `/token","revocation_endpoint":"` + s.URL + `/revoke"}`))
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		got := dpopProofNonce(t, r.Header.Get("DPoP"))
+		s.attempts = append(s.attempts, got)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("DPoP-Nonce", s.nonce)
+		if got != s.nonce {
+			w.WriteHeader(s.status)
+			w.Write([]byte(`{"error":"use_dpop_nonce","error_description":"Authorization server requires nonce in DPoP proof"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"DPoP","expires_in":3600}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *nonceAuthServer) rotate(nonce string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonce = nonce
+}
+
+func (s *nonceAuthServer) takeAttempts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.attempts
+	s.attempts = nil
+	return attempts
+}
+
+func refreshTestSession() *OAuthSession {
+	expiredTime := time.Now().Add(-1 * time.Hour)
+	return &OAuthSession{
+		ID:             "test-session",
+		DID:            "did:plc:test",
+		AccessToken:    "old-token",
+		RefreshToken:   "test-refresh-token",
+		DPoPKey:        GenerateSecretJWK(),
+		PDSUrl:         "https://pds.example.com",
+		TokenExpiresAt: &expiredTime,
+	}
+}
+
+// TestRefreshAccessToken_DPoPNonce tests that token refresh retries with the
+// nonce the server demands and reuses it for later refreshes
+func TestRefreshAccessToken_DPoPNonce(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := newNonceAuthServer(t, status)
+			retries := testutil.ToFloat64(telemetry.OAuthDPoPNonceRetries)
+
+			accessToken, _, _, err := RefreshAccessToken(context.Background(), refreshTestSession(), server.URL, "client-id", GenerateSecretJWK())
+			if err != nil {
+				t.Fatalf("RefreshAccessToken failed: %v", err)
+			}
+			if accessToken != "new-access-token" {
+				t.Errorf("Expected new-access-token, got %s", accessToken)
+			}
+			if attempts := server.takeAttempts(); len(attempts) != 2 || attempts[0] != "" || attempts[1] != "nonce-1" {
+				t.Errorf("Expected a first attempt without nonce and a retry with nonce-1, got %q", attempts)
+			}
+			if got := testutil.ToFloat64(telemetry.OAuthDPoPNonceRetries) - retries; got != 1 {
+				t.Errorf("Expected 1 nonce retry to be counted, got %v", got)
+			}
+
+			t.Run("reuses the nonce", func(t *testing.T) {
+				if _, _, _, err := RefreshAccessToken(context.Background(), refreshTestSession(), server.URL, "client-id", GenerateSecretJWK()); err != nil {
+					t.Fatalf("RefreshAccessToken failed: %v", err)
+				}
+				if attempts := server.takeAttempts(); len(attempts) != 1 || attempts[0] != "nonce-1" {
+					t.Errorf("Expected a single attempt with nonce-1, got %q", attempts)
+				}
+			})
+
+			t.Run("retries once after rotation", func(t *testing.T) {
+				server.rotate("nonce-2")
+				if _, _, _, err := RefreshAccessToken(context.Background(), refreshTestSession(), server.URL, "client-id", GenerateSecretJWK()); err != nil {
+					t.Fatalf("RefreshAccessToken failed: %v", err)
+				}
+				if attempts := server.takeAttempts(); len(attempts) != 2 || attempts[0] != "nonce-1" || attempts[1] != "nonce-2" {
+					t.Errorf("Expected attempts with nonce-1 then nonce-2, got %q", attempts)
+				}
+			})
+		})
+	}
+
+	t.Run("gives up after one retry", func(t *testing.T) {
+		var attempts int
+		var serverURL string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/.well-known/oauth-authorization-server" {
+				w.Write([]byte(`{"token_endpoint":"` + serverURL + `/token"}`))
+				return
+			}
+			attempts++
+			w.Header().Set("DPoP-Nonce", "always-new")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"use_dpop_nonce"}`))
+		}))
+		defer server.Close()
+		serverURL = server.URL
+
+		_, _, _, err := RefreshAccessToken(context.Background(), refreshTestSession(), server.URL, "client-id", GenerateSecretJWK())
+		if !errors.Is(err, ErrRefreshTransient) {
+			t.Errorf("Expected ErrRefreshTransient when the server keeps demanding a nonce, got: %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("Expected 2 attempts, got %d", attempts)
+		}
+	})
+}
+
+func TestRequiresDPoPNonce(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   bool
+	}{
+		{http.StatusBadRequest, `{"error":"use_dpop_nonce"}`, true},
+		{http.StatusUnauthorized, `{"error":"use_dpop_nonce"}`, true},
+		{http.StatusBadRequest, `{"error":"invalid_grant"}`, false},
+		{http.StatusInternalServerError, `{"error":"use_dpop_nonce"}`, false},
+		{http.StatusBadRequest, `not json`, false},
+	}
+	for _, tt := range tests {
+		if got := requiresDPoPNonce(tt.status, []byte(tt.body)); got != tt.want {
+			t.Errorf("requiresDPoPNonce(%d, %s) = %v, want %v", tt.status, tt.body, got, tt.want)
+		}
+	}
+}
@@ -1,26 +1,233 @@
 package oauth
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/labstack/echo/v4"
 )
 
+// DefaultScope is the scope requested when Config.Scope is empty
+const DefaultScope = "atproto transition:generic"
+
+// requiredScopes must be in Config.Scope: atproto for the login itself, and
+// transition:generic to write survey records to users' PDSes
+var requiredScopes = []string{"atproto", "transition:generic"}
+
 // Config holds OAuth handler configuration
 type Config struct {
-	Host      string // Public hostname (e.g., survey.openmeet.net)
+	Host      string // Primary public hostname (e.g., survey.openmeet.net)
 	SecretJWK string // Signing key (JWK format)
+	Scope     string // Space-separated scopes to request; empty means DefaultScope
+
+	// AdditionalHosts are other hostnames the app is served under (e.g. a
+	// staging domain). Each is its own OAuth client, with client metadata at
+	// https://<host>/oauth/client-metadata.json.
+	AdditionalHosts []string
+
+	// RefreshThreshold is how long before expiry access tokens are refreshed.
+	// Zero means DefaultRefreshThreshold.
+	RefreshThreshold time.Duration
+}
+
+// refreshThreshold returns the configured refresh threshold or the default
+func (c Config) refreshThreshold() time.Duration {
+	if c.RefreshThreshold <= 0 {
+		return DefaultRefreshThreshold
+	}
+	return c.RefreshThreshold
+}
+
+// scope returns the configured scope or the default
+func (c Config) scope() string {
+	if c.Scope == "" {
+		return DefaultScope
+	}
+	return c.Scope
+}
+
+// hosts returns the primary host followed by the additional hosts, normalized
+func (c Config) hosts() []string {
+	hosts := []string{normalizeHost(c.Host)}
+	for _, host := range c.AdditionalHosts {
+		hosts = append(hosts, normalizeHost(host))
+	}
+	return hosts
+}
+
+// requestHost returns the configured host an incoming request was made to.
+// With no additional hosts every request is served as the primary host, as
+// before; otherwise a request to a host that isn't configured is rejected
+// (ok is false).
+func (c Config) requestHost(host string) (string, bool) {
+	if len(c.AdditionalHosts) == 0 {
+		return normalizeHost(c.Host), true
+	}
+	for _, allowed := range c.hosts() {
+		if strings.EqualFold(allowed, host) {
+			return allowed, true
+		}
+	}
+	return "", false
+}
+
+// clientHost returns the configured host matching host, falling back to the
+// primary host for sessions stored without one (or with one no longer
+// configured)
+func (c Config) clientHost(host string) string {
+	for _, allowed := range c.hosts() {
+		if host != "" && strings.EqualFold(allowed, host) {
+			return allowed
+		}
+	}
+	return normalizeHost(c.Host)
+}
+
+// clientID returns the OAuth client ID (client metadata URL) for host
+func (c Config) clientID(host string) string {
+	return fmt.Sprintf("https://%s/oauth/client-metadata.json", c.clientHost(host))
+}
+
+// redirectURI returns the OAuth callback URL for host
+func (c Config) redirectURI(host string) string {
+	return fmt.Sprintf("https://%s/oauth/callback", c.clientHost(host))
+}
+
+// Validate checks the configuration, so a misconfiguration stops the server
+// at startup rather than failing the first login. It reports every problem
+// found, joined into one error.
+func (c Config) Validate() error {
+	var errs []error
+
+	if normalizeHost(c.Host) == "" {
+		errs = append(errs, errors.New("host is empty: set SERVER_HOST to the public hostname, e.g. survey.openmeet.net"))
+	} else if err := validateHost(c.Host); err != nil {
+		errs = append(errs, fmt.Errorf("%w: set SERVER_HOST to just the hostname (and port), e.g. survey.openmeet.net", err))
+	}
+	seen := map[string]bool{strings.ToLower(normalizeHost(c.Host)): true}
+	for _, host := range c.AdditionalHosts {
+		if err := validateHost(host); err != nil {
+			errs = append(errs, fmt.Errorf("additional %w: set OAUTH_ADDITIONAL_HOSTS to comma-separated hostnames", err))
+			continue
+		}
+		if key := strings.ToLower(normalizeHost(host)); seen[key] {
+			errs = append(errs, fmt.Errorf("additional host %q is listed more than once", host))
+		} else {
+			seen[key] = true
+		}
+	}
+
+	if err := validateSecretJWK(c.SecretJWK); err != nil {
+		errs = append(errs, fmt.Errorf("secret JWK is invalid: %w (generate one with cmd/keygen and set OAUTH_SECRET_JWK_B64)", err))
+	}
+
+	scopes := strings.Fields(c.scope())
+	for _, required := range requiredScopes {
+		if !slices.Contains(scopes, required) {
+			errs = append(errs, fmt.Errorf("scope %q is missing required scope %q", c.scope(), required))
+		}
+	}
+
+	if c.RefreshThreshold < 0 || c.RefreshThreshold > MaxRefreshThreshold {
+		errs = append(errs, fmt.Errorf("refresh threshold %v must be between 0 and %v", c.RefreshThreshold, MaxRefreshThreshold))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateHost checks that host is a bare hostname (and port) that can be
+// used in an HTTPS redirect URI
+func validateHost(host string) error {
+	bare := normalizeHost(host)
+	if bare == "" {
+		return fmt.Errorf("host %q is empty", host)
+	}
+	u, err := url.Parse("https://" + bare)
+	if err != nil || u.Host != bare || u.Hostname() == "" {
+		return fmt.Errorf("host %q is not a bare hostname", host)
+	}
+	if strings.HasPrefix(host, "http://") && !isLocalhost(u.Hostname()) {
+		return fmt.Errorf("host %q uses http://, but the redirect URI https://%s/oauth/callback must be HTTPS outside localhost", host, bare)
+	}
+	return nil
+}
+
+// isLocalhost reports whether host names the local machine, where plain HTTP
+// redirect URIs are allowed for development
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// validateSecretJWK checks that jwk is a private ES256 (P-256) key, the kind
+// client assertions are signed with
+func validateSecretJWK(secretJWK string) error {
+	if secretJWK == "" {
+		return errors.New("it is empty")
+	}
+
+	var jwk jose.JSONWebKey
+	if err := json.Unmarshal([]byte(secretJWK), &jwk); err != nil {
+		return fmt.Errorf("it does not parse as a JWK: %v", err)
+	}
+
+	key, ok := jwk.Key.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("it must be an EC private key, got %T", jwk.Key)
+	}
+	if key.Curve != elliptic.P256() {
+		return fmt.Errorf("it must use the P-256 curve, got %s", key.Curve.Params().Name)
+	}
+	if jwk.Algorithm != "" && jwk.Algorithm != string(jose.ES256) {
+		return fmt.Errorf("its algorithm must be ES256, got %s", jwk.Algorithm)
+	}
+
+	return nil
+}
+
+// RefreshThresholdFromEnv reads how long before expiry access tokens are
+// refreshed from OAUTH_REFRESH_THRESHOLD (a Go duration such as "2m"),
+// defaulting to DefaultRefreshThreshold. It must be positive and at most
+// MaxRefreshThreshold.
+func RefreshThresholdFromEnv() (time.Duration, error) {
+	value := os.Getenv("OAUTH_REFRESH_THRESHOLD")
+	if value == "" {
+		return DefaultRefreshThreshold, nil
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold <= 0 || threshold > MaxRefreshThreshold {
+		return 0, fmt.Errorf("invalid OAUTH_REFRESH_THRESHOLD: %q (must be between 0 and %v)", value, MaxRefreshThreshold)
+	}
+	return threshold, nil
+}
+
+// AdditionalHostsFromEnv reads the hosts served besides SERVER_HOST from
+// OAUTH_ADDITIONAL_HOSTS, a comma-separated list. Config.Validate checks them.
+func AdditionalHostsFromEnv() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("OAUTH_ADDITIONAL_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
 }
 
 // Handlers provides OAuth HTTP handlers
 type Handlers struct {
-	storage *Storage
-	config  Config
+	storage  *Storage
+	config   Config
+	resolver *Resolver
 }
 
 // normalizeHost strips protocol prefix from host if present
@@ -32,16 +239,23 @@ func normalizeHost(host string) string {
 	return host
 }
 
-// NewHandlers creates a new Handlers instance
-func NewHandlers(db *sql.DB, config Config) *Handlers {
+// NewHandlers creates a new Handlers instance. The storage should be the one
+// the rest of the server uses, so sessions are encrypted with the same cipher.
+func NewHandlers(storage *Storage, config Config) *Handlers {
 	// Normalize the host to ensure it's just the hostname without protocol
 	config.Host = normalizeHost(config.Host)
 	return &Handlers{
-		storage: NewStorage(db),
-		config:  config,
+		storage:  storage,
+		config:   config,
+		resolver: NewResolver(NetworkResolverBackend{}, nil),
 	}
 }
 
+// SetResolver sets the identity resolver used to look up login handles
+func (h *Handlers) SetResolver(resolver *Resolver) {
+	h.resolver = resolver
+}
+
 // ClientMetadata represents OAuth client metadata
 // See: https://atproto.com/specs/oauth#client-metadata
 type ClientMetadata struct {
@@ -122,6 +336,35 @@ func (h *Handlers) LoginPage(c echo.Context) error {
 	return c.HTML(http.StatusOK, html)
 }
 
+// stateCookieMaxAge keeps the state cookie well past AuthRequestTTL, so a
+// login that comes back late still has its state and gets loginExpiredHTML
+// rather than a CSRF error
+const stateCookieMaxAge = 24 * time.Hour
+
+// loginExpiredHTML is shown when a login comes back after AuthRequestTTL, or
+// after its request has been removed
+const loginExpiredHTML = `<!DOCTYPE html>
+<html>
+<head>
+    <title>Login expired - Survey Service</title>
+    <style>
+        body {
+            font-family: system-ui, -apple-system, sans-serif;
+            max-width: 400px;
+            margin: 100px auto;
+            padding: 20px;
+        }
+        a {
+            color: #0085ff;
+        }
+    </style>
+</head>
+<body>
+    <h1>Login expired</h1>
+    <p>Your login took too long to complete. Please <a href="/oauth/login">try again</a>.</p>
+</body>
+</html>`
+
 // Login initiates the OAuth flow
 func (h *Handlers) Login(c echo.Context) error {
 	// Only accept POST requests
@@ -129,6 +372,12 @@ func (h *Handlers) Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusMethodNotAllowed, "method not allowed")
 	}
 
+	// The login completes on the host it started on, as that host's client
+	host, ok := h.config.requestHost(c.Request().Host)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown host")
+	}
+
 	// Get handle from form
 	handle := c.FormValue("handle")
 	if handle == "" {
@@ -156,12 +405,12 @@ func (h *Handlers) Login(c echo.Context) error {
 		HttpOnly: true,
 		Secure:   true, // HTTPS only
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   600, // 10 minutes (same as OAuth request expiry)
+		MaxAge:   int(stateCookieMaxAge.Seconds()),
 	}
 	c.SetCookie(stateCookie)
 
 	// Resolve handle → DID
-	did, err := HandleToDID(handle)
+	did, err := h.resolver.ResolveHandle(c.Request().Context(), handle)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to resolve handle: %v", err))
 	}
@@ -192,14 +441,13 @@ func (h *Handlers) Login(c echo.Context) error {
 	dpopKeyJWK := GenerateSecretJWK()
 
 	// Build client metadata URL
-	clientID := fmt.Sprintf("https://%s/oauth/client-metadata.json", h.config.Host)
-	redirectURI := fmt.Sprintf("https://%s/oauth/callback", h.config.Host)
+	clientID := h.config.clientID(host)
 
 	// Execute PAR request
 	parConfig := PARConfig{
 		ClientID:      clientID,
-		RedirectURI:   redirectURI,
-		Scope:         "atproto transition:generic",
+		RedirectURI:   h.config.redirectURI(host),
+		Scope:         h.config.scope(),
 		State:         state,
 		CodeVerifier:  pkceVerifier,
 		DPoPKey:       dpopKeyJWK,
@@ -220,7 +468,8 @@ func (h *Handlers) Login(c echo.Context) error {
 		PKCEVerifier:   pkceVerifier,
 		DPoPPrivateKey: dpopKeyJWK,
 		Destination:    destination,
-		ExpiresAt:      time.Now().Add(10 * time.Minute),
+		Host:           host,
+		ExpiresAt:      time.Now().Add(AuthRequestTTL),
 	}
 
 	if err := h.storage.SaveOAuthRequest(c.Request().Context(), oauthReq); err != nil {
@@ -249,6 +498,11 @@ func (h *Handlers) Callback(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "missing required parameters")
 	}
 
+	host, ok := h.config.requestHost(c.Request().Host)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown host")
+	}
+
 	// CSRF Protection: Verify state matches the cookie value
 	// This prevents attackers from using their own authorization code with a victim's session
 	stateCookie, err := c.Cookie("oauth_state")
@@ -275,26 +529,42 @@ func (h *Handlers) Callback(c echo.Context) error {
 	// Look up OAuth request by state
 	oauthReq, err := h.storage.GetOAuthRequest(c.Request().Context(), state)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return echo.NewHTTPError(http.StatusBadRequest, "invalid or expired state")
+		if errors.Is(err, sql.ErrNoRows) {
+			// Already removed by the janitor, or finished in another tab
+			return c.HTML(http.StatusBadRequest, loginExpiredHTML)
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to retrieve OAuth request")
 	}
 
+	// Reject logins that took too long to come back; the janitor may not have
+	// removed the request yet
+	if authRequestExpired(oauthReq.CreatedAt, time.Now()) {
+		if delErr := h.storage.DeleteOAuthRequest(c.Request().Context(), state); delErr != nil {
+			c.Logger().Errorf("Failed to delete OAuth request: %v", delErr)
+		}
+		return c.HTML(http.StatusBadRequest, loginExpiredHTML)
+	}
+
 	// Verify issuer matches
 	if oauthReq.Issuer != iss {
 		return echo.NewHTTPError(http.StatusBadRequest, "issuer mismatch")
 	}
 
+	// The code was issued to the client the login started as; requests saved
+	// before hosts were recorded started on the primary host
+	if h.config.clientHost(oauthReq.Host) != host {
+		return echo.NewHTTPError(http.StatusBadRequest, "host mismatch")
+	}
+
 	// Get token endpoint from the auth server
-	tokenEndpoint, err := GetTokenEndpoint(iss)
+	tokenEndpoint, err := GetTokenEndpoint(c.Request().Context(), iss)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to get token endpoint: %v", err))
 	}
 
 	// Build client ID and redirect URI
-	clientID := fmt.Sprintf("https://%s/oauth/client-metadata.json", h.config.Host)
-	redirectURI := fmt.Sprintf("https://%s/oauth/callback", h.config.Host)
+	clientID := h.config.clientID(host)
+	redirectURI := h.config.redirectURI(host)
 
 	// Exchange authorization code for tokens
 	tokenConfig := TokenConfig{
@@ -343,6 +613,9 @@ func (h *Handlers) Callback(c echo.Context) error {
 		PDSUrl:         pdsURL,
 		TokenExpiresAt: tokenExpiresAt,
 		Issuer:         iss, // Store issuer for token refresh
+		UserAgent:      c.Request().UserAgent(),
+		IPAddress:      c.RealIP(),
+		Host:           host,                           // Refreshes use this host's client ID
 		ExpiresAt:      time.Now().Add(24 * time.Hour), // Session cookie expiry
 	}
 
@@ -381,20 +654,26 @@ func (h *Handlers) Callback(c echo.Context) error {
 	return c.Redirect(http.StatusFound, destination)
 }
 
-// ClientMetadata returns the OAuth client metadata
+// ClientMetadata returns the OAuth client metadata of the host it is
+// requested from
 func (h *Handlers) ClientMetadata(c echo.Context) error {
+	host, ok := h.config.requestHost(c.Request().Host)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown host")
+	}
+
 	metadata := ClientMetadata{
-		ClientID:                    fmt.Sprintf("https://%s/oauth/client-metadata.json", h.config.Host),
+		ClientID:                    h.config.clientID(host),
 		ClientName:                  "Survey Service",
 		ApplicationType:             "web",
 		GrantTypes:                  []string{"authorization_code", "refresh_token"},
-		Scope:                       "atproto transition:generic",
+		Scope:                       h.config.scope(),
 		ResponseTypes:               []string{"code"},
-		RedirectURIs:                []string{fmt.Sprintf("https://%s/oauth/callback", h.config.Host)},
+		RedirectURIs:                []string{h.config.redirectURI(host)},
 		DPopBoundAccessTokens:       true,
 		TokenEndpointAuthMethod:     "private_key_jwt",
 		TokenEndpointAuthSigningAlg: "ES256",
-		JwksUri:                     fmt.Sprintf("https://%s/oauth/jwks.json", h.config.Host),
+		JwksUri:                     fmt.Sprintf("https://%s/oauth/jwks.json", host),
 	}
 
 	return c.JSON(http.StatusOK, metadata)
@@ -427,8 +706,15 @@ func (h *Handlers) Logout(c echo.Context) error {
 	// Get session cookie
 	cookie, err := c.Cookie("session")
 	if err == nil && cookie.Value != "" {
-		// Delete session from database
-		if err := h.storage.DeleteSession(c.Request().Context(), cookie.Value); err != nil {
+		ctx := c.Request().Context()
+		if session, err := h.storage.GetSessionByID(ctx, cookie.Value); err == nil {
+			// Revoke the refresh token upstream and delete the session; the
+			// local logout completes even if revocation fails
+			if err := RevokeSession(ctx, session, h.storage, h.config); err != nil {
+				c.Logger().Errorf("Failed to revoke session: %v", err)
+			}
+		} else if err := h.storage.DeleteSession(ctx, cookie.Value); err != nil {
+			// Delete session from database
 			c.Logger().Errorf("Failed to delete session: %v", err)
 		}
 	}
@@ -25,7 +25,7 @@ func TestLoginPageHandler(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/oauth/login", nil)
@@ -59,7 +59,7 @@ func TestLoginHandler(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	t.Run("initiates OAuth flow with valid handle", func(t *testing.T) {
 		e := echo.New()
@@ -129,7 +129,7 @@ func TestClientMetadataHandler(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/oauth/client-metadata.json", nil)
@@ -171,7 +171,7 @@ func TestJWKSHandler(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/oauth/jwks.json", nil)
@@ -207,7 +207,7 @@ func TestCallbackHandler(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	t.Run("returns error for missing parameters", func(t *testing.T) {
 		e := echo.New()
@@ -279,7 +279,7 @@ func TestLoginSetsOAuthStateCookie(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 
@@ -313,8 +313,8 @@ func TestLoginSetsOAuthStateCookie(t *testing.T) {
 			if cookie.SameSite != http.SameSiteLaxMode {
 				t.Errorf("Expected SameSite=Lax, got %v", cookie.SameSite)
 			}
-			if cookie.MaxAge != 600 { // 10 minutes
-				t.Errorf("Expected MaxAge=600, got %d", cookie.MaxAge)
+			if cookie.MaxAge <= int(AuthRequestTTL.Seconds()) {
+				t.Errorf("Expected MaxAge to outlive AuthRequestTTL, got %d", cookie.MaxAge)
 			}
 			if cookie.Value == "" {
 				t.Error("Expected non-empty cookie value")
@@ -339,7 +339,7 @@ func TestCallbackRejectsWithoutStateCookie(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 
@@ -395,7 +395,7 @@ func TestCallbackRejectsStateMismatch(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 
@@ -456,7 +456,7 @@ func TestCallbackSucceedsWithMatchingState(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 
@@ -513,7 +513,7 @@ func TestCallbackClearsStateCookie(t *testing.T) {
 		SecretJWK: mustGenerateTestKey(t),
 	}
 
-	handlers := NewHandlers(dbConn, config)
+	handlers := NewHandlers(NewStorage(dbConn), config)
 
 	e := echo.New()
 
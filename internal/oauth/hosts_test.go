@@ -0,0 +1,110 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+)
+
+// multiHostConfig serves a primary and a staging host
+func multiHostConfig() Config {
+	return Config{
+		Host:            "survey.example.com",
+		AdditionalHosts: []string{"staging.example.com"},
+		SecretJWK:       GenerateSecretJWK(),
+	}
+}
+
+// expectHTTPError fails t unless err is an echo.HTTPError with status and message
+func expectHTTPError(t *testing.T, err error, status int, message string) {
+	t.Helper()
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected an HTTP error, got %v", err)
+	}
+	if httpErr.Code != status || httpErr.Message != message {
+		t.Errorf("Expected %d %q, got %d %q", status, message, httpErr.Code, httpErr.Message)
+	}
+}
+
+func TestLoginRejectsUnknownHost(t *testing.T) {
+	fake := queriestest.New(t)
+	handlers := NewHandlers(NewStorage(fake.DB), multiHostConfig())
+
+	form := url.Values{"handle": {"alice.test"}}
+	req := httptest.NewRequest(http.MethodPost, "https://evil.example.com/oauth/login", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+
+	expectHTTPError(t, handlers.Login(echo.New().NewContext(req, rec)), http.StatusBadRequest, "unknown host")
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("Expected no state cookie for an unknown host")
+	}
+}
+
+func TestCallbackHosts(t *testing.T) {
+	callback := func(t *testing.T, requestHost, loginHost string) error {
+		t.Helper()
+		fake := queriestest.New(t)
+		fake.Expect("FROM oauth_requests WHERE state = $1").Rows(
+			[]string{"state", "issuer", "pkce_verifier", "dpop_private_key", "destination", "host", "created_at", "expires_at"},
+			[]interface{}{"host-state", "https://auth.example.com", "verifier", `{"kty":"EC"}`, "/", loginHost, time.Now(), time.Now().Add(AuthRequestTTL)},
+		)
+		handlers := NewHandlers(NewStorage(fake.DB), multiHostConfig())
+
+		req := httptest.NewRequest(http.MethodGet, "https://"+requestHost+"/oauth/callback?iss=https://auth.example.com&code=code&state=host-state", nil)
+		req.AddCookie(&http.Cookie{Name: "oauth_state", Value: "host-state"})
+		return handlers.Callback(echo.New().NewContext(req, httptest.NewRecorder()))
+	}
+
+	t.Run("rejects an unknown host", func(t *testing.T) {
+		expectHTTPError(t, callback(t, "evil.example.com", "survey.example.com"), http.StatusBadRequest, "unknown host")
+	})
+
+	t.Run("rejects a callback on another host than the login", func(t *testing.T) {
+		expectHTTPError(t, callback(t, "staging.example.com", "survey.example.com"), http.StatusBadRequest, "host mismatch")
+	})
+
+	t.Run("rejects a login without a stored host on an additional host", func(t *testing.T) {
+		expectHTTPError(t, callback(t, "staging.example.com", ""), http.StatusBadRequest, "host mismatch")
+	})
+}
+
+func TestClientMetadataPerHost(t *testing.T) {
+	handlers := NewHandlers(NewStorage(queriestest.New(t).DB), multiHostConfig())
+
+	for _, host := range []string{"survey.example.com", "staging.example.com"} {
+		t.Run(host, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://"+host+"/oauth/client-metadata.json", nil)
+			rec := httptest.NewRecorder()
+			if err := handlers.ClientMetadata(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatalf("ClientMetadata failed: %v", err)
+			}
+
+			var metadata ClientMetadata
+			if err := json.Unmarshal(rec.Body.Bytes(), &metadata); err != nil {
+				t.Fatalf("Failed to parse metadata: %v", err)
+			}
+			if want := "https://" + host + "/oauth/client-metadata.json"; metadata.ClientID != want {
+				t.Errorf("Expected client_id %s, got %s", want, metadata.ClientID)
+			}
+			if want := "https://" + host + "/oauth/callback"; len(metadata.RedirectURIs) != 1 || metadata.RedirectURIs[0] != want {
+				t.Errorf("Expected redirect_uris [%s], got %v", want, metadata.RedirectURIs)
+			}
+		})
+	}
+
+	t.Run("unknown host", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://evil.example.com/oauth/client-metadata.json", nil)
+		err := handlers.ClientMetadata(echo.New().NewContext(req, httptest.NewRecorder()))
+		expectHTTPError(t, err, http.StatusNotFound, "unknown host")
+	})
+}
@@ -0,0 +1,52 @@
+package oauth
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Token refresh results for TokenRefreshTotal
+const (
+	RefreshResultOK             = "ok"
+	RefreshResultInvalidGrant   = "invalid_grant"   // the auth server rejected the refresh token
+	RefreshResultTransientError = "transient_error" // network, 5xx, storage or other failures
+	RefreshResultMissingFields  = "missing_fields"  // the session lacks an issuer, refresh token or DPoP key
+)
+
+// OAuth metrics are registered here rather than in the telemetry package so
+// any binary that imports oauth gets them without extra wiring.
+var (
+	// TokenRefreshTotal counts token refreshes by what triggered them and
+	// their outcome
+	TokenRefreshTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_oauth_token_refresh_total",
+			Help: "Total number of OAuth access token refreshes",
+		},
+		[]string{"trigger", "result"}, // trigger: lazy, proactive; result: ok, invalid_grant, transient_error, missing_fields
+	)
+
+	// TokenRefreshDuration tracks time spent on token endpoint requests
+	TokenRefreshDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "survey_oauth_token_refresh_duration_seconds",
+			Help:    "Time to refresh an OAuth access token at the authorization server",
+			Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"trigger"},
+	)
+)
+
+// refreshResult returns the TokenRefreshTotal result for a refresh error
+func refreshResult(err error) string {
+	if err == nil {
+		return RefreshResultOK
+	}
+	var refreshErr *refreshError
+	if errors.As(err, &refreshErr) {
+		return refreshErr.result
+	}
+	return RefreshResultTransientError
+}
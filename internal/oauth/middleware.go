@@ -18,6 +18,7 @@ type User struct {
 type SessionStore interface {
 	GetSessionByID(ctx context.Context, id string) (*OAuthSession, error)
 	DeleteSession(ctx context.Context, id string) error
+	TouchSession(ctx context.Context, id string) error
 }
 
 // SessionMiddleware creates middleware that reads the session cookie
@@ -61,6 +62,11 @@ func SessionMiddleware(storage SessionStore) echo.MiddlewareFunc {
 				return next(c)
 			}
 
+			// Keep the session from being cleaned up as idle
+			if err := storage.TouchSession(c.Request().Context(), cookie.Value); err != nil {
+				c.Logger().Errorf("Failed to record session use: %v", err)
+			}
+
 			// Valid session - add user to context
 			user := &User{
 				DID: session.DID,
@@ -18,6 +18,7 @@ type stubSessionStore struct {
 	sessions    map[string]*OAuthSession
 	deleteErr   error
 	deleteCalls []string
+	touchCalls  []string
 }
 
 func (s *stubSessionStore) GetSessionByID(ctx context.Context, id string) (*OAuthSession, error) {
@@ -37,6 +38,11 @@ func (s *stubSessionStore) DeleteSession(ctx context.Context, id string) error {
 	return nil
 }
 
+func (s *stubSessionStore) TouchSession(ctx context.Context, id string) error {
+	s.touchCalls = append(s.touchCalls, id)
+	return nil
+}
+
 func TestSessionMiddlewareExpiredSessionDeletes(t *testing.T) {
 	store := &stubSessionStore{
 		sessions: map[string]*OAuthSession{
@@ -69,6 +75,7 @@ func TestSessionMiddlewareExpiredSessionDeletes(t *testing.T) {
 	assert.Equal(t, []string{"expired-session"}, store.deleteCalls)
 	_, exists := store.sessions["expired-session"]
 	assert.False(t, exists)
+	assert.Empty(t, store.touchCalls)
 }
 
 func TestSessionMiddlewareDeleteErrorDoesNotBlock(t *testing.T) {
@@ -134,4 +141,23 @@ func TestSessionMiddlewareValidSessionDoesNotDelete(t *testing.T) {
 	require.NotNil(t, capturedUser)
 	assert.Equal(t, "did:plc:valid", capturedUser.DID)
 	assert.Empty(t, store.deleteCalls)
+	assert.Equal(t, []string{"valid-session"}, store.touchCalls)
+}
+
+func TestSessionIdleTimeoutFromEnv(t *testing.T) {
+	t.Setenv("OAUTH_SESSION_IDLE_TIMEOUT", "")
+	timeout, err := SessionIdleTimeoutFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSessionIdleTimeout, timeout)
+
+	t.Setenv("OAUTH_SESSION_IDLE_TIMEOUT", "72h")
+	timeout, err = SessionIdleTimeoutFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 72*time.Hour, timeout)
+
+	for _, value := range []string{"0s", "-1h", "a week"} {
+		t.Setenv("OAUTH_SESSION_IDLE_TIMEOUT", value)
+		_, err := SessionIdleTimeoutFromEnv()
+		assert.Error(t, err, value)
+	}
 }
@@ -2,7 +2,9 @@ package oauth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -156,106 +158,38 @@ func CreateRecord(session *OAuthSession, collection string, rkey string, record
 
 // RefreshAccessToken refreshes an expired access token using a refresh token
 // Returns new access token, refresh token, and expires_in seconds
-func RefreshAccessToken(session *OAuthSession, authServerURL, clientID, clientKey string) (string, string, int, error) {
+func RefreshAccessToken(ctx context.Context, session *OAuthSession, authServerURL, clientID, clientKey string) (string, string, int, error) {
 	if session == nil {
 		return "", "", 0, fmt.Errorf("session cannot be nil")
 	}
 
 	if session.RefreshToken == "" {
-		return "", "", 0, fmt.Errorf("session missing refresh token")
+		return "", "", 0, missingFields(fmt.Errorf("session missing refresh token"))
 	}
 
 	if session.DPoPKey == "" {
-		return "", "", 0, fmt.Errorf("session missing DPoP key")
+		return "", "", 0, missingFields(fmt.Errorf("session missing DPoP key"))
 	}
 
 	// Get token endpoint from auth server
-	tokenEndpoint, err := GetTokenEndpoint(authServerURL)
+	tokenEndpoint, err := GetTokenEndpoint(ctx, authServerURL)
 	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to get token endpoint: %w", err)
-	}
-
-	// Create client assertion for authentication
-	clientAssertion, err := SignClientAssertion(clientKey, clientID, authServerURL)
-	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to create client assertion: %w", err)
-	}
-
-	// Create DPoP proof (no access token for token endpoint)
-	dpopProof, err := CreateDPoPProof(session.DPoPKey, "POST", tokenEndpoint, "", "")
-	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to create DPoP proof: %w", err)
+		return "", "", 0, refreshTransient(fmt.Errorf("failed to get token endpoint: %w", err))
 	}
 
 	// Build form data
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("refresh_token", session.RefreshToken)
-	data.Set("client_id", clientID)
-	data.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
-	data.Set("client_assertion", clientAssertion)
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", tokenEndpoint, strings.NewReader(data.Encode()))
-	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("DPoP", dpopProof)
-
-	// Execute request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", "", 0, fmt.Errorf("token refresh request failed: %w", err)
-	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	status, body, err := postToAuthServer(ctx, session, authServerURL, tokenEndpoint, clientID, clientKey, data)
 	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check for DPoP nonce requirement
-	if resp.StatusCode == http.StatusBadRequest {
-		var errorResp map[string]interface{}
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			if errorCode, ok := errorResp["error"].(string); ok && errorCode == "use_dpop_nonce" {
-				dpopNonce := resp.Header.Get("DPoP-Nonce")
-				if dpopNonce != "" {
-					// Retry with nonce (no access token for token endpoint)
-					dpopProof, err = CreateDPoPProof(session.DPoPKey, "POST", tokenEndpoint, dpopNonce, "")
-					if err != nil {
-						return "", "", 0, fmt.Errorf("failed to create DPoP proof with nonce: %w", err)
-					}
-
-					req, err = http.NewRequest("POST", tokenEndpoint, strings.NewReader(data.Encode()))
-					if err != nil {
-						return "", "", 0, fmt.Errorf("failed to create retry request: %w", err)
-					}
-
-					req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-					req.Header.Set("DPoP", dpopProof)
-
-					resp, err = client.Do(req)
-					if err != nil {
-						return "", "", 0, fmt.Errorf("token refresh retry request failed: %w", err)
-					}
-					defer resp.Body.Close()
-
-					body, err = io.ReadAll(resp.Body)
-					if err != nil {
-						return "", "", 0, fmt.Errorf("failed to read retry response: %w", err)
-					}
-				}
-			}
-		}
+		return "", "", 0, refreshTransient(fmt.Errorf("token refresh request failed: %w", err))
 	}
 
 	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		return "", "", 0, fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+	if status != http.StatusOK {
+		return "", "", 0, refreshStatusError(status, body)
 	}
 
 	// Parse token response
@@ -344,6 +278,52 @@ func ListRecords(pdsURL, did, collection string, cursor string, limit int) (*Lis
 	return &result, nil
 }
 
+// GetRecord fetches a single record (public endpoint, no auth required)
+func GetRecord(pdsURL, did, collection, rkey string) (*PDSRecord, error) {
+	if pdsURL == "" {
+		return nil, fmt.Errorf("PDS URL cannot be empty")
+	}
+
+	if did == "" {
+		return nil, fmt.Errorf("DID cannot be empty")
+	}
+
+	params := url.Values{}
+	params.Set("repo", did)
+	params.Set("collection", collection)
+	params.Set("rkey", rkey)
+	fullURL := strings.TrimSuffix(pdsURL, "/") + "/xrpc/com.atproto.repo.getRecord?" + params.Encode()
+
+	req, err := http.NewRequest("GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("PDS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("PDS returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var record PDSRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	record.RKey = rkey
+
+	return &record, nil
+}
+
 // DeleteRecord deletes a single record from the user's PDS (requires auth)
 func DeleteRecord(session *OAuthSession, collection, rkey string) error {
 	if session == nil {
@@ -450,8 +430,27 @@ func DeleteRecord(session *OAuthSession, collection, rkey string) error {
 	return nil
 }
 
+// ErrRecordChanged is returned by SwapRecord when the record is no longer at
+// the CID the caller expected
+var ErrRecordChanged = errors.New("record was changed on the PDS")
+
 // UpdateRecord updates an existing record in the user's PDS (requires auth)
 func UpdateRecord(session *OAuthSession, collection, rkey string, record interface{}) (string, string, error) {
+	return putRecord(session, collection, rkey, record, "")
+}
+
+// SwapRecord is UpdateRecord for a record last seen at swapCID. If it has
+// changed since, nothing is written and ErrRecordChanged is returned.
+func SwapRecord(session *OAuthSession, collection, rkey string, record interface{}, swapCID string) (string, string, error) {
+	if swapCID == "" {
+		return "", "", fmt.Errorf("swap CID cannot be empty")
+	}
+	return putRecord(session, collection, rkey, record, swapCID)
+}
+
+// putRecord writes a record with com.atproto.repo.putRecord, with the
+// swapRecord check when swapCID isn't empty
+func putRecord(session *OAuthSession, collection, rkey string, record interface{}, swapCID string) (string, string, error) {
 	if session == nil {
 		return "", "", fmt.Errorf("session cannot be nil")
 	}
@@ -482,6 +481,9 @@ func UpdateRecord(session *OAuthSession, collection, rkey string, record interfa
 		"record":     record,
 		"validate":   &validateFalse,
 	}
+	if swapCID != "" {
+		payload["swapRecord"] = swapCID
+	}
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
@@ -552,6 +554,15 @@ func UpdateRecord(session *OAuthSession, collection, rkey string, record interfa
 		}
 	}
 
+	if resp.StatusCode == http.StatusBadRequest && swapCID != "" {
+		var xrpcErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &xrpcErr) == nil && xrpcErr.Error == "InvalidSwap" {
+			return "", "", ErrRecordChanged
+		}
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return "", "", fmt.Errorf("PDS returned status %d: %s", resp.StatusCode, string(body))
 	}
@@ -2,6 +2,9 @@ package oauth_test
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -43,11 +46,86 @@ func TestEnsureValidTokenIntegration(t *testing.T) {
 // TestEnsureValidTokenUpdatesSession is a unit test that verifies the session
 // object is updated in memory after a successful refresh
 func TestEnsureValidTokenUpdatesSession(t *testing.T) {
-	t.Skip("TODO: Implement with mock storage")
+	authServer := newTokenServer(t)
+	mock := newMockStorage()
+	session := expiringSession(authServer.URL, -1*time.Minute)
+
+	if err := oauth.EnsureValidToken(context.Background(), session, mock, testConfig()); err != nil {
+		t.Fatalf("EnsureValidToken failed: %v", err)
+	}
+
+	if session.AccessToken != "new-access-token" {
+		t.Errorf("Expected AccessToken new-access-token, got %s", session.AccessToken)
+	}
+	if session.RefreshToken != "new-refresh-token" {
+		t.Errorf("Expected RefreshToken new-refresh-token, got %s", session.RefreshToken)
+	}
+	if session.TokenExpiresAt == nil || session.TokenExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("Expected TokenExpiresAt about an hour from now, got %v", session.TokenExpiresAt)
+	}
+
+	update, ok := mock.sessionsUpdated[session.ID]
+	if !ok {
+		t.Fatal("Expected the refreshed tokens to be stored")
+	}
+	if update.accessToken != session.AccessToken || update.refreshToken != session.RefreshToken || update.tokenExpiresAt != session.TokenExpiresAt {
+		t.Errorf("Stored tokens %+v differ from the session's", update)
+	}
+}
 
-	// This test would verify that after EnsureValidToken succeeds,
-	// the session object passed to it has updated AccessToken, RefreshToken,
-	// and TokenExpiresAt fields
+// TestEnsureValidToken_ExpiredToken tests that refresh happens when token is expired
+func TestEnsureValidToken_ExpiredToken(t *testing.T) {
+	authServer := newTokenServer(t)
+	mock := newMockStorage()
+	session := expiringSession(authServer.URL, -1*time.Hour)
+
+	if err := oauth.EnsureValidToken(context.Background(), session, mock, testConfig()); err != nil {
+		t.Fatalf("EnsureValidToken failed: %v", err)
+	}
+	if !mock.updateTokensCalled {
+		t.Error("Expected the tokens to be refreshed and stored")
+	}
+	if authServer.refreshes != 1 {
+		t.Errorf("Expected 1 refresh, got %d", authServer.refreshes)
+	}
+}
+
+// TestEnsureValidToken_ExpiringToken tests that refresh happens when token
+// expires within the default 5 minute threshold
+func TestEnsureValidToken_ExpiringToken(t *testing.T) {
+	authServer := newTokenServer(t)
+	mock := newMockStorage()
+	session := expiringSession(authServer.URL, 4*time.Minute)
+
+	if err := oauth.EnsureValidToken(context.Background(), session, mock, testConfig()); err != nil {
+		t.Fatalf("EnsureValidToken failed: %v", err)
+	}
+	if !mock.updateTokensCalled {
+		t.Error("Expected the tokens to be refreshed and stored")
+	}
+	if session.AccessToken != "new-access-token" {
+		t.Errorf("Expected AccessToken new-access-token, got %s", session.AccessToken)
+	}
+}
+
+// TestEnsureValidToken_RefreshFails tests that nothing is stored when the
+// auth server rejects the refresh token
+func TestEnsureValidToken_RefreshFails(t *testing.T) {
+	authServer := newTokenServer(t)
+	authServer.reject = true
+	mock := newMockStorage()
+	session := expiringSession(authServer.URL, -1*time.Minute)
+
+	err := oauth.EnsureValidToken(context.Background(), session, mock, testConfig())
+	if !errors.Is(err, oauth.ErrSessionInvalid) {
+		t.Fatalf("Expected ErrSessionInvalid for a rejected refresh, got: %v", err)
+	}
+	if mock.updateTokensCalled {
+		t.Error("Expected no tokens to be stored")
+	}
+	if session.AccessToken != "old-token" {
+		t.Errorf("Expected the session to keep its token, got %s", session.AccessToken)
+	}
 }
 
 // TestAPIHandlersCallEnsureValidToken tests that API handlers properly use
@@ -63,7 +141,7 @@ func TestAPIHandlersCallEnsureValidToken(t *testing.T) {
 	// 5. If EnsureValidToken fails, the handler returns appropriate error
 }
 
-// Example of how to test with a mock storage
+// mockStorage is an oauth.SessionTokenUpdater that records the tokens it stores
 type mockStorage struct {
 	updateTokensCalled bool
 	sessionsUpdated    map[string]tokenUpdate
@@ -91,26 +169,57 @@ func (m *mockStorage) UpdateSessionTokens(ctx context.Context, id, accessToken,
 	return nil
 }
 
-// TestMockStoragePattern demonstrates the pattern for testing with mock storage
-func TestMockStoragePattern(t *testing.T) {
-	t.Skip("Example pattern only")
+// tokenServer is a mock auth server whose token endpoint refreshes tokens
+type tokenServer struct {
+	*httptest.Server
+	refreshes int
+	reject    bool // answer invalid_grant
+}
 
-	// This demonstrates how we would test EnsureValidToken with a mock:
-	mock := newMockStorage()
+func newTokenServer(t *testing.T) *tokenServer {
+	s := &tokenServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Write([]byte(`{"token_endpoint":"` + s.URL + `/token"}`))
+			return
+		}
+
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh-token" {
+			t.Errorf("Unexpected token request %v", r.Form)
+		}
+		if s.reject {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		s.refreshes++
+		w.Write([]byte(`{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"DPoP","expires_in":3600}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
 
-	expiresAt := time.Now().Add(-1 * time.Minute) // Expired
-	session := &oauth.OAuthSession{
+// expiringSession returns a session issued by issuer whose access token
+// expires after expiresIn
+func expiringSession(issuer string, expiresIn time.Duration) *oauth.OAuthSession {
+	expiresAt := time.Now().Add(expiresIn)
+	return &oauth.OAuthSession{
 		ID:             "test-session",
 		DID:            "did:plc:test123",
 		AccessToken:    "old-token",
 		RefreshToken:   "refresh-token",
-		DPoPKey:        "dpop-key",
-		Issuer:         "https://auth.example.com",
+		DPoPKey:        oauth.GenerateSecretJWK(),
+		PDSUrl:         "https://pds.example.com",
+		Issuer:         issuer,
 		TokenExpiresAt: &expiresAt,
 	}
+}
 
-	// Would need to setup mock HTTP server for auth endpoint
-	// Then call EnsureValidToken and verify mock.updateTokensCalled is true
-	_ = session
-	_ = mock
+func testConfig() oauth.Config {
+	return oauth.Config{
+		Host:      "survey.openmeet.net",
+		SecretJWK: oauth.GenerateSecretJWK(),
+	}
 }
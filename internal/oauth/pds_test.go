@@ -1,7 +1,9 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -225,7 +227,7 @@ func TestRefreshToken(t *testing.T) {
 			TokenExpiresAt: &expiredTime,
 		}
 
-		newToken, newRefresh, expiresIn, err := RefreshAccessToken(session, authServer.URL, "client-id", GenerateSecretJWK())
+		newToken, newRefresh, expiresIn, err := RefreshAccessToken(context.Background(), session, authServer.URL, "client-id", GenerateSecretJWK())
 		if err != nil {
 			t.Fatalf("RefreshAccessToken failed: %v", err)
 		}
@@ -242,7 +244,7 @@ func TestRefreshToken(t *testing.T) {
 	})
 
 	t.Run("returns error for nil session", func(t *testing.T) {
-		_, _, _, err := RefreshAccessToken(nil, "https://auth.example.com", "client-id", GenerateSecretJWK())
+		_, _, _, err := RefreshAccessToken(context.Background(), nil, "https://auth.example.com", "client-id", GenerateSecretJWK())
 		if err == nil {
 			t.Error("Expected error for nil session")
 		}
@@ -253,7 +255,7 @@ func TestRefreshToken(t *testing.T) {
 			DID:         "did:plc:test",
 			AccessToken: "test-token",
 		}
-		_, _, _, err := RefreshAccessToken(session, "https://auth.example.com", "client-id", GenerateSecretJWK())
+		_, _, _, err := RefreshAccessToken(context.Background(), session, "https://auth.example.com", "client-id", GenerateSecretJWK())
 		if err == nil {
 			t.Error("Expected error for missing refresh token")
 		}
@@ -468,3 +470,70 @@ func TestUpdateRecord(t *testing.T) {
 		}
 	})
 }
+
+// TestSwapRecord tests updating a record only if it's unchanged
+func TestSwapRecord(t *testing.T) {
+	var swapped string
+	pdsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+
+		w.Header().Set("Content-Type", "application/json")
+		if payload["swapRecord"] != "bafycurrent" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"InvalidSwap","message":"Record was at bafycurrent"}`))
+			return
+		}
+		swapped = payload["swapRecord"].(string)
+		w.Write([]byte(`{"uri":"at://did:plc:test123/net.openmeet.survey/abc123","cid":"bafynewcid"}`))
+	}))
+	defer pdsServer.Close()
+
+	tokenExpiresAt := time.Now().Add(1 * time.Hour)
+	session := &OAuthSession{
+		DID:            "did:plc:test123",
+		AccessToken:    "test-access-token",
+		DPoPKey:        GenerateSecretJWK(),
+		PDSUrl:         pdsServer.URL,
+		TokenExpiresAt: &tokenExpiresAt,
+	}
+	record := map[string]interface{}{"name": "Updated"}
+
+	t.Run("writes when the record is unchanged", func(t *testing.T) {
+		_, cid, err := SwapRecord(session, "net.openmeet.survey", "abc123", record, "bafycurrent")
+		if err != nil {
+			t.Fatalf("SwapRecord failed: %v", err)
+		}
+		if cid != "bafynewcid" || swapped != "bafycurrent" {
+			t.Errorf("Expected a swap from bafycurrent to bafynewcid, got %q to %q", swapped, cid)
+		}
+	})
+
+	t.Run("reports a changed record", func(t *testing.T) {
+		_, _, err := SwapRecord(session, "net.openmeet.survey", "abc123", record, "bafystale")
+		if !errors.Is(err, ErrRecordChanged) {
+			t.Errorf("Expected ErrRecordChanged, got %v", err)
+		}
+	})
+}
+
+// TestGetRecord tests fetching a single record
+func TestGetRecord(t *testing.T) {
+	pdsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/com.atproto.repo.getRecord" || r.URL.Query().Get("rkey") != "abc123" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"uri":"at://did:plc:test123/net.openmeet.survey/abc123","cid":"bafycurrent","value":{"name":"Lunch","lang":"es"}}`))
+	}))
+	defer pdsServer.Close()
+
+	record, err := GetRecord(pdsServer.URL, "did:plc:test123", "net.openmeet.survey", "abc123")
+	if err != nil {
+		t.Fatalf("GetRecord failed: %v", err)
+	}
+	if record.CID != "bafycurrent" || record.RKey != "abc123" || record.Value["lang"] != "es" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+}
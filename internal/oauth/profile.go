@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -54,7 +55,7 @@ func GetProfile(did string) (*Profile, error) {
 	}
 
 	// Fetch from API
-	profile, err := fetchProfileFromAPI(did, defaultBlueskyAPIURL)
+	profile, err := fetchProfileFromAPI(context.Background(), http.DefaultClient, did, defaultBlueskyAPIURL)
 	if err != nil {
 		return nil, err
 	}
@@ -70,9 +71,9 @@ func GetProfile(did string) (*Profile, error) {
 	return profile, nil
 }
 
-// fetchProfileFromAPI fetches a profile from the Bluesky API
+// fetchProfileFromAPI fetches a profile from the Bluesky API with client
 // The baseURL parameter allows testing with a mock server
-func fetchProfileFromAPI(did, baseURL string) (*Profile, error) {
+func fetchProfileFromAPI(ctx context.Context, client *http.Client, did, baseURL string) (*Profile, error) {
 	// Build request URL
 	endpoint := fmt.Sprintf("%s/xrpc/app.bsky.actor.getProfile", baseURL)
 	params := url.Values{}
@@ -81,7 +82,11 @@ func fetchProfileFromAPI(did, baseURL string) (*Profile, error) {
 	reqURL := fmt.Sprintf("%s?%s", endpoint, params.Encode())
 
 	// Make HTTP request
-	resp, err := http.Get(reqURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch profile: %w", err)
 	}
@@ -0,0 +1,101 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// ProfileCacheTTL is how long a cached profile is served before it is
+	// refreshed in the background
+	ProfileCacheTTL = 24 * time.Hour
+
+	// profileRefreshTimeout bounds a profile refresh, which may outlive the
+	// request that started it
+	profileRefreshTimeout = 10 * time.Second
+)
+
+// ProfileStore caches profiles. *Storage implements it.
+type ProfileStore interface {
+	GetProfile(ctx context.Context, did string) (*StoredProfile, error)
+	UpsertProfile(ctx context.Context, profile *Profile) error
+}
+
+// ProfileCache serves Bluesky profiles from a ProfileStore, fetching them
+// from the AppView on a miss. A profile older than ProfileCacheTTL is still
+// served, and refreshed in the background (stale-while-revalidate).
+type ProfileCache struct {
+	store     ProfileStore
+	fetch     func(did string) (*Profile, error)
+	ttl       time.Duration
+	now       func() time.Time
+	refreshes singleflight.Group // one fetch per DID at a time
+}
+
+// NewProfileCache creates a ProfileCache backed by store
+func NewProfileCache(store ProfileStore) *ProfileCache {
+	return &ProfileCache{
+		store: store,
+		fetch: func(did string) (*Profile, error) {
+			return fetchProfileFromAPI(context.Background(), http.DefaultClient, did, defaultBlueskyAPIURL)
+		},
+		ttl: ProfileCacheTTL,
+		now: time.Now,
+	}
+}
+
+// profileStale reports whether a profile fetched at fetchedAt is due for a
+// refresh
+func profileStale(fetchedAt time.Time, ttl time.Duration, now time.Time) bool {
+	return !now.Before(fetchedAt.Add(ttl))
+}
+
+// Get returns did's profile. A cached profile is returned right away, even
+// if stale; otherwise the profile is fetched, and an error means none is
+// available.
+func (p *ProfileCache) Get(ctx context.Context, did string) (*Profile, error) {
+	stored, err := p.store.GetProfile(ctx, did)
+	if err == nil {
+		if profileStale(stored.FetchedAt, p.ttl, p.now()) {
+			p.refreshes.DoChan(did, func() (interface{}, error) {
+				return p.refresh(did)
+			})
+		}
+		return &stored.Profile, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error reading cached profile for %s: %v", did, err)
+	}
+
+	profile, err, _ := p.refreshes.Do(did, func() (interface{}, error) {
+		return p.refresh(did)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return profile.(*Profile), nil
+}
+
+// refresh fetches did's profile and caches it. It doesn't use the caller's
+// context, since a background refresh outlives the request.
+func (p *ProfileCache) refresh(did string) (*Profile, error) {
+	profile, err := p.fetch(did)
+	if err != nil {
+		return nil, err
+	}
+	profile.DID = did
+
+	ctx, cancel := context.WithTimeout(context.Background(), profileRefreshTimeout)
+	defer cancel()
+	if err := p.store.UpsertProfile(ctx, profile); err != nil {
+		log.Printf("Error caching profile for %s: %v", did, err)
+	}
+
+	return profile, nil
+}
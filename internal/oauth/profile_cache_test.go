@@ -0,0 +1,157 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProfileStore is an in-memory ProfileStore
+type fakeProfileStore struct {
+	mu       sync.Mutex
+	profiles map[string]StoredProfile
+	upserted chan string // receives each upserted DID, if set
+}
+
+func (s *fakeProfileStore) GetProfile(_ context.Context, did string) (*StoredProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.profiles[did]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &stored, nil
+}
+
+func (s *fakeProfileStore) UpsertProfile(_ context.Context, profile *Profile) error {
+	s.mu.Lock()
+	s.profiles[profile.DID] = StoredProfile{Profile: *profile, FetchedAt: time.Now()}
+	s.mu.Unlock()
+	if s.upserted != nil {
+		s.upserted <- profile.DID
+	}
+	return nil
+}
+
+func TestProfileStale(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		fetchedAt time.Time
+		want      bool
+	}{
+		{"just fetched", now, false},
+		{"within TTL", now.Add(-ProfileCacheTTL + time.Second), false},
+		{"exactly at TTL", now.Add(-ProfileCacheTTL), true},
+		{"past TTL", now.Add(-ProfileCacheTTL - time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := profileStale(tt.fetchedAt, ProfileCacheTTL, now); got != tt.want {
+				t.Errorf("profileStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileCacheGet(t *testing.T) {
+	ctx := context.Background()
+	alice := Profile{DID: "did:plc:alice", Handle: "alice.test", DisplayName: "Alice"}
+
+	newCache := func(store *fakeProfileStore, fetch func(did string) (*Profile, error)) *ProfileCache {
+		cache := NewProfileCache(store)
+		cache.fetch = fetch
+		return cache
+	}
+
+	t.Run("serves a fresh profile without fetching", func(t *testing.T) {
+		store := &fakeProfileStore{profiles: map[string]StoredProfile{
+			alice.DID: {Profile: alice, FetchedAt: time.Now()},
+		}}
+		cache := newCache(store, func(did string) (*Profile, error) {
+			t.Error("Expected no fetch for a fresh profile")
+			return nil, errors.New("unexpected fetch")
+		})
+
+		profile, err := cache.Get(ctx, alice.DID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if profile.DisplayName != "Alice" {
+			t.Errorf("Expected the cached profile, got %+v", profile)
+		}
+	})
+
+	t.Run("serves a stale profile and refreshes it in the background", func(t *testing.T) {
+		store := &fakeProfileStore{
+			profiles: map[string]StoredProfile{
+				alice.DID: {Profile: alice, FetchedAt: time.Now().Add(-ProfileCacheTTL - time.Minute)},
+			},
+			upserted: make(chan string, 1),
+		}
+		release := make(chan struct{})
+		cache := newCache(store, func(did string) (*Profile, error) {
+			<-release
+			return &Profile{Handle: "alice.test", DisplayName: "Alice Renamed"}, nil
+		})
+
+		profile, err := cache.Get(ctx, alice.DID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if profile.DisplayName != "Alice" {
+			t.Errorf("Expected the stale profile right away, got %+v", profile)
+		}
+
+		close(release)
+		select {
+		case did := <-store.upserted:
+			if did != alice.DID {
+				t.Errorf("Expected %s to be refreshed, got %s", alice.DID, did)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the stale profile to be refreshed")
+		}
+
+		profile, err = cache.Get(ctx, alice.DID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if profile.DisplayName != "Alice Renamed" {
+			t.Errorf("Expected the refreshed profile, got %+v", profile)
+		}
+	})
+
+	t.Run("fetches and stores a profile on a miss", func(t *testing.T) {
+		store := &fakeProfileStore{profiles: map[string]StoredProfile{}}
+		cache := newCache(store, func(did string) (*Profile, error) {
+			return &Profile{Handle: "alice.test", DisplayName: "Alice"}, nil
+		})
+
+		profile, err := cache.Get(ctx, alice.DID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if profile.DID != alice.DID || profile.DisplayName != "Alice" {
+			t.Errorf("Expected the fetched profile, got %+v", profile)
+		}
+		if _, ok := store.profiles[alice.DID]; !ok {
+			t.Error("Expected the fetched profile to be stored")
+		}
+	})
+
+	t.Run("returns an error when nothing is cached and the fetch fails", func(t *testing.T) {
+		store := &fakeProfileStore{profiles: map[string]StoredProfile{}}
+		cache := newCache(store, func(did string) (*Profile, error) {
+			return nil, errors.New("appview unavailable")
+		})
+
+		if _, err := cache.Get(ctx, alice.DID); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
@@ -1,10 +1,12 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,7 +33,7 @@ func TestFetchProfile(t *testing.T) {
 		defer server.Close()
 
 		// Fetch profile
-		profile, err := fetchProfileFromAPI("did:plc:test123", server.URL)
+		profile, err := fetchProfileFromAPI(context.Background(), http.DefaultClient, "did:plc:test123", server.URL)
 		require.NoError(t, err)
 		require.NotNil(t, profile)
 
@@ -53,7 +55,7 @@ func TestFetchProfile(t *testing.T) {
 		}))
 		defer server.Close()
 
-		profile, err := fetchProfileFromAPI("did:plc:test123", server.URL)
+		profile, err := fetchProfileFromAPI(context.Background(), http.DefaultClient, "did:plc:test123", server.URL)
 		require.NoError(t, err)
 		require.NotNil(t, profile)
 
@@ -69,7 +71,7 @@ func TestFetchProfile(t *testing.T) {
 		}))
 		defer server.Close()
 
-		profile, err := fetchProfileFromAPI("did:plc:test123", server.URL)
+		profile, err := fetchProfileFromAPI(context.Background(), http.DefaultClient, "did:plc:test123", server.URL)
 		assert.Error(t, err)
 		assert.Nil(t, profile)
 		assert.Contains(t, err.Error(), "unexpected status code")
@@ -82,10 +84,27 @@ func TestFetchProfile(t *testing.T) {
 		}))
 		defer server.Close()
 
-		profile, err := fetchProfileFromAPI("did:plc:test123", server.URL)
+		profile, err := fetchProfileFromAPI(context.Background(), http.DefaultClient, "did:plc:test123", server.URL)
 		assert.Error(t, err)
 		assert.Nil(t, profile)
 	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		profile, err := fetchProfileFromAPI(ctx, http.DefaultClient, "did:plc:test123", server.URL)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, profile)
+		assert.Less(t, time.Since(start), time.Second)
+	})
 }
 
 func TestGetProfile(t *testing.T) {
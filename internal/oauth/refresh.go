@@ -2,68 +2,270 @@ package oauth
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultRefreshThreshold is how long before expiry EnsureValidToken
+	// refreshes an access token, unless Config.RefreshThreshold is set
+	DefaultRefreshThreshold = 5 * time.Minute
+
+	// MaxRefreshThreshold caps Config.RefreshThreshold. A larger margin would
+	// refresh even long-lived tokens on nearly every request.
+	MaxRefreshThreshold = time.Hour
+
+	// refreshTimeout bounds a shared refresh, so a hung authorization server
+	// releases the session's refresh slot instead of holding it forever
+	refreshTimeout = 30 * time.Second
+)
+
+// refreshFlights runs at most one refresh per session ID at a time. AT
+// Protocol refresh tokens are single-use, so concurrent requests refreshing
+// the same session would otherwise all but one fail with invalid_grant.
+var refreshFlights singleflight.Group
+
+// Errors from EnsureValidToken and RefreshAccessToken wrap one of these so
+// callers can tell a session that must be discarded from a failure worth
+// retrying. Test with errors.Is.
+var (
+	// ErrSessionInvalid means the session can't be refreshed: it is missing
+	// what a refresh needs, or the authorization server rejected its refresh
+	// token with invalid_grant. The user has to log in again.
+	ErrSessionInvalid = errors.New("oauth session invalid")
+
+	// ErrRefreshTransient means the refresh failed for a reason unrelated to
+	// the session, such as a network error, a 5xx from the authorization
+	// server or any rejection other than invalid_grant. The session is kept
+	// and the request can be retried later.
+	ErrRefreshTransient = errors.New("oauth token refresh temporarily failed")
 )
 
+// refreshError tags err with ErrSessionInvalid or ErrRefreshTransient while
+// keeping err's message
+type refreshError struct {
+	kind   error
+	err    error
+	result string // TokenRefreshTotal result
+}
+
+func (e *refreshError) Error() string {
+	return e.err.Error()
+}
+
+func (e *refreshError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// sessionInvalid marks err, a rejection by the auth server, as ErrSessionInvalid
+func sessionInvalid(err error) error {
+	return &refreshError{kind: ErrSessionInvalid, err: err, result: RefreshResultInvalidGrant}
+}
+
+// missingFields marks err, a session lacking what a refresh needs, as
+// ErrSessionInvalid
+func missingFields(err error) error {
+	return &refreshError{kind: ErrSessionInvalid, err: err, result: RefreshResultMissingFields}
+}
+
+// refreshTransient marks err as ErrRefreshTransient
+func refreshTransient(err error) error {
+	return &refreshError{kind: ErrRefreshTransient, err: err, result: RefreshResultTransientError}
+}
+
+// refreshStatusError classifies a failed token endpoint response. Only
+// invalid_grant, the refresh token itself being rejected, invalidates the
+// session. Anything else is transient: server errors, rate limiting and a
+// nonce still rejected after the retry are expected to pass, and other
+// rejections (such as invalid_client) point at our client configuration
+// rather than the user's session, so they're logged loudly instead of
+// logging every user out.
+func refreshStatusError(status int, body []byte) error {
+	err := fmt.Errorf("token refresh failed with status %d: %s", status, string(body))
+
+	var errorResp struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &errorResp) == nil && errorResp.Error == "invalid_grant" {
+		return sessionInvalid(err)
+	}
+
+	if status < http.StatusInternalServerError && status != http.StatusTooManyRequests && !requiresDPoPNonce(status, body) {
+		log.Printf("ERROR: Unexpected token refresh rejection, keeping the session: %v", err)
+	}
+	return refreshTransient(err)
+}
+
+// SessionTokenUpdater stores a session's refreshed tokens. *Storage
+// implements it.
+type SessionTokenUpdater interface {
+	UpdateSessionTokens(ctx context.Context, id, accessToken, refreshToken string, tokenExpiresAt *time.Time) error
+}
+
+// sessionLoader is implemented by token updaters that can also load a
+// session, letting EnsureValidToken see a refresh made by another request
+// or process since the caller loaded its copy
+type sessionLoader interface {
+	GetSessionByID(ctx context.Context, id string) (*OAuthSession, error)
+}
+
+// refreshedTokens is the outcome of a refresh, shared by every caller that
+// waited on it
+type refreshedTokens struct {
+	accessToken    string
+	refreshToken   string
+	tokenExpiresAt *time.Time
+}
+
+// refreshTrigger is what caused a refresh: a request about to use the token
+// (lazy) or the background refresh worker (proactive)
+type refreshTrigger struct {
+	name   string        // metric label
+	window time.Duration // tokens expiring within this are refreshed
+}
+
+// lazyRefresh is the trigger for refreshes from EnsureValidToken
+func lazyRefresh(config Config) refreshTrigger {
+	return refreshTrigger{name: "lazy", window: config.refreshThreshold()}
+}
+
+// needsRefresh reports whether a token expiring at expiresAt should be
+// refreshed at now, given a refresh window. A token expiring exactly window
+// from now is refreshed. A nil expiry is treated as valid.
+func needsRefresh(expiresAt *time.Time, window time.Duration, now time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(now.Add(window))
+}
+
 // EnsureValidToken checks if the access token is valid and refreshes it if necessary.
 // Returns nil if token is valid or was successfully refreshed.
 // Returns error if refresh is needed but fails (caller should invalidate session).
 //
 // Token is considered valid if:
 // - TokenExpiresAt is nil (no expiration set)
-// - TokenExpiresAt is more than 5 minutes in the future
+// - TokenExpiresAt is more than config.RefreshThreshold (default 5m) away
 //
 // Token refresh is attempted if:
-// - TokenExpiresAt is in the past or within 5 minutes
-func EnsureValidToken(ctx context.Context, session *OAuthSession, storage *Storage, config Config) error {
+// - TokenExpiresAt is in the past or at most config.RefreshThreshold away
+//
+// Errors wrap ErrSessionInvalid when the user must log in again and
+// ErrRefreshTransient when the refresh may succeed if retried.
+//
+// Concurrent calls for the same session share a single refresh. If storage
+// can load sessions, as *Storage can, a caller holding a session loaded
+// before another request refreshed it picks up the stored tokens instead of
+// reusing the spent refresh token.
+func EnsureValidToken(ctx context.Context, session *OAuthSession, storage SessionTokenUpdater, config Config) error {
 	if session == nil {
 		return fmt.Errorf("session cannot be nil")
 	}
 
-	// If no expiration time set, treat as valid
-	if session.TokenExpiresAt == nil {
-		return nil
-	}
-
-	// Check if token is still valid (expires more than 5 minutes from now)
-	threshold := time.Now().Add(5 * time.Minute)
-	if session.TokenExpiresAt.After(threshold) {
+	trigger := lazyRefresh(config)
+	if !needsRefresh(session.TokenExpiresAt, trigger.window, time.Now()) {
 		// Token is still valid, no refresh needed
 		return nil
 	}
 
 	// Token is expired or expiring soon, need to refresh
 	// Verify we have the required fields for refresh
-	if session.Issuer == "" {
-		return fmt.Errorf("cannot refresh token: session missing issuer")
+	var missing error
+	switch {
+	case session.Issuer == "":
+		missing = missingFields(fmt.Errorf("cannot refresh token: session missing issuer"))
+	case session.RefreshToken == "":
+		missing = missingFields(fmt.Errorf("cannot refresh token: session missing refresh token"))
+	case session.DPoPKey == "":
+		missing = missingFields(fmt.Errorf("cannot refresh token: session missing DPoP key"))
+	}
+	if missing != nil {
+		TokenRefreshTotal.WithLabelValues(trigger.name, RefreshResultMissingFields).Inc()
+		return missing
 	}
 
-	if session.RefreshToken == "" {
-		return fmt.Errorf("cannot refresh token: session missing refresh token")
+	if storage == nil {
+		return fmt.Errorf("cannot refresh token: storage is nil")
 	}
 
-	if session.DPoPKey == "" {
-		return fmt.Errorf("cannot refresh token: session missing DPoP key")
+	tokens, err := sharedRefresh(ctx, session.ID, session, storage, config, trigger)
+	if err != nil {
+		return err
 	}
 
-	if storage == nil {
-		return fmt.Errorf("cannot refresh token: storage is nil")
+	// Update the session object in memory
+	session.AccessToken = tokens.accessToken
+	session.RefreshToken = tokens.refreshToken
+	session.TokenExpiresAt = tokens.tokenExpiresAt
+
+	return nil
+}
+
+// sharedRefresh refreshes session's tokens in the refreshFlights slot for id,
+// joining a refresh already in flight for the session. The refresh is shared,
+// so it isn't cancelled with whichever caller happened to start it; it runs
+// for at most refreshTimeout, and each caller stops waiting when its own ctx
+// is done.
+func sharedRefresh(ctx context.Context, id string, session *OAuthSession, storage SessionTokenUpdater, config Config, trigger refreshTrigger) (*refreshedTokens, error) {
+	results := refreshFlights.DoChan(id, func() (interface{}, error) {
+		flightCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+		return refreshSessionTokens(flightCtx, session, storage, config, trigger)
+	})
+
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*refreshedTokens), nil
+	case <-ctx.Done():
+		return nil, refreshTransient(fmt.Errorf("stopped waiting for token refresh: %w", ctx.Err()))
+	}
+}
+
+// refreshSessionTokens refreshes session's tokens and stores them, unless the
+// stored session was already refreshed since session was loaded. Callers
+// must hold session's refreshFlights slot; see sharedRefresh.
+func refreshSessionTokens(ctx context.Context, session *OAuthSession, storage SessionTokenUpdater, config Config, trigger refreshTrigger) (*refreshedTokens, error) {
+	current := session
+	if loader, ok := storage.(sessionLoader); ok {
+		stored, err := loader.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			return nil, refreshTransient(fmt.Errorf("failed to reload session: %w", err))
+		}
+		if !needsRefresh(stored.TokenExpiresAt, trigger.window, time.Now()) {
+			return &refreshedTokens{
+				accessToken:    stored.AccessToken,
+				refreshToken:   stored.RefreshToken,
+				tokenExpiresAt: stored.TokenExpiresAt,
+			}, nil
+		}
+		current = stored
 	}
 
-	// Build client ID from config
-	clientID := fmt.Sprintf("https://%s/oauth/client-metadata.json", config.Host)
+	// Refresh as the client the session was issued to
+	clientID := config.clientID(session.Host)
 
 	// Attempt to refresh the token
+	_, span := startRefreshSpan(ctx, session.Issuer, trigger)
+	start := time.Now()
 	newAccessToken, newRefreshToken, expiresIn, err := RefreshAccessToken(
-		session,
+		ctx,
+		current,
 		session.Issuer,
 		clientID,
 		config.SecretJWK,
 	)
+	TokenRefreshDuration.WithLabelValues(trigger.name).Observe(time.Since(start).Seconds())
+	TokenRefreshTotal.WithLabelValues(trigger.name, refreshResult(err)).Inc()
+	endSpan(span, err)
 
 	if err != nil {
-		return fmt.Errorf("token refresh failed: %w", err)
+		return nil, fmt.Errorf("token refresh failed: %w", err)
 	}
 
 	// Calculate new expiration time
@@ -76,13 +278,12 @@ func EnsureValidToken(ctx context.Context, session *OAuthSession, storage *Stora
 	// Update session in database
 	err = storage.UpdateSessionTokens(ctx, session.ID, newAccessToken, newRefreshToken, newExpiresAt)
 	if err != nil {
-		return fmt.Errorf("failed to update session tokens: %w", err)
+		return nil, refreshTransient(fmt.Errorf("failed to update session tokens: %w", err))
 	}
 
-	// Update the session object in memory
-	session.AccessToken = newAccessToken
-	session.RefreshToken = newRefreshToken
-	session.TokenExpiresAt = newExpiresAt
-
-	return nil
+	return &refreshedTokens{
+		accessToken:    newAccessToken,
+		refreshToken:   newRefreshToken,
+		tokenExpiresAt: newExpiresAt,
+	}, nil
 }
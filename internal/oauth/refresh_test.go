@@ -2,8 +2,21 @@ package oauth
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // TestEnsureValidToken_ValidToken tests that no refresh happens when token is still valid
@@ -41,16 +54,6 @@ func TestEnsureValidToken_ValidToken(t *testing.T) {
 	}
 }
 
-// TestEnsureValidToken_ExpiredToken tests that refresh happens when token is expired
-func TestEnsureValidToken_ExpiredToken(t *testing.T) {
-	t.Skip("TODO: Implement with mock HTTP server for token endpoint")
-}
-
-// TestEnsureValidToken_ExpiringToken tests that refresh happens when token expires within 5 minutes
-func TestEnsureValidToken_ExpiringToken(t *testing.T) {
-	t.Skip("TODO: Implement with mock HTTP server for token endpoint")
-}
-
 // TestEnsureValidToken_MissingIssuer tests that refresh fails when issuer is missing
 func TestEnsureValidToken_MissingIssuer(t *testing.T) {
 	expiresAt := time.Now().Add(-1 * time.Minute) // Expired
@@ -70,16 +73,27 @@ func TestEnsureValidToken_MissingIssuer(t *testing.T) {
 		SecretJWK: "test-key",
 	}
 
+	missing := TokenRefreshTotal.WithLabelValues("lazy", RefreshResultMissingFields)
+	before := testutil.ToFloat64(missing)
+
 	ctx := context.Background()
 	err := EnsureValidToken(ctx, session, nil, config)
 
 	if err == nil {
-		t.Error("Expected error for missing issuer")
+		t.Fatal("Expected error for missing issuer")
 	}
 
 	if err.Error() != "cannot refresh token: session missing issuer" {
 		t.Errorf("Expected 'missing issuer' error, got: %v", err)
 	}
+
+	if !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Expected ErrSessionInvalid, got: %v", err)
+	}
+
+	if got := testutil.ToFloat64(missing) - before; got != 1 {
+		t.Errorf("Expected the missing_fields counter to increase by 1, got %v", got)
+	}
 }
 
 // TestEnsureValidToken_MissingRefreshToken tests that refresh fails when refresh token is missing
@@ -111,6 +125,10 @@ func TestEnsureValidToken_MissingRefreshToken(t *testing.T) {
 	if err.Error() != "cannot refresh token: session missing refresh token" {
 		t.Errorf("Expected 'missing refresh token' error, got: %v", err)
 	}
+
+	if !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Expected ErrSessionInvalid, got: %v", err)
+	}
 }
 
 // TestEnsureValidToken_NilTokenExpiresAt tests that we treat nil expiration as valid
@@ -154,3 +172,483 @@ func TestStorageGetSessionByID_RetrievesIssuer(t *testing.T) {
 func TestCreateSession_StoresIssuer(t *testing.T) {
 	t.Skip("TODO: Integration test - requires database with migration")
 }
+
+// TestEnsureValidToken_ConcurrentRefresh tests that concurrent requests on a
+// session with an expired token share one refresh, since the auth server
+// only honors each refresh token once
+func TestEnsureValidToken_ConcurrentRefresh(t *testing.T) {
+	var refreshes atomic.Int32
+	var authServerURL string
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token_endpoint":"` + authServerURL + `/token"}`))
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("refresh_token") != "refresh-token" || refreshes.Add(1) > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		// Give the other requests time to pile up behind this refresh
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"DPoP","expires_in":3600}`))
+	}))
+	defer authServer.Close()
+	authServerURL = authServer.URL
+
+	expiresAt := time.Now().Add(-1 * time.Minute)
+	dpopKey := GenerateSecretJWK()
+	fake := queriestest.New(t)
+	fake.Expect("FROM oauth_sessions").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		[]interface{}{"concurrent-session", "did:plc:test123", "expired-token", "refresh-token", dpopKey, "https://pds.example.com", expiresAt, authServer.URL, "", time.Now(), time.Now().Add(time.Hour)},
+	)
+	fake.Expect("UPDATE oauth_sessions").RowsAffected(1)
+	storage := NewStorage(fake.DB)
+
+	config := Config{
+		Host:      "survey.openmeet.net",
+		SecretJWK: GenerateSecretJWK(),
+	}
+
+	const requests = 10
+	sessions := make([]*OAuthSession, requests)
+	errs := make([]error, requests)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range sessions {
+		// Each request loads its own copy of the session
+		sessions[i] = &OAuthSession{
+			ID:             "concurrent-session",
+			DID:            "did:plc:test123",
+			AccessToken:    "expired-token",
+			RefreshToken:   "refresh-token",
+			DPoPKey:        dpopKey,
+			PDSUrl:         "https://pds.example.com",
+			TokenExpiresAt: &expiresAt,
+			Issuer:         authServer.URL,
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = EnsureValidToken(context.Background(), sessions[i], storage, config)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Request %d: expected no error, got: %v", i, err)
+			continue
+		}
+		if sessions[i].AccessToken != "new-access-token" || sessions[i].RefreshToken != "new-refresh-token" {
+			t.Errorf("Request %d: expected the refreshed tokens, got %q, %q", i, sessions[i].AccessToken, sessions[i].RefreshToken)
+		}
+		if sessions[i].TokenExpiresAt == nil || !sessions[i].TokenExpiresAt.After(time.Now().Add(time.Hour-time.Minute)) {
+			t.Errorf("Request %d: expected the new expiry, got %v", i, sessions[i].TokenExpiresAt)
+		}
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("Expected 1 refresh, got %d", n)
+	}
+	if n := len(fake.CallsMatching("UPDATE oauth_sessions")); n != 1 {
+		t.Errorf("Expected the tokens to be stored once, got %d", n)
+	}
+}
+
+// TestEnsureValidToken_CallerStopsWaiting tests that a request whose context
+// ends stops waiting for a refresh held up by the authorization server, while
+// the refresh carries on for the requests still waiting on it
+func TestEnsureValidToken_CallerStopsWaiting(t *testing.T) {
+	release := make(chan struct{})
+	var refreshes atomic.Int32
+	var authServerURL string
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token_endpoint":"` + authServerURL + `/token"}`))
+			return
+		}
+		if refreshes.Add(1) > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"DPoP","expires_in":3600}`))
+	}))
+	defer authServer.Close()
+	authServerURL = authServer.URL
+
+	expiresAt := time.Now().Add(-1 * time.Minute)
+	dpopKey := GenerateSecretJWK()
+	fake := queriestest.New(t)
+	fake.Expect("FROM oauth_sessions").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		[]interface{}{"waiting-session", "did:plc:test123", "expired-token", "refresh-token", dpopKey, "https://pds.example.com", expiresAt, authServer.URL, "", time.Now(), time.Now().Add(time.Hour)},
+	)
+	fake.Expect("UPDATE oauth_sessions").RowsAffected(1)
+	storage := NewStorage(fake.DB)
+	config := Config{
+		Host:      "survey.openmeet.net",
+		SecretJWK: GenerateSecretJWK(),
+	}
+	newSession := func() *OAuthSession {
+		return &OAuthSession{
+			ID:             "waiting-session",
+			DID:            "did:plc:test123",
+			AccessToken:    "expired-token",
+			RefreshToken:   "refresh-token",
+			DPoPKey:        dpopKey,
+			PDSUrl:         "https://pds.example.com",
+			TokenExpiresAt: &expiresAt,
+			Issuer:         authServer.URL,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := EnsureValidToken(ctx, newSession(), storage, config)
+	if !errors.Is(err, ErrRefreshTransient) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a transient deadline error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request to stop waiting at its deadline, waited %v", elapsed)
+	}
+
+	// The refresh the first request started is still running; a later
+	// request joins it rather than spending the refresh token again
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+	session := newSession()
+	if err := EnsureValidToken(context.Background(), session, storage, config); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if session.AccessToken != "new-access-token" {
+		t.Errorf("Expected the refreshed access token, got %q", session.AccessToken)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("Expected 1 refresh, got %d", n)
+	}
+}
+
+// TestEnsureValidToken_ClientIDPerHost tests that a session refreshes as the
+// client of the host it was created on, and that sessions stored without a
+// host refresh as the primary host's client
+func TestEnsureValidToken_ClientIDPerHost(t *testing.T) {
+	config := Config{
+		Host:            "survey.openmeet.net",
+		AdditionalHosts: []string{"staging.openmeet.net"},
+		SecretJWK:       GenerateSecretJWK(),
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		clientID string
+	}{
+		{"primary host", "survey.openmeet.net", "https://survey.openmeet.net/oauth/client-metadata.json"},
+		{"additional host", "staging.openmeet.net", "https://staging.openmeet.net/oauth/client-metadata.json"},
+		{"no stored host", "", "https://survey.openmeet.net/oauth/client-metadata.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var clientID string
+			var authServerURL string
+			authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/.well-known/oauth-authorization-server" {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"token_endpoint":"` + authServerURL + `/token"}`))
+					return
+				}
+				r.ParseForm()
+				clientID = r.Form.Get("client_id")
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"DPoP","expires_in":3600}`))
+			}))
+			defer authServer.Close()
+			authServerURL = authServer.URL
+
+			expiresAt := time.Now().Add(-1 * time.Minute)
+			dpopKey := GenerateSecretJWK()
+			fake := queriestest.New(t)
+			fake.Expect("FROM oauth_sessions").Rows(
+				[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+				[]interface{}{"host-session", "did:plc:test123", "expired-token", "refresh-token", dpopKey, "https://pds.example.com", expiresAt, authServer.URL, tt.host, time.Now(), time.Now().Add(time.Hour)},
+			)
+			fake.Expect("UPDATE oauth_sessions").RowsAffected(1)
+
+			session := &OAuthSession{
+				ID:             "host-session",
+				DID:            "did:plc:test123",
+				AccessToken:    "expired-token",
+				RefreshToken:   "refresh-token",
+				DPoPKey:        dpopKey,
+				PDSUrl:         "https://pds.example.com",
+				TokenExpiresAt: &expiresAt,
+				Issuer:         authServer.URL,
+				Host:           tt.host,
+			}
+			if err := EnsureValidToken(context.Background(), session, NewStorage(fake.DB), config); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if clientID != tt.clientID {
+				t.Errorf("Expected client_id %q, got %q", tt.clientID, clientID)
+			}
+		})
+	}
+}
+
+// TestEnsureValidToken_AlreadyRefreshed tests that a request holding a
+// session loaded before another request refreshed it uses the stored tokens
+// rather than spending the old refresh token again
+func TestEnsureValidToken_AlreadyRefreshed(t *testing.T) {
+	expiredAt := time.Now().Add(-1 * time.Minute)
+	refreshedAt := time.Now().Add(time.Hour)
+	fake := queriestest.New(t)
+	fake.Expect("FROM oauth_sessions").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		[]interface{}{"stale-session", "did:plc:test123", "new-access-token", "new-refresh-token", "dpop-key", "https://pds.example.com", refreshedAt, "https://auth.example.com", "", time.Now(), time.Now().Add(time.Hour)},
+	)
+
+	session := &OAuthSession{
+		ID:             "stale-session",
+		DID:            "did:plc:test123",
+		AccessToken:    "expired-token",
+		RefreshToken:   "spent-refresh-token",
+		DPoPKey:        "dpop-key",
+		PDSUrl:         "https://pds.example.com",
+		TokenExpiresAt: &expiredAt,
+		Issuer:         "https://auth.example.com", // unreachable: no refresh may be attempted
+	}
+
+	err := EnsureValidToken(context.Background(), session, NewStorage(fake.DB), Config{Host: "survey.openmeet.net"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if session.AccessToken != "new-access-token" || session.RefreshToken != "new-refresh-token" {
+		t.Errorf("Expected the stored tokens, got %q, %q", session.AccessToken, session.RefreshToken)
+	}
+	if len(fake.CallsMatching("UPDATE oauth_sessions")) != 0 {
+		t.Error("Expected no token update")
+	}
+}
+
+// TestRefreshAccessToken_ErrorKinds tests that failed refreshes are classified
+// as needing a new login or as worth retrying
+func TestRefreshAccessToken_ErrorKinds(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"invalid_grant", http.StatusBadRequest, `{"error":"invalid_grant"}`, ErrSessionInvalid},
+		{"unauthorized client", http.StatusUnauthorized, `{"error":"invalid_client"}`, ErrRefreshTransient},
+		{"invalid request", http.StatusBadRequest, `{"error":"invalid_request"}`, ErrRefreshTransient},
+		{"unparseable rejection", http.StatusBadRequest, `bad request`, ErrRefreshTransient},
+		{"invalid_grant with another status", http.StatusUnauthorized, `{"error":"invalid_grant","error_description":"refresh token revoked"}`, ErrSessionInvalid},
+		{"rate limited", http.StatusTooManyRequests, `{"error":"rate_limited"}`, ErrRefreshTransient},
+		{"server error", http.StatusInternalServerError, `internal error`, ErrRefreshTransient},
+		{"unavailable", http.StatusServiceUnavailable, `down for maintenance`, ErrRefreshTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var serverURL string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/.well-known/oauth-authorization-server" {
+					w.Write([]byte(`{"token_endpoint":"` + serverURL + `/token"}`))
+					return
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			serverURL = server.URL
+
+			_, _, _, err := RefreshAccessToken(context.Background(), refreshTestSession(), server.URL, "client-id", GenerateSecretJWK())
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Expected %v, got: %v", tt.want, err)
+			}
+			if !strings.Contains(err.Error(), tt.body) {
+				t.Errorf("Expected the error to include the response body, got: %v", err)
+			}
+		})
+	}
+
+	t.Run("network error", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, _, _, err := RefreshAccessToken(context.Background(), refreshTestSession(), server.URL, "client-id", GenerateSecretJWK())
+		if !errors.Is(err, ErrRefreshTransient) {
+			t.Errorf("Expected ErrRefreshTransient, got: %v", err)
+		}
+	})
+
+	t.Run("storage failure", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("FROM oauth_sessions").Err(errors.New("connection refused"))
+
+		session := refreshTestSession()
+		session.Issuer = "https://auth.example.com"
+		err := EnsureValidToken(context.Background(), session, NewStorage(fake.DB), Config{Host: "survey.openmeet.net"})
+		if !errors.Is(err, ErrRefreshTransient) {
+			t.Errorf("Expected ErrRefreshTransient, got: %v", err)
+		}
+	})
+}
+
+// TestNeedsRefresh_Boundary documents that a token expiring exactly the
+// threshold from now is refreshed, and one expiring any later is not
+func TestNeedsRefresh_Boundary(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		expiresAt := now.Add(d)
+		return &expiresAt
+	}
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		threshold time.Duration
+		want      bool
+	}{
+		{"exactly at the threshold", at(5 * time.Minute), 5 * time.Minute, true},
+		{"just past the threshold", at(5*time.Minute + time.Nanosecond), 5 * time.Minute, false},
+		{"just inside the threshold", at(5*time.Minute - time.Nanosecond), 5 * time.Minute, true},
+		{"exactly at a short threshold", at(30 * time.Second), 30 * time.Second, true},
+		{"past a short threshold", at(31 * time.Second), 30 * time.Second, false},
+		{"exactly at a long threshold", at(time.Hour), time.Hour, true},
+		{"already expired", at(-time.Minute), 5 * time.Minute, true},
+		{"expiring now", at(0), 5 * time.Minute, true},
+		{"no expiry", nil, 5 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsRefresh(tt.expiresAt, tt.threshold, now); got != tt.want {
+				t.Errorf("needsRefresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEnsureValidToken_RefreshThreshold tests that EnsureValidToken refreshes
+// according to Config.RefreshThreshold. The sessions lack an issuer, so an
+// attempted refresh shows up as an error.
+func TestEnsureValidToken_RefreshThreshold(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresIn   time.Duration
+		threshold   time.Duration
+		wantRefresh bool
+	}{
+		{"short threshold leaves a 3m token alone", 3 * time.Minute, 2 * time.Minute, false},
+		{"short threshold refreshes a 1m token", time.Minute, 2 * time.Minute, true},
+		{"long threshold refreshes a 20m token", 20 * time.Minute, 30 * time.Minute, true},
+		{"default threshold refreshes a 4m token", 4 * time.Minute, 0, true},
+		{"default threshold leaves a 6m token alone", 6 * time.Minute, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresAt := time.Now().Add(tt.expiresIn)
+			session := &OAuthSession{ID: "threshold-session", TokenExpiresAt: &expiresAt}
+			config := Config{Host: "survey.openmeet.net", RefreshThreshold: tt.threshold}
+
+			err := EnsureValidToken(context.Background(), session, nil, config)
+			if refreshed := err != nil; refreshed != tt.wantRefresh {
+				t.Errorf("Expected refresh %v, got error: %v", tt.wantRefresh, err)
+			}
+		})
+	}
+}
+
+// TestRefreshSessionTokens_Instrumentation tests that a refresh is counted by
+// result and traced with the issuer's host but none of the session's tokens
+func TestRefreshSessionTokens_Instrumentation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	var authServerURL string
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token_endpoint":"` + authServerURL + `/token"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	defer authServer.Close()
+	authServerURL = authServer.URL
+
+	expiresAt := time.Now().Add(-1 * time.Minute)
+	session := &OAuthSession{
+		ID:             "traced-session",
+		DID:            "did:plc:test123",
+		AccessToken:    "expired-access-token",
+		RefreshToken:   "secret-refresh-token",
+		DPoPKey:        GenerateSecretJWK(),
+		PDSUrl:         "https://pds.example.com",
+		TokenExpiresAt: &expiresAt,
+		Issuer:         authServer.URL,
+	}
+	config := Config{
+		Host:      "survey.openmeet.net",
+		SecretJWK: GenerateSecretJWK(),
+	}
+
+	invalid := TokenRefreshTotal.WithLabelValues("lazy", RefreshResultInvalidGrant)
+	before := testutil.ToFloat64(invalid)
+
+	_, err := refreshSessionTokens(context.Background(), session, nil, config, lazyRefresh(config))
+	if !errors.Is(err, ErrSessionInvalid) {
+		t.Fatalf("Expected ErrSessionInvalid, got: %v", err)
+	}
+
+	if got := testutil.ToFloat64(invalid) - before; got != 1 {
+		t.Errorf("Expected the invalid_grant counter to increase by 1, got %v", got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "oauth.refresh_token" {
+		t.Errorf("Expected span name 'oauth.refresh_token', got %q", span.Name())
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("Expected error status, got %v", span.Status().Code)
+	}
+
+	attrs := make(map[string]string)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+		for _, token := range []string{session.AccessToken, session.RefreshToken} {
+			if strings.Contains(kv.Value.Emit(), token) {
+				t.Errorf("Span attribute %s contains a token", kv.Key)
+			}
+		}
+	}
+	if want := strings.TrimPrefix(authServer.URL, "http://"); attrs["oauth.issuer_host"] != want {
+		t.Errorf("Expected oauth.issuer_host %q, got %q", want, attrs["oauth.issuer_host"])
+	}
+	if attrs["oauth.refresh_trigger"] != "lazy" {
+		t.Errorf("Expected oauth.refresh_trigger 'lazy', got %q", attrs["oauth.refresh_trigger"])
+	}
+}
@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -14,22 +15,28 @@ import (
 // 2. HTTP well-known at https://<handle>/.well-known/atproto-did
 // 3. Bluesky API (for bsky.social handles)
 func HandleToDID(handle string) (string, error) {
+	return resolveHandleToDID(context.Background(), http.DefaultClient, handle)
+}
+
+// resolveHandleToDID resolves a handle as HandleToDID does, making its
+// requests with client and stopping when ctx is done
+func resolveHandleToDID(ctx context.Context, client *http.Client, handle string) (string, error) {
 	if handle == "" {
 		return "", fmt.Errorf("handle cannot be empty")
 	}
 
 	// Try DNS TXT record first
-	if did, err := resolveHandleViaDNS(handle); err == nil {
+	if did, err := resolveHandleViaDNS(ctx, handle); err == nil {
 		return did, nil
 	}
 
 	// Try HTTP well-known
-	if did, err := resolveHandleViaHTTP(handle); err == nil {
+	if did, err := resolveHandleViaHTTP(ctx, client, handle); err == nil {
 		return did, nil
 	}
 
 	// Try Bluesky API as fallback (works for bsky.social handles)
-	if did, err := resolveHandleViaAPI(handle); err == nil {
+	if did, err := resolveHandleViaAPI(ctx, client, handle); err == nil {
 		return did, nil
 	}
 
@@ -37,8 +44,8 @@ func HandleToDID(handle string) (string, error) {
 }
 
 // resolveHandleViaDNS tries DNS TXT record resolution
-func resolveHandleViaDNS(handle string) (string, error) {
-	txtRecords, err := net.LookupTXT(fmt.Sprintf("_atproto.%s", handle))
+func resolveHandleViaDNS(ctx context.Context, handle string) (string, error) {
+	txtRecords, err := net.DefaultResolver.LookupTXT(ctx, fmt.Sprintf("_atproto.%s", handle))
 	if err != nil {
 		return "", err
 	}
@@ -56,9 +63,13 @@ func resolveHandleViaDNS(handle string) (string, error) {
 }
 
 // resolveHandleViaHTTP tries HTTP well-known resolution
-func resolveHandleViaHTTP(handle string) (string, error) {
+func resolveHandleViaHTTP(ctx context.Context, client *http.Client, handle string) (string, error) {
 	url := fmt.Sprintf("https://%s/.well-known/atproto-did", handle)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -81,9 +92,13 @@ func resolveHandleViaHTTP(handle string) (string, error) {
 }
 
 // resolveHandleViaAPI tries the Bluesky API for handle resolution
-func resolveHandleViaAPI(handle string) (string, error) {
+func resolveHandleViaAPI(ctx context.Context, client *http.Client, handle string) (string, error) {
 	url := fmt.Sprintf("https://bsky.social/xrpc/com.atproto.identity.resolveHandle?handle=%s", handle)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,253 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// resolverPositiveTTL is how long a successful resolution is cached
+	resolverPositiveTTL = 10 * time.Minute
+
+	// resolverNegativeTTL is how long a failed resolution is cached, so a
+	// burst of lookups for a bad handle doesn't reach the identity API
+	resolverNegativeTTL = time.Minute
+
+	// resolverMaxEntries caps each direction of the in-memory cache
+	resolverMaxEntries = 10000
+
+	// resolverRequestTimeout bounds each request NetworkResolverBackend
+	// makes, so a slow identity service can't hold up the pages waiting on it
+	resolverRequestTimeout = 10 * time.Second
+)
+
+// ResolverBackend resolves identities without caching
+type ResolverBackend interface {
+	ResolveHandle(ctx context.Context, handle string) (did string, err error)
+	ResolveDID(ctx context.Context, did string) (handle string, err error)
+}
+
+// NetworkResolverBackend resolves identities over the network: handles as
+// HandleToDID does and DIDs via the public Bluesky profile API. Lookups stop
+// when their context is done.
+type NetworkResolverBackend struct{}
+
+// resolverClient makes NetworkResolverBackend's requests
+var resolverClient = &http.Client{Timeout: resolverRequestTimeout}
+
+// ResolveHandle resolves a handle to a DID
+func (NetworkResolverBackend) ResolveHandle(ctx context.Context, handle string) (string, error) {
+	return resolveHandleToDID(ctx, resolverClient, handle)
+}
+
+// ResolveDID resolves a DID to its current handle
+func (NetworkResolverBackend) ResolveDID(ctx context.Context, did string) (string, error) {
+	profile, err := fetchProfileFromAPI(ctx, resolverClient, did, defaultBlueskyAPIURL)
+	if err != nil {
+		return "", err
+	}
+	if profile.Handle == "" {
+		return "", fmt.Errorf("no handle found for %s", did)
+	}
+	return profile.Handle, nil
+}
+
+// ResolverStore persists resolved identities in the did -> handle mapping the
+// consumer keeps current from identity events. *db.Queries implements it.
+type ResolverStore interface {
+	GetDIDForHandle(ctx context.Context, handle string) (string, error)
+	GetHandle(ctx context.Context, did string) (string, error)
+	UpsertHandle(ctx context.Context, did, handle string) error
+}
+
+// resolverEntry is a cached resolution, successful or not
+type resolverEntry struct {
+	value     string
+	err       error
+	expiresAt time.Time
+}
+
+// Resolver resolves handles to DIDs and DIDs to handles, caching results in
+// memory and, when it has a store, persisting them. It is safe for
+// concurrent use; concurrent lookups of the same identity share one backend
+// call.
+type Resolver struct {
+	backend ResolverBackend
+	store   ResolverStore // nil keeps resolutions in memory only
+	now     func() time.Time
+
+	mu      sync.RWMutex
+	handles map[string]resolverEntry // handle -> DID
+	dids    map[string]resolverEntry // DID -> handle
+	flights singleflight.Group
+}
+
+// NewResolver creates a Resolver backed by backend. store may be nil.
+func NewResolver(backend ResolverBackend, store ResolverStore) *Resolver {
+	return &Resolver{
+		backend: backend,
+		store:   store,
+		now:     time.Now,
+		handles: make(map[string]resolverEntry),
+		dids:    make(map[string]resolverEntry),
+	}
+}
+
+// normalizeHandle lowercases a handle and strips a leading "@"; handles are
+// case-insensitive
+func normalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// ResolveHandle resolves a handle to a DID
+func (r *Resolver) ResolveHandle(ctx context.Context, handle string) (string, error) {
+	handle = normalizeHandle(handle)
+	if handle == "" {
+		return "", fmt.Errorf("handle cannot be empty")
+	}
+
+	return r.lookup(ctx, r.handles, "handle:", handle, func() (string, error) {
+		if r.store != nil {
+			did, err := r.store.GetDIDForHandle(ctx, handle)
+			if err != nil {
+				log.Printf("Error reading stored DID for handle %s: %v", handle, err)
+			} else if did != "" {
+				return did, nil
+			}
+		}
+
+		did, err := r.backend.ResolveHandle(ctx, handle)
+		if err != nil {
+			return "", err
+		}
+		r.persist(ctx, did, handle)
+		return did, nil
+	})
+}
+
+// ResolveDID resolves a DID to its current handle
+func (r *Resolver) ResolveDID(ctx context.Context, did string) (string, error) {
+	if did == "" {
+		return "", fmt.Errorf("DID cannot be empty")
+	}
+
+	return r.lookup(ctx, r.dids, "did:", did, func() (string, error) {
+		if r.store != nil {
+			handle, err := r.store.GetHandle(ctx, did)
+			if err != nil {
+				log.Printf("Error reading stored handle for %s: %v", did, err)
+			} else if handle != "" {
+				return handle, nil
+			}
+		}
+
+		handle, err := r.backend.ResolveDID(ctx, did)
+		if err != nil {
+			return "", err
+		}
+		r.persist(ctx, did, normalizeHandle(handle))
+		return handle, nil
+	})
+}
+
+// Invalidate drops cached resolutions of did and handle, e.g. when an
+// identity event reports a handle change. Either may be empty.
+func (r *Resolver) Invalidate(did, handle string) {
+	handle = normalizeHandle(handle)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if handle != "" {
+		delete(r.handles, handle)
+	}
+	if did == "" {
+		return
+	}
+	delete(r.dids, did)
+	// The DID's previous handle may now belong to someone else
+	for cached, entry := range r.handles {
+		if entry.value == did {
+			delete(r.handles, cached)
+		}
+	}
+}
+
+// Purge drops every cached resolution, e.g. after missing identity events
+func (r *Resolver) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handles = make(map[string]resolverEntry)
+	r.dids = make(map[string]resolverEntry)
+}
+
+// lookup returns the cached resolution of key, or resolves it with fetch
+// and caches the result
+func (r *Resolver) lookup(ctx context.Context, cache map[string]resolverEntry, flightPrefix, key string, fetch func() (string, error)) (string, error) {
+	r.mu.RLock()
+	entry, ok := cache[key]
+	r.mu.RUnlock()
+	if ok && r.now().Before(entry.expiresAt) {
+		return entry.value, entry.err
+	}
+
+	value, err, _ := r.flights.Do(flightPrefix+key, func() (interface{}, error) {
+		value, err := fetch()
+		// A cancelled request says nothing about the identity
+		if ctx.Err() == nil {
+			r.cache(cache, key, value, err)
+		}
+		return value, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// cache stores a resolution of key, evicting expired entries (and, if that
+// isn't enough, an arbitrary one) when the cache is full
+func (r *Resolver) cache(cache map[string]resolverEntry, key, value string, err error) {
+	ttl := resolverPositiveTTL
+	if err != nil {
+		ttl = resolverNegativeTTL
+	}
+	now := r.now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(cache) >= resolverMaxEntries {
+		for cached, entry := range cache {
+			if !now.Before(entry.expiresAt) {
+				delete(cache, cached)
+			}
+		}
+	}
+	if len(cache) >= resolverMaxEntries {
+		for cached := range cache {
+			delete(cache, cached)
+			break
+		}
+	}
+
+	cache[key] = resolverEntry{value: value, err: err, expiresAt: now.Add(ttl)}
+}
+
+// persist stores a resolved did -> handle mapping, if the resolver has a store
+func (r *Resolver) persist(ctx context.Context, did, handle string) {
+	if r.store == nil || did == "" || handle == "" {
+		return
+	}
+	if err := r.store.UpsertHandle(ctx, did, handle); err != nil {
+		log.Printf("Error storing handle for %s: %v", did, err)
+	}
+}
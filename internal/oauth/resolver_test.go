@@ -0,0 +1,280 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolverBackend resolves from fixed maps and counts its calls
+type fakeResolverBackend struct {
+	mu      sync.Mutex
+	handles map[string]string // handle -> DID
+	dids    map[string]string // DID -> handle
+	delay   time.Duration
+
+	handleCalls atomic.Int32
+	didCalls    atomic.Int32
+}
+
+func newFakeResolverBackend() *fakeResolverBackend {
+	return &fakeResolverBackend{
+		handles: map[string]string{"alice.test": "did:plc:alice"},
+		dids:    map[string]string{"did:plc:alice": "alice.test"},
+	}
+}
+
+func (b *fakeResolverBackend) ResolveHandle(_ context.Context, handle string) (string, error) {
+	b.handleCalls.Add(1)
+	time.Sleep(b.delay)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if did, ok := b.handles[handle]; ok {
+		return did, nil
+	}
+	return "", errors.New("handle not found")
+}
+
+func (b *fakeResolverBackend) ResolveDID(_ context.Context, did string) (string, error) {
+	b.didCalls.Add(1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if handle, ok := b.dids[did]; ok {
+		return handle, nil
+	}
+	return "", errors.New("DID not found")
+}
+
+// set points handle at did in both directions
+func (b *fakeResolverBackend) set(did, handle string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handles[handle] = did
+	b.dids[did] = handle
+}
+
+// fakeResolverStore is an in-memory ResolverStore
+type fakeResolverStore struct {
+	mu      sync.Mutex
+	handles map[string]string // DID -> handle
+}
+
+func (s *fakeResolverStore) GetDIDForHandle(_ context.Context, handle string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for did, stored := range s.handles {
+		if stored == handle {
+			return did, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *fakeResolverStore) GetHandle(_ context.Context, did string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handles[did], nil
+}
+
+func (s *fakeResolverStore) UpsertHandle(_ context.Context, did, handle string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles[did] = handle
+	return nil
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("caches successful resolutions", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		resolver := NewResolver(backend, nil)
+
+		for i := 0; i < 3; i++ {
+			did, err := resolver.ResolveHandle(ctx, "@Alice.test")
+			if err != nil {
+				t.Fatalf("ResolveHandle failed: %v", err)
+			}
+			if did != "did:plc:alice" {
+				t.Errorf("Expected did:plc:alice, got %q", did)
+			}
+			handle, err := resolver.ResolveDID(ctx, "did:plc:alice")
+			if err != nil {
+				t.Fatalf("ResolveDID failed: %v", err)
+			}
+			if handle != "alice.test" {
+				t.Errorf("Expected alice.test, got %q", handle)
+			}
+		}
+
+		if n := backend.handleCalls.Load(); n != 1 {
+			t.Errorf("Expected 1 handle lookup, got %d", n)
+		}
+		if n := backend.didCalls.Load(); n != 1 {
+			t.Errorf("Expected 1 DID lookup, got %d", n)
+		}
+	})
+
+	t.Run("expires entries after their TTL", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		resolver := NewResolver(backend, nil)
+		now := time.Now()
+		resolver.now = func() time.Time { return now }
+
+		if _, err := resolver.ResolveHandle(ctx, "alice.test"); err != nil {
+			t.Fatalf("ResolveHandle failed: %v", err)
+		}
+		if _, err := resolver.ResolveHandle(ctx, "nobody.test"); err == nil {
+			t.Fatal("Expected an error for an unknown handle")
+		}
+
+		// Failures expire first
+		now = now.Add(resolverNegativeTTL)
+		resolver.ResolveHandle(ctx, "alice.test")
+		resolver.ResolveHandle(ctx, "nobody.test")
+		if n := backend.handleCalls.Load(); n != 3 {
+			t.Errorf("Expected only the failure to be looked up again, got %d lookups", n)
+		}
+
+		now = now.Add(resolverPositiveTTL)
+		resolver.ResolveHandle(ctx, "alice.test")
+		if n := backend.handleCalls.Load(); n != 4 {
+			t.Errorf("Expected the success to be looked up again, got %d lookups", n)
+		}
+	})
+
+	t.Run("caches failures", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		resolver := NewResolver(backend, nil)
+
+		for i := 0; i < 3; i++ {
+			if _, err := resolver.ResolveHandle(ctx, "nobody.test"); err == nil {
+				t.Fatal("Expected an error for an unknown handle")
+			}
+		}
+
+		if n := backend.handleCalls.Load(); n != 1 {
+			t.Errorf("Expected 1 handle lookup, got %d", n)
+		}
+	})
+
+	t.Run("does not cache cancelled lookups", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		resolver := NewResolver(backend, nil)
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		resolver.ResolveHandle(cancelled, "alice.test")
+		resolver.ResolveHandle(ctx, "alice.test")
+
+		if n := backend.handleCalls.Load(); n != 2 {
+			t.Errorf("Expected 2 handle lookups, got %d", n)
+		}
+	})
+
+	t.Run("shares concurrent lookups", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		backend.delay = 50 * time.Millisecond
+		resolver := NewResolver(backend, nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				did, err := resolver.ResolveHandle(ctx, "alice.test")
+				if err != nil || did != "did:plc:alice" {
+					t.Errorf("Expected did:plc:alice, got %q, %v", did, err)
+				}
+				resolver.Invalidate("did:plc:bob", "bob.test") // concurrent busts must be safe
+			}()
+		}
+		wg.Wait()
+
+		if n := backend.handleCalls.Load(); n != 1 {
+			t.Errorf("Expected 1 handle lookup, got %d", n)
+		}
+	})
+
+	t.Run("invalidate drops a changed identity", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		resolver := NewResolver(backend, nil)
+
+		resolver.ResolveHandle(ctx, "alice.test")
+		resolver.ResolveDID(ctx, "did:plc:alice")
+
+		backend.set("did:plc:alice", "alice.example")
+		resolver.Invalidate("did:plc:alice", "alice.example")
+
+		handle, err := resolver.ResolveDID(ctx, "did:plc:alice")
+		if err != nil {
+			t.Fatalf("ResolveDID failed: %v", err)
+		}
+		if handle != "alice.example" {
+			t.Errorf("Expected the new handle, got %q", handle)
+		}
+
+		// The old handle is looked up again rather than served from cache
+		resolver.ResolveHandle(ctx, "alice.test")
+		if n := backend.handleCalls.Load(); n != 2 {
+			t.Errorf("Expected the old handle to be looked up again, got %d lookups", n)
+		}
+	})
+
+	t.Run("purge drops every resolution", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		resolver := NewResolver(backend, nil)
+
+		resolver.ResolveHandle(ctx, "alice.test")
+		resolver.ResolveDID(ctx, "did:plc:alice")
+		resolver.Purge()
+		resolver.ResolveHandle(ctx, "alice.test")
+		resolver.ResolveDID(ctx, "did:plc:alice")
+
+		if n := backend.handleCalls.Load(); n != 2 {
+			t.Errorf("Expected the handle to be looked up again, got %d lookups", n)
+		}
+	})
+
+	t.Run("persists resolutions in the store", func(t *testing.T) {
+		backend := newFakeResolverBackend()
+		store := &fakeResolverStore{handles: map[string]string{}}
+
+		if _, err := NewResolver(backend, store).ResolveHandle(ctx, "alice.test"); err != nil {
+			t.Fatalf("ResolveHandle failed: %v", err)
+		}
+		if store.handles["did:plc:alice"] != "alice.test" {
+			t.Errorf("Expected the resolution to be stored, got %v", store.handles)
+		}
+
+		// A fresh resolver, e.g. after a restart, is served from the store
+		fresh := NewResolver(backend, store)
+		did, err := fresh.ResolveHandle(ctx, "alice.test")
+		if err != nil || did != "did:plc:alice" {
+			t.Errorf("Expected did:plc:alice, got %q, %v", did, err)
+		}
+		handle, err := fresh.ResolveDID(ctx, "did:plc:alice")
+		if err != nil || handle != "alice.test" {
+			t.Errorf("Expected alice.test, got %q, %v", handle, err)
+		}
+		if n := backend.handleCalls.Load(); n != 1 {
+			t.Errorf("Expected 1 handle lookup, got %d", n)
+		}
+		if n := backend.didCalls.Load(); n != 0 {
+			t.Errorf("Expected no DID lookups, got %d", n)
+		}
+	})
+
+	t.Run("rejects empty input", func(t *testing.T) {
+		resolver := NewResolver(newFakeResolverBackend(), nil)
+		if _, err := resolver.ResolveHandle(ctx, " "); err == nil {
+			t.Error("Expected an error for an empty handle")
+		}
+		if _, err := resolver.ResolveDID(ctx, ""); err == nil {
+			t.Error("Expected an error for an empty DID")
+		}
+	})
+}
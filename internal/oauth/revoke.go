@@ -0,0 +1,148 @@
+package oauth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/telemetry"
+)
+
+// revokeTimeout bounds the upstream revocation so an unresponsive
+// authorization server can't hold up logout
+const revokeTimeout = 5 * time.Second
+
+// SessionDeleter deletes a stored session. *Storage implements it.
+type SessionDeleter interface {
+	DeleteSession(ctx context.Context, id string) error
+}
+
+// RevokeSession revokes session's refresh token at its issuer and deletes the
+// session from storage. Revocation is best-effort: the session is deleted
+// even if the issuer can't be reached or refuses, in which case the failure
+// is counted and returned alongside any error from deleting the session.
+func RevokeSession(ctx context.Context, session *OAuthSession, storage SessionDeleter, config Config) error {
+	if session == nil {
+		return fmt.Errorf("session cannot be nil")
+	}
+
+	if storage == nil {
+		return fmt.Errorf("cannot revoke session: storage is nil")
+	}
+
+	return errors.Join(revokeSessionToken(ctx, session, config), storage.DeleteSession(ctx, session.ID))
+}
+
+// RevokeUserSession revokes the refresh token of did's session id at its
+// issuer and deletes the session, e.g. when the user ends it from another
+// device. As with RevokeSession, revocation is best-effort: a failure is
+// counted and logged, and the session is deleted regardless. Returns
+// sql.ErrNoRows if did has no session id.
+func RevokeUserSession(ctx context.Context, did, id string, storage *Storage, config Config) error {
+	session, err := storage.GetSessionByID(ctx, id)
+	switch {
+	case err == nil && session.DID == did:
+		if err := revokeSessionToken(ctx, session, config); err != nil {
+			log.Printf("Error revoking OAuth session for %s: %v", did, err)
+		}
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		log.Printf("Error loading OAuth session to revoke: %v", err)
+	}
+
+	return storage.DeleteUserSession(ctx, did, id)
+}
+
+// RevokeSessionsByDID revokes the refresh tokens of all of did's sessions at
+// their issuers and deletes the sessions, e.g. when the user suspects their
+// account is compromised. As with RevokeSession, revocation is best-effort:
+// failures are counted and logged, and every session is deleted regardless.
+// Returns the number of sessions deleted.
+func RevokeSessionsByDID(ctx context.Context, did string, storage *Storage, config Config) (int64, error) {
+	if did == "" {
+		return 0, fmt.Errorf("DID cannot be empty")
+	}
+
+	ids, err := storage.sessionIDsByDID(ctx, did)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		session, err := storage.GetSessionByID(ctx, id)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error loading OAuth session to revoke: %v", err)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := revokeSessionToken(ctx, session, config); err != nil {
+				log.Printf("Error revoking OAuth session for %s: %v", did, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return storage.DeleteSessionsByDID(ctx, did)
+}
+
+// revokeSessionToken revokes session's refresh token, if it has one, within
+// revokeTimeout. Failures are counted.
+func revokeSessionToken(ctx context.Context, session *OAuthSession, config Config) error {
+	if session.RefreshToken == "" {
+		return nil
+	}
+
+	revokeCtx, cancel := context.WithTimeout(ctx, revokeTimeout)
+	defer cancel()
+	if err := revokeRefreshToken(revokeCtx, session, config); err != nil {
+		telemetry.OAuthRevocationFailures.Inc()
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// revokeRefreshToken asks session's issuer to revoke its refresh token
+// (RFC 7009), with the same client authentication and DPoP binding as a
+// token refresh
+func revokeRefreshToken(ctx context.Context, session *OAuthSession, config Config) error {
+	if session.Issuer == "" {
+		return fmt.Errorf("session missing issuer")
+	}
+
+	if session.DPoPKey == "" {
+		return fmt.Errorf("session missing DPoP key")
+	}
+
+	revocationEndpoint, err := authServerEndpoint(ctx, session.Issuer, "revocation_endpoint")
+	if err != nil {
+		return fmt.Errorf("failed to get revocation endpoint: %w", err)
+	}
+
+	data := url.Values{}
+	data.Set("token", session.RefreshToken)
+	data.Set("token_type_hint", "refresh_token")
+
+	clientID := config.clientID(session.Host)
+	status, body, err := postToAuthServer(ctx, session, session.Issuer, revocationEndpoint, clientID, config.SecretJWK, data)
+	if err != nil {
+		return fmt.Errorf("revocation request failed: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return fmt.Errorf("revocation failed with status %d: %s", status, string(body))
+	}
+
+	return nil
+}
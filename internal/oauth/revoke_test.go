@@ -0,0 +1,269 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/openmeet-team/survey/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// revocationServer is a mock auth server with a revocation endpoint
+type revocationServer struct {
+	*httptest.Server
+	status int
+
+	mu       sync.Mutex
+	requests []*http.Request // parsed revocation requests
+}
+
+func newRevocationServer(t *testing.T, status int) *revocationServer {
+	s := &revocationServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Write([]byte(`{"token_endpoint":"` + s.URL + `/token","revocation_endpoint":"` + s.URL + `/revoke"}`))
+			return
+		}
+
+		r.ParseForm()
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *revocationServer) takeRequests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func revokeTestSession(issuer string) *OAuthSession {
+	expiresAt := time.Now().Add(time.Hour)
+	return &OAuthSession{
+		ID:             "test-session",
+		DID:            "did:plc:test",
+		AccessToken:    "access-token",
+		RefreshToken:   "refresh-token",
+		DPoPKey:        GenerateSecretJWK(),
+		PDSUrl:         "https://pds.example.com",
+		Issuer:         issuer,
+		TokenExpiresAt: &expiresAt,
+	}
+}
+
+// TestRevokeSession tests that logout revokes the refresh token upstream and
+// always deletes the local session
+func TestRevokeSession(t *testing.T) {
+	config := Config{Host: "survey.openmeet.net", SecretJWK: GenerateSecretJWK()}
+
+	setup := func(t *testing.T) (*queriestest.DB, *Storage) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM oauth_sessions").RowsAffected(1)
+		return fake, NewStorage(fake.DB)
+	}
+	deleted := func(t *testing.T, fake *queriestest.DB) {
+		t.Helper()
+		deletes := fake.CallsMatching("DELETE FROM oauth_sessions")
+		if len(deletes) != 1 || deletes[0].Args[0] != "test-session" {
+			t.Errorf("Expected test-session to be deleted, got %v", deletes)
+		}
+	}
+
+	t.Run("revokes the refresh token", func(t *testing.T) {
+		fake, storage := setup(t)
+		server := newRevocationServer(t, http.StatusOK)
+		failures := testutil.ToFloat64(telemetry.OAuthRevocationFailures)
+
+		if err := RevokeSession(context.Background(), revokeTestSession(server.URL), storage, config); err != nil {
+			t.Fatalf("RevokeSession failed: %v", err)
+		}
+
+		requests := server.takeRequests()
+		if len(requests) != 1 {
+			t.Fatalf("Expected 1 revocation request, got %d", len(requests))
+		}
+		req := requests[0]
+		if req.Form.Get("token") != "refresh-token" || req.Form.Get("token_type_hint") != "refresh_token" {
+			t.Errorf("Expected the refresh token to be revoked, got %v", req.Form)
+		}
+		if req.Form.Get("client_assertion") == "" {
+			t.Error("Expected a client assertion")
+		}
+		if req.Header.Get("DPoP") == "" {
+			t.Error("Expected a DPoP proof")
+		}
+		if got := testutil.ToFloat64(telemetry.OAuthRevocationFailures) - failures; got != 0 {
+			t.Errorf("Expected no revocation failures to be counted, got %v", got)
+		}
+		deleted(t, fake)
+	})
+
+	t.Run("retries with the nonce the issuer demands", func(t *testing.T) {
+		fake, storage := setup(t)
+		server := newNonceAuthServer(t, http.StatusBadRequest)
+		retries := testutil.ToFloat64(telemetry.OAuthDPoPNonceRetries)
+
+		if err := RevokeSession(context.Background(), revokeTestSession(server.URL), storage, config); err != nil {
+			t.Fatalf("RevokeSession failed: %v", err)
+		}
+		if attempts := server.takeAttempts(); len(attempts) != 2 || attempts[0] != "" || attempts[1] != "nonce-1" {
+			t.Errorf("Expected a first attempt without nonce and a retry with nonce-1, got %q", attempts)
+		}
+		if got := testutil.ToFloat64(telemetry.OAuthDPoPNonceRetries) - retries; got != 1 {
+			t.Errorf("Expected 1 nonce retry to be counted, got %v", got)
+		}
+		deleted(t, fake)
+	})
+
+	t.Run("deletes the session when revocation is refused", func(t *testing.T) {
+		fake, storage := setup(t)
+		server := newRevocationServer(t, http.StatusServiceUnavailable)
+		failures := testutil.ToFloat64(telemetry.OAuthRevocationFailures)
+
+		err := RevokeSession(context.Background(), revokeTestSession(server.URL), storage, config)
+		if err == nil {
+			t.Fatal("Expected an error for a refused revocation")
+		}
+		if got := testutil.ToFloat64(telemetry.OAuthRevocationFailures) - failures; got != 1 {
+			t.Errorf("Expected 1 revocation failure to be counted, got %v", got)
+		}
+		deleted(t, fake)
+	})
+
+	t.Run("deletes the session when the issuer is unreachable", func(t *testing.T) {
+		fake, storage := setup(t)
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		if err := RevokeSession(context.Background(), revokeTestSession(server.URL), storage, config); err == nil {
+			t.Error("Expected an error for an unreachable issuer")
+		}
+		deleted(t, fake)
+	})
+
+	t.Run("skips revocation without a refresh token", func(t *testing.T) {
+		fake, storage := setup(t)
+		server := newRevocationServer(t, http.StatusOK)
+		session := revokeTestSession(server.URL)
+		session.RefreshToken = ""
+
+		if err := RevokeSession(context.Background(), session, storage, config); err != nil {
+			t.Fatalf("RevokeSession failed: %v", err)
+		}
+		if requests := server.takeRequests(); len(requests) != 0 {
+			t.Errorf("Expected no revocation request, got %d", len(requests))
+		}
+		deleted(t, fake)
+	})
+
+	t.Run("reports a failed delete", func(t *testing.T) {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM oauth_sessions").Err(errors.New("connection refused"))
+		server := newRevocationServer(t, http.StatusOK)
+
+		if err := RevokeSession(context.Background(), revokeTestSession(server.URL), NewStorage(fake.DB), config); err == nil {
+			t.Error("Expected an error when the session can't be deleted")
+		}
+	})
+}
+
+// TestRevokeSessionsByDID tests that logging out everywhere revokes each
+// session's refresh token and deletes every session even when revocation fails
+func TestRevokeSessionsByDID(t *testing.T) {
+	config := Config{Host: "survey.openmeet.net", SecretJWK: GenerateSecretJWK()}
+
+	for _, status := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := newRevocationServer(t, status)
+			session := revokeTestSession(server.URL)
+
+			fake := queriestest.New(t)
+			fake.Expect("DELETE FROM oauth_sessions WHERE did").RowsAffected(2)
+			fake.Expect("SELECT id FROM oauth_sessions WHERE did").Rows([]string{"id"},
+				[]interface{}{"laptop-session"},
+				[]interface{}{"phone-session"},
+			)
+			fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+				[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+				[]interface{}{"laptop-session", session.DID, session.AccessToken, session.RefreshToken, session.DPoPKey, session.PDSUrl, *session.TokenExpiresAt, session.Issuer, "", time.Now(), time.Now().Add(time.Hour)},
+			)
+			failures := testutil.ToFloat64(telemetry.OAuthRevocationFailures)
+
+			count, err := RevokeSessionsByDID(context.Background(), session.DID, NewStorage(fake.DB), config)
+			if err != nil {
+				t.Fatalf("RevokeSessionsByDID failed: %v", err)
+			}
+			if count != 2 {
+				t.Errorf("Expected 2 sessions deleted, got %d", count)
+			}
+
+			if n := len(server.takeRequests()); n != 2 {
+				t.Errorf("Expected 2 revocation requests, got %d", n)
+			}
+			deletes := fake.CallsMatching("DELETE FROM oauth_sessions WHERE did")
+			if len(deletes) != 1 || deletes[0].Args[0] != session.DID {
+				t.Errorf("Expected %s's sessions to be deleted, got %v", session.DID, deletes)
+			}
+
+			wantFailures := 0.0
+			if status != http.StatusOK {
+				wantFailures = 2
+			}
+			if got := testutil.ToFloat64(telemetry.OAuthRevocationFailures) - failures; got != wantFailures {
+				t.Errorf("Expected %v revocation failures, got %v", wantFailures, got)
+			}
+		})
+	}
+}
+
+// TestRevokeUserSession tests that ending one session revokes its refresh
+// token before deleting it, but only for the session's own user
+func TestRevokeUserSession(t *testing.T) {
+	config := Config{Host: "survey.openmeet.net", SecretJWK: GenerateSecretJWK()}
+	server := newRevocationServer(t, http.StatusOK)
+	session := revokeTestSession(server.URL)
+
+	setup := func(t *testing.T) *queriestest.DB {
+		fake := queriestest.New(t)
+		fake.Expect("DELETE FROM oauth_sessions WHERE id = $1 AND did = $2").RowsAffected(1)
+		fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+			[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+			[]interface{}{"phone-session", session.DID, session.AccessToken, session.RefreshToken, session.DPoPKey, session.PDSUrl, *session.TokenExpiresAt, session.Issuer, "", time.Now(), time.Now().Add(time.Hour)},
+		)
+		return fake
+	}
+
+	t.Run("revokes and deletes", func(t *testing.T) {
+		fake := setup(t)
+		if err := RevokeUserSession(context.Background(), session.DID, "phone-session", NewStorage(fake.DB), config); err != nil {
+			t.Fatalf("RevokeUserSession failed: %v", err)
+		}
+		if n := len(server.takeRequests()); n != 1 {
+			t.Errorf("Expected 1 revocation request, got %d", n)
+		}
+		if deletes := fake.CallsMatching("DELETE FROM oauth_sessions"); len(deletes) != 1 {
+			t.Errorf("Expected the session to be deleted, got %v", deletes)
+		}
+	})
+
+	t.Run("other users' sessions aren't revoked", func(t *testing.T) {
+		fake := setup(t)
+		RevokeUserSession(context.Background(), "did:plc:mallory", "phone-session", NewStorage(fake.DB), config)
+		if n := len(server.takeRequests()); n != 0 {
+			t.Errorf("Expected no revocation request, got %d", n)
+		}
+	})
+}
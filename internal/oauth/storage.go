@@ -2,10 +2,30 @@ package oauth
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"time"
+
+	"github.com/openmeet-team/survey/internal/telemetry"
+)
+
+const (
+	// DefaultSessionIdleTimeout is how long a session can go unused before the
+	// cleanup worker deletes it
+	DefaultSessionIdleTimeout = 30 * 24 * time.Hour
+
+	// sessionTouchInterval limits how often TouchSession writes, so a burst of
+	// requests costs one update rather than one each
+	sessionTouchInterval = time.Minute
+
+	// AuthRequestTTL is how long a login has to come back through the
+	// callback before its state and PKCE verifier are discarded
+	AuthRequestTTL = 15 * time.Minute
 )
 
 // OAuthRequest represents a pending OAuth request
@@ -15,6 +35,7 @@ type OAuthRequest struct {
 	PKCEVerifier   string
 	DPoPPrivateKey string
 	Destination    string
+	Host           string // Host the login started on; empty means the primary host
 	CreatedAt      time.Time
 	ExpiresAt      time.Time
 }
@@ -29,13 +50,34 @@ type OAuthSession struct {
 	PDSUrl         string     // User's PDS URL for direct writes
 	TokenExpiresAt *time.Time // When the access token expires
 	Issuer         string     // Auth server URL (needed for token refresh)
+	UserAgent      string     // User-Agent of the login request
+	IPAddress      string     // Client IP of the login request
+	Host           string     // Host the session was created on; empty means the primary host
 	CreatedAt      time.Time
 	ExpiresAt      time.Time
 }
 
+// SessionInfo describes one of a user's sessions, without its tokens
+type SessionInfo struct {
+	ID         string
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// SessionHandle returns a stable identifier for the session with the given
+// ID. Session IDs are the session cookie's value, so listings show users the
+// handle instead.
+func SessionHandle(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
 // Storage provides database operations for OAuth
 type Storage struct {
-	db *sql.DB
+	db     *sql.DB
+	tokens *TokenCipher // nil stores tokens unencrypted
 }
 
 // NewStorage creates a new Storage instance
@@ -43,11 +85,37 @@ func NewStorage(db *sql.DB) *Storage {
 	return &Storage{db: db}
 }
 
+// SetTokenCipher encrypts session tokens and DPoP keys at rest. Plaintext
+// rows written before encryption was enabled stay readable and are encrypted
+// the next time their tokens are updated.
+func (s *Storage) SetTokenCipher(tokens *TokenCipher) {
+	s.tokens = tokens
+}
+
+// sealToken encrypts a secret for storage, if encryption is enabled
+func (s *Storage) sealToken(value string) (string, error) {
+	if s.tokens == nil {
+		return value, nil
+	}
+	return s.tokens.Encrypt(value)
+}
+
+// openToken decrypts a stored secret; plaintext values are returned as they are
+func (s *Storage) openToken(stored string) (string, error) {
+	if s.tokens == nil {
+		if isEncryptedToken(stored) {
+			return "", errors.New("session token is encrypted but SESSION_ENCRYPTION_KEY is not set")
+		}
+		return stored, nil
+	}
+	return s.tokens.Decrypt(stored)
+}
+
 // SaveOAuthRequest stores an OAuth request state
 func (s *Storage) SaveOAuthRequest(ctx context.Context, req OAuthRequest) error {
 	query := `
-		INSERT INTO oauth_requests (state, issuer, pkce_verifier, dpop_private_key, destination, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO oauth_requests (state, issuer, pkce_verifier, dpop_private_key, destination, host, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
 	`
 
 	_, err := s.db.ExecContext(
@@ -58,6 +126,7 @@ func (s *Storage) SaveOAuthRequest(ctx context.Context, req OAuthRequest) error
 		req.PKCEVerifier,
 		req.DPoPPrivateKey,
 		req.Destination,
+		req.Host,
 		req.ExpiresAt,
 	)
 
@@ -71,7 +140,7 @@ func (s *Storage) SaveOAuthRequest(ctx context.Context, req OAuthRequest) error
 // GetOAuthRequest retrieves an OAuth request by state
 func (s *Storage) GetOAuthRequest(ctx context.Context, state string) (*OAuthRequest, error) {
 	query := `
-		SELECT state, issuer, pkce_verifier, dpop_private_key, destination, created_at, expires_at
+		SELECT state, issuer, pkce_verifier, dpop_private_key, destination, COALESCE(host, ''), created_at, expires_at
 		FROM oauth_requests
 		WHERE state = $1
 	`
@@ -83,6 +152,7 @@ func (s *Storage) GetOAuthRequest(ctx context.Context, state string) (*OAuthRequ
 		&req.PKCEVerifier,
 		&req.DPoPPrivateKey,
 		&req.Destination,
+		&req.Host,
 		&req.CreatedAt,
 		&req.ExpiresAt,
 	)
@@ -108,22 +178,38 @@ func (s *Storage) DeleteOAuthRequest(ctx context.Context, state string) error {
 
 // CreateSession creates a new OAuth session
 func (s *Storage) CreateSession(ctx context.Context, session OAuthSession) error {
+	accessToken, err := s.sealToken(session.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	refreshToken, err := s.sealToken(session.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+	dpopKey, err := s.sealToken(session.DPoPKey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt DPoP key: %w", err)
+	}
+
 	query := `
-		INSERT INTO oauth_sessions (id, did, access_token, refresh_token, dpop_key, pds_url, token_expires_at, issuer, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO oauth_sessions (id, did, access_token, refresh_token, dpop_key, pds_url, token_expires_at, issuer, user_agent, ip_address, host, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12)
 	`
 
-	_, err := s.db.ExecContext(
+	_, err = s.db.ExecContext(
 		ctx,
 		query,
 		session.ID,
 		session.DID,
-		session.AccessToken,
-		session.RefreshToken,
-		session.DPoPKey,
+		accessToken,
+		refreshToken,
+		dpopKey,
 		session.PDSUrl,
 		session.TokenExpiresAt,
 		session.Issuer,
+		session.UserAgent,
+		session.IPAddress,
+		session.Host,
 		session.ExpiresAt,
 	)
 
@@ -137,7 +223,7 @@ func (s *Storage) CreateSession(ctx context.Context, session OAuthSession) error
 // GetSessionByID retrieves a session by its ID
 func (s *Storage) GetSessionByID(ctx context.Context, id string) (*OAuthSession, error) {
 	query := `
-		SELECT id, did, access_token, refresh_token, dpop_key, pds_url, token_expires_at, issuer, created_at, expires_at
+		SELECT id, did, access_token, refresh_token, dpop_key, pds_url, token_expires_at, issuer, COALESCE(host, ''), created_at, expires_at
 		FROM oauth_sessions
 		WHERE id = $1
 	`
@@ -152,6 +238,7 @@ func (s *Storage) GetSessionByID(ctx context.Context, id string) (*OAuthSession,
 		&session.PDSUrl,
 		&session.TokenExpiresAt,
 		&session.Issuer,
+		&session.Host,
 		&session.CreatedAt,
 		&session.ExpiresAt,
 	)
@@ -160,18 +247,43 @@ func (s *Storage) GetSessionByID(ctx context.Context, id string) (*OAuthSession,
 		return nil, err
 	}
 
+	if session.AccessToken, err = s.openToken(session.AccessToken); err != nil {
+		return nil, fmt.Errorf("failed to decrypt access token: %w", err)
+	}
+	if session.RefreshToken, err = s.openToken(session.RefreshToken); err != nil {
+		return nil, fmt.Errorf("failed to decrypt refresh token: %w", err)
+	}
+	if session.DPoPKey, err = s.openToken(session.DPoPKey); err != nil {
+		return nil, fmt.Errorf("failed to decrypt DPoP key: %w", err)
+	}
+
 	return session, nil
 }
 
-// UpdateSessionTokens updates the access token, refresh token, and expiration for a session
+// UpdateSessionTokens updates the access token, refresh token, and expiration for a session.
+// With encryption enabled, a DPoP key stored in plaintext or under a rotated
+// key is re-encrypted with the current key at the same time.
 func (s *Storage) UpdateSessionTokens(ctx context.Context, id, accessToken, refreshToken string, tokenExpiresAt *time.Time) error {
+	sealedAccess, err := s.sealToken(accessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	sealedRefresh, err := s.sealToken(refreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+	dpopKey, err := s.resealedDPoPKey(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE oauth_sessions
-		SET access_token = $1, refresh_token = $2, token_expires_at = $3
+		SET access_token = $1, refresh_token = $2, token_expires_at = $3, dpop_key = COALESCE($5, dpop_key)
 		WHERE id = $4
 	`
 
-	result, err := s.db.ExecContext(ctx, query, accessToken, refreshToken, tokenExpiresAt, id)
+	result, err := s.db.ExecContext(ctx, query, sealedAccess, sealedRefresh, tokenExpiresAt, id, dpopKey)
 	if err != nil {
 		return fmt.Errorf("failed to update session tokens: %w", err)
 	}
@@ -188,6 +300,36 @@ func (s *Storage) UpdateSessionTokens(ctx context.Context, id, accessToken, refr
 	return nil
 }
 
+// resealedDPoPKey returns the session's DPoP key encrypted with the current
+// key if it isn't already, or nil if it needs no rewrite
+func (s *Storage) resealedDPoPKey(ctx context.Context, id string) (*string, error) {
+	if s.tokens == nil {
+		return nil, nil
+	}
+
+	var stored string
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(dpop_key, '') FROM oauth_sessions WHERE id = $1`, id).Scan(&stored)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("failed to read DPoP key: %w", err)
+	}
+	if !s.tokens.NeedsRewrite(stored) {
+		return nil, nil
+	}
+
+	plaintext, err := s.tokens.Decrypt(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DPoP key: %w", err)
+	}
+	sealed, err := s.tokens.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt DPoP key: %w", err)
+	}
+	return &sealed, nil
+}
+
 // DeleteSession removes a session by ID
 func (s *Storage) DeleteSession(ctx context.Context, id string) error {
 	query := `DELETE FROM oauth_sessions WHERE id = $1`
@@ -200,13 +342,265 @@ func (s *Storage) DeleteSession(ctx context.Context, id string) error {
 	return nil
 }
 
-// CleanupExpiredRequests removes expired OAuth requests
-func (s *Storage) CleanupExpiredRequests(ctx context.Context) (int64, error) {
-	query := `DELETE FROM oauth_requests WHERE expires_at < NOW()`
+// DeleteUserSession removes one of did's sessions. It returns sql.ErrNoRows
+// if no session with that ID belongs to did, so users can't end each other's
+// sessions.
+func (s *Storage) DeleteUserSession(ctx context.Context, did, id string) error {
+	query := `DELETE FROM oauth_sessions WHERE id = $1 AND did = $2`
 
-	result, err := s.db.ExecContext(ctx, query)
+	result, err := s.db.ExecContext(ctx, query, id, did)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteSessionsByDID deletes all of did's sessions, returning how many were
+// deleted
+func (s *Storage) DeleteSessionsByDID(ctx context.Context, did string) (int64, error) {
+	query := `DELETE FROM oauth_sessions WHERE did = $1`
+
+	result, err := s.db.ExecContext(ctx, query, did)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return count, nil
+}
+
+// sessionIDsByDID returns the IDs of all of did's sessions, expired or not
+func (s *Storage) sessionIDsByDID(ctx context.Context, did string) ([]string, error) {
+	query := `SELECT id FROM oauth_sessions WHERE did = $1`
+
+	rows, err := s.db.QueryContext(ctx, query, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetSessionsByDID lists did's unexpired sessions, most recently used first
+func (s *Storage) GetSessionsByDID(ctx context.Context, did string) ([]SessionInfo, error) {
+	query := `
+		SELECT id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_used_at
+		FROM oauth_sessions
+		WHERE did = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY last_used_at DESC, created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, did)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []SessionInfo{}
+	for rows.Next() {
+		var session SessionInfo
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// GetSessionsDueForRefresh returns the IDs of up to limit unexpired,
+// refreshable sessions whose access token expires before expiresBefore and
+// that were used since usedSince, soonest expiry first
+func (s *Storage) GetSessionsDueForRefresh(ctx context.Context, expiresBefore, usedSince time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT id
+		FROM oauth_sessions
+		WHERE token_expires_at < $1
+		  AND last_used_at >= $2
+		  AND COALESCE(refresh_token, '') <> ''
+		  AND COALESCE(issuer, '') <> ''
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY token_expires_at
+		LIMIT $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, expiresBefore, usedSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions due for refresh: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return ids, nil
+}
+
+// StoredProfile is a cached profile and when it was fetched
+type StoredProfile struct {
+	Profile
+	FetchedAt time.Time
+}
+
+// UpsertProfile caches a profile, marking it fetched now
+func (s *Storage) UpsertProfile(ctx context.Context, profile *Profile) error {
+	if profile == nil || profile.DID == "" {
+		return fmt.Errorf("profile must have a DID")
+	}
+
+	query := `
+		INSERT INTO profiles (did, handle, display_name, avatar, fetched_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (did) DO UPDATE SET
+			handle = EXCLUDED.handle,
+			display_name = EXCLUDED.display_name,
+			avatar = EXCLUDED.avatar,
+			fetched_at = EXCLUDED.fetched_at
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, profile.DID, profile.Handle, profile.DisplayName, profile.Avatar); err != nil {
+		return fmt.Errorf("failed to upsert profile: %w", err)
+	}
+
+	return nil
+}
+
+// GetProfile returns did's cached profile, or sql.ErrNoRows if none is cached
+func (s *Storage) GetProfile(ctx context.Context, did string) (*StoredProfile, error) {
+	query := `SELECT did, handle, display_name, avatar, fetched_at FROM profiles WHERE did = $1`
+
+	stored := &StoredProfile{}
+	err := s.db.QueryRowContext(ctx, query, did).Scan(
+		&stored.DID,
+		&stored.Handle,
+		&stored.DisplayName,
+		&stored.Avatar,
+		&stored.FetchedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	return stored, nil
+}
+
+// SessionIdleTimeoutFromEnv reads how long a session may go unused from
+// OAUTH_SESSION_IDLE_TIMEOUT (a Go duration such as "720h"), defaulting to
+// DefaultSessionIdleTimeout
+func SessionIdleTimeoutFromEnv() (time.Duration, error) {
+	value := os.Getenv("OAUTH_SESSION_IDLE_TIMEOUT")
+	if value == "" {
+		return DefaultSessionIdleTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid OAUTH_SESSION_IDLE_TIMEOUT: %q", value)
+	}
+	return timeout, nil
+}
+
+// TouchSession records that a session was just used. Updates within
+// sessionTouchInterval of the last one are skipped.
+func (s *Storage) TouchSession(ctx context.Context, id string) error {
+	query := `
+		UPDATE oauth_sessions
+		SET last_used_at = NOW()
+		WHERE id = $1 AND last_used_at < $2
+	`
+
+	_, err := s.db.ExecContext(ctx, query, id, time.Now().Add(-sessionTouchInterval))
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions that haven't been used for
+// olderThan, and expired sessions whose refresh token is gone, which can
+// never be refreshed. Expired sessions are refused by SessionMiddleware, so a
+// session in active use within olderThan is never deleted. Returns the number
+// of sessions deleted.
+func (s *Storage) DeleteExpiredSessions(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+		DELETE FROM oauth_sessions
+		WHERE last_used_at < $1
+		   OR (COALESCE(refresh_token, '') = '' AND expires_at < NOW())
+	`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return count, nil
+}
+
+// authRequestExpired reports whether an OAuth request created at createdAt
+// is too old to complete at now
+func authRequestExpired(createdAt, now time.Time) bool {
+	return now.Sub(createdAt) > AuthRequestTTL
+}
+
+// DeleteExpiredAuthRequests removes OAuth requests older than AuthRequestTTL
+// or past their expiry, left behind by logins that never reached the
+// callback. Returns the number of requests deleted.
+func (s *Storage) DeleteExpiredAuthRequests(ctx context.Context) (int64, error) {
+	query := `DELETE FROM oauth_requests WHERE created_at < $1 OR expires_at < NOW()`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().Add(-AuthRequestTTL))
 	if err != nil {
-		return 0, fmt.Errorf("failed to cleanup expired requests: %w", err)
+		return 0, fmt.Errorf("failed to delete expired OAuth requests: %w", err)
 	}
 
 	count, err := result.RowsAffected()
@@ -235,15 +629,16 @@ func (s *Storage) CleanupExpiredSessions(ctx context.Context) (int64, error) {
 }
 
 // StartCleanupWorker starts a background goroutine that periodically cleans up
-// expired OAuth requests and sessions. It runs until the context is cancelled.
-func StartCleanupWorker(ctx context.Context, storage *Storage, interval time.Duration) {
+// expired OAuth requests and sessions idle for longer than sessionIdleTimeout
+// (see DeleteExpiredSessions). It runs until the context is cancelled.
+func StartCleanupWorker(ctx context.Context, storage *Storage, interval, sessionIdleTimeout time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	log.Printf("OAuth cleanup worker started (interval: %v)", interval)
+	log.Printf("OAuth cleanup worker started (interval: %v, session idle timeout: %v)", interval, sessionIdleTimeout)
 
 	// Run cleanup immediately on start
-	runCleanup(ctx, storage)
+	runCleanup(ctx, storage, sessionIdleTimeout)
 
 	for {
 		select {
@@ -251,26 +646,27 @@ func StartCleanupWorker(ctx context.Context, storage *Storage, interval time.Dur
 			log.Println("OAuth cleanup worker stopped")
 			return
 		case <-ticker.C:
-			runCleanup(ctx, storage)
+			runCleanup(ctx, storage, sessionIdleTimeout)
 		}
 	}
 }
 
 // runCleanup executes both cleanup operations and logs results
-func runCleanup(ctx context.Context, storage *Storage) {
+func runCleanup(ctx context.Context, storage *Storage, sessionIdleTimeout time.Duration) {
 	// Cleanup expired requests
-	requestCount, err := storage.CleanupExpiredRequests(ctx)
+	requestCount, err := storage.DeleteExpiredAuthRequests(ctx)
 	if err != nil {
 		log.Printf("Error cleaning up expired OAuth requests: %v", err)
 	} else if requestCount > 0 {
 		log.Printf("Cleaned up %d expired OAuth requests", requestCount)
 	}
 
-	// Cleanup expired sessions
-	sessionCount, err := storage.CleanupExpiredSessions(ctx)
+	// Cleanup idle and dead sessions
+	sessionCount, err := storage.DeleteExpiredSessions(ctx, sessionIdleTimeout)
 	if err != nil {
 		log.Printf("Error cleaning up expired OAuth sessions: %v", err)
 	} else if sessionCount > 0 {
+		telemetry.OAuthSessionsDeleted.Add(float64(sessionCount))
 		log.Printf("Cleaned up %d expired OAuth sessions", sessionCount)
 	}
 }
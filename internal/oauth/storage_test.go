@@ -253,6 +253,275 @@ func TestOAuthSessionStorage(t *testing.T) {
 			t.Error("Expected error after deletion, got nil")
 		}
 	})
+
+	t.Run("lists a user's sessions", func(t *testing.T) {
+		for _, session := range []OAuthSession{
+			{ID: "list-laptop", DID: "did:plc:list", UserAgent: "Firefox", IPAddress: "203.0.113.7", ExpiresAt: time.Now().Add(24 * time.Hour)},
+			{ID: "list-phone", DID: "did:plc:list", ExpiresAt: time.Now().Add(24 * time.Hour)},
+			{ID: "list-expired", DID: "did:plc:list", ExpiresAt: time.Now().Add(-time.Hour)},
+			{ID: "list-other", DID: "did:plc:other", ExpiresAt: time.Now().Add(24 * time.Hour)},
+		} {
+			if err := storage.CreateSession(ctx, session); err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+		}
+		if _, err := dbConn.ExecContext(ctx, `UPDATE oauth_sessions SET last_used_at = NOW() - INTERVAL '1 hour' WHERE id = 'list-laptop'`); err != nil {
+			t.Fatalf("Failed to age session: %v", err)
+		}
+
+		sessions, err := storage.GetSessionsByDID(ctx, "did:plc:list")
+		if err != nil {
+			t.Fatalf("GetSessionsByDID failed: %v", err)
+		}
+		if len(sessions) != 2 || sessions[0].ID != "list-phone" || sessions[1].ID != "list-laptop" {
+			t.Fatalf("Expected list-phone then list-laptop, got %+v", sessions)
+		}
+		if sessions[1].UserAgent != "Firefox" || sessions[1].IPAddress != "203.0.113.7" {
+			t.Errorf("Expected the login snapshot, got %+v", sessions[1])
+		}
+		if sessions[0].UserAgent != "" || sessions[0].IPAddress != "" {
+			t.Errorf("Expected no snapshot, got %+v", sessions[0])
+		}
+	})
+
+	t.Run("deletes only the user's own session", func(t *testing.T) {
+		session := OAuthSession{
+			ID:        "owned-session",
+			DID:       "did:plc:owner",
+			ExpiresAt: time.Now().Add(24 * time.Hour),
+		}
+		if err := storage.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+
+		if err := storage.DeleteUserSession(ctx, "did:plc:intruder", "owned-session"); err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows for another user's session, got %v", err)
+		}
+		if _, err := storage.GetSessionByID(ctx, "owned-session"); err != nil {
+			t.Fatalf("Expected the session to remain: %v", err)
+		}
+
+		if err := storage.DeleteUserSession(ctx, "did:plc:owner", "owned-session"); err != nil {
+			t.Fatalf("DeleteUserSession failed: %v", err)
+		}
+		if _, err := storage.GetSessionByID(ctx, "owned-session"); err != sql.ErrNoRows {
+			t.Errorf("Expected the session to be deleted, got %v", err)
+		}
+	})
+}
+
+// TestDeleteSessionsByDID tests ending all of a user's sessions at once
+func TestDeleteSessionsByDID(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	storage := NewStorage(dbConn)
+	ctx := context.Background()
+
+	sessions := []OAuthSession{
+		{ID: "everywhere-session-1", DID: "did:plc:everywhere"},
+		{ID: "everywhere-session-2", DID: "did:plc:everywhere"},
+		{ID: "everywhere-session-3", DID: "did:plc:everywhere", ExpiresAt: time.Now().Add(-time.Hour)},
+		{ID: "everywhere-session-other", DID: "did:plc:bystander"},
+	}
+	for _, session := range sessions {
+		if session.ExpiresAt.IsZero() {
+			session.ExpiresAt = time.Now().Add(time.Hour)
+		}
+		if err := storage.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		defer storage.DeleteSession(ctx, session.ID)
+	}
+
+	count, err := storage.DeleteSessionsByDID(ctx, "did:plc:everywhere")
+	if err != nil {
+		t.Fatalf("DeleteSessionsByDID failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 sessions deleted, got %d", count)
+	}
+
+	for _, id := range []string{"everywhere-session-1", "everywhere-session-2", "everywhere-session-3"} {
+		if _, err := storage.GetSessionByID(ctx, id); err != sql.ErrNoRows {
+			t.Errorf("Expected %s to be deleted, got error: %v", id, err)
+		}
+	}
+	if _, err := storage.GetSessionByID(ctx, "everywhere-session-other"); err != nil {
+		t.Errorf("Expected another user's session to be kept, got error: %v", err)
+	}
+
+	count, err = storage.DeleteSessionsByDID(ctx, "did:plc:everywhere")
+	if err != nil {
+		t.Fatalf("DeleteSessionsByDID failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no sessions left to delete, got %d", count)
+	}
+}
+
+// TestSessionTokenEncryption tests that tokens are encrypted at rest and that
+// plaintext rows are encrypted on their next token update
+func TestSessionTokenEncryption(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	tokens, err := NewTokenCipher([]string{"0123456789abcdef0123456789abcdef-storage-test"})
+	if err != nil {
+		t.Fatalf("NewTokenCipher failed: %v", err)
+	}
+
+	plain := NewStorage(dbConn)
+	encrypted := NewStorage(dbConn)
+	encrypted.SetTokenCipher(tokens)
+
+	stored := func(id string) (access, refresh, dpop string) {
+		t.Helper()
+		err := dbConn.QueryRowContext(ctx,
+			`SELECT access_token, refresh_token, dpop_key FROM oauth_sessions WHERE id = $1`, id,
+		).Scan(&access, &refresh, &dpop)
+		if err != nil {
+			t.Fatalf("Failed to read stored session: %v", err)
+		}
+		return access, refresh, dpop
+	}
+
+	t.Run("encrypts new sessions", func(t *testing.T) {
+		session := OAuthSession{
+			ID:           "encrypted-session-test",
+			DID:          "did:plc:encrypted",
+			AccessToken:  "secret-access",
+			RefreshToken: "secret-refresh",
+			DPoPKey:      `{"kty":"EC","d":"secret"}`,
+			ExpiresAt:    time.Now().Add(24 * time.Hour),
+		}
+		if err := encrypted.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		defer encrypted.DeleteSession(ctx, session.ID)
+
+		access, refresh, dpop := stored(session.ID)
+		if !isEncryptedToken(access) || !isEncryptedToken(refresh) || !isEncryptedToken(dpop) {
+			t.Errorf("Expected every secret encrypted at rest, got %q %q %q", access, refresh, dpop)
+		}
+
+		retrieved, err := encrypted.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID failed: %v", err)
+		}
+		if retrieved.AccessToken != "secret-access" || retrieved.RefreshToken != "secret-refresh" || retrieved.DPoPKey != session.DPoPKey {
+			t.Errorf("Expected decrypted secrets, got %+v", retrieved)
+		}
+
+		// Without the key the row can't be read
+		if _, err := plain.GetSessionByID(ctx, session.ID); err == nil {
+			t.Error("Expected an error reading an encrypted session without a key")
+		}
+	})
+
+	t.Run("re-encrypts plaintext sessions on token update", func(t *testing.T) {
+		session := OAuthSession{
+			ID:           "plaintext-session-test",
+			DID:          "did:plc:plaintext",
+			AccessToken:  "old-access",
+			RefreshToken: "old-refresh",
+			DPoPKey:      `{"kty":"EC","d":"legacy"}`,
+			ExpiresAt:    time.Now().Add(24 * time.Hour),
+		}
+		if err := plain.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		defer plain.DeleteSession(ctx, session.ID)
+
+		retrieved, err := encrypted.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID failed on a plaintext row: %v", err)
+		}
+		if retrieved.AccessToken != "old-access" || retrieved.DPoPKey != session.DPoPKey {
+			t.Errorf("Expected plaintext values, got %+v", retrieved)
+		}
+
+		if err := encrypted.UpdateSessionTokens(ctx, session.ID, "new-access", "new-refresh", nil); err != nil {
+			t.Fatalf("UpdateSessionTokens failed: %v", err)
+		}
+		access, refresh, dpop := stored(session.ID)
+		if !isEncryptedToken(access) || !isEncryptedToken(refresh) || !isEncryptedToken(dpop) {
+			t.Errorf("Expected every secret encrypted after update, got %q %q %q", access, refresh, dpop)
+		}
+
+		retrieved, err = encrypted.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID failed: %v", err)
+		}
+		if retrieved.AccessToken != "new-access" || retrieved.RefreshToken != "new-refresh" || retrieved.DPoPKey != session.DPoPKey {
+			t.Errorf("Expected updated secrets, got %+v", retrieved)
+		}
+	})
+}
+
+// TestProfileStorage tests caching profiles
+func TestProfileStorage(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	storage := NewStorage(dbConn)
+	ctx := context.Background()
+
+	did := "did:plc:profile-test"
+	if _, err := dbConn.Exec("DELETE FROM profiles WHERE did = $1", did); err != nil {
+		t.Fatalf("Failed to clean profiles: %v", err)
+	}
+
+	t.Run("returns ErrNoRows for an uncached profile", func(t *testing.T) {
+		if _, err := storage.GetProfile(ctx, did); err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("inserts and updates a profile", func(t *testing.T) {
+		profile := &Profile{DID: did, Handle: "profile.test", DisplayName: "Profile Test", Avatar: "https://cdn.example.com/a.jpg"}
+		if err := storage.UpsertProfile(ctx, profile); err != nil {
+			t.Fatalf("UpsertProfile failed: %v", err)
+		}
+
+		stored, err := storage.GetProfile(ctx, did)
+		if err != nil {
+			t.Fatalf("GetProfile failed: %v", err)
+		}
+		if stored.Profile != *profile {
+			t.Errorf("Expected %+v, got %+v", *profile, stored.Profile)
+		}
+		if time.Since(stored.FetchedAt) > time.Minute {
+			t.Errorf("Expected fetched_at to be now, got %v", stored.FetchedAt)
+		}
+
+		// Age the row, then check an update marks it fetched again
+		if _, err := dbConn.Exec("UPDATE profiles SET fetched_at = NOW() - INTERVAL '2 days' WHERE did = $1", did); err != nil {
+			t.Fatalf("Failed to age profile: %v", err)
+		}
+		updated := &Profile{DID: did, Handle: "renamed.test"}
+		if err := storage.UpsertProfile(ctx, updated); err != nil {
+			t.Fatalf("UpsertProfile failed: %v", err)
+		}
+
+		stored, err = storage.GetProfile(ctx, did)
+		if err != nil {
+			t.Fatalf("GetProfile failed: %v", err)
+		}
+		if stored.Profile != *updated {
+			t.Errorf("Expected %+v, got %+v", *updated, stored.Profile)
+		}
+		if time.Since(stored.FetchedAt) > time.Minute {
+			t.Errorf("Expected fetched_at to be reset, got %v", stored.FetchedAt)
+		}
+	})
+
+	t.Run("rejects a profile without a DID", func(t *testing.T) {
+		if err := storage.UpsertProfile(ctx, &Profile{Handle: "nodid.test"}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
 }
 
 // setupTestDB creates a test database connection
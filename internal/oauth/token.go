@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -168,22 +169,6 @@ func makeTokenRequest(config TokenConfig, clientAssertion, dpopProof string) (*T
 }
 
 // GetTokenEndpoint fetches the token endpoint from the auth server metadata
-func GetTokenEndpoint(authServer string) (string, error) {
-	resp, err := http.Get(authServer + "/.well-known/oauth-authorization-server")
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	var metadata map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
-		return "", err
-	}
-
-	endpoint, ok := metadata["token_endpoint"].(string)
-	if !ok {
-		return "", fmt.Errorf("missing token_endpoint")
-	}
-
-	return endpoint, nil
+func GetTokenEndpoint(ctx context.Context, authServer string) (string, error) {
+	return authServerEndpoint(ctx, authServer, "token_endpoint")
 }
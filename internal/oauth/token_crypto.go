@@ -0,0 +1,176 @@
+package oauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// encryptedTokenPrefix marks a stored value as encrypted; values without
+	// it are plaintext written before encryption was enabled. The full format
+	// is enc:v1:<key ID>:<base64 nonce and ciphertext>.
+	encryptedTokenPrefix = "enc:v1:"
+
+	// tokenKeyInfo separates keys derived for token encryption from any other
+	// use of the same secret
+	tokenKeyInfo = "openmeet-survey oauth token encryption"
+)
+
+// ErrTokenKeyUnknown is returned when a stored token was encrypted with a key
+// that isn't configured
+var ErrTokenKeyUnknown = errors.New("token encrypted with an unknown key")
+
+// TokenCipher encrypts OAuth tokens at rest with AES-256-GCM. It holds one
+// or more keys: the first encrypts, every key decrypts, so a key can be
+// rotated by putting the new one first and dropping the old one once every
+// row has been rewritten.
+type TokenCipher struct {
+	keys []tokenKey
+}
+
+type tokenKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewTokenCipher derives encryption keys from the given secrets, the first of
+// which is used for writes. Secrets should be long random strings.
+func NewTokenCipher(secrets []string) (*TokenCipher, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("at least one encryption key is required")
+	}
+
+	c := &TokenCipher{}
+	seen := make(map[string]bool, len(secrets))
+	for i, secret := range secrets {
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("encryption key %d is too short: use at least 32 characters", i+1)
+		}
+
+		key, err := hkdf.Key(sha256.New, []byte(secret), nil, tokenKeyInfo, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive encryption key %d: %w", i+1, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key %d: %w", i+1, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCM for key %d: %w", i+1, err)
+		}
+
+		// The ID identifies the key without revealing it
+		sum := sha256.Sum256(key)
+		id := hex.EncodeToString(sum[:4])
+		if seen[id] {
+			return nil, fmt.Errorf("encryption key %d is a duplicate", i+1)
+		}
+		seen[id] = true
+
+		c.keys = append(c.keys, tokenKey{id: id, aead: aead})
+	}
+	return c, nil
+}
+
+// TokenCipherFromEnv builds a TokenCipher from SESSION_ENCRYPTION_KEY, a
+// comma-separated list of secrets with the write key first. Returns nil
+// without an error when the variable is unset, leaving tokens unencrypted.
+func TokenCipherFromEnv() (*TokenCipher, error) {
+	value := os.Getenv("SESSION_ENCRYPTION_KEY")
+	if value == "" {
+		return nil, nil
+	}
+
+	var secrets []string
+	for _, secret := range strings.Split(value, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	c, err := NewTokenCipher(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SESSION_ENCRYPTION_KEY: %w", err)
+	}
+	return c, nil
+}
+
+// Encrypt encrypts plaintext with the write key. The empty string stays
+// empty so "no token" remains recognizable.
+func (c *TokenCipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	key := c.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(key.id))
+	return encryptedTokenPrefix + key.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt with whichever configured key wrote the value.
+// Values without the encrypted prefix are returned as they are.
+func (c *TokenCipher) Decrypt(stored string) (string, error) {
+	if !isEncryptedToken(stored) {
+		return stored, nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(stored, encryptedTokenPrefix), ":")
+	if !ok {
+		return "", errors.New("malformed encrypted token")
+	}
+	key, ok := c.key(id)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTokenKeyUnknown, id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return "", errors.New("malformed encrypted token")
+	}
+	nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+
+	plaintext, err := key.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRewrite reports whether stored isn't encrypted with the write key:
+// plaintext, or written with a key that has since been rotated out of first
+// place
+func (c *TokenCipher) NeedsRewrite(stored string) bool {
+	if stored == "" {
+		return false
+	}
+	return !strings.HasPrefix(stored, encryptedTokenPrefix+c.keys[0].id+":")
+}
+
+func (c *TokenCipher) key(id string) (tokenKey, bool) {
+	for _, key := range c.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return tokenKey{}, false
+}
+
+// isEncryptedToken reports whether a stored value was written by Encrypt
+func isEncryptedToken(stored string) bool {
+	return strings.HasPrefix(stored, encryptedTokenPrefix)
+}
@@ -0,0 +1,220 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTokenSecret    = "0123456789abcdef0123456789abcdef-current"
+	testOldTokenSecret = "0123456789abcdef0123456789abcdef-previous"
+)
+
+func TestTokenCipherRoundTrip(t *testing.T) {
+	c, err := NewTokenCipher([]string{testTokenSecret})
+	require.NoError(t, err)
+
+	sealed, err := c.Encrypt("access-token-value")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, encryptedTokenPrefix))
+	assert.NotContains(t, sealed, "access-token-value")
+
+	opened, err := c.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "access-token-value", opened)
+
+	// A fresh nonce each time
+	again, err := c.Encrypt("access-token-value")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+}
+
+func TestTokenCipherEmptyStaysEmpty(t *testing.T) {
+	c, err := NewTokenCipher([]string{testTokenSecret})
+	require.NoError(t, err)
+
+	sealed, err := c.Encrypt("")
+	require.NoError(t, err)
+	assert.Equal(t, "", sealed)
+	assert.False(t, c.NeedsRewrite(""))
+}
+
+func TestTokenCipherReadsPlaintext(t *testing.T) {
+	c, err := NewTokenCipher([]string{testTokenSecret})
+	require.NoError(t, err)
+
+	opened, err := c.Decrypt(`{"kty":"EC"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"kty":"EC"}`, opened)
+	assert.True(t, c.NeedsRewrite(`{"kty":"EC"}`))
+}
+
+func TestTokenCipherKeyRotation(t *testing.T) {
+	old, err := NewTokenCipher([]string{testOldTokenSecret})
+	require.NoError(t, err)
+	sealedWithOld, err := old.Encrypt("refresh-token")
+	require.NoError(t, err)
+
+	rotated, err := NewTokenCipher([]string{testTokenSecret, testOldTokenSecret})
+	require.NoError(t, err)
+
+	opened, err := rotated.Decrypt(sealedWithOld)
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-token", opened)
+	assert.True(t, rotated.NeedsRewrite(sealedWithOld))
+
+	sealedWithNew, err := rotated.Encrypt("refresh-token")
+	require.NoError(t, err)
+	assert.False(t, rotated.NeedsRewrite(sealedWithNew))
+
+	// Once the old key is dropped its ciphertexts can't be read
+	current, err := NewTokenCipher([]string{testTokenSecret})
+	require.NoError(t, err)
+	_, err = current.Decrypt(sealedWithOld)
+	assert.ErrorIs(t, err, ErrTokenKeyUnknown)
+}
+
+func TestTokenCipherRejectsTampering(t *testing.T) {
+	c, err := NewTokenCipher([]string{testTokenSecret})
+	require.NoError(t, err)
+
+	sealed, err := c.Encrypt("access-token-value")
+	require.NoError(t, err)
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if tampered == sealed {
+		tampered = sealed[:len(sealed)-2] + "BB"
+	}
+	_, err = c.Decrypt(tampered)
+	assert.Error(t, err)
+
+	_, err = c.Decrypt(encryptedTokenPrefix + "no-separator")
+	assert.Error(t, err)
+}
+
+func TestNewTokenCipherValidation(t *testing.T) {
+	_, err := NewTokenCipher(nil)
+	assert.Error(t, err)
+
+	_, err = NewTokenCipher([]string{"too-short"})
+	assert.Error(t, err)
+
+	_, err = NewTokenCipher([]string{testTokenSecret, testTokenSecret})
+	assert.Error(t, err)
+}
+
+func TestTokenCipherFromEnv(t *testing.T) {
+	t.Setenv("SESSION_ENCRYPTION_KEY", "")
+	c, err := TokenCipherFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	t.Setenv("SESSION_ENCRYPTION_KEY", testTokenSecret+", "+testOldTokenSecret)
+	c, err = TokenCipherFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Len(t, c.keys, 2)
+
+	t.Setenv("SESSION_ENCRYPTION_KEY", "short")
+	_, err = TokenCipherFromEnv()
+	assert.Error(t, err)
+}
+
+// TestEncryptedSessionLifecycle tests that with SESSION_ENCRYPTION_KEY set,
+// the session the callback stores is encrypted, and the middleware and logout
+// can read it back from the same rows
+func TestEncryptedSessionLifecycle(t *testing.T) {
+	t.Setenv("SESSION_ENCRYPTION_KEY", testTokenSecret)
+	tokens, err := TokenCipherFromEnv()
+	require.NoError(t, err)
+	require.NotNil(t, tokens)
+
+	var revoked []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/oauth-authorization-server":
+			w.Write([]byte(`{"token_endpoint":"` + server.URL + `/token","revocation_endpoint":"` + server.URL + `/revoke"}`))
+		case "/token":
+			w.Write([]byte(`{"access_token":"access-token","refresh_token":"refresh-token","token_type":"DPoP","expires_in":3600,"sub":"did:example:alice"}`))
+		case "/revoke":
+			r.ParseForm()
+			revoked = append(revoked, r.Form.Get("token"))
+		}
+	}))
+	defer server.Close()
+
+	fake := queriestest.New(t)
+	fake.Expect("FROM oauth_requests WHERE state = $1").Rows(
+		[]string{"state", "issuer", "pkce_verifier", "dpop_private_key", "destination", "host", "created_at", "expires_at"},
+		[]interface{}{"login-state", server.URL, "verifier", GenerateSecretJWK(), "/my-data", "", time.Now(), time.Now().Add(AuthRequestTTL)},
+	)
+	fake.Expect("DELETE FROM oauth_requests").RowsAffected(1)
+	fake.Expect("INSERT INTO oauth_sessions").RowsAffected(1)
+
+	storage := NewStorage(fake.DB)
+	storage.SetTokenCipher(tokens)
+	config := Config{Host: "survey.example.com", SecretJWK: GenerateSecretJWK()}
+	handlers := NewHandlers(storage, config)
+	e := echo.New()
+
+	// The callback stores the session with its tokens encrypted
+	req := httptest.NewRequest(http.MethodGet, "/oauth/callback?iss="+server.URL+"&code=code&state=login-state", nil)
+	req.AddCookie(&http.Cookie{Name: "oauth_state", Value: "login-state"})
+	rec := httptest.NewRecorder()
+	require.NoError(t, handlers.Callback(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	inserts := fake.CallsMatching("INSERT INTO oauth_sessions")
+	require.Len(t, inserts, 1)
+	row := inserts[0].Args
+	assert.NotEqual(t, "access-token", row[2], "access token should be stored encrypted")
+	assert.NotEqual(t, "refresh-token", row[3], "refresh token should be stored encrypted")
+	assert.True(t, isEncryptedToken(row[4].(string)), "DPoP key should be stored encrypted")
+
+	var sessionCookie *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "session" {
+			sessionCookie = cookie
+		}
+	}
+	require.NotNil(t, sessionCookie)
+	assert.Equal(t, row[0], sessionCookie.Value)
+
+	// Later requests read the row the callback wrote
+	fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		[]interface{}{row[0], row[1], row[2], row[3], row[4], row[5], row[6], row[7], row[10], time.Now(), row[11]},
+	)
+	fake.Expect("UPDATE oauth_sessions SET last_used_at").RowsAffected(1)
+	fake.Expect("DELETE FROM oauth_sessions").RowsAffected(1)
+
+	// The middleware decrypts it and signs the user in
+	req = httptest.NewRequest(http.MethodGet, "/my-data", nil)
+	req.AddCookie(sessionCookie)
+	c := e.NewContext(req, httptest.NewRecorder())
+	var user *User
+	err = SessionMiddleware(storage)(func(c echo.Context) error {
+		user = GetUser(c)
+		return nil
+	})(c)
+	require.NoError(t, err)
+	require.NotNil(t, user, "middleware should read the encrypted session")
+	assert.Equal(t, "did:example:alice", user.DID)
+
+	// Logout decrypts it to revoke the refresh token upstream
+	req = httptest.NewRequest(http.MethodGet, "/oauth/logout", nil)
+	req.AddCookie(sessionCookie)
+	require.NoError(t, handlers.Logout(e.NewContext(req, httptest.NewRecorder())))
+	assert.Equal(t, []string{"refresh-token"}, revoked)
+	assert.Len(t, fake.CallsMatching("DELETE FROM oauth_sessions"), 1)
+}
@@ -0,0 +1,172 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// TokenRefreshWorkerInterval is how often the token refresh worker looks
+	// for expiring tokens
+	TokenRefreshWorkerInterval = time.Minute
+
+	// DefaultTokenRefreshAhead is how long before expiry the worker refreshes
+	// a token. It must exceed the refresh threshold, or requests get there
+	// first.
+	DefaultTokenRefreshAhead = 15 * time.Minute
+
+	// DefaultTokenRefreshIdle is how long a session can go unused before the
+	// worker stops keeping its token fresh
+	DefaultTokenRefreshIdle = time.Hour
+
+	// DefaultTokenRefreshConcurrency caps the worker's refreshes in flight
+	DefaultTokenRefreshConcurrency = 4
+
+	// tokenRefreshJitter is the most a refresh is delayed, so sessions that
+	// logged in together don't refresh together
+	tokenRefreshJitter = 30 * time.Second
+
+	// tokenRefreshBatchSize caps the sessions refreshed per run
+	tokenRefreshBatchSize = 200
+)
+
+// TokenRefreshWorkerConfig controls the background token refresh worker
+type TokenRefreshWorkerConfig struct {
+	Enabled     bool
+	Ahead       time.Duration // Refresh tokens expiring within this
+	Idle        time.Duration // Skip sessions unused for longer than this
+	Concurrency int           // Refreshes in flight at once
+}
+
+// TokenRefreshWorkerConfigFromEnv reads the token refresh worker config.
+// refreshThreshold is Config.RefreshThreshold, which Ahead must exceed.
+// Environment variables:
+//   - OAUTH_TOKEN_REFRESH_WORKER: "true" to enable the worker (default: off)
+//   - OAUTH_TOKEN_REFRESH_AHEAD: Go duration before expiry to refresh (default: 15m)
+//   - OAUTH_TOKEN_REFRESH_IDLE: Go duration after which idle sessions are skipped (default: 1h)
+//   - OAUTH_TOKEN_REFRESH_CONCURRENCY: refreshes in flight at once (default: 4)
+func TokenRefreshWorkerConfigFromEnv(refreshThreshold time.Duration) (TokenRefreshWorkerConfig, error) {
+	config := TokenRefreshWorkerConfig{
+		Enabled:     os.Getenv("OAUTH_TOKEN_REFRESH_WORKER") == "true",
+		Ahead:       DefaultTokenRefreshAhead,
+		Idle:        DefaultTokenRefreshIdle,
+		Concurrency: DefaultTokenRefreshConcurrency,
+	}
+
+	if value := os.Getenv("OAUTH_TOKEN_REFRESH_AHEAD"); value != "" {
+		ahead, err := time.ParseDuration(value)
+		if err != nil {
+			return TokenRefreshWorkerConfig{}, fmt.Errorf("invalid OAUTH_TOKEN_REFRESH_AHEAD: %q", value)
+		}
+		config.Ahead = ahead
+	}
+	if config.Enabled && config.Ahead <= refreshThreshold {
+		return TokenRefreshWorkerConfig{}, fmt.Errorf("OAUTH_TOKEN_REFRESH_AHEAD (%v) must be longer than OAUTH_REFRESH_THRESHOLD (%v)", config.Ahead, refreshThreshold)
+	}
+
+	if value := os.Getenv("OAUTH_TOKEN_REFRESH_IDLE"); value != "" {
+		idle, err := time.ParseDuration(value)
+		if err != nil || idle <= 0 {
+			return TokenRefreshWorkerConfig{}, fmt.Errorf("invalid OAUTH_TOKEN_REFRESH_IDLE: %q", value)
+		}
+		config.Idle = idle
+	}
+
+	if value := os.Getenv("OAUTH_TOKEN_REFRESH_CONCURRENCY"); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency <= 0 {
+			return TokenRefreshWorkerConfig{}, fmt.Errorf("invalid OAUTH_TOKEN_REFRESH_CONCURRENCY: %q", value)
+		}
+		config.Concurrency = concurrency
+	}
+
+	return config, nil
+}
+
+// StartTokenRefreshWorker starts a background loop that refreshes access
+// tokens shortly before they expire, so requests rarely wait on a refresh.
+// Only sessions used within config.Idle are kept fresh. Refreshes share
+// EnsureValidToken's per-session locking. It runs until the context is
+// cancelled.
+func StartTokenRefreshWorker(ctx context.Context, storage *Storage, oauthConfig Config, config TokenRefreshWorkerConfig) {
+	ticker := time.NewTicker(TokenRefreshWorkerInterval)
+	defer ticker.Stop()
+
+	log.Printf("OAuth token refresh worker started (ahead: %v, idle: %v, concurrency: %d)", config.Ahead, config.Idle, config.Concurrency)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("OAuth token refresh worker stopped")
+			return
+		case <-ticker.C:
+			runTokenRefresh(ctx, storage, oauthConfig, config, tokenRefreshJitter)
+		}
+	}
+}
+
+// runTokenRefresh refreshes the tokens of the sessions due for refresh, each
+// after a random delay of up to jitter, at most config.Concurrency at a time
+func runTokenRefresh(ctx context.Context, storage *Storage, oauthConfig Config, config TokenRefreshWorkerConfig, jitter time.Duration) {
+	now := time.Now()
+	ids, err := storage.GetSessionsDueForRefresh(ctx, now.Add(config.Ahead), now.Add(-config.Idle), tokenRefreshBatchSize)
+	if err != nil {
+		log.Printf("Error finding OAuth sessions to refresh: %v", err)
+		return
+	}
+
+	trigger := refreshTrigger{name: "proactive", window: config.Ahead}
+	slots := make(chan struct{}, config.Concurrency)
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if jitter > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Duration(rand.Int63n(int64(jitter)))):
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- struct{}{}:
+			}
+			defer func() { <-slots }()
+
+			if err := refreshStoredSession(ctx, storage, id, oauthConfig, trigger); err != nil {
+				log.Printf("Error refreshing OAuth session tokens: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// refreshStoredSession refreshes a stored session's tokens. A session the
+// authorization server won't refresh is deleted, so it isn't retried every
+// run; its user has to log in again either way.
+func refreshStoredSession(ctx context.Context, storage *Storage, id string, config Config, trigger refreshTrigger) error {
+	session, err := storage.GetSessionByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+
+	_, err = sharedRefresh(ctx, id, session, storage, config, trigger)
+	if errors.Is(err, ErrSessionInvalid) {
+		if deleteErr := storage.DeleteSession(ctx, id); deleteErr != nil {
+			return errors.Join(err, deleteErr)
+		}
+	}
+	return err
+}
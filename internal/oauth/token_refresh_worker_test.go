@@ -0,0 +1,208 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTokenRefreshWorkerConfigFromEnv(t *testing.T) {
+	t.Run("defaults to off", func(t *testing.T) {
+		config, err := TokenRefreshWorkerConfigFromEnv(DefaultRefreshThreshold)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if config.Enabled {
+			t.Error("Expected the worker to be disabled by default")
+		}
+		if config.Ahead != DefaultTokenRefreshAhead || config.Idle != DefaultTokenRefreshIdle || config.Concurrency != DefaultTokenRefreshConcurrency {
+			t.Errorf("Expected defaults, got %+v", config)
+		}
+	})
+
+	t.Run("reads overrides", func(t *testing.T) {
+		t.Setenv("OAUTH_TOKEN_REFRESH_WORKER", "true")
+		t.Setenv("OAUTH_TOKEN_REFRESH_AHEAD", "10m")
+		t.Setenv("OAUTH_TOKEN_REFRESH_IDLE", "2h")
+		t.Setenv("OAUTH_TOKEN_REFRESH_CONCURRENCY", "8")
+
+		config, err := TokenRefreshWorkerConfigFromEnv(DefaultRefreshThreshold)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		want := TokenRefreshWorkerConfig{Enabled: true, Ahead: 10 * time.Minute, Idle: 2 * time.Hour, Concurrency: 8}
+		if config != want {
+			t.Errorf("Expected %+v, got %+v", want, config)
+		}
+	})
+
+	t.Run("rejects ahead within the refresh threshold", func(t *testing.T) {
+		t.Setenv("OAUTH_TOKEN_REFRESH_WORKER", "true")
+		t.Setenv("OAUTH_TOKEN_REFRESH_AHEAD", "5m")
+		if _, err := TokenRefreshWorkerConfigFromEnv(DefaultRefreshThreshold); err == nil {
+			t.Error("Expected an error for ahead equal to the threshold")
+		}
+
+		t.Setenv("OAUTH_TOKEN_REFRESH_AHEAD", "")
+		if _, err := TokenRefreshWorkerConfigFromEnv(30 * time.Minute); err == nil {
+			t.Error("Expected an error for the default ahead within a 30m threshold")
+		}
+	})
+
+	for name, env := range map[string][2]string{
+		"unparseable ahead": {"OAUTH_TOKEN_REFRESH_AHEAD", "later"},
+		"unparseable idle":  {"OAUTH_TOKEN_REFRESH_IDLE", "soon"},
+		"zero concurrency":  {"OAUTH_TOKEN_REFRESH_CONCURRENCY", "0"},
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			t.Setenv(env[0], env[1])
+			if _, err := TokenRefreshWorkerConfigFromEnv(DefaultRefreshThreshold); err == nil {
+				t.Errorf("Expected an error for %s=%s", env[0], env[1])
+			}
+		})
+	}
+}
+
+// workerAuthServer is a mock auth server that tracks how many refreshes it
+// handles at once
+type workerAuthServer struct {
+	*httptest.Server
+	reject   bool // answer invalid_grant
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+	total    atomic.Int32
+}
+
+func newWorkerAuthServer(t *testing.T) *workerAuthServer {
+	s := &workerAuthServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Write([]byte(`{"token_endpoint":"` + s.URL + `/token"}`))
+			return
+		}
+
+		s.total.Add(1)
+		n := s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		for seen := s.maxSeen.Load(); n > seen && !s.maxSeen.CompareAndSwap(seen, n); seen = s.maxSeen.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if s.reject {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"new-access-token","refresh_token":"new-refresh-token","token_type":"DPoP","expires_in":3600}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// workerStorage returns storage listing ids as due for refresh, each loading
+// as a session issued by issuer whose token expires in ten minutes
+func workerStorage(t *testing.T, issuer string, ids ...string) (*queriestest.DB, *Storage) {
+	expiresAt := time.Now().Add(10 * time.Minute)
+	dueRows := make([][]interface{}, len(ids))
+	for i, id := range ids {
+		dueRows[i] = []interface{}{id}
+	}
+
+	fake := queriestest.New(t)
+	fake.Expect("SELECT id FROM oauth_sessions WHERE token_expires_at").Rows([]string{"id"}, dueRows...)
+	fake.Expect("DELETE FROM oauth_sessions").RowsAffected(1)
+	fake.Expect("FROM oauth_sessions WHERE id = $1").Rows(
+		[]string{"id", "did", "access_token", "refresh_token", "dpop_key", "pds_url", "token_expires_at", "issuer", "host", "created_at", "expires_at"},
+		[]interface{}{ids[0], "did:plc:test123", "old-token", "refresh-token", GenerateSecretJWK(), "https://pds.example.com", expiresAt, issuer, "", time.Now(), time.Now().Add(time.Hour)},
+	)
+	fake.Expect("UPDATE oauth_sessions").RowsAffected(1)
+	return fake, NewStorage(fake.DB)
+}
+
+func TestRunTokenRefresh(t *testing.T) {
+	config := Config{Host: "survey.openmeet.net", SecretJWK: GenerateSecretJWK()}
+	workerConfig := TokenRefreshWorkerConfig{Enabled: true, Ahead: 15 * time.Minute, Idle: time.Hour, Concurrency: 2}
+
+	t.Run("refreshes due sessions within the concurrency cap", func(t *testing.T) {
+		authServer := newWorkerAuthServer(t)
+		ids := make([]string, 6)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("session-%d", i)
+		}
+		fake, storage := workerStorage(t, authServer.URL, ids...)
+		proactive := testutil.ToFloat64(TokenRefreshTotal.WithLabelValues("proactive", RefreshResultOK))
+
+		runTokenRefresh(context.Background(), storage, config, workerConfig, 0)
+
+		if n := authServer.total.Load(); n != 6 {
+			t.Errorf("Expected 6 refreshes, got %d", n)
+		}
+		if n := authServer.maxSeen.Load(); n > 2 {
+			t.Errorf("Expected at most 2 refreshes at once, got %d", n)
+		}
+		if n := len(fake.CallsMatching("UPDATE oauth_sessions")); n != 6 {
+			t.Errorf("Expected 6 token updates, got %d", n)
+		}
+		if got := testutil.ToFloat64(TokenRefreshTotal.WithLabelValues("proactive", RefreshResultOK)) - proactive; got != 6 {
+			t.Errorf("Expected 6 proactive refreshes to be counted, got %v", got)
+		}
+
+		due := fake.CallsMatching("SELECT id FROM oauth_sessions WHERE token_expires_at")
+		if len(due) != 1 {
+			t.Fatalf("Expected 1 query for due sessions, got %d", len(due))
+		}
+		expiresBefore := due[0].Args[0].(time.Time)
+		usedSince := due[0].Args[1].(time.Time)
+		if d := time.Until(expiresBefore); d < 14*time.Minute || d > 15*time.Minute {
+			t.Errorf("Expected tokens expiring within 15m, got %v", d)
+		}
+		if d := time.Since(usedSince); d < time.Hour || d > time.Hour+time.Minute {
+			t.Errorf("Expected sessions used within 1h, got %v", d)
+		}
+	})
+
+	t.Run("deletes sessions the auth server won't refresh", func(t *testing.T) {
+		authServer := newWorkerAuthServer(t)
+		authServer.reject = true
+		fake, storage := workerStorage(t, authServer.URL, "dead-session")
+
+		runTokenRefresh(context.Background(), storage, config, workerConfig, 0)
+
+		deletes := fake.CallsMatching("DELETE FROM oauth_sessions")
+		if len(deletes) != 1 || deletes[0].Args[0] != "dead-session" {
+			t.Errorf("Expected dead-session to be deleted, got %v", deletes)
+		}
+		if n := len(fake.CallsMatching("UPDATE oauth_sessions")); n != 0 {
+			t.Errorf("Expected no token updates, got %d", n)
+		}
+	})
+
+	t.Run("stops waiting on jitter when cancelled", func(t *testing.T) {
+		authServer := newWorkerAuthServer(t)
+		_, storage := workerStorage(t, authServer.URL, "session-1")
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		done := make(chan struct{})
+		go func() {
+			runTokenRefresh(ctx, storage, config, workerConfig, time.Hour)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("runTokenRefresh did not return after cancellation")
+		}
+		if n := authServer.total.Load(); n != 0 {
+			t.Errorf("Expected no refreshes, got %d", n)
+		}
+	})
+}
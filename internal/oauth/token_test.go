@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -306,7 +307,7 @@ func TestGetTokenEndpoint(t *testing.T) {
 	defer server.Close()
 	serverURL = server.URL
 
-	endpoint, err := GetTokenEndpoint(server.URL)
+	endpoint, err := GetTokenEndpoint(context.Background(), server.URL)
 	if err != nil {
 		t.Fatalf("GetTokenEndpoint() failed: %v", err)
 	}
@@ -331,7 +332,7 @@ func TestGetTokenEndpoint_MissingField(t *testing.T) {
 	defer server.Close()
 	serverURL = server.URL
 
-	_, err := GetTokenEndpoint(server.URL)
+	_, err := GetTokenEndpoint(context.Background(), server.URL)
 	if err == nil {
 		t.Fatal("Expected error, got nil")
 	}
@@ -0,0 +1,44 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/openmeet-team/survey/internal/oauth"
+
+// tracer returns the oauth tracer from the global provider. It is looked up
+// on each call so a provider installed after package init (e.g. in tests) is used.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// startRefreshSpan starts the span for a token refresh. Only the issuer's
+// host is recorded; tokens and DIDs stay out of traces.
+func startRefreshSpan(ctx context.Context, issuer string, trigger refreshTrigger) (context.Context, trace.Span) {
+	host := issuer
+	if u, err := url.Parse(issuer); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return tracer().Start(ctx, "oauth.refresh_token",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("oauth.issuer_host", host),
+			attribute.String("oauth.refresh_trigger", trigger.name),
+		),
+	)
+}
+
+// endSpan records err on the span (if any) and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,94 @@
+package ogimage
+
+import (
+	"container/list"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCacheSize is how many rendered images a Cache keeps. Each is
+// around 30-60 KB.
+const DefaultCacheSize = 500
+
+// Cache renders images and keeps the most recently used in memory, by a key
+// that changes whenever the card would, such as a survey's slug and
+// version. Concurrent requests for an image that isn't cached render it
+// once.
+type Cache struct {
+	renderer *Renderer
+	renders  singleflight.Group
+
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	image []byte
+}
+
+// NewCache creates a Cache of up to size images drawn by renderer
+func NewCache(renderer *Renderer, size int) *Cache {
+	return &Cache{
+		renderer: renderer,
+		size:     size,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Image returns the PNG for key, rendering card when it isn't cached
+func (c *Cache) Image(key string, card Card) ([]byte, error) {
+	if image, ok := c.get(key); ok {
+		return image, nil
+	}
+	image, err, _ := c.renders.Do(key, func() (interface{}, error) {
+		image, err := c.renderer.Render(card)
+		if err != nil {
+			return nil, err
+		}
+		c.add(key, image)
+		return image, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return image.([]byte), nil
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).image, true
+}
+
+func (c *Cache) add(key string, image []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).image = image
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, image: image})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns how many images are cached
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
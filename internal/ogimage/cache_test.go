@@ -0,0 +1,41 @@
+package ogimage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(newTestRenderer(t), 2)
+
+	first, err := cache.Image("lunch:1", Card{Title: "Lunch order", Questions: 2})
+	require.NoError(t, err)
+
+	t.Run("serves a cached image by key", func(t *testing.T) {
+		again, err := cache.Image("lunch:1", Card{Title: "Ignored, the key is cached"})
+		require.NoError(t, err)
+		assert.Equal(t, first, again)
+	})
+
+	t.Run("renders a new version", func(t *testing.T) {
+		edited, err := cache.Image("lunch:2", Card{Title: "Dinner order", Questions: 2})
+		require.NoError(t, err)
+		assert.NotEqual(t, first, edited)
+		assert.Equal(t, 2, cache.Len())
+	})
+
+	t.Run("evicts the least recently used", func(t *testing.T) {
+		_, err := cache.Image("lunch:1", Card{Title: "Lunch order", Questions: 2})
+		require.NoError(t, err)
+		_, err = cache.Image("picnic:1", Card{Title: "Picnic"})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, cache.Len())
+		_, ok := cache.get("lunch:2")
+		assert.False(t, ok)
+		_, ok = cache.get("lunch:1")
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,338 @@
+// Package ogimage renders the Open Graph images link previews show for a
+// survey: its title, author and question count on the site's colors.
+package ogimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Image size, the 1.91:1 ratio link previews crop to
+const (
+	Width  = 1200
+	Height = 630
+)
+
+// Layout, in pixels
+const (
+	margin        = 80
+	brandSize     = 36
+	titleSize     = 68
+	titleLeading  = 84
+	maxTitleLines = 3
+	footerSize    = 34
+	accentHeight  = 12
+)
+
+// Brand is the name drawn above every title
+const Brand = "OpenMeet Survey"
+
+// Colors from the site's navigation bar
+var (
+	background = color.RGBA{0x2c, 0x3e, 0x50, 0xff}
+	foreground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	muted      = color.RGBA{0xec, 0xf0, 0xf1, 0xff}
+	accent     = color.RGBA{0x34, 0x98, 0xdb, 0xff}
+)
+
+// Card is what an image shows
+type Card struct {
+	Title     string
+	Author    string // Handle, without the @; empty leaves it out
+	Questions int
+}
+
+// Renderer draws cards. Each rune is drawn with the first font that has a
+// glyph for it: the embedded Go fonts, which cover Latin, Greek and
+// Cyrillic, then the fallback fonts in order. A Renderer is safe for
+// concurrent use.
+type Renderer struct {
+	regular []*sfnt.Font
+	bold    []*sfnt.Font
+
+	mu    sync.Mutex // faces hold glyph buffers, so drawing is serialized
+	faces map[faceKey]font.Face
+}
+
+type faceKey struct {
+	font *sfnt.Font
+	size float64
+}
+
+// NewRenderer creates a Renderer with the embedded Go fonts, and fallbacks
+// for the scripts they don't cover
+func NewRenderer(fallbacks ...*sfnt.Font) (*Renderer, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("parse Go Regular: %w", err)
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("parse Go Bold: %w", err)
+	}
+	return &Renderer{
+		regular: append([]*sfnt.Font{regular}, fallbacks...),
+		bold:    append([]*sfnt.Font{bold}, fallbacks...),
+		faces:   make(map[faceKey]font.Face),
+	}, nil
+}
+
+// LoadFont reads a TrueType or OpenType font, or the first font of a
+// collection (.ttc), such as Noto Sans CJK
+func LoadFont(path string) (*sfnt.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f, err := opentype.Parse(data); err == nil {
+		return f, nil
+	}
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse font %s: %w", path, err)
+	}
+	return collection.Font(0)
+}
+
+// DefaultFallbackFonts are where Noto Sans CJK, for Chinese, Japanese and
+// Korean titles, is installed by Alpine's font-noto-cjk package (as in the
+// Docker image) and Debian's fonts-noto-cjk
+var DefaultFallbackFonts = []string{
+	"/usr/share/fonts/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+}
+
+// FallbackFontsFromEnv loads the fonts OG_IMAGE_FONTS lists, a
+// comma-separated list of font files for scripts the Go fonts don't cover,
+// e.g. /usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc. Without it,
+// the first of DefaultFallbackFonts that's installed is used, if any.
+func FallbackFontsFromEnv() ([]*sfnt.Font, error) {
+	list := os.Getenv("OG_IMAGE_FONTS")
+	if list == "" {
+		return defaultFallbackFonts()
+	}
+
+	var fonts []*sfnt.Font
+	for _, path := range strings.Split(list, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		f, err := LoadFont(path)
+		if err != nil {
+			return nil, fmt.Errorf("OG_IMAGE_FONTS: %w", err)
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+}
+
+// defaultFallbackFonts loads the first of DefaultFallbackFonts that exists
+func defaultFallbackFonts() ([]*sfnt.Font, error) {
+	for _, path := range DefaultFallbackFonts {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		f, err := LoadFont(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*sfnt.Font{f}, nil
+	}
+	return nil, nil
+}
+
+// Render draws card as a Width×Height PNG
+func (r *Renderer) Render(card Card) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, Height-accentHeight, Width, Height), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	textWidth := fixed.I(Width - 2*margin)
+	r.drawText(img, r.bold, brandSize, accent, margin, margin+brandSize, Brand)
+
+	title := strings.Join(strings.Fields(card.Title), " ")
+	lines := r.wrap(r.bold, titleSize, title, textWidth, maxTitleLines)
+	// Center the title between the brand and the footer
+	top := margin + brandSize + (Height-2*margin-brandSize-footerSize-accentHeight-len(lines)*titleLeading)/2
+	for i, line := range lines {
+		r.drawText(img, r.bold, titleSize, foreground, margin, top+(i+1)*titleLeading-(titleLeading-titleSize)/2, line)
+	}
+
+	footer := r.ellipsize(r.regular, footerSize, Footer(card), textWidth)
+	r.drawText(img, r.regular, footerSize, muted, margin, Height-margin, footer)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Footer is the line under the title: the author and question count
+func Footer(card Card) string {
+	questions := fmt.Sprintf("%d questions", card.Questions)
+	if card.Questions == 1 {
+		questions = "1 question"
+	}
+	if card.Author == "" {
+		return questions
+	}
+	return "@" + card.Author + " · " + questions
+}
+
+// face returns f at size, creating it the first time
+func (r *Renderer) face(f *sfnt.Font, size float64) font.Face {
+	key := faceKey{font: f, size: size}
+	if face, ok := r.faces[key]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		// Only invalid options fail, and ours are fixed
+		panic(fmt.Sprintf("ogimage: new face: %v", err))
+	}
+	r.faces[key] = face
+	return face
+}
+
+// fontFor returns the first of fonts with a glyph for c, or the first font,
+// which draws its missing glyph box
+func fontFor(fonts []*sfnt.Font, c rune) *sfnt.Font {
+	var buf sfnt.Buffer
+	for _, f := range fonts {
+		if i, err := f.GlyphIndex(&buf, c); err == nil && i != 0 {
+			return f
+		}
+	}
+	return fonts[0]
+}
+
+// run is text that one font draws
+type run struct {
+	font *sfnt.Font
+	text string
+}
+
+// runs splits text into runs by the font that draws each rune. Spaces and
+// marks stay with the run before them.
+func runs(fonts []*sfnt.Font, text string) []run {
+	var out []run
+	for _, c := range text {
+		f := fontFor(fonts, c)
+		if len(out) > 0 && (f == out[len(out)-1].font || unicode.IsSpace(c) || unicode.Is(unicode.Mn, c)) {
+			out[len(out)-1].text += string(c)
+			continue
+		}
+		out = append(out, run{font: f, text: string(c)})
+	}
+	return out
+}
+
+// measure returns how wide text is drawn
+func (r *Renderer) measure(fonts []*sfnt.Font, size float64, text string) fixed.Int26_6 {
+	var width fixed.Int26_6
+	for _, run := range runs(fonts, text) {
+		width += font.MeasureString(r.face(run.font, size), run.text)
+	}
+	return width
+}
+
+// drawText draws text with its baseline starting at (x, y)
+func (r *Renderer) drawText(img draw.Image, fonts []*sfnt.Font, size float64, c color.Color, x, y int, text string) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Dot: fixed.P(x, y)}
+	for _, run := range runs(fonts, text) {
+		d.Face = r.face(run.font, size)
+		d.DrawString(run.text)
+	}
+}
+
+// wrap breaks text into at most maxLines lines no wider than width,
+// between words where it can. Text past the last line is cut with an
+// ellipsis. Scripts written without spaces, like Chinese and Japanese,
+// break between characters.
+func (r *Renderer) wrap(fonts []*sfnt.Font, size float64, text string, width fixed.Int26_6, maxLines int) []string {
+	var lines []string
+	rest := text
+	for rest != "" && len(lines) < maxLines {
+		if len(lines) == maxLines-1 {
+			lines = append(lines, r.ellipsize(fonts, size, rest, width))
+			break
+		}
+		line, next := r.fitLine(fonts, size, rest, width)
+		lines = append(lines, line)
+		rest = next
+	}
+	return lines
+}
+
+// fitLine splits off the longest start of text that fits width, preferring
+// to break at a space. A word too long for a line is broken anywhere.
+func (r *Renderer) fitLine(fonts []*sfnt.Font, size float64, text string, width fixed.Int26_6) (line, rest string) {
+	if r.measure(fonts, size, text) <= width {
+		return text, ""
+	}
+	runes := []rune(text)
+	fits := 1 // Always make progress, even if one rune is too wide
+	for n := 2; n <= len(runes); n++ {
+		if r.measure(fonts, size, string(runes[:n])) > width {
+			break
+		}
+		fits = n
+	}
+	// Break after the last space that fits, unless the break follows a
+	// character from a script written without spaces
+	if space := lastSpace(runes[:fits+1]); space > 0 && !unbreakable(runes[fits-1]) {
+		fits = space
+	}
+	return strings.TrimSpace(string(runes[:fits])), strings.TrimSpace(string(runes[fits:]))
+}
+
+// lastSpace returns the index of the last space in runes, or -1
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// unbreakable reports whether c is from a script that lines may break
+// after any character of
+func unbreakable(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
+
+// ellipsize cuts text to fit width, ending it with an ellipsis when cut
+func (r *Renderer) ellipsize(fonts []*sfnt.Font, size float64, text string, width fixed.Int26_6) string {
+	if r.measure(fonts, size, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		cut := strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + "…"
+		if r.measure(fonts, size, cut) <= width {
+			return cut
+		}
+	}
+	return "…"
+}
@@ -0,0 +1,158 @@
+package ogimage
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func newTestRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	r, err := NewRenderer()
+	require.NoError(t, err)
+	return r
+}
+
+func TestRender(t *testing.T) {
+	r := newTestRenderer(t)
+
+	data, err := r.Render(Card{Title: "Where should the meetup go next?", Author: "jane.bsky.social", Questions: 4})
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, Width, img.Bounds().Dx())
+	assert.Equal(t, Height, img.Bounds().Dy())
+
+	red, green, blue, _ := img.At(5, 5).RGBA()
+	assert.Equal(t, [3]uint32{0x2c, 0x3e, 0x50}, [3]uint32{red >> 8, green >> 8, blue >> 8}, "background")
+	red, green, blue, _ = img.At(5, Height-1).RGBA()
+	assert.Equal(t, [3]uint32{0x34, 0x98, 0xdb}, [3]uint32{red >> 8, green >> 8, blue >> 8}, "accent bar")
+
+	other, err := r.Render(Card{Title: "Lunch order", Questions: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, data, other)
+}
+
+func TestWrap(t *testing.T) {
+	r := newTestRenderer(t)
+	width := fixed.I(Width - 2*margin)
+
+	t.Run("short title is one line", func(t *testing.T) {
+		assert.Equal(t, []string{"Lunch order"}, r.wrap(r.bold, titleSize, "Lunch order", width, maxTitleLines))
+	})
+
+	t.Run("breaks between words", func(t *testing.T) {
+		title := "Annual community feedback survey about the neighbourhood library"
+		lines := r.wrap(r.bold, titleSize, title, width, maxTitleLines)
+		assert.Greater(t, len(lines), 1)
+		assert.Equal(t, title, strings.Join(lines, " "))
+		for _, line := range lines {
+			assert.LessOrEqual(t, r.measure(r.bold, titleSize, line), width, line)
+		}
+	})
+
+	t.Run("ellipsis after the last line", func(t *testing.T) {
+		title := strings.Repeat("A very long survey title that keeps going ", 6)
+		lines := r.wrap(r.bold, titleSize, title, width, maxTitleLines)
+		require.Len(t, lines, maxTitleLines)
+		assert.True(t, strings.HasSuffix(lines[2], "…"), lines[2])
+		for _, line := range lines {
+			assert.LessOrEqual(t, r.measure(r.bold, titleSize, line), width, line)
+		}
+	})
+
+	t.Run("breaks a word too long for a line", func(t *testing.T) {
+		lines := r.wrap(r.bold, titleSize, strings.Repeat("x", 60), width, maxTitleLines)
+		assert.Greater(t, len(lines), 1)
+		assert.Equal(t, strings.Repeat("x", 60), strings.Join(lines, ""))
+	})
+
+	t.Run("breaks between characters without spaces", func(t *testing.T) {
+		title := strings.Repeat("次の勉強会はどこで開催しますか", 3)
+		lines := r.wrap(r.bold, titleSize, title, width, maxTitleLines)
+		assert.Greater(t, len(lines), 1)
+		assert.Equal(t, title, strings.Join(lines, ""))
+	})
+}
+
+func TestFontFor(t *testing.T) {
+	r := newTestRenderer(t)
+	var buf sfnt.Buffer
+
+	// The Go fonts draw Latin, Greek and Cyrillic themselves
+	for _, c := range "Aé Ωλ Жя" {
+		i, err := fontFor(r.regular, c).GlyphIndex(&buf, c)
+		require.NoError(t, err)
+		assert.NotZero(t, i, string(c))
+	}
+
+	// Without a fallback, a script they lack gets the missing glyph box
+	assert.Same(t, r.regular[0], fontFor(r.regular, '調'))
+	assert.Len(t, runs(r.regular, "Привет 世界"), 1)
+}
+
+func TestFooter(t *testing.T) {
+	assert.Equal(t, "@jane.bsky.social · 4 questions", Footer(Card{Author: "jane.bsky.social", Questions: 4}))
+	assert.Equal(t, "1 question", Footer(Card{Questions: 1}))
+}
+
+func TestFallbackFontsFromEnv(t *testing.T) {
+	t.Setenv("OG_IMAGE_FONTS", "/no/such/font.ttf")
+	_, err := FallbackFontsFromEnv()
+	assert.Error(t, err)
+
+	t.Run("defaults to an installed font", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "fallback.ttf")
+		require.NoError(t, os.WriteFile(path, goregular.TTF, 0o644))
+		defaults := DefaultFallbackFonts
+		DefaultFallbackFonts = []string{filepath.Join(dir, "missing.ttc"), path}
+		t.Cleanup(func() { DefaultFallbackFonts = defaults })
+
+		t.Setenv("OG_IMAGE_FONTS", "")
+		fonts, err := FallbackFontsFromEnv()
+		require.NoError(t, err)
+		assert.Len(t, fonts, 1)
+
+		DefaultFallbackFonts = []string{filepath.Join(dir, "missing.ttc")}
+		fonts, err = FallbackFontsFromEnv()
+		require.NoError(t, err)
+		assert.Empty(t, fonts)
+	})
+}
+
+// TestDefaultFontsDrawCJK checks a CJK title is drawn without missing glyph
+// boxes under the default config, where Noto Sans CJK is installed as in the
+// Docker image
+func TestDefaultFontsDrawCJK(t *testing.T) {
+	t.Setenv("OG_IMAGE_FONTS", "")
+	fonts, err := FallbackFontsFromEnv()
+	require.NoError(t, err)
+	if len(fonts) == 0 {
+		t.Skip("Noto Sans CJK isn't installed at any of DefaultFallbackFonts")
+	}
+	r, err := NewRenderer(fonts...)
+	require.NoError(t, err)
+
+	var buf sfnt.Buffer
+	for _, fonts := range [][]*sfnt.Font{r.bold, r.regular} {
+		for _, c := range "次の勉強会はどこで開催しますか 下次聚会在哪里 다음 모임은 어디서" {
+			if c == ' ' {
+				continue
+			}
+			i, err := fontFor(fonts, c).GlyphIndex(&buf, c)
+			require.NoError(t, err)
+			assert.NotZero(t, i, "%q is drawn as .notdef", c)
+		}
+	}
+}
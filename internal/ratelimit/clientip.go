@@ -0,0 +1,195 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultTrustedProxies are the networks trusted to set X-Forwarded-For when
+// TRUSTED_PROXIES is unset: private networks and loopback, where our load
+// balancers live
+var DefaultTrustedProxies = []string{
+	"10.0.0.0/8",     // Private network (Class A)
+	"172.16.0.0/12",  // Private network (Class B)
+	"192.168.0.0/16", // Private network (Class C)
+	"127.0.0.0/8",    // Loopback IPv4
+	"::1/128",        // Loopback IPv6
+	"fc00::/7",       // Unique local address (IPv6 private)
+	"fe80::/10",      // Link-local address (IPv6)
+}
+
+// DefaultIPExtractor trusts DefaultTrustedProxies
+var DefaultIPExtractor = mustNewIPExtractor(DefaultTrustedProxies)
+
+// IPExtractor finds the client IP of a request, trusting X-Forwarded-For
+// only from a list of proxy networks
+type IPExtractor struct {
+	trusted []*net.IPNet
+}
+
+// NewIPExtractor creates an IPExtractor trusting the given CIDR ranges. A
+// bare IP is trusted as a single address.
+func NewIPExtractor(trustedProxies []string) (*IPExtractor, error) {
+	x := &IPExtractor{}
+	for _, cidr := range trustedProxies {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			cidr = fmt.Sprintf("%s/%d", cidr, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		x.trusted = append(x.trusted, ipNet)
+	}
+	return x, nil
+}
+
+func mustNewIPExtractor(trustedProxies []string) *IPExtractor {
+	x, err := NewIPExtractor(trustedProxies)
+	if err != nil {
+		// This should never happen with hardcoded CIDRs
+		panic(err)
+	}
+	return x
+}
+
+// IPExtractorFromEnv builds an IPExtractor from TRUSTED_PROXIES, a
+// comma-separated list of CIDR ranges (or IPs) of the proxies in front of the
+// service. Unset means DefaultTrustedProxies.
+func IPExtractorFromEnv() (*IPExtractor, error) {
+	value := os.Getenv("TRUSTED_PROXIES")
+	if value == "" {
+		return DefaultIPExtractor, nil
+	}
+
+	var proxies []string
+	for _, proxy := range strings.Split(value, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	x, err := NewIPExtractor(proxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	return x, nil
+}
+
+// isTrusted checks if an IP address is in the trusted proxy ranges
+func (x *IPExtractor) isTrusted(ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	for _, ipNet := range x.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP extracts an IP address from a string that might include a port
+// Returns the IP part only, or empty string if invalid
+func parseIP(addr string) string {
+	// Try to split host:port first
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port, treat as plain IP
+		host = addr
+	}
+
+	// Validate it's a real IP
+	ip := net.ParseIP(strings.TrimSpace(host))
+	if ip == nil {
+		return ""
+	}
+
+	return ip.String()
+}
+
+// ClientIP extracts the real client IP from the request using secure logic
+//
+// Security considerations:
+// 1. Only trusts X-Forwarded-For when request comes from a trusted proxy
+// 2. Uses rightmost untrusted IP from X-Forwarded-For (more secure than leftmost)
+// 3. Falls back to RemoteAddr when X-Forwarded-For is not from trusted source
+// 4. Validates all IPs to prevent injection attacks
+//
+// Why rightmost untrusted IP?
+// X-Forwarded-For format: "client, proxy1, proxy2, proxy3"
+// Each proxy appends to the right. We can only trust IPs added by OUR proxies.
+// Walk from right to left, skip our trusted proxies, return first untrusted IP.
+//
+// Example: "spoofed-ip, real-client, untrusted-proxy, 10.0.0.1"
+// - 10.0.0.1 is our load balancer (trusted)
+// - untrusted-proxy is the rightmost untrusted IP (return this)
+// - real-client and spoofed-ip were added by untrusted sources
+func (x *IPExtractor) ClientIP(r *http.Request) string {
+	remoteIP := parseIP(r.RemoteAddr)
+
+	// If RemoteAddr is not from a trusted proxy, don't trust X-Forwarded-For
+	if !x.isTrusted(remoteIP) {
+		return remoteIP
+	}
+
+	// RemoteAddr is trusted, check X-Forwarded-For
+	xff := r.Header.Get("X-Forwarded-For")
+	if xff == "" {
+		return remoteIP
+	}
+
+	// Parse X-Forwarded-For chain
+	ips := strings.Split(xff, ",")
+	if len(ips) == 0 {
+		return remoteIP
+	}
+
+	// Walk from right to left to find the rightmost untrusted IP
+	for i := len(ips) - 1; i >= 0; i-- {
+		ipStr := strings.TrimSpace(ips[i])
+
+		// Skip empty or invalid IPs
+		if ipStr == "" {
+			continue
+		}
+
+		// Parse and validate the IP
+		parsedIP := parseIP(ipStr)
+		if parsedIP == "" {
+			continue // Skip invalid IPs
+		}
+
+		// If this IP is not trusted, it's the rightmost untrusted IP
+		if !x.isTrusted(parsedIP) {
+			return parsedIP
+		}
+	}
+
+	// All IPs in the chain are trusted (internal traffic)
+	// Use the leftmost IP as the client
+	for i := 0; i < len(ips); i++ {
+		ipStr := strings.TrimSpace(ips[i])
+		parsedIP := parseIP(ipStr)
+		if parsedIP != "" {
+			return parsedIP
+		}
+	}
+
+	// Fallback to RemoteAddr if we couldn't parse anything
+	return remoteIP
+}
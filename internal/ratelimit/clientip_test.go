@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP_DefaultTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"direct connection", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"spoofed header from a public IP", "203.0.113.7:1234", "192.0.2.1", "203.0.113.7"},
+		{"behind a private load balancer", "10.0.0.1:1234", "203.0.113.7", "203.0.113.7"},
+		{"rightmost untrusted IP", "10.0.0.1:1234", "192.0.2.1, 203.0.113.7, 10.0.0.2", "203.0.113.7"},
+		{"only trusted hops", "10.0.0.1:1234", "10.0.0.5, 10.0.0.2", "10.0.0.5"},
+		{"invalid entries skipped", "10.0.0.1:1234", "203.0.113.7, not-an-ip", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			assert.Equal(t, tt.want, DefaultIPExtractor.ClientIP(req))
+		})
+	}
+}
+
+func TestClientIP_CustomTrustedProxies(t *testing.T) {
+	extractor, err := NewIPExtractor([]string{"198.51.100.0/24", "192.0.2.10"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.4:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 192.0.2.10")
+	assert.Equal(t, "203.0.113.7", extractor.ClientIP(req))
+
+	// Private networks are no longer trusted
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	assert.Equal(t, "10.0.0.1", extractor.ClientIP(req))
+}
+
+func TestIPExtractorFromEnv(t *testing.T) {
+	t.Run("defaults to private networks", func(t *testing.T) {
+		extractor, err := IPExtractorFromEnv()
+		require.NoError(t, err)
+		assert.Same(t, DefaultIPExtractor, extractor)
+	})
+
+	t.Run("reads a comma-separated list", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "198.51.100.0/24, 2001:db8::1")
+		extractor, err := IPExtractorFromEnv()
+		require.NoError(t, err)
+		assert.True(t, extractor.isTrusted("198.51.100.200"))
+		assert.True(t, extractor.isTrusted("2001:db8::1"))
+		assert.False(t, extractor.isTrusted("10.0.0.1"))
+	})
+
+	t.Run("rejects an invalid entry", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "198.51.100.0/24,load-balancer")
+		_, err := IPExtractorFromEnv()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DBLimiter enforces sliding window limits shared by every process using
+// the same database. Each request it allows is a row in rate_limit_hits;
+// checks for a key take a transaction-scoped advisory lock on it, so
+// concurrent checks, in this process or another, are counted one at a time.
+// It is safe for concurrent use.
+type DBLimiter struct {
+	db *sql.DB
+}
+
+// NewDBLimiter creates a DBLimiter counting in db
+func NewDBLimiter(db *sql.DB) *DBLimiter {
+	return &DBLimiter{db: db}
+}
+
+// Allow records a request for key if fewer than limit were recorded in the
+// last window. When the limit is reached it returns false and how long
+// until the oldest request in the window leaves it.
+func (l *DBLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Held until commit or rollback. Keys whose hashes collide only wait
+	// for each other.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		return false, 0, fmt.Errorf("failed to lock rate limit key: %w", err)
+	}
+
+	// clock_timestamp, not NOW: the transaction may have started well
+	// before it got the lock
+	var count int
+	var oldest sql.NullTime
+	var now time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), MIN(created_at), clock_timestamp()
+		FROM rate_limit_hits
+		WHERE key = $1 AND created_at > clock_timestamp() - make_interval(secs => $2)
+	`, key, window.Seconds()).Scan(&count, &oldest, &now)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to count requests: %w", err)
+	}
+
+	if count >= limit {
+		retryAfter := time.Duration(0)
+		if oldest.Valid {
+			retryAfter = max(0, oldest.Time.Add(window).Sub(now))
+		}
+		return false, retryAfter, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO rate_limit_hits (key, created_at) VALUES ($1, $2)`, key, now); err != nil {
+		return false, 0, fmt.Errorf("failed to record request: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, 0, fmt.Errorf("failed to commit request: %w", err)
+	}
+
+	return true, 0, nil
+}
+
+// Prune deletes recorded requests older than retention, which must be at
+// least the longest window any process passes to Allow. It returns how many
+// it deleted.
+func (l *DBLimiter) Prune(ctx context.Context, retention time.Duration) (int64, error) {
+	result, err := l.db.ExecContext(ctx, `
+		DELETE FROM rate_limit_hits
+		WHERE created_at < clock_timestamp() - make_interval(secs => $1)
+	`, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune rate limit hits: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// StartPruning runs Prune every interval until ctx is done
+func (l *DBLimiter) StartPruning(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := l.Prune(ctx, retention); err != nil {
+				log.Printf("Error pruning rate limit hits: %v", err)
+			} else if n > 0 {
+				log.Printf("Pruned %d expired rate limit hits", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+//go:build e2e
+
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/openmeet-team/survey/internal/db"
+)
+
+// connect opens a pool of its own on the test database, as another API
+// replica would
+func connect(t *testing.T) *sql.DB {
+	t.Helper()
+
+	cfg, err := db.ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Failed to load database config: %v", err)
+	}
+	conn, err := db.Connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	if err := db.Migrate(context.Background(), conn); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestDBLimiter_SharedAcrossProcesses checks that two limiters on separate
+// pools, hammering one key at once, allow no more than the limit between them
+func TestDBLimiter_SharedAcrossProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	replicas := []*DBLimiter{NewDBLimiter(connect(t)), NewDBLimiter(connect(t))}
+	key := "test:" + uuid.NewString()
+	const limit = 10
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(limiter *DBLimiter) {
+			defer wg.Done()
+			ok, _, err := limiter.Allow(context.Background(), key, limit, time.Hour)
+			if err != nil {
+				t.Errorf("Allow failed: %v", err)
+			}
+			if ok {
+				allowed.Add(1)
+			}
+		}(replicas[i%2])
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != limit {
+		t.Errorf("allowed %d requests, want %d", got, limit)
+	}
+
+	// Either replica now rejects the key until the window passes
+	for _, limiter := range replicas {
+		ok, retryAfter, err := limiter.Allow(context.Background(), key, limit, time.Hour)
+		if err != nil {
+			t.Fatalf("Allow failed: %v", err)
+		}
+		if ok {
+			t.Error("allowed a request past the limit")
+		}
+		if retryAfter <= 0 || retryAfter > time.Hour {
+			t.Errorf("retryAfter = %v, want within the window", retryAfter)
+		}
+	}
+}
+
+// TestDBLimiter_SlidingWindow checks that requests leave the window as it
+// slides past them
+func TestDBLimiter_SlidingWindow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database test in short mode")
+	}
+
+	limiter := NewDBLimiter(connect(t))
+	key := "test:" + uuid.NewString()
+	ctx := context.Background()
+	window := 500 * time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		if ok, _, err := limiter.Allow(ctx, key, 2, window); err != nil || !ok {
+			t.Fatalf("request %d: allowed = %v, err = %v", i+1, ok, err)
+		}
+	}
+	if ok, _, _ := limiter.Allow(ctx, key, 2, window); ok {
+		t.Fatal("allowed a third request in the window")
+	}
+
+	time.Sleep(window + 100*time.Millisecond)
+	if ok, _, err := limiter.Allow(ctx, key, 2, window); err != nil || !ok {
+		t.Errorf("after the window: allowed = %v, err = %v", ok, err)
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/db/queriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countColumns are the columns DBLimiter.Allow counts with
+var countColumns = []string{"count", "min", "clock_timestamp"}
+
+func TestDBLimiter_AllowsUnderLimit(t *testing.T) {
+	fake := queriestest.New(t)
+	now := time.Now()
+	fake.Expect("pg_advisory_xact_lock").RowsAffected(1)
+	fake.Expect("FROM rate_limit_hits").Rows(countColumns, []interface{}{2, now.Add(-time.Minute), now})
+	fake.Expect("INSERT INTO rate_limit_hits").RowsAffected(1)
+
+	allowed, retryAfter, err := NewDBLimiter(fake.DB).Allow(context.Background(), "ai:auth:did:plc:test", 3, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Zero(t, retryAfter)
+
+	lock := fake.CallsMatching("pg_advisory_xact_lock")
+	require.Len(t, lock, 1)
+	assert.Equal(t, "ai:auth:did:plc:test", lock[0].Args[0], "locks the key")
+
+	count := fake.CallsMatching("FROM rate_limit_hits")
+	require.Len(t, count, 1)
+	assert.Equal(t, []interface{}{"ai:auth:did:plc:test", 3600.0}, count[0].Args)
+
+	insert := fake.CallsMatching("INSERT INTO rate_limit_hits")
+	require.Len(t, insert, 1)
+	assert.Equal(t, "ai:auth:did:plc:test", insert[0].Args[0])
+	assert.WithinDuration(t, now, insert[0].Args[1].(time.Time), 0, "recorded at the database's time")
+}
+
+func TestDBLimiter_RejectsAtLimit(t *testing.T) {
+	fake := queriestest.New(t)
+	now := time.Now()
+	fake.Expect("pg_advisory_xact_lock").RowsAffected(1)
+	fake.Expect("FROM rate_limit_hits").Rows(countColumns, []interface{}{3, now.Add(-20 * time.Minute), now})
+
+	allowed, retryAfter, err := NewDBLimiter(fake.DB).Allow(context.Background(), "ai:anon:192.0.2.1", 3, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, 40*time.Minute, retryAfter, "until the oldest request leaves the window")
+	assert.Empty(t, fake.CallsMatching("INSERT INTO rate_limit_hits"), "rejected requests aren't counted")
+}
+
+func TestDBLimiter_ZeroLimit(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("pg_advisory_xact_lock").RowsAffected(1)
+	fake.Expect("FROM rate_limit_hits").Rows(countColumns, []interface{}{0, nil, time.Now()})
+
+	allowed, retryAfter, err := NewDBLimiter(fake.DB).Allow(context.Background(), "ai:anon:192.0.2.1", 0, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Zero(t, retryAfter)
+}
+
+func TestDBLimiter_Errors(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("pg_advisory_xact_lock").RowsAffected(1)
+	fake.Expect("FROM rate_limit_hits").Err(errors.New("connection reset"))
+
+	allowed, _, err := NewDBLimiter(fake.DB).Allow(context.Background(), "ai:anon:192.0.2.1", 3, time.Hour)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+	assert.False(t, allowed)
+}
+
+func TestDBLimiter_Prune(t *testing.T) {
+	fake := queriestest.New(t)
+	fake.Expect("DELETE FROM rate_limit_hits").RowsAffected(7)
+
+	n, err := NewDBLimiter(fake.DB).Prune(context.Background(), 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), n)
+
+	calls := fake.CallsMatching("DELETE FROM rate_limit_hits")
+	require.Len(t, calls, 1)
+	assert.Equal(t, 86400.0, calls[0].Args[0])
+}
@@ -0,0 +1,134 @@
+// Package ratelimit throttles requests per client IP with token buckets.
+//
+// A Limiter gives each client a bucket of Burst tokens that refills at
+// Requests per Period; each request spends a token, and a client with an
+// empty bucket gets 429 Too Many Requests with a Retry-After header.
+//
+// A Limiter's buckets live in one process. A DBLimiter instead counts
+// requests in the database, so its limits hold across replicas and deploys.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// Config configures a Limiter
+type Config struct {
+	Requests int           // Sustained rate: Requests per Period
+	Period   time.Duration // Window the sustained rate is measured over
+	Burst    int           // Requests allowed back to back; zero means Requests
+
+	// ClientIP identifies clients. Nil means DefaultIPExtractor.
+	ClientIP *IPExtractor
+}
+
+// bucket is one client's token bucket and when it was last used
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter enforces a Config per client IP. It is safe for concurrent use.
+type Limiter struct {
+	limit    rate.Limit
+	burst    int
+	clientIP *IPExtractor
+	now      func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	idleAfter time.Duration // a bucket unused this long is full again
+	lastSweep time.Time
+}
+
+// New creates a Limiter
+func New(config Config) *Limiter {
+	burst := config.Burst
+	if burst <= 0 {
+		burst = config.Requests
+	}
+	clientIP := config.ClientIP
+	if clientIP == nil {
+		clientIP = DefaultIPExtractor
+	}
+	limit := rate.Limit(float64(config.Requests) / config.Period.Seconds())
+
+	return &Limiter{
+		limit:     limit,
+		burst:     burst,
+		clientIP:  clientIP,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+		idleAfter: time.Duration(float64(burst) / float64(limit) * float64(time.Second)),
+	}
+}
+
+// Allow spends one of key's tokens. When none is left it returns false and
+// how long until one is.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	if b.limiter.AllowN(now, 1) {
+		return true, 0
+	}
+	reservation := b.limiter.ReserveN(now, 1)
+	retryAfter := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+	return false, retryAfter
+}
+
+// sweep drops buckets idle long enough to have refilled, which behave like
+// new ones, at most once per idle period. The caller holds l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleAfter {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.idleAfter {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware returns an Echo middleware that limits requests per client IP
+func (l *Limiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			allowed, retryAfter := l.Allow(l.clientIP.ClientIP(c.Request()))
+			if !allowed {
+				c.Response().Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+				return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
+					"error":   "Rate limit exceeded",
+					"message": "Too many requests. Please try again later.",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// retryAfterSeconds formats a delay as a Retry-After value: whole seconds,
+// rounded up so a client retrying on time finds a token
+func retryAfterSeconds(delay time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(delay.Seconds()))))
+}
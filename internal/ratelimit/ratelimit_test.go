@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLimiter returns a 10 per minute, burst 5 limiter on a fake clock
+func newTestLimiter() (*Limiter, *time.Time) {
+	limiter := New(Config{Requests: 10, Period: time.Minute, Burst: 5})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+// serve sends a request from remoteAddr through the limiter's middleware
+func serve(limiter *Limiter, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	handler := limiter.Middleware()(func(c echo.Context) error {
+		return c.String(http.StatusOK, "success")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth/login", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	if err := handler(echo.New().NewContext(req, rec)); err != nil {
+		panic(err)
+	}
+	return rec
+}
+
+func TestLimiter_RejectsPastBurst(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	for i := 0; i < 5; i++ {
+		rec := serve(limiter, "203.0.113.7:1234", "")
+		require.Equal(t, http.StatusOK, rec.Code, "request %d should be within the burst", i+1)
+	}
+
+	rec := serve(limiter, "203.0.113.7:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "6", rec.Header().Get("Retry-After"), "a token refills every 6s at 10 per minute")
+	assert.Contains(t, rec.Body.String(), "Rate limit exceeded")
+}
+
+func TestLimiter_AllowsTrafficBelowTheLimit(t *testing.T) {
+	limiter, now := newTestLimiter()
+
+	// One request every 7s for ten minutes stays under 10 per minute
+	for i := 0; i < 90; i++ {
+		rec := serve(limiter, "203.0.113.7:1234", "")
+		require.Equal(t, http.StatusOK, rec.Code, "request %d", i+1)
+		*now = now.Add(7 * time.Second)
+	}
+}
+
+func TestLimiter_RefillsOverTime(t *testing.T) {
+	limiter, now := newTestLimiter()
+
+	for i := 0; i < 5; i++ {
+		serve(limiter, "203.0.113.7:1234", "")
+	}
+	require.Equal(t, http.StatusTooManyRequests, serve(limiter, "203.0.113.7:1234", "").Code)
+
+	*now = now.Add(6 * time.Second)
+	assert.Equal(t, http.StatusOK, serve(limiter, "203.0.113.7:1234", "").Code, "one token after 6s")
+	assert.Equal(t, http.StatusTooManyRequests, serve(limiter, "203.0.113.7:1234", "").Code, "but only one")
+}
+
+func TestLimiter_SeparatesClients(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	for i := 0; i < 5; i++ {
+		serve(limiter, "203.0.113.7:1234", "")
+	}
+	require.Equal(t, http.StatusTooManyRequests, serve(limiter, "203.0.113.7:1234", "").Code)
+
+	assert.Equal(t, http.StatusOK, serve(limiter, "203.0.113.8:1234", "").Code)
+}
+
+func TestLimiter_UsesForwardedForFromTrustedProxies(t *testing.T) {
+	limiter, _ := newTestLimiter()
+
+	// Clients behind our load balancer have their own buckets
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, serve(limiter, "10.0.0.1:1234", "203.0.113.7").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve(limiter, "10.0.0.1:1234", "203.0.113.7").Code)
+	assert.Equal(t, http.StatusOK, serve(limiter, "10.0.0.1:1234", "203.0.113.8").Code)
+
+	// A client can't dodge the limit by forging X-Forwarded-For
+	for i := 0; i < 5; i++ {
+		serve(limiter, "198.51.100.9:1234", "")
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve(limiter, "198.51.100.9:1234", "192.0.2.1").Code)
+}
+
+func TestLimiter_DropsIdleBuckets(t *testing.T) {
+	limiter, now := newTestLimiter()
+
+	limiter.Allow("203.0.113.7")
+	limiter.Allow("203.0.113.8")
+	require.Len(t, limiter.buckets, 2)
+
+	// Burst 5 at one token per 6s is full again after 30s
+	*now = now.Add(30 * time.Second)
+	limiter.Allow("203.0.113.8")
+	assert.Len(t, limiter.buckets, 1)
+	assert.Contains(t, limiter.buckets, "203.0.113.8")
+}
+
+func TestLimiter_BurstDefaultsToRequests(t *testing.T) {
+	limiter := New(Config{Requests: 3, Period: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("203.0.113.7")
+		require.True(t, allowed)
+	}
+	allowed, retryAfter := limiter.Allow("203.0.113.7")
+	assert.False(t, allowed)
+	assert.Positive(t, retryAfter)
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, "1", retryAfterSeconds(0))
+	assert.Equal(t, "1", retryAfterSeconds(200*time.Millisecond))
+	assert.Equal(t, "6", retryAfterSeconds(5100*time.Millisecond))
+	assert.Equal(t, "6", retryAfterSeconds(6*time.Second))
+}
@@ -62,6 +62,19 @@ func TestAITokensTotal_CounterLabels(t *testing.T) {
 	assert.Equal(t, 300.0, outputTokens, "output tokens should be 300")
 }
 
+func TestAIInputTokenEstimateRatio_Histogram(t *testing.T) {
+	// Reset metrics before test
+	AIInputTokenEstimateRatio.Reset()
+
+	// Record actual/estimated input tokens per provider
+	AIInputTokenEstimateRatio.WithLabelValues("openai").Observe(1.02)
+	AIInputTokenEstimateRatio.WithLabelValues("openai").Observe(0.97)
+	AIInputTokenEstimateRatio.WithLabelValues("anthropic").Observe(1.2)
+
+	// Verify each provider has its own series
+	assert.Equal(t, 2, testutil.CollectAndCount(AIInputTokenEstimateRatio))
+}
+
 func TestAIDailyCost_Gauge(t *testing.T) {
 	// Set daily cost
 	AIDailyCostUSD.Set(5.25)
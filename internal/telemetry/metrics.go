@@ -29,28 +29,34 @@ var (
 
 	// Consumer metrics
 
-	// JetstreamRecordsProcessed tracks records processed by the consumer
+	// JetstreamRecordsProcessed tracks records processed by the consumer.
+	//
+	// Deprecated: use consumer.EventsTotal.
 	JetstreamRecordsProcessed = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "survey_jetstream_records_processed_total",
-			Help: "Total number of ATProto records processed from Jetstream",
+			Help: "Deprecated: use survey_consumer_events_total. Total number of ATProto records processed from Jetstream",
 		},
 		[]string{"collection", "operation", "status"}, // status: "success" or "error"
 	)
 
-	// JetstreamCursorLag tracks time since last processed event
+	// JetstreamCursorLag tracks time since last processed event.
+	//
+	// Deprecated: use consumer.LagSeconds.
 	JetstreamCursorLag = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "survey_jetstream_cursor_lag_seconds",
-			Help: "Seconds since the last processed Jetstream event (0 = real-time)",
+			Help: "Deprecated: use survey_consumer_lag_seconds. Seconds since the last processed Jetstream event (0 = real-time)",
 		},
 	)
 
-	// JetstreamProcessingDuration tracks time to process each message
+	// JetstreamProcessingDuration tracks time to process each message.
+	//
+	// Deprecated: use consumer.EventDuration.
 	JetstreamProcessingDuration = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "survey_jetstream_processing_duration_seconds",
-			Help:    "Time to process a Jetstream message",
+			Help:    "Deprecated: use survey_consumer_event_duration_seconds. Time to process a Jetstream message",
 			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1},
 		},
 		[]string{"collection", "operation"},
@@ -72,6 +78,14 @@ var (
 		},
 	)
 
+	// ConsumerReconnectAttempts tracks reconnect attempts made with backoff
+	ConsumerReconnectAttempts = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "survey_consumer_reconnect_attempts_total",
+			Help: "Total number of consumer reconnect attempts (each one waits an exponential backoff delay)",
+		},
+	)
+
 	// Business metrics for ATProto records
 
 	// SurveysIndexed tracks surveys indexed from ATProto
@@ -159,6 +173,29 @@ var (
 		[]string{"type"},
 	)
 
+	// AIInputTokenEstimateRatio tracks the provider-reported input tokens of
+	// a generation divided by the estimate made before calling it, to see
+	// how far the estimate drifts
+	// Labels: provider (openai, anthropic)
+	AIInputTokenEstimateRatio = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "survey_ai_input_token_estimate_ratio",
+			Help:    "Provider-reported AI input tokens divided by the pre-flight estimate",
+			Buckets: []float64{0.5, 0.75, 0.9, 0.95, 1, 1.05, 1.1, 1.25, 1.5, 2},
+		},
+		[]string{"provider"},
+	)
+
+	// AIOutputValidationTotal tracks how generated surveys passed validation
+	// Labels: outcome (valid, repaired, reprompted, invalid)
+	AIOutputValidationTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "survey_ai_output_validation_total",
+			Help: "Total number of AI generation outputs by validation outcome",
+		},
+		[]string{"outcome"},
+	)
+
 	// AIDailyCostUSD tracks daily cost in USD
 	AIDailyCostUSD = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -176,6 +213,34 @@ var (
 		},
 		[]string{"user_type"},
 	)
+
+	// OAuth metrics
+
+	// OAuthSessionsDeleted tracks sessions removed by the OAuth cleanup worker
+	OAuthSessionsDeleted = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "survey_oauth_sessions_deleted_total",
+			Help: "Total number of idle or dead OAuth sessions deleted by cleanup",
+		},
+	)
+
+	// OAuthDPoPNonceRetries tracks token refresh and revocation requests
+	// retried because the authorization server demanded a new DPoP nonce
+	OAuthDPoPNonceRetries = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "survey_oauth_dpop_nonce_retries_total",
+			Help: "Total number of token refresh and revocation requests retried with a server-provided DPoP nonce",
+		},
+	)
+
+	// OAuthRevocationFailures tracks logouts whose refresh token could not be
+	// revoked at the authorization server
+	OAuthRevocationFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "survey_oauth_revocation_failures_total",
+			Help: "Total number of logouts where revoking the refresh token upstream failed",
+		},
+	)
 )
 
 // RegisterMetrics registers all Prometheus metrics
@@ -0,0 +1,123 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderBarChart(t *testing.T, label string, bars []ChartBar, total int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, BarChart(label, bars, total).Render(context.Background(), &buf))
+	return buf.String()
+}
+
+func TestBarChart(t *testing.T) {
+	bars := []ChartBar{{Label: "Tacos", Count: 3}, {Label: "Sushi", Count: 1}}
+
+	t.Run("draws a bar per option with its share", func(t *testing.T) {
+		html := renderBarChart(t, "Where?", bars, 4)
+		assert.Contains(t, html, "<svg")
+		assert.Equal(t, 2, strings.Count(html, "<g>"))
+		assert.Contains(t, html, "3 votes (75.0%)")
+		assert.Contains(t, html, `width="360.0"`, "75% of the longest bar")
+		assert.Contains(t, html, `width="120.0"`)
+		assert.NotContains(t, html, "<script", "no JavaScript")
+	})
+
+	t.Run("is accessible", func(t *testing.T) {
+		html := renderBarChart(t, "Where?", bars, 4)
+		assert.Contains(t, html, `role="img"`)
+		assert.Contains(t, html, `aria-label="Where?. Tacos: 3 votes (75.0%); Sushi: 1 votes (25.0%)"`)
+		assert.Contains(t, html, "<title>Where?. Tacos: 3 votes (75.0%); Sushi: 1 votes (25.0%)</title>")
+		assert.Contains(t, html, "<title>Tacos: 3 votes (75.0%)</title>")
+	})
+
+	t.Run("colors bars by position", func(t *testing.T) {
+		many := make([]ChartBar, len(chartColors)+1)
+		for i := range many {
+			many[i] = ChartBar{Label: fmt.Sprintf("Option %d", i), Count: 1}
+		}
+		html := renderBarChart(t, "Pick", many, 9)
+		assert.Equal(t, html, renderBarChart(t, "Pick", many, 9))
+		assert.Equal(t, 2, strings.Count(html, `fill="`+chartColors[0]+`"`), "the ninth bar wraps around")
+	})
+
+	t.Run("no responses", func(t *testing.T) {
+		html := renderBarChart(t, "Where?", []ChartBar{{Label: "Tacos"}}, 0)
+		assert.Contains(t, html, "No responses yet")
+		assert.NotContains(t, html, "<svg")
+	})
+
+	t.Run("an option with every vote", func(t *testing.T) {
+		html := renderBarChart(t, "Where?", []ChartBar{{Label: "Tacos", Count: 5}, {Label: "Sushi"}}, 5)
+		assert.Contains(t, html, "5 votes (100.0%)")
+		assert.Contains(t, html, `width="480.0"`)
+		assert.Contains(t, html, "0 votes (0.0%)")
+	})
+
+	t.Run("scrolls more than 20 options", func(t *testing.T) {
+		many := make([]ChartBar, 21)
+		for i := range many {
+			many[i] = ChartBar{Label: fmt.Sprintf("Option %d", i), Count: i}
+		}
+		assert.Contains(t, renderBarChart(t, "Pick", many, 210), `class="bar-chart-scroll"`)
+		assert.NotContains(t, renderBarChart(t, "Pick", many[:20], 190), "bar-chart-scroll")
+	})
+
+	t.Run("escapes and shortens labels", func(t *testing.T) {
+		long := strings.Repeat("word ", 30)
+		html := renderBarChart(t, `<b>"Bold"</b>`, []ChartBar{{Label: "<script>alert(1)</script>", Count: 1}, {Label: long, Count: 1}}, 2)
+		assert.NotContains(t, html, "<script>")
+		assert.NotContains(t, html, "<b>")
+		assert.Contains(t, html, "…</text>")
+	})
+}
+
+func TestResultsPartial_ChoiceChart(t *testing.T) {
+	survey := &models.Survey{Definition: models.SurveyDefinition{Questions: []models.Question{
+		{ID: "q1", Text: "Where?", Type: models.QuestionTypeMulti, Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}}},
+	}}}
+	results := &models.SurveyResults{
+		TotalVotes: 2,
+		QuestionResults: map[string]*models.QuestionResult{
+			"q1": {QuestionID: "q1", OptionCounts: map[string]int{"a": 2, "b": 1}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, ResultsPartial(survey, results, false).Render(context.Background(), &buf))
+	html := buf.String()
+	assert.Contains(t, html, `aria-label="Where?. Tacos: 2 votes (100.0%); Sushi: 1 votes (50.0%)"`)
+}
+
+// TestResultsPartial_RemovedQuestions notes answers kept from questions an
+// edit removed
+func TestResultsPartial_RemovedQuestions(t *testing.T) {
+	survey := &models.Survey{DefinitionVersion: 2, Definition: models.SurveyDefinition{Questions: []models.Question{
+		{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}}},
+	}}}
+	results := &models.SurveyResults{
+		TotalVotes:        3,
+		QuestionResults:   map[string]*models.QuestionResult{"q1": {QuestionID: "q1", OptionCounts: map[string]int{"a": 3}}},
+		DefinitionVersion: 2,
+		RemovedQuestions:  map[string]int{"q3": 1, "q2": 3},
+	}
+
+	html := renderIn(t, context.Background(), ResultsPartial(survey, results, false))
+	assert.Contains(t, html, "Answers to the questions it removed are kept")
+	assert.Contains(t, html, "<li>Question q2: 3 responses</li><li>Question q3: 1 response</li>")
+
+	html = renderIn(t, spanish, ResultsPartial(survey, results, false))
+	assert.Contains(t, html, "<li>Pregunta q2: 3 respuestas</li>")
+
+	results.RemovedQuestions = nil
+	assert.NotContains(t, renderIn(t, context.Background(), ResultsPartial(survey, results, false)), "removed-questions")
+}
@@ -0,0 +1,115 @@
+package templates
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrowseFilter_URL(t *testing.T) {
+	assert.Equal(t, "/surveys", BrowseFilter{}.URL(""))
+	assert.Equal(t, "/surveys", BrowseFilter{Sort: BrowseSortNewest}.URL(""), "the default order is left out")
+	assert.Equal(t, "/surveys?cursor=abc", BrowseFilter{}.URL("abc"))
+	assert.Equal(t, "/surveys?cursor=abc&lang=pt-BR&sort=responses&status=open",
+		BrowseFilter{Status: models.SurveyStatusOpen, Lang: "pt-BR", Sort: BrowseSortResponses}.URL("abc"))
+}
+
+func TestBrowseOGMeta(t *testing.T) {
+	og := browseOGMeta(context.Background(), BrowseFilter{}, "")
+	assert.Equal(t, "Browse Surveys - OpenMeet Survey", og.Title)
+	assert.GreaterOrEqual(t, len(og.Description), 110)
+	assert.False(t, og.NoIndex, "the unfiltered first page may be indexed")
+
+	assert.True(t, browseOGMeta(context.Background(), BrowseFilter{Status: models.SurveyStatusOpen}, "").NoIndex)
+	assert.True(t, browseOGMeta(context.Background(), BrowseFilter{Sort: BrowseSortResponses}, "").NoIndex)
+	assert.True(t, browseOGMeta(context.Background(), BrowseFilter{}, "abc").NoIndex)
+}
+
+func TestBrowsePage(t *testing.T) {
+	t.Cleanup(func() { SetNoIndex(true) })
+	starts := time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC)
+	description := "Where should we eat?"
+	surveys := []BrowseSurvey{
+		{
+			Survey: &models.Survey{Slug: "lunch", Title: `Lunch "today"`, Description: &description, Status: models.SurveyStatusOpen,
+				ResponseCount: 2, CreatedAt: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+			AuthorHandle: "alice.bsky.social",
+		},
+		{
+			Survey: &models.Survey{Slug: "retro", Title: "Retro", Status: models.SurveyStatusScheduled, StartsAt: &starts,
+				ResponseCount: 1, CreatedAt: time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)},
+		},
+	}
+	filter := BrowseFilter{Status: models.SurveyStatusOpen, Lang: "es", Sort: BrowseSortResponses}
+
+	html := renderIn(t, context.Background(), BrowsePage(filter, "", surveys, "next-page", nil, nil, ""))
+	assert.Contains(t, html, `<a href="/surveys/lunch" style="color: #3498db;">Lunch &#34;today&#34;</a>`)
+	assert.Contains(t, html, "Where should we eat?")
+	assert.Contains(t, html, "by @alice.bsky.social · 2 responses · March 1, 2025")
+	assert.Contains(t, html, "1 response · February 1, 2025")
+	assert.Contains(t, html, ">Opens April 1, 2025</span>")
+	assert.NotContains(t, html, "No surveys match")
+
+	// The form shows the filters in effect
+	assert.Contains(t, html, `<option value="open" selected>`)
+	assert.Contains(t, html, `name="lang" value="es"`)
+	assert.Contains(t, html, `<option value="responses" selected>`)
+
+	// Load more keeps the filters
+	next := `/surveys?cursor=next-page&amp;lang=es&amp;sort=responses&amp;status=open`
+	assert.Contains(t, html, `href="`+next+`"`)
+	assert.Contains(t, html, `hx-get="`+next+`"`)
+	assert.Contains(t, html, `hx-target="closest .browse-more"`)
+
+	t.Run("nothing is indexed by default", func(t *testing.T) {
+		html := renderIn(t, context.Background(), BrowsePage(BrowseFilter{}, "", surveys, "", nil, nil, ""))
+		assert.Contains(t, html, `<meta name="robots" content="noindex, nofollow">`)
+	})
+
+	t.Run("only the unfiltered first page is indexed", func(t *testing.T) {
+		SetNoIndex(false)
+		html := renderIn(t, context.Background(), BrowsePage(BrowseFilter{}, "", surveys, "", nil, nil, ""))
+		assert.False(t, strings.Contains(html, `name="robots"`))
+		assert.Contains(t, html, `<meta name="twitter:card" content="summary">`)
+
+		html = renderIn(t, context.Background(), BrowsePage(filter, "", surveys, "", nil, nil, ""))
+		assert.Contains(t, html, `<meta name="robots" content="noindex">`)
+		html = renderIn(t, context.Background(), BrowsePage(BrowseFilter{}, "next-page", surveys, "", nil, nil, ""))
+		assert.Contains(t, html, `<meta name="robots" content="noindex">`)
+	})
+
+	t.Run("in Spanish", func(t *testing.T) {
+		html := renderIn(t, spanish, BrowsePage(filter, "", surveys, "next-page", nil, nil, ""))
+		assert.Contains(t, html, "<h1 style=\"margin-bottom: 1.5rem;\">Explorar encuestas</h1>")
+		assert.Contains(t, html, "por @alice.bsky.social · 2 respuestas · 1 de marzo de 2025")
+		assert.Contains(t, html, "1 respuesta · 1 de febrero de 2025")
+		assert.Contains(t, html, ">Abre el 1 de abril de 2025</span>")
+		assert.Contains(t, html, `placeholder="Cualquier idioma"`)
+		assert.Contains(t, html, ">Cargar más</a>")
+		assert.NotContains(t, html, "Browse Surveys")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		html := renderIn(t, context.Background(), BrowsePage(filter, "", nil, "", nil, nil, ""))
+		assert.Contains(t, html, "No surveys match these filters.")
+		assert.NotContains(t, html, "Load more")
+	})
+}
+
+// TestBrowseResults ensures a Load more response is just the list
+func TestBrowseResults(t *testing.T) {
+	surveys := []BrowseSurvey{{Survey: &models.Survey{Slug: "lunch", Title: "Lunch"}}}
+
+	html := renderIn(t, context.Background(), BrowseResults(BrowseFilter{}, surveys, "after-lunch"))
+	assert.False(t, strings.Contains(html, "<html"))
+	assert.False(t, strings.Contains(html, "<form"))
+	assert.Contains(t, html, `href="/surveys/lunch"`)
+	assert.Contains(t, html, `hx-get="/surveys?cursor=after-lunch"`)
+
+	html = renderIn(t, context.Background(), BrowseResults(BrowseFilter{}, surveys, ""))
+	assert.NotContains(t, html, "Load more", "last page")
+}
@@ -1,5 +1,7 @@
 package templates
 
+import "strings"
+
 // NoIndex controls whether search engines should index pages.
 // Default is true (block indexing). Set to false in production to allow indexing.
 var NoIndex = true
@@ -9,3 +11,31 @@ var NoIndex = true
 func SetNoIndex(val bool) {
 	NoIndex = val
 }
+
+// TwitterSite is the site's X account, such as @openmeet, named in Twitter
+// cards as twitter:site. Empty leaves it out.
+var TwitterSite = ""
+
+// SetTwitterSite sets the site's X account, with or without its @.
+// Call this at startup based on environment configuration.
+func SetTwitterSite(handle string) {
+	handle = strings.TrimPrefix(strings.TrimSpace(handle), "@")
+	if handle == "" {
+		TwitterSite = ""
+		return
+	}
+	TwitterSite = "@" + handle
+}
+
+// SiteHost is the public hostname the site is served on, such as
+// survey.openmeet.net. Empty hides what needs it, like survey QR codes.
+var SiteHost = ""
+
+// SetSiteHost sets the site's public hostname; like SERVER_HOST, it may be
+// given as an https:// URL.
+// Call this at startup based on environment configuration.
+func SetSiteHost(host string) {
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	SiteHost = strings.TrimSuffix(host, "/")
+}
@@ -99,6 +99,14 @@ func TestCreateSurvey_ContainsGenerateHandlerScript(t *testing.T) {
 
 	// Check for consent validation
 	assert.Contains(t, html, "ai-consent", "Should check consent before generating")
+
+	// Check for streamed progress, with the non-streaming endpoint as fallback
+	assert.Contains(t, html, "/api/v1/surveys/generate/stream", "Should start a streamed generation")
+	assert.Contains(t, html, "EventSource", "Should follow progress with server-sent events")
+	assert.Contains(t, html, "id=\"ai-loading-text\"", "Should have a progress message")
+
+	// Check for budget reset information
+	assert.Contains(t, html, "resets_at", "Should say when a spent budget resets")
 }
 
 // TestCreateSurvey_TemplateMode ensures template mode shows correct UI
@@ -115,7 +123,7 @@ func TestCreateSurvey_TemplateMode(t *testing.T) {
 	// Check for template-specific UI elements
 	assert.Contains(t, html, "Build on Existing Survey", "Should have template mode header")
 	assert.Contains(t, html, "Modify with AI", "Should have modify section heading")
-	assert.Contains(t, html, "Describe what you'd like to change", "Should have template-specific label")
+	assert.Contains(t, html, "Describe what you&#39;d like to change", "Should have template-specific label")
 	assert.Contains(t, html, "Modify Survey", "Should have modify button text")
 	assert.Contains(t, html, "Skip to Editor", "Should have simplified skip button text")
 
@@ -0,0 +1,147 @@
+package templates
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Each locale file is a flat JSON object of message keys to fmt format
+// strings. A message with a count has two keys, ending in ".one" and
+// ".other"; see TN.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// DefaultLocale is the language of pages when a visitor's can't be matched.
+// Its catalog has every message; one missing from another locale's is shown
+// in this language rather than left empty.
+var DefaultLocale = language.English
+
+// Locales are the languages pages can be shown in, DefaultLocale first
+var Locales = []language.Tag{language.English, language.Spanish}
+
+// LocaleCookie holds the language a visitor picked, which takes precedence
+// over their browser's Accept-Language
+const LocaleCookie = "lang"
+
+var (
+	localeMatcher = language.NewMatcher(Locales)
+	catalogs      = loadCatalogs()
+
+	// printers format numbers in each locale's style, e.g. "1.234,5" in Spanish
+	printers = newPrinters()
+)
+
+type localeKey struct{}
+
+func loadCatalogs() map[language.Tag]map[string]string {
+	catalogs := make(map[language.Tag]map[string]string, len(Locales))
+	for _, tag := range Locales {
+		data, err := localeFiles.ReadFile("locales/" + tag.String() + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("templates: locale %s: %v", tag, err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("templates: locale %s: %v", tag, err))
+		}
+		catalogs[tag] = messages
+	}
+	return catalogs
+}
+
+func newPrinters() map[language.Tag]*message.Printer {
+	printers := make(map[language.Tag]*message.Printer, len(Locales))
+	for _, tag := range Locales {
+		printers[tag] = message.NewPrinter(tag)
+	}
+	return printers
+}
+
+// SetLocale returns a context whose pages render in the supported locale
+// closest to tag
+func SetLocale(ctx context.Context, tag language.Tag) context.Context {
+	_, i, _ := localeMatcher.Match(tag)
+	return context.WithValue(ctx, localeKey{}, Locales[i])
+}
+
+// Locale returns the locale set on ctx, or DefaultLocale
+func Locale(ctx context.Context) language.Tag {
+	if tag, ok := ctx.Value(localeKey{}).(language.Tag); ok {
+		return tag
+	}
+	return DefaultLocale
+}
+
+// MatchLocale picks the locale for a request: the LocaleCookie value if it
+// names a supported locale, otherwise the best match for the Accept-Language
+// header, otherwise DefaultLocale
+func MatchLocale(cookie, acceptLanguage string) language.Tag {
+	if tag, err := language.Parse(cookie); err == nil {
+		if _, i, confidence := localeMatcher.Match(tag); confidence != language.No {
+			return Locales[i]
+		}
+	}
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, i, _ := localeMatcher.Match(tags...)
+	return Locales[i]
+}
+
+// localeName is tag's name in its own language, e.g. "Español"
+func localeName(tag language.Tag) string {
+	return lookup(tag, "locale.name")
+}
+
+// T looks up key in the locale set on ctx and formats it with args
+func T(ctx context.Context, key string, args ...any) string {
+	tag := Locale(ctx)
+	return printers[tag].Sprintf(lookup(tag, key), args...)
+}
+
+// lookup returns key's format in tag's catalog, falling back to
+// DefaultLocale's, then to the key itself so a missing message shows which
+// one it is rather than nothing
+func lookup(tag language.Tag, key string) string {
+	if format, ok := catalogs[tag][key]; ok {
+		return format
+	}
+	if format, ok := catalogs[DefaultLocale][key]; ok {
+		return format
+	}
+	return key
+}
+
+// TN is T for a message about n things, choosing its key's ".one" form
+// when n is 1 and its ".other" form otherwise, which is how both English
+// and Spanish make plurals. n is only used to choose; pass it in args too.
+func TN(ctx context.Context, key string, n int, args ...any) string {
+	if n == 1 {
+		return T(ctx, key+".one", args...)
+	}
+	return T(ctx, key+".other", args...)
+}
+
+// formatDate formats t's date in the locale set on ctx, e.g. "March 8, 2025"
+func formatDate(ctx context.Context, t time.Time) string {
+	t = t.UTC()
+	month := T(ctx, "date.month."+strconv.Itoa(int(t.Month())))
+	// The year is a string so it isn't formatted as a number ("2,025")
+	return T(ctx, "date.long", month, t.Day(), strconv.Itoa(t.Year()))
+}
+
+// scriptMessages returns the formats of keys in the locale set on ctx, for
+// a page's script to fill in. Scripts only fill %d and %s, in order.
+func scriptMessages(ctx context.Context, keys ...string) map[string]string {
+	messages := make(map[string]string, len(keys))
+	for _, key := range keys {
+		messages[key] = lookup(Locale(ctx), key)
+	}
+	return messages
+}
@@ -0,0 +1,203 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+var spanish = SetLocale(context.Background(), language.Spanish)
+
+func renderIn(t *testing.T, ctx context.Context, component templ.Component) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, component.Render(ctx, &buf))
+	return buf.String()
+}
+
+func TestT(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "Page 2 of 3", T(ctx, "form.pageOf", 2, 3))
+	assert.Equal(t, "Página 2 de 3", T(spanish, "form.pageOf", 2, 3))
+
+	// Numbers are formatted the locale's way
+	assert.Equal(t, "1,234 votes (12.5%)", T(ctx, "results.optionStats", 1234, 12.5))
+	assert.Equal(t, "1.234 votos (12,5%)", T(spanish, "results.optionStats", 1234, 12.5))
+
+	t.Run("missing translation falls back to English", func(t *testing.T) {
+		es := catalogs[language.Spanish]
+		format := es["form.pageOf"]
+		delete(es, "form.pageOf")
+		t.Cleanup(func() { es["form.pageOf"] = format })
+
+		assert.Equal(t, "Page 2 of 3", T(spanish, "form.pageOf", 2, 3))
+	})
+
+	t.Run("unknown key shows the key", func(t *testing.T) {
+		assert.Equal(t, "no.such.message", T(spanish, "no.such.message"))
+	})
+}
+
+func TestTN(t *testing.T) {
+	assert.Equal(t, "1 text response", TN(context.Background(), "results.textResponses", 1, 1))
+	assert.Equal(t, "0 text responses", TN(context.Background(), "results.textResponses", 0, 0))
+	assert.Equal(t, "1 respuesta de texto", TN(spanish, "results.textResponses", 1, 1))
+	assert.Equal(t, "3 respuestas de texto", TN(spanish, "results.textResponses", 3, 3))
+}
+
+func TestLocale(t *testing.T) {
+	assert.Equal(t, language.English, Locale(context.Background()))
+	assert.Equal(t, language.Spanish, Locale(spanish))
+	assert.Equal(t, language.Spanish, Locale(SetLocale(context.Background(), language.MustParse("es-MX"))))
+	assert.Equal(t, language.English, Locale(SetLocale(context.Background(), language.French)))
+}
+
+func TestMatchLocale(t *testing.T) {
+	assert.Equal(t, language.English, MatchLocale("", ""))
+	assert.Equal(t, language.Spanish, MatchLocale("", "es-AR,es;q=0.9"))
+	assert.Equal(t, language.Spanish, MatchLocale("", "fr;q=0.9,es;q=0.5"), "first supported language")
+	assert.Equal(t, language.English, MatchLocale("", "fr,de"))
+	assert.Equal(t, language.English, MatchLocale("en", "es"), "cookie wins")
+	assert.Equal(t, language.Spanish, MatchLocale("es", "en-US"))
+	assert.Equal(t, language.Spanish, MatchLocale("fr", "es"), "unsupported cookie is ignored")
+	assert.Equal(t, language.Spanish, MatchLocale("not a tag!", "es"))
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2025, 3, 8, 23, 30, 0, 0, time.UTC)
+	assert.Equal(t, "March 8, 2025", formatDate(context.Background(), date))
+	assert.Equal(t, "8 de marzo de 2025", formatDate(spanish, date))
+	assert.Equal(t, "hace 2 días", formatRelativeTime(spanish, date, date.Add(50*time.Hour)))
+}
+
+// TestCatalogs ensures every translation is of an English message and takes
+// the same arguments
+func TestCatalogs(t *testing.T) {
+	verb := regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+	verbs := func(format string) []string {
+		found := verb.FindAllString(format, -1)
+		for i, v := range found {
+			found[i] = regexp.MustCompile(`\[\d+\]`).ReplaceAllString(v, "")
+		}
+		sort.Strings(found)
+		return found
+	}
+
+	english := catalogs[DefaultLocale]
+	for _, tag := range Locales {
+		assert.NotEmpty(t, catalogs[tag]["locale.name"], tag.String())
+		for key, format := range catalogs[tag] {
+			require.Contains(t, english, key, "%s has a message English doesn't", tag)
+			assert.Equal(t, verbs(english[key]), verbs(format), "%s: %s", tag, key)
+		}
+	}
+}
+
+func TestSurveyForm_Locales(t *testing.T) {
+	survey := pagingTestSurvey(2)
+	survey.Definition.Questions[4] = models.Question{
+		ID: "q5", Text: "Toppings?", Type: models.QuestionTypeMulti, MaxSelections: 2,
+		Options: []models.Option{{ID: "a", Text: "Cheese"}, {ID: "b", Text: "Ham"}, {ID: "c", Text: "Other", AllowFreeText: true}},
+	}
+	component := SurveyForm(survey, models.SurveyStatusOpen, nil, nil, nil, "", nil, nil)
+
+	html := renderIn(t, context.Background(), component)
+	assert.Contains(t, html, `<html lang="en">`)
+	assert.Contains(t, html, "Submit Response")
+	assert.Contains(t, html, `aria-label="Page 1 of 3"`)
+	assert.Contains(t, html, "Select up to 2 options")
+	assert.Contains(t, html, `placeholder="Your answer..."`)
+	assert.Contains(t, html, "View Results →")
+
+	html = renderIn(t, spanish, component)
+	assert.Contains(t, html, `<html lang="es">`)
+	assert.Contains(t, html, "Enviar respuesta")
+	assert.Contains(t, html, `aria-label="Página 1 de 3"`)
+	assert.Contains(t, html, "0 de 2 preguntas obligatorias respondidas")
+	assert.Contains(t, html, "Selecciona hasta 2 opciones")
+	assert.Contains(t, html, `placeholder="Tu respuesta..."`)
+	assert.Contains(t, html, `aria-label="Other: especifica"`)
+	assert.Contains(t, html, "Ver resultados →")
+	assert.Contains(t, html, "Crear encuesta", "navigation")
+	assert.False(t, strings.Contains(html, "Submit Response"), "no English left")
+
+	// The paging script gets its messages in the page's language
+	assert.Contains(t, html, `<script id="survey-form-messages" type="application/json">`)
+	assert.Contains(t, html, `"form.selectAtLeastOne":"Selecciona al menos una opción."`)
+
+	t.Run("survey's own language wins for html lang", func(t *testing.T) {
+		lang := "fr"
+		survey := pagingTestSurvey(0)
+		survey.Lang = &lang
+		html := renderIn(t, spanish, SurveyForm(survey, models.SurveyStatusOpen, nil, nil, nil, "", nil, nil))
+		assert.Contains(t, html, `<html lang="fr">`)
+	})
+}
+
+func TestCreateSurvey_Locales(t *testing.T) {
+	html := renderIn(t, context.Background(), CreateSurvey(nil, nil, "", ""))
+	assert.Contains(t, html, "Create New Survey")
+	assert.Contains(t, html, "0 / 2000 characters")
+	assert.Contains(t, html, `"create.charCount":"%d / 2000 characters"`)
+
+	html = renderIn(t, spanish, CreateSurvey(nil, nil, "", ""))
+	assert.Contains(t, html, "<title>Crear encuesta - OpenMeet Survey</title>")
+	assert.Contains(t, html, "Crear nueva encuesta")
+	assert.Contains(t, html, "Generar encuesta con IA")
+	assert.Contains(t, html, "0 / 2000 caracteres")
+	assert.Contains(t, html, `<optgroup label="Club de motos">`)
+	assert.Contains(t, html, `<script id="create-survey-messages" type="application/json">`)
+	assert.Contains(t, html, `"create.charCount":"%d / 2000 caracteres"`)
+	assert.Contains(t, html, `"form.submit":"Enviar respuesta"`)
+	assert.False(t, strings.Contains(html, "Generate Survey"), "no English left")
+}
+
+func TestSurveyResults_Locales(t *testing.T) {
+	survey := &models.Survey{Slug: "lunch", Title: "Lunch order", Definition: models.SurveyDefinition{Questions: []models.Question{
+		{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}, {ID: "b", Text: "Sushi"}}},
+		{ID: "q2", Text: "Why?", Type: models.QuestionTypeText},
+	}}}
+	results := &models.SurveyResults{TotalVotes: 4, QuestionResults: map[string]*models.QuestionResult{
+		"q1": {OptionCounts: map[string]int{"a": 3, "b": 1}},
+	}}
+	published := &models.PublishedResults{
+		PublishedAt:     time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC),
+		TotalVotes:      4,
+		QuestionResults: map[string]*models.PublishedQuestionResult{"q2": {TextResponseCount: 1}},
+	}
+	component := SurveyResults(survey, results, published, nil, nil, nil, "")
+
+	html := renderIn(t, context.Background(), component)
+	assert.Contains(t, html, "<title>Lunch order - Results - OpenMeet Survey</title>")
+	assert.Contains(t, html, "Total Responses:")
+	assert.Contains(t, html, "3 votes (75.0%)")
+	assert.Contains(t, html, "Official results published by the author on March 8, 2025")
+	assert.Contains(t, html, "No responses yet")
+
+	html = renderIn(t, spanish, component)
+	assert.Contains(t, html, "<title>Lunch order - Resultados - OpenMeet Survey</title>")
+	assert.Contains(t, html, "Total de respuestas:")
+	assert.Contains(t, html, "3 votos (75,0%)")
+	assert.Contains(t, html, "Resultados oficiales publicados por el autor el 8 de marzo de 2025")
+	assert.Contains(t, html, "1 respuesta de texto")
+	assert.Contains(t, html, "Aún no hay respuestas")
+	assert.Contains(t, html, "← Volver a la encuesta")
+	assert.False(t, strings.Contains(html, "No responses yet"), "no English left")
+}
+
+func TestLanguageSwitcher(t *testing.T) {
+	html := renderIn(t, spanish, Layout("Home", nil, nil, ""))
+	assert.Contains(t, html, `<a href="/language/en" lang="en" hreflang="en"`)
+	assert.Contains(t, html, `<strong lang="es">Español</strong>`)
+	assert.Contains(t, html, "Política de privacidad")
+}
@@ -0,0 +1,112 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var jsonLDBlock = regexp.MustCompile(`(?s)<script id="survey-json-ld" type="application/ld\+json"[^>]*>(.*?)</script>`)
+
+// renderJSONLD renders a survey's JSON-LD block and decodes it
+func renderJSONLD(t *testing.T, survey *models.Survey, author *oauth.Profile, responseCount *int) (string, map[string]any) {
+	t.Helper()
+	html := renderIn(t, context.Background(), SurveyJSONLD(survey, author, responseCount))
+	match := jsonLDBlock.FindStringSubmatch(html)
+	require.Len(t, match, 2, html)
+
+	var data map[string]any
+	require.NoError(t, json.Unmarshal([]byte(match[1]), &data))
+	return html, data
+}
+
+func TestSurveyJSONLD(t *testing.T) {
+	description := "  Where should we eat?  "
+	lang := "en-GB"
+	survey := &models.Survey{
+		Title:       "Lunch order",
+		Description: &description,
+		Lang:        &lang,
+		CreatedAt:   time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2025, 3, 2, 10, 30, 0, 0, time.UTC),
+	}
+	count := 42
+
+	_, data := renderJSONLD(t, survey, &oauth.Profile{Handle: "alice.bsky.social", DisplayName: "Alice"}, &count)
+	assert.Equal(t, map[string]any{
+		"@context":     "https://schema.org",
+		"@type":        "Question",
+		"name":         "Lunch order",
+		"text":         "Where should we eat?",
+		"inLanguage":   "en-GB",
+		"dateCreated":  "2025-03-01T09:00:00Z",
+		"dateModified": "2025-03-02T10:30:00Z",
+		"author": map[string]any{
+			"@type":         "Person",
+			"name":          "Alice",
+			"alternateName": "@alice.bsky.social",
+			"url":           "https://bsky.app/profile/alice.bsky.social",
+		},
+		"interactionStatistic": map[string]any{
+			"@type":                "InteractionCounter",
+			"interactionType":      "https://schema.org/VoteAction",
+			"userInteractionCount": float64(42),
+		},
+	}, data)
+
+	t.Run("author without a display name", func(t *testing.T) {
+		_, data := renderJSONLD(t, survey, &oauth.Profile{Handle: "bob.bsky.social"}, &count)
+		assert.Equal(t, "@bob.bsky.social", data["author"].(map[string]any)["name"])
+	})
+
+	t.Run("unknown author and count are left out", func(t *testing.T) {
+		_, data := renderJSONLD(t, &models.Survey{Title: "Guest survey"}, nil, nil)
+		assert.NotContains(t, data, "author")
+		assert.NotContains(t, data, "interactionStatistic")
+		assert.NotContains(t, data, "text")
+	})
+
+	t.Run("no response is still a count", func(t *testing.T) {
+		zero := 0
+		_, data := renderJSONLD(t, survey, nil, &zero)
+		assert.Equal(t, float64(0), data["interactionStatistic"].(map[string]any)["userInteractionCount"])
+	})
+}
+
+// TestSurveyJSONLD_ScriptInjection ensures survey text can't end the script
+// element and inject markup
+func TestSurveyJSONLD_ScriptInjection(t *testing.T) {
+	title := `Lunch</script><script>alert("title")</script>`
+	description := `</SCRIPT><img src=x onerror=alert(1)> & <!-- more`
+	survey := &models.Survey{Title: title, Description: &description}
+	author := &oauth.Profile{Handle: "eve.bsky.social", DisplayName: "</script><script>alert('author')</script>"}
+
+	html, data := renderJSONLD(t, survey, author, nil)
+	assert.Equal(t, 1, strings.Count(strings.ToLower(html), "</script"), "only the block's own end tag")
+	assert.Equal(t, 1, strings.Count(html, "<script"), "no injected script")
+	assert.NotContains(t, html, "<img")
+	assert.NotContains(t, html, "<!--")
+
+	// The text survives intact for anyone reading the JSON
+	assert.Equal(t, title, data["name"])
+	assert.Equal(t, description, data["text"])
+	assert.Equal(t, author.DisplayName, data["author"].(map[string]any)["name"])
+}
+
+func TestSurveyForm_JSONLD(t *testing.T) {
+	ctx := SetNonce(context.Background(), testNonce)
+	count := 3
+	html := renderIn(t, ctx, SurveyForm(pagingTestSurvey(0), models.SurveyStatusOpen, nil, nil, nil, "", nil, &count))
+
+	assert.Contains(t, html, `<script id="survey-json-ld" type="application/ld+json" nonce="`+testNonce+`">`)
+	assert.Contains(t, html, `"name":"Long survey"`)
+	assert.Contains(t, html, `"userInteractionCount":3`)
+}
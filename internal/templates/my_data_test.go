@@ -0,0 +1,25 @@
+package templates
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMyDataCollectionPage_ResponseCounts(t *testing.T) {
+	records := []oauth.PDSRecord{
+		{RKey: "lunch", URI: "at://did:plc:alice/net.openmeet.survey/lunch"},
+		{RKey: "new", URI: "at://did:plc:alice/net.openmeet.survey/new"},
+	}
+	user := &oauth.User{DID: "did:plc:alice"}
+
+	html := renderIn(t, context.Background(), MyDataCollectionPage(user, nil, "net.openmeet.survey", records, map[string]int{records[0].URI: 12}, "", ""))
+	assert.Contains(t, html, ">Responses</th>")
+	assert.Contains(t, html, ">12</td>")
+	assert.Contains(t, html, "–</span>", "not indexed yet")
+
+	html = renderIn(t, context.Background(), MyDataCollectionPage(user, nil, "net.openmeet.survey.response", records, nil, "", ""))
+	assert.NotContains(t, html, ">Responses</th>")
+}
@@ -0,0 +1,19 @@
+package templates
+
+import (
+	"context"
+
+	"github.com/a-h/templ"
+)
+
+// SetNonce returns a context whose pages stamp nonce on their inline scripts
+// and styles, so a Content-Security-Policy naming it can allow just those.
+// It's templ's nonce, so JSON scripts get it too.
+func SetNonce(ctx context.Context, nonce string) context.Context {
+	return templ.WithNonce(ctx, nonce)
+}
+
+// Nonce returns the Content-Security-Policy nonce set on ctx, or ""
+func Nonce(ctx context.Context) string {
+	return templ.GetNonce(ctx)
+}
@@ -0,0 +1,79 @@
+package templates
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNonce = "dGVzdC1ub25jZQ"
+
+var (
+	scriptOrStyleTag = regexp.MustCompile(`<(script|style)\b[^>]*>`)
+	eventAttribute   = regexp.MustCompile(`<[a-z]+\s[^>]*\son[a-z]+=`)
+)
+
+// assertNonced fails for any script or style tag without the nonce, and
+// for event handler attributes, which the Content-Security-Policy blocks
+func assertNonced(t *testing.T, html string) {
+	t.Helper()
+	tags := scriptOrStyleTag.FindAllString(html, -1)
+	require.NotEmpty(t, tags)
+	for _, tag := range tags {
+		assert.Contains(t, tag, `nonce="`+testNonce+`"`, "CSP would block %s", tag)
+	}
+	assert.Empty(t, eventAttribute.FindAllString(html, -1), "CSP blocks event handler attributes")
+}
+
+// TestCreateSurvey_Nonce ensures every script on the create page, including
+// the Monaco wiring, runs under the Content-Security-Policy
+func TestCreateSurvey_Nonce(t *testing.T) {
+	ctx := SetNonce(context.Background(), testNonce)
+	assert.Equal(t, testNonce, Nonce(ctx))
+
+	for _, templateJSON := range []string{"", `{"title":"Lunch","questions":[]}`} {
+		html := renderIn(t, ctx, CreateSurvey(nil, nil, "phc_test", templateJSON))
+		assertNonced(t, html)
+		assert.Contains(t, html, `<script id="create-survey-messages" type="application/json" nonce="`+testNonce+`">`)
+		assert.Contains(t, html, `<script type="text/javascript" nonce="`+testNonce+`">posthog.init(`)
+	}
+}
+
+// TestPages_Nonce ensures every page's scripts and styles carry the nonce
+func TestPages_Nonce(t *testing.T) {
+	ctx := SetNonce(context.Background(), testNonce)
+	authorDID := "did:plc:author"
+	survey := pagingTestSurvey(2)
+	survey.AuthorDID = &authorDID
+	survey.Definition.Questions[0].Type = models.QuestionTypeText
+	results := &models.SurveyResults{QuestionResults: map[string]*models.QuestionResult{}}
+	user := &oauth.User{DID: authorDID}
+	profile := &oauth.Profile{Handle: "author.bsky.social"}
+	record := &oauth.PDSRecord{RKey: "abc", URI: "at://did:plc:author/net.openmeet.survey/abc"}
+
+	pages := map[string]templ.Component{
+		"landing":       LandingPage(&models.Stats{}, nil, nil, "", "phc_test"),
+		"survey":        SurveyForm(survey, models.SurveyStatusOpen, nil, user, profile, "phc_test", nil, nil),
+		"results":       SurveyResults(survey, results, nil, nil, user, profile, "phc_test"),
+		"embed":         SurveyEmbed(survey, models.SurveyStatusOpen, "phc_test"),
+		"search":        SearchPage("lunch", nil, nil, nil, ""),
+		"browse":        BrowsePage(BrowseFilter{}, "", []BrowseSurvey{{Survey: survey}}, "next", nil, nil, ""),
+		"my data":       MyDataPage(user, profile, []oauth.SessionInfo{{ID: "s1", CreatedAt: time.Now(), LastUsedAt: time.Now()}}, "s1", ""),
+		"my collection": MyDataCollectionPage(user, profile, "net.openmeet.survey", []oauth.PDSRecord{*record}, map[string]int{record.URI: 3}, "", ""),
+		"my record":     MyDataRecordPage(user, profile, "net.openmeet.survey", record, ""),
+		"privacy":       PrivacyPage(nil, nil, ""),
+		"terms":         TermsPage(nil, nil, ""),
+	}
+	for name, page := range pages {
+		t.Run(name, func(t *testing.T) {
+			assertNonced(t, renderIn(t, ctx, page))
+		})
+	}
+}
@@ -1,5 +1,15 @@
 package templates
 
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"golang.org/x/text/language"
+)
+
 // OGMeta holds Open Graph metadata for social sharing
 type OGMeta struct {
 	Title       string // og:title - defaults to page title if empty
@@ -7,10 +17,106 @@ type OGMeta struct {
 	URL         string // og:url - canonical URL
 	Image       string // og:image - defaults to /static/og-image.png if empty
 	Type        string // og:type - defaults to "website" if empty
+	Lang        string // <html lang> and og:locale - BCP-47, defaults to "en" if empty
+	NoIndex     bool   // robots noindex for this page even when NoIndex is off, e.g. an unlisted survey
+
+	Twitter *TwitterMeta // twitter: card tags - derived from the fields above if nil
+}
+
+// TwitterMeta holds the Twitter/X card metadata X reads instead of Open Graph
+type TwitterMeta struct {
+	Card        string // twitter:card - defaults to "summary_large_image" with an image, "summary" without
+	Site        string // twitter:site - the site's @handle, defaults to TwitterSite
+	Title       string // twitter:title
+	Description string // twitter:description
+	Image       string // twitter:image
+}
+
+// twitterMeta returns the Twitter card for a page's Open Graph data: og's
+// own if it has one, or else one with the same title, description and
+// image. Only an image the page sets itself makes a large card; the
+// default image is the site's logo. A nil og gets the defaults.
+func twitterMeta(og *OGMeta) *TwitterMeta {
+	if og == nil {
+		og = &OGMeta{}
+	}
+	tw := TwitterMeta{Title: og.Title, Description: og.Description, Image: og.Image}
+	if og.Twitter != nil {
+		tw = *og.Twitter
+	}
+	if tw.Site == "" {
+		tw.Site = TwitterSite
+	}
+	if tw.Card == "" {
+		tw.Card = "summary"
+		if tw.Image != "" {
+			tw.Card = "summary_large_image"
+		}
+	}
+	if tw.Image == "" {
+		tw.Image = DefaultOGImage
+	}
+	tw.Image = absoluteURL(tw.Image)
+	return &tw
+}
+
+// ogImage returns a page's og:image: its own image, or else DefaultOGImage,
+// as an absolute URL
+func ogImage(og *OGMeta) string {
+	if og == nil || og.Image == "" {
+		return absoluteURL(DefaultOGImage)
+	}
+	return absoluteURL(og.Image)
+}
+
+// absoluteURL returns path on the site's host, since link previews can't
+// resolve relative image URLs. Paths are left as they are without a
+// SiteHost, and full URLs always are.
+func absoluteURL(path string) string {
+	if SiteHost == "" || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return path
+	}
+	return "https://" + SiteHost + path
 }
 
 // DefaultOGImage is the default Open Graph image path
 const DefaultOGImage = "/static/og-image.png"
 
+// surveyOGImage returns the URL of a survey's own Open Graph image. The
+// version in the query string makes link previews fetch it again after an
+// edit. The image handler redirects to DefaultOGImage when it can't render
+// one.
+func surveyOGImage(survey *models.Survey) string {
+	if survey.Slug == "" {
+		return DefaultOGImage
+	}
+	return fmt.Sprintf("/surveys/%s/og.png?v=%d", url.PathEscape(survey.Slug), survey.Version)
+}
+
 // DefaultOGType is the default Open Graph type
 const DefaultOGType = "website"
+
+// htmlLang returns the page's <html lang> value: the content's declared
+// language, or else the language the page is shown in
+func htmlLang(ctx context.Context, og *OGMeta) string {
+	if og == nil || og.Lang == "" {
+		return Locale(ctx).String()
+	}
+	return og.Lang
+}
+
+// ogLocale converts a BCP-47 tag to the language_TERRITORY form og:locale
+// expects ("es-MX" -> "es_MX"), guessing the territory when the tag has none
+// ("ja" -> "ja_JP"). Returns "" if the tag can't be parsed.
+func ogLocale(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil || tag == language.Und {
+		return ""
+	}
+	base, _ := tag.Base()
+	region, confidence := tag.Region()
+	if confidence == language.No {
+		return base.String()
+	}
+	return base.String() + "_" + region.String()
+}
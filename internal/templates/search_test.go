@@ -0,0 +1,26 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchHelpers(t *testing.T) {
+	assert.Equal(t, "Search Surveys", searchPageTitle("  "))
+	assert.Equal(t, "Search: coffee", searchPageTitle(" coffee "))
+
+	assert.Equal(t, "1 response", formatResponseCount(1))
+	assert.Equal(t, "0 responses", formatResponseCount(0))
+	assert.Equal(t, "12 responses", formatResponseCount(12))
+
+	short := "A short description"
+	assert.Equal(t, short, searchSnippet(short))
+
+	long := strings.Repeat("ü", searchSnippetLength+10)
+	snippet := searchSnippet(long)
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+	assert.Equal(t, searchSnippetLength+1, utf8.RuneCountInString(snippet))
+}
@@ -0,0 +1,46 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestShareLinks_QRCode ensures the share section previews the QR code and
+// links a print-sized download
+func TestShareLinks_QRCode(t *testing.T) {
+	render := func(t *testing.T) string {
+		var buf bytes.Buffer
+		require.NoError(t, ShareLinks(&models.Survey{Slug: "lunch", Title: "Lunch order"}).Render(context.Background(), &buf))
+		return buf.String()
+	}
+
+	t.Run("with a site host", func(t *testing.T) {
+		SetSiteHost("survey.example.com")
+		t.Cleanup(func() { SetSiteHost("") })
+		html := render(t)
+
+		assert.Contains(t, html, `src="/surveys/lunch/qr.png?size=128"`)
+		assert.Contains(t, html, `alt="QR code for Lunch order"`)
+		assert.Contains(t, html, `href="/surveys/lunch/qr.png?size=1024" download="lunch-qr.png"`)
+	})
+
+	t.Run("hidden without a site host, which QR codes need", func(t *testing.T) {
+		html := render(t)
+
+		assert.NotContains(t, html, "qr.png")
+		assert.NotContains(t, html, "Download QR code")
+	})
+}
+
+func TestSetSiteHost(t *testing.T) {
+	t.Cleanup(func() { SetSiteHost("") })
+	for _, host := range []string{"survey.example.com", "https://survey.example.com/", "http://survey.example.com"} {
+		SetSiteHost(host)
+		assert.Equal(t, "survey.example.com", SiteHost, host)
+	}
+}
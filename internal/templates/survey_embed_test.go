@@ -0,0 +1,121 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/openmeet-team/survey/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func embedTestSurvey() *models.Survey {
+	return &models.Survey{
+		Slug:  "lunch",
+		Title: "Lunch order",
+		Definition: models.SurveyDefinition{Questions: []models.Question{
+			{ID: "q1", Text: "Where?", Type: models.QuestionTypeSingle, Options: []models.Option{{ID: "a", Text: "Tacos"}}},
+			{ID: "q2", Text: "Anything else?", Type: models.QuestionTypeText},
+		}},
+	}
+}
+
+func renderEmbed(t *testing.T, survey *models.Survey, posthogKey string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, SurveyEmbed(survey, models.SurveyStatusOpen, posthogKey).Render(context.Background(), &buf))
+	return buf.String()
+}
+
+// TestSurveyEmbed_RendersWithoutError ensures the template compiles and renders
+func TestSurveyEmbed_RendersWithoutError(t *testing.T) {
+	html := renderEmbed(t, embedTestSurvey(), "")
+
+	assert.Contains(t, html, "<!doctype html>")
+	assert.Contains(t, html, "Lunch order")
+	assert.Contains(t, html, "Where?")
+	assert.Contains(t, html, "Anything else?")
+}
+
+// TestSurveyEmbed_NoSiteChrome ensures the embed is just the survey
+func TestSurveyEmbed_NoSiteChrome(t *testing.T) {
+	html := renderEmbed(t, embedTestSurvey(), "")
+
+	assert.NotContains(t, html, "<nav")
+	assert.NotContains(t, html, "<footer")
+	assert.NotContains(t, html, "Share this survey")
+	assert.Contains(t, html, `content="noindex, nofollow"`, "embeds are never indexed, the survey page is")
+}
+
+// TestSurveyEmbed_PostHog ensures analytics only load when the handler passes a key
+func TestSurveyEmbed_PostHog(t *testing.T) {
+	assert.NotContains(t, renderEmbed(t, embedTestSurvey(), ""), "posthog")
+	assert.Contains(t, renderEmbed(t, embedTestSurvey(), "phc_test"), "posthog.init('phc_test'")
+}
+
+// TestSurveyEmbed_Form ensures guest-answerable surveys submit inside the frame
+func TestSurveyEmbed_Form(t *testing.T) {
+	tests := []struct {
+		name   string
+		survey func() *models.Survey
+	}{
+		{
+			name:   "guest survey",
+			survey: embedTestSurvey,
+		},
+		{
+			name: "anonymous ATProto survey",
+			survey: func() *models.Survey {
+				s := embedTestSurvey()
+				s.URI = stringPtr("at://did:plc:alice/net.openmeet.survey/abc")
+				s.Definition.Anonymous = true
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := renderEmbed(t, tt.survey(), "")
+
+			assert.Contains(t, html, `hx-post="/surveys/lunch/responses"`)
+			assert.Contains(t, html, `name="embed" value="1"`)
+			assert.Contains(t, html, "Submit Response")
+			assert.NotContains(t, html, "Open full page to log in")
+		})
+	}
+}
+
+// TestSurveyEmbed_LoginPrompt ensures surveys answered from the voter's account
+// send them to the full page instead
+func TestSurveyEmbed_LoginPrompt(t *testing.T) {
+	survey := embedTestSurvey()
+	survey.URI = stringPtr("at://did:plc:alice/net.openmeet.survey/abc")
+
+	html := renderEmbed(t, survey, "")
+
+	assert.NotContains(t, html, "<form")
+	assert.Contains(t, html, "Open full page to log in")
+	assert.Contains(t, html, `href="/surveys/lunch" target="_blank"`)
+}
+
+// TestSurveyEmbed_LinksLeaveTheFrame ensures links don't load framed pages,
+// which refuse to render there
+func TestSurveyEmbed_LinksLeaveTheFrame(t *testing.T) {
+	html := renderEmbed(t, embedTestSurvey(), "")
+
+	assert.Contains(t, html, `href="/surveys/lunch/results" target="_blank"`)
+}
+
+// TestShareLinks_EmbedSnippet ensures the share section offers the iframe snippet
+func TestShareLinks_EmbedSnippet(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, ShareLinks(embedTestSurvey()).Render(context.Background(), &buf))
+	html := buf.String()
+
+	assert.Contains(t, html, `class="embed-btn"`)
+	assert.Contains(t, html, `id="share-embed-code"`)
+	assert.Contains(t, html, `data-title="Lunch order"`)
+	assert.Contains(t, html, "/embed")
+}
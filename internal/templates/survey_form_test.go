@@ -1,11 +1,17 @@
 package templates
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/openmeet-team/survey/internal/models"
+	"github.com/openmeet-team/survey/internal/oauth"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestSurveyOGMeta_TitleWithSuffix tests that og:title always includes " - OpenMeet Survey"
@@ -108,7 +114,389 @@ func TestSurveyOGMeta_TypeIsWebsite(t *testing.T) {
 	assert.Equal(t, "website", og.Type, "OG type should be website")
 }
 
+func TestSurveyOGMeta_Image(t *testing.T) {
+	og := surveyOGMeta(&models.Survey{Slug: "lunch-order", Title: "Lunch order", Version: 3})
+	assert.Equal(t, "/surveys/lunch-order/og.png?v=3", og.Image, "each survey has its own image, versioned")
+
+	og = surveyOGMeta(&models.Survey{Title: "Unsaved"})
+	assert.Equal(t, DefaultOGImage, og.Image)
+}
+
+// TestSurveyOGMeta_Twitter tests that the Twitter card repeats the Open
+// Graph fields
+func TestSurveyOGMeta_Twitter(t *testing.T) {
+	t.Cleanup(func() { SetTwitterSite("") })
+	SetTwitterSite("openmeet")
+
+	og := surveyOGMeta(&models.Survey{Slug: "lunch-order", Title: "Lunch order", Description: stringPtr("Where should we eat?"), Version: 3})
+	assert.Equal(t, &TwitterMeta{
+		Card:        "summary_large_image",
+		Site:        "@openmeet",
+		Title:       "Lunch order - Share Your Opinion on OpenMeet Survey",
+		Description: "Where should we eat?",
+		Image:       "/surveys/lunch-order/og.png?v=3",
+	}, og.Twitter)
+
+	// Pages without an image of their own get the small card
+	tw := twitterMeta(&OGMeta{Title: "OpenMeet Survey"})
+	assert.Equal(t, "summary", tw.Card)
+	assert.Equal(t, "@openmeet", tw.Site)
+
+	// A page's own card is kept
+	tw = twitterMeta(&OGMeta{Title: "OG title", Twitter: &TwitterMeta{Card: "summary", Title: "Card title", Image: "/card.png"}})
+	assert.Equal(t, &TwitterMeta{Card: "summary", Site: "@openmeet", Title: "Card title", Image: "/card.png"}, tw)
+
+	SetTwitterSite(" @openmeet ")
+	assert.Equal(t, "@openmeet", TwitterSite)
+	SetTwitterSite("")
+	assert.Equal(t, "", twitterMeta(&OGMeta{}).Site, "no site without an account")
+}
+
+// TestLayout_SocialTags tests that every page has a Twitter card, and that
+// preview images are absolute URLs on the site's host
+func TestLayout_SocialTags(t *testing.T) {
+	t.Run("pages without Open Graph data get the defaults", func(t *testing.T) {
+		html := renderIn(t, context.Background(), Layout("My Surveys", nil, nil, ""))
+		assert.Contains(t, html, `<meta name="twitter:card" content="summary">`)
+		assert.Contains(t, html, `<meta name="twitter:title" content="My Surveys - OpenMeet Survey">`)
+		assert.Contains(t, html, `<meta name="twitter:image" content="/static/og-image.png">`)
+		assert.Contains(t, html, `<meta property="og:image" content="/static/og-image.png">`)
+	})
+
+	t.Run("images are absolute on the site host", func(t *testing.T) {
+		SetSiteHost("survey.example.com")
+		t.Cleanup(func() { SetSiteHost("") })
+
+		html := renderIn(t, context.Background(), Layout("My Surveys", nil, nil, ""))
+		assert.Contains(t, html, `<meta property="og:image" content="https://survey.example.com/static/og-image.png">`)
+		assert.Contains(t, html, `<meta name="twitter:image" content="https://survey.example.com/static/og-image.png">`)
+
+		html = renderIn(t, context.Background(), SurveyForm(pagingTestSurvey(0), models.SurveyStatusOpen, nil, nil, nil, "", nil, nil))
+		assert.Contains(t, html, `<meta property="og:image" content="https://survey.example.com/surveys/long/og.png?v=0">`)
+		assert.Contains(t, html, `<meta name="twitter:image" content="https://survey.example.com/surveys/long/og.png?v=0">`)
+	})
+
+	t.Run("full URLs are kept", func(t *testing.T) {
+		SetSiteHost("survey.example.com")
+		t.Cleanup(func() { SetSiteHost("") })
+		assert.Equal(t, "https://cdn.example.com/card.png", ogImage(&OGMeta{Image: "https://cdn.example.com/card.png"}))
+	})
+}
+
+func TestSurveyOGMeta_Lang(t *testing.T) {
+	ctx := context.Background()
+	og := surveyOGMeta(&models.Survey{Title: "Encuesta", Lang: stringPtr("es-MX")})
+	assert.Equal(t, "es-MX", og.Lang)
+	assert.Equal(t, "es-MX", htmlLang(ctx, og))
+	assert.Equal(t, "es_MX", ogLocale(og.Lang))
+
+	// No declared language falls back to English and omits og:locale
+	og = surveyOGMeta(&models.Survey{Title: "Survey"})
+	assert.Equal(t, "en", htmlLang(ctx, og))
+	assert.Equal(t, "", ogLocale(og.Lang))
+	assert.Equal(t, "en", htmlLang(ctx, nil))
+
+	assert.Equal(t, "ja_JP", ogLocale("ja"), "territory is guessed when missing")
+	assert.Equal(t, "", ogLocale("not a language!"))
+}
+
 // Helper function to create string pointer
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestRatingHelpers(t *testing.T) {
+	ctx := context.Background()
+	question := models.Question{
+		ID:     "q1",
+		Type:   models.QuestionTypeRating,
+		Min:    1,
+		Max:    3,
+		Labels: []string{"Bad", "OK", "Good"},
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, ratingValues(question))
+	assert.Equal(t, "Bad", ratingLabel(question, 1))
+	assert.Equal(t, "Good", ratingLabel(question, 3))
+	assert.Equal(t, "", ratingLabel(question, 4), "out-of-range value has no label")
+	assert.Equal(t, "2 - OK", formatRatingValue(question, 2))
+
+	unlabelled := models.Question{ID: "q2", Type: models.QuestionTypeRating, Min: 0, Max: 10}
+	assert.Len(t, ratingValues(unlabelled), 11)
+	assert.Equal(t, "", ratingLabel(unlabelled, 5))
+	assert.Equal(t, "5", formatRatingValue(unlabelled, 5))
+
+	result := &models.QuestionResult{}
+	result.AddRating(3)
+	assert.Equal(t, "Average: 3.0 / 3 (1 rating)", formatRatingAverage(ctx, result, question))
+	result.AddRating(2)
+	assert.Equal(t, "Average: 2.5 / 3 (2 ratings)", formatRatingAverage(ctx, result, question))
+}
+
+func TestPublishedResultsHelpers(t *testing.T) {
+	ctx := context.Background()
+	publishedAt := time.Date(2025, 3, 7, 23, 30, 0, 0, time.FixedZone("", -5*3600))
+	assert.Equal(t, "Official results published by the author on March 8, 2025", formatPublishedOn(ctx, publishedAt))
+
+	assert.Equal(t, 0, publishedRatingTotal(&models.PublishedQuestionResult{}))
+	assert.Equal(t, 5, publishedRatingTotal(&models.PublishedQuestionResult{RatingCounts: map[int]int{1: 2, 3: 3}}))
+
+	assert.Equal(t, "0 text responses", formatTextResponseCount(ctx, 0))
+	assert.Equal(t, "1 text response", formatTextResponseCount(ctx, 1))
+	assert.Equal(t, "4 text responses", formatTextResponseCount(ctx, 4))
+}
+
+func TestSelectionHint(t *testing.T) {
+	ctx := context.Background()
+	options := []models.Option{{ID: "a", Text: "A"}, {ID: "b", Text: "B"}, {ID: "c", Text: "C"}}
+	hint := func(maxSelections int) string {
+		return selectionHint(ctx, models.Question{Type: models.QuestionTypeMulti, Options: options, MaxSelections: maxSelections})
+	}
+
+	assert.Equal(t, "", hint(0), "no cap has no hint")
+	assert.Equal(t, "Select 1 option", hint(1))
+	assert.Equal(t, "Select up to 2 options", hint(2))
+	assert.Equal(t, "", hint(3), "a cap of every option is no cap")
+}
+
+func TestCommentHelpers(t *testing.T) {
+	ctx := context.Background()
+	comments := []*models.Comment{{Text: "Nice survey"}}
+
+	survey := &models.Survey{Definition: models.SurveyDefinition{AllowComments: true}}
+	assert.True(t, showComments(survey, comments))
+	assert.False(t, showComments(survey, nil), "no comments, no section")
+
+	survey.Definition.AllowComments = false
+	assert.False(t, showComments(survey, comments), "hidden unless the author enables comments")
+
+	createdAt := time.Date(2025, 3, 7, 23, 30, 0, 0, time.FixedZone("", -5*3600))
+	assert.Equal(t, "March 8, 2025", formatCommentDate(ctx, createdAt))
+}
+
+func TestLastUpdatedText(t *testing.T) {
+	ctx := context.Background()
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	survey := func(recordUpdatedAt *time.Time) *models.Survey {
+		return &models.Survey{CreatedAt: created, RecordUpdatedAt: recordUpdatedAt}
+	}
+	at := func(d time.Duration) *time.Time {
+		t := created.Add(d)
+		return &t
+	}
+
+	assert.Equal(t, "", lastUpdatedText(ctx, survey(nil), now), "never indexed from a record")
+	assert.Equal(t, "", lastUpdatedText(ctx, survey(at(5*time.Second)), now), "create event shortly after createdAt is not an edit")
+	assert.Equal(t, "Last updated 2 days ago", lastUpdatedText(ctx, survey(at(7*24*time.Hour)), now))
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "just now", formatRelativeTime(ctx, now.Add(-30*time.Second), now))
+	assert.Equal(t, "1 minute ago", formatRelativeTime(ctx, now.Add(-time.Minute), now))
+	assert.Equal(t, "5 minutes ago", formatRelativeTime(ctx, now.Add(-5*time.Minute), now))
+	assert.Equal(t, "3 hours ago", formatRelativeTime(ctx, now.Add(-3*time.Hour), now))
+	assert.Equal(t, "1 day ago", formatRelativeTime(ctx, now.Add(-25*time.Hour), now))
+	assert.Equal(t, "on January 15, 2025", formatRelativeTime(ctx, time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC), now))
+}
+
+func TestOtherTextField(t *testing.T) {
+	assert.Equal(t, "q1-other-opt3", OtherTextField("q1", "opt3"))
+}
+
+func TestIsSurveyAuthor(t *testing.T) {
+	author := "did:plc:author"
+	survey := &models.Survey{AuthorDID: &author}
+
+	assert.True(t, IsSurveyAuthor(survey, &oauth.User{DID: author}))
+	assert.False(t, IsSurveyAuthor(survey, &oauth.User{DID: "did:plc:someoneelse"}))
+	assert.False(t, IsSurveyAuthor(survey, nil), "signed out")
+	assert.False(t, IsSurveyAuthor(&models.Survey{}, &oauth.User{DID: author}), "local-only survey has no author")
+}
+
+func pagingTestSurvey(pageSize int) *models.Survey {
+	questions := make([]models.Question, 5)
+	for i := range questions {
+		questions[i] = models.Question{ID: fmt.Sprintf("q%d", i+1), Text: fmt.Sprintf("Question %d", i+1), Type: models.QuestionTypeText, Required: i < 2}
+	}
+	return &models.Survey{Slug: "long", Title: "Long survey", Definition: models.SurveyDefinition{Questions: questions, PageSize: pageSize}}
+}
+
+func renderSurveyForm(t *testing.T, survey *models.Survey) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, SurveyForm(survey, models.SurveyStatusOpen, nil, nil, nil, "", nil, nil).Render(context.Background(), &buf))
+	return buf.String()
+}
+
+// TestSurveyForm_SinglePage ensures surveys without pages render as one form
+func TestSurveyForm_SinglePage(t *testing.T) {
+	html := renderSurveyForm(t, pagingTestSurvey(0))
+
+	assert.False(t, strings.Contains(html, `class="survey-page"`), "no pages")
+	assert.False(t, strings.Contains(html, `class="btn survey-page-next"`), "no page navigation")
+	assert.Equal(t, 5, strings.Count(html, `class="survey-question"`))
+	assert.Equal(t, 2, strings.Count(html, `class="survey-question" data-required`))
+	assert.Contains(t, html, "Submit Response")
+}
+
+// TestSurveyForm_Paged ensures a paged survey groups its questions into
+// pages with navigation and progress, submitting to the same endpoint
+func TestSurveyForm_Paged(t *testing.T) {
+	survey := pagingTestSurvey(2)
+	html := renderSurveyForm(t, survey)
+
+	assert.Equal(t, 3, strings.Count(html, `class="survey-page"`))
+	assert.Contains(t, html, `aria-label="Page 1 of 3"`)
+	assert.Contains(t, html, `aria-label="Page 3 of 3"`)
+	assert.Contains(t, html, `class="btn survey-page-back"`)
+	assert.Contains(t, html, `class="btn survey-page-next"`)
+	assert.Contains(t, html, `<progress class="survey-progress-bar" max="2" value="0"`)
+	assert.Contains(t, html, "0 of 2 required questions answered")
+
+	// Numbering carries on across pages
+	assert.Contains(t, html, "5. Question 5")
+	assert.Less(t, strings.Index(html, "2. Question 2"), strings.Index(html, `data-page="1"`))
+	assert.Greater(t, strings.Index(html, "3. Question 3"), strings.Index(html, `data-page="1"`))
+
+	// Every page is in the one form, which is submitted as before
+	assert.Equal(t, 1, strings.Count(html, "<form"))
+	assert.Contains(t, html, `hx-post="/surveys/long/responses"`)
+	assert.Contains(t, html, "Submit Response")
+
+	t.Run("pages follow page breaks", func(t *testing.T) {
+		survey := pagingTestSurvey(0)
+		survey.Definition.Questions[3].PageBreak = true
+		html := renderSurveyForm(t, survey)
+
+		assert.Equal(t, 2, strings.Count(html, `class="survey-page"`))
+	})
+
+	t.Run("no required questions means no progress bar", func(t *testing.T) {
+		survey := pagingTestSurvey(2)
+		for i := range survey.Definition.Questions {
+			survey.Definition.Questions[i].Required = false
+		}
+		html := renderSurveyForm(t, survey)
+
+		assert.False(t, strings.Contains(html, "<progress"), "no progress bar")
+		assert.Contains(t, html, `class="survey-progress-page"`)
+	})
+}
+
+// TestSurveyEmbed_Paged ensures embeds page long surveys too
+func TestSurveyEmbed_Paged(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, SurveyEmbed(pagingTestSurvey(2), models.SurveyStatusOpen, "").Render(context.Background(), &buf))
+
+	assert.Equal(t, 3, strings.Count(buf.String(), `class="survey-page"`))
+}
+
+// TestSurveyForm_Status ensures only an open survey shows its form; closed
+// and scheduled ones say when they closed or open, on the page and in their
+// link previews
+func TestSurveyForm_Status(t *testing.T) {
+	opens := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	closes := time.Date(2025, 3, 8, 17, 0, 0, 0, time.UTC)
+	survey := pagingTestSurvey(0)
+	survey.StartsAt = &opens
+	survey.EndsAt = &closes
+	render := func(ctx context.Context, status models.SurveyStatus) string {
+		return renderIn(t, ctx, SurveyForm(survey, status, nil, nil, nil, "", nil, nil))
+	}
+
+	t.Run("open", func(t *testing.T) {
+		html := render(context.Background(), models.SurveyStatusOpen)
+		assert.Contains(t, html, `hx-post="/surveys/long/responses"`)
+		assert.Contains(t, html, "Submit Response")
+		assert.False(t, strings.Contains(html, `class="survey-status"`))
+		assert.Contains(t, html, `content="Long survey - Share Your Opinion on OpenMeet Survey"`)
+		assert.Contains(t, html, `<meta name="twitter:card" content="summary_large_image">`)
+		assert.Contains(t, html, `<meta name="twitter:title" content="Long survey - Share Your Opinion on OpenMeet Survey">`)
+		assert.Contains(t, html, `<meta name="twitter:image" content="/surveys/long/og.png?v=0">`)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		html := render(context.Background(), models.SurveyStatusClosed)
+		assert.Contains(t, html, "This survey closed on March 8, 2025.")
+		assert.Contains(t, html, `<a href="/surveys/long/results" class="btn"`)
+		assert.False(t, strings.Contains(html, "<form"), "no form")
+		assert.False(t, strings.Contains(html, "Question 1"), "no questions")
+		assert.False(t, strings.Contains(html, "survey-form-messages"), "no form script")
+		assert.Contains(t, html, `<meta property="og:description" content="This survey closed on March 8, 2025. See the results on OpenMeet Survey."`)
+		assert.Contains(t, html, `<meta name="twitter:description" content="This survey closed on March 8, 2025. See the results on OpenMeet Survey.">`)
+
+		html = render(spanish, models.SurveyStatusClosed)
+		assert.Contains(t, html, "Esta encuesta cerró el 8 de marzo de 2025.")
+	})
+
+	t.Run("scheduled", func(t *testing.T) {
+		html := render(context.Background(), models.SurveyStatusScheduled)
+		assert.Contains(t, html, "This survey opens on March 1, 2025.")
+		assert.Contains(t, html, "Come back then to share your answers.")
+		assert.False(t, strings.Contains(html, "<form"), "no form")
+		assert.Contains(t, html, `<meta property="og:description" content="This survey opens on March 1, 2025."`)
+
+		html = render(spanish, models.SurveyStatusScheduled)
+		assert.Contains(t, html, "Esta encuesta abre el 1 de marzo de 2025.")
+	})
+
+	t.Run("embed", func(t *testing.T) {
+		html := renderIn(t, context.Background(), SurveyEmbed(survey, models.SurveyStatusClosed, ""))
+		assert.Contains(t, html, "This survey closed on March 8, 2025.")
+		assert.False(t, strings.Contains(html, "<form"), "no form")
+
+		html = renderIn(t, context.Background(), SurveyEmbed(survey, models.SurveyStatusOpen, ""))
+		assert.Contains(t, html, `name="embed" value="1"`)
+	})
+}
+
+// TestSurveyPageOGMeta_Status ensures an unavailable survey's preview keeps
+// its own description after the notice
+func TestSurveyPageOGMeta_Status(t *testing.T) {
+	closes := time.Date(2025, 3, 8, 17, 0, 0, 0, time.UTC)
+	description := "Where should we eat?"
+	survey := &models.Survey{Title: "Lunch", Description: &description, EndsAt: &closes}
+
+	og := surveyPageOGMeta(survey, models.SurveyStatusClosed)
+	assert.Equal(t, "Lunch - OpenMeet Survey", og.Title)
+	assert.Equal(t, "This survey closed on March 8, 2025. See the results on OpenMeet Survey. Where should we eat?", og.Description)
+	assert.Equal(t, og.Title, og.Twitter.Title)
+	assert.Equal(t, og.Description, og.Twitter.Description)
+
+	assert.Equal(t, surveyOGMeta(survey), surveyPageOGMeta(survey, models.SurveyStatusOpen))
+}
+
+// TestSurveyForm_Robots ensures only discoverable surveys may be indexed
+// once indexing is allowed
+func TestSurveyForm_Robots(t *testing.T) {
+	t.Cleanup(func() { SetNoIndex(true) })
+	survey := pagingTestSurvey(0)
+
+	html := renderSurveyForm(t, survey)
+	assert.Contains(t, html, `<meta name="robots" content="noindex, nofollow">`, "nothing is indexed by default")
+
+	SetNoIndex(false)
+	html = renderSurveyForm(t, survey)
+	assert.Contains(t, html, `<meta name="robots" content="noindex">`, "unlisted survey")
+
+	survey.Definition.Discoverable = true
+	html = renderSurveyForm(t, survey)
+	assert.False(t, strings.Contains(html, `name="robots"`), "discoverable survey")
+}
+
+// TestSurveyForm_OGEscaping ensures quotes in a title can't end a meta tag's
+// content attribute
+func TestSurveyForm_OGEscaping(t *testing.T) {
+	survey := pagingTestSurvey(0)
+	survey.Title = `Best "pizza" in town? <b>'Really'</b>`
+
+	html := renderSurveyForm(t, survey)
+	escaped := `Best &#34;pizza&#34; in town? &lt;b&gt;&#39;Really&#39;&lt;/b&gt; - Share Your Opinion on OpenMeet Survey`
+	assert.Contains(t, html, `<meta property="og:title" content="`+escaped+`">`)
+	assert.Contains(t, html, `<meta name="twitter:title" content="`+escaped+`">`)
+	assert.False(t, strings.Contains(html, `"pizza"`), "no raw quotes")
+}